@@ -0,0 +1,42 @@
+package config
+
+// Default attachment limits
+const (
+	// DefaultMaxAttachmentSize is the default maximum size of a single attachment (10 MiB)
+	DefaultMaxAttachmentSize = 10 << 20
+
+	// DefaultAttachmentQuota is the default total size of attachments a user can upload (100 MiB)
+	DefaultAttachmentQuota = 100 << 20
+)
+
+// DefaultAllowedAttachmentTypes lists the content types accepted by default
+// The content type is sniffed from the file contents, not taken from the client
+var DefaultAllowedAttachmentTypes = []string{
+	"image/png",
+	"image/jpeg",
+	"image/gif",
+	"image/webp",
+	"application/pdf",
+	"text/plain; charset=utf-8",
+}
+
+// AttachmentConfig holds attachment related configuration
+type AttachmentConfig struct {
+	// MaxFileSize is the maximum size of a single attachment in bytes
+	MaxFileSize int64
+
+	// UserQuota is the maximum total size of attachments a user can upload in bytes
+	UserQuota int64
+
+	// AllowedContentTypes lists the sniffed content types that can be uploaded
+	AllowedContentTypes []string
+}
+
+// DefaultAttachmentConfig returns a default AttachmentConfig with sensible defaults
+func DefaultAttachmentConfig() *AttachmentConfig {
+	return &AttachmentConfig{
+		MaxFileSize:         DefaultMaxAttachmentSize,
+		UserQuota:           DefaultAttachmentQuota,
+		AllowedContentTypes: DefaultAllowedAttachmentTypes,
+	}
+}
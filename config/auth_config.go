@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"time"
 )
 
@@ -11,8 +12,39 @@ const (
 
 	// DefaultRefreshTokenExpiry is the default duration for refresh tokens (7 days)
 	DefaultRefreshTokenExpiry = 7 * 24 * time.Hour
+
+	// DefaultMFAChallengeExpiry is the default duration for completing a login with an MFA code (5 minutes)
+	DefaultMFAChallengeExpiry = 5 * time.Minute
+
+	// DefaultReauthenticationWindow is the default time after logging in that a user without a password
+	// can change their email address or delete their account (5 minutes)
+	DefaultReauthenticationWindow = 5 * time.Minute
+)
+
+// UnverifiedAccess is what users who have not verified their email address can do
+type UnverifiedAccess string
+
+const (
+	// UnverifiedAccessFull lets unverified users do everything verified users can
+	UnverifiedAccessFull UnverifiedAccess = "full"
+
+	// UnverifiedAccessReadOnly lets unverified users log in and read, but not make changes
+	UnverifiedAccessReadOnly UnverifiedAccess = "read-only"
+
+	// UnverifiedAccessNone does not let unverified users log in
+	UnverifiedAccessNone UnverifiedAccess = "none"
 )
 
+// ParseUnverifiedAccess parses an UnverifiedAccess setting
+func ParseUnverifiedAccess(value string) (UnverifiedAccess, error) {
+	switch access := UnverifiedAccess(value); access {
+	case UnverifiedAccessFull, UnverifiedAccessReadOnly, UnverifiedAccessNone:
+		return access, nil
+	default:
+		return "", fmt.Errorf("unknown unverified access %q", value)
+	}
+}
+
 // AuthConfig holds authentication related configuration
 type AuthConfig struct {
 	// JWTSecret is the secret key used to sign JWT tokens
@@ -23,22 +55,38 @@ type AuthConfig struct {
 
 	// RefreshTokenExpiry is the duration for which a refresh token is valid
 	RefreshTokenExpiry time.Duration
+
+	// MFAChallengeExpiry is the duration for which an MFA challenge token is valid
+	MFAChallengeExpiry time.Duration
+
+	// UnverifiedAccess is what users who have not verified their email address can do
+	UnverifiedAccess UnverifiedAccess
+
+	// ReauthenticationWindow is the time after logging in that a user without a password can change
+	// their email address or delete their account, which users with a password confirm with it instead
+	ReauthenticationWindow time.Duration
 }
 
 // NewAuthConfig creates a new AuthConfig with the provided parameters
 func NewAuthConfig(jwtSecret string, accessTokenExpiry, refreshTokenExpiry time.Duration) *AuthConfig {
 	return &AuthConfig{
-		JWTSecret:          jwtSecret,
-		AccessTokenExpiry:  accessTokenExpiry,
-		RefreshTokenExpiry: refreshTokenExpiry,
+		JWTSecret:              jwtSecret,
+		AccessTokenExpiry:      accessTokenExpiry,
+		RefreshTokenExpiry:     refreshTokenExpiry,
+		MFAChallengeExpiry:     DefaultMFAChallengeExpiry,
+		UnverifiedAccess:       UnverifiedAccessReadOnly,
+		ReauthenticationWindow: DefaultReauthenticationWindow,
 	}
 }
 
 // DefaultAuthConfig returns a default AuthConfig with sensible defaults
 func DefaultAuthConfig() *AuthConfig {
 	return &AuthConfig{
-		JWTSecret:          "default-secret-key-change-in-production",
-		AccessTokenExpiry:  DefaultAccessTokenExpiry,
-		RefreshTokenExpiry: DefaultRefreshTokenExpiry,
+		JWTSecret:              "default-secret-key-change-in-production",
+		AccessTokenExpiry:      DefaultAccessTokenExpiry,
+		RefreshTokenExpiry:     DefaultRefreshTokenExpiry,
+		MFAChallengeExpiry:     DefaultMFAChallengeExpiry,
+		UnverifiedAccess:       UnverifiedAccessReadOnly,
+		ReauthenticationWindow: DefaultReauthenticationWindow,
 	}
 }
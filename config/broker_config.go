@@ -0,0 +1,51 @@
+package config
+
+import (
+	"time"
+)
+
+// Default message broker settings
+const (
+	// DefaultBrokerStream is the default name of the JetStream stream domain events are stored in
+	DefaultBrokerStream = "TODOMS"
+
+	// DefaultBrokerTopicPrefix is the default prefix of the subjects domain events are published on
+	DefaultBrokerTopicPrefix = "todoms"
+
+	// DefaultBrokerTimeout is the default time to connect to the broker and to wait for it to acknowledge an event
+	DefaultBrokerTimeout = 5 * time.Second
+
+	// DefaultBrokerDuplicateWindow is the default time within which the broker discards an event published again
+	DefaultBrokerDuplicateWindow = 10 * time.Minute
+)
+
+// BrokerConfig holds the settings of the NATS JetStream server domain events are published to
+type BrokerConfig struct {
+	// URL is the address of the NATS server, such as nats://localhost:4222
+	// Events are only logged when it is empty
+	URL string
+
+	// Stream is the name of the JetStream stream domain events are stored in
+	// It is created on startup if it does not exist, capturing every subject under the topic prefix
+	Stream string
+
+	// TopicPrefix is the prefix of the subjects domain events are published on, as "<prefix>.<event type>"
+	TopicPrefix string
+
+	// Timeout is the time to connect to the broker and to wait for it to acknowledge an event
+	Timeout time.Duration
+
+	// DuplicateWindow is the time within which the broker discards an event published again
+	// The outbox relay publishes an event again when it fails to record that the event was published
+	DuplicateWindow time.Duration
+}
+
+// DefaultBrokerConfig returns a default BrokerConfig with sensible defaults
+func DefaultBrokerConfig() *BrokerConfig {
+	return &BrokerConfig{
+		Stream:          DefaultBrokerStream,
+		TopicPrefix:     DefaultBrokerTopicPrefix,
+		Timeout:         DefaultBrokerTimeout,
+		DuplicateWindow: DefaultBrokerDuplicateWindow,
+	}
+}
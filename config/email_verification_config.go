@@ -0,0 +1,39 @@
+package config
+
+import (
+	"time"
+)
+
+// Default email verification settings
+const (
+	// DefaultVerificationTokenTTL is the default time a verification link can be used
+	DefaultVerificationTokenTTL = 24 * time.Hour
+
+	// DefaultVerificationResendCooldown is the default time before another verification email can be sent
+	DefaultVerificationResendCooldown = time.Minute
+
+	// DefaultVerificationURL is the default page verification links point to
+	DefaultVerificationURL = "http://localhost:8080/verify-email"
+)
+
+// EmailVerificationConfig holds email verification related configuration
+type EmailVerificationConfig struct {
+	// TokenTTL is the time a verification link can be used
+	TokenTTL time.Duration
+
+	// ResendCooldown is the time before another verification email can be sent to the same user
+	ResendCooldown time.Duration
+
+	// VerificationURL is the page verification links point to, with the token added as the token query parameter
+	// The page is expected to post the token to POST /api/auth/verify-email
+	VerificationURL string
+}
+
+// DefaultEmailVerificationConfig returns a default EmailVerificationConfig with sensible defaults
+func DefaultEmailVerificationConfig() *EmailVerificationConfig {
+	return &EmailVerificationConfig{
+		TokenTTL:        DefaultVerificationTokenTTL,
+		ResendCooldown:  DefaultVerificationResendCooldown,
+		VerificationURL: DefaultVerificationURL,
+	}
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"time"
+)
+
+// Default idempotency key settings
+const (
+	// DefaultIdempotencyKeyTTL is the default time a response is kept for replay
+	DefaultIdempotencyKeyTTL = 24 * time.Hour
+
+	// DefaultIdempotencyLockTimeout is the default time after which a request that never finished
+	// no longer holds its key
+	DefaultIdempotencyLockTimeout = time.Minute
+
+	// DefaultIdempotencyMaxKeyLength is the default maximum length of an Idempotency-Key header
+	DefaultIdempotencyMaxKeyLength = 255
+
+	// DefaultIdempotencyMaxBodySize is the default maximum size of a request body buffered for fingerprinting (16 MiB)
+	DefaultIdempotencyMaxBodySize = 16 << 20
+)
+
+// IdempotencyConfig holds Idempotency-Key related configuration
+type IdempotencyConfig struct {
+	// TTL is the time a response is kept for replay, after which the key can be used again
+	TTL time.Duration
+
+	// LockTimeout is the time after which a request that never finished, for example because the
+	// server stopped, no longer holds its key and a retry is processed again
+	LockTimeout time.Duration
+
+	// MaxKeyLength is the maximum length of an Idempotency-Key header
+	MaxKeyLength int
+
+	// MaxBodySize is the maximum size of a request body buffered for fingerprinting in bytes
+	// Requests with larger bodies are processed without an Idempotency-Key
+	MaxBodySize int64
+}
+
+// DefaultIdempotencyConfig returns a default IdempotencyConfig with sensible defaults
+func DefaultIdempotencyConfig() *IdempotencyConfig {
+	return &IdempotencyConfig{
+		TTL:          DefaultIdempotencyKeyTTL,
+		LockTimeout:  DefaultIdempotencyLockTimeout,
+		MaxKeyLength: DefaultIdempotencyMaxKeyLength,
+		MaxBodySize:  DefaultIdempotencyMaxBodySize,
+	}
+}
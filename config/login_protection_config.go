@@ -0,0 +1,69 @@
+package config
+
+import (
+	"time"
+)
+
+// Default login protection settings
+const (
+	// DefaultLoginIPBurst is the default number of login attempts a client IP can make at once
+	DefaultLoginIPBurst = 20
+
+	// DefaultLoginIPPeriod is the default time over which the IP allowance is refilled
+	DefaultLoginIPPeriod = time.Minute
+
+	// DefaultLoginAccountBurst is the default number of login attempts that can be made for an email at once
+	DefaultLoginAccountBurst = 5
+
+	// DefaultLoginAccountPeriod is the default time over which the account allowance is refilled
+	DefaultLoginAccountPeriod = time.Minute
+
+	// DefaultLockoutThreshold is the default number of consecutive failed logins after which an account is locked
+	DefaultLockoutThreshold = 5
+
+	// DefaultLockoutBaseDuration is the default duration of the first lockout, doubled on every further failure
+	DefaultLockoutBaseDuration = time.Minute
+
+	// DefaultLockoutMaxDuration is the default upper bound of a lockout
+	DefaultLockoutMaxDuration = time.Hour
+
+	// DefaultLockoutFailureWindow is the default time without failures after which the failure count starts over
+	DefaultLockoutFailureWindow = 24 * time.Hour
+)
+
+// LoginProtectionConfig holds rate limit and lockout configuration for the login endpoint
+type LoginProtectionConfig struct {
+	// IPBurst and IPPeriod limit the login attempts made from a single client IP
+	IPBurst  int
+	IPPeriod time.Duration
+
+	// AccountBurst and AccountPeriod limit the login attempts made for a single email
+	AccountBurst  int
+	AccountPeriod time.Duration
+
+	// LockoutThreshold is the number of consecutive failed logins after which an account is locked
+	LockoutThreshold int
+
+	// LockoutBaseDuration is the duration of the first lockout, doubled on every further failure
+	LockoutBaseDuration time.Duration
+
+	// LockoutMaxDuration is the upper bound of a lockout
+	LockoutMaxDuration time.Duration
+
+	// LockoutFailureWindow is the time without failures after which the failure count starts over
+	LockoutFailureWindow time.Duration
+}
+
+// DefaultLoginProtectionConfig returns a default LoginProtectionConfig with sensible defaults
+func DefaultLoginProtectionConfig() *LoginProtectionConfig {
+	return &LoginProtectionConfig{
+		IPBurst:              DefaultLoginIPBurst,
+		IPPeriod:             DefaultLoginIPPeriod,
+		AccountBurst:         DefaultLoginAccountBurst,
+		AccountPeriod:        DefaultLoginAccountPeriod,
+		LockoutThreshold:     DefaultLockoutThreshold,
+		LockoutBaseDuration:  DefaultLockoutBaseDuration,
+		LockoutMaxDuration:   DefaultLockoutMaxDuration,
+		LockoutFailureWindow: DefaultLockoutFailureWindow,
+	}
+}
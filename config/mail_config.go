@@ -0,0 +1,51 @@
+package config
+
+import (
+	"time"
+)
+
+// Mail drivers
+const (
+	// MailDriverLog logs emails, and also writes them to Dir when it is set, for development
+	MailDriverLog = "log"
+
+	// MailDriverSMTP sends emails through an SMTP server
+	MailDriverSMTP = "smtp"
+)
+
+// DefaultSMTPTimeout is the default time allowed for sending an email through SMTP
+const DefaultSMTPTimeout = 30 * time.Second
+
+// MailConfig holds email sending configuration
+type MailConfig struct {
+	// Driver selects how emails are sent, MailDriverLog or MailDriverSMTP
+	Driver string
+
+	// From is the sender address of emails
+	From string
+
+	// Dir is the directory the log driver writes emails to, or empty to only log them
+	Dir string
+
+	// SMTPHost and SMTPPort are the address of the SMTP server
+	SMTPHost string
+	SMTPPort string
+
+	// SMTPUsername and SMTPPassword authenticate with the SMTP server, which is skipped when the username is empty
+	SMTPUsername string
+	SMTPPassword string
+
+	// SMTPTimeout is the time allowed for sending an email through SMTP
+	SMTPTimeout time.Duration
+}
+
+// DefaultMailConfig returns a default MailConfig with sensible defaults
+func DefaultMailConfig() *MailConfig {
+	return &MailConfig{
+		Driver:      MailDriverLog,
+		From:        "todoms <no-reply@localhost>",
+		SMTPHost:    "localhost",
+		SMTPPort:    "1025",
+		SMTPTimeout: DefaultSMTPTimeout,
+	}
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// mfaEncryptionKeyInfo is the HKDF label an MFA encryption key is derived with
+const mfaEncryptionKeyInfo = "todoms mfa secret encryption key"
+
+// Default MFA settings
+const (
+	// DefaultMFAIssuer is the default account issuer shown by authenticator apps
+	DefaultMFAIssuer = "todoms"
+
+	// DefaultMFARecoveryCodeCount is the default number of recovery codes generated at a time
+	DefaultMFARecoveryCodeCount = 10
+)
+
+// MFAConfig holds TOTP two-factor authentication related configuration
+type MFAConfig struct {
+	// Issuer is the account issuer shown by authenticator apps
+	Issuer string
+
+	// EncryptionKey is the key TOTP secrets are encrypted with at rest
+	// Changing it makes the authenticators enrolled before unusable
+	EncryptionKey string
+
+	// RecoveryCodeCount is the number of recovery codes generated at a time
+	RecoveryCodeCount int
+}
+
+// DefaultMFAConfig returns a default MFAConfig with sensible defaults
+// EncryptionKey has no default and has to be set
+func DefaultMFAConfig() *MFAConfig {
+	return &MFAConfig{
+		Issuer:            DefaultMFAIssuer,
+		RecoveryCodeCount: DefaultMFARecoveryCodeCount,
+	}
+}
+
+// DeriveMFAEncryptionKey derives an EncryptionKey from another secret, such as the JWT secret,
+// for deployments that do not configure one
+// The key is derived with HKDF under its own label, so it reveals nothing of the secret it is derived from
+func DeriveMFAEncryptionKey(secret string) string {
+	key := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, []byte(secret), nil, []byte(mfaEncryptionKeyInfo)), key); err != nil {
+		// HKDF-SHA256 can expand up to 255 hashes, far more than one
+		panic(err)
+	}
+	return hex.EncodeToString(key)
+}
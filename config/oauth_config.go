@@ -0,0 +1,39 @@
+package config
+
+import (
+	"time"
+)
+
+// Default OAuth authorization server settings
+const (
+	// DefaultOAuthCodeTTL is the default time an authorization code can be redeemed in
+	DefaultOAuthCodeTTL = 5 * time.Minute
+
+	// DefaultOAuthAccessTokenTTL is the default lifetime of access tokens issued to clients
+	DefaultOAuthAccessTokenTTL = time.Hour
+
+	// DefaultOAuthRefreshTokenTTL is the default lifetime of refresh tokens issued to clients
+	DefaultOAuthRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// OAuthConfig holds the configuration of todoms as an OAuth 2.0 authorization server for third-party clients
+type OAuthConfig struct {
+	// CodeTTL is the time an authorization code can be redeemed in
+	CodeTTL time.Duration
+
+	// AccessTokenTTL is the lifetime of access tokens issued to clients
+	AccessTokenTTL time.Duration
+
+	// RefreshTokenTTL is the lifetime of refresh tokens issued to clients
+	// A refresh token is replaced by a new one each time it is used
+	RefreshTokenTTL time.Duration
+}
+
+// DefaultOAuthConfig returns a default OAuthConfig with sensible defaults
+func DefaultOAuthConfig() *OAuthConfig {
+	return &OAuthConfig{
+		CodeTTL:         DefaultOAuthCodeTTL,
+		AccessTokenTTL:  DefaultOAuthAccessTokenTTL,
+		RefreshTokenTTL: DefaultOAuthRefreshTokenTTL,
+	}
+}
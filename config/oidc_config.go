@@ -0,0 +1,58 @@
+package config
+
+import (
+	"time"
+)
+
+// Default OpenID Connect settings
+const (
+	// DefaultOIDCStateTTL is the default time a login or link with a provider can be completed in
+	DefaultOIDCStateTTL = 10 * time.Minute
+
+	// DefaultOIDCHTTPTimeout is the default timeout of requests to providers
+	DefaultOIDCHTTPTimeout = 10 * time.Second
+)
+
+// DefaultOIDCScopes are the scopes requested when a provider has none configured
+var DefaultOIDCScopes = []string{"openid", "email", "profile"}
+
+// OIDCProviderConfig holds the settings of an OpenID Connect provider users can log in with
+type OIDCProviderConfig struct {
+	// Name identifies the provider in URLs and linked identities
+	// Changing it unlinks the identities linked before
+	Name string
+
+	// Issuer is the issuer URL of the provider, its configuration is discovered from
+	Issuer string
+
+	// ClientID and ClientSecret are the credentials of the client registered with the provider
+	ClientID     string
+	ClientSecret string
+
+	// RedirectURL is the page the provider redirects the browser back to, registered with the provider
+	RedirectURL string
+
+	// Scopes are the scopes requested, which have to include openid
+	Scopes []string
+}
+
+// OIDCConfig holds OpenID Connect social login related configuration
+type OIDCConfig struct {
+	// Providers are the providers users can log in with
+	Providers []OIDCProviderConfig
+
+	// StateTTL is the time a login or link with a provider can be completed in
+	StateTTL time.Duration
+
+	// HTTPTimeout is the timeout of requests to providers
+	HTTPTimeout time.Duration
+}
+
+// DefaultOIDCConfig returns a default OIDCConfig with sensible defaults
+// No provider is configured by default
+func DefaultOIDCConfig() *OIDCConfig {
+	return &OIDCConfig{
+		StateTTL:    DefaultOIDCStateTTL,
+		HTTPTimeout: DefaultOIDCHTTPTimeout,
+	}
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"time"
+)
+
+// Default outbox relay settings
+const (
+	// DefaultOutboxBatchSize is the default number of events the relay publishes per run
+	DefaultOutboxBatchSize = 100
+
+	// DefaultOutboxBaseBackoff is the default delay before the first retry, doubled on every further retry
+	DefaultOutboxBaseBackoff = time.Second
+
+	// DefaultOutboxMaxBackoff is the default upper bound of the delay between retries
+	DefaultOutboxMaxBackoff = 5 * time.Minute
+
+	// DefaultOutboxRetention is the default time published events are kept before they are pruned
+	DefaultOutboxRetention = 7 * 24 * time.Hour
+)
+
+// OutboxConfig holds outbox relay related configuration
+type OutboxConfig struct {
+	// BatchSize is the number of events the relay publishes per run
+	BatchSize int
+
+	// BaseBackoff is the delay before the first retry, doubled on every further retry
+	BaseBackoff time.Duration
+
+	// MaxBackoff is the upper bound of the delay between retries
+	// Events are retried until they are published, so there is no limit on the number of attempts
+	MaxBackoff time.Duration
+
+	// Retention is the time published events are kept before they are pruned
+	Retention time.Duration
+}
+
+// DefaultOutboxConfig returns a default OutboxConfig with sensible defaults
+func DefaultOutboxConfig() *OutboxConfig {
+	return &OutboxConfig{
+		BatchSize:   DefaultOutboxBatchSize,
+		BaseBackoff: DefaultOutboxBaseBackoff,
+		MaxBackoff:  DefaultOutboxMaxBackoff,
+		Retention:   DefaultOutboxRetention,
+	}
+}
@@ -0,0 +1,79 @@
+package config
+
+import "fmt"
+
+// PasswordHashAlgorithm is the algorithm new password hashes are made with
+type PasswordHashAlgorithm string
+
+const (
+	// PasswordHashArgon2id hashes passwords with Argon2id, which hashes passwords of any length in full
+	PasswordHashArgon2id PasswordHashAlgorithm = "argon2id"
+
+	// PasswordHashBcrypt hashes passwords with bcrypt, which cannot hash passwords longer than 72 bytes
+	PasswordHashBcrypt PasswordHashAlgorithm = "bcrypt"
+)
+
+// ParsePasswordHashAlgorithm parses a PasswordHashAlgorithm setting
+func ParsePasswordHashAlgorithm(value string) (PasswordHashAlgorithm, error) {
+	switch algorithm := PasswordHashAlgorithm(value); algorithm {
+	case PasswordHashArgon2id, PasswordHashBcrypt:
+		return algorithm, nil
+	default:
+		return "", fmt.Errorf("unknown password hash algorithm %q", value)
+	}
+}
+
+// Default password hash settings
+// The Argon2id parameters are the second recommended option of RFC 9106
+const (
+	// DefaultArgon2Memory is the default memory, in KiB, Argon2id uses to hash a password (64 MiB)
+	DefaultArgon2Memory = 64 * 1024
+
+	// DefaultArgon2Iterations is the default number of passes Argon2id makes over the memory
+	DefaultArgon2Iterations = 3
+
+	// DefaultArgon2Parallelism is the default number of threads Argon2id hashes a password with
+	DefaultArgon2Parallelism = 4
+
+	// DefaultArgon2SaltLength is the default length, in bytes, of the random salt of an Argon2id hash
+	DefaultArgon2SaltLength = 16
+
+	// DefaultArgon2KeyLength is the default length, in bytes, of an Argon2id hash
+	DefaultArgon2KeyLength = 32
+
+	// DefaultBcryptCost is the default cost of a bcrypt hash
+	DefaultBcryptCost = 12
+)
+
+// PasswordHashConfig holds the algorithm and parameters passwords are hashed with
+// Hashes made with another algorithm or other parameters are still checked,
+// and are made again with the current ones when their user logs in
+type PasswordHashConfig struct {
+	// Algorithm is the algorithm new password hashes are made with
+	Algorithm PasswordHashAlgorithm
+
+	// Argon2Memory (in KiB), Argon2Iterations and Argon2Parallelism are the cost parameters of Argon2id
+	Argon2Memory      uint32
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+
+	// Argon2SaltLength and Argon2KeyLength are the lengths, in bytes, of the salt and the hash of Argon2id
+	Argon2SaltLength uint32
+	Argon2KeyLength  uint32
+
+	// BcryptCost is the cost of bcrypt
+	BcryptCost int
+}
+
+// DefaultPasswordHashConfig returns a default PasswordHashConfig with sensible defaults
+func DefaultPasswordHashConfig() *PasswordHashConfig {
+	return &PasswordHashConfig{
+		Algorithm:         PasswordHashArgon2id,
+		Argon2Memory:      DefaultArgon2Memory,
+		Argon2Iterations:  DefaultArgon2Iterations,
+		Argon2Parallelism: DefaultArgon2Parallelism,
+		Argon2SaltLength:  DefaultArgon2SaltLength,
+		Argon2KeyLength:   DefaultArgon2KeyLength,
+		BcryptCost:        DefaultBcryptCost,
+	}
+}
@@ -0,0 +1,56 @@
+package config
+
+// Default password policy settings
+const (
+	// DefaultPasswordMinLength is the default minimum number of characters of a password
+	DefaultPasswordMinLength = 8
+
+	// DefaultPasswordMaxLength is the default maximum number of characters of a password
+	DefaultPasswordMaxLength = 128
+
+	// DefaultPasswordMinCharacterClasses is the default number of character classes
+	// (lowercase, uppercase, digits and symbols) a password has to mix
+	DefaultPasswordMinCharacterClasses = 1
+
+	// DefaultPasswordMinStrength is the default minimum strength score of a password, from 0 (too guessable) to 4
+	DefaultPasswordMinStrength = 2
+
+	// DefaultBreachedPasswordMinCount is the default number of breaches a password has to appear in to be refused
+	DefaultBreachedPasswordMinCount = 1
+)
+
+// PasswordPolicyConfig holds the rules new passwords are checked against
+type PasswordPolicyConfig struct {
+	// MinLength and MaxLength bound the number of characters of a password
+	MinLength int
+	MaxLength int
+
+	// MinCharacterClasses is the number of character classes (lowercase, uppercase, digits and symbols)
+	// a password has to mix
+	MinCharacterClasses int
+
+	// ForbidEmail refuses passwords that contain the email address or its local part
+	ForbidEmail bool
+
+	// MinStrength is the minimum estimated strength score of a password, from 0 (too guessable) to 4
+	MinStrength int
+
+	// BreachedPasswordsDir is the directory of the breached-password corpus, with one file per
+	// SHA-1 hash prefix in the format of the Have I Been Pwned range API, and no corpus is checked when empty
+	BreachedPasswordsDir string
+
+	// BreachedMinCount is the number of breaches a password has to appear in to be refused
+	BreachedMinCount int
+}
+
+// DefaultPasswordPolicyConfig returns a default PasswordPolicyConfig with sensible defaults
+func DefaultPasswordPolicyConfig() *PasswordPolicyConfig {
+	return &PasswordPolicyConfig{
+		MinLength:           DefaultPasswordMinLength,
+		MaxLength:           DefaultPasswordMaxLength,
+		MinCharacterClasses: DefaultPasswordMinCharacterClasses,
+		ForbidEmail:         true,
+		MinStrength:         DefaultPasswordMinStrength,
+		BreachedMinCount:    DefaultBreachedPasswordMinCount,
+	}
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"time"
+)
+
+// Default password reset settings
+const (
+	// DefaultPasswordResetTokenTTL is the default time a password reset link can be used
+	DefaultPasswordResetTokenTTL = 30 * time.Minute
+
+	// DefaultPasswordResetCooldown is the default time before another password reset email can be sent
+	DefaultPasswordResetCooldown = time.Minute
+
+	// DefaultPasswordResetURL is the default page password reset links point to
+	DefaultPasswordResetURL = "http://localhost:8080/reset-password"
+)
+
+// PasswordResetConfig holds password reset related configuration
+type PasswordResetConfig struct {
+	// TokenTTL is the time a password reset link can be used
+	TokenTTL time.Duration
+
+	// Cooldown is the time before another password reset email can be sent to the same user
+	Cooldown time.Duration
+
+	// ResetURL is the page password reset links point to, with the token added as the token query parameter
+	// The page is expected to post the token with the new password to POST /api/auth/reset-password
+	ResetURL string
+}
+
+// DefaultPasswordResetConfig returns a default PasswordResetConfig with sensible defaults
+func DefaultPasswordResetConfig() *PasswordResetConfig {
+	return &PasswordResetConfig{
+		TokenTTL: DefaultPasswordResetTokenTTL,
+		Cooldown: DefaultPasswordResetCooldown,
+		ResetURL: DefaultPasswordResetURL,
+	}
+}
@@ -0,0 +1,32 @@
+package config
+
+import (
+	"time"
+)
+
+// Default personal access token settings
+const (
+	// DefaultMaxPersonalAccessTokens is the default number of personal access tokens a user can have
+	DefaultMaxPersonalAccessTokens = 50
+
+	// DefaultPersonalAccessTokenLastUsedInterval is the default interval the last use of a token is recorded at
+	DefaultPersonalAccessTokenLastUsedInterval = time.Minute
+)
+
+// PersonalAccessTokenConfig holds the configuration of the tokens users create for scripts and CLIs
+type PersonalAccessTokenConfig struct {
+	// MaxTokens is the number of tokens a user can have, including expired ones that are not purged yet
+	MaxTokens int
+
+	// LastUsedInterval is the interval the last use of a token is recorded at
+	// Uses within the interval of the recorded one are not written to the database
+	LastUsedInterval time.Duration
+}
+
+// DefaultPersonalAccessTokenConfig returns a default PersonalAccessTokenConfig with sensible defaults
+func DefaultPersonalAccessTokenConfig() *PersonalAccessTokenConfig {
+	return &PersonalAccessTokenConfig{
+		MaxTokens:        DefaultMaxPersonalAccessTokens,
+		LastUsedInterval: DefaultPersonalAccessTokenLastUsedInterval,
+	}
+}
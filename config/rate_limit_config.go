@@ -0,0 +1,42 @@
+package config
+
+import (
+	"time"
+)
+
+// Default API rate limit settings
+const (
+	// DefaultAPIRateLimitBurst is the default number of requests a user can make at once
+	DefaultAPIRateLimitBurst = 120
+
+	// DefaultAPIRateLimitPeriod is the default time over which the allowance is refilled
+	DefaultAPIRateLimitPeriod = time.Minute
+)
+
+// RateLimitRule allows Burst requests at once, refilled at Burst requests per Period
+type RateLimitRule struct {
+	Burst  int
+	Period time.Duration
+}
+
+// RateLimitConfig holds the per-user rate limits of authenticated API requests
+type RateLimitConfig struct {
+	// Default is the limit of route groups without an override
+	Default RateLimitRule
+
+	// Routes overrides the limit of route groups, keyed by route path prefix
+	// A route group has its own allowance, and the longest matching prefix applies
+	Routes map[string]RateLimitRule
+}
+
+// DefaultRateLimitConfig returns a default RateLimitConfig with sensible defaults
+func DefaultRateLimitConfig() *RateLimitConfig {
+	return &RateLimitConfig{
+		Default: RateLimitRule{Burst: DefaultAPIRateLimitBurst, Period: DefaultAPIRateLimitPeriod},
+		Routes: map[string]RateLimitRule{
+			"/api/todos/:id/attachments": {Burst: 30, Period: time.Minute},
+			"/api/sync":                  {Burst: 60, Period: time.Minute},
+			"/api/stream":                {Burst: 10, Period: time.Minute},
+		},
+	}
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"time"
+)
+
+// Default real-time stream settings
+const (
+	// DefaultStreamHeartbeatInterval is the default interval of heartbeats on idle streams
+	DefaultStreamHeartbeatInterval = 25 * time.Second
+
+	// DefaultStreamReplayLimit is the default maximum number of events replayed on resume
+	DefaultStreamReplayLimit = 1000
+
+	// DefaultStreamBufferSize is the default number of events buffered for a slow client
+	DefaultStreamBufferSize = 256
+
+	// DefaultStreamTicketTTL is the default time a stream ticket can be used within
+	DefaultStreamTicketTTL = 30 * time.Second
+)
+
+// StreamConfig holds real-time stream related configuration
+type StreamConfig struct {
+	// HeartbeatInterval is the interval of heartbeats on idle streams, which keeps proxies from closing them
+	HeartbeatInterval time.Duration
+
+	// ReplayLimit is the maximum number of events replayed on resume
+	// A client that missed more is told to reload its todos instead
+	ReplayLimit int
+
+	// BufferSize is the number of events buffered for a slow client before it is disconnected
+	BufferSize int
+
+	// TicketTTL is the time a stream ticket can be used within
+	// Tickets are used once, right after they are issued, so it only has to cover the time to connect
+	TicketTTL time.Duration
+}
+
+// DefaultStreamConfig returns a default StreamConfig with sensible defaults
+func DefaultStreamConfig() *StreamConfig {
+	return &StreamConfig{
+		HeartbeatInterval: DefaultStreamHeartbeatInterval,
+		ReplayLimit:       DefaultStreamReplayLimit,
+		BufferSize:        DefaultStreamBufferSize,
+		TicketTTL:         DefaultStreamTicketTTL,
+	}
+}
@@ -0,0 +1,27 @@
+package config
+
+// Default todo quotas
+const (
+	// DefaultMaxTodosPerUser is the default number of todos a user can own
+	DefaultMaxTodosPerUser = 10000
+
+	// DefaultMaxDescriptionLength is the default maximum length of a todo description in characters
+	DefaultMaxDescriptionLength = 10000
+)
+
+// TodoQuotaConfig holds the limits on the todos a user can store
+type TodoQuotaConfig struct {
+	// MaxTodosPerUser is the number of todos a user can own
+	MaxTodosPerUser int
+
+	// MaxDescriptionLength is the maximum length of a todo description in characters
+	MaxDescriptionLength int
+}
+
+// DefaultTodoQuotaConfig returns a default TodoQuotaConfig with sensible defaults
+func DefaultTodoQuotaConfig() *TodoQuotaConfig {
+	return &TodoQuotaConfig{
+		MaxTodosPerUser:      DefaultMaxTodosPerUser,
+		MaxDescriptionLength: DefaultMaxDescriptionLength,
+	}
+}
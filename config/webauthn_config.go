@@ -0,0 +1,46 @@
+package config
+
+import (
+	"time"
+)
+
+// Default WebAuthn settings
+const (
+	// DefaultWebAuthnRPID is the default relying party ID, the domain passkeys are scoped to
+	DefaultWebAuthnRPID = "localhost"
+
+	// DefaultWebAuthnRPName is the default relying party name shown by authenticators
+	DefaultWebAuthnRPName = "todoms"
+
+	// DefaultWebAuthnOrigin is the default origin of the pages that register and use passkeys
+	DefaultWebAuthnOrigin = "http://localhost:8080"
+
+	// DefaultWebAuthnChallengeTTL is the default time a registration or login ceremony can be completed in
+	DefaultWebAuthnChallengeTTL = 5 * time.Minute
+)
+
+// WebAuthnConfig holds passkey related configuration
+type WebAuthnConfig struct {
+	// RPID is the relying party ID, the domain passkeys are scoped to
+	// Changing it makes the passkeys registered before unusable
+	RPID string
+
+	// RPName is the relying party name shown by authenticators
+	RPName string
+
+	// Origins are the origins of the pages allowed to register and use passkeys
+	Origins []string
+
+	// ChallengeTTL is the time a registration or login ceremony can be completed in
+	ChallengeTTL time.Duration
+}
+
+// DefaultWebAuthnConfig returns a default WebAuthnConfig with sensible defaults
+func DefaultWebAuthnConfig() *WebAuthnConfig {
+	return &WebAuthnConfig{
+		RPID:         DefaultWebAuthnRPID,
+		RPName:       DefaultWebAuthnRPName,
+		Origins:      []string{DefaultWebAuthnOrigin},
+		ChallengeTTL: DefaultWebAuthnChallengeTTL,
+	}
+}
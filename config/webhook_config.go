@@ -0,0 +1,56 @@
+package config
+
+import (
+	"time"
+)
+
+// Default webhook delivery settings
+const (
+	// DefaultWebhookMaxAttempts is the default number of delivery attempts before a delivery is dead-lettered
+	DefaultWebhookMaxAttempts = 8
+
+	// DefaultWebhookBaseBackoff is the default delay before the first retry, doubled on every further retry
+	DefaultWebhookBaseBackoff = 30 * time.Second
+
+	// DefaultWebhookMaxBackoff is the default upper bound of the delay between retries
+	DefaultWebhookMaxBackoff = 6 * time.Hour
+
+	// DefaultWebhookTimeout is the default time a receiver has to respond to a delivery
+	DefaultWebhookTimeout = 10 * time.Second
+
+	// DefaultWebhookBatchSize is the default number of deliveries the worker sends per run
+	DefaultWebhookBatchSize = 50
+)
+
+// WebhookConfig holds webhook delivery related configuration
+type WebhookConfig struct {
+	// MaxAttempts is the number of delivery attempts before a delivery is dead-lettered
+	MaxAttempts int
+
+	// BaseBackoff is the delay before the first retry, doubled on every further retry
+	BaseBackoff time.Duration
+
+	// MaxBackoff is the upper bound of the delay between retries
+	MaxBackoff time.Duration
+
+	// Timeout is the time a receiver has to respond to a delivery
+	Timeout time.Duration
+
+	// BatchSize is the number of deliveries the worker sends per run
+	BatchSize int
+
+	// AllowPrivateNetworks lets webhooks point at loopback, private and link-local addresses
+	// It is meant for local development, as it lets users make the server send requests into its own network
+	AllowPrivateNetworks bool
+}
+
+// DefaultWebhookConfig returns a default WebhookConfig with sensible defaults
+func DefaultWebhookConfig() *WebhookConfig {
+	return &WebhookConfig{
+		MaxAttempts: DefaultWebhookMaxAttempts,
+		BaseBackoff: DefaultWebhookBaseBackoff,
+		MaxBackoff:  DefaultWebhookMaxBackoff,
+		Timeout:     DefaultWebhookTimeout,
+		BatchSize:   DefaultWebhookBatchSize,
+	}
+}
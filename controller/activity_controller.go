@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/yukimaterrace/todoms/handler"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+)
+
+// ActivityController handles activity log related HTTP requests
+type ActivityController struct {
+	activityService service.ActivityService
+	authHandler     *handler.AuthHandler
+}
+
+// NewActivityController creates a new ActivityController
+func NewActivityController(activityService service.ActivityService, authHandler *handler.AuthHandler) *ActivityController {
+	return &ActivityController{
+		activityService: activityService,
+		authHandler:     authHandler,
+	}
+}
+
+// RegisterRoutes registers the activity routes to the given Echo instance
+func (c *ActivityController) RegisterRoutes(e *echo.Echo) {
+	e.GET("/api/activity", c.GetFeed, c.authHandler.RequireAuth)
+	e.GET("/api/todos/:id/activity", c.GetTodoActivity, c.authHandler.RequireAuth)
+}
+
+// handleActivityError handles error patterns for activity operations
+func (c *ActivityController) handleActivityError(ctx echo.Context, err error) error {
+	switch err {
+	case service.ErrInvalidCursor:
+		return ctx.JSON(http.StatusBadRequest, model.InvalidPaginationResponse)
+	default:
+		return handleTodoError(ctx, err)
+	}
+}
+
+// GetFeed returns a page of the authenticated user's activity feed
+// The page is selected with the optional cursor and limit query parameters
+func (c *ActivityController) GetFeed(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	limit, ok := getLimitFromQueryWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by getLimitFromQueryWithResponse
+	}
+
+	// Get feed from service
+	events, nextCursor, err := c.activityService.GetFeed(ctx.Request().Context(), userID, ctx.QueryParam("cursor"), limit)
+	if err != nil {
+		return c.handleActivityError(ctx, err)
+	}
+
+	// Return response
+	return ctx.JSON(http.StatusOK, model.NewActivityListResponse(events, nextCursor))
+}
+
+// GetTodoActivity returns a page of the activity of a todo
+// The page is selected with the optional cursor and limit query parameters
+func (c *ActivityController) GetTodoActivity(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	// Parse todo ID from URL parameter
+	todoID, ok := getUUIDFromParamWithResponse(ctx, "id", model.InvalidTodoIDFormatResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+
+	limit, ok := getLimitFromQueryWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by getLimitFromQueryWithResponse
+	}
+
+	// Get activity from service
+	events, nextCursor, err := c.activityService.GetTodoActivity(ctx.Request().Context(), userID, todoID, ctx.QueryParam("cursor"), limit)
+	if err != nil {
+		return c.handleActivityError(ctx, err)
+	}
+
+	// Return response
+	return ctx.JSON(http.StatusOK, model.NewActivityListResponse(events, nextCursor))
+}
@@ -0,0 +1,258 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/yukimaterrace/todoms/handler"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+)
+
+// Values of the status query parameter of the user list
+const (
+	userStatusActive   = "active"
+	userStatusDisabled = "disabled"
+)
+
+// AdminController handles HTTP requests of admins managing the users
+type AdminController struct {
+	adminService service.AdminService
+	authHandler  *handler.AuthHandler
+}
+
+// NewAdminController creates a new AdminController
+func NewAdminController(adminService service.AdminService, authHandler *handler.AuthHandler) *AdminController {
+	return &AdminController{
+		adminService: adminService,
+		authHandler:  authHandler,
+	}
+}
+
+// RegisterRoutes registers the admin routes to the given Echo instance
+func (c *AdminController) RegisterRoutes(e *echo.Echo) {
+	admin := e.Group("/api/admin", c.authHandler.RequireAccountAuth, c.authHandler.RequireRole(model.RoleAdmin))
+	admin.GET("/users", c.ListUsers)
+	admin.GET("/users/:id", c.GetUser)
+	admin.POST("/users/:id/disable", c.DisableUser)
+	admin.POST("/users/:id/enable", c.EnableUser)
+	admin.POST("/users/:id/password-reset", c.ForcePasswordReset)
+	admin.GET("/users/:id/sessions", c.ListSessions)
+	admin.DELETE("/users/:id/sessions", c.RevokeAllSessions)
+	admin.DELETE("/users/:id/sessions/:sessionId", c.RevokeSession)
+	admin.GET("/users/:id/todo-stats", c.GetTodoStats)
+}
+
+// handleAdminError handles error patterns for admin operations
+func (c *AdminController) handleAdminError(ctx echo.Context, err error) error {
+	switch err {
+	case service.ErrUserNotFound:
+		return ctx.JSON(http.StatusNotFound, model.UserNotFoundResponse)
+	case service.ErrSessionNotFound:
+		return ctx.JSON(http.StatusNotFound, model.SessionNotFoundResponse)
+	case service.ErrCannotDisableSelf:
+		return ctx.JSON(http.StatusBadRequest, model.CannotDisableSelfResponse)
+	case service.ErrInvalidCursor:
+		return ctx.JSON(http.StatusBadRequest, model.InvalidPaginationResponse)
+	default:
+		return ctx.JSON(http.StatusInternalServerError, model.FailedToOperateResponse)
+	}
+}
+
+// getUserFilterFromQueryWithResponse parses the q, role and status query parameters of the user list
+// If parsing fails, it sends an error response and returns false
+func getUserFilterFromQueryWithResponse(ctx echo.Context) (*model.UserFilter, bool) {
+	filter := &model.UserFilter{
+		Query: ctx.QueryParam("q"),
+		Role:  ctx.QueryParam("role"),
+	}
+
+	switch filter.Role {
+	case "", model.RoleUser, model.RoleAdmin:
+	default:
+		ctx.JSON(http.StatusBadRequest, model.InvalidUserFilterResponse)
+		return nil, false
+	}
+
+	switch ctx.QueryParam("status") {
+	case "":
+	case userStatusActive:
+		disabled := false
+		filter.Disabled = &disabled
+	case userStatusDisabled:
+		disabled := true
+		filter.Disabled = &disabled
+	default:
+		ctx.JSON(http.StatusBadRequest, model.InvalidUserFilterResponse)
+		return nil, false
+	}
+
+	return filter, true
+}
+
+// ListUsers returns a page of the users, newest first
+// The users are filtered with the optional q, role and status query parameters,
+// and the page is selected with the optional cursor and limit query parameters
+func (c *AdminController) ListUsers(ctx echo.Context) error {
+	filter, ok := getUserFilterFromQueryWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by getUserFilterFromQueryWithResponse
+	}
+
+	limit, ok := getLimitFromQueryWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by getLimitFromQueryWithResponse
+	}
+
+	users, nextCursor, err := c.adminService.ListUsers(ctx.Request().Context(), filter, ctx.QueryParam("cursor"), limit)
+	if err != nil {
+		return c.handleAdminError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, model.NewAdminUserListResponse(users, nextCursor))
+}
+
+// GetUser returns the account of a user
+func (c *AdminController) GetUser(ctx echo.Context) error {
+	userID, ok := getUUIDFromParamWithResponse(ctx, "id", model.InvalidUserIDParamResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+
+	user, err := c.adminService.GetUser(ctx.Request().Context(), userID)
+	if err != nil {
+		return c.handleAdminError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, model.NewAdminUserResponse(user))
+}
+
+// DisableUser stops a user from logging in and ends their sessions
+func (c *AdminController) DisableUser(ctx echo.Context) error {
+	return c.updateUser(ctx, c.adminService.DisableUser)
+}
+
+// EnableUser lets a disabled user log in again
+func (c *AdminController) EnableUser(ctx echo.Context) error {
+	return c.updateUser(ctx, c.adminService.EnableUser)
+}
+
+// updateUser applies update to the user in the URL on behalf of the authenticated admin
+// and returns the updated account
+func (c *AdminController) updateUser(
+	ctx echo.Context,
+	update func(ctx context.Context, adminID, userID uuid.UUID) (*model.User, error),
+) error {
+	adminID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	userID, ok := getUUIDFromParamWithResponse(ctx, "id", model.InvalidUserIDParamResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+
+	user, err := update(ctx.Request().Context(), adminID, userID)
+	if err != nil {
+		return c.handleAdminError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, model.NewAdminUserResponse(user))
+}
+
+// ForcePasswordReset removes the password of a user, ending their sessions,
+// and emails them a link to choose a new one
+func (c *AdminController) ForcePasswordReset(ctx echo.Context) error {
+	adminID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	userID, ok := getUUIDFromParamWithResponse(ctx, "id", model.InvalidUserIDParamResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+
+	if err := c.adminService.ForcePasswordReset(ctx.Request().Context(), adminID, userID); err != nil {
+		return c.handleAdminError(ctx, err)
+	}
+
+	return ctx.NoContent(http.StatusAccepted)
+}
+
+// ListSessions returns the sessions of a user
+func (c *AdminController) ListSessions(ctx echo.Context) error {
+	userID, ok := getUUIDFromParamWithResponse(ctx, "id", model.InvalidUserIDParamResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+
+	sessions, err := c.adminService.ListSessions(ctx.Request().Context(), userID)
+	if err != nil {
+		return c.handleAdminError(ctx, err)
+	}
+
+	// None of the sessions is the one the request was made with
+	return ctx.JSON(http.StatusOK, model.NewSessionListResponse(sessions, ""))
+}
+
+// RevokeSession ends a session of a user
+func (c *AdminController) RevokeSession(ctx echo.Context) error {
+	adminID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	userID, ok := getUUIDFromParamWithResponse(ctx, "id", model.InvalidUserIDParamResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+
+	sessionID, ok := getUUIDFromParamWithResponse(ctx, "sessionId", model.InvalidSessionIDResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+
+	if err := c.adminService.RevokeSession(ctx.Request().Context(), adminID, userID, sessionID); err != nil {
+		return c.handleAdminError(ctx, err)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// RevokeAllSessions ends every session of a user
+func (c *AdminController) RevokeAllSessions(ctx echo.Context) error {
+	adminID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	userID, ok := getUUIDFromParamWithResponse(ctx, "id", model.InvalidUserIDParamResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+
+	if err := c.adminService.RevokeAllSessions(ctx.Request().Context(), adminID, userID); err != nil {
+		return c.handleAdminError(ctx, err)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// GetTodoStats returns the counts of the todos of a user
+func (c *AdminController) GetTodoStats(ctx echo.Context) error {
+	userID, ok := getUUIDFromParamWithResponse(ctx, "id", model.InvalidUserIDParamResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+
+	stats, err := c.adminService.GetTodoStats(ctx.Request().Context(), userID)
+	if err != nil {
+		return c.handleAdminError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, model.NewTodoStatsResponse(stats))
+}
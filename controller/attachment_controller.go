@@ -0,0 +1,183 @@
+package controller
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/yukimaterrace/todoms/handler"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+)
+
+// multipartOverhead is the extra room allowed in an upload request for multipart headers and boundaries
+const multipartOverhead = 1 << 20
+
+// AttachmentController handles attachment related HTTP requests
+type AttachmentController struct {
+	attachmentService service.AttachmentService
+	maxUploadSize     int64
+	authHandler       *handler.AuthHandler
+}
+
+// NewAttachmentController creates a new AttachmentController
+func NewAttachmentController(attachmentService service.AttachmentService, maxUploadSize int64, authHandler *handler.AuthHandler) *AttachmentController {
+	return &AttachmentController{
+		attachmentService: attachmentService,
+		maxUploadSize:     maxUploadSize,
+		authHandler:       authHandler,
+	}
+}
+
+// RegisterRoutes registers the attachment routes to the given Echo instance
+func (c *AttachmentController) RegisterRoutes(e *echo.Echo) {
+	attachments := e.Group("/api/todos/:id/attachments", c.authHandler.RequireAuth)
+	attachments.GET("", c.GetAttachments)
+	attachments.POST("", c.UploadAttachment)
+	attachments.GET("/:attachmentId", c.DownloadAttachment)
+	attachments.DELETE("/:attachmentId", c.DeleteAttachment)
+}
+
+// handleAttachmentError handles error patterns for attachment operations
+func (c *AttachmentController) handleAttachmentError(ctx echo.Context, err error) error {
+	switch err {
+	case service.ErrAttachmentNotFound:
+		return ctx.JSON(http.StatusNotFound, model.AttachmentNotFoundResponse)
+	case service.ErrEmptyAttachment:
+		return ctx.JSON(http.StatusBadRequest, model.MissingAttachmentFileResponse)
+	case service.ErrAttachmentTooLarge:
+		return ctx.JSON(http.StatusRequestEntityTooLarge, model.AttachmentTooLargeResponse)
+	case service.ErrAttachmentQuotaExceeded:
+		return ctx.JSON(http.StatusRequestEntityTooLarge, model.AttachmentQuotaExceededResponse)
+	case service.ErrUnsupportedAttachmentType:
+		return ctx.JSON(http.StatusUnsupportedMediaType, model.UnsupportedAttachmentTypeResponse)
+	default:
+		return handleTodoError(ctx, err)
+	}
+}
+
+// GetAttachments returns the attachments of a todo
+func (c *AttachmentController) GetAttachments(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	// Parse todo ID from URL parameter
+	todoID, ok := getUUIDFromParamWithResponse(ctx, "id", model.InvalidTodoIDFormatResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+
+	// Get attachments from service
+	attachments, err := c.attachmentService.GetAttachments(ctx.Request().Context(), userID, todoID)
+	if err != nil {
+		return c.handleAttachmentError(ctx, err)
+	}
+
+	// Return response
+	return ctx.JSON(http.StatusOK, model.NewAttachmentListResponse(attachments))
+}
+
+// UploadAttachment attaches the file in the "file" form field to a todo
+func (c *AttachmentController) UploadAttachment(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	// Parse todo ID from URL parameter
+	todoID, ok := getUUIDFromParamWithResponse(ctx, "id", model.InvalidTodoIDFormatResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+
+	// Cap the request body so oversized uploads are rejected before they are spooled to disk
+	req := ctx.Request()
+	req.Body = http.MaxBytesReader(ctx.Response(), req.Body, c.maxUploadSize+multipartOverhead)
+
+	fileHeader, err := ctx.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return ctx.JSON(http.StatusRequestEntityTooLarge, model.AttachmentTooLargeResponse)
+		}
+		return ctx.JSON(http.StatusBadRequest, model.MissingAttachmentFileResponse)
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, model.FailedToOperateResponse)
+	}
+	defer file.Close()
+
+	// Upload attachment using service
+	attachment, err := c.attachmentService.UploadAttachment(req.Context(), userID, todoID, fileHeader.Filename, fileHeader.Size, file)
+	if err != nil {
+		return c.handleAttachmentError(ctx, err)
+	}
+
+	// Return response
+	return ctx.JSON(http.StatusCreated, model.NewAttachmentResponse(attachment))
+}
+
+// DownloadAttachment streams the contents of an attachment
+func (c *AttachmentController) DownloadAttachment(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	// Parse todo ID and attachment ID from URL parameters
+	todoID, ok := getUUIDFromParamWithResponse(ctx, "id", model.InvalidTodoIDFormatResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+	attachmentID, ok := getUUIDFromParamWithResponse(ctx, "attachmentId", model.InvalidAttachmentIDResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+
+	// Open attachment using service
+	attachment, body, err := c.attachmentService.DownloadAttachment(ctx.Request().Context(), userID, todoID, attachmentID)
+	if err != nil {
+		return c.handleAttachmentError(ctx, err)
+	}
+	defer body.Close()
+
+	// Always download instead of rendering inline, and never let the browser guess the type
+	header := ctx.Response().Header()
+	header.Set(echo.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName}))
+	header.Set(echo.HeaderContentLength, strconv.FormatInt(attachment.Size, 10))
+	header.Set(echo.HeaderXContentTypeOptions, "nosniff")
+	return ctx.Stream(http.StatusOK, attachment.ContentType, body)
+}
+
+// DeleteAttachment removes an attachment from a todo
+func (c *AttachmentController) DeleteAttachment(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	// Parse todo ID and attachment ID from URL parameters
+	todoID, ok := getUUIDFromParamWithResponse(ctx, "id", model.InvalidTodoIDFormatResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+	attachmentID, ok := getUUIDFromParamWithResponse(ctx, "attachmentId", model.InvalidAttachmentIDResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+
+	// Delete attachment using service
+	err := c.attachmentService.DeleteAttachment(ctx.Request().Context(), userID, todoID, attachmentID)
+	if err != nil {
+		return c.handleAttachmentError(ctx, err)
+	}
+
+	// Return success response
+	return ctx.NoContent(http.StatusNoContent)
+}
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/labstack/echo/v4"
@@ -11,17 +12,30 @@ import (
 
 // AuthController handles authentication related HTTP requests
 type AuthController struct {
-	authService service.AuthenticationService
-	userService service.UserService
-	authHandler *handler.AuthHandler
+	authService              service.AuthenticationService
+	userService              service.UserService
+	loginLimiter             service.LoginLimiter
+	emailVerificationService service.EmailVerificationService
+	passwordResetService     service.PasswordResetService
+	authHandler              *handler.AuthHandler
 }
 
 // NewAuthController creates a new authentication controller
-func NewAuthController(authService service.AuthenticationService, userService service.UserService, authHandler *handler.AuthHandler) *AuthController {
+func NewAuthController(
+	authService service.AuthenticationService,
+	userService service.UserService,
+	loginLimiter service.LoginLimiter,
+	emailVerificationService service.EmailVerificationService,
+	passwordResetService service.PasswordResetService,
+	authHandler *handler.AuthHandler,
+) *AuthController {
 	return &AuthController{
-		authService: authService,
-		userService: userService,
-		authHandler: authHandler,
+		authService:              authService,
+		userService:              userService,
+		loginLimiter:             loginLimiter,
+		emailVerificationService: emailVerificationService,
+		passwordResetService:     passwordResetService,
+		authHandler:              authHandler,
 	}
 }
 
@@ -29,8 +43,13 @@ func NewAuthController(authService service.AuthenticationService, userService se
 func (c *AuthController) RegisterRoutes(e *echo.Echo) {
 	auth := e.Group("/api/auth")
 	auth.POST("/signup", c.SignUp)
-	auth.POST("/login", c.Login)
-	auth.POST("/refresh", c.Refresh)
+	auth.POST("/login", c.Login, handler.SecretResponse)
+	auth.POST("/refresh", c.Refresh, handler.SecretResponse)
+	auth.POST("/mfa/verify", c.VerifyMFA, handler.SecretResponse)
+	auth.POST("/verify-email", c.VerifyEmail)
+	auth.POST("/resend-verification", c.ResendVerification)
+	auth.POST("/forgot-password", c.ForgotPassword)
+	auth.POST("/reset-password", c.ResetPassword)
 	auth.GET("/me", c.Me, c.authHandler.RequireAuth)
 }
 
@@ -43,16 +62,19 @@ func (c *AuthController) SignUp(ctx echo.Context) error {
 
 	user, err := c.userService.CreateUser(ctx.Request().Context(), req.Email, req.Password)
 	if err != nil {
+		if handlePasswordPolicyError(ctx, err, "password") {
+			return nil
+		}
 		if err == service.ErrEmailAlreadyExists {
 			return ctx.JSON(http.StatusConflict, model.EmailAlreadyExistsResponse)
 		}
 		return ctx.JSON(http.StatusInternalServerError, model.FailedToCreateUserResponse)
 	}
 
-	return ctx.JSON(http.StatusCreated, model.UserResponse{
-		ID:    user.ID.String(),
-		Email: user.Email,
-	})
+	// The account exists either way, and the user can ask for the email again when sending fails
+	_ = c.emailVerificationService.SendVerification(ctx.Request().Context(), user)
+
+	return ctx.JSON(http.StatusCreated, model.NewUserResponse(user))
 }
 
 // Login handles user authentication and returns JWT tokens
@@ -62,16 +84,97 @@ func (c *AuthController) Login(ctx echo.Context) error {
 		return err // Error response already sent by ValidateRequest
 	}
 
-	tokenPair, err := c.authService.Authenticate(ctx.Request().Context(), req.Email, req.Password)
+	// Refuse the attempt before checking the password when the client or account has made too many
+	retryAfter, err := c.loginLimiter.Check(ctx.Request().Context(), ctx.RealIP(), req.Email)
+	if err != nil {
+		switch err {
+		case service.ErrTooManyLoginAttempts:
+			setRetryAfter(ctx, retryAfter)
+			return ctx.JSON(http.StatusTooManyRequests, model.TooManyLoginAttemptsResponse)
+		case service.ErrAccountLocked:
+			setRetryAfter(ctx, retryAfter)
+			return ctx.JSON(http.StatusTooManyRequests, model.AccountLockedResponse)
+		default:
+			return ctx.JSON(http.StatusInternalServerError, model.AuthenticationFailedResponse)
+		}
+	}
+
+	result, err := c.authService.Authenticate(ctx.Request().Context(), req.Email, req.Password)
 	if err != nil {
 		switch err {
 		case service.ErrUserNotFound, service.ErrInvalidCredentials:
+			c.loginLimiter.RecordFailure(ctx.Request().Context(), req.Email)
 			return ctx.JSON(http.StatusUnauthorized, model.InvalidCredentialsResponse)
+		case service.ErrEmailNotVerified:
+			c.loginLimiter.RecordSuccess(ctx.Request().Context(), req.Email)
+			return ctx.JSON(http.StatusForbidden, model.EmailNotVerifiedResponse)
+		case service.ErrAccountDisabled:
+			c.loginLimiter.RecordSuccess(ctx.Request().Context(), req.Email)
+			return ctx.JSON(http.StatusForbidden, model.AccountDisabledResponse)
 		default:
 			return ctx.JSON(http.StatusInternalServerError, model.AuthenticationFailedResponse)
 		}
 	}
 
+	// With MFA the failed logins are cleared once the code is verified, so that they also limit guessing codes
+	if !result.MFARequired {
+		c.loginLimiter.RecordSuccess(ctx.Request().Context(), req.Email)
+	}
+	return ctx.JSON(http.StatusOK, result)
+}
+
+// VerifyMFA completes a login with an MFA challenge token and a TOTP or recovery code
+// A wrong code counts as a failed login
+func (c *AuthController) VerifyMFA(ctx echo.Context) error {
+	req := new(model.VerifyMFARequest)
+	if err := ValidateRequest(ctx, req); err != nil {
+		return err // Error response already sent by ValidateRequest
+	}
+
+	claims, err := c.authService.ValidateMFAChallenge(req.ChallengeToken)
+	if err != nil {
+		switch err {
+		case service.ErrExpiredToken:
+			return ctx.JSON(http.StatusUnauthorized, model.TokenExpiredResponse)
+		case service.ErrInvalidTokenType:
+			return ctx.JSON(http.StatusUnauthorized, model.InvalidTokenTypeResponse)
+		default:
+			return ctx.JSON(http.StatusUnauthorized, model.InvalidTokenResponse)
+		}
+	}
+
+	retryAfter, err := c.loginLimiter.Check(ctx.Request().Context(), ctx.RealIP(), claims.Email)
+	if err != nil {
+		switch err {
+		case service.ErrTooManyLoginAttempts:
+			setRetryAfter(ctx, retryAfter)
+			return ctx.JSON(http.StatusTooManyRequests, model.TooManyLoginAttemptsResponse)
+		case service.ErrAccountLocked:
+			setRetryAfter(ctx, retryAfter)
+			return ctx.JSON(http.StatusTooManyRequests, model.AccountLockedResponse)
+		default:
+			return ctx.JSON(http.StatusInternalServerError, model.AuthenticationFailedResponse)
+		}
+	}
+
+	tokenPair, err := c.authService.VerifyMFA(ctx.Request().Context(), req.ChallengeToken, req.Code)
+	if err != nil {
+		switch err {
+		case service.ErrInvalidMFACode:
+			c.loginLimiter.RecordFailure(ctx.Request().Context(), claims.Email)
+			return ctx.JSON(http.StatusUnauthorized, model.InvalidMFACodeResponse)
+		case service.ErrInvalidToken, service.ErrExpiredToken:
+			return ctx.JSON(http.StatusUnauthorized, model.InvalidTokenResponse)
+		case service.ErrUserNotFound:
+			return ctx.JSON(http.StatusUnauthorized, model.InvalidCredentialsResponse)
+		case service.ErrAccountDisabled:
+			return ctx.JSON(http.StatusForbidden, model.AccountDisabledResponse)
+		default:
+			return ctx.JSON(http.StatusInternalServerError, model.AuthenticationFailedResponse)
+		}
+	}
+
+	c.loginLimiter.RecordSuccess(ctx.Request().Context(), claims.Email)
 	return ctx.JSON(http.StatusOK, tokenPair)
 }
 
@@ -91,6 +194,10 @@ func (c *AuthController) Refresh(ctx echo.Context) error {
 			return ctx.JSON(http.StatusUnauthorized, model.InvalidTokenTypeResponse)
 		case service.ErrUserNotFound:
 			return ctx.JSON(http.StatusUnauthorized, model.InvalidCredentialsResponse)
+		case service.ErrEmailNotVerified:
+			return ctx.JSON(http.StatusForbidden, model.EmailNotVerifiedResponse)
+		case service.ErrAccountDisabled:
+			return ctx.JSON(http.StatusForbidden, model.AccountDisabledResponse)
 		default:
 			return ctx.JSON(http.StatusInternalServerError, model.AuthenticationFailedResponse)
 		}
@@ -107,7 +214,79 @@ func (c *AuthController) Me(ctx echo.Context) error {
 	}
 
 	return ctx.JSON(http.StatusOK, model.UserResponse{
-		ID:    claims.UserID,
-		Email: claims.Email,
+		ID:            claims.UserID,
+		Email:         claims.Email,
+		EmailVerified: &claims.EmailVerified,
 	})
 }
+
+// VerifyEmail marks the email address of the user a verification token was sent to as verified
+func (c *AuthController) VerifyEmail(ctx echo.Context) error {
+	req := new(model.VerifyEmailRequest)
+	if err := ValidateRequest(ctx, req); err != nil {
+		return err // Error response already sent by ValidateRequest
+	}
+
+	user, err := c.emailVerificationService.VerifyEmail(ctx.Request().Context(), req.Token)
+	if err != nil {
+		if err == service.ErrInvalidVerificationToken {
+			return ctx.JSON(http.StatusBadRequest, model.InvalidVerificationTokenResponse)
+		}
+		return ctx.JSON(http.StatusInternalServerError, model.FailedToOperateResponse)
+	}
+
+	return ctx.JSON(http.StatusOK, model.NewUserResponse(user))
+}
+
+// ResendVerification sends a new verification email
+// It is accepted whether or not the email is registered, so that the response does not reveal it
+func (c *AuthController) ResendVerification(ctx echo.Context) error {
+	req := new(model.ResendVerificationRequest)
+	if err := ValidateRequest(ctx, req); err != nil {
+		return err // Error response already sent by ValidateRequest
+	}
+
+	if err := c.emailVerificationService.ResendVerification(ctx.Request().Context(), req.Email); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, model.FailedToOperateResponse)
+	}
+
+	return ctx.NoContent(http.StatusAccepted)
+}
+
+// ForgotPassword sends a password reset email
+// The email is sent in the background and the request is always accepted, so that neither the response
+// nor its timing reveals whether the email is registered
+func (c *AuthController) ForgotPassword(ctx echo.Context) error {
+	req := new(model.ForgotPasswordRequest)
+	if err := ValidateRequest(ctx, req); err != nil {
+		return err // Error response already sent by ValidateRequest
+	}
+
+	go c.passwordResetService.RequestReset(context.WithoutCancel(ctx.Request().Context()), req.Email)
+
+	return ctx.NoContent(http.StatusAccepted)
+}
+
+// ResetPassword sets a new password with a password reset token, revoking every session of the user
+func (c *AuthController) ResetPassword(ctx echo.Context) error {
+	req := new(model.ResetPasswordRequest)
+	if err := ValidateRequest(ctx, req); err != nil {
+		return err // Error response already sent by ValidateRequest
+	}
+
+	user, err := c.passwordResetService.ResetPassword(ctx.Request().Context(), req.Token, req.Password)
+	if err != nil {
+		if handlePasswordPolicyError(ctx, err, "password") {
+			return nil
+		}
+		if err == service.ErrInvalidPasswordResetToken {
+			return ctx.JSON(http.StatusBadRequest, model.InvalidPasswordResetTokenResponse)
+		}
+		return ctx.JSON(http.StatusInternalServerError, model.FailedToOperateResponse)
+	}
+
+	// The user can log in with the new password straight away, even if the account was locked
+	c.loginLimiter.RecordSuccess(ctx.Request().Context(), user.Email)
+
+	return ctx.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,166 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/yukimaterrace/todoms/handler"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+)
+
+// CommentController handles comment related HTTP requests
+type CommentController struct {
+	commentService service.CommentService
+	authHandler    *handler.AuthHandler
+}
+
+// NewCommentController creates a new CommentController
+func NewCommentController(commentService service.CommentService, authHandler *handler.AuthHandler) *CommentController {
+	return &CommentController{
+		commentService: commentService,
+		authHandler:    authHandler,
+	}
+}
+
+// RegisterRoutes registers the comment routes to the given Echo instance
+func (c *CommentController) RegisterRoutes(e *echo.Echo) {
+	comments := e.Group("/api/todos/:id/comments", c.authHandler.RequireAuth)
+	comments.GET("", c.ListComments)
+	comments.POST("", c.CreateComment)
+	comments.PUT("/:commentId", c.UpdateComment)
+	comments.DELETE("/:commentId", c.DeleteComment)
+}
+
+// handleCommentError handles error patterns for comment operations
+func (c *CommentController) handleCommentError(ctx echo.Context, err error) error {
+	switch err {
+	case service.ErrCommentNotFound:
+		return ctx.JSON(http.StatusNotFound, model.CommentNotFoundResponse)
+	case service.ErrNotCommentAuthor:
+		return ctx.JSON(http.StatusForbidden, model.NotCommentAuthorResponse)
+	case service.ErrInvalidCursor:
+		return ctx.JSON(http.StatusBadRequest, model.InvalidPaginationResponse)
+	default:
+		return handleTodoError(ctx, err)
+	}
+}
+
+// ListComments returns a page of comments on a todo
+// The page is selected with the optional cursor and limit query parameters
+func (c *CommentController) ListComments(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	// Parse todo ID from URL parameter
+	todoID, ok := getUUIDFromParamWithResponse(ctx, "id", model.InvalidTodoIDFormatResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+
+	// Parse pagination parameters
+	limit, ok := getLimitFromQueryWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by getLimitFromQueryWithResponse
+	}
+
+	// Get comments from service
+	comments, nextCursor, err := c.commentService.ListComments(ctx.Request().Context(), userID, todoID, ctx.QueryParam("cursor"), limit)
+	if err != nil {
+		return c.handleCommentError(ctx, err)
+	}
+
+	// Return response
+	return ctx.JSON(http.StatusOK, model.NewCommentListResponse(comments, nextCursor))
+}
+
+// CreateComment adds a comment to a todo
+func (c *CommentController) CreateComment(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	// Parse todo ID from URL parameter
+	todoID, ok := getUUIDFromParamWithResponse(ctx, "id", model.InvalidTodoIDFormatResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+
+	// Bind and validate request
+	req := new(model.CreateCommentRequest)
+	if err := ValidateRequest(ctx, req); err != nil {
+		return err // Error response already sent by ValidateRequest
+	}
+
+	// Create comment using service
+	comment, err := c.commentService.CreateComment(ctx.Request().Context(), userID, todoID, *req)
+	if err != nil {
+		return c.handleCommentError(ctx, err)
+	}
+
+	// Return response
+	return ctx.JSON(http.StatusCreated, model.NewCommentResponse(comment))
+}
+
+// UpdateComment edits a comment written by the authenticated user
+func (c *CommentController) UpdateComment(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	// Parse todo ID and comment ID from URL parameters
+	todoID, ok := getUUIDFromParamWithResponse(ctx, "id", model.InvalidTodoIDFormatResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+	commentID, ok := getUUIDFromParamWithResponse(ctx, "commentId", model.InvalidCommentIDFormatResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+
+	// Bind and validate request
+	req := new(model.UpdateCommentRequest)
+	if err := ValidateRequest(ctx, req); err != nil {
+		return err // Error response already sent by ValidateRequest
+	}
+
+	// Update comment using service
+	comment, err := c.commentService.UpdateComment(ctx.Request().Context(), userID, todoID, commentID, *req)
+	if err != nil {
+		return c.handleCommentError(ctx, err)
+	}
+
+	// Return response
+	return ctx.JSON(http.StatusOK, model.NewCommentResponse(comment))
+}
+
+// DeleteComment deletes a comment
+func (c *CommentController) DeleteComment(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	// Parse todo ID and comment ID from URL parameters
+	todoID, ok := getUUIDFromParamWithResponse(ctx, "id", model.InvalidTodoIDFormatResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+	commentID, ok := getUUIDFromParamWithResponse(ctx, "commentId", model.InvalidCommentIDFormatResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+
+	// Delete comment using service
+	err := c.commentService.DeleteComment(ctx.Request().Context(), userID, todoID, commentID)
+	if err != nil {
+		return c.handleCommentError(ctx, err)
+	}
+
+	// Return success response
+	return ctx.NoContent(http.StatusNoContent)
+}
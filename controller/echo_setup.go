@@ -3,12 +3,41 @@ package controller
 import (
 	"github.com/labstack/echo/v4"
 	middleware "github.com/labstack/echo/v4/middleware"
+	"github.com/yukimaterrace/todoms/config"
 	"github.com/yukimaterrace/todoms/handler"
 	"github.com/yukimaterrace/todoms/service"
 )
 
 // SetupEcho initializes and configures Echo instance with given services
-func SetupEcho(userService service.UserService, authService service.AuthenticationService, todoService service.TodoService) *echo.Echo {
+func SetupEcho(
+	userService service.UserService,
+	authService service.AuthenticationService,
+	todoService service.TodoService,
+	shareService service.ShareService,
+	commentService service.CommentService,
+	attachmentService service.AttachmentService,
+	activityService service.ActivityService,
+	webhookService service.WebhookService,
+	streamService service.TodoStreamService,
+	streamTicketService service.StreamTicketService,
+	syncService service.SyncService,
+	idempotencyService service.IdempotencyService,
+	loginLimiter service.LoginLimiter,
+	rateLimitService service.RateLimitService,
+	emailVerificationService service.EmailVerificationService,
+	passwordResetService service.PasswordResetService,
+	mfaService service.MFAService,
+	webAuthnService service.WebAuthnService,
+	oidcService service.OIDCService,
+	oauthService service.OAuthService,
+	tokenService service.PersonalAccessTokenService,
+	sessionService service.SessionService,
+	adminService service.AdminService,
+	authConfig *config.AuthConfig,
+	attachmentConfig *config.AttachmentConfig,
+	streamConfig *config.StreamConfig,
+	idempotencyConfig *config.IdempotencyConfig,
+) *echo.Echo {
 	// Initialize Echo
 	e := echo.New()
 	e.Validator = NewValidator()
@@ -17,17 +46,54 @@ func SetupEcho(userService service.UserService, authService service.Authenticati
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
 	e.Use(middleware.CORS())
+	e.Use(middleware.RequestID())
+	e.Use(requestMetadata)
 
-	// Create auth handler
-	authHandler := handler.NewAuthHandler(authService)
+	// Create auth handler, which also rate limits authenticated requests per user,
+	// restricts users who have not verified their email address and enforces the scopes of OAuth clients and personal access tokens
+	authHandler := handler.NewAuthHandler(authService, oauthService, tokenService, streamTicketService, rateLimitService, authConfig.UnverifiedAccess)
+
+	// Make mutating requests with an Idempotency-Key safe to retry
+	idempotencyHandler := handler.NewIdempotencyHandler(idempotencyService, authService, oauthService, tokenService, idempotencyConfig)
+	e.Use(idempotencyHandler.Middleware)
 
 	// Initialize controllers
-	authController := NewAuthController(authService, userService, authHandler)
+	authController := NewAuthController(authService, userService, loginLimiter, emailVerificationService, passwordResetService, authHandler)
+	mfaController := NewMFAController(mfaService, authHandler)
+	passkeyController := NewPasskeyController(webAuthnService, authService, authHandler)
+	oidcController := NewOIDCController(oidcService, authService, authHandler)
+	oauthController := NewOAuthController(oauthService, authHandler)
+	tokenController := NewTokenController(tokenService, authHandler)
+	sessionController := NewSessionController(sessionService, authHandler)
+	adminController := NewAdminController(adminService, authHandler)
+	userController := NewUserController(userService, authService, emailVerificationService, authHandler)
 	todoController := NewTodoController(todoService, authHandler)
+	shareController := NewShareController(shareService, authHandler)
+	commentController := NewCommentController(commentService, authHandler)
+	attachmentController := NewAttachmentController(attachmentService, attachmentConfig.MaxFileSize, authHandler)
+	activityController := NewActivityController(activityService, authHandler)
+	webhookController := NewWebhookController(webhookService, authHandler)
+	streamController := NewStreamController(streamService, streamTicketService, streamConfig, authHandler)
+	syncController := NewSyncController(syncService, authHandler)
 
 	// Register routes
 	authController.RegisterRoutes(e)
+	mfaController.RegisterRoutes(e)
+	passkeyController.RegisterRoutes(e)
+	oidcController.RegisterRoutes(e)
+	oauthController.RegisterRoutes(e)
+	tokenController.RegisterRoutes(e)
+	sessionController.RegisterRoutes(e)
+	adminController.RegisterRoutes(e)
+	userController.RegisterRoutes(e)
 	todoController.RegisterRoutes(e)
+	shareController.RegisterRoutes(e)
+	commentController.RegisterRoutes(e)
+	attachmentController.RegisterRoutes(e)
+	activityController.RegisterRoutes(e)
+	webhookController.RegisterRoutes(e)
+	streamController.RegisterRoutes(e)
+	syncController.RegisterRoutes(e)
 
 	// Default route
 	e.GET("/", func(c echo.Context) error {
@@ -36,3 +102,14 @@ func SetupEcho(userService service.UserService, authService service.Authenticati
 
 	return e
 }
+
+// requestMetadata passes the request ID, client IP and user agent to the services
+// so they are recorded in the activity log and login sessions
+func requestMetadata(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		requestID := c.Response().Header().Get(echo.HeaderXRequestID)
+		ctx := service.WithRequestMetadata(c.Request().Context(), requestID, c.RealIP(), c.Request().UserAgent())
+		c.SetRequest(c.Request().WithContext(ctx))
+		return next(c)
+	}
+}
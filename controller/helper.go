@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/yukimaterrace/todoms/handler"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+)
+
+// getUUIDFromParam is a helper function to extract and validate a UUID from URL parameters
+func getUUIDFromParam(ctx echo.Context, paramName string) (uuid.UUID, error) {
+	idStr := ctx.Param(paramName)
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return id, nil
+}
+
+// getUUIDFromParamWithResponse extracts a UUID from URL parameters and handles the error response
+// Returns the UUID and true if successful, or uuid.Nil and false if there was an error
+func getUUIDFromParamWithResponse(ctx echo.Context, paramName string, errorResponse *model.ErrorResponse) (uuid.UUID, bool) {
+	id, err := getUUIDFromParam(ctx, paramName)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse)
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// handlePasswordPolicyError sends the reasons a password was refused as a validation error of the field
+// Returns true if err is a *service.PasswordPolicyError and the response has been sent
+func handlePasswordPolicyError(ctx echo.Context, err error, field string) bool {
+	var policyErr *service.PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+
+	response := *model.ValidationFailedResponse
+	response.Message = "Password does not meet the password policy"
+	for _, violation := range policyErr.Violations {
+		response.Details = append(response.Details, model.ErrorDetail{
+			Field:   field,
+			Reason:  violation.Reason,
+			Message: violation.Message,
+		})
+	}
+	ctx.JSON(http.StatusBadRequest, &response)
+	return true
+}
+
+// getLimitFromQueryWithResponse parses the optional limit query parameter, returning 0 when absent
+// If parsing fails, it sends an error response and returns false
+func getLimitFromQueryWithResponse(ctx echo.Context) (int, bool) {
+	limitStr := ctx.QueryParam("limit")
+	if limitStr == "" {
+		return 0, true
+	}
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 {
+		ctx.JSON(http.StatusBadRequest, model.InvalidPaginationResponse)
+		return 0, false
+	}
+	return limit, true
+}
+
+// handleTodoError handles common error patterns for todo operations
+func handleTodoError(ctx echo.Context, err error) error {
+	switch err {
+	case service.ErrTodoNotFound:
+		return ctx.JSON(http.StatusNotFound, model.TodoNotFoundResponse)
+	case service.ErrUnauthorized:
+		return ctx.JSON(http.StatusForbidden, model.NoPermissionToAccessTodoResponse)
+	case service.ErrAssigneeNoAccess:
+		return ctx.JSON(http.StatusBadRequest, model.AssigneeNoAccessResponse)
+	case service.ErrInvalidTodoID:
+		return ctx.JSON(http.StatusBadRequest, model.InvalidTodoIDFormatResponse)
+	case service.ErrTodoIDConflict:
+		return ctx.JSON(http.StatusConflict, model.TodoIDConflictResponse)
+	case service.ErrTodoQuotaExceeded:
+		return ctx.JSON(http.StatusForbidden, model.TodoQuotaExceededResponse)
+	case service.ErrDescriptionTooLong:
+		return ctx.JSON(http.StatusBadRequest, model.DescriptionTooLongResponse)
+	default:
+		return ctx.JSON(http.StatusInternalServerError, model.FailedToOperateResponse)
+	}
+}
+
+// setRetryAfter sets the Retry-After header to the duration in whole seconds, rounded up
+func setRetryAfter(ctx echo.Context, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	ctx.Response().Header().Set(handler.HeaderRetryAfter, strconv.Itoa(max(seconds, 1)))
+}
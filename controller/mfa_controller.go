@@ -0,0 +1,146 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/yukimaterrace/todoms/handler"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+)
+
+// MFAController handles HTTP requests for managing the authenticated user's TOTP two-factor authentication
+type MFAController struct {
+	mfaService  service.MFAService
+	authHandler *handler.AuthHandler
+}
+
+// NewMFAController creates a new MFAController
+func NewMFAController(mfaService service.MFAService, authHandler *handler.AuthHandler) *MFAController {
+	return &MFAController{
+		mfaService:  mfaService,
+		authHandler: authHandler,
+	}
+}
+
+// RegisterRoutes registers the MFA management routes to the given Echo instance
+// Completing a login with an MFA code is handled by AuthController
+func (c *MFAController) RegisterRoutes(e *echo.Echo) {
+	mfa := e.Group("/api/auth/mfa", c.authHandler.RequireAccountAuth)
+	mfa.GET("", c.GetStatus)
+	mfa.DELETE("", c.Disable)
+	mfa.POST("/enroll", c.BeginEnrollment, handler.SecretResponse)
+	mfa.POST("/confirm", c.ConfirmEnrollment, handler.SecretResponse)
+	mfa.POST("/recovery-codes", c.RegenerateRecoveryCodes, handler.SecretResponse)
+}
+
+// handleMFAError handles error patterns for MFA operations
+func (c *MFAController) handleMFAError(ctx echo.Context, err error) error {
+	switch err {
+	case service.ErrInvalidMFACode:
+		return ctx.JSON(http.StatusForbidden, model.IncorrectMFACodeResponse)
+	case service.ErrMFANotEnrolled:
+		return ctx.JSON(http.StatusBadRequest, model.MFANotEnrolledResponse)
+	case service.ErrMFAAlreadyEnabled:
+		return ctx.JSON(http.StatusConflict, model.MFAAlreadyEnabledResponse)
+	case service.ErrMFANotEnabled:
+		return ctx.JSON(http.StatusConflict, model.MFANotEnabledResponse)
+	case service.ErrUserNotFound:
+		return ctx.JSON(http.StatusNotFound, model.UserNotFoundResponse)
+	default:
+		return ctx.JSON(http.StatusInternalServerError, model.FailedToOperateResponse)
+	}
+}
+
+// GetStatus returns whether the authenticated user has MFA enabled
+func (c *MFAController) GetStatus(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	status, err := c.mfaService.GetStatus(ctx.Request().Context(), userID)
+	if err != nil {
+		return c.handleMFAError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, status)
+}
+
+// BeginEnrollment generates a TOTP secret for the authenticated user to add to an authenticator app
+func (c *MFAController) BeginEnrollment(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	enrollment, err := c.mfaService.BeginEnrollment(ctx.Request().Context(), userID)
+	if err != nil {
+		return c.handleMFAError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, model.MFAEnrollmentResponse{
+		Secret:     enrollment.Secret,
+		OTPAuthURI: enrollment.OTPAuthURI,
+	})
+}
+
+// ConfirmEnrollment enables MFA with the first code from the authenticator app
+// The response is the only one that includes the recovery codes
+func (c *MFAController) ConfirmEnrollment(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	req := new(model.MFACodeRequest)
+	if err := ValidateRequest(ctx, req); err != nil {
+		return err // Error response already sent by ValidateRequest
+	}
+
+	recoveryCodes, err := c.mfaService.ConfirmEnrollment(ctx.Request().Context(), userID, req.Code)
+	if err != nil {
+		return c.handleMFAError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, model.MFARecoveryCodesResponse{RecoveryCodes: recoveryCodes})
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the authenticated user
+func (c *MFAController) RegenerateRecoveryCodes(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	req := new(model.MFACodeRequest)
+	if err := ValidateRequest(ctx, req); err != nil {
+		return err // Error response already sent by ValidateRequest
+	}
+
+	recoveryCodes, err := c.mfaService.RegenerateRecoveryCodes(ctx.Request().Context(), userID, req.Code)
+	if err != nil {
+		return c.handleMFAError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, model.MFARecoveryCodesResponse{RecoveryCodes: recoveryCodes})
+}
+
+// Disable turns off MFA for the authenticated user
+func (c *MFAController) Disable(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	req := new(model.MFACodeRequest)
+	if err := ValidateRequest(ctx, req); err != nil {
+		return err // Error response already sent by ValidateRequest
+	}
+
+	if err := c.mfaService.Disable(ctx.Request().Context(), userID, req.Code); err != nil {
+		return c.handleMFAError(ctx, err)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
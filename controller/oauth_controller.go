@@ -0,0 +1,285 @@
+package controller
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/labstack/echo/v4"
+	"github.com/yukimaterrace/todoms/handler"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+)
+
+// OAuthController handles HTTP requests for todoms as an OAuth 2.0 authorization server:
+// registering clients, the consent of users, and the token, introspection and revocation endpoints
+type OAuthController struct {
+	oauthService service.OAuthService
+	authHandler  *handler.AuthHandler
+}
+
+// NewOAuthController creates a new OAuthController
+func NewOAuthController(oauthService service.OAuthService, authHandler *handler.AuthHandler) *OAuthController {
+	return &OAuthController{
+		oauthService: oauthService,
+		authHandler:  authHandler,
+	}
+}
+
+// RegisterRoutes registers the OAuth routes to the given Echo instance
+func (c *OAuthController) RegisterRoutes(e *echo.Echo) {
+	clients := e.Group("/api/oauth/clients", c.authHandler.RequireAccountAuth)
+	clients.GET("", c.ListClients)
+	clients.POST("", c.CreateClient, handler.SecretResponse)
+	clients.DELETE("/:id", c.DeleteClient)
+
+	authorize := e.Group("/api/oauth/authorize", c.authHandler.RequireAccountAuth)
+	authorize.GET("", c.GetAuthorization)
+	authorize.POST("", c.Authorize, handler.SecretResponse)
+
+	consents := e.Group("/api/oauth/consents", c.authHandler.RequireAccountAuth)
+	consents.GET("", c.ListConsents)
+	consents.DELETE("/:clientId", c.RevokeConsent)
+
+	// Clients authenticate to these endpoints themselves
+	e.POST("/api/oauth/token", c.Token, handler.SecretResponse)
+	e.POST("/api/oauth/introspect", c.Introspect)
+	e.POST("/api/oauth/revoke", c.Revoke)
+}
+
+// handleOAuthError handles error patterns for managing clients and consents, and authorizing clients
+func (c *OAuthController) handleOAuthError(ctx echo.Context, err error) error {
+	switch err {
+	case service.ErrInvalidRedirectURI:
+		return ctx.JSON(http.StatusBadRequest, model.InvalidRedirectURIResponse)
+	case service.ErrInvalidOAuthScope:
+		return ctx.JSON(http.StatusBadRequest, model.InvalidOAuthScopeResponse)
+	case service.ErrOAuthClientNotFound:
+		return ctx.JSON(http.StatusNotFound, model.OAuthClientNotFoundResponse)
+	case service.ErrOAuthConsentNotFound:
+		return ctx.JSON(http.StatusNotFound, model.OAuthConsentNotFoundResponse)
+	default:
+		return ctx.JSON(http.StatusInternalServerError, model.FailedToOperateResponse)
+	}
+}
+
+// handleTokenError handles error patterns for the endpoints clients call, in the format of RFC 6749 section 5.2
+func (c *OAuthController) handleTokenError(ctx echo.Context, err error) error {
+	switch err {
+	case service.ErrInvalidOAuthRequest:
+		return ctx.JSON(http.StatusBadRequest, model.OAuthInvalidRequestResponse)
+	case service.ErrInvalidOAuthClient:
+		ctx.Response().Header().Set(echo.HeaderWWWAuthenticate, `Basic realm="todoms"`)
+		return ctx.JSON(http.StatusUnauthorized, model.OAuthInvalidClientResponse)
+	case service.ErrInvalidOAuthGrant:
+		return ctx.JSON(http.StatusBadRequest, model.OAuthInvalidGrantResponse)
+	case service.ErrUnauthorizedOAuthClient:
+		return ctx.JSON(http.StatusBadRequest, model.OAuthUnauthorizedClientResponse)
+	case service.ErrUnsupportedGrantType:
+		return ctx.JSON(http.StatusBadRequest, model.OAuthUnsupportedGrantTypeResponse)
+	case service.ErrInvalidOAuthScope:
+		return ctx.JSON(http.StatusBadRequest, model.OAuthInvalidScopeResponse)
+	default:
+		return ctx.JSON(http.StatusInternalServerError, model.OAuthServerErrorResponse)
+	}
+}
+
+// clientCredentials returns the ID and secret a client authenticates with,
+// from the Authorization header (HTTP Basic) or else from the form parameters
+func clientCredentials(ctx echo.Context) (string, string) {
+	if clientID, clientSecret, ok := ctx.Request().BasicAuth(); ok {
+		// Credentials in the Authorization header are form-urlencoded first (RFC 6749 section 2.3.1)
+		if unescaped, err := url.QueryUnescape(clientID); err == nil {
+			clientID = unescaped
+		}
+		if unescaped, err := url.QueryUnescape(clientSecret); err == nil {
+			clientSecret = unescaped
+		}
+		return clientID, clientSecret
+	}
+	return ctx.FormValue("client_id"), ctx.FormValue("client_secret")
+}
+
+// ListClients returns the clients the authenticated user registered
+func (c *OAuthController) ListClients(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	clients, err := c.oauthService.ListClients(ctx.Request().Context(), userID)
+	if err != nil {
+		return c.handleOAuthError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, model.NewOAuthClientListResponse(clients))
+}
+
+// CreateClient registers a client for the authenticated user
+// The response is the only time the secret of a confidential client is shown
+func (c *OAuthController) CreateClient(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	req := new(model.CreateOAuthClientRequest)
+	if err := ValidateRequest(ctx, req); err != nil {
+		return err // Error response already sent by ValidateRequest
+	}
+
+	client, secret, err := c.oauthService.CreateClient(ctx.Request().Context(), userID, *req)
+	if err != nil {
+		return c.handleOAuthError(ctx, err)
+	}
+
+	response := model.NewOAuthClientResponse(client)
+	response.ClientSecret = secret
+	return ctx.JSON(http.StatusCreated, response)
+}
+
+// DeleteClient removes a client the authenticated user registered
+func (c *OAuthController) DeleteClient(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	clientID, ok := getUUIDFromParamWithResponse(ctx, "id", model.InvalidOAuthClientIDFormatResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+
+	if err := c.oauthService.DeleteClient(ctx.Request().Context(), userID, clientID); err != nil {
+		return c.handleOAuthError(ctx, err)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// GetAuthorization validates the authorization request of a client and returns what it asks the user to grant
+func (c *OAuthController) GetAuthorization(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	req := new(model.OAuthAuthorizeRequest)
+	if err := ValidateRequest(ctx, req); err != nil {
+		return err // Error response already sent by ValidateRequest
+	}
+
+	authorization, err := c.oauthService.GetAuthorization(ctx.Request().Context(), userID, req)
+	if err != nil {
+		return c.handleOAuthError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, authorization)
+}
+
+// Authorize approves the authorization request of a client for the authenticated user,
+// and returns the URL to redirect the browser to with the authorization code
+func (c *OAuthController) Authorize(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	req := new(model.OAuthAuthorizeRequest)
+	if err := ValidateRequest(ctx, req); err != nil {
+		return err // Error response already sent by ValidateRequest
+	}
+
+	redirectURL, err := c.oauthService.Authorize(ctx.Request().Context(), userID, req)
+	if err != nil {
+		return c.handleOAuthError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, model.OAuthRedirectResponse{RedirectURL: redirectURL})
+}
+
+// ListConsents returns the clients the authenticated user granted access to
+func (c *OAuthController) ListConsents(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	consents, err := c.oauthService.ListConsents(ctx.Request().Context(), userID)
+	if err != nil {
+		return c.handleOAuthError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, model.NewOAuthConsentListResponse(consents))
+}
+
+// RevokeConsent withdraws the consent of the authenticated user to a client
+func (c *OAuthController) RevokeConsent(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	clientID, ok := getUUIDFromParamWithResponse(ctx, "clientId", model.InvalidOAuthClientIDFormatResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+
+	if err := c.oauthService.RevokeConsent(ctx.Request().Context(), userID, clientID); err != nil {
+		return c.handleOAuthError(ctx, err)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// Token exchanges an authorization code, refresh token or client credentials for tokens (RFC 6749 section 3.2)
+func (c *OAuthController) Token(ctx echo.Context) error {
+	// Responses with tokens must not be cached (RFC 6749 section 5.1)
+	header := ctx.Response().Header()
+	header.Set(echo.HeaderCacheControl, "no-store")
+	header.Set("Pragma", "no-cache")
+
+	req := new(model.OAuthTokenRequest)
+	if err := ctx.Bind(req); err != nil {
+		return c.handleTokenError(ctx, service.ErrInvalidOAuthRequest)
+	}
+
+	clientID, clientSecret := clientCredentials(ctx)
+	response, err := c.oauthService.Token(ctx.Request().Context(), clientID, clientSecret, req)
+	if err != nil {
+		return c.handleTokenError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, response)
+}
+
+// Introspect returns whether a token issued to the client is active, and what it grants (RFC 7662)
+func (c *OAuthController) Introspect(ctx echo.Context) error {
+	token := ctx.FormValue("token")
+	if token == "" {
+		return c.handleTokenError(ctx, service.ErrInvalidOAuthRequest)
+	}
+
+	clientID, clientSecret := clientCredentials(ctx)
+	response, err := c.oauthService.Introspect(ctx.Request().Context(), clientID, clientSecret, token)
+	if err != nil {
+		return c.handleTokenError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, response)
+}
+
+// Revoke revokes a token issued to the client (RFC 7009)
+// Tokens that are invalid or already revoked are reported as revoked as well
+func (c *OAuthController) Revoke(ctx echo.Context) error {
+	token := ctx.FormValue("token")
+	if token == "" {
+		return c.handleTokenError(ctx, service.ErrInvalidOAuthRequest)
+	}
+
+	clientID, clientSecret := clientCredentials(ctx)
+	if err := c.oauthService.Revoke(ctx.Request().Context(), clientID, clientSecret, token); err != nil {
+		return c.handleTokenError(ctx, err)
+	}
+
+	return ctx.NoContent(http.StatusOK)
+}
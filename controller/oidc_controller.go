@@ -0,0 +1,175 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/yukimaterrace/todoms/handler"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+)
+
+// OIDCController handles HTTP requests for logging in with external OpenID Connect providers
+// and linking their accounts to users
+type OIDCController struct {
+	oidcService service.OIDCService
+	authService service.AuthenticationService
+	authHandler *handler.AuthHandler
+}
+
+// NewOIDCController creates a new OIDCController
+func NewOIDCController(
+	oidcService service.OIDCService,
+	authService service.AuthenticationService,
+	authHandler *handler.AuthHandler,
+) *OIDCController {
+	return &OIDCController{
+		oidcService: oidcService,
+		authService: authService,
+		authHandler: authHandler,
+	}
+}
+
+// RegisterRoutes registers the OpenID Connect routes to the given Echo instance
+func (c *OIDCController) RegisterRoutes(e *echo.Echo) {
+	login := e.Group("/api/auth/oidc")
+	login.GET("/providers", c.ListProviders)
+	login.POST("/:provider/authorize", c.BeginLogin)
+	login.POST("/:provider/callback", c.FinishLogin, handler.SecretResponse)
+
+	identities := e.Group("/api/users/me/identities", c.authHandler.RequireAccountAuth)
+	identities.GET("", c.ListIdentities)
+	identities.POST("/:provider/authorize", c.BeginLink)
+	identities.POST("/:provider/callback", c.FinishLink)
+	identities.DELETE("/:id", c.Unlink)
+}
+
+// handleOIDCError handles error patterns for OpenID Connect operations
+func (c *OIDCController) handleOIDCError(ctx echo.Context, err error) error {
+	switch err {
+	case service.ErrInvalidOIDCState:
+		return ctx.JSON(http.StatusBadRequest, model.InvalidOIDCStateResponse)
+	case service.ErrOIDCAuthenticationFailed:
+		return ctx.JSON(http.StatusUnauthorized, model.OIDCAuthenticationFailedResponse)
+	case service.ErrOIDCEmailNotVerified:
+		return ctx.JSON(http.StatusForbidden, model.OIDCEmailNotVerifiedResponse)
+	case service.ErrEmailNotVerified:
+		return ctx.JSON(http.StatusForbidden, model.EmailNotVerifiedResponse)
+	case service.ErrAccountDisabled:
+		return ctx.JSON(http.StatusForbidden, model.AccountDisabledResponse)
+	case service.ErrOIDCProviderNotFound:
+		return ctx.JSON(http.StatusNotFound, model.OIDCProviderNotFoundResponse)
+	case service.ErrIdentityNotFound:
+		return ctx.JSON(http.StatusNotFound, model.IdentityNotFoundResponse)
+	case service.ErrUserNotFound:
+		return ctx.JSON(http.StatusNotFound, model.UserNotFoundResponse)
+	case service.ErrOIDCAccountExists:
+		return ctx.JSON(http.StatusConflict, model.OIDCAccountExistsResponse)
+	case service.ErrIdentityAlreadyLinked:
+		return ctx.JSON(http.StatusConflict, model.IdentityAlreadyLinkedResponse)
+	case service.ErrLastLoginMethod:
+		return ctx.JSON(http.StatusConflict, model.LastLoginMethodResponse)
+	default:
+		return ctx.JSON(http.StatusInternalServerError, model.FailedToOperateResponse)
+	}
+}
+
+// ListProviders returns the providers users can log in with
+func (c *OIDCController) ListProviders(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, c.oidcService.Providers())
+}
+
+// BeginLogin returns the authorization request to redirect the browser to for logging in with a provider
+func (c *OIDCController) BeginLogin(ctx echo.Context) error {
+	authorization, err := c.oidcService.BeginLogin(ctx.Request().Context(), ctx.Param("provider"))
+	if err != nil {
+		return c.handleOIDCError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, authorization)
+}
+
+// FinishLogin logs a user in with the authorization code the provider redirected back with and returns JWT tokens,
+// or an MFA challenge token when the user has MFA enabled
+func (c *OIDCController) FinishLogin(ctx echo.Context) error {
+	req := new(model.OIDCCallbackRequest)
+	if err := ValidateRequest(ctx, req); err != nil {
+		return err // Error response already sent by ValidateRequest
+	}
+
+	result, err := c.authService.AuthenticateOIDC(ctx.Request().Context(), ctx.Param("provider"), req.Code, req.State)
+	if err != nil {
+		return c.handleOIDCError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, result)
+}
+
+// ListIdentities returns the external accounts linked to the authenticated user
+func (c *OIDCController) ListIdentities(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	identities, err := c.oidcService.ListIdentities(ctx.Request().Context(), userID)
+	if err != nil {
+		return c.handleOIDCError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, identities)
+}
+
+// BeginLink returns the authorization request to redirect the browser to for linking a provider
+func (c *OIDCController) BeginLink(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	authorization, err := c.oidcService.BeginLink(ctx.Request().Context(), userID, ctx.Param("provider"))
+	if err != nil {
+		return c.handleOIDCError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, authorization)
+}
+
+// FinishLink links the external account of the authorization code the provider redirected back with
+func (c *OIDCController) FinishLink(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	req := new(model.OIDCCallbackRequest)
+	if err := ValidateRequest(ctx, req); err != nil {
+		return err // Error response already sent by ValidateRequest
+	}
+
+	identity, err := c.oidcService.FinishLink(ctx.Request().Context(), userID, ctx.Param("provider"), req.Code, req.State)
+	if err != nil {
+		return c.handleOIDCError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusCreated, identity)
+}
+
+// Unlink removes a linked external account of the authenticated user
+func (c *OIDCController) Unlink(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	identityID, ok := getUUIDFromParamWithResponse(ctx, "id", model.InvalidIdentityIDFormatResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+
+	if err := c.oidcService.Unlink(ctx.Request().Context(), userID, identityID); err != nil {
+		return c.handleOIDCError(ctx, err)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
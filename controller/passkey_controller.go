@@ -0,0 +1,161 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/yukimaterrace/todoms/handler"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+)
+
+// PasskeyController handles HTTP requests for registering passkeys and logging in with them
+type PasskeyController struct {
+	webAuthnService service.WebAuthnService
+	authService     service.AuthenticationService
+	authHandler     *handler.AuthHandler
+}
+
+// NewPasskeyController creates a new PasskeyController
+func NewPasskeyController(
+	webAuthnService service.WebAuthnService,
+	authService service.AuthenticationService,
+	authHandler *handler.AuthHandler,
+) *PasskeyController {
+	return &PasskeyController{
+		webAuthnService: webAuthnService,
+		authService:     authService,
+		authHandler:     authHandler,
+	}
+}
+
+// RegisterRoutes registers the passkey routes to the given Echo instance
+func (c *PasskeyController) RegisterRoutes(e *echo.Echo) {
+	login := e.Group("/api/auth/passkeys/login")
+	login.POST("/begin", c.BeginLogin)
+	login.POST("/finish", c.FinishLogin, handler.SecretResponse)
+
+	passkeys := e.Group("/api/users/me/passkeys", c.authHandler.RequireAccountAuth)
+	passkeys.GET("", c.ListPasskeys)
+	passkeys.POST("/register/begin", c.BeginRegistration)
+	passkeys.POST("/register/finish", c.FinishRegistration)
+	passkeys.DELETE("/:id", c.DeletePasskey)
+}
+
+// handlePasskeyError handles error patterns for passkey operations
+func (c *PasskeyController) handlePasskeyError(ctx echo.Context, err error) error {
+	switch err {
+	case service.ErrInvalidPasskeyChallenge:
+		return ctx.JSON(http.StatusBadRequest, model.InvalidPasskeyChallengeResponse)
+	case service.ErrInvalidPasskeyRegistration:
+		return ctx.JSON(http.StatusBadRequest, model.InvalidPasskeyRegistrationResponse)
+	case service.ErrPasskeyAuthenticationFailed:
+		return ctx.JSON(http.StatusUnauthorized, model.PasskeyAuthenticationFailedResponse)
+	case service.ErrEmailNotVerified:
+		return ctx.JSON(http.StatusForbidden, model.EmailNotVerifiedResponse)
+	case service.ErrAccountDisabled:
+		return ctx.JSON(http.StatusForbidden, model.AccountDisabledResponse)
+	case service.ErrPasskeyNotFound:
+		return ctx.JSON(http.StatusNotFound, model.PasskeyNotFoundResponse)
+	case service.ErrUserNotFound:
+		return ctx.JSON(http.StatusNotFound, model.UserNotFoundResponse)
+	case service.ErrPasskeyAlreadyRegistered:
+		return ctx.JSON(http.StatusConflict, model.PasskeyAlreadyRegisteredResponse)
+	default:
+		return ctx.JSON(http.StatusInternalServerError, model.FailedToOperateResponse)
+	}
+}
+
+// BeginLogin returns the options for navigator.credentials.get() to log in with a passkey
+func (c *PasskeyController) BeginLogin(ctx echo.Context) error {
+	options, err := c.webAuthnService.BeginLogin(ctx.Request().Context())
+	if err != nil {
+		return c.handlePasskeyError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, options)
+}
+
+// FinishLogin logs a user in with the response of navigator.credentials.get() and returns JWT tokens
+func (c *PasskeyController) FinishLogin(ctx echo.Context) error {
+	req := new(model.AssertionCredential)
+	if err := ValidateRequest(ctx, req); err != nil {
+		return err // Error response already sent by ValidateRequest
+	}
+
+	tokenPair, err := c.authService.AuthenticatePasskey(ctx.Request().Context(), req)
+	if err != nil {
+		return c.handlePasskeyError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, tokenPair)
+}
+
+// ListPasskeys returns the passkeys of the authenticated user
+func (c *PasskeyController) ListPasskeys(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	passkeys, err := c.webAuthnService.ListCredentials(ctx.Request().Context(), userID)
+	if err != nil {
+		return c.handlePasskeyError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, passkeys)
+}
+
+// BeginRegistration returns the options for navigator.credentials.create() to register a passkey
+func (c *PasskeyController) BeginRegistration(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	options, err := c.webAuthnService.BeginRegistration(ctx.Request().Context(), userID)
+	if err != nil {
+		return c.handlePasskeyError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, options)
+}
+
+// FinishRegistration stores the passkey created by navigator.credentials.create()
+func (c *PasskeyController) FinishRegistration(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	req := new(model.RegisterPasskeyRequest)
+	if err := ValidateRequest(ctx, req); err != nil {
+		return err // Error response already sent by ValidateRequest
+	}
+
+	passkey, err := c.webAuthnService.FinishRegistration(ctx.Request().Context(), userID, req.Name, &req.Credential)
+	if err != nil {
+		return c.handlePasskeyError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusCreated, passkey)
+}
+
+// DeletePasskey removes a passkey of the authenticated user
+func (c *PasskeyController) DeletePasskey(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	passkeyID, ok := getUUIDFromParamWithResponse(ctx, "id", model.InvalidPasskeyIDFormatResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+
+	if err := c.webAuthnService.DeleteCredential(ctx.Request().Context(), userID, passkeyID); err != nil {
+		return c.handlePasskeyError(ctx, err)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/yukimaterrace/todoms/handler"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+)
+
+// SessionController handles HTTP requests for managing the login sessions of users on their devices
+type SessionController struct {
+	sessionService service.SessionService
+	authHandler    *handler.AuthHandler
+}
+
+// NewSessionController creates a new SessionController
+func NewSessionController(sessionService service.SessionService, authHandler *handler.AuthHandler) *SessionController {
+	return &SessionController{
+		sessionService: sessionService,
+		authHandler:    authHandler,
+	}
+}
+
+// RegisterRoutes registers the session routes to the given Echo instance
+func (c *SessionController) RegisterRoutes(e *echo.Echo) {
+	sessions := e.Group("/api/users/me/sessions", c.authHandler.RequireAccountAuth)
+	sessions.GET("", c.ListSessions)
+	sessions.DELETE("/:id", c.RevokeSession)
+}
+
+// handleSessionError handles error patterns for session operations
+func (c *SessionController) handleSessionError(ctx echo.Context, err error) error {
+	switch err {
+	case service.ErrSessionNotFound:
+		return ctx.JSON(http.StatusNotFound, model.SessionNotFoundResponse)
+	default:
+		return ctx.JSON(http.StatusInternalServerError, model.FailedToOperateResponse)
+	}
+}
+
+// ListSessions returns the sessions of the authenticated user, marking the one the request was made with
+func (c *SessionController) ListSessions(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	claims, err := c.authHandler.GetUserClaims(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, model.FailedToGetUserClaimsResponse)
+	}
+
+	sessions, err := c.sessionService.List(ctx.Request().Context(), userID)
+	if err != nil {
+		return c.handleSessionError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, model.NewSessionListResponse(sessions, claims.SessionID))
+}
+
+// RevokeSession ends a session of the authenticated user, such as one on a lost device
+func (c *SessionController) RevokeSession(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	sessionID, ok := getUUIDFromParamWithResponse(ctx, "id", model.InvalidSessionIDResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+
+	if err := c.sessionService.Revoke(ctx.Request().Context(), userID, sessionID); err != nil {
+		return c.handleSessionError(ctx, err)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/yukimaterrace/todoms/handler"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+)
+
+// ShareController handles todo sharing related HTTP requests
+type ShareController struct {
+	shareService service.ShareService
+	authHandler  *handler.AuthHandler
+}
+
+// NewShareController creates a new ShareController
+func NewShareController(shareService service.ShareService, authHandler *handler.AuthHandler) *ShareController {
+	return &ShareController{
+		shareService: shareService,
+		authHandler:  authHandler,
+	}
+}
+
+// RegisterRoutes registers the share routes to the given Echo instance
+func (c *ShareController) RegisterRoutes(e *echo.Echo) {
+	shares := e.Group("/api/todos/:id/shares", c.authHandler.RequireFirstPartyAuth)
+	shares.GET("", c.GetShares)
+	shares.POST("", c.ShareTodo)
+	shares.DELETE("/:userId", c.RemoveShare)
+}
+
+// handleShareError handles error patterns for share operations
+func (c *ShareController) handleShareError(ctx echo.Context, err error) error {
+	switch err {
+	case service.ErrShareTargetNotFound:
+		return ctx.JSON(http.StatusNotFound, model.UserNotFoundResponse)
+	case service.ErrShareNotFound:
+		return ctx.JSON(http.StatusNotFound, model.ShareNotFoundResponse)
+	case service.ErrCannotShareWithOwner:
+		return ctx.JSON(http.StatusBadRequest, model.CannotShareWithOwnerResponse)
+	default:
+		return handleTodoError(ctx, err)
+	}
+}
+
+// GetShares returns all shares of a todo
+func (c *ShareController) GetShares(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	// Parse todo ID from URL parameter
+	todoID, ok := getUUIDFromParamWithResponse(ctx, "id", model.InvalidTodoIDFormatResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+
+	// Get shares from service
+	shares, err := c.shareService.GetShares(ctx.Request().Context(), userID, todoID)
+	if err != nil {
+		return c.handleShareError(ctx, err)
+	}
+
+	// Return response
+	return ctx.JSON(http.StatusOK, model.NewTodoShareListResponse(shares))
+}
+
+// ShareTodo shares a todo with another user, or changes their role if already shared
+func (c *ShareController) ShareTodo(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	// Parse todo ID from URL parameter
+	todoID, ok := getUUIDFromParamWithResponse(ctx, "id", model.InvalidTodoIDFormatResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+
+	// Bind and validate request
+	req := new(model.ShareTodoRequest)
+	if err := ValidateRequest(ctx, req); err != nil {
+		return err // Error response already sent by ValidateRequest
+	}
+
+	// Share todo using service
+	share, err := c.shareService.ShareTodo(ctx.Request().Context(), userID, todoID, *req)
+	if err != nil {
+		return c.handleShareError(ctx, err)
+	}
+
+	// Return response
+	return ctx.JSON(http.StatusOK, model.NewTodoShareResponse(share))
+}
+
+// RemoveShare revokes a user's access to a todo
+func (c *ShareController) RemoveShare(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	// Parse todo ID and target user ID from URL parameters
+	todoID, ok := getUUIDFromParamWithResponse(ctx, "id", model.InvalidTodoIDFormatResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+	targetUserID, ok := getUUIDFromParamWithResponse(ctx, "userId", model.InvalidUserIDParamResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+
+	// Remove share using service
+	err := c.shareService.RemoveShare(ctx.Request().Context(), userID, todoID, targetUserID)
+	if err != nil {
+		return c.handleShareError(ctx, err)
+	}
+
+	// Return success response
+	return ctx.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,218 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/handler"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+	"golang.org/x/net/websocket"
+)
+
+// sseRetryMillis is the reconnection delay suggested to SSE clients
+const sseRetryMillis = 3000
+
+// StreamController handles the real-time streams of todo events
+type StreamController struct {
+	streamService       service.TodoStreamService
+	streamTicketService service.StreamTicketService
+	config              *config.StreamConfig
+	authHandler         *handler.AuthHandler
+}
+
+// NewStreamController creates a new StreamController
+func NewStreamController(
+	streamService service.TodoStreamService,
+	streamTicketService service.StreamTicketService,
+	cfg *config.StreamConfig,
+	authHandler *handler.AuthHandler,
+) *StreamController {
+	return &StreamController{
+		streamService:       streamService,
+		streamTicketService: streamTicketService,
+		config:              cfg,
+		authHandler:         authHandler,
+	}
+}
+
+// RegisterRoutes registers the stream routes to the given Echo instance
+func (c *StreamController) RegisterRoutes(e *echo.Echo) {
+	e.POST("/api/stream/tickets", c.IssueTicket, c.authHandler.RequireAccountAuth, handler.SecretResponse)
+	e.GET("/api/stream", c.StreamEvents, c.authHandler.RequireStreamAuth)
+	e.GET("/api/stream/ws", c.StreamEventsWebSocket, c.authHandler.RequireStreamAuth)
+}
+
+// IssueTicket issues a single-use ticket that opens a stream for the authenticated user,
+// for clients that cannot send the access token in the Authorization header
+func (c *StreamController) IssueTicket(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	ticket, err := c.streamTicketService.IssueTicket(ctx.Request().Context(), userID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, model.FailedToOperateResponse)
+	}
+
+	ctx.Response().Header().Set("Cache-Control", "no-store")
+	return ctx.JSON(http.StatusCreated, model.StreamTicketResponse{
+		Ticket:    ticket,
+		ExpiresIn: int(c.config.TicketTTL.Seconds()),
+	})
+}
+
+// getLastEventIDWithResponse reads the event to resume after from the Last-Event-ID header or the
+// lastEventId query parameter, returning 0 when there is none
+// Returns false if it is invalid (in which case the response has already been sent)
+func getLastEventIDWithResponse(ctx echo.Context) (int64, bool) {
+	value := ctx.Request().Header.Get("Last-Event-ID")
+	if value == "" {
+		value = ctx.QueryParam("lastEventId")
+	}
+	if value == "" {
+		return 0, true
+	}
+
+	lastEventID, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || lastEventID < 0 {
+		ctx.JSON(http.StatusBadRequest, model.InvalidLastEventIDResponse)
+		return 0, false
+	}
+	return lastEventID, true
+}
+
+// subscribeWithResponse subscribes the authenticated user to their todo events
+// Returns false if an error occurred (in which case the response has already been sent)
+func (c *StreamController) subscribeWithResponse(ctx echo.Context) (*service.TodoSubscription, bool) {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil, false // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	lastEventID, ok := getLastEventIDWithResponse(ctx)
+	if !ok {
+		return nil, false // Response already sent by getLastEventIDWithResponse
+	}
+
+	subscription, err := c.streamService.Subscribe(ctx.Request().Context(), userID, lastEventID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, model.FailedToOperateResponse)
+		return nil, false
+	}
+	return subscription, true
+}
+
+// StreamEvents streams the events of the authenticated user's todos as Server-Sent Events
+func (c *StreamController) StreamEvents(ctx echo.Context) error {
+	subscription, ok := c.subscribeWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by subscribeWithResponse
+	}
+	defer subscription.Close()
+
+	res := ctx.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+	// Keep reverse proxies such as nginx from buffering the stream
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(res, "retry: %d\n\n", sseRetryMillis)
+	res.Flush()
+
+	return c.forward(ctx.Request().Context(), subscription,
+		func(event model.TodoStreamEvent) error {
+			var data bytes.Buffer
+			if len(event.Data) > 0 {
+				// An SSE data line cannot contain newlines
+				if err := json.Compact(&data, event.Data); err != nil {
+					return err
+				}
+			}
+
+			if event.ID > 0 {
+				fmt.Fprintf(res, "id: %d\n", event.ID)
+			}
+			fmt.Fprintf(res, "event: %s\ndata: %s\n\n", event.Type, data.String())
+			res.Flush()
+			return nil
+		},
+		func() error {
+			_, err := fmt.Fprint(res, ": heartbeat\n\n")
+			res.Flush()
+			return err
+		})
+}
+
+// StreamEventsWebSocket streams the events of the authenticated user's todos over a WebSocket
+// Each event is sent as a JSON text message, and the server does not expect any message from the client
+func (c *StreamController) StreamEventsWebSocket(ctx echo.Context) error {
+	subscription, ok := c.subscribeWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by subscribeWithResponse
+	}
+	defer subscription.Close()
+
+	// Requests are authenticated by token rather than cookies, so the origin is not checked
+	server := websocket.Server{
+		Handler: func(ws *websocket.Conn) {
+			streamCtx, cancel := context.WithCancel(ctx.Request().Context())
+			defer cancel()
+
+			// The request context is not cancelled when a hijacked connection closes,
+			// so reading until the client goes away is how a disconnect is noticed
+			go func() {
+				defer cancel()
+				var message string
+				for websocket.Message.Receive(ws, &message) == nil {
+				}
+			}()
+
+			c.forward(streamCtx, subscription,
+				func(event model.TodoStreamEvent) error {
+					return websocket.JSON.Send(ws, event)
+				},
+				func() error {
+					return websocket.JSON.Send(ws, model.TodoStreamEvent{Type: model.StreamEventHeartbeat})
+				})
+		},
+	}
+	server.ServeHTTP(ctx.Response(), ctx.Request())
+	return nil
+}
+
+// forward writes the events of a subscription with send, and a heartbeat when the stream is idle,
+// until the client disconnects or falls too far behind
+func (c *StreamController) forward(ctx context.Context, subscription *service.TodoSubscription, send func(event model.TodoStreamEvent) error, heartbeat func() error) error {
+	ticker := time.NewTicker(c.config.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-subscription.Events():
+			if !ok {
+				// Closing the stream makes the client reconnect and resume from its last event
+				return nil
+			}
+			if err := send(event); err != nil {
+				return nil
+			}
+			ticker.Reset(c.config.HeartbeatInterval)
+		case <-ticker.C:
+			if err := heartbeat(); err != nil {
+				return nil
+			}
+		}
+	}
+}
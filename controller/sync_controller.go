@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/yukimaterrace/todoms/handler"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+)
+
+// SyncController handles offline sync related HTTP requests
+type SyncController struct {
+	syncService service.SyncService
+	authHandler *handler.AuthHandler
+}
+
+// NewSyncController creates a new SyncController
+func NewSyncController(syncService service.SyncService, authHandler *handler.AuthHandler) *SyncController {
+	return &SyncController{
+		syncService: syncService,
+		authHandler: authHandler,
+	}
+}
+
+// RegisterRoutes registers the sync routes to the given Echo instance
+func (c *SyncController) RegisterRoutes(e *echo.Echo) {
+	sync := e.Group("/api/sync", c.authHandler.RequireAuth)
+	sync.GET("", c.GetChanges)
+	sync.POST("", c.PushMutations)
+}
+
+// handleSyncError handles error patterns for sync operations
+func (c *SyncController) handleSyncError(ctx echo.Context, err error) error {
+	switch err {
+	case service.ErrInvalidSyncToken:
+		return ctx.JSON(http.StatusBadRequest, model.InvalidSyncTokenResponse)
+	default:
+		return handleTodoError(ctx, err)
+	}
+}
+
+// GetChanges returns a page of the changes to the authenticated user's todos
+// The changes are selected with the optional since and limit query parameters
+func (c *SyncController) GetChanges(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	limit, ok := getLimitFromQueryWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by getLimitFromQueryWithResponse
+	}
+
+	// Get changes from service
+	changes, err := c.syncService.GetChanges(ctx.Request().Context(), userID, ctx.QueryParam("since"), limit)
+	if err != nil {
+		return c.handleSyncError(ctx, err)
+	}
+
+	// Return response
+	return ctx.JSON(http.StatusOK, model.NewSyncChangesResponse(changes))
+}
+
+// PushMutations applies a batch of mutations made by a client while offline
+// Conflicts and rejections are reported per mutation, so the response is 200 even if some were not applied
+func (c *SyncController) PushMutations(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	// Bind and validate request
+	req := new(model.SyncPushRequest)
+	if err := ValidateRequest(ctx, req); err != nil {
+		return err // Error response already sent by ValidateRequest
+	}
+
+	// Apply mutations using service
+	results, err := c.syncService.ApplyMutations(ctx.Request().Context(), userID, req.Mutations)
+	if err != nil {
+		return c.handleSyncError(ctx, err)
+	}
+
+	// Return response
+	return ctx.JSON(http.StatusOK, model.SyncPushResponse{Results: results})
+}
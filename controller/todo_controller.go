@@ -32,43 +32,12 @@ func (c *TodoController) RegisterRoutes(e *echo.Echo) {
 	todos.POST("", c.CreateTodo)
 	todos.PUT("/:id", c.UpdateTodo)
 	todos.DELETE("/:id", c.DeleteTodo)
-}
-
-// getUUIDFromParam is a helper method to extract and validate a UUID from URL parameters
-func (c *TodoController) getUUIDFromParam(ctx echo.Context, paramName string) (uuid.UUID, error) {
-	idStr := ctx.Param(paramName)
-	id, err := uuid.Parse(idStr)
-	if err != nil {
-		return uuid.Nil, err
-	}
-	return id, nil
-}
-
-// getUUIDFromParamWithResponse extracts a UUID from URL parameters and handles the error response
-// Returns the UUID and true if successful, or uuid.Nil and false if there was an error
-func (c *TodoController) getUUIDFromParamWithResponse(ctx echo.Context, paramName string) (uuid.UUID, bool) {
-	id, err := c.getUUIDFromParam(ctx, paramName)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, model.InvalidTodoIDFormatResponse)
-		return uuid.Nil, false
-	}
-	return id, true
-}
-
-// handleTodoError handles common error patterns for todo operations
-// Returns true if an error was handled, false otherwise
-func (c *TodoController) handleTodoError(ctx echo.Context, err error) error {
-	switch err {
-	case service.ErrTodoNotFound:
-		return ctx.JSON(http.StatusNotFound, model.TodoNotFoundResponse)
-	case service.ErrUnauthorized:
-		return ctx.JSON(http.StatusForbidden, model.NoPermissionToAccessTodoResponse)
-	default:
-		return ctx.JSON(http.StatusInternalServerError, model.FailedToOperateResponse)
-	}
+	todos.PUT("/:id/assignee", c.AssignTodo)
+	todos.GET("/:id/assignments", c.GetAssignments)
 }
 
 // GetTodos returns all todos for the authenticated user
+// With ?assignedTo=me only the todos assigned to the user are returned
 func (c *TodoController) GetTodos(ctx echo.Context) error {
 	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
 	if !ok {
@@ -76,7 +45,16 @@ func (c *TodoController) GetTodos(ctx echo.Context) error {
 	}
 
 	// Get todos from service
-	todos, err := c.todoService.GetTodos(ctx.Request().Context(), userID)
+	var todos []model.Todo
+	var err error
+	switch ctx.QueryParam("assignedTo") {
+	case "":
+		todos, err = c.todoService.GetTodos(ctx.Request().Context(), userID)
+	case "me":
+		todos, err = c.todoService.GetAssignedTodos(ctx.Request().Context(), userID)
+	default:
+		return ctx.JSON(http.StatusBadRequest, model.InvalidAssignedToFilterResponse)
+	}
 	if err != nil {
 		return ctx.JSON(http.StatusInternalServerError, model.FailedToOperateResponse)
 	}
@@ -93,7 +71,7 @@ func (c *TodoController) GetTodo(ctx echo.Context) error {
 	}
 
 	// Parse todo ID from URL parameter
-	todoID, ok := c.getUUIDFromParamWithResponse(ctx, "id")
+	todoID, ok := getUUIDFromParamWithResponse(ctx, "id", model.InvalidTodoIDFormatResponse)
 	if !ok {
 		return nil // Response already sent by getUUIDFromParamWithResponse
 	}
@@ -101,7 +79,7 @@ func (c *TodoController) GetTodo(ctx echo.Context) error {
 	// Get todo from service
 	todo, err := c.todoService.GetTodoByID(ctx.Request().Context(), userID, todoID)
 	if err != nil {
-		return c.handleTodoError(ctx, err)
+		return handleTodoError(ctx, err)
 	}
 
 	// Return response
@@ -124,7 +102,7 @@ func (c *TodoController) CreateTodo(ctx echo.Context) error {
 	// Create todo using service
 	todo, err := c.todoService.CreateTodo(ctx.Request().Context(), userID, *req)
 	if err != nil {
-		return ctx.JSON(http.StatusInternalServerError, model.FailedToOperateResponse)
+		return handleTodoError(ctx, err)
 	}
 
 	// Return response
@@ -139,7 +117,7 @@ func (c *TodoController) UpdateTodo(ctx echo.Context) error {
 	}
 
 	// Parse todo ID from URL parameter
-	todoID, ok := c.getUUIDFromParamWithResponse(ctx, "id")
+	todoID, ok := getUUIDFromParamWithResponse(ctx, "id", model.InvalidTodoIDFormatResponse)
 	if !ok {
 		return nil // Response already sent by getUUIDFromParamWithResponse
 	}
@@ -153,7 +131,7 @@ func (c *TodoController) UpdateTodo(ctx echo.Context) error {
 	// Update todo using service
 	todo, err := c.todoService.UpdateTodo(ctx.Request().Context(), userID, todoID, *req)
 	if err != nil {
-		return c.handleTodoError(ctx, err)
+		return handleTodoError(ctx, err)
 	}
 
 	// Return response
@@ -168,7 +146,7 @@ func (c *TodoController) DeleteTodo(ctx echo.Context) error {
 	}
 
 	// Parse todo ID from URL parameter
-	todoID, ok := c.getUUIDFromParamWithResponse(ctx, "id")
+	todoID, ok := getUUIDFromParamWithResponse(ctx, "id", model.InvalidTodoIDFormatResponse)
 	if !ok {
 		return nil // Response already sent by getUUIDFromParamWithResponse
 	}
@@ -176,9 +154,67 @@ func (c *TodoController) DeleteTodo(ctx echo.Context) error {
 	// Delete todo using service
 	err := c.todoService.DeleteTodo(ctx.Request().Context(), userID, todoID)
 	if err != nil {
-		return c.handleTodoError(ctx, err)
+		return handleTodoError(ctx, err)
 	}
 
 	// Return success response
 	return ctx.NoContent(http.StatusNoContent)
 }
+
+// AssignTodo assigns a specific todo to a user, or unassigns it
+func (c *TodoController) AssignTodo(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	// Parse todo ID from URL parameter
+	todoID, ok := getUUIDFromParamWithResponse(ctx, "id", model.InvalidTodoIDFormatResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+
+	// Bind and validate request
+	req := new(model.AssignTodoRequest)
+	if err := ValidateRequest(ctx, req); err != nil {
+		return err // Error response already sent by ValidateRequest
+	}
+
+	var assigneeID *uuid.UUID
+	if req.AssigneeID != nil {
+		id := uuid.MustParse(*req.AssigneeID) // Already validated as a UUID
+		assigneeID = &id
+	}
+
+	// Assign todo using service
+	todo, err := c.todoService.AssignTodo(ctx.Request().Context(), userID, todoID, assigneeID)
+	if err != nil {
+		return handleTodoError(ctx, err)
+	}
+
+	// Return response
+	return ctx.JSON(http.StatusOK, model.NewTodoResponse(todo))
+}
+
+// GetAssignments returns the assignment history of a specific todo
+func (c *TodoController) GetAssignments(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	// Parse todo ID from URL parameter
+	todoID, ok := getUUIDFromParamWithResponse(ctx, "id", model.InvalidTodoIDFormatResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+
+	// Get assignment history from service
+	assignments, err := c.todoService.GetAssignments(ctx.Request().Context(), userID, todoID)
+	if err != nil {
+		return handleTodoError(ctx, err)
+	}
+
+	// Return response
+	return ctx.JSON(http.StatusOK, model.NewTodoAssignmentListResponse(assignments))
+}
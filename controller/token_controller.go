@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/yukimaterrace/todoms/handler"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+)
+
+// TokenController handles HTTP requests for managing the personal access tokens of users
+type TokenController struct {
+	tokenService service.PersonalAccessTokenService
+	authHandler  *handler.AuthHandler
+}
+
+// NewTokenController creates a new TokenController
+func NewTokenController(tokenService service.PersonalAccessTokenService, authHandler *handler.AuthHandler) *TokenController {
+	return &TokenController{
+		tokenService: tokenService,
+		authHandler:  authHandler,
+	}
+}
+
+// RegisterRoutes registers the personal access token routes to the given Echo instance
+func (c *TokenController) RegisterRoutes(e *echo.Echo) {
+	tokens := e.Group("/api/users/me/tokens", c.authHandler.RequireAccountAuth)
+	tokens.GET("", c.ListTokens)
+	tokens.POST("", c.CreateToken, handler.SecretResponse)
+	tokens.DELETE("/:id", c.DeleteToken)
+}
+
+// handleTokenError handles error patterns for personal access token operations
+func (c *TokenController) handleTokenError(ctx echo.Context, err error) error {
+	switch err {
+	case service.ErrPersonalAccessTokenLimit:
+		return ctx.JSON(http.StatusForbidden, model.PersonalAccessTokenLimitResponse)
+	case service.ErrPersonalAccessTokenNotFound:
+		return ctx.JSON(http.StatusNotFound, model.TokenNotFoundResponse)
+	default:
+		return ctx.JSON(http.StatusInternalServerError, model.FailedToOperateResponse)
+	}
+}
+
+// ListTokens returns the personal access tokens of the authenticated user
+func (c *TokenController) ListTokens(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	tokens, err := c.tokenService.List(ctx.Request().Context(), userID)
+	if err != nil {
+		return c.handleTokenError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, model.NewPersonalAccessTokenListResponse(tokens))
+}
+
+// CreateToken creates a personal access token for the authenticated user
+// The response is the only time the token is shown
+func (c *TokenController) CreateToken(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	req := new(model.CreatePersonalAccessTokenRequest)
+	if err := ValidateRequest(ctx, req); err != nil {
+		return err // Error response already sent by ValidateRequest
+	}
+
+	token, raw, err := c.tokenService.Create(ctx.Request().Context(), userID, *req)
+	if err != nil {
+		return c.handleTokenError(ctx, err)
+	}
+
+	response := model.NewPersonalAccessTokenResponse(token)
+	response.Token = raw
+	return ctx.JSON(http.StatusCreated, response)
+}
+
+// DeleteToken revokes a personal access token of the authenticated user
+func (c *TokenController) DeleteToken(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	tokenID, ok := getUUIDFromParamWithResponse(ctx, "id", model.InvalidTokenIDFormatResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+
+	if err := c.tokenService.Delete(ctx.Request().Context(), userID, tokenID); err != nil {
+		return c.handleTokenError(ctx, err)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
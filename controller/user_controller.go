@@ -0,0 +1,181 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/yukimaterrace/todoms/handler"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+)
+
+// UserController handles HTTP requests for managing the authenticated user's own account
+type UserController struct {
+	userService              service.UserService
+	authService              service.AuthenticationService
+	emailVerificationService service.EmailVerificationService
+	authHandler              *handler.AuthHandler
+}
+
+// NewUserController creates a new UserController
+func NewUserController(
+	userService service.UserService,
+	authService service.AuthenticationService,
+	emailVerificationService service.EmailVerificationService,
+	authHandler *handler.AuthHandler,
+) *UserController {
+	return &UserController{
+		userService:              userService,
+		authService:              authService,
+		emailVerificationService: emailVerificationService,
+		authHandler:              authHandler,
+	}
+}
+
+// RegisterRoutes registers the account routes to the given Echo instance
+func (c *UserController) RegisterRoutes(e *echo.Echo) {
+	me := e.Group("/api/users/me", c.authHandler.RequireAccountAuth)
+	me.GET("", c.GetAccount)
+	me.DELETE("", c.DeleteAccount, c.authHandler.RequireActiveSession)
+	me.PUT("/profile", c.UpdateProfile)
+	me.PUT("/password", c.ChangePassword, handler.SecretResponse)
+	me.PUT("/email", c.ChangeEmail, c.authHandler.RequireActiveSession)
+}
+
+// handleUserError handles error patterns for account operations
+func (c *UserController) handleUserError(ctx echo.Context, err error) error {
+	switch err {
+	case service.ErrUserNotFound:
+		return ctx.JSON(http.StatusNotFound, model.UserNotFoundResponse)
+	case service.ErrInvalidCurrentPassword:
+		return ctx.JSON(http.StatusForbidden, model.InvalidCurrentPasswordResponse)
+	case service.ErrReauthenticationRequired:
+		return ctx.JSON(http.StatusForbidden, model.ReauthenticationRequiredResponse)
+	case service.ErrPasswordResetRequired:
+		return ctx.JSON(http.StatusForbidden, model.PasswordResetRequiredResponse)
+	case service.ErrEmailAlreadyExists:
+		return ctx.JSON(http.StatusConflict, model.EmailAlreadyExistsResponse)
+	default:
+		return ctx.JSON(http.StatusInternalServerError, model.FailedToOperateResponse)
+	}
+}
+
+// GetAccount returns the account of the authenticated user
+func (c *UserController) GetAccount(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	user, err := c.userService.GetUser(ctx.Request().Context(), userID)
+	if err != nil {
+		return c.handleUserError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, model.NewUserResponse(user))
+}
+
+// UpdateProfile sets the profile of the authenticated user
+func (c *UserController) UpdateProfile(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	req := new(model.UpdateProfileRequest)
+	if err := ValidateRequest(ctx, req); err != nil {
+		return err // Error response already sent by ValidateRequest
+	}
+
+	user, err := c.userService.UpdateProfile(ctx.Request().Context(), userID, *req)
+	if err != nil {
+		return c.handleUserError(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, model.NewUserResponse(user))
+}
+
+// ChangePassword sets the password of the authenticated user
+// Every session of the user is revoked, and the response is the token pair of a new session for the current device
+func (c *UserController) ChangePassword(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	req := new(model.ChangePasswordRequest)
+	if err := ValidateRequest(ctx, req); err != nil {
+		return err // Error response already sent by ValidateRequest
+	}
+
+	user, err := c.userService.ChangePassword(ctx.Request().Context(), userID, req.CurrentPassword, req.NewPassword)
+	if err != nil {
+		if handlePasswordPolicyError(ctx, err, "newPassword") {
+			return nil
+		}
+		return c.handleUserError(ctx, err)
+	}
+
+	tokenPair, err := c.authService.IssueTokenPair(ctx.Request().Context(), user)
+	if err == service.ErrAccountDisabled {
+		return ctx.JSON(http.StatusForbidden, model.AccountDisabledResponse)
+	}
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, model.AuthenticationFailedResponse)
+	}
+
+	return ctx.JSON(http.StatusOK, tokenPair)
+}
+
+// ChangeEmail sets the email address of the authenticated user and sends a verification email to it
+func (c *UserController) ChangeEmail(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	req := new(model.ChangeEmailRequest)
+	if err := ValidateRequest(ctx, req); err != nil {
+		return err // Error response already sent by ValidateRequest
+	}
+
+	user, err := c.userService.ChangeEmail(ctx.Request().Context(), userID, req.Email, req.Password, c.authTime(ctx))
+	if err != nil {
+		return c.handleUserError(ctx, err)
+	}
+
+	// The email is changed either way, and the user can ask for the email again when sending fails
+	_ = c.emailVerificationService.SendVerification(ctx.Request().Context(), user)
+
+	return ctx.JSON(http.StatusOK, model.NewUserResponse(user))
+}
+
+// DeleteAccount deletes the authenticated user together with the todos they own
+func (c *UserController) DeleteAccount(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	req := new(model.DeleteAccountRequest)
+	if err := ValidateRequest(ctx, req); err != nil {
+		return err // Error response already sent by ValidateRequest
+	}
+
+	if err := c.userService.DeleteAccount(ctx.Request().Context(), userID, req.Password, c.authTime(ctx)); err != nil {
+		return c.handleUserError(ctx, err)
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// authTime returns when the authenticated user logged in for the session of the request
+// It is the zero time when the token does not belong to a login session
+func (c *UserController) authTime(ctx echo.Context) time.Time {
+	claims, err := c.authHandler.GetUserClaims(ctx)
+	if err != nil {
+		return time.Time{}
+	}
+	return claims.AuthenticatedAt()
+}
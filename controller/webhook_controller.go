@@ -0,0 +1,218 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/yukimaterrace/todoms/handler"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+)
+
+// WebhookController handles webhook subscription related HTTP requests
+type WebhookController struct {
+	webhookService service.WebhookService
+	authHandler    *handler.AuthHandler
+}
+
+// NewWebhookController creates a new WebhookController
+func NewWebhookController(webhookService service.WebhookService, authHandler *handler.AuthHandler) *WebhookController {
+	return &WebhookController{
+		webhookService: webhookService,
+		authHandler:    authHandler,
+	}
+}
+
+// RegisterRoutes registers the webhook routes to the given Echo instance
+func (c *WebhookController) RegisterRoutes(e *echo.Echo) {
+	webhooks := e.Group("/api/webhooks", c.authHandler.RequireFirstPartyAuth)
+	webhooks.GET("", c.GetWebhooks)
+	webhooks.POST("", c.CreateWebhook, handler.SecretResponse)
+	webhooks.GET("/:id", c.GetWebhook)
+	webhooks.PUT("/:id", c.UpdateWebhook)
+	webhooks.DELETE("/:id", c.DeleteWebhook)
+	webhooks.GET("/:id/deliveries", c.GetDeliveries)
+	webhooks.POST("/:id/deliveries/:deliveryId/redeliver", c.RedeliverDelivery)
+}
+
+// handleWebhookError handles error patterns for webhook operations
+func (c *WebhookController) handleWebhookError(ctx echo.Context, err error) error {
+	switch err {
+	case service.ErrWebhookNotFound:
+		return ctx.JSON(http.StatusNotFound, model.WebhookNotFoundResponse)
+	case service.ErrWebhookDeliveryNotFound:
+		return ctx.JSON(http.StatusNotFound, model.DeliveryNotFoundResponse)
+	case service.ErrWebhookURLNotAllowed:
+		return ctx.JSON(http.StatusBadRequest, model.WebhookURLNotAllowedResponse)
+	default:
+		return ctx.JSON(http.StatusInternalServerError, model.FailedToOperateResponse)
+	}
+}
+
+// GetWebhooks returns all webhooks of the authenticated user
+func (c *WebhookController) GetWebhooks(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	// Get webhooks from service
+	webhooks, err := c.webhookService.GetWebhooks(ctx.Request().Context(), userID)
+	if err != nil {
+		return c.handleWebhookError(ctx, err)
+	}
+
+	// Return response
+	return ctx.JSON(http.StatusOK, model.NewWebhookListResponse(webhooks))
+}
+
+// CreateWebhook subscribes the authenticated user to todo events
+// The response is the only one that includes the signing secret
+func (c *WebhookController) CreateWebhook(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	// Bind and validate request
+	req := new(model.CreateWebhookRequest)
+	if err := ValidateRequest(ctx, req); err != nil {
+		return err // Error response already sent by ValidateRequest
+	}
+
+	// Create webhook using service
+	webhook, err := c.webhookService.CreateWebhook(ctx.Request().Context(), userID, *req)
+	if err != nil {
+		return c.handleWebhookError(ctx, err)
+	}
+
+	// Return response with the secret
+	response := model.NewWebhookResponse(webhook)
+	response.Secret = webhook.Secret
+	return ctx.JSON(http.StatusCreated, response)
+}
+
+// GetWebhook returns a specific webhook
+func (c *WebhookController) GetWebhook(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	// Parse webhook ID from URL parameter
+	webhookID, ok := getUUIDFromParamWithResponse(ctx, "id", model.InvalidWebhookIDFormatResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+
+	// Get webhook from service
+	webhook, err := c.webhookService.GetWebhook(ctx.Request().Context(), userID, webhookID)
+	if err != nil {
+		return c.handleWebhookError(ctx, err)
+	}
+
+	// Return response
+	return ctx.JSON(http.StatusOK, model.NewWebhookResponse(webhook))
+}
+
+// UpdateWebhook updates a specific webhook
+func (c *WebhookController) UpdateWebhook(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	// Parse webhook ID from URL parameter
+	webhookID, ok := getUUIDFromParamWithResponse(ctx, "id", model.InvalidWebhookIDFormatResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+
+	// Bind and validate request
+	req := new(model.UpdateWebhookRequest)
+	if err := ValidateRequest(ctx, req); err != nil {
+		return err // Error response already sent by ValidateRequest
+	}
+
+	// Update webhook using service
+	webhook, err := c.webhookService.UpdateWebhook(ctx.Request().Context(), userID, webhookID, *req)
+	if err != nil {
+		return c.handleWebhookError(ctx, err)
+	}
+
+	// Return response
+	return ctx.JSON(http.StatusOK, model.NewWebhookResponse(webhook))
+}
+
+// DeleteWebhook deletes a specific webhook
+func (c *WebhookController) DeleteWebhook(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	// Parse webhook ID from URL parameter
+	webhookID, ok := getUUIDFromParamWithResponse(ctx, "id", model.InvalidWebhookIDFormatResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+
+	// Delete webhook using service
+	err := c.webhookService.DeleteWebhook(ctx.Request().Context(), userID, webhookID)
+	if err != nil {
+		return c.handleWebhookError(ctx, err)
+	}
+
+	// Return success response
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// GetDeliveries returns the delivery log of a specific webhook
+func (c *WebhookController) GetDeliveries(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	// Parse webhook ID from URL parameter
+	webhookID, ok := getUUIDFromParamWithResponse(ctx, "id", model.InvalidWebhookIDFormatResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+
+	// Get deliveries from service
+	deliveries, err := c.webhookService.GetDeliveries(ctx.Request().Context(), userID, webhookID)
+	if err != nil {
+		return c.handleWebhookError(ctx, err)
+	}
+
+	// Return response
+	return ctx.JSON(http.StatusOK, model.NewWebhookDeliveryListResponse(deliveries))
+}
+
+// RedeliverDelivery queues a delivery to be sent again, typically one that was dead-lettered
+func (c *WebhookController) RedeliverDelivery(ctx echo.Context) error {
+	userID, ok := c.authHandler.GetUserIDFromContextWithResponse(ctx)
+	if !ok {
+		return nil // Response already sent by GetUserIDFromContextWithResponse
+	}
+
+	// Parse webhook ID and delivery ID from URL parameters
+	webhookID, ok := getUUIDFromParamWithResponse(ctx, "id", model.InvalidWebhookIDFormatResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+	deliveryID, ok := getUUIDFromParamWithResponse(ctx, "deliveryId", model.InvalidDeliveryIDFormatResponse)
+	if !ok {
+		return nil // Response already sent by getUUIDFromParamWithResponse
+	}
+
+	// Queue delivery using service
+	delivery, err := c.webhookService.RedeliverDelivery(ctx.Request().Context(), userID, webhookID, deliveryID)
+	if err != nil {
+		return c.handleWebhookError(ctx, err)
+	}
+
+	// Return response
+	return ctx.JSON(http.StatusAccepted, model.NewWebhookDeliveryResponse(delivery))
+}
@@ -2,12 +2,15 @@
 package handler
 
 import (
+	"context"
 	"errors"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"github.com/yukimaterrace/todoms/config"
 	"github.com/yukimaterrace/todoms/model"
 	"github.com/yukimaterrace/todoms/service"
 )
@@ -18,15 +21,53 @@ var (
 	ErrInvalidUserIDFormat = errors.New("invalid user ID format")
 )
 
+// authMode is what an authenticated route gives access to
+type authMode int
+
+const (
+	// resourceAuth is for todos and the resources around them
+	// Unverified users are restricted, and scoped tokens need the scope of the request
+	resourceAuth authMode = iota
+
+	// accountAuth is for managing the user's own account, which scoped tokens cannot do
+	accountAuth
+
+	// firstPartyAuth is for resources that send todos or grant access to them beyond the user's account,
+	// which scoped tokens cannot manage, while unverified users are restricted as for resourceAuth
+	firstPartyAuth
+)
+
 // AuthHandler contains authentication handler functions
 type AuthHandler struct {
-	authService service.AuthenticationService
+	authService         service.AuthenticationService
+	oauthService        service.OAuthService
+	tokenService        service.PersonalAccessTokenService
+	streamTicketService service.StreamTicketService
+	rateLimitService    service.RateLimitService
+	unverifiedAccess    config.UnverifiedAccess
 }
 
 // NewAuthHandler creates a new authentication handler
-func NewAuthHandler(authService service.AuthenticationService) *AuthHandler {
+// Access tokens issued to OAuth clients are validated with oauthService, or rejected if it is nil,
+// personal access tokens with tokenService, or rejected if it is nil,
+// and stream tickets with streamTicketService, or rejected if it is nil
+// Authenticated requests are rate limited per user with rateLimitService, or not limited if it is nil
+// Users who have not verified their email address can only make read requests unless unverifiedAccess is full
+func NewAuthHandler(
+	authService service.AuthenticationService,
+	oauthService service.OAuthService,
+	tokenService service.PersonalAccessTokenService,
+	streamTicketService service.StreamTicketService,
+	rateLimitService service.RateLimitService,
+	unverifiedAccess config.UnverifiedAccess,
+) *AuthHandler {
 	return &AuthHandler{
-		authService: authService,
+		authService:         authService,
+		oauthService:        oauthService,
+		tokenService:        tokenService,
+		streamTicketService: streamTicketService,
+		rateLimitService:    rateLimitService,
+		unverifiedAccess:    unverifiedAccess,
 	}
 }
 
@@ -75,7 +116,87 @@ func (h *AuthHandler) GetUserIDFromContextWithResponse(ctx echo.Context) (uuid.U
 }
 
 // RequireAuth is a middleware to ensure the request is authenticated
+// Tokens issued to OAuth clients and personal access tokens need the todos:read scope for read requests,
+// and todos:write for the others
 func (h *AuthHandler) RequireAuth(next echo.HandlerFunc) echo.HandlerFunc {
+	return h.requireBearer(next, resourceAuth)
+}
+
+// RequireAccountAuth is RequireAuth for managing the user's own account
+// Users who have not verified their email address are not restricted, so that they can correct it,
+// and tokens issued to OAuth clients and personal access tokens are not accepted
+func (h *AuthHandler) RequireAccountAuth(next echo.HandlerFunc) echo.HandlerFunc {
+	return h.requireBearer(next, accountAuth)
+}
+
+// RequireFirstPartyAuth is RequireAuth for webhooks and shares, which reach beyond the user's account
+// Tokens issued to OAuth clients and personal access tokens are not accepted, whatever their scopes
+func (h *AuthHandler) RequireFirstPartyAuth(next echo.HandlerFunc) echo.HandlerFunc {
+	return h.requireBearer(next, firstPartyAuth)
+}
+
+// RequireRole is a middleware to ensure the authenticated user has the role
+// It has to come after RequireAuth or RequireAccountAuth, and checks the role of the user as it is now
+// rather than the roles in the access token, so that a user who is demoted or disabled loses access at once
+func (h *AuthHandler) RequireRole(role string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			claims, err := h.GetUserClaims(ctx)
+			if err != nil {
+				return ctx.JSON(http.StatusInternalServerError, model.FailedToGetUserClaimsResponse)
+			}
+			if !claims.HasRole(role) {
+				return ctx.JSON(http.StatusForbidden, model.InsufficientRoleResponse)
+			}
+
+			user, ok := h.currentUser(ctx, claims)
+			if !ok {
+				return nil // Response already sent by currentUser
+			}
+			if !slices.Contains(user.Roles(), role) {
+				return ctx.JSON(http.StatusForbidden, model.InsufficientRoleResponse)
+			}
+			return next(ctx)
+		}
+	}
+}
+
+// RequireActiveSession is a middleware for account changes that cannot be undone by whoever holds a token
+// It has to come after RequireAuth or RequireAccountAuth, and refuses tokens whose session has been ended,
+// whose password has been changed since or whose account has been disabled, which are otherwise valid until they expire
+func (h *AuthHandler) RequireActiveSession(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		claims, err := h.GetUserClaims(ctx)
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, model.FailedToGetUserClaimsResponse)
+		}
+
+		if _, ok := h.currentUser(ctx, claims); !ok {
+			return nil // Response already sent by currentUser
+		}
+		return next(ctx)
+	}
+}
+
+// currentUser loads the user of the claims as they are now, sending an error response when the token is no longer valid
+func (h *AuthHandler) currentUser(ctx echo.Context, claims *service.Claims) (*model.User, bool) {
+	user, err := h.authService.CurrentUser(ctx.Request().Context(), claims)
+	if err != nil {
+		switch err {
+		case service.ErrInvalidToken, service.ErrUserNotFound:
+			ctx.JSON(http.StatusUnauthorized, model.InvalidTokenResponse)
+		case service.ErrAccountDisabled:
+			ctx.JSON(http.StatusForbidden, model.AccountDisabledResponse)
+		default:
+			ctx.JSON(http.StatusInternalServerError, model.FailedToOperateResponse)
+		}
+		return nil, false
+	}
+	return user, true
+}
+
+// requireBearer authenticates the request with the access token in the Authorization header
+func (h *AuthHandler) requireBearer(next echo.HandlerFunc, mode authMode) echo.HandlerFunc {
 	return func(ctx echo.Context) error {
 		authHeader := ctx.Request().Header.Get("Authorization")
 		if authHeader == "" {
@@ -89,25 +210,141 @@ func (h *AuthHandler) RequireAuth(next echo.HandlerFunc) echo.HandlerFunc {
 			return ctx.JSON(http.StatusUnauthorized, model.InvalidAuthHeaderFormatResponse)
 		}
 
-		token := parts[1]
-		claims, err := h.authService.ValidateToken(token)
-		if err != nil {
-			switch err {
-			case service.ErrExpiredToken:
-				return ctx.JSON(http.StatusUnauthorized, model.TokenExpiredResponse)
-			default:
-				return ctx.JSON(http.StatusUnauthorized, model.InvalidTokenResponse)
+		return h.authenticate(ctx, parts[1], next, mode)
+	}
+}
+
+// RequireStreamAuth is RequireAuth for the real-time streams
+// It also accepts a stream ticket in the ticket query parameter, because browsers cannot set
+// headers on EventSource and WebSocket connections
+// Access tokens are not accepted in the URL, where they would be written to access logs
+func (h *AuthHandler) RequireStreamAuth(next echo.HandlerFunc) echo.HandlerFunc {
+	requireAuth := h.RequireAuth(next)
+	return func(ctx echo.Context) error {
+		if ctx.Request().Header.Get("Authorization") == "" {
+			if ticket := ctx.QueryParam("ticket"); ticket != "" {
+				return h.redeemTicket(ctx, ticket, next)
 			}
 		}
+		return requireAuth(ctx)
+	}
+}
 
-		// Check if it's an access token
-		if claims.Type != string(service.AccessToken) {
-			return ctx.JSON(http.StatusUnauthorized, model.InvalidTokenTypeResponse)
+// redeemTicket consumes a stream ticket and sets the claims of its user in the context before calling next
+func (h *AuthHandler) redeemTicket(ctx echo.Context, ticket string, next echo.HandlerFunc) error {
+	if h.streamTicketService == nil {
+		return ctx.JSON(http.StatusUnauthorized, model.InvalidStreamTicketResponse)
+	}
+
+	claims, err := h.streamTicketService.RedeemTicket(ctx.Request().Context(), ticket)
+	if err != nil {
+		switch err {
+		case service.ErrInvalidStreamTicket:
+			return ctx.JSON(http.StatusUnauthorized, model.InvalidStreamTicketResponse)
+		case service.ErrAccountDisabled:
+			return ctx.JSON(http.StatusForbidden, model.AccountDisabledResponse)
+		default:
+			return ctx.JSON(http.StatusInternalServerError, model.FailedToOperateResponse)
 		}
+	}
 
-		// Set the user claims in the context for later use
-		ctx.Set("user", claims)
+	return h.authorize(ctx, claims, next, resourceAuth)
+}
 
-		return next(ctx)
+// authenticate validates an access token and sets its claims in the context before calling next
+func (h *AuthHandler) authenticate(ctx echo.Context, token string, next echo.HandlerFunc, mode authMode) error {
+	claims, err := validateAccessToken(ctx.Request().Context(), h.authService, h.oauthService, h.tokenService, token)
+	if err != nil {
+		switch err {
+		case service.ErrExpiredToken:
+			return ctx.JSON(http.StatusUnauthorized, model.TokenExpiredResponse)
+		default:
+			return ctx.JSON(http.StatusUnauthorized, model.InvalidTokenResponse)
+		}
+	}
+
+	// Check if it's an access token
+	if claims.Type != string(service.AccessToken) {
+		return ctx.JSON(http.StatusUnauthorized, model.InvalidTokenTypeResponse)
+	}
+
+	return h.authorize(ctx, claims, next, mode)
+}
+
+// authorize sets the claims of an authenticated request in the context and calls next
+// if the token and the user may make the request
+func (h *AuthHandler) authorize(ctx echo.Context, claims *service.Claims, next echo.HandlerFunc, mode authMode) error {
+	// Set the user claims in the context for later use
+	ctx.Set("user", claims)
+
+	if claims.Delegated() {
+		scope := requiredScope(ctx.Request().Method)
+		if mode != resourceAuth {
+			scope = ""
+		}
+		if scope == "" || !claims.HasScope(scope) {
+			return insufficientScope(ctx, scope)
+		}
+	}
+
+	if mode != accountAuth && !claims.EmailVerified && h.unverifiedAccess != config.UnverifiedAccessFull && !isReadMethod(ctx.Request().Method) {
+		return ctx.JSON(http.StatusForbidden, model.EmailNotVerifiedResponse)
+	}
+
+	return h.limitRate(ctx, claims, next)
+}
+
+// validateAccessToken validates a JWT the user logged in for, an opaque access token issued to an OAuth client,
+// or a personal access token, telling them apart by their prefix
+// Tokens issued to OAuth clients are rejected when oauthService is nil, and personal access tokens when tokenService is nil
+func validateAccessToken(
+	ctx context.Context,
+	authService service.AuthenticationService,
+	oauthService service.OAuthService,
+	tokenService service.PersonalAccessTokenService,
+	token string,
+) (*service.Claims, error) {
+	switch {
+	case strings.HasPrefix(token, service.OAuthAccessTokenPrefix):
+		if oauthService == nil {
+			return nil, service.ErrInvalidToken
+		}
+		return oauthService.ValidateAccessToken(ctx, token)
+	case strings.HasPrefix(token, service.PersonalAccessTokenPrefix):
+		if tokenService == nil {
+			return nil, service.ErrInvalidToken
+		}
+		return tokenService.ValidateToken(ctx, token)
+	default:
+		return authService.ValidateToken(token)
+	}
+}
+
+// requiredScope returns the scope a scoped token needs for a request with the method
+func requiredScope(method string) string {
+	if isReadMethod(method) {
+		return model.ScopeTodosRead
+	}
+	return model.ScopeTodosWrite
+}
+
+// insufficientScope responds that the token does not grant the scope the request needs (RFC 6750 section 3.1)
+// The scope is left out for requests that no scope grants
+func insufficientScope(ctx echo.Context, scope string) error {
+	challenge := `Bearer error="insufficient_scope"`
+	if scope != "" {
+		challenge += `, scope="` + scope + `"`
+	}
+	ctx.Response().Header().Set(echo.HeaderWWWAuthenticate, challenge)
+	return ctx.JSON(http.StatusForbidden, model.InsufficientScopeResponse)
+}
+
+// isReadMethod reports whether the HTTP method does not change anything
+func isReadMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
 	}
 }
@@ -12,6 +12,7 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/yukimaterrace/todoms/config"
 	"github.com/yukimaterrace/todoms/model"
 	"github.com/yukimaterrace/todoms/service"
 )
@@ -22,14 +23,50 @@ type MockAuthenticationService struct {
 }
 
 // Authenticate mocks the Authenticate method
-func (m *MockAuthenticationService) Authenticate(ctx context.Context, email, password string) (*service.TokenPair, error) {
+func (m *MockAuthenticationService) Authenticate(ctx context.Context, email, password string) (*service.LoginResult, error) {
 	args := m.Called(ctx, email, password)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
+	return args.Get(0).(*service.LoginResult), args.Error(1)
+}
+
+// ValidateMFAChallenge mocks the ValidateMFAChallenge method
+func (m *MockAuthenticationService) ValidateMFAChallenge(challengeToken string) (*service.Claims, error) {
+	args := m.Called(challengeToken)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.Claims), args.Error(1)
+}
+
+// VerifyMFA mocks the VerifyMFA method
+func (m *MockAuthenticationService) VerifyMFA(ctx context.Context, challengeToken, code string) (*service.TokenPair, error) {
+	args := m.Called(ctx, challengeToken, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.TokenPair), args.Error(1)
+}
+
+// AuthenticatePasskey mocks the AuthenticatePasskey method
+func (m *MockAuthenticationService) AuthenticatePasskey(ctx context.Context, credential *model.AssertionCredential) (*service.TokenPair, error) {
+	args := m.Called(ctx, credential)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
 	return args.Get(0).(*service.TokenPair), args.Error(1)
 }
 
+// AuthenticateOIDC mocks the AuthenticateOIDC method
+func (m *MockAuthenticationService) AuthenticateOIDC(ctx context.Context, provider, code, state string) (*service.LoginResult, error) {
+	args := m.Called(ctx, provider, code, state)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.LoginResult), args.Error(1)
+}
+
 // ValidateToken mocks the ValidateToken method
 func (m *MockAuthenticationService) ValidateToken(tokenString string) (*service.Claims, error) {
 	args := m.Called(tokenString)
@@ -48,6 +85,74 @@ func (m *MockAuthenticationService) RefreshToken(ctx context.Context, refreshTok
 	return args.Get(0).(*service.TokenPair), args.Error(1)
 }
 
+// IssueTokenPair mocks the IssueTokenPair method
+func (m *MockAuthenticationService) IssueTokenPair(ctx context.Context, user *model.User) (*service.TokenPair, error) {
+	args := m.Called(ctx, user)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.TokenPair), args.Error(1)
+}
+
+// CurrentUser mocks the CurrentUser method
+func (m *MockAuthenticationService) CurrentUser(ctx context.Context, claims *service.Claims) (*model.User, error) {
+	args := m.Called(ctx, claims)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+// MockStreamTicketService is a mock of the StreamTicketService interface
+type MockStreamTicketService struct {
+	mock.Mock
+}
+
+// IssueTicket mocks the IssueTicket method
+func (m *MockStreamTicketService) IssueTicket(ctx context.Context, userID uuid.UUID) (string, error) {
+	args := m.Called(ctx, userID)
+	return args.String(0), args.Error(1)
+}
+
+// RedeemTicket mocks the RedeemTicket method
+func (m *MockStreamTicketService) RedeemTicket(ctx context.Context, ticket string) (*service.Claims, error) {
+	args := m.Called(ctx, ticket)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.Claims), args.Error(1)
+}
+
+// MockOAuthService is a mock of the OAuthService interface
+// Only ValidateAccessToken is mocked, as the middleware calls nothing else
+type MockOAuthService struct {
+	service.OAuthService
+	mock.Mock
+}
+
+func (m *MockOAuthService) ValidateAccessToken(ctx context.Context, token string) (*service.Claims, error) {
+	args := m.Called(ctx, token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.Claims), args.Error(1)
+}
+
+// MockPersonalAccessTokenService is a mock of the PersonalAccessTokenService interface
+// Only ValidateToken is mocked, as the middleware calls nothing else
+type MockPersonalAccessTokenService struct {
+	service.PersonalAccessTokenService
+	mock.Mock
+}
+
+func (m *MockPersonalAccessTokenService) ValidateToken(ctx context.Context, token string) (*service.Claims, error) {
+	args := m.Called(ctx, token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.Claims), args.Error(1)
+}
+
 func TestRequireAuth(t *testing.T) {
 	// Test cases
 	tests := []struct {
@@ -150,7 +255,7 @@ func TestRequireAuth(t *testing.T) {
 			tc.setupMock(mockService)
 
 			// Create auth handler
-			authHandler := NewAuthHandler(mockService)
+			authHandler := NewAuthHandler(mockService, nil, nil, nil, nil, config.UnverifiedAccessFull)
 
 			// Create test request
 			req := httptest.NewRequest(http.MethodGet, "/", nil)
@@ -206,9 +311,503 @@ func TestRequireAuth(t *testing.T) {
 	}
 }
 
+func TestRequireStreamAuth(t *testing.T) {
+	accessClaims := &service.Claims{
+		UserID: "user123",
+		Email:  "test@example.com",
+		Type:   string(service.AccessToken),
+	}
+
+	tests := []struct {
+		name               string
+		target             string
+		setupHeader        func(req *http.Request)
+		setupMock          func(mockService *MockAuthenticationService, ticketService *MockStreamTicketService)
+		expectedStatusCode int
+		expectedError      *model.ErrorResponse
+	}{
+		{
+			name:        "Valid Token In Header",
+			target:      "/",
+			setupHeader: func(req *http.Request) { req.Header.Set("Authorization", "Bearer valid-token") },
+			setupMock: func(mockService *MockAuthenticationService, ticketService *MockStreamTicketService) {
+				mockService.On("ValidateToken", "valid-token").Return(accessClaims, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:        "Valid Ticket In Query",
+			target:      "/?ticket=valid-ticket",
+			setupHeader: func(req *http.Request) {},
+			setupMock: func(mockService *MockAuthenticationService, ticketService *MockStreamTicketService) {
+				ticketService.On("RedeemTicket", mock.Anything, "valid-ticket").Return(accessClaims, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:        "Header Takes Precedence Over Ticket",
+			target:      "/?ticket=query-ticket",
+			setupHeader: func(req *http.Request) { req.Header.Set("Authorization", "Bearer header-token") },
+			setupMock: func(mockService *MockAuthenticationService, ticketService *MockStreamTicketService) {
+				mockService.On("ValidateToken", "header-token").Return(accessClaims, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Access Token In Query Refused",
+			target:             "/?access_token=valid-token",
+			setupHeader:        func(req *http.Request) {},
+			setupMock:          func(mockService *MockAuthenticationService, ticketService *MockStreamTicketService) {},
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedError:      model.MissingAuthHeaderResponse,
+		},
+		{
+			name:               "Missing Token",
+			target:             "/",
+			setupHeader:        func(req *http.Request) {},
+			setupMock:          func(mockService *MockAuthenticationService, ticketService *MockStreamTicketService) {},
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedError:      model.MissingAuthHeaderResponse,
+		},
+		{
+			name:        "Used Or Expired Ticket",
+			target:      "/?ticket=used-ticket",
+			setupHeader: func(req *http.Request) {},
+			setupMock: func(mockService *MockAuthenticationService, ticketService *MockStreamTicketService) {
+				ticketService.On("RedeemTicket", mock.Anything, "used-ticket").Return(nil, service.ErrInvalidStreamTicket)
+			},
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedError:      model.InvalidStreamTicketResponse,
+		},
+		{
+			name:        "Ticket Of Disabled Account",
+			target:      "/?ticket=disabled-ticket",
+			setupHeader: func(req *http.Request) {},
+			setupMock: func(mockService *MockAuthenticationService, ticketService *MockStreamTicketService) {
+				ticketService.On("RedeemTicket", mock.Anything, "disabled-ticket").Return(nil, service.ErrAccountDisabled)
+			},
+			expectedStatusCode: http.StatusForbidden,
+			expectedError:      model.AccountDisabledResponse,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			mockService := new(MockAuthenticationService)
+			ticketService := new(MockStreamTicketService)
+			tc.setupMock(mockService, ticketService)
+			authHandler := NewAuthHandler(mockService, nil, nil, ticketService, nil, config.UnverifiedAccessFull)
+
+			req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+			tc.setupHeader(req)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			nextHandler := func(c echo.Context) error {
+				claims, ok := c.Get("user").(*service.Claims)
+				assert.True(t, ok)
+				assert.Equal(t, "user123", claims.UserID)
+				return c.String(http.StatusOK, "Success")
+			}
+
+			err := authHandler.RequireStreamAuth(nextHandler)(c)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedStatusCode, rec.Code)
+			if tc.expectedError != nil {
+				var errorResponse model.ErrorResponse
+				assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &errorResponse))
+				assert.Equal(t, tc.expectedError.Code, errorResponse.Code)
+			}
+			mockService.AssertExpectations(t)
+			ticketService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRequireAuthUnverifiedAccess(t *testing.T) {
+	tests := []struct {
+		name               string
+		unverifiedAccess   config.UnverifiedAccess
+		emailVerified      bool
+		account            bool
+		firstParty         bool
+		method             string
+		expectedStatusCode int
+	}{
+		{name: "Read-only allows reads", unverifiedAccess: config.UnverifiedAccessReadOnly, method: http.MethodGet, expectedStatusCode: http.StatusOK},
+		{name: "Read-only refuses writes", unverifiedAccess: config.UnverifiedAccessReadOnly, method: http.MethodPost, expectedStatusCode: http.StatusForbidden},
+		{name: "Read-only allows verified writes", unverifiedAccess: config.UnverifiedAccessReadOnly, emailVerified: true, method: http.MethodDelete, expectedStatusCode: http.StatusOK},
+		{name: "None refuses writes", unverifiedAccess: config.UnverifiedAccessNone, method: http.MethodPut, expectedStatusCode: http.StatusForbidden},
+		{name: "Full allows writes", unverifiedAccess: config.UnverifiedAccessFull, method: http.MethodPatch, expectedStatusCode: http.StatusOK},
+		{name: "Account routes allow writes", unverifiedAccess: config.UnverifiedAccessReadOnly, account: true, method: http.MethodPut, expectedStatusCode: http.StatusOK},
+		{name: "First-party routes refuse writes", unverifiedAccess: config.UnverifiedAccessReadOnly, firstParty: true, method: http.MethodPost, expectedStatusCode: http.StatusForbidden},
+		{name: "First-party routes allow verified writes", unverifiedAccess: config.UnverifiedAccessReadOnly, emailVerified: true, firstParty: true, method: http.MethodPost, expectedStatusCode: http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			mockService := new(MockAuthenticationService)
+			mockService.On("ValidateToken", "valid-token").Return(&service.Claims{
+				UserID:        uuid.New().String(),
+				Email:         "test@example.com",
+				EmailVerified: tc.emailVerified,
+				Type:          string(service.AccessToken),
+			}, nil)
+			authHandler := NewAuthHandler(mockService, nil, nil, nil, nil, tc.unverifiedAccess)
+
+			req := httptest.NewRequest(tc.method, "/", nil)
+			req.Header.Set("Authorization", "Bearer valid-token")
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			middleware := authHandler.RequireAuth
+			if tc.account {
+				middleware = authHandler.RequireAccountAuth
+			}
+			if tc.firstParty {
+				middleware = authHandler.RequireFirstPartyAuth
+			}
+			err := middleware(func(c echo.Context) error {
+				return c.String(http.StatusOK, "Success")
+			})(c)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedStatusCode, rec.Code)
+			if tc.expectedStatusCode == http.StatusForbidden {
+				var response model.ErrorResponse
+				assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+				assert.Equal(t, model.EmailNotVerifiedResponse.Code, response.Code)
+			}
+		})
+	}
+}
+
+func TestRequireAuthOAuthScopes(t *testing.T) {
+	const token = service.OAuthAccessTokenPrefix + "access"
+
+	tests := []struct {
+		name               string
+		scopes             []string
+		account            bool
+		firstParty         bool
+		method             string
+		validateErr        error
+		expectedStatusCode int
+		expectedChallenge  string
+	}{
+		{name: "Read scope allows reads", scopes: []string{model.ScopeTodosRead}, method: http.MethodGet, expectedStatusCode: http.StatusOK},
+		{name: "Read scope refuses writes", scopes: []string{model.ScopeTodosRead}, method: http.MethodPost, expectedStatusCode: http.StatusForbidden,
+			expectedChallenge: `Bearer error="insufficient_scope", scope="todos:write"`},
+		{name: "Write scope allows writes", scopes: []string{model.ScopeTodosWrite}, method: http.MethodDelete, expectedStatusCode: http.StatusOK},
+		{name: "Write scope refuses reads", scopes: []string{model.ScopeTodosWrite}, method: http.MethodGet, expectedStatusCode: http.StatusForbidden,
+			expectedChallenge: `Bearer error="insufficient_scope", scope="todos:read"`},
+		{name: "Account routes refuse clients", scopes: model.OAuthScopes, account: true, method: http.MethodGet, expectedStatusCode: http.StatusForbidden,
+			expectedChallenge: `Bearer error="insufficient_scope"`},
+		{name: "First-party routes refuse clients", scopes: model.OAuthScopes, firstParty: true, method: http.MethodPost, expectedStatusCode: http.StatusForbidden,
+			expectedChallenge: `Bearer error="insufficient_scope"`},
+		{name: "Expired token", validateErr: service.ErrExpiredToken, method: http.MethodGet, expectedStatusCode: http.StatusUnauthorized},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			authService := new(MockAuthenticationService)
+			oauthService := new(MockOAuthService)
+			if tc.validateErr != nil {
+				oauthService.On("ValidateAccessToken", mock.Anything, token).Return(nil, tc.validateErr)
+			} else {
+				oauthService.On("ValidateAccessToken", mock.Anything, token).Return(&service.Claims{
+					UserID:        uuid.New().String(),
+					Email:         "test@example.com",
+					EmailVerified: true,
+					Type:          string(service.AccessToken),
+					ClientID:      uuid.New().String(),
+					Scopes:        tc.scopes,
+				}, nil)
+			}
+			authHandler := NewAuthHandler(authService, oauthService, nil, nil, nil, config.UnverifiedAccessFull)
+
+			req := httptest.NewRequest(tc.method, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			middleware := authHandler.RequireAuth
+			if tc.account {
+				middleware = authHandler.RequireAccountAuth
+			}
+			if tc.firstParty {
+				middleware = authHandler.RequireFirstPartyAuth
+			}
+			err := middleware(func(c echo.Context) error {
+				return c.String(http.StatusOK, "Success")
+			})(c)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedStatusCode, rec.Code)
+			authService.AssertNotCalled(t, "ValidateToken", mock.Anything)
+			if tc.expectedChallenge != "" {
+				assert.Equal(t, tc.expectedChallenge, rec.Header().Get(echo.HeaderWWWAuthenticate))
+				var response model.ErrorResponse
+				assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+				assert.Equal(t, model.InsufficientScopeResponse.Code, response.Code)
+			}
+		})
+	}
+
+	t.Run("Refused without the OAuth service", func(t *testing.T) {
+		e := echo.New()
+		authHandler := NewAuthHandler(new(MockAuthenticationService), nil, nil, nil, nil, config.UnverifiedAccessFull)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+
+		err := authHandler.RequireAuth(func(c echo.Context) error {
+			return c.String(http.StatusOK, "Success")
+		})(e.NewContext(req, rec))
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
+
+func TestRequireAuthPersonalAccessToken(t *testing.T) {
+	const token = service.PersonalAccessTokenPrefix + "token"
+
+	tests := []struct {
+		name               string
+		account            bool
+		firstParty         bool
+		method             string
+		expectedStatusCode int
+	}{
+		{name: "Scope allows the request", method: http.MethodGet, expectedStatusCode: http.StatusOK},
+		{name: "Scope refuses the request", method: http.MethodPut, expectedStatusCode: http.StatusForbidden},
+		{name: "Account routes refuse the token", account: true, method: http.MethodGet, expectedStatusCode: http.StatusForbidden},
+		{name: "First-party routes refuse the token", firstParty: true, method: http.MethodGet, expectedStatusCode: http.StatusForbidden},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			authService := new(MockAuthenticationService)
+			tokenService := new(MockPersonalAccessTokenService)
+			tokenService.On("ValidateToken", mock.Anything, token).Return(&service.Claims{
+				UserID:        uuid.New().String(),
+				Email:         "test@example.com",
+				EmailVerified: true,
+				Type:          string(service.AccessToken),
+				TokenID:       uuid.New().String(),
+				Scopes:        []string{model.ScopeTodosRead},
+			}, nil)
+			authHandler := NewAuthHandler(authService, nil, tokenService, nil, nil, config.UnverifiedAccessFull)
+
+			req := httptest.NewRequest(tc.method, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			middleware := authHandler.RequireAuth
+			if tc.account {
+				middleware = authHandler.RequireAccountAuth
+			}
+			if tc.firstParty {
+				middleware = authHandler.RequireFirstPartyAuth
+			}
+			err := middleware(func(c echo.Context) error {
+				return c.String(http.StatusOK, "Success")
+			})(c)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedStatusCode, rec.Code)
+			authService.AssertNotCalled(t, "ValidateToken", mock.Anything)
+			tokenService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	adminClaims := &service.Claims{UserID: uuid.New().String(), Roles: []string{model.RoleUser, model.RoleAdmin}}
+
+	tests := []struct {
+		name              string
+		claims            *service.Claims
+		setupMock         func(mockService *MockAuthenticationService)
+		expectedCode      int
+		expectedErrorResp *model.ErrorResponse
+	}{
+		{
+			name:   "Admin allowed",
+			claims: adminClaims,
+			setupMock: func(mockService *MockAuthenticationService) {
+				mockService.On("CurrentUser", mock.Anything, adminClaims).Return(&model.User{Role: model.RoleAdmin}, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:   "Demoted admin refused",
+			claims: adminClaims,
+			setupMock: func(mockService *MockAuthenticationService) {
+				mockService.On("CurrentUser", mock.Anything, adminClaims).Return(&model.User{Role: model.RoleUser}, nil)
+			},
+			expectedCode:      http.StatusForbidden,
+			expectedErrorResp: model.InsufficientRoleResponse,
+		},
+		{
+			name:   "Disabled admin refused",
+			claims: adminClaims,
+			setupMock: func(mockService *MockAuthenticationService) {
+				mockService.On("CurrentUser", mock.Anything, adminClaims).Return(nil, service.ErrAccountDisabled)
+			},
+			expectedCode:      http.StatusForbidden,
+			expectedErrorResp: model.AccountDisabledResponse,
+		},
+		{
+			name:   "Revoked token refused",
+			claims: adminClaims,
+			setupMock: func(mockService *MockAuthenticationService) {
+				mockService.On("CurrentUser", mock.Anything, adminClaims).Return(nil, service.ErrInvalidToken)
+			},
+			expectedCode:      http.StatusUnauthorized,
+			expectedErrorResp: model.InvalidTokenResponse,
+		},
+		{
+			name:   "User lookup failure",
+			claims: adminClaims,
+			setupMock: func(mockService *MockAuthenticationService) {
+				mockService.On("CurrentUser", mock.Anything, adminClaims).Return(nil, errors.New("database error"))
+			},
+			expectedCode:      http.StatusInternalServerError,
+			expectedErrorResp: model.FailedToOperateResponse,
+		},
+		{
+			name:              "User refused",
+			claims:            &service.Claims{UserID: uuid.New().String(), Roles: []string{model.RoleUser}},
+			setupMock:         func(mockService *MockAuthenticationService) {},
+			expectedCode:      http.StatusForbidden,
+			expectedErrorResp: model.InsufficientRoleResponse,
+		},
+		{
+			name:              "Token without roles refused",
+			claims:            &service.Claims{UserID: uuid.New().String()},
+			setupMock:         func(mockService *MockAuthenticationService) {},
+			expectedCode:      http.StatusForbidden,
+			expectedErrorResp: model.InsufficientRoleResponse,
+		},
+		{
+			name:              "Missing claims",
+			setupMock:         func(mockService *MockAuthenticationService) {},
+			expectedCode:      http.StatusInternalServerError,
+			expectedErrorResp: model.FailedToGetUserClaimsResponse,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			mockService := new(MockAuthenticationService)
+			tc.setupMock(mockService)
+			authHandler := NewAuthHandler(mockService, nil, nil, nil, nil, config.UnverifiedAccessFull)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/admin/users", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			if tc.claims != nil {
+				c.Set("user", tc.claims)
+			}
+
+			nextCalled := false
+			next := func(c echo.Context) error {
+				nextCalled = true
+				return c.String(http.StatusOK, "success")
+			}
+
+			err := authHandler.RequireRole(model.RoleAdmin)(next)(c)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			assert.Equal(t, tc.expectedErrorResp == nil, nextCalled)
+			if tc.expectedErrorResp != nil {
+				var response model.ErrorResponse
+				assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+				assert.Equal(t, tc.expectedErrorResp.Code, response.Code)
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRequireActiveSession(t *testing.T) {
+	claims := &service.Claims{
+		UserID:    uuid.New().String(),
+		Type:      string(service.AccessToken),
+		SessionID: uuid.New().String(),
+	}
+
+	tests := []struct {
+		name              string
+		currentUserError  error
+		expectedCode      int
+		expectedErrorResp *model.ErrorResponse
+	}{
+		{name: "Active session allowed", expectedCode: http.StatusOK},
+		{name: "Revoked session refused", currentUserError: service.ErrInvalidToken, expectedCode: http.StatusUnauthorized, expectedErrorResp: model.InvalidTokenResponse},
+		{name: "Disabled account refused", currentUserError: service.ErrAccountDisabled, expectedCode: http.StatusForbidden, expectedErrorResp: model.AccountDisabledResponse},
+		{name: "User lookup failure", currentUserError: errors.New("database error"), expectedCode: http.StatusInternalServerError, expectedErrorResp: model.FailedToOperateResponse},
+	}
+
+	routes := []struct {
+		method string
+		path   string
+	}{
+		{method: http.MethodPut, path: "/api/users/me/email"},
+		{method: http.MethodDelete, path: "/api/users/me"},
+	}
+
+	for _, tc := range tests {
+		for _, route := range routes {
+			t.Run(tc.name+" "+route.method+" "+route.path, func(t *testing.T) {
+				mockService := new(MockAuthenticationService)
+				mockService.On("ValidateToken", "access-token").Return(claims, nil)
+				if tc.currentUserError != nil {
+					mockService.On("CurrentUser", mock.Anything, claims).Return(nil, tc.currentUserError)
+				} else {
+					mockService.On("CurrentUser", mock.Anything, claims).Return(&model.User{}, nil)
+				}
+				authHandler := NewAuthHandler(mockService, nil, nil, nil, nil, config.UnverifiedAccessFull)
+
+				nextCalled := false
+				e := echo.New()
+				e.Add(route.method, route.path, func(c echo.Context) error {
+					nextCalled = true
+					return c.NoContent(http.StatusOK)
+				}, authHandler.RequireAccountAuth, authHandler.RequireActiveSession)
+
+				req := httptest.NewRequest(route.method, route.path, nil)
+				req.Header.Set("Authorization", "Bearer access-token")
+				rec := httptest.NewRecorder()
+				e.ServeHTTP(rec, req)
+
+				assert.Equal(t, tc.expectedCode, rec.Code)
+				assert.Equal(t, tc.expectedErrorResp == nil, nextCalled)
+				if tc.expectedErrorResp != nil {
+					var response model.ErrorResponse
+					assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+					assert.Equal(t, tc.expectedErrorResp.Code, response.Code)
+				}
+				mockService.AssertExpectations(t)
+			})
+		}
+	}
+}
+
 func TestNewAuthHandler(t *testing.T) {
 	mockService := new(MockAuthenticationService)
-	handler := NewAuthHandler(mockService)
+	handler := NewAuthHandler(mockService, nil, nil, nil, nil, config.UnverifiedAccessFull)
 
 	assert.NotNil(t, handler)
 	assert.Equal(t, mockService, handler.authService)
@@ -266,7 +865,7 @@ func TestGetUserClaims(t *testing.T) {
 
 			// Create auth handler
 			mockService := new(MockAuthenticationService)
-			authHandler := NewAuthHandler(mockService)
+			authHandler := NewAuthHandler(mockService, nil, nil, nil, nil, config.UnverifiedAccessFull)
 
 			// Create test request and response
 			req := httptest.NewRequest(http.MethodGet, "/", nil)
@@ -351,7 +950,7 @@ func TestGetUserIDFromContextWithResponse(t *testing.T) {
 
 			// Create auth handler
 			mockService := new(MockAuthenticationService)
-			authHandler := NewAuthHandler(mockService)
+			authHandler := NewAuthHandler(mockService, nil, nil, nil, nil, config.UnverifiedAccessFull)
 
 			// Create test request and response
 			req := httptest.NewRequest(http.MethodGet, "/", nil)
@@ -0,0 +1,227 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+)
+
+// Idempotency headers
+const (
+	HeaderIdempotencyKey     = "Idempotency-Key"
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+)
+
+// secretResponseKey is the context key marking a response that must not be stored
+const secretResponseKey = "idempotency.secretResponse"
+
+// SecretResponse marks a route whose response carries secrets such as tokens or keys
+// The Idempotency-Key middleware stores only the status of its response, so a retry is not handed the secret again
+func SecretResponse(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		ctx.Set(secretResponseKey, true)
+		return next(ctx)
+	}
+}
+
+// IdempotencyHandler contains the Idempotency-Key middleware
+type IdempotencyHandler struct {
+	idempotencyService service.IdempotencyService
+	authService        service.AuthenticationService
+	oauthService       service.OAuthService
+	tokenService       service.PersonalAccessTokenService
+	config             *config.IdempotencyConfig
+}
+
+// NewIdempotencyHandler creates a new Idempotency-Key handler
+// Access tokens issued to OAuth clients are validated with oauthService and personal access tokens with tokenService,
+// or passed through if they are nil
+func NewIdempotencyHandler(
+	idempotencyService service.IdempotencyService,
+	authService service.AuthenticationService,
+	oauthService service.OAuthService,
+	tokenService service.PersonalAccessTokenService,
+	cfg *config.IdempotencyConfig,
+) *IdempotencyHandler {
+	return &IdempotencyHandler{
+		idempotencyService: idempotencyService,
+		authService:        authService,
+		oauthService:       oauthService,
+		tokenService:       tokenService,
+		config:             cfg,
+	}
+}
+
+// Middleware makes mutating requests sent with an Idempotency-Key header safe to retry
+// The response to the first request is stored and replayed for retries with the same key and request,
+// and a key sent again with a different request is rejected
+// Only the status is stored for routes marked with SecretResponse, and only responses a retry would get again are stored
+// Keys belong to the authenticated user, so requests without a valid access token are passed through
+func (h *IdempotencyHandler) Middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		req := ctx.Request()
+		key := req.Header.Get(HeaderIdempotencyKey)
+		if key == "" || !isMutatingMethod(req.Method) {
+			return next(ctx)
+		}
+
+		userID, ok := h.userIDFromToken(req)
+		if !ok {
+			return next(ctx)
+		}
+
+		if len(key) > h.config.MaxKeyLength {
+			return ctx.JSON(http.StatusBadRequest, model.InvalidIdempotencyKeyResponse)
+		}
+
+		// Read the body to fingerprint the request, and put it back for the handler
+		// A body too large to buffer is passed on as it is, without the key
+		body, err := io.ReadAll(io.LimitReader(req.Body, h.config.MaxBodySize+1))
+		if err != nil {
+			return ctx.JSON(http.StatusBadRequest, model.InvalidRequestBodyResponse)
+		}
+		if int64(len(body)) > h.config.MaxBodySize {
+			req.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(body), req.Body), req.Body}
+			return next(ctx)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+
+		record, err := h.idempotencyService.Begin(req.Context(), userID, key, fingerprint(req, body))
+		if err != nil {
+			switch err {
+			case service.ErrIdempotencyKeyReused:
+				return ctx.JSON(http.StatusUnprocessableEntity, model.IdempotencyKeyReusedResponse)
+			case service.ErrIdempotencyKeyInProgress:
+				return ctx.JSON(http.StatusConflict, model.IdempotencyKeyInProgressResponse)
+			default:
+				return ctx.JSON(http.StatusInternalServerError, model.FailedToOperateResponse)
+			}
+		}
+		if record != nil {
+			return replay(ctx, record)
+		}
+
+		// Record the response while it is written
+		recorder := &responseRecorder{ResponseWriter: ctx.Response().Writer}
+		ctx.Response().Writer = recorder
+
+		// The key is kept only once a response has been stored, so it is released if the handler panics
+		completed := false
+		storeCtx := context.WithoutCancel(req.Context())
+		defer func() {
+			if !completed {
+				h.idempotencyService.Release(storeCtx, userID, key)
+			}
+		}()
+
+		err = next(ctx)
+
+		// A response not written by the handler cannot be stored, and one that may have been transient
+		// is not, so the request is left to be retried
+		res := ctx.Response()
+		if !res.Committed || !isStorableStatus(res.Status) {
+			return err
+		}
+
+		contentType, body := res.Header().Get(echo.HeaderContentType), recorder.body.Bytes()
+		if secret, _ := ctx.Get(secretResponseKey).(bool); secret {
+			contentType, body = "", nil
+		}
+		if h.idempotencyService.Complete(storeCtx, userID, key, res.Status, contentType, body) == nil {
+			completed = true
+		}
+		return err
+	}
+}
+
+// userIDFromToken returns the user of a valid access token in the Authorization header
+func (h *IdempotencyHandler) userIDFromToken(req *http.Request) (uuid.UUID, bool) {
+	token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return uuid.Nil, false
+	}
+
+	claims, err := validateAccessToken(req.Context(), h.authService, h.oauthService, h.tokenService, token)
+	if err != nil || claims.Type != string(service.AccessToken) {
+		return uuid.Nil, false
+	}
+
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// isMutatingMethod reports whether requests with the method change state
+func isMutatingMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// isStorableStatus reports whether a response with the status would be returned again for the same request
+// Errors that depend on when the request was made, such as rate limiting, authentication, conflicts with
+// concurrent requests and server errors, are not stored, so that a retry with the same key can succeed
+// The middleware runs before authentication and rate limiting, whose responses it would otherwise store
+func isStorableStatus(status int) bool {
+	switch {
+	case status < http.StatusBadRequest:
+		return true
+	case status == http.StatusBadRequest, status == http.StatusNotFound, status == http.StatusGone,
+		status == http.StatusRequestEntityTooLarge, status == http.StatusUnsupportedMediaType,
+		status == http.StatusUnprocessableEntity:
+		return true
+	default:
+		return false
+	}
+}
+
+// fingerprint identifies a request by its method, URI and body
+func fingerprint(req *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(req.Method + " " + req.URL.RequestURI() + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// replay writes a stored response
+// A response stored without its body is replayed as the status alone
+func replay(ctx echo.Context, record *model.IdempotencyRecord) error {
+	res := ctx.Response()
+	if record.ResponseContentType != nil && *record.ResponseContentType != "" {
+		res.Header().Set(echo.HeaderContentType, *record.ResponseContentType)
+	}
+	res.Header().Set(HeaderIdempotentReplayed, "true")
+	res.WriteHeader(*record.ResponseStatus)
+	_, err := res.Write(record.ResponseBody)
+	return err
+}
+
+// responseRecorder is an http.ResponseWriter that keeps a copy of the body it writes
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+// Write writes the data to the response and the copy
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
@@ -0,0 +1,393 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+)
+
+// MockIdempotencyService is a mock of IdempotencyService interface
+type MockIdempotencyService struct {
+	mock.Mock
+}
+
+// Begin mocks the Begin method
+func (m *MockIdempotencyService) Begin(ctx context.Context, userID uuid.UUID, key string, fingerprint string) (*model.IdempotencyRecord, error) {
+	args := m.Called(ctx, userID, key, fingerprint)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.IdempotencyRecord), args.Error(1)
+}
+
+// Complete mocks the Complete method
+func (m *MockIdempotencyService) Complete(ctx context.Context, userID uuid.UUID, key string, status int, contentType string, body []byte) error {
+	args := m.Called(ctx, userID, key, status, contentType, body)
+	return args.Error(0)
+}
+
+// Release mocks the Release method
+func (m *MockIdempotencyService) Release(ctx context.Context, userID uuid.UUID, key string) error {
+	args := m.Called(ctx, userID, key)
+	return args.Error(0)
+}
+
+// PurgeExpired mocks the PurgeExpired method
+func (m *MockIdempotencyService) PurgeExpired(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	userID := uuid.New()
+	status := http.StatusCreated
+	contentType := echo.MIMEApplicationJSON
+	stored := &model.IdempotencyRecord{
+		ResponseStatus:      &status,
+		ResponseContentType: &contentType,
+		ResponseBody:        []byte(`{"id":"stored"}`),
+	}
+	emptyContentType := ""
+	storedSecret := &model.IdempotencyRecord{
+		ResponseStatus:      &status,
+		ResponseContentType: &emptyContentType,
+	}
+
+	tests := []struct {
+		name               string
+		method             string
+		key                string
+		token              string
+		handler            echo.HandlerFunc
+		setupMock          func(mockService *MockIdempotencyService)
+		expectedStatusCode int
+		expectedBody       string
+		expectedError      *model.ErrorResponse
+		expectReplayed     bool
+		expectEmptyBody    bool
+		expectHandlerCall  bool
+		expectErr          bool
+	}{
+		{
+			name:               "Request without key is passed through",
+			method:             http.MethodPost,
+			token:              "valid-token",
+			setupMock:          func(mockService *MockIdempotencyService) {},
+			expectedStatusCode: http.StatusCreated,
+			expectHandlerCall:  true,
+		},
+		{
+			name:               "Safe method is passed through",
+			method:             http.MethodGet,
+			key:                "key-1",
+			token:              "valid-token",
+			setupMock:          func(mockService *MockIdempotencyService) {},
+			expectedStatusCode: http.StatusCreated,
+			expectHandlerCall:  true,
+		},
+		{
+			name:               "Unauthenticated request is passed through",
+			method:             http.MethodPost,
+			key:                "key-1",
+			setupMock:          func(mockService *MockIdempotencyService) {},
+			expectedStatusCode: http.StatusCreated,
+			expectHandlerCall:  true,
+		},
+		{
+			name:               "Key too long",
+			method:             http.MethodPost,
+			key:                strings.Repeat("k", 256),
+			token:              "valid-token",
+			setupMock:          func(mockService *MockIdempotencyService) {},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedError:      model.InvalidIdempotencyKeyResponse,
+		},
+		{
+			name:   "First request stores the response",
+			method: http.MethodPost,
+			key:    "key-1",
+			token:  "valid-token",
+			setupMock: func(mockService *MockIdempotencyService) {
+				mockService.On("Begin", mock.Anything, userID, "key-1", mock.Anything).Return(nil, nil)
+				mockService.On("Complete", mock.Anything, userID, "key-1", http.StatusCreated, echo.MIMEApplicationJSON,
+					mock.MatchedBy(func(body []byte) bool { return strings.Contains(string(body), `"body":"{\"title\":\"Buy milk\"}"`) })).Return(nil)
+			},
+			expectedStatusCode: http.StatusCreated,
+			expectHandlerCall:  true,
+		},
+		{
+			name:   "Retry replays the stored response",
+			method: http.MethodPost,
+			key:    "key-1",
+			token:  "valid-token",
+			setupMock: func(mockService *MockIdempotencyService) {
+				mockService.On("Begin", mock.Anything, userID, "key-1", mock.Anything).Return(stored, nil)
+			},
+			expectedStatusCode: http.StatusCreated,
+			expectedBody:       `{"id":"stored"}`,
+			expectReplayed:     true,
+		},
+		{
+			name:   "Secret response stores only the status",
+			method: http.MethodPost,
+			key:    "key-1",
+			token:  "valid-token",
+			handler: SecretResponse(func(ctx echo.Context) error {
+				return ctx.JSON(http.StatusCreated, map[string]string{"secret": "s3cr3t"})
+			}),
+			setupMock: func(mockService *MockIdempotencyService) {
+				mockService.On("Begin", mock.Anything, userID, "key-1", mock.Anything).Return(nil, nil)
+				mockService.On("Complete", mock.Anything, userID, "key-1", http.StatusCreated, "", []byte(nil)).Return(nil)
+			},
+			expectedStatusCode: http.StatusCreated,
+			expectedBody:       "{\"secret\":\"s3cr3t\"}\n",
+			expectHandlerCall:  true,
+		},
+		{
+			name:   "Retry of a secret response replays only the status",
+			method: http.MethodPost,
+			key:    "key-1",
+			token:  "valid-token",
+			setupMock: func(mockService *MockIdempotencyService) {
+				mockService.On("Begin", mock.Anything, userID, "key-1", mock.Anything).Return(storedSecret, nil)
+			},
+			expectedStatusCode: http.StatusCreated,
+			expectReplayed:     true,
+			expectEmptyBody:    true,
+		},
+		{
+			name:   "Key reused with a different request",
+			method: http.MethodPost,
+			key:    "key-1",
+			token:  "valid-token",
+			setupMock: func(mockService *MockIdempotencyService) {
+				mockService.On("Begin", mock.Anything, userID, "key-1", mock.Anything).Return(nil, service.ErrIdempotencyKeyReused)
+			},
+			expectedStatusCode: http.StatusUnprocessableEntity,
+			expectedError:      model.IdempotencyKeyReusedResponse,
+		},
+		{
+			name:   "Key in progress",
+			method: http.MethodPost,
+			key:    "key-1",
+			token:  "valid-token",
+			setupMock: func(mockService *MockIdempotencyService) {
+				mockService.On("Begin", mock.Anything, userID, "key-1", mock.Anything).Return(nil, service.ErrIdempotencyKeyInProgress)
+			},
+			expectedStatusCode: http.StatusConflict,
+			expectedError:      model.IdempotencyKeyInProgressResponse,
+		},
+		{
+			name:   "Server error releases the key",
+			method: http.MethodDelete,
+			key:    "key-1",
+			token:  "valid-token",
+			handler: func(ctx echo.Context) error {
+				return ctx.JSON(http.StatusInternalServerError, model.FailedToOperateResponse)
+			},
+			setupMock: func(mockService *MockIdempotencyService) {
+				mockService.On("Begin", mock.Anything, userID, "key-1", mock.Anything).Return(nil, nil)
+				mockService.On("Release", mock.Anything, userID, "key-1").Return(nil)
+			},
+			expectedStatusCode: http.StatusInternalServerError,
+			expectHandlerCall:  true,
+		},
+		{
+			name:   "Validation error is stored",
+			method: http.MethodPost,
+			key:    "key-1",
+			token:  "valid-token",
+			handler: func(ctx echo.Context) error {
+				return ctx.JSON(http.StatusBadRequest, model.ValidationFailedResponse)
+			},
+			setupMock: func(mockService *MockIdempotencyService) {
+				mockService.On("Begin", mock.Anything, userID, "key-1", mock.Anything).Return(nil, nil)
+				mockService.On("Complete", mock.Anything, userID, "key-1", http.StatusBadRequest, echo.MIMEApplicationJSON, mock.Anything).Return(nil)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectHandlerCall:  true,
+		},
+		{
+			name:   "Rate limited response releases the key",
+			method: http.MethodPost,
+			key:    "key-1",
+			token:  "valid-token",
+			handler: func(ctx echo.Context) error {
+				return ctx.JSON(http.StatusTooManyRequests, model.RateLimitExceededResponse)
+			},
+			setupMock: func(mockService *MockIdempotencyService) {
+				mockService.On("Begin", mock.Anything, userID, "key-1", mock.Anything).Return(nil, nil)
+				mockService.On("Release", mock.Anything, userID, "key-1").Return(nil)
+			},
+			expectedStatusCode: http.StatusTooManyRequests,
+			expectHandlerCall:  true,
+		},
+		{
+			name:   "Authentication error releases the key",
+			method: http.MethodPost,
+			key:    "key-1",
+			token:  "valid-token",
+			handler: func(ctx echo.Context) error {
+				return ctx.JSON(http.StatusForbidden, model.EmailNotVerifiedResponse)
+			},
+			setupMock: func(mockService *MockIdempotencyService) {
+				mockService.On("Begin", mock.Anything, userID, "key-1", mock.Anything).Return(nil, nil)
+				mockService.On("Release", mock.Anything, userID, "key-1").Return(nil)
+			},
+			expectedStatusCode: http.StatusForbidden,
+			expectHandlerCall:  true,
+		},
+		{
+			name:   "Unwritten response releases the key",
+			method: http.MethodPut,
+			key:    "key-1",
+			token:  "valid-token",
+			handler: func(ctx echo.Context) error {
+				return errors.New("handler failed")
+			},
+			setupMock: func(mockService *MockIdempotencyService) {
+				mockService.On("Begin", mock.Anything, userID, "key-1", mock.Anything).Return(nil, nil)
+				mockService.On("Release", mock.Anything, userID, "key-1").Return(nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectHandlerCall:  true,
+			expectErr:          true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			e := echo.New()
+			req := httptest.NewRequest(tt.method, "/api/todos", strings.NewReader(`{"title":"Buy milk"}`))
+			if tt.key != "" {
+				req.Header.Set(HeaderIdempotencyKey, tt.key)
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(req, rec)
+
+			mockAuthService := new(MockAuthenticationService)
+			mockAuthService.On("ValidateToken", "valid-token").Return(&service.Claims{
+				UserID: userID.String(),
+				Type:   string(service.AccessToken),
+			}, nil)
+			mockService := new(MockIdempotencyService)
+			tt.setupMock(mockService)
+			h := NewIdempotencyHandler(mockService, mockAuthService, nil, nil, config.DefaultIdempotencyConfig())
+
+			// The default handler echoes the body it received, to check it was put back after fingerprinting
+			handlerCalled := false
+			next := tt.handler
+			if next == nil {
+				next = func(ctx echo.Context) error {
+					body, _ := io.ReadAll(ctx.Request().Body)
+					return ctx.JSON(http.StatusCreated, map[string]string{"body": string(body)})
+				}
+			}
+
+			// Execute
+			err := h.Middleware(func(ctx echo.Context) error {
+				handlerCalled = true
+				return next(ctx)
+			})(ctx)
+
+			// Assert
+			if tt.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedStatusCode, rec.Code)
+			assert.Equal(t, tt.expectHandlerCall, handlerCalled)
+			if tt.expectedBody != "" {
+				assert.Equal(t, tt.expectedBody, rec.Body.String())
+			}
+			if tt.expectEmptyBody {
+				assert.Empty(t, rec.Body.String())
+				assert.Empty(t, rec.Header().Get(echo.HeaderContentType))
+			}
+			if tt.expectedError != nil {
+				var errorResponse model.ErrorResponse
+				json.Unmarshal(rec.Body.Bytes(), &errorResponse)
+				assert.Equal(t, tt.expectedError.Code, errorResponse.Code)
+			}
+			if tt.expectReplayed {
+				assert.Equal(t, "true", rec.Header().Get(HeaderIdempotentReplayed))
+			} else {
+				assert.Empty(t, rec.Header().Get(HeaderIdempotentReplayed))
+			}
+			if handlerCalled && tt.handler == nil {
+				assert.Contains(t, rec.Body.String(), "Buy milk")
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestIdempotencyRetryAfterRateLimit(t *testing.T) {
+	userID := uuid.New()
+
+	mockAuthService := new(MockAuthenticationService)
+	mockAuthService.On("ValidateToken", "valid-token").Return(&service.Claims{
+		UserID: userID.String(),
+		Type:   string(service.AccessToken),
+	}, nil)
+	mockRateLimitService := new(MockRateLimitService)
+	mockRateLimitService.On("Take", mock.Anything, userID, "/api/todos").
+		Return(&model.RateLimitResult{Allowed: false, Limit: 10, RetryAfter: time.Second}, nil).Once()
+	mockRateLimitService.On("Take", mock.Anything, userID, "/api/todos").
+		Return(&model.RateLimitResult{Allowed: true, Limit: 10, Remaining: 9}, nil).Once()
+
+	// The throttled request leaves the key to the retry, whose response is the one stored
+	mockService := new(MockIdempotencyService)
+	mockService.On("Begin", mock.Anything, userID, "key-1", mock.Anything).Return(nil, nil).Twice()
+	mockService.On("Release", mock.Anything, userID, "key-1").Return(nil).Once()
+	mockService.On("Complete", mock.Anything, userID, "key-1", http.StatusCreated, echo.MIMEApplicationJSON, mock.Anything).Return(nil).Once()
+
+	authHandler := NewAuthHandler(mockAuthService, nil, nil, nil, mockRateLimitService, config.UnverifiedAccessFull)
+	idempotencyHandler := NewIdempotencyHandler(mockService, mockAuthService, nil, nil, config.DefaultIdempotencyConfig())
+	e := echo.New()
+	e.Use(idempotencyHandler.Middleware)
+	handlerCalls := 0
+	e.POST("/api/todos", func(ctx echo.Context) error {
+		handlerCalls++
+		return ctx.JSON(http.StatusCreated, map[string]string{"id": "created"})
+	}, authHandler.RequireAuth)
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/todos", strings.NewReader(`{"title":"Buy milk"}`))
+		req.Header.Set(HeaderIdempotencyKey, "key-1")
+		req.Header.Set("Authorization", "Bearer valid-token")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := send()
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, 0, handlerCalls)
+
+	rec = send()
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Empty(t, rec.Header().Get(HeaderIdempotentReplayed))
+	assert.Equal(t, 1, handlerCalls)
+	mockService.AssertExpectations(t)
+	mockRateLimitService.AssertExpectations(t)
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+)
+
+// Rate limit headers
+const (
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+	HeaderRetryAfter         = "Retry-After"
+)
+
+// limitRate takes the request from the allowance of the authenticated user before calling next,
+// and reports the allowance in the RateLimit headers
+// The request is let through if the allowance cannot be checked, so that the limits never take the API down
+func (h *AuthHandler) limitRate(ctx echo.Context, claims *service.Claims, next echo.HandlerFunc) error {
+	if h.rateLimitService == nil {
+		return next(ctx)
+	}
+
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return next(ctx)
+	}
+
+	result, err := h.rateLimitService.Take(ctx.Request().Context(), userID, ctx.Path())
+	if err != nil {
+		return next(ctx)
+	}
+
+	header := ctx.Response().Header()
+	header.Set(HeaderRateLimitLimit, strconv.Itoa(result.Limit))
+	header.Set(HeaderRateLimitRemaining, strconv.Itoa(result.Remaining))
+	header.Set(HeaderRateLimitReset, strconv.Itoa(ceilSeconds(result.ResetAfter)))
+	if !result.Allowed {
+		header.Set(HeaderRetryAfter, strconv.Itoa(max(ceilSeconds(result.RetryAfter), 1)))
+		return ctx.JSON(http.StatusTooManyRequests, model.RateLimitExceededResponse)
+	}
+
+	return next(ctx)
+}
+
+// ceilSeconds returns the duration in whole seconds, rounded up
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+)
+
+// MockRateLimitService is a mock of RateLimitService interface
+type MockRateLimitService struct {
+	mock.Mock
+}
+
+// Take mocks the Take method
+func (m *MockRateLimitService) Take(ctx context.Context, userID uuid.UUID, route string) (*model.RateLimitResult, error) {
+	args := m.Called(ctx, userID, route)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.RateLimitResult), args.Error(1)
+}
+
+// PurgeFull mocks the PurgeFull method
+func (m *MockRateLimitService) PurgeFull(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func TestRequireAuthRateLimit(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name               string
+		result             *model.RateLimitResult
+		err                error
+		expectedStatusCode int
+		expectedHeaders    map[string]string
+		expectHandlerCall  bool
+	}{
+		{
+			name:               "request within the limit",
+			result:             &model.RateLimitResult{Allowed: true, Limit: 120, Remaining: 119, ResetAfter: 500 * time.Millisecond},
+			expectedStatusCode: http.StatusOK,
+			expectedHeaders: map[string]string{
+				HeaderRateLimitLimit:     "120",
+				HeaderRateLimitRemaining: "119",
+				HeaderRateLimitReset:     "1",
+				HeaderRetryAfter:         "",
+			},
+			expectHandlerCall: true,
+		},
+		{
+			name:               "request over the limit",
+			result:             &model.RateLimitResult{Limit: 120, RetryAfter: 1500 * time.Millisecond, ResetAfter: time.Minute},
+			expectedStatusCode: http.StatusTooManyRequests,
+			expectedHeaders: map[string]string{
+				HeaderRateLimitLimit:     "120",
+				HeaderRateLimitRemaining: "0",
+				HeaderRateLimitReset:     "60",
+				HeaderRetryAfter:         "2",
+			},
+		},
+		{
+			name:               "request is let through when the limit cannot be checked",
+			err:                errors.New("store unavailable"),
+			expectedStatusCode: http.StatusOK,
+			expectedHeaders: map[string]string{
+				HeaderRateLimitLimit: "",
+			},
+			expectHandlerCall: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/api/todos/1", nil)
+			req.Header.Set("Authorization", "Bearer valid-token")
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(req, rec)
+			ctx.SetPath("/api/todos/:id")
+
+			mockAuthService := new(MockAuthenticationService)
+			mockAuthService.On("ValidateToken", "valid-token").Return(&service.Claims{
+				UserID: userID.String(),
+				Type:   string(service.AccessToken),
+			}, nil)
+			mockRateLimitService := new(MockRateLimitService)
+			if tt.result != nil {
+				mockRateLimitService.On("Take", mock.Anything, userID, "/api/todos/:id").Return(tt.result, nil)
+			} else {
+				mockRateLimitService.On("Take", mock.Anything, userID, "/api/todos/:id").Return(nil, tt.err)
+			}
+			authHandler := NewAuthHandler(mockAuthService, nil, nil, nil, mockRateLimitService, config.UnverifiedAccessFull)
+
+			// Execute
+			handlerCalled := false
+			err := authHandler.RequireAuth(func(ctx echo.Context) error {
+				handlerCalled = true
+				return ctx.NoContent(http.StatusOK)
+			})(ctx)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatusCode, rec.Code)
+			assert.Equal(t, tt.expectHandlerCall, handlerCalled)
+			for header, value := range tt.expectedHeaders {
+				assert.Equal(t, value, rec.Header().Get(header), header)
+			}
+			if tt.expectedStatusCode == http.StatusTooManyRequests {
+				var errorResponse model.ErrorResponse
+				json.Unmarshal(rec.Body.Bytes(), &errorResponse)
+				assert.Equal(t, model.RateLimitExceededResponse.Code, errorResponse.Code)
+			}
+			mockRateLimitService.AssertExpectations(t)
+		})
+	}
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"strings"
+	"time"
 
 	"github.com/yukimaterrace/todoms/config"
 	"github.com/yukimaterrace/todoms/controller"
@@ -29,19 +32,208 @@ func main() {
 	// Initialize repositories
 	userRepo := repository.NewUserRepository(db)
 	todoRepo := repository.NewTodoRepository(db)
+	shareRepo := repository.NewTodoShareRepository(db)
+	commentRepo := repository.NewCommentRepository(db)
+	attachmentRepo := repository.NewAttachmentRepository(db)
+	activityRepo := repository.NewActivityRepository(db)
+	webhookRepo := repository.NewWebhookRepository(db)
+	outboxRepo := repository.NewOutboxRepository(db)
+	syncRepo := repository.NewSyncRepository(db)
+	idempotencyRepo := repository.NewIdempotencyRepository(db)
+	loginAttemptRepo := repository.NewLoginAttemptRepository(db)
+	userTokenRepo := repository.NewUserTokenRepository(db)
+	mfaRepo := repository.NewMFARepository(db)
+	webAuthnRepo := repository.NewWebAuthnRepository(db)
+	oauthRepo := repository.NewOAuthRepository(db)
+	tokenRepo := repository.NewPersonalAccessTokenRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	identityRepo := repository.NewIdentityRepository(db)
+	transactor := repository.NewTransactor(db)
+
+	// Connect to rate limit store
+	rateLimitStore, err := repository.ConnectRateLimitStore(db)
+	if err != nil {
+		log.Fatalf("Failed to connect to rate limit store: %v", err)
+	}
+
+	// Connect to blob store
+	blobStore, err := repository.ConnectBlobStore(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to connect to blob store: %v", err)
+	}
+
+	// Open the breached-password corpus, which new passwords are only checked against when it is configured
+	passwordPolicyConfig := config.DefaultPasswordPolicyConfig()
+	passwordPolicyConfig.BreachedPasswordsDir = repository.GetEnvOrDefault("BREACHED_PASSWORDS_DIR", "")
+	var breachedPasswordStore repository.BreachedPasswordStore
+	if passwordPolicyConfig.BreachedPasswordsDir != "" {
+		breachedPasswordStore, err = repository.NewFileBreachedPasswordStore(passwordPolicyConfig.BreachedPasswordsDir)
+		if err != nil {
+			log.Fatalf("Failed to open breached password corpus: %v", err)
+		}
+	}
+
+	// Initialize mailer
+	mailConfig := config.DefaultMailConfig()
+	mailConfig.Driver = repository.GetEnvOrDefault("MAIL_DRIVER", mailConfig.Driver)
+	mailConfig.From = repository.GetEnvOrDefault("MAIL_FROM", mailConfig.From)
+	mailConfig.Dir = repository.GetEnvOrDefault("MAIL_DIR", mailConfig.Dir)
+	mailConfig.SMTPHost = repository.GetEnvOrDefault("SMTP_HOST", mailConfig.SMTPHost)
+	mailConfig.SMTPPort = repository.GetEnvOrDefault("SMTP_PORT", mailConfig.SMTPPort)
+	mailConfig.SMTPUsername = repository.GetEnvOrDefault("SMTP_USERNAME", mailConfig.SMTPUsername)
+	mailConfig.SMTPPassword = repository.GetEnvOrDefault("SMTP_PASSWORD", mailConfig.SMTPPassword)
+	mailer, err := service.NewMailer(mailConfig, logger)
+	if err != nil {
+		log.Fatalf("Failed to initialize mailer: %v", err)
+	}
 
 	// Initialize services
+	unverifiedAccess, err := config.ParseUnverifiedAccess(repository.GetEnvOrDefault("UNVERIFIED_ACCESS", string(config.UnverifiedAccessReadOnly)))
+	if err != nil {
+		log.Fatalf("Invalid UNVERIFIED_ACCESS: %v", err)
+	}
 	authConfig := &config.AuthConfig{
-		JWTSecret:          repository.GetEnvOrDefault("JWT_SECRET", "your-secret-key-change-me-in-production"),
-		AccessTokenExpiry:  config.DefaultAccessTokenExpiry,
-		RefreshTokenExpiry: config.DefaultRefreshTokenExpiry,
+		JWTSecret:              repository.GetEnvOrDefault("JWT_SECRET", "your-secret-key-change-me-in-production"),
+		AccessTokenExpiry:      config.DefaultAccessTokenExpiry,
+		RefreshTokenExpiry:     config.DefaultRefreshTokenExpiry,
+		MFAChallengeExpiry:     config.DefaultMFAChallengeExpiry,
+		UnverifiedAccess:       unverifiedAccess,
+		ReauthenticationWindow: config.DefaultReauthenticationWindow,
+	}
+	passwordHashConfig := config.DefaultPasswordHashConfig()
+	passwordHashConfig.Algorithm, err = config.ParsePasswordHashAlgorithm(repository.GetEnvOrDefault("PASSWORD_HASH_ALGORITHM", string(passwordHashConfig.Algorithm)))
+	if err != nil {
+		log.Fatalf("Invalid PASSWORD_HASH_ALGORITHM: %v", err)
+	}
+	mfaConfig := config.DefaultMFAConfig()
+	mfaConfig.Issuer = repository.GetEnvOrDefault("MFA_ISSUER", mfaConfig.Issuer)
+	mfaConfig.EncryptionKey = repository.GetEnvOrDefault("MFA_ENCRYPTION_KEY", "")
+	if mfaConfig.EncryptionKey == "" {
+		logger.Warn("MFA_ENCRYPTION_KEY is not set, deriving the key two-factor secrets are encrypted with from JWT_SECRET")
+		mfaConfig.EncryptionKey = config.DeriveMFAEncryptionKey(authConfig.JWTSecret)
+	}
+	webAuthnConfig := config.DefaultWebAuthnConfig()
+	webAuthnConfig.RPID = repository.GetEnvOrDefault("WEBAUTHN_RP_ID", webAuthnConfig.RPID)
+	webAuthnConfig.RPName = repository.GetEnvOrDefault("WEBAUTHN_RP_NAME", webAuthnConfig.RPName)
+	webAuthnConfig.Origins = strings.Split(repository.GetEnvOrDefault("WEBAUTHN_ORIGINS", strings.Join(webAuthnConfig.Origins, ",")), ",")
+	oidcConfig := config.DefaultOIDCConfig()
+	for _, name := range strings.Fields(strings.ReplaceAll(repository.GetEnvOrDefault("OIDC_PROVIDERS", ""), ",", " ")) {
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		providerConfig := config.OIDCProviderConfig{
+			Name:         name,
+			Issuer:       repository.GetEnvOrDefault(prefix+"ISSUER", ""),
+			ClientID:     repository.GetEnvOrDefault(prefix+"CLIENT_ID", ""),
+			ClientSecret: repository.GetEnvOrDefault(prefix+"CLIENT_SECRET", ""),
+			RedirectURL:  repository.GetEnvOrDefault(prefix+"REDIRECT_URL", ""),
+			Scopes:       strings.Fields(repository.GetEnvOrDefault(prefix+"SCOPES", "")),
+		}
+		if providerConfig.Issuer == "" || providerConfig.ClientID == "" || providerConfig.RedirectURL == "" {
+			log.Fatalf("%sISSUER, %sCLIENT_ID and %sREDIRECT_URL are required for OIDC provider %q", prefix, prefix, prefix, name)
+		}
+		oidcConfig.Providers = append(oidcConfig.Providers, providerConfig)
+	}
+	emailVerificationConfig := config.DefaultEmailVerificationConfig()
+	emailVerificationConfig.VerificationURL = repository.GetEnvOrDefault("EMAIL_VERIFICATION_URL", emailVerificationConfig.VerificationURL)
+	passwordResetConfig := config.DefaultPasswordResetConfig()
+	passwordResetConfig.ResetURL = repository.GetEnvOrDefault("PASSWORD_RESET_URL", passwordResetConfig.ResetURL)
+	webhookConfig := config.DefaultWebhookConfig()
+	webhookConfig.AllowPrivateNetworks = repository.GetEnvOrDefault("WEBHOOK_ALLOW_PRIVATE_NETWORKS", "false") == "true"
+	mfaService := service.NewMFAService(userRepo, mfaRepo, transactor, mfaConfig, logger)
+	webAuthnService := service.NewWebAuthnService(userRepo, webAuthnRepo, webAuthnConfig, logger)
+	todoService := service.NewTodoService(todoRepo, shareRepo, activityRepo, transactor, config.DefaultTodoQuotaConfig(), logger)
+	passwordPolicy := service.NewPasswordPolicy(breachedPasswordStore, passwordPolicyConfig, logger)
+	passwordHasher := service.NewPasswordHasher(passwordHashConfig)
+	userService := service.NewUserService(todoService, passwordPolicy, passwordHasher, userRepo, userTokenRepo, identityRepo, activityRepo, transactor, authConfig, logger)
+	oidcService := service.NewOIDCService(userService, userRepo, identityRepo, nil, oidcConfig, logger)
+	authService := service.NewJWTAuthService(userRepo, sessionRepo, passwordHasher, mfaService, webAuthnService, oidcService, authConfig, logger)
+	oauthService := service.NewOAuthService(userRepo, oauthRepo, transactor, config.DefaultOAuthConfig(), logger)
+	tokenService := service.NewPersonalAccessTokenService(userRepo, tokenRepo, transactor, config.DefaultPersonalAccessTokenConfig(), logger)
+	sessionService := service.NewSessionService(sessionRepo, logger)
+	shareService := service.NewShareService(todoService, shareRepo, userRepo, activityRepo, transactor, logger)
+	commentService := service.NewCommentService(todoService, commentRepo, activityRepo, transactor, logger)
+	attachmentConfig := config.DefaultAttachmentConfig()
+	activityService := service.NewActivityService(todoService, activityRepo, logger)
+	webhookService := service.NewWebhookService(webhookRepo, nil, webhookConfig, logger)
+	attachmentService := service.NewAttachmentService(todoService, attachmentRepo, activityRepo, transactor, blobStore, attachmentConfig, logger)
+	syncService := service.NewSyncService(todoService, syncRepo, todoRepo, activityRepo, transactor, logger)
+	idempotencyConfig := config.DefaultIdempotencyConfig()
+	idempotencyService := service.NewIdempotencyService(idempotencyRepo, idempotencyConfig, logger)
+	loginLimiter := service.NewLoginLimiter(rateLimitStore, loginAttemptRepo, config.DefaultLoginProtectionConfig(), logger)
+	rateLimitService := service.NewRateLimitService(rateLimitStore, config.DefaultRateLimitConfig(), logger)
+	emailVerificationService := service.NewEmailVerificationService(userRepo, userTokenRepo, activityRepo, transactor, mailer, authConfig, emailVerificationConfig, logger)
+	passwordResetService := service.NewPasswordResetService(passwordPolicy, passwordHasher, userRepo, userTokenRepo, activityRepo, transactor, mailer, authConfig, passwordResetConfig, logger)
+	adminService := service.NewAdminService(userService, sessionService, passwordResetService, userRepo, todoRepo, activityRepo, transactor, logger)
+
+	// Purge blobs of deleted attachments in the background
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			attachmentService.PurgeDeletedBlobs(context.Background())
+		}
+	}()
+
+	// Purge expired idempotency keys, user tokens, passkey challenges, OpenID Connect states,
+	// OAuth codes and tokens, personal access tokens and sessions in the background
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			idempotencyService.PurgeExpired(context.Background())
+			emailVerificationService.PurgeExpired(context.Background())
+			webAuthnService.PurgeExpired(context.Background())
+			oidcService.PurgeExpired(context.Background())
+			oauthService.PurgeExpired(context.Background())
+			tokenService.PurgeExpired(context.Background())
+			sessionService.PurgeExpired(context.Background())
+		}
+	}()
+
+	// Purge refilled rate limit buckets and stale failed logins in the background
+	go func() {
+		ticker := time.NewTicker(10 * time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			rateLimitService.PurgeFull(context.Background())
+			loginLimiter.PurgeExpired(context.Background())
+		}
+	}()
+
+	// Deliver webhook events in the background
+	webhookWorker := service.NewWebhookWorker(webhookRepo, nil, webhookConfig, logger)
+	go webhookWorker.Run(context.Background(), 5*time.Second)
+
+	// Relay domain events from the outbox in the background
+	// Todo events are queued for webhooks, and published to NATS JetStream when NATS_URL is set or logged otherwise
+	brokerConfig := config.DefaultBrokerConfig()
+	brokerConfig.URL = repository.GetEnvOrDefault("NATS_URL", "")
+	brokerConfig.Stream = repository.GetEnvOrDefault("NATS_STREAM", brokerConfig.Stream)
+	brokerPublisher := service.NewLogEventPublisher(logger)
+	if brokerConfig.URL != "" {
+		js, err := service.ConnectJetStream(context.Background(), brokerConfig)
+		if err != nil {
+			log.Fatalf("Failed to connect to NATS: %v", err)
+		}
+		brokerPublisher = service.NewBrokerEventPublisher(service.NewNATSBroker(js, brokerConfig.Timeout), brokerConfig.TopicPrefix)
+	}
+	// Webhooks come first, so a broker outage does not hold them back: the event is retried as a whole,
+	// and the deliveries queued on the earlier attempt are not queued twice
+	eventPublisher := service.NewMultiEventPublisher(service.NewWebhookDispatcher(webhookRepo), brokerPublisher)
+	outboxRelay := service.NewOutboxRelay(outboxRepo, transactor, eventPublisher, config.DefaultOutboxConfig(), logger)
+	go outboxRelay.Run(context.Background(), time.Second)
+
+	// Stream todo events to real-time clients, fanned out to every instance with LISTEN/NOTIFY
+	streamConfig := config.DefaultStreamConfig()
+	outboxNotifications, err := repository.ListenOutbox(context.Background(), repository.DatabaseURL())
+	if err != nil {
+		log.Fatalf("Failed to listen for outbox events: %v", err)
 	}
-	userService := service.NewUserService(userRepo, logger)
-	authService := service.NewJWTAuthService(userRepo, authConfig, logger)
-	todoService := service.NewTodoService(todoRepo, logger)
+	todoStreamHub := service.NewTodoStreamHub(outboxRepo, todoService, streamConfig, logger)
+	go todoStreamHub.Run(context.Background(), outboxNotifications)
+	streamTicketService := service.NewStreamTicketService(userRepo, userTokenRepo, authConfig, streamConfig, logger)
 
 	// Setup Echo using controller package
-	e := controller.SetupEcho(userService, authService, todoService)
+	e := controller.SetupEcho(userService, authService, todoService, shareService, commentService, attachmentService, activityService, webhookService, todoStreamHub, streamTicketService, syncService, idempotencyService, loginLimiter, rateLimitService, emailVerificationService, passwordResetService, mfaService, webAuthnService, oidcService, oauthService, tokenService, sessionService, adminService, authConfig, attachmentConfig, streamConfig, idempotencyConfig)
 
 	// Start server
 	port := repository.GetEnvOrDefault("PORT", "8080")
@@ -0,0 +1,108 @@
+-- Change sequence for offline sync
+-- Every change a client has to download takes the next number, and clients sync from the last one they saw
+CREATE SEQUENCE IF NOT EXISTS todo_change_seq;
+
+-- version counts the updates of a todo, which clients send back to detect conflicting edits
+-- change_seq is the number of the last change to the todo
+ALTER TABLE todos
+    ADD COLUMN version    BIGINT NOT NULL DEFAULT 1,
+    ADD COLUMN change_seq BIGINT NOT NULL DEFAULT nextval('todo_change_seq');
+
+-- Create index for finding the todos of a user changed since a sync
+CREATE INDEX idx_todos_user_id_change_seq ON todos(user_id, change_seq);
+
+CREATE OR REPLACE FUNCTION bump_todo_version()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.version = OLD.version + 1;
+    NEW.change_seq = nextval('todo_change_seq');
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER bump_todo_version
+BEFORE UPDATE ON todos
+FOR EACH ROW
+EXECUTE FUNCTION bump_todo_version();
+
+-- A todo shared with a user is a change for that user even if the todo itself did not change
+ALTER TABLE todo_shares
+    ADD COLUMN change_seq BIGINT NOT NULL DEFAULT nextval('todo_change_seq');
+
+CREATE INDEX idx_todo_shares_user_id_change_seq ON todo_shares(user_id, change_seq);
+
+-- Create todo_tombstones table
+-- A tombstone tells a user's clients to remove a todo, because it was deleted or is no longer shared with them
+-- There is no foreign key, as tombstones are written while the todo or the share is being deleted
+CREATE TABLE IF NOT EXISTS todo_tombstones (
+    todo_id     UUID      NOT NULL,
+    user_id     UUID      NOT NULL,
+    change_seq  BIGINT    NOT NULL DEFAULT nextval('todo_change_seq'),
+    deleted_at  TIMESTAMP NOT NULL DEFAULT now(),
+    PRIMARY KEY (todo_id, user_id)
+);
+
+-- Create index for finding the tombstones of a user since a sync
+CREATE INDEX idx_todo_tombstones_user_id_change_seq ON todo_tombstones(user_id, change_seq);
+
+-- Write a tombstone for one user, or renew it if the todo was removed for them before
+CREATE OR REPLACE FUNCTION write_todo_tombstone(p_todo_id UUID, p_user_id UUID)
+RETURNS VOID AS $$
+BEGIN
+    INSERT INTO todo_tombstones (todo_id, user_id)
+    VALUES (p_todo_id, p_user_id)
+    ON CONFLICT (todo_id, user_id) DO UPDATE
+    SET change_seq = nextval('todo_change_seq'), deleted_at = now();
+END;
+$$ LANGUAGE plpgsql;
+
+-- The owner gets a tombstone when a todo is deleted; the users it was shared with get one
+-- when the cascade removes their shares
+CREATE OR REPLACE FUNCTION tombstone_deleted_todo()
+RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM write_todo_tombstone(OLD.id, OLD.user_id);
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER tombstone_deleted_todo
+AFTER DELETE ON todos
+FOR EACH ROW
+EXECUTE FUNCTION tombstone_deleted_todo();
+
+CREATE OR REPLACE FUNCTION tombstone_deleted_share()
+RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM write_todo_tombstone(OLD.todo_id, OLD.user_id);
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER tombstone_deleted_share
+AFTER DELETE ON todo_shares
+FOR EACH ROW
+EXECUTE FUNCTION tombstone_deleted_share();
+
+-- A todo shared again, or created again with the same client-generated ID, is no longer removed
+CREATE OR REPLACE FUNCTION clear_todo_tombstone()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_TABLE_NAME = 'todos' THEN
+        DELETE FROM todo_tombstones WHERE todo_id = NEW.id AND user_id = NEW.user_id;
+    ELSE
+        DELETE FROM todo_tombstones WHERE todo_id = NEW.todo_id AND user_id = NEW.user_id;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER clear_todo_tombstone
+AFTER INSERT ON todos
+FOR EACH ROW
+EXECUTE FUNCTION clear_todo_tombstone();
+
+CREATE TRIGGER clear_todo_tombstone
+AFTER INSERT ON todo_shares
+FOR EACH ROW
+EXECUTE FUNCTION clear_todo_tombstone();
@@ -0,0 +1,18 @@
+-- Create idempotency_keys table
+-- Each row remembers the response to a mutating request sent with an Idempotency-Key header,
+-- so that a retry of the request gets the same response instead of being applied again
+-- The response columns are null while the request is still being processed
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    user_id                UUID      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    idempotency_key        TEXT      NOT NULL,
+    fingerprint            TEXT      NOT NULL,
+    response_status        INTEGER,
+    response_content_type  TEXT,
+    response_body          BYTEA,
+    created_at             TIMESTAMP NOT NULL DEFAULT now(),
+    expires_at             TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, idempotency_key)
+);
+
+-- Create index for purging expired keys
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
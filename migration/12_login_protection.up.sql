@@ -0,0 +1,23 @@
+-- Create rate_limit_buckets table
+-- Each row is a token bucket shared by every instance, refilled from the time it was last taken from
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    bucket_key  TEXT             PRIMARY KEY,
+    tokens      DOUBLE PRECISION NOT NULL,
+    updated_at  TIMESTAMP        NOT NULL DEFAULT now()
+);
+
+-- Create index for purging idle buckets
+CREATE INDEX idx_rate_limit_buckets_updated_at ON rate_limit_buckets(updated_at);
+
+-- Create login_attempts table
+-- Failed logins are counted per email, whether or not an account exists for it,
+-- so that a lockout does not reveal which emails are registered
+CREATE TABLE IF NOT EXISTS login_attempts (
+    email            TEXT      PRIMARY KEY,
+    failure_count    INTEGER   NOT NULL DEFAULT 0,
+    last_failure_at  TIMESTAMP NOT NULL DEFAULT now(),
+    locked_until     TIMESTAMP
+);
+
+-- Create index for purging stale attempts
+CREATE INDEX idx_login_attempts_last_failure_at ON login_attempts(last_failure_at);
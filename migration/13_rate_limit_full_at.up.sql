@@ -0,0 +1,6 @@
+-- Record when each token bucket is full again, so that buckets with different limits can be purged once
+-- they no longer hold any state
+ALTER TABLE rate_limit_buckets ADD COLUMN full_at TIMESTAMP NOT NULL DEFAULT now();
+
+DROP INDEX IF EXISTS idx_rate_limit_buckets_updated_at;
+CREATE INDEX idx_rate_limit_buckets_full_at ON rate_limit_buckets(full_at);
@@ -0,0 +1,23 @@
+-- Record when users verified their email address
+-- Accounts created before verification was introduced are treated as verified
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP;
+UPDATE users SET email_verified_at = created_at;
+
+-- Create user_tokens table
+-- Each row is a single-use token sent to a user, such as an email verification link
+-- Only the token ID is stored, and the token given to the user is signed so that forged tokens are rejected
+-- without a lookup
+CREATE TABLE IF NOT EXISTS user_tokens (
+    id          UUID      PRIMARY KEY,
+    user_id     UUID      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose     TEXT      NOT NULL,
+    created_at  TIMESTAMP NOT NULL DEFAULT now(),
+    expires_at  TIMESTAMP NOT NULL,
+    used_at     TIMESTAMP
+);
+
+-- Create index for finding the tokens of a user
+CREATE INDEX idx_user_tokens_user_id_purpose ON user_tokens(user_id, purpose, created_at);
+
+-- Create index for purging expired tokens
+CREATE INDEX idx_user_tokens_expires_at ON user_tokens(expires_at);
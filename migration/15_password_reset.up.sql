@@ -0,0 +1,12 @@
+-- Count the password changes of users
+-- Tokens carry the version they were issued for, so changing it revokes every existing session
+ALTER TABLE users ADD COLUMN session_version INTEGER NOT NULL DEFAULT 0;
+
+-- Store user tokens hashed, so that tokens cannot be recovered from the database
+-- Tokens issued before cannot be matched to a hash and are removed; users can ask for a new one
+ALTER TABLE user_tokens ADD COLUMN token_hash BYTEA;
+DELETE FROM user_tokens WHERE token_hash IS NULL;
+ALTER TABLE user_tokens ALTER COLUMN token_hash SET NOT NULL;
+
+-- Create index for looking up tokens by their hash
+CREATE UNIQUE INDEX idx_user_tokens_token_hash ON user_tokens(token_hash);
@@ -0,0 +1,13 @@
+-- Add the profile of users
+ALTER TABLE users
+    ADD COLUMN display_name TEXT NOT NULL DEFAULT '',
+    ADD COLUMN timezone     TEXT NOT NULL DEFAULT 'UTC',
+    ADD COLUMN locale       TEXT NOT NULL DEFAULT 'en';
+
+-- Remove the todos of users deleted before todos referenced their owner, with their tombstones
+DELETE FROM todos WHERE user_id NOT IN (SELECT id FROM users);
+DELETE FROM todo_tombstones WHERE user_id NOT IN (SELECT id FROM users);
+
+-- Delete the todos of a user together with the user
+ALTER TABLE todos
+    ADD CONSTRAINT fk_todos_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
@@ -0,0 +1,24 @@
+-- Create user_mfa table
+-- Each row is the TOTP authenticator of a user, which is pending until it is confirmed with a first code
+-- The secret is encrypted, as it has to be read back to check codes
+-- last_used_step is the time step of the last accepted code, so that a code cannot be replayed
+CREATE TABLE IF NOT EXISTS user_mfa (
+    user_id        UUID      PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret         BYTEA     NOT NULL,
+    enabled_at     TIMESTAMP,
+    last_used_step BIGINT    NOT NULL DEFAULT 0,
+    created_at     TIMESTAMP NOT NULL DEFAULT now()
+);
+
+-- Create mfa_recovery_codes table
+-- Each row is a one-time code a user can log in with instead of a TOTP code, and only its hash is stored
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    id          UUID      PRIMARY KEY,
+    user_id     UUID      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash   BYTEA     NOT NULL,
+    used_at     TIMESTAMP,
+    created_at  TIMESTAMP NOT NULL DEFAULT now()
+);
+
+-- Create index for looking up the codes of a user by their hash
+CREATE UNIQUE INDEX idx_mfa_recovery_codes_user_id_code_hash ON mfa_recovery_codes(user_id, code_hash);
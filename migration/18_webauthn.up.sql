@@ -0,0 +1,34 @@
+-- Create webauthn_credentials table
+-- Each row is a passkey a user registered to log in without a password
+-- public_key is the COSE key of the credential, and sign_count the signature counter last reported by the authenticator
+CREATE TABLE IF NOT EXISTS webauthn_credentials (
+    id            UUID      PRIMARY KEY,
+    user_id       UUID      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    credential_id BYTEA     NOT NULL,
+    public_key    BYTEA     NOT NULL,
+    sign_count    BIGINT    NOT NULL DEFAULT 0,
+    aaguid        BYTEA     NOT NULL,
+    name          TEXT      NOT NULL,
+    created_at    TIMESTAMP NOT NULL DEFAULT now(),
+    last_used_at  TIMESTAMP
+);
+
+-- Create index for looking up credentials by the ID the authenticator returns
+CREATE UNIQUE INDEX idx_webauthn_credentials_credential_id ON webauthn_credentials(credential_id);
+
+-- Create index for listing the credentials of a user
+CREATE INDEX idx_webauthn_credentials_user_id ON webauthn_credentials(user_id, created_at);
+
+-- Create webauthn_challenges table
+-- Each row is a single-use challenge of a registration or login ceremony, and only its hash is stored
+-- user_id is set for registrations, while logins start before the user is known
+CREATE TABLE IF NOT EXISTS webauthn_challenges (
+    challenge_hash BYTEA     PRIMARY KEY,
+    user_id        UUID      REFERENCES users(id) ON DELETE CASCADE,
+    ceremony       TEXT      NOT NULL,
+    created_at     TIMESTAMP NOT NULL DEFAULT now(),
+    expires_at     TIMESTAMP NOT NULL
+);
+
+-- Create index for purging expired challenges
+CREATE INDEX idx_webauthn_challenges_expires_at ON webauthn_challenges(expires_at);
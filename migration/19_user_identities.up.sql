@@ -0,0 +1,35 @@
+-- Create user_identities table
+-- Each row links an account of an external OpenID Connect provider to a user, who can then log in with it
+-- subject is the stable ID the provider gives the account, and email the address it reported at the last login
+CREATE TABLE IF NOT EXISTS user_identities (
+    id            UUID      PRIMARY KEY,
+    user_id       UUID      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider      TEXT      NOT NULL,
+    subject       TEXT      NOT NULL,
+    email         TEXT      NOT NULL DEFAULT '',
+    created_at    TIMESTAMP NOT NULL DEFAULT now(),
+    last_login_at TIMESTAMP
+);
+
+-- Create index for looking up the user an external account is linked to
+CREATE UNIQUE INDEX idx_user_identities_provider_subject ON user_identities(provider, subject);
+
+-- Create index for listing the identities of a user
+CREATE INDEX idx_user_identities_user_id ON user_identities(user_id, created_at);
+
+-- Create oidc_states table
+-- Each row is a single-use state of an authorization request to a provider, and only its hash is stored
+-- code_verifier is the PKCE verifier the authorization code is redeemed with, and nonce the value the ID token has to carry
+-- user_id is set when linking a provider to a logged in user
+CREATE TABLE IF NOT EXISTS oidc_states (
+    state_hash    BYTEA     PRIMARY KEY,
+    provider      TEXT      NOT NULL,
+    code_verifier TEXT      NOT NULL,
+    nonce         TEXT      NOT NULL,
+    user_id       UUID      REFERENCES users(id) ON DELETE CASCADE,
+    created_at    TIMESTAMP NOT NULL DEFAULT now(),
+    expires_at    TIMESTAMP NOT NULL
+);
+
+-- Create index for purging expired states
+CREATE INDEX idx_oidc_states_expires_at ON oidc_states(expires_at);
@@ -0,0 +1,73 @@
+-- Create oauth_clients table
+-- Each row is a third-party application a user registered to access the API on behalf of users
+-- The ID is the client_id, and only the hash of the secret is stored. Public clients have no secret
+-- redirect_uris are the only URIs authorization codes are sent to, and scopes the most the client can be granted
+CREATE TABLE IF NOT EXISTS oauth_clients (
+    id            UUID      PRIMARY KEY,
+    user_id       UUID      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name          TEXT      NOT NULL,
+    secret_hash   BYTEA,
+    redirect_uris TEXT[]    NOT NULL,
+    scopes        TEXT[]    NOT NULL,
+    created_at    TIMESTAMP NOT NULL DEFAULT now()
+);
+
+-- Create index for listing the clients of a user
+CREATE INDEX idx_oauth_clients_user_id ON oauth_clients(user_id, created_at);
+
+-- Create oauth_consents table
+-- Each row records the scopes a user granted a client
+CREATE TABLE IF NOT EXISTS oauth_consents (
+    user_id    UUID      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    client_id  UUID      NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+    scopes     TEXT[]    NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, client_id)
+);
+
+-- Create oauth_authorization_codes table
+-- Each row is an authorization code issued to a client, and only its hash is stored
+-- A code is kept once used, so that a second use can be detected and the tokens of its grant revoked
+-- code_challenge is the PKCE challenge the verifier has to match when the code is redeemed
+CREATE TABLE IF NOT EXISTS oauth_authorization_codes (
+    code_hash      BYTEA     PRIMARY KEY,
+    grant_id       UUID      NOT NULL,
+    client_id      UUID      NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+    user_id        UUID      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    redirect_uri   TEXT      NOT NULL,
+    scopes         TEXT[]    NOT NULL,
+    code_challenge TEXT      NOT NULL,
+    created_at     TIMESTAMP NOT NULL DEFAULT now(),
+    expires_at     TIMESTAMP NOT NULL,
+    used_at        TIMESTAMP
+);
+
+-- Create index for purging expired codes
+CREATE INDEX idx_oauth_authorization_codes_expires_at ON oauth_authorization_codes(expires_at);
+
+-- Create oauth_tokens table
+-- Each row is an access or refresh token issued to a client, and only its hash is stored
+-- Tokens issued for the same authorization share the grant_id, so that they can be revoked together
+-- session_version is the version of the sessions of the user when the grant was made, so that
+-- changing or resetting the password revokes the tokens
+CREATE TABLE IF NOT EXISTS oauth_tokens (
+    token_hash      BYTEA     PRIMARY KEY,
+    kind            TEXT      NOT NULL,
+    grant_id        UUID      NOT NULL,
+    client_id       UUID      NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+    user_id         UUID      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    scopes          TEXT[]    NOT NULL,
+    session_version INTEGER   NOT NULL,
+    created_at      TIMESTAMP NOT NULL DEFAULT now(),
+    expires_at      TIMESTAMP NOT NULL
+);
+
+-- Create index for revoking the tokens of a grant
+CREATE INDEX idx_oauth_tokens_grant_id ON oauth_tokens(grant_id);
+
+-- Create index for revoking the tokens a user granted a client
+CREATE INDEX idx_oauth_tokens_user_id_client_id ON oauth_tokens(user_id, client_id);
+
+-- Create index for purging expired tokens
+CREATE INDEX idx_oauth_tokens_expires_at ON oauth_tokens(expires_at);
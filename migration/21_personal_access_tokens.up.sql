@@ -0,0 +1,20 @@
+-- Create personal_access_tokens table
+-- Each row is a long-lived token a user created for scripts and CLIs, and only its hash is stored
+-- scopes are what the token grants, and a token without expires_at does not expire
+-- last_used_at is updated at most once a minute, so that busy scripts do not write on every request
+CREATE TABLE IF NOT EXISTS personal_access_tokens (
+    id           UUID      PRIMARY KEY,
+    user_id      UUID      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name         TEXT      NOT NULL,
+    token_hash   BYTEA     NOT NULL UNIQUE,
+    scopes       TEXT[]    NOT NULL,
+    created_at   TIMESTAMP NOT NULL DEFAULT now(),
+    expires_at   TIMESTAMP,
+    last_used_at TIMESTAMP
+);
+
+-- Create index for listing the tokens of a user
+CREATE INDEX idx_personal_access_tokens_user_id ON personal_access_tokens(user_id, created_at);
+
+-- Create index for purging expired tokens
+CREATE INDEX idx_personal_access_tokens_expires_at ON personal_access_tokens(expires_at);
@@ -0,0 +1,21 @@
+-- Create sessions table
+-- Each row is a login on a device, kept alive by refreshing its token pair
+-- Only the hash of the latest refresh token of the session is stored, so a rotated-out refresh token cannot be used again
+-- session_version is the session version of the user at login, and sessions of an older version were revoked by a password change
+CREATE TABLE IF NOT EXISTS sessions (
+    id                 UUID      PRIMARY KEY,
+    user_id            UUID      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    refresh_token_hash BYTEA     NOT NULL,
+    session_version    INTEGER   NOT NULL,
+    user_agent         TEXT      NOT NULL DEFAULT '',
+    ip_address         TEXT      NOT NULL DEFAULT '',
+    created_at         TIMESTAMP NOT NULL DEFAULT now(),
+    last_used_at       TIMESTAMP NOT NULL DEFAULT now(),
+    expires_at         TIMESTAMP NOT NULL
+);
+
+-- Create index for listing the sessions of a user
+CREATE INDEX idx_sessions_user_id ON sessions(user_id, created_at);
+
+-- Create index for purging expired sessions
+CREATE INDEX idx_sessions_expires_at ON sessions(expires_at);
@@ -0,0 +1,8 @@
+-- Add the role of users and whether an admin disabled their account
+-- Admins can manage other users through the admin API, and disabled users cannot log in
+ALTER TABLE users
+    ADD COLUMN role        TEXT      NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'admin')),
+    ADD COLUMN disabled_at TIMESTAMP;
+
+-- Create index for listing users, newest first
+CREATE INDEX idx_users_created_at ON users(created_at, id);
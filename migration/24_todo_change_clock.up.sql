@@ -0,0 +1,45 @@
+-- Serialize the numbering of sync changes
+-- Numbers taken from a sequence are assigned when a change is written, so a transaction that commits after
+-- one with a higher number could be passed over by a client that synced in between
+-- Changes now take their number from a single-row clock whose row lock is held until the transaction ends,
+-- so writers number their changes one transaction at a time and every commit has higher numbers than the last
+CREATE TABLE IF NOT EXISTS todo_change_clock (
+    id   BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    seq  BIGINT  NOT NULL
+);
+
+INSERT INTO todo_change_clock (seq)
+SELECT last_value FROM todo_change_seq;
+
+CREATE OR REPLACE FUNCTION next_todo_change_seq()
+RETURNS BIGINT AS $$
+DECLARE
+    next_seq BIGINT;
+BEGIN
+    UPDATE todo_change_clock SET seq = seq + 1 RETURNING seq INTO next_seq;
+    RETURN next_seq;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE todos ALTER COLUMN change_seq SET DEFAULT next_todo_change_seq();
+ALTER TABLE todo_shares ALTER COLUMN change_seq SET DEFAULT next_todo_change_seq();
+ALTER TABLE todo_tombstones ALTER COLUMN change_seq SET DEFAULT next_todo_change_seq();
+
+CREATE OR REPLACE FUNCTION bump_todo_version()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.version = OLD.version + 1;
+    NEW.change_seq = next_todo_change_seq();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION write_todo_tombstone(p_todo_id UUID, p_user_id UUID)
+RETURNS VOID AS $$
+BEGIN
+    INSERT INTO todo_tombstones (todo_id, user_id)
+    VALUES (p_todo_id, p_user_id)
+    ON CONFLICT (todo_id, user_id) DO UPDATE
+    SET change_seq = next_todo_change_seq(), deleted_at = now();
+END;
+$$ LANGUAGE plpgsql;
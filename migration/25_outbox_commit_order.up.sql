@@ -0,0 +1,20 @@
+-- Number outbox events in commit order
+-- Numbers taken from a sequence are assigned when an event is written, so an event of a transaction that
+-- commits after one with a higher number could be passed over by a stream client resuming in between
+-- Events are written in the same transactions as the todo changes they describe, so they take their number
+-- from the todo change clock those transactions already hold, which numbers one transaction at a time
+UPDATE todo_change_clock SET seq = GREATEST(seq, (SELECT COALESCE(MAX(sequence), 0) FROM outbox));
+
+ALTER TABLE outbox ALTER COLUMN sequence SET DEFAULT next_todo_change_seq();
+DROP SEQUENCE IF EXISTS outbox_sequence_seq;
+
+-- Record the highest sequence of the events pruned so far, so that a stream client resuming from an older
+-- event is told to reload rather than silently missing the pruned ones
+-- Events below the oldest one kept may already have been pruned
+CREATE TABLE IF NOT EXISTS outbox_prune_watermark (
+    id        BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    sequence  BIGINT  NOT NULL
+);
+
+INSERT INTO outbox_prune_watermark (sequence)
+SELECT COALESCE((SELECT MIN(sequence) - 1 FROM outbox), (SELECT seq FROM todo_change_clock));
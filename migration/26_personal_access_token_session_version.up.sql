@@ -0,0 +1,12 @@
+-- Record the session version of the user when a personal access token was created
+-- A password change or reset increments the session version, so tokens created before it are no longer accepted,
+-- the same as the tokens issued to OAuth clients
+-- Existing tokens keep working until the next password change
+ALTER TABLE personal_access_tokens ADD COLUMN IF NOT EXISTS session_version INTEGER NOT NULL DEFAULT 0;
+
+UPDATE personal_access_tokens t
+SET session_version = u.session_version
+FROM users u
+WHERE u.id = t.user_id;
+
+ALTER TABLE personal_access_tokens ALTER COLUMN session_version DROP DEFAULT;
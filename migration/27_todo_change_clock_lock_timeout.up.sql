@@ -0,0 +1,9 @@
+-- Bound the wait for the todo change clock
+-- The clock numbers changes in commit order by letting one transaction hold it at a time, from its first todo write
+-- until it commits, so every write to todos, shares, tombstones and the outbox across all users waits for the
+-- transaction holding it
+-- That trades write throughput for sync and stream clients never missing a change, which is fine while
+-- transactions writing todos stay short, but one kept open would block every other writer
+-- Waits longer than the timeout fail the transaction instead, so a stuck writer cannot stall the whole service
+-- The setting only applies while the function runs, so other locks of the transaction are not affected
+ALTER FUNCTION next_todo_change_seq() SET lock_timeout = '5s';
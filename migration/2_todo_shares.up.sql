@@ -0,0 +1,19 @@
+-- Create todo_shares table
+-- The owner of a todo is todos.user_id; this table only holds the users it has been shared with
+CREATE TABLE IF NOT EXISTS todo_shares (
+    todo_id       UUID      NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+    user_id       UUID      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role          TEXT      NOT NULL CHECK (role IN ('editor', 'viewer')),
+    created_at    TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at    TIMESTAMP NOT NULL DEFAULT now(),
+    PRIMARY KEY (todo_id, user_id)
+);
+
+-- Create index on user_id for looking up todos shared with a user
+CREATE INDEX idx_todo_shares_user_id ON todo_shares(user_id);
+
+-- Trigger for todo_shares table
+CREATE TRIGGER set_timestamp_todo_shares
+BEFORE UPDATE ON todo_shares
+FOR EACH ROW
+EXECUTE FUNCTION update_timestamp();
@@ -0,0 +1,18 @@
+-- Add assignee to todos
+ALTER TABLE todos ADD COLUMN assignee_id UUID REFERENCES users(id) ON DELETE SET NULL;
+
+-- Create index on assignee_id for listing todos assigned to a user
+CREATE INDEX idx_todos_assignee_id ON todos(assignee_id);
+
+-- Create todo_assignments table to keep the assignment history
+-- assignee_id is NULL when the todo was unassigned
+CREATE TABLE IF NOT EXISTS todo_assignments (
+    id            UUID      PRIMARY KEY,
+    todo_id       UUID      NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+    assignee_id   UUID      REFERENCES users(id) ON DELETE SET NULL,
+    assigned_by   UUID      REFERENCES users(id) ON DELETE SET NULL,
+    created_at    TIMESTAMP NOT NULL DEFAULT now()
+);
+
+-- Create index on todo_id for listing the history of a todo
+CREATE INDEX idx_todo_assignments_todo_id ON todo_assignments(todo_id);
@@ -0,0 +1,21 @@
+-- Create todo_comments table
+-- body holds the markdown source and body_html the sanitised HTML rendered from it
+CREATE TABLE IF NOT EXISTS todo_comments (
+    id            UUID      PRIMARY KEY,
+    todo_id       UUID      NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+    author_id     UUID      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    body          TEXT      NOT NULL,
+    body_html     TEXT      NOT NULL,
+    created_at    TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at    TIMESTAMP NOT NULL DEFAULT now(),
+    deleted_at    TIMESTAMP
+);
+
+-- Create index for listing the comments of a todo in cursor order
+CREATE INDEX idx_todo_comments_todo_id ON todo_comments(todo_id, created_at, id);
+
+-- Trigger for todo_comments table
+CREATE TRIGGER set_timestamp_todo_comments
+BEFORE UPDATE ON todo_comments
+FOR EACH ROW
+EXECUTE FUNCTION update_timestamp();
@@ -0,0 +1,39 @@
+-- Create todo_attachments table
+-- The file contents live in the blob store under storage_key
+CREATE TABLE IF NOT EXISTS todo_attachments (
+    id            UUID      PRIMARY KEY,
+    todo_id       UUID      NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+    uploader_id   UUID      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    file_name     TEXT      NOT NULL,
+    content_type  TEXT      NOT NULL,
+    size          BIGINT    NOT NULL,
+    storage_key   TEXT      UNIQUE NOT NULL,
+    created_at    TIMESTAMP NOT NULL DEFAULT now()
+);
+
+-- Create indexes for listing attachments of a todo and computing per-user quotas
+CREATE INDEX idx_todo_attachments_todo_id ON todo_attachments(todo_id);
+CREATE INDEX idx_todo_attachments_uploader_id ON todo_attachments(uploader_id);
+
+-- Create blob_deletions table
+-- Blobs of removed attachments are queued here and purged from the blob store in the background,
+-- so that no blob is leaked if the process dies between the database write and the blob removal
+CREATE TABLE IF NOT EXISTS blob_deletions (
+    storage_key   TEXT      PRIMARY KEY,
+    created_at    TIMESTAMP NOT NULL DEFAULT now()
+);
+
+-- Queue the blobs of attachments removed together with their todo or uploader
+CREATE OR REPLACE FUNCTION queue_attachment_blob_deletion()
+RETURNS TRIGGER AS $$
+BEGIN
+   INSERT INTO blob_deletions (storage_key) VALUES (OLD.storage_key) ON CONFLICT DO NOTHING;
+   RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Trigger for todo_attachments table
+CREATE TRIGGER queue_blob_deletion_todo_attachments
+AFTER DELETE ON todo_attachments
+FOR EACH ROW
+EXECUTE FUNCTION queue_attachment_blob_deletion();
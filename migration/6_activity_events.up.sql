@@ -0,0 +1,34 @@
+-- Create activity_events table
+-- Events outlive the entities they describe, so there are no foreign keys
+-- before and after hold only the fields that changed
+CREATE TABLE IF NOT EXISTS activity_events (
+    id            UUID      PRIMARY KEY,
+    actor_id      UUID      NOT NULL,
+    entity_type   TEXT      NOT NULL,
+    entity_id     UUID      NOT NULL,
+    todo_id       UUID,
+    action        TEXT      NOT NULL,
+    before        JSONB,
+    after         JSONB,
+    request_id    TEXT      NOT NULL DEFAULT '',
+    ip_address    TEXT      NOT NULL DEFAULT '',
+    created_at    TIMESTAMP NOT NULL DEFAULT clock_timestamp()
+);
+
+-- Create indexes for the per-todo history and the per-user feed, both newest first
+CREATE INDEX idx_activity_events_todo_id ON activity_events(todo_id, created_at DESC, id DESC);
+CREATE INDEX idx_activity_events_actor_id ON activity_events(actor_id, created_at DESC, id DESC);
+
+-- Reject any modification of recorded events
+CREATE OR REPLACE FUNCTION prevent_activity_event_mutation()
+RETURNS TRIGGER AS $$
+BEGIN
+   RAISE EXCEPTION 'activity_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+-- Trigger for activity_events table
+CREATE TRIGGER append_only_activity_events
+BEFORE UPDATE OR DELETE ON activity_events
+FOR EACH ROW
+EXECUTE FUNCTION prevent_activity_event_mutation();
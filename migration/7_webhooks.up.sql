@@ -0,0 +1,50 @@
+-- Create webhooks table
+-- secret is kept in plain text because it is needed to sign every delivery
+CREATE TABLE IF NOT EXISTS webhooks (
+    id            UUID      PRIMARY KEY,
+    user_id       UUID      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    url           TEXT      NOT NULL,
+    secret        TEXT      NOT NULL,
+    event_types   TEXT[]    NOT NULL,
+    is_active     BOOLEAN   NOT NULL DEFAULT true,
+    created_at    TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at    TIMESTAMP NOT NULL DEFAULT now()
+);
+
+-- Create index for listing the webhooks of a user
+CREATE INDEX idx_webhooks_user_id ON webhooks(user_id);
+
+-- Trigger for webhooks table
+CREATE TRIGGER set_timestamp_webhooks
+BEFORE UPDATE ON webhooks
+FOR EACH ROW
+EXECUTE FUNCTION update_timestamp();
+
+-- Create webhook_deliveries table
+-- Each row is one event to be delivered to one webhook, and doubles as its delivery log
+-- Deliveries are retried with backoff while pending, and become dead after the last attempt fails
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id               UUID      PRIMARY KEY,
+    webhook_id       UUID      NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event_id         UUID      NOT NULL,
+    event_type       TEXT      NOT NULL,
+    payload          JSONB     NOT NULL,
+    status           TEXT      NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'succeeded', 'dead')),
+    attempts         INTEGER   NOT NULL DEFAULT 0,
+    next_attempt_at  TIMESTAMP NOT NULL DEFAULT now(),
+    last_status_code INTEGER,
+    last_error       TEXT,
+    created_at       TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at       TIMESTAMP NOT NULL DEFAULT now(),
+    UNIQUE (webhook_id, event_id)
+);
+
+-- Create indexes for the worker to find due deliveries and for the delivery log
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_webhook_deliveries_webhook_id ON webhook_deliveries(webhook_id, created_at DESC);
+
+-- Trigger for webhook_deliveries table
+CREATE TRIGGER set_timestamp_webhook_deliveries
+BEFORE UPDATE ON webhook_deliveries
+FOR EACH ROW
+EXECUTE FUNCTION update_timestamp();
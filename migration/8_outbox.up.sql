@@ -0,0 +1,26 @@
+-- Create outbox table
+-- Domain events are written in the same transaction as the change they describe, and published
+-- afterwards by the outbox relay, so an event is never lost nor published for a rolled back change
+-- id doubles as the idempotency key consumers use to discard events delivered more than once
+CREATE TABLE IF NOT EXISTS outbox (
+    id               UUID        PRIMARY KEY,
+    sequence         BIGSERIAL   NOT NULL UNIQUE,
+    aggregate_type   TEXT        NOT NULL,
+    aggregate_id     UUID        NOT NULL,
+    event_type       TEXT        NOT NULL,
+    payload          JSONB       NOT NULL,
+    attempts         INTEGER     NOT NULL DEFAULT 0,
+    next_attempt_at  TIMESTAMP   NOT NULL DEFAULT now(),
+    last_error       TEXT,
+    created_at       TIMESTAMP   NOT NULL DEFAULT now(),
+    published_at     TIMESTAMP
+);
+
+-- Create index for the relay to find unpublished events in order
+CREATE INDEX idx_outbox_unpublished ON outbox(sequence) WHERE published_at IS NULL;
+
+-- Create index for finding earlier unpublished events of the same aggregate
+CREATE INDEX idx_outbox_aggregate_unpublished ON outbox(aggregate_id, sequence) WHERE published_at IS NULL;
+
+-- Create index for pruning published events
+CREATE INDEX idx_outbox_published_at ON outbox(published_at) WHERE published_at IS NOT NULL;
@@ -0,0 +1,21 @@
+-- Add the users who can see an event to the outbox, so events can be streamed to their clients
+-- It is captured when the event is written, as the shares of a deleted todo are gone afterwards
+ALTER TABLE outbox ADD COLUMN audience UUID[] NOT NULL DEFAULT '{}';
+
+-- Create index for replaying the events of a user
+CREATE INDEX idx_outbox_audience ON outbox USING GIN (audience);
+
+-- Notify listeners of every instance when an event is committed
+-- The payload is the sequence of the event, which listeners use to load it
+CREATE OR REPLACE FUNCTION notify_outbox_event()
+RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify('outbox_events', NEW.sequence::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER notify_outbox_event
+AFTER INSERT ON outbox
+FOR EACH ROW
+EXECUTE FUNCTION notify_outbox_event();
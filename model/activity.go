@@ -0,0 +1,119 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx/types"
+)
+
+// ActivityEntity is the kind of entity an activity event describes
+type ActivityEntity string
+
+// Entities recorded in the activity log
+const (
+	ActivityEntityTodo       ActivityEntity = "todo"
+	ActivityEntityShare      ActivityEntity = "share"
+	ActivityEntityComment    ActivityEntity = "comment"
+	ActivityEntityAttachment ActivityEntity = "attachment"
+	ActivityEntityUser       ActivityEntity = "user"
+)
+
+// ActivityAction is the change an activity event records
+type ActivityAction string
+
+// Actions recorded in the activity log
+const (
+	ActivityActionCreated         ActivityAction = "created"
+	ActivityActionUpdated         ActivityAction = "updated"
+	ActivityActionDeleted         ActivityAction = "deleted"
+	ActivityActionAssigned        ActivityAction = "assigned"
+	ActivityActionPasswordChanged ActivityAction = "password_changed"
+)
+
+// ActivityEvent represents an entry in the append-only activity log
+// Before and After hold only the fields that changed, and are null when the entity did not exist
+type ActivityEvent struct {
+	ID         uuid.UUID          `db:"id"`
+	ActorID    uuid.UUID          `db:"actor_id"`
+	ActorEmail *string            `db:"actor_email"`
+	EntityType ActivityEntity     `db:"entity_type"`
+	EntityID   uuid.UUID          `db:"entity_id"`
+	TodoID     *uuid.UUID         `db:"todo_id"`
+	Action     ActivityAction     `db:"action"`
+	Before     types.NullJSONText `db:"before"`
+	After      types.NullJSONText `db:"after"`
+	RequestID  string             `db:"request_id"`
+	IPAddress  string             `db:"ip_address"`
+	CreatedAt  time.Time          `db:"created_at"`
+}
+
+// ActivityCursor identifies the position of an event in an activity feed
+type ActivityCursor struct {
+	CreatedAt time.Time `json:"c"`
+	ID        uuid.UUID `json:"i"`
+}
+
+// ActivityEventResponse represents the response for an activity event
+type ActivityEventResponse struct {
+	ID         string          `json:"id"`
+	Actor      *UserResponse   `json:"actor"`
+	EntityType ActivityEntity  `json:"entityType"`
+	EntityID   string          `json:"entityId"`
+	TodoID     *string         `json:"todoId"`
+	Action     ActivityAction  `json:"action"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	RequestID  string          `json:"requestId"`
+	IPAddress  string          `json:"ipAddress"`
+	CreatedAt  time.Time       `json:"createdAt"`
+}
+
+// ActivityListResponse represents a page of activity events
+type ActivityListResponse struct {
+	Events     []ActivityEventResponse `json:"events"`
+	NextCursor *string                 `json:"nextCursor"`
+}
+
+// NewActivityEventResponse creates a new ActivityEventResponse from an ActivityEvent model
+func NewActivityEventResponse(event *ActivityEvent) ActivityEventResponse {
+	var todoID *string
+	if event.TodoID != nil {
+		id := event.TodoID.String()
+		todoID = &id
+	}
+	return ActivityEventResponse{
+		ID:         event.ID.String(),
+		Actor:      newUserSummary(&event.ActorID, event.ActorEmail),
+		EntityType: event.EntityType,
+		EntityID:   event.EntityID.String(),
+		TodoID:     todoID,
+		Action:     event.Action,
+		Before:     nullJSON(event.Before),
+		After:      nullJSON(event.After),
+		RequestID:  event.RequestID,
+		IPAddress:  event.IPAddress,
+		CreatedAt:  event.CreatedAt,
+	}
+}
+
+// NewActivityListResponse creates a new ActivityListResponse from a page of ActivityEvent models
+func NewActivityListResponse(events []ActivityEvent, nextCursor *string) ActivityListResponse {
+	eventResponses := make([]ActivityEventResponse, len(events))
+	for i, event := range events {
+		eventResponses[i] = NewActivityEventResponse(&event)
+	}
+	return ActivityListResponse{
+		Events:     eventResponses,
+		NextCursor: nextCursor,
+	}
+}
+
+// nullJSON converts a nullable JSON column into a raw message that encodes as null when invalid
+func nullJSON(text types.NullJSONText) json.RawMessage {
+	if !text.Valid {
+		return json.RawMessage("null")
+	}
+	return json.RawMessage(text.JSONText)
+}
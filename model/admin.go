@@ -0,0 +1,100 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserFilter selects the users an admin lists
+// Query matches part of the email address or display name, ignoring case, and empty fields match every user
+type UserFilter struct {
+	Query    string
+	Role     string
+	Disabled *bool
+}
+
+// UserCursor identifies the position of a user in the list of users
+type UserCursor struct {
+	CreatedAt time.Time `json:"c"`
+	ID        uuid.UUID `json:"i"`
+}
+
+// TodoStats represents counts of the todos of a user
+// Overdue todos are open todos whose due date has passed, and assigned todos are the todos of anyone
+// assigned to the user
+type TodoStats struct {
+	Total     int `db:"total"`
+	Completed int `db:"completed"`
+	Overdue   int `db:"overdue"`
+	Assigned  int `db:"assigned"`
+}
+
+// AdminUserResponse represents the response for a user account seen by an admin
+type AdminUserResponse struct {
+	ID            string      `json:"id"`
+	Email         string      `json:"email"`
+	EmailVerified bool        `json:"emailVerified"`
+	HasPassword   bool        `json:"hasPassword"`
+	ResetRequired bool        `json:"passwordResetRequired"`
+	Role          string      `json:"role"`
+	Profile       UserProfile `json:"profile"`
+	DisabledAt    *time.Time  `json:"disabledAt"`
+	CreatedAt     time.Time   `json:"createdAt"`
+	UpdatedAt     time.Time   `json:"updatedAt"`
+}
+
+// AdminUserListResponse represents the response for a page of user accounts
+// NextCursor is nil when there are no more users
+type AdminUserListResponse struct {
+	Users      []AdminUserResponse `json:"users"`
+	NextCursor *string             `json:"nextCursor"`
+}
+
+// TodoStatsResponse represents the response for the todo statistics of a user
+type TodoStatsResponse struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Open      int `json:"open"`
+	Overdue   int `json:"overdue"`
+	Assigned  int `json:"assigned"`
+}
+
+// NewAdminUserResponse creates a new AdminUserResponse from a User model
+func NewAdminUserResponse(user *User) AdminUserResponse {
+	return AdminUserResponse{
+		ID:            user.ID.String(),
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified(),
+		HasPassword:   user.HasPassword(),
+		ResetRequired: user.PasswordResetRequired(),
+		Role:          user.Role,
+		Profile:       user.UserProfile,
+		DisabledAt:    user.DisabledAt,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+	}
+}
+
+// NewAdminUserListResponse creates a new AdminUserListResponse from a page of User models
+func NewAdminUserListResponse(users []User, nextCursor *string) AdminUserListResponse {
+	userResponses := make([]AdminUserResponse, len(users))
+	for i, user := range users {
+		userResponses[i] = NewAdminUserResponse(&user)
+	}
+	return AdminUserListResponse{
+		Users:      userResponses,
+		NextCursor: nextCursor,
+	}
+}
+
+// NewTodoStatsResponse creates a new TodoStatsResponse from TodoStats
+func NewTodoStatsResponse(stats *TodoStats) TodoStatsResponse {
+	return TodoStatsResponse{
+		Total:     stats.Total,
+		Completed: stats.Completed,
+		Open:      stats.Total - stats.Completed,
+		Overdue:   stats.Overdue,
+		Assigned:  stats.Assigned,
+	}
+}
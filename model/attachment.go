@@ -0,0 +1,59 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Attachment represents the metadata of a file attached to a todo item
+type Attachment struct {
+	ID          uuid.UUID `db:"id"`
+	TodoID      uuid.UUID `db:"todo_id"`
+	UploaderID  uuid.UUID `db:"uploader_id"`
+	FileName    string    `db:"file_name"`
+	ContentType string    `db:"content_type"`
+	Size        int64     `db:"size"`
+	StorageKey  string    `db:"storage_key"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+// AttachmentResponse represents the response for an attachment
+type AttachmentResponse struct {
+	ID          string    `json:"id"`
+	TodoID      string    `json:"todoId"`
+	UploaderID  string    `json:"uploaderId"`
+	FileName    string    `json:"fileName"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// AttachmentListResponse represents the response for a list of attachments
+type AttachmentListResponse struct {
+	Attachments []AttachmentResponse `json:"attachments"`
+}
+
+// NewAttachmentResponse creates a new AttachmentResponse from an Attachment model
+func NewAttachmentResponse(attachment *Attachment) AttachmentResponse {
+	return AttachmentResponse{
+		ID:          attachment.ID.String(),
+		TodoID:      attachment.TodoID.String(),
+		UploaderID:  attachment.UploaderID.String(),
+		FileName:    attachment.FileName,
+		ContentType: attachment.ContentType,
+		Size:        attachment.Size,
+		CreatedAt:   attachment.CreatedAt,
+	}
+}
+
+// NewAttachmentListResponse creates a new AttachmentListResponse from a slice of Attachment models
+func NewAttachmentListResponse(attachments []Attachment) AttachmentListResponse {
+	attachmentResponses := make([]AttachmentResponse, len(attachments))
+	for i, attachment := range attachments {
+		attachmentResponses[i] = NewAttachmentResponse(&attachment)
+	}
+	return AttachmentListResponse{
+		Attachments: attachmentResponses,
+	}
+}
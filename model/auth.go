@@ -1,9 +1,12 @@
 package model
 
+import "github.com/google/uuid"
+
 // SignUpRequest represents the request body for user registration
+// The password is checked against the password policy rather than here
 type SignUpRequest struct {
 	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=6"`
+	Password string `json:"password" validate:"required"`
 }
 
 // LoginRequest represents the request body for user login
@@ -17,8 +20,84 @@ type RefreshTokenRequest struct {
 	RefreshToken string `json:"refresh_token" validate:"required"`
 }
 
+// VerifyEmailRequest represents the request body for email verification
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// ResendVerificationRequest represents the request body for resending the verification email
+type ResendVerificationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ForgotPasswordRequest represents the request body for requesting a password reset email
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ResetPasswordRequest represents the request body for resetting a password
+// The password is checked against the password policy rather than here
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+// UpdateProfileRequest represents the request body for updating the profile of the user
+type UpdateProfileRequest struct {
+	DisplayName string `json:"displayName" validate:"max=100"`
+	Timezone    string `json:"timezone" validate:"required,timezone"`
+	Locale      string `json:"locale" validate:"required,bcp47_language_tag"`
+}
+
+// ChangePasswordRequest represents the request body for changing the password of the user
+// The new password is checked against the password policy rather than here
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword" validate:"required"`
+	NewPassword     string `json:"newPassword" validate:"required"`
+}
+
+// ChangeEmailRequest represents the request body for changing the email address of the user
+// Password is not needed by users without a password, who have to have logged in recently instead
+type ChangeEmailRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password"`
+}
+
+// DeleteAccountRequest represents the request body for deleting the account of the user
+// Password is not needed by users without a password, who have to have logged in recently instead
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
 // UserResponse represents the response for user data
+// It is also embedded in other responses as a summary of the referenced user
+// EmailVerified and Profile are only set for the user's own account
 type UserResponse struct {
-	ID    string `json:"id"`
-	Email string `json:"email"`
+	ID            string       `json:"id"`
+	Email         string       `json:"email"`
+	EmailVerified *bool        `json:"emailVerified,omitempty"`
+	Profile       *UserProfile `json:"profile,omitempty"`
+}
+
+// NewUserResponse creates a new UserResponse from a User model
+func NewUserResponse(user *User) UserResponse {
+	verified := user.EmailVerified()
+	profile := user.UserProfile
+	return UserResponse{
+		ID:            user.ID.String(),
+		Email:         user.Email,
+		EmailVerified: &verified,
+		Profile:       &profile,
+	}
+}
+
+// newUserSummary creates an embedded user summary from a nullable user reference
+func newUserSummary(id *uuid.UUID, email *string) *UserResponse {
+	if id == nil || email == nil {
+		return nil
+	}
+	return &UserResponse{
+		ID:    id.String(),
+		Email: *email,
+	}
 }
@@ -0,0 +1,81 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Comment represents a comment on a todo item
+type Comment struct {
+	ID          uuid.UUID  `db:"id"`
+	TodoID      uuid.UUID  `db:"todo_id"`
+	AuthorID    uuid.UUID  `db:"author_id"`
+	AuthorEmail string     `db:"author_email"`
+	Body        string     `db:"body"`
+	BodyHTML    string     `db:"body_html"`
+	CreatedAt   time.Time  `db:"created_at"`
+	UpdatedAt   time.Time  `db:"updated_at"`
+	DeletedAt   *time.Time `db:"deleted_at"`
+}
+
+// CommentCursor identifies the position of a comment in a todo's comment thread
+type CommentCursor struct {
+	CreatedAt time.Time `json:"c"`
+	ID        uuid.UUID `json:"i"`
+}
+
+// CreateCommentRequest represents the request to create a new comment
+type CreateCommentRequest struct {
+	Body string `json:"body" validate:"required,max=10000"`
+}
+
+// UpdateCommentRequest represents the request to edit a comment
+type UpdateCommentRequest struct {
+	Body string `json:"body" validate:"required,max=10000"`
+}
+
+// CommentResponse represents the response for a comment
+type CommentResponse struct {
+	ID        string       `json:"id"`
+	TodoID    string       `json:"todoId"`
+	Author    UserResponse `json:"author"`
+	Body      string       `json:"body"`
+	BodyHTML  string       `json:"bodyHtml"`
+	CreatedAt time.Time    `json:"createdAt"`
+	UpdatedAt time.Time    `json:"updatedAt"`
+}
+
+// CommentListResponse represents a page of comments
+type CommentListResponse struct {
+	Comments   []CommentResponse `json:"comments"`
+	NextCursor *string           `json:"nextCursor"`
+}
+
+// NewCommentResponse creates a new CommentResponse from a Comment model
+func NewCommentResponse(comment *Comment) CommentResponse {
+	return CommentResponse{
+		ID:     comment.ID.String(),
+		TodoID: comment.TodoID.String(),
+		Author: UserResponse{
+			ID:    comment.AuthorID.String(),
+			Email: comment.AuthorEmail,
+		},
+		Body:      comment.Body,
+		BodyHTML:  comment.BodyHTML,
+		CreatedAt: comment.CreatedAt,
+		UpdatedAt: comment.UpdatedAt,
+	}
+}
+
+// NewCommentListResponse creates a new CommentListResponse from a page of Comment models
+func NewCommentListResponse(comments []Comment, nextCursor *string) CommentListResponse {
+	commentResponses := make([]CommentResponse, len(comments))
+	for i, comment := range comments {
+		commentResponses[i] = NewCommentResponse(&comment)
+	}
+	return CommentListResponse{
+		Comments:   commentResponses,
+		NextCursor: nextCursor,
+	}
+}
@@ -6,8 +6,17 @@ import (
 )
 
 // ErrorResponse represents a standardized error response format
+// Details are only set on validation errors that break down into several reasons
 type ErrorResponse struct {
-	Code    string `json:"code"`
+	Code    string        `json:"code"`
+	Message string        `json:"message"`
+	Details []ErrorDetail `json:"details,omitempty"`
+}
+
+// ErrorDetail represents a reason a field of a request was refused
+type ErrorDetail struct {
+	Field   string `json:"field"`
+	Reason  string `json:"reason"`
 	Message string `json:"message"`
 }
 
@@ -27,26 +36,108 @@ func formatErrorCode(statusCode int, number int) string {
 // Auth error constants
 var (
 	// 400 Bad Request errors
-	InvalidRequestBodyResponse  = NewErrorResponse(http.StatusBadRequest, 1, "Invalid request body")
-	ValidationFailedResponse    = NewErrorResponse(http.StatusBadRequest, 2, "Validation failed")
-	InvalidTodoIDFormatResponse = NewErrorResponse(http.StatusBadRequest, 10, "Invalid todo ID format")
+	InvalidRequestBodyResponse         = NewErrorResponse(http.StatusBadRequest, 1, "Invalid request body")
+	ValidationFailedResponse           = NewErrorResponse(http.StatusBadRequest, 2, "Validation failed")
+	InvalidTodoIDFormatResponse        = NewErrorResponse(http.StatusBadRequest, 10, "Invalid todo ID format")
+	InvalidUserIDParamResponse         = NewErrorResponse(http.StatusBadRequest, 11, "Invalid user ID format")
+	CannotShareWithOwnerResponse       = NewErrorResponse(http.StatusBadRequest, 12, "Cannot share a todo with its owner")
+	InvalidAssignedToFilterResponse    = NewErrorResponse(http.StatusBadRequest, 13, "Invalid assignedTo filter, only 'me' is supported")
+	AssigneeNoAccessResponse           = NewErrorResponse(http.StatusBadRequest, 14, "Assignee does not have access to this todo")
+	InvalidCommentIDFormatResponse     = NewErrorResponse(http.StatusBadRequest, 15, "Invalid comment ID format")
+	InvalidPaginationResponse          = NewErrorResponse(http.StatusBadRequest, 16, "Invalid cursor or limit")
+	MissingAttachmentFileResponse      = NewErrorResponse(http.StatusBadRequest, 17, "A non-empty file is required")
+	InvalidAttachmentIDResponse        = NewErrorResponse(http.StatusBadRequest, 18, "Invalid attachment ID format")
+	InvalidWebhookIDFormatResponse     = NewErrorResponse(http.StatusBadRequest, 19, "Invalid webhook ID format")
+	InvalidDeliveryIDFormatResponse    = NewErrorResponse(http.StatusBadRequest, 20, "Invalid delivery ID format")
+	InvalidLastEventIDResponse         = NewErrorResponse(http.StatusBadRequest, 21, "Invalid Last-Event-ID")
+	InvalidSyncTokenResponse           = NewErrorResponse(http.StatusBadRequest, 22, "Invalid sync token")
+	InvalidIdempotencyKeyResponse      = NewErrorResponse(http.StatusBadRequest, 23, "Invalid Idempotency-Key")
+	DescriptionTooLongResponse         = NewErrorResponse(http.StatusBadRequest, 24, "Description exceeds the maximum length")
+	InvalidVerificationTokenResponse   = NewErrorResponse(http.StatusBadRequest, 25, "Invalid or expired verification token")
+	InvalidPasswordResetTokenResponse  = NewErrorResponse(http.StatusBadRequest, 26, "Invalid or expired password reset token")
+	MFANotEnrolledResponse             = NewErrorResponse(http.StatusBadRequest, 27, "MFA enrolment has not been started")
+	InvalidPasskeyChallengeResponse    = NewErrorResponse(http.StatusBadRequest, 28, "Invalid or expired passkey challenge")
+	InvalidPasskeyRegistrationResponse = NewErrorResponse(http.StatusBadRequest, 29, "Invalid passkey registration")
+	InvalidPasskeyIDFormatResponse     = NewErrorResponse(http.StatusBadRequest, 30, "Invalid passkey ID format")
+	InvalidOIDCStateResponse           = NewErrorResponse(http.StatusBadRequest, 31, "Invalid or expired OIDC state")
+	InvalidIdentityIDFormatResponse    = NewErrorResponse(http.StatusBadRequest, 32, "Invalid identity ID format")
+	InvalidOAuthClientIDFormatResponse = NewErrorResponse(http.StatusBadRequest, 33, "Invalid OAuth client ID format")
+	InvalidRedirectURIResponse         = NewErrorResponse(http.StatusBadRequest, 34, "Redirect URI is not registered for the client")
+	InvalidOAuthScopeResponse          = NewErrorResponse(http.StatusBadRequest, 35, "Invalid or unauthorized scope")
+	InvalidTokenIDFormatResponse       = NewErrorResponse(http.StatusBadRequest, 36, "Invalid personal access token ID format")
+	InvalidSessionIDResponse           = NewErrorResponse(http.StatusBadRequest, 37, "Invalid session ID format")
+	InvalidUserFilterResponse          = NewErrorResponse(http.StatusBadRequest, 38, "Invalid role or status filter")
+	CannotDisableSelfResponse          = NewErrorResponse(http.StatusBadRequest, 39, "Cannot disable your own account")
+	WebhookURLNotAllowedResponse       = NewErrorResponse(http.StatusBadRequest, 40, "Webhook URL must resolve to a public address")
 
 	// 401 Unauthorized errors
-	InvalidCredentialsResponse      = NewErrorResponse(http.StatusUnauthorized, 1, "Invalid email or password")
-	MissingAuthHeaderResponse       = NewErrorResponse(http.StatusUnauthorized, 2, "Missing authorization header")
-	InvalidAuthHeaderFormatResponse = NewErrorResponse(http.StatusUnauthorized, 3, "Invalid authorization header format")
-	TokenExpiredResponse            = NewErrorResponse(http.StatusUnauthorized, 4, "Token expired")
-	InvalidTokenResponse            = NewErrorResponse(http.StatusUnauthorized, 5, "Invalid token")
-	InvalidTokenTypeResponse        = NewErrorResponse(http.StatusUnauthorized, 6, "Invalid token type")
+	InvalidCredentialsResponse          = NewErrorResponse(http.StatusUnauthorized, 1, "Invalid email or password")
+	MissingAuthHeaderResponse           = NewErrorResponse(http.StatusUnauthorized, 2, "Missing authorization header")
+	InvalidAuthHeaderFormatResponse     = NewErrorResponse(http.StatusUnauthorized, 3, "Invalid authorization header format")
+	TokenExpiredResponse                = NewErrorResponse(http.StatusUnauthorized, 4, "Token expired")
+	InvalidTokenResponse                = NewErrorResponse(http.StatusUnauthorized, 5, "Invalid token")
+	InvalidTokenTypeResponse            = NewErrorResponse(http.StatusUnauthorized, 6, "Invalid token type")
+	InvalidMFACodeResponse              = NewErrorResponse(http.StatusUnauthorized, 7, "Invalid MFA code")
+	PasskeyAuthenticationFailedResponse = NewErrorResponse(http.StatusUnauthorized, 8, "Passkey authentication failed")
+	OIDCAuthenticationFailedResponse    = NewErrorResponse(http.StatusUnauthorized, 9, "OIDC authentication failed")
+	InvalidStreamTicketResponse         = NewErrorResponse(http.StatusUnauthorized, 10, "Invalid or expired stream ticket")
 
 	// 403 Forbidden errors
 	NoPermissionToAccessTodoResponse = NewErrorResponse(http.StatusForbidden, 1, "You don't have permission to access this todo")
+	NotCommentAuthorResponse         = NewErrorResponse(http.StatusForbidden, 2, "You can only modify your own comments")
+	TodoQuotaExceededResponse        = NewErrorResponse(http.StatusForbidden, 3, "Todo quota exceeded")
+	EmailNotVerifiedResponse         = NewErrorResponse(http.StatusForbidden, 4, "Email address is not verified")
+	InvalidCurrentPasswordResponse   = NewErrorResponse(http.StatusForbidden, 5, "Current password is incorrect")
+	IncorrectMFACodeResponse         = NewErrorResponse(http.StatusForbidden, 6, "MFA code is incorrect")
+	OIDCEmailNotVerifiedResponse     = NewErrorResponse(http.StatusForbidden, 7, "Provider did not report a verified email address")
+	InsufficientScopeResponse        = NewErrorResponse(http.StatusForbidden, 8, "Token does not grant the required scope")
+	PersonalAccessTokenLimitResponse = NewErrorResponse(http.StatusForbidden, 9, "Personal access token limit reached")
+	AccountDisabledResponse          = NewErrorResponse(http.StatusForbidden, 10, "Account is disabled")
+	InsufficientRoleResponse         = NewErrorResponse(http.StatusForbidden, 11, "You don't have the role required for this request")
+	ReauthenticationRequiredResponse = NewErrorResponse(http.StatusForbidden, 12, "Log in again to confirm this change")
+	PasswordResetRequiredResponse    = NewErrorResponse(http.StatusForbidden, 13, "Reset your password to make this change")
 
 	// 404 Not Found errors
-	TodoNotFoundResponse = NewErrorResponse(http.StatusNotFound, 1, "Todo not found")
+	TodoNotFoundResponse         = NewErrorResponse(http.StatusNotFound, 1, "Todo not found")
+	UserNotFoundResponse         = NewErrorResponse(http.StatusNotFound, 2, "User not found")
+	ShareNotFoundResponse        = NewErrorResponse(http.StatusNotFound, 3, "Share not found")
+	CommentNotFoundResponse      = NewErrorResponse(http.StatusNotFound, 4, "Comment not found")
+	AttachmentNotFoundResponse   = NewErrorResponse(http.StatusNotFound, 5, "Attachment not found")
+	WebhookNotFoundResponse      = NewErrorResponse(http.StatusNotFound, 6, "Webhook not found")
+	DeliveryNotFoundResponse     = NewErrorResponse(http.StatusNotFound, 7, "Webhook delivery not found")
+	PasskeyNotFoundResponse      = NewErrorResponse(http.StatusNotFound, 8, "Passkey not found")
+	OIDCProviderNotFoundResponse = NewErrorResponse(http.StatusNotFound, 9, "OIDC provider not found")
+	IdentityNotFoundResponse     = NewErrorResponse(http.StatusNotFound, 10, "Identity not found")
+	OAuthClientNotFoundResponse  = NewErrorResponse(http.StatusNotFound, 11, "OAuth client not found")
+	OAuthConsentNotFoundResponse = NewErrorResponse(http.StatusNotFound, 12, "OAuth consent not found")
+	TokenNotFoundResponse        = NewErrorResponse(http.StatusNotFound, 13, "Personal access token not found")
+	SessionNotFoundResponse      = NewErrorResponse(http.StatusNotFound, 14, "Session not found")
 
 	// 409 Conflict errors
-	EmailAlreadyExistsResponse = NewErrorResponse(http.StatusConflict, 1, "Email already exists")
+	EmailAlreadyExistsResponse       = NewErrorResponse(http.StatusConflict, 1, "Email already exists")
+	IdempotencyKeyInProgressResponse = NewErrorResponse(http.StatusConflict, 2, "A request with this Idempotency-Key is still being processed")
+	TodoIDConflictResponse           = NewErrorResponse(http.StatusConflict, 3, "Todo ID is already in use by a different todo")
+	MFAAlreadyEnabledResponse        = NewErrorResponse(http.StatusConflict, 4, "MFA is already enabled")
+	MFANotEnabledResponse            = NewErrorResponse(http.StatusConflict, 5, "MFA is not enabled")
+	PasskeyAlreadyRegisteredResponse = NewErrorResponse(http.StatusConflict, 6, "Passkey is already registered")
+	OIDCAccountExistsResponse        = NewErrorResponse(http.StatusConflict, 7, "An account with this email already exists, log in to link the provider")
+	IdentityAlreadyLinkedResponse    = NewErrorResponse(http.StatusConflict, 8, "External account is already linked")
+	LastLoginMethodResponse          = NewErrorResponse(http.StatusConflict, 9, "Cannot unlink the only way to log in")
+
+	// 413 Payload Too Large errors
+	AttachmentTooLargeResponse      = NewErrorResponse(http.StatusRequestEntityTooLarge, 1, "File exceeds the maximum attachment size")
+	AttachmentQuotaExceededResponse = NewErrorResponse(http.StatusRequestEntityTooLarge, 2, "Attachment storage quota exceeded")
+
+	// 415 Unsupported Media Type errors
+	UnsupportedAttachmentTypeResponse = NewErrorResponse(http.StatusUnsupportedMediaType, 1, "File type is not allowed")
+
+	// 422 Unprocessable Entity errors
+	IdempotencyKeyReusedResponse = NewErrorResponse(http.StatusUnprocessableEntity, 1, "Idempotency-Key was already used with a different request")
+
+	// 429 Too Many Requests errors
+	TooManyLoginAttemptsResponse = NewErrorResponse(http.StatusTooManyRequests, 1, "Too many login attempts, please retry later")
+	AccountLockedResponse        = NewErrorResponse(http.StatusTooManyRequests, 2, "Account temporarily locked after repeated failed logins")
+	RateLimitExceededResponse    = NewErrorResponse(http.StatusTooManyRequests, 3, "Rate limit exceeded")
 
 	// 500 Internal Server Error errors
 	FailedToCreateUserResponse    = NewErrorResponse(http.StatusInternalServerError, 1, "Failed to create user")
@@ -55,3 +146,21 @@ var (
 	InvalidUserIDFormatResponse   = NewErrorResponse(http.StatusInternalServerError, 4, "Invalid user ID format")
 	FailedToOperateResponse       = NewErrorResponse(http.StatusInternalServerError, 10, "Failed to operate")
 )
+
+// OAuthErrorResponse represents an error response of the OAuth token, introspection and revocation endpoints
+// It has the format of RFC 6749 section 5.2 that OAuth clients expect, rather than the format of ErrorResponse
+type OAuthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// OAuth error constants
+var (
+	OAuthInvalidRequestResponse       = &OAuthErrorResponse{Error: "invalid_request", ErrorDescription: "The request is missing a required parameter"}
+	OAuthInvalidClientResponse        = &OAuthErrorResponse{Error: "invalid_client", ErrorDescription: "Client authentication failed"}
+	OAuthInvalidGrantResponse         = &OAuthErrorResponse{Error: "invalid_grant", ErrorDescription: "The grant is invalid, expired, revoked or was issued to another client"}
+	OAuthUnauthorizedClientResponse   = &OAuthErrorResponse{Error: "unauthorized_client", ErrorDescription: "The client cannot use this grant type"}
+	OAuthUnsupportedGrantTypeResponse = &OAuthErrorResponse{Error: "unsupported_grant_type", ErrorDescription: "The grant type is not supported"}
+	OAuthInvalidScopeResponse         = &OAuthErrorResponse{Error: "invalid_scope", ErrorDescription: "The scope is invalid or exceeds what was granted"}
+	OAuthServerErrorResponse          = &OAuthErrorResponse{Error: "server_error", ErrorDescription: "The request could not be processed"}
+)
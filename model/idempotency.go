@@ -0,0 +1,25 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IdempotencyRecord represents a request made with an Idempotency-Key and the response to it
+// The response fields are nil while the request is still being processed
+type IdempotencyRecord struct {
+	UserID              uuid.UUID `db:"user_id"`
+	Key                 string    `db:"idempotency_key"`
+	Fingerprint         string    `db:"fingerprint"`
+	ResponseStatus      *int      `db:"response_status"`
+	ResponseContentType *string   `db:"response_content_type"`
+	ResponseBody        []byte    `db:"response_body"`
+	CreatedAt           time.Time `db:"created_at"`
+	ExpiresAt           time.Time `db:"expires_at"`
+}
+
+// Completed reports whether the response to the request has been stored
+func (r *IdempotencyRecord) Completed() bool {
+	return r.ResponseStatus != nil
+}
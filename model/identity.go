@@ -0,0 +1,50 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserIdentity represents an account of an external OpenID Connect provider linked to a user
+// Subject is the stable ID the provider gives the account, and Email the address it reported at the last login
+type UserIdentity struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	UserID      uuid.UUID  `json:"-" db:"user_id"`
+	Provider    string     `json:"provider" db:"provider"`
+	Subject     string     `json:"-" db:"subject"`
+	Email       string     `json:"email" db:"email"`
+	CreatedAt   time.Time  `json:"createdAt" db:"created_at"`
+	LastLoginAt *time.Time `json:"lastLoginAt" db:"last_login_at"`
+}
+
+// OIDCState represents a single-use state of an authorization request to an OpenID Connect provider
+// Only the SHA-256 hash of the state is stored, and UserID is set when linking a provider to a logged in user
+type OIDCState struct {
+	StateHash    []byte     `db:"state_hash"`
+	Provider     string     `db:"provider"`
+	CodeVerifier string     `db:"code_verifier"`
+	Nonce        string     `db:"nonce"`
+	UserID       *uuid.UUID `db:"user_id"`
+	CreatedAt    time.Time  `db:"created_at"`
+	ExpiresAt    time.Time  `db:"expires_at"`
+}
+
+// OIDCProvider represents an OpenID Connect provider users can log in with
+type OIDCProvider struct {
+	Name string `json:"name"`
+}
+
+// OIDCAuthorizationResponse represents the response for starting a login or link with a provider
+// The client redirects the browser to AuthorizationURL, and checks that the provider redirects back with State
+type OIDCAuthorizationResponse struct {
+	AuthorizationURL string `json:"authorizationUrl"`
+	State            string `json:"state"`
+}
+
+// OIDCCallbackRequest represents the request body for completing a login or link with a provider
+// Code and State are the query parameters the provider redirected the browser back with
+type OIDCCallbackRequest struct {
+	Code  string `json:"code" validate:"required"`
+	State string `json:"state" validate:"required"`
+}
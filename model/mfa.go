@@ -0,0 +1,51 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserMFA represents the TOTP authenticator of a user
+// Secret is encrypted, and the authenticator is pending until EnabledAt is set
+type UserMFA struct {
+	UserID       uuid.UUID  `db:"user_id"`
+	Secret       []byte     `db:"secret"`
+	EnabledAt    *time.Time `db:"enabled_at"`
+	LastUsedStep int64      `db:"last_used_step"`
+	CreatedAt    time.Time  `db:"created_at"`
+}
+
+// Enabled reports whether the authenticator has been confirmed
+func (m *UserMFA) Enabled() bool {
+	return m.EnabledAt != nil
+}
+
+// MFACodeRequest represents the request body for confirming an action with an MFA code
+// Code is a TOTP code or, where accepted, a recovery code
+type MFACodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// VerifyMFARequest represents the request body for completing a login with an MFA code
+type VerifyMFARequest struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code" validate:"required"`
+}
+
+// MFAEnrollmentResponse represents the response for starting MFA enrolment
+type MFAEnrollmentResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauthUri"`
+}
+
+// MFARecoveryCodesResponse represents the response for newly generated recovery codes
+type MFARecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// MFAStatus represents whether a user has MFA enabled and how many recovery codes they have left
+type MFAStatus struct {
+	Enabled                bool `json:"enabled"`
+	RemainingRecoveryCodes int  `json:"remainingRecoveryCodes"`
+}
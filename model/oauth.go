@@ -0,0 +1,214 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// OAuth scopes third-party clients can be granted
+const (
+	// ScopeTodosRead allows reading todos and the resources around them
+	ScopeTodosRead = "todos:read"
+
+	// ScopeTodosWrite allows creating, changing and deleting todos and the resources around them
+	ScopeTodosWrite = "todos:write"
+)
+
+// OAuthScopes are all the scopes, in the order they are listed in
+var OAuthScopes = []string{ScopeTodosRead, ScopeTodosWrite}
+
+// OAuthTokenKind is the kind of a token issued to an OAuth client
+type OAuthTokenKind string
+
+const (
+	// OAuthAccessToken is a short-lived token the client accesses the API with
+	OAuthAccessToken OAuthTokenKind = "access"
+
+	// OAuthRefreshToken is a long-lived token the client obtains new access tokens with
+	OAuthRefreshToken OAuthTokenKind = "refresh"
+)
+
+// OAuthClient represents a third-party application a user registered to access the API on behalf of users
+// Only the SHA-256 hash of the secret is stored, and public clients that cannot keep a secret have none
+type OAuthClient struct {
+	ID           uuid.UUID      `db:"id"`
+	UserID       uuid.UUID      `db:"user_id"`
+	Name         string         `db:"name"`
+	SecretHash   []byte         `db:"secret_hash"`
+	RedirectURIs pq.StringArray `db:"redirect_uris"`
+	Scopes       pq.StringArray `db:"scopes"`
+	CreatedAt    time.Time      `db:"created_at"`
+}
+
+// Confidential reports whether the client authenticates with a secret
+func (c *OAuthClient) Confidential() bool {
+	return c.SecretHash != nil
+}
+
+// OAuthConsent records the scopes a user granted a client
+type OAuthConsent struct {
+	UserID    uuid.UUID      `db:"user_id"`
+	ClientID  uuid.UUID      `db:"client_id"`
+	Scopes    pq.StringArray `db:"scopes"`
+	CreatedAt time.Time      `db:"created_at"`
+	UpdatedAt time.Time      `db:"updated_at"`
+
+	// ClientName is joined from the oauth_clients table when the consents of a user are listed
+	ClientName string `db:"client_name"`
+}
+
+// OAuthAuthorizationCode represents an authorization code issued to a client
+// Only the SHA-256 hash of the code is stored, and CodeChallenge is the PKCE challenge (S256) it is redeemed with
+type OAuthAuthorizationCode struct {
+	CodeHash      []byte         `db:"code_hash"`
+	GrantID       uuid.UUID      `db:"grant_id"`
+	ClientID      uuid.UUID      `db:"client_id"`
+	UserID        uuid.UUID      `db:"user_id"`
+	RedirectURI   string         `db:"redirect_uri"`
+	Scopes        pq.StringArray `db:"scopes"`
+	CodeChallenge string         `db:"code_challenge"`
+	CreatedAt     time.Time      `db:"created_at"`
+	ExpiresAt     time.Time      `db:"expires_at"`
+	UsedAt        *time.Time     `db:"used_at"`
+}
+
+// OAuthToken represents an access or refresh token issued to a client
+// Only the SHA-256 hash of the token is stored, and tokens issued for the same authorization share the GrantID
+type OAuthToken struct {
+	TokenHash      []byte         `db:"token_hash"`
+	Kind           OAuthTokenKind `db:"kind"`
+	GrantID        uuid.UUID      `db:"grant_id"`
+	ClientID       uuid.UUID      `db:"client_id"`
+	UserID         uuid.UUID      `db:"user_id"`
+	Scopes         pq.StringArray `db:"scopes"`
+	SessionVersion int            `db:"session_version"`
+	CreatedAt      time.Time      `db:"created_at"`
+	ExpiresAt      time.Time      `db:"expires_at"`
+}
+
+// CreateOAuthClientRequest represents the request to register a third-party client
+// A public client, such as a native or browser application, cannot keep a secret and is not given one
+type CreateOAuthClientRequest struct {
+	Name         string   `json:"name" validate:"required,max=100"`
+	RedirectURIs []string `json:"redirectUris" validate:"required,min=1,max=10,dive,url,max=2048"`
+	Scopes       []string `json:"scopes" validate:"required,min=1,dive,oneof=todos:read todos:write"`
+	Public       bool     `json:"public"`
+}
+
+// OAuthClientResponse represents the response for a registered client
+// The secret is only included in the response to its registration
+type OAuthClientResponse struct {
+	ClientID     string    `json:"clientId"`
+	ClientSecret string    `json:"clientSecret,omitempty"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirectUris"`
+	Scopes       []string  `json:"scopes"`
+	Public       bool      `json:"public"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// NewOAuthClientResponse creates a new OAuthClientResponse from an OAuthClient model without its secret
+func NewOAuthClientResponse(client *OAuthClient) OAuthClientResponse {
+	return OAuthClientResponse{
+		ClientID:     client.ID.String(),
+		Name:         client.Name,
+		RedirectURIs: client.RedirectURIs,
+		Scopes:       client.Scopes,
+		Public:       !client.Confidential(),
+		CreatedAt:    client.CreatedAt,
+	}
+}
+
+// NewOAuthClientListResponse creates a slice of OAuthClientResponse from a slice of OAuthClient models
+func NewOAuthClientListResponse(clients []OAuthClient) []OAuthClientResponse {
+	clientResponses := make([]OAuthClientResponse, len(clients))
+	for i, client := range clients {
+		clientResponses[i] = NewOAuthClientResponse(&client)
+	}
+	return clientResponses
+}
+
+// OAuthConsentResponse represents the response for the scopes a user granted a client
+type OAuthConsentResponse struct {
+	ClientID   string    `json:"clientId"`
+	ClientName string    `json:"clientName"`
+	Scopes     []string  `json:"scopes"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// NewOAuthConsentListResponse creates a slice of OAuthConsentResponse from a slice of OAuthConsent models
+func NewOAuthConsentListResponse(consents []OAuthConsent) []OAuthConsentResponse {
+	consentResponses := make([]OAuthConsentResponse, len(consents))
+	for i, consent := range consents {
+		consentResponses[i] = OAuthConsentResponse{
+			ClientID:   consent.ClientID.String(),
+			ClientName: consent.ClientName,
+			Scopes:     consent.Scopes,
+			CreatedAt:  consent.CreatedAt,
+			UpdatedAt:  consent.UpdatedAt,
+		}
+	}
+	return consentResponses
+}
+
+// OAuthAuthorizeRequest represents an authorization request of a client (RFC 6749 section 4.1.1)
+// The application the user is logged in to passes on the query parameters the client redirected the browser with
+// PKCE (RFC 7636) with the S256 method is required of every client
+type OAuthAuthorizeRequest struct {
+	ResponseType        string `json:"response_type" query:"response_type" validate:"required,oneof=code"`
+	ClientID            string `json:"client_id" query:"client_id" validate:"required"`
+	RedirectURI         string `json:"redirect_uri" query:"redirect_uri" validate:"required"`
+	Scope               string `json:"scope" query:"scope"`
+	State               string `json:"state" query:"state"`
+	CodeChallenge       string `json:"code_challenge" query:"code_challenge" validate:"required,min=43,max=128"`
+	CodeChallengeMethod string `json:"code_challenge_method" query:"code_challenge_method" validate:"required,oneof=S256"`
+}
+
+// OAuthAuthorizationResponse represents what a client asks the user to grant, for the consent screen
+// Consented is true when the user already granted the client all the scopes
+type OAuthAuthorizationResponse struct {
+	ClientID   string   `json:"clientId"`
+	ClientName string   `json:"clientName"`
+	Scopes     []string `json:"scopes"`
+	Consented  bool     `json:"consented"`
+}
+
+// OAuthRedirectResponse represents the response for an approved authorization request
+// The application redirects the browser to RedirectURL, which carries the authorization code and state to the client
+type OAuthRedirectResponse struct {
+	RedirectURL string `json:"redirectUrl"`
+}
+
+// OAuthTokenRequest represents a request to the token endpoint (RFC 6749 section 3.2)
+type OAuthTokenRequest struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	RefreshToken string `form:"refresh_token"`
+	Scope        string `form:"scope"`
+}
+
+// OAuthTokenResponse represents a successful response of the token endpoint (RFC 6749 section 5.1)
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope"`
+}
+
+// OAuthIntrospectionResponse represents a response of the introspection endpoint (RFC 7662 section 2.2)
+// Only Active is set for a token that is not active
+type OAuthIntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+}
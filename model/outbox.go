@@ -0,0 +1,60 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx/types"
+	"github.com/lib/pq"
+)
+
+// Aggregate types of domain events
+const (
+	AggregateTodo = "todo"
+)
+
+// Domain event types written to the outbox
+const (
+	DomainEventTodoCreated   = "todo.created"
+	DomainEventTodoUpdated   = "todo.updated"
+	DomainEventTodoCompleted = "todo.completed"
+	DomainEventTodoAssigned  = "todo.assigned"
+	DomainEventTodoDeleted   = "todo.deleted"
+)
+
+// OutboxEvent represents a domain event waiting in the outbox to be published
+type OutboxEvent struct {
+	ID            uuid.UUID      `db:"id"`
+	Sequence      int64          `db:"sequence"`
+	AggregateType string         `db:"aggregate_type"`
+	AggregateID   uuid.UUID      `db:"aggregate_id"`
+	EventType     string         `db:"event_type"`
+	Payload       types.JSONText `db:"payload"`
+	Attempts      int            `db:"attempts"`
+	NextAttemptAt time.Time      `db:"next_attempt_at"`
+	LastError     *string        `db:"last_error"`
+	CreatedAt     time.Time      `db:"created_at"`
+	PublishedAt   *time.Time     `db:"published_at"`
+
+	// Audience is the IDs of the users who can see the event, who receive it on the real-time stream
+	Audience pq.StringArray `db:"audience"`
+}
+
+// IdempotencyKey returns the key consumers use to discard an event delivered more than once
+func (e *OutboxEvent) IdempotencyKey() string {
+	return e.ID.String()
+}
+
+// TodoEventData is the payload of todo domain events, the todo as returned by the API plus its owner
+type TodoEventData struct {
+	TodoResponse
+	UserID string `json:"userId"`
+}
+
+// NewTodoEventData creates a new TodoEventData from a Todo model
+func NewTodoEventData(todo *Todo) TodoEventData {
+	return TodoEventData{
+		TodoResponse: NewTodoResponse(todo),
+		UserID:       todo.UserID.String(),
+	}
+}
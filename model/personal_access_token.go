@@ -0,0 +1,68 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// PersonalAccessToken represents a long-lived token a user created for scripts and CLIs
+// Only the SHA-256 hash of the token is stored, and a token without ExpiresAt does not expire
+type PersonalAccessToken struct {
+	ID             uuid.UUID      `db:"id"`
+	UserID         uuid.UUID      `db:"user_id"`
+	Name           string         `db:"name"`
+	TokenHash      []byte         `db:"token_hash"`
+	Scopes         pq.StringArray `db:"scopes"`
+	SessionVersion int            `db:"session_version"`
+	CreatedAt      time.Time      `db:"created_at"`
+	ExpiresAt      *time.Time     `db:"expires_at"`
+	LastUsedAt     *time.Time     `db:"last_used_at"`
+}
+
+// Expired reports whether the token has expired at the time
+func (t *PersonalAccessToken) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && !t.ExpiresAt.After(now)
+}
+
+// CreatePersonalAccessTokenRequest represents the request to create a personal access token
+// The token does not expire when ExpiresInDays is omitted
+type CreatePersonalAccessTokenRequest struct {
+	Name          string   `json:"name" validate:"required,max=100"`
+	Scopes        []string `json:"scopes" validate:"required,min=1,dive,oneof=todos:read todos:write"`
+	ExpiresInDays *int     `json:"expiresInDays" validate:"omitempty,min=1,max=365"`
+}
+
+// PersonalAccessTokenResponse represents the response for a personal access token
+// The token itself is only included in the response to its creation
+type PersonalAccessTokenResponse struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Token      string     `json:"token,omitempty"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+}
+
+// NewPersonalAccessTokenResponse creates a new PersonalAccessTokenResponse from a PersonalAccessToken model without the token
+func NewPersonalAccessTokenResponse(token *PersonalAccessToken) PersonalAccessTokenResponse {
+	return PersonalAccessTokenResponse{
+		ID:         token.ID.String(),
+		Name:       token.Name,
+		Scopes:     token.Scopes,
+		CreatedAt:  token.CreatedAt,
+		ExpiresAt:  token.ExpiresAt,
+		LastUsedAt: token.LastUsedAt,
+	}
+}
+
+// NewPersonalAccessTokenListResponse creates a slice of PersonalAccessTokenResponse from a slice of PersonalAccessToken models
+func NewPersonalAccessTokenListResponse(tokens []PersonalAccessToken) []PersonalAccessTokenResponse {
+	tokenResponses := make([]PersonalAccessTokenResponse, len(tokens))
+	for i, token := range tokens {
+		tokenResponses[i] = NewPersonalAccessTokenResponse(&token)
+	}
+	return tokenResponses
+}
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"time"
+)
+
+// RateLimit describes a token bucket holding up to Burst tokens, refilled at Burst tokens per Period
+type RateLimit struct {
+	Burst  int
+	Period time.Duration
+}
+
+// RefillRate returns the number of tokens added to the bucket per second
+func (l RateLimit) RefillRate() float64 {
+	return float64(l.Burst) / l.Period.Seconds()
+}
+
+// RateLimitResult is the outcome of taking a token from a bucket
+type RateLimitResult struct {
+	// Allowed reports whether a token was taken
+	Allowed bool
+
+	// Limit is the number of tokens the bucket holds when full
+	Limit int
+
+	// Remaining is the number of whole tokens left in the bucket
+	Remaining int
+
+	// RetryAfter is the time until a token is available when none was taken
+	RetryAfter time.Duration
+
+	// ResetAfter is the time until the bucket is full again
+	ResetAfter time.Duration
+}
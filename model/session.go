@@ -0,0 +1,55 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session represents a login of a user on a device, kept alive by refreshing its token pair
+// Only the SHA-256 hash of the latest refresh token of the session is stored
+type Session struct {
+	ID               uuid.UUID `db:"id"`
+	UserID           uuid.UUID `db:"user_id"`
+	RefreshTokenHash []byte    `db:"refresh_token_hash"`
+	SessionVersion   int       `db:"session_version"`
+	UserAgent        string    `db:"user_agent"`
+	IPAddress        string    `db:"ip_address"`
+	CreatedAt        time.Time `db:"created_at"`
+	LastUsedAt       time.Time `db:"last_used_at"`
+	ExpiresAt        time.Time `db:"expires_at"`
+}
+
+// SessionResponse represents the response for a session
+// Current is true for the session the request was made with
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"userAgent"`
+	IPAddress  string    `json:"ipAddress"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	Current    bool      `json:"current"`
+}
+
+// NewSessionResponse creates a new SessionResponse from a Session model
+func NewSessionResponse(session *Session, currentID string) SessionResponse {
+	return SessionResponse{
+		ID:         session.ID.String(),
+		UserAgent:  session.UserAgent,
+		IPAddress:  session.IPAddress,
+		CreatedAt:  session.CreatedAt,
+		LastUsedAt: session.LastUsedAt,
+		ExpiresAt:  session.ExpiresAt,
+		Current:    session.ID.String() == currentID,
+	}
+}
+
+// NewSessionListResponse creates a slice of SessionResponse from a slice of Session models
+func NewSessionListResponse(sessions []Session, currentID string) []SessionResponse {
+	sessionResponses := make([]SessionResponse, len(sessions))
+	for i, session := range sessions {
+		sessionResponses[i] = NewSessionResponse(&session, currentID)
+	}
+	return sessionResponses
+}
@@ -0,0 +1,92 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ShareRole represents the role a user has on a todo
+type ShareRole string
+
+const (
+	// ShareRoleOwner is the role of the user who created the todo
+	ShareRoleOwner ShareRole = "owner"
+
+	// ShareRoleEditor can read and update the todo
+	ShareRoleEditor ShareRole = "editor"
+
+	// ShareRoleViewer can only read the todo
+	ShareRoleViewer ShareRole = "viewer"
+)
+
+// rank returns the privilege level of the role, higher is more privileged
+func (r ShareRole) rank() int {
+	switch r {
+	case ShareRoleOwner:
+		return 3
+	case ShareRoleEditor:
+		return 2
+	case ShareRoleViewer:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Includes reports whether the role grants at least the permissions of the required role
+func (r ShareRole) Includes(required ShareRole) bool {
+	return r.rank() > 0 && r.rank() >= required.rank()
+}
+
+// TodoShare represents a todo shared with another user
+type TodoShare struct {
+	TodoID    uuid.UUID `db:"todo_id"`
+	UserID    uuid.UUID `db:"user_id"`
+	Email     string    `db:"email"`
+	Role      ShareRole `db:"role"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+// ShareTodoRequest represents the request to share a todo with another user
+type ShareTodoRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role" validate:"required,oneof=editor viewer"`
+}
+
+// TodoShareResponse represents the response for a todo share
+type TodoShareResponse struct {
+	UserID    string    `json:"userId"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// TodoShareListResponse represents the response for a list of todo shares
+type TodoShareListResponse struct {
+	Shares []TodoShareResponse `json:"shares"`
+}
+
+// NewTodoShareResponse creates a new TodoShareResponse from a TodoShare model
+func NewTodoShareResponse(share *TodoShare) TodoShareResponse {
+	return TodoShareResponse{
+		UserID:    share.UserID.String(),
+		Email:     share.Email,
+		Role:      string(share.Role),
+		CreatedAt: share.CreatedAt,
+		UpdatedAt: share.UpdatedAt,
+	}
+}
+
+// NewTodoShareListResponse creates a new TodoShareListResponse from a slice of TodoShare models
+func NewTodoShareListResponse(shares []TodoShare) TodoShareListResponse {
+	shareResponses := make([]TodoShareResponse, len(shares))
+	for i, share := range shares {
+		shareResponses[i] = NewTodoShareResponse(&share)
+	}
+	return TodoShareListResponse{
+		Shares: shareResponses,
+	}
+}
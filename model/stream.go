@@ -0,0 +1,52 @@
+package model
+
+import (
+	"encoding/json"
+)
+
+// Todo stream event types
+const (
+	StreamEventTodoCreated = "todo.created"
+	StreamEventTodoUpdated = "todo.updated"
+	StreamEventTodoDeleted = "todo.deleted"
+
+	// StreamEventReset tells the client that more events were missed than can be replayed,
+	// and it should reload its todos
+	StreamEventReset = "reset"
+
+	// StreamEventHeartbeat keeps idle WebSocket connections alive
+	StreamEventHeartbeat = "heartbeat"
+)
+
+// TodoStreamEvent represents an event pushed to real-time clients
+// ID is the sequence of the event, which clients send back as Last-Event-ID to resume
+type TodoStreamEvent struct {
+	ID   int64           `json:"id,string,omitempty"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// StreamTicketResponse represents a ticket that opens a real-time stream
+// ExpiresIn is the number of seconds the ticket can be used within
+type StreamTicketResponse struct {
+	Ticket    string `json:"ticket"`
+	ExpiresIn int    `json:"expiresIn"`
+}
+
+// NewTodoStreamEvent creates a new TodoStreamEvent from an outbox event
+// Completions and assignments are streamed as updates, since clients only need the new state of the todo
+func NewTodoStreamEvent(event *OutboxEvent) TodoStreamEvent {
+	eventType := StreamEventTodoUpdated
+	switch event.EventType {
+	case DomainEventTodoCreated:
+		eventType = StreamEventTodoCreated
+	case DomainEventTodoDeleted:
+		eventType = StreamEventTodoDeleted
+	}
+
+	return TodoStreamEvent{
+		ID:   event.Sequence,
+		Type: eventType,
+		Data: json.RawMessage(event.Payload),
+	}
+}
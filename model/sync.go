@@ -0,0 +1,121 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Sync mutation operations
+const (
+	SyncOpUpsert = "upsert"
+	SyncOpDelete = "delete"
+)
+
+// Sync mutation result statuses
+const (
+	// SyncStatusApplied means the mutation was written, or a deleted todo was already gone
+	SyncStatusApplied = "applied"
+
+	// SyncStatusConflict means the todo changed on the server since the version the client based its mutation on
+	SyncStatusConflict = "conflict"
+
+	// SyncStatusRejected means the mutation is not allowed or could not be written
+	SyncStatusRejected = "rejected"
+)
+
+// SyncTodo represents a todo changed since a sync, together with the sequence of its latest change for the user
+// The sequence is that of the todo itself, or of the share that gave the user access if that is newer
+type SyncTodo struct {
+	Todo
+	SyncSeq int64 `db:"sync_seq"`
+}
+
+// TodoTombstone records that a todo was removed for a user, because it was deleted or no longer shared with them
+type TodoTombstone struct {
+	TodoID    uuid.UUID `db:"todo_id"`
+	UserID    uuid.UUID `db:"user_id"`
+	ChangeSeq int64     `db:"change_seq"`
+	DeletedAt time.Time `db:"deleted_at"`
+}
+
+// SyncToken identifies the last change a client has received
+type SyncToken struct {
+	Seq int64 `json:"s"`
+}
+
+// SyncChanges represents a page of changes since a sync token
+type SyncChanges struct {
+	Todos     []SyncTodo
+	Deleted   []TodoTombstone
+	NextToken string
+	HasMore   bool
+}
+
+// SyncMutation represents a change made by a client while offline
+// BaseVersion is the version of the todo the change was made to, or 0 for a todo created by the client
+type SyncMutation struct {
+	ID          string             `json:"id" validate:"required,uuid"`
+	Op          string             `json:"op" validate:"required,oneof=upsert delete"`
+	BaseVersion int64              `json:"baseVersion" validate:"min=0"`
+	Todo        *UpdateTodoRequest `json:"todo" validate:"required_if=Op upsert"`
+}
+
+// SyncPushRequest represents a batch of client mutations, applied in order
+type SyncPushRequest struct {
+	Mutations []SyncMutation `json:"mutations" validate:"required,max=100,dive"`
+}
+
+// SyncMutationResult represents the outcome of a single mutation
+// Todo is the state of the todo on the server after the mutation, or nil if it does not exist
+type SyncMutationResult struct {
+	ID     string            `json:"id"`
+	Status string            `json:"status"`
+	Todo   *SyncTodoResponse `json:"todo"`
+	Error  *ErrorResponse    `json:"error,omitempty"`
+}
+
+// SyncTodoResponse represents a todo in sync responses, with the version clients base their mutations on
+type SyncTodoResponse struct {
+	TodoResponse
+	Version int64 `json:"version"`
+}
+
+// SyncChangesResponse represents the response for a page of changes
+type SyncChangesResponse struct {
+	Todos     []SyncTodoResponse `json:"todos"`
+	Deleted   []string           `json:"deleted"`
+	NextToken string             `json:"nextToken"`
+	HasMore   bool               `json:"hasMore"`
+}
+
+// SyncPushResponse represents the response for a batch of client mutations, in the order they were sent
+type SyncPushResponse struct {
+	Results []SyncMutationResult `json:"results"`
+}
+
+// NewSyncTodoResponse creates a new SyncTodoResponse from a Todo model
+func NewSyncTodoResponse(todo *Todo) SyncTodoResponse {
+	return SyncTodoResponse{
+		TodoResponse: NewTodoResponse(todo),
+		Version:      todo.Version,
+	}
+}
+
+// NewSyncChangesResponse creates a new SyncChangesResponse from a page of changes
+func NewSyncChangesResponse(changes *SyncChanges) SyncChangesResponse {
+	todos := make([]SyncTodoResponse, len(changes.Todos))
+	for i, todo := range changes.Todos {
+		todos[i] = NewSyncTodoResponse(&todo.Todo)
+	}
+	deleted := make([]string, len(changes.Deleted))
+	for i, tombstone := range changes.Deleted {
+		deleted[i] = tombstone.TodoID.String()
+	}
+	return SyncChangesResponse{
+		Todos:     todos,
+		Deleted:   deleted,
+		NextToken: changes.NextToken,
+		HasMore:   changes.HasMore,
+	}
+}
@@ -14,12 +14,31 @@ type Todo struct {
 	Description *string    `db:"description"`
 	DueDate     *time.Time `db:"due_date"`
 	IsCompleted bool       `db:"is_completed"`
+	AssigneeID  *uuid.UUID `db:"assignee_id"`
+	Version     int64      `db:"version"`
+	ChangeSeq   int64      `db:"change_seq"`
 	CreatedAt   time.Time  `db:"created_at"`
 	UpdatedAt   time.Time  `db:"updated_at"`
+
+	// AssigneeEmail is joined from the users table and is nil when the todo is unassigned
+	AssigneeEmail *string `db:"assignee_email"`
+}
+
+// TodoAssignment represents an entry in the assignment history of a todo
+type TodoAssignment struct {
+	ID              uuid.UUID  `db:"id"`
+	TodoID          uuid.UUID  `db:"todo_id"`
+	AssigneeID      *uuid.UUID `db:"assignee_id"`
+	AssigneeEmail   *string    `db:"assignee_email"`
+	AssignedBy      *uuid.UUID `db:"assigned_by"`
+	AssignedByEmail *string    `db:"assigned_by_email"`
+	CreatedAt       time.Time  `db:"created_at"`
 }
 
 // CreateTodoRequest represents the request to create a new todo
+// ID is optional and lets offline clients create todos with their own UUIDs
 type CreateTodoRequest struct {
+	ID          *string    `json:"id" validate:"omitempty,uuid"`
 	Title       string     `json:"title" validate:"required"`
 	Description *string    `json:"description"`
 	DueDate     *time.Time `json:"dueDate"`
@@ -33,15 +52,22 @@ type UpdateTodoRequest struct {
 	IsCompleted bool       `json:"isCompleted"`
 }
 
+// AssignTodoRequest represents the request to assign a todo to a user
+// A null assigneeId unassigns the todo
+type AssignTodoRequest struct {
+	AssigneeID *string `json:"assigneeId" validate:"omitempty,uuid"`
+}
+
 // TodoResponse represents the response for a todo item
 type TodoResponse struct {
-	ID          string     `json:"id"`
-	Title       string     `json:"title"`
-	Description *string    `json:"description"`
-	DueDate     *time.Time `json:"dueDate"`
-	IsCompleted bool       `json:"isCompleted"`
-	CreatedAt   time.Time  `json:"createdAt"`
-	UpdatedAt   time.Time  `json:"updatedAt"`
+	ID          string        `json:"id"`
+	Title       string        `json:"title"`
+	Description *string       `json:"description"`
+	DueDate     *time.Time    `json:"dueDate"`
+	IsCompleted bool          `json:"isCompleted"`
+	Assignee    *UserResponse `json:"assignee"`
+	CreatedAt   time.Time     `json:"createdAt"`
+	UpdatedAt   time.Time     `json:"updatedAt"`
 }
 
 // TodoListResponse represents the response for a list of todo items
@@ -57,6 +83,7 @@ func NewTodoResponse(todo *Todo) TodoResponse {
 		Description: todo.Description,
 		DueDate:     todo.DueDate,
 		IsCompleted: todo.IsCompleted,
+		Assignee:    newUserSummary(todo.AssigneeID, todo.AssigneeEmail),
 		CreatedAt:   todo.CreatedAt,
 		UpdatedAt:   todo.UpdatedAt,
 	}
@@ -72,3 +99,30 @@ func NewTodoListResponse(todos []Todo) TodoListResponse {
 		Todos: todoResponses,
 	}
 }
+
+// TodoAssignmentResponse represents the response for an assignment history entry
+type TodoAssignmentResponse struct {
+	Assignee   *UserResponse `json:"assignee"`
+	AssignedBy *UserResponse `json:"assignedBy"`
+	CreatedAt  time.Time     `json:"createdAt"`
+}
+
+// TodoAssignmentListResponse represents the response for the assignment history of a todo
+type TodoAssignmentListResponse struct {
+	Assignments []TodoAssignmentResponse `json:"assignments"`
+}
+
+// NewTodoAssignmentListResponse creates a new TodoAssignmentListResponse from a slice of TodoAssignment models
+func NewTodoAssignmentListResponse(assignments []TodoAssignment) TodoAssignmentListResponse {
+	assignmentResponses := make([]TodoAssignmentResponse, len(assignments))
+	for i, assignment := range assignments {
+		assignmentResponses[i] = TodoAssignmentResponse{
+			Assignee:   newUserSummary(assignment.AssigneeID, assignment.AssigneeEmail),
+			AssignedBy: newUserSummary(assignment.AssignedBy, assignment.AssignedByEmail),
+			CreatedAt:  assignment.CreatedAt,
+		}
+	}
+	return TodoAssignmentListResponse{
+		Assignments: assignmentResponses,
+	}
+}
@@ -6,11 +6,74 @@ import (
 	"github.com/google/uuid"
 )
 
+// Profile defaults of new users
+const (
+	DefaultTimezone = "UTC"
+	DefaultLocale   = "en"
+)
+
+// Roles of users
+const (
+	// RoleUser is the role of every user
+	RoleUser = "user"
+
+	// RoleAdmin is the role of operators, who can manage other users through the admin API
+	RoleAdmin = "admin"
+)
+
+// PasswordResetRequiredHash is stored as the password hash of a user whose password an admin removed,
+// until they reset it
+// It matches no password, but unlike an empty hash it does not make the user look like one who never had a password
+const PasswordResetRequiredHash = "!password-reset-required"
+
 // User represents a user in the system
 type User struct {
-	ID           uuid.UUID `db:"id"`
-	Email        string    `db:"email"`
-	PasswordHash string    `db:"password_hash"`
-	CreatedAt    time.Time `db:"created_at"`
-	UpdatedAt    time.Time `db:"updated_at"`
+	ID              uuid.UUID  `db:"id"`
+	Email           string     `db:"email"`
+	PasswordHash    string     `db:"password_hash"`
+	EmailVerifiedAt *time.Time `db:"email_verified_at"`
+	SessionVersion  int        `db:"session_version"`
+	Role            string     `db:"role"`
+	DisabledAt      *time.Time `db:"disabled_at"`
+	UserProfile
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+// UserProfile represents the profile a user shows to others and their preferences
+type UserProfile struct {
+	DisplayName string `json:"displayName" db:"display_name"`
+	Timezone    string `json:"timezone" db:"timezone"`
+	Locale      string `json:"locale" db:"locale"`
+}
+
+// EmailVerified reports whether the user has verified their email address
+func (u *User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// Roles returns the roles of the user
+// Admins also have the user role
+func (u *User) Roles() []string {
+	if u.Role == RoleAdmin {
+		return []string{RoleUser, RoleAdmin}
+	}
+	return []string{RoleUser}
+}
+
+// Disabled reports whether an admin disabled the account of the user, who then cannot log in
+func (u *User) Disabled() bool {
+	return u.DisabledAt != nil
+}
+
+// HasPassword reports whether the user can log in with a password
+// Users who signed up with an external provider have none until they reset it,
+// while users whose password an admin removed still have one, which they have to reset first
+func (u *User) HasPassword() bool {
+	return u.PasswordHash != ""
+}
+
+// PasswordResetRequired reports whether an admin removed the password of the user, who has to reset it
+func (u *User) PasswordResetRequired() bool {
+	return u.PasswordHash == PasswordResetRequiredHash
 }
@@ -0,0 +1,33 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserTokenPurpose is what a single-use user token can be used for
+type UserTokenPurpose string
+
+const (
+	// UserTokenEmailVerification verifies the email address of a user
+	UserTokenEmailVerification UserTokenPurpose = "email_verification"
+
+	// UserTokenPasswordReset resets the password of a user
+	UserTokenPasswordReset UserTokenPurpose = "password_reset"
+
+	// UserTokenStreamTicket opens a real-time stream, for clients that cannot set the Authorization header
+	UserTokenStreamTicket UserTokenPurpose = "stream_ticket"
+)
+
+// UserToken represents a single-use token sent to a user
+// Only the SHA-256 hash of the token is stored
+type UserToken struct {
+	ID        uuid.UUID        `db:"id"`
+	UserID    uuid.UUID        `db:"user_id"`
+	Purpose   UserTokenPurpose `db:"purpose"`
+	TokenHash []byte           `db:"token_hash"`
+	CreatedAt time.Time        `db:"created_at"`
+	ExpiresAt time.Time        `db:"expires_at"`
+	UsedAt    *time.Time       `db:"used_at"`
+}
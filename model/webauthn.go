@@ -0,0 +1,158 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebAuthnCeremony is the WebAuthn ceremony a challenge was issued for
+type WebAuthnCeremony string
+
+const (
+	// WebAuthnRegistration registers a new passkey for a user
+	WebAuthnRegistration WebAuthnCeremony = "registration"
+
+	// WebAuthnLogin logs a user in with a passkey
+	WebAuthnLogin WebAuthnCeremony = "login"
+)
+
+// WebAuthnCredential represents a passkey registered by a user
+// PublicKey is the COSE key of the credential, and SignCount the signature counter last reported by the authenticator
+type WebAuthnCredential struct {
+	ID           uuid.UUID  `json:"id" db:"id"`
+	UserID       uuid.UUID  `json:"-" db:"user_id"`
+	CredentialID []byte     `json:"-" db:"credential_id"`
+	PublicKey    []byte     `json:"-" db:"public_key"`
+	SignCount    int64      `json:"-" db:"sign_count"`
+	AAGUID       []byte     `json:"-" db:"aaguid"`
+	Name         string     `json:"name" db:"name"`
+	CreatedAt    time.Time  `json:"createdAt" db:"created_at"`
+	LastUsedAt   *time.Time `json:"lastUsedAt" db:"last_used_at"`
+}
+
+// WebAuthnChallenge represents a single-use challenge of a WebAuthn ceremony
+// Only the SHA-256 hash of the challenge is stored, and UserID is nil for logins
+type WebAuthnChallenge struct {
+	ChallengeHash []byte           `db:"challenge_hash"`
+	UserID        *uuid.UUID       `db:"user_id"`
+	Ceremony      WebAuthnCeremony `db:"ceremony"`
+	CreatedAt     time.Time        `db:"created_at"`
+	ExpiresAt     time.Time        `db:"expires_at"`
+}
+
+// Base64URL is binary data encoded in JSON as unpadded base64url, as in the WebAuthn JSON serialization
+type Base64URL []byte
+
+// MarshalJSON encodes the data as an unpadded base64url string
+func (b Base64URL) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+// UnmarshalJSON decodes a base64url string, with or without padding
+func (b *Base64URL) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+// RelyingParty identifies the server to authenticators
+type RelyingParty struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// PublicKeyCredentialUser identifies the user a passkey is registered for
+type PublicKeyCredentialUser struct {
+	ID          Base64URL `json:"id"`
+	Name        string    `json:"name"`
+	DisplayName string    `json:"displayName"`
+}
+
+// PublicKeyCredentialParameters is a signature algorithm accepted for new passkeys
+type PublicKeyCredentialParameters struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+// PublicKeyCredentialDescriptor identifies a registered passkey
+type PublicKeyCredentialDescriptor struct {
+	Type string    `json:"type"`
+	ID   Base64URL `json:"id"`
+}
+
+// AuthenticatorSelection states the authenticators a passkey can be registered with
+type AuthenticatorSelection struct {
+	ResidentKey        string `json:"residentKey"`
+	RequireResidentKey bool   `json:"requireResidentKey"`
+	UserVerification   string `json:"userVerification"`
+}
+
+// PublicKeyCredentialCreationOptions represents the options for navigator.credentials.create()
+type PublicKeyCredentialCreationOptions struct {
+	Challenge              Base64URL                       `json:"challenge"`
+	RP                     RelyingParty                    `json:"rp"`
+	User                   PublicKeyCredentialUser         `json:"user"`
+	PubKeyCredParams       []PublicKeyCredentialParameters `json:"pubKeyCredParams"`
+	Timeout                int64                           `json:"timeout"`
+	ExcludeCredentials     []PublicKeyCredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection          `json:"authenticatorSelection"`
+	Attestation            string                          `json:"attestation"`
+}
+
+// PublicKeyCredentialRequestOptions represents the options for navigator.credentials.get()
+// AllowCredentials is empty, so that the user picks any of their passkeys without entering an email
+type PublicKeyCredentialRequestOptions struct {
+	Challenge        Base64URL                       `json:"challenge"`
+	Timeout          int64                           `json:"timeout"`
+	RPID             string                          `json:"rpId"`
+	AllowCredentials []PublicKeyCredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                          `json:"userVerification"`
+}
+
+// AuthenticatorAttestationResponse represents the response of an authenticator to a registration
+type AuthenticatorAttestationResponse struct {
+	ClientDataJSON    Base64URL `json:"clientDataJSON" validate:"required"`
+	AttestationObject Base64URL `json:"attestationObject" validate:"required"`
+}
+
+// RegistrationCredential represents the result of navigator.credentials.create() in the WebAuthn JSON serialization
+type RegistrationCredential struct {
+	ID       string                           `json:"id" validate:"required"`
+	RawID    Base64URL                        `json:"rawId" validate:"required"`
+	Type     string                           `json:"type" validate:"eq=public-key"`
+	Response AuthenticatorAttestationResponse `json:"response"`
+}
+
+// AuthenticatorAssertionResponse represents the response of an authenticator to a login
+type AuthenticatorAssertionResponse struct {
+	ClientDataJSON    Base64URL `json:"clientDataJSON" validate:"required"`
+	AuthenticatorData Base64URL `json:"authenticatorData" validate:"required"`
+	Signature         Base64URL `json:"signature" validate:"required"`
+	UserHandle        Base64URL `json:"userHandle"`
+}
+
+// AssertionCredential represents the result of navigator.credentials.get() in the WebAuthn JSON serialization
+type AssertionCredential struct {
+	ID       string                         `json:"id" validate:"required"`
+	RawID    Base64URL                      `json:"rawId" validate:"required"`
+	Type     string                         `json:"type" validate:"eq=public-key"`
+	Response AuthenticatorAssertionResponse `json:"response"`
+}
+
+// RegisterPasskeyRequest represents the request body for completing a passkey registration
+type RegisterPasskeyRequest struct {
+	Name       string                 `json:"name" validate:"max=100"`
+	Credential RegistrationCredential `json:"credential"`
+}
@@ -0,0 +1,175 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx/types"
+	"github.com/lib/pq"
+)
+
+// Webhook event types
+const (
+	WebhookEventTodoCreated   = "todo.created"
+	WebhookEventTodoUpdated   = "todo.updated"
+	WebhookEventTodoCompleted = "todo.completed"
+	WebhookEventTodoDeleted   = "todo.deleted"
+)
+
+// WebhookDeliveryStatus represents the state of a webhook delivery
+type WebhookDeliveryStatus string
+
+const (
+	// WebhookDeliveryPending is waiting for its first or next attempt
+	WebhookDeliveryPending WebhookDeliveryStatus = "pending"
+
+	// WebhookDeliverySucceeded was accepted by the receiver
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded"
+
+	// WebhookDeliveryDead failed on every attempt and will not be retried unless redelivered
+	WebhookDeliveryDead WebhookDeliveryStatus = "dead"
+)
+
+// Webhook represents a user's subscription to todo events
+type Webhook struct {
+	ID         uuid.UUID      `db:"id"`
+	UserID     uuid.UUID      `db:"user_id"`
+	URL        string         `db:"url"`
+	Secret     string         `db:"secret"`
+	EventTypes pq.StringArray `db:"event_types"`
+	IsActive   bool           `db:"is_active"`
+	CreatedAt  time.Time      `db:"created_at"`
+	UpdatedAt  time.Time      `db:"updated_at"`
+}
+
+// WebhookDelivery represents one event to be delivered to one webhook
+type WebhookDelivery struct {
+	ID             uuid.UUID             `db:"id"`
+	WebhookID      uuid.UUID             `db:"webhook_id"`
+	EventID        uuid.UUID             `db:"event_id"`
+	EventType      string                `db:"event_type"`
+	Payload        types.JSONText        `db:"payload"`
+	Status         WebhookDeliveryStatus `db:"status"`
+	Attempts       int                   `db:"attempts"`
+	NextAttemptAt  time.Time             `db:"next_attempt_at"`
+	LastStatusCode *int                  `db:"last_status_code"`
+	LastError      *string               `db:"last_error"`
+	CreatedAt      time.Time             `db:"created_at"`
+	UpdatedAt      time.Time             `db:"updated_at"`
+
+	// URL and Secret are joined from the webhooks table when deliveries are claimed for sending
+	URL    string `db:"url"`
+	Secret string `db:"secret"`
+}
+
+// WebhookEvent is the JSON body sent to webhook receivers
+type WebhookEvent struct {
+	ID        string       `json:"id"`
+	Type      string       `json:"type"`
+	CreatedAt time.Time    `json:"createdAt"`
+	Data      TodoResponse `json:"data"`
+}
+
+// CreateWebhookRequest represents the request to subscribe to todo events
+type CreateWebhookRequest struct {
+	URL        string   `json:"url" validate:"required,http_url,max=2048"`
+	EventTypes []string `json:"eventTypes" validate:"required,min=1,dive,oneof=todo.created todo.updated todo.completed todo.deleted"`
+}
+
+// UpdateWebhookRequest represents the request to update a webhook subscription
+type UpdateWebhookRequest struct {
+	URL        string   `json:"url" validate:"required,http_url,max=2048"`
+	EventTypes []string `json:"eventTypes" validate:"required,min=1,dive,oneof=todo.created todo.updated todo.completed todo.deleted"`
+	IsActive   bool     `json:"isActive"`
+}
+
+// WebhookResponse represents the response for a webhook
+// The secret is only included in the response to its creation
+type WebhookResponse struct {
+	ID         string    `json:"id"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"secret,omitempty"`
+	EventTypes []string  `json:"eventTypes"`
+	IsActive   bool      `json:"isActive"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// WebhookListResponse represents the response for a list of webhooks
+type WebhookListResponse struct {
+	Webhooks []WebhookResponse `json:"webhooks"`
+}
+
+// WebhookDeliveryResponse represents the response for a webhook delivery log entry
+type WebhookDeliveryResponse struct {
+	ID             string                `json:"id"`
+	EventID        string                `json:"eventId"`
+	EventType      string                `json:"eventType"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	NextAttemptAt  *time.Time            `json:"nextAttemptAt"`
+	LastStatusCode *int                  `json:"lastStatusCode"`
+	LastError      *string               `json:"lastError"`
+	CreatedAt      time.Time             `json:"createdAt"`
+	UpdatedAt      time.Time             `json:"updatedAt"`
+}
+
+// WebhookDeliveryListResponse represents the response for the delivery log of a webhook
+type WebhookDeliveryListResponse struct {
+	Deliveries []WebhookDeliveryResponse `json:"deliveries"`
+}
+
+// NewWebhookResponse creates a new WebhookResponse from a Webhook model without its secret
+func NewWebhookResponse(webhook *Webhook) WebhookResponse {
+	return WebhookResponse{
+		ID:         webhook.ID.String(),
+		URL:        webhook.URL,
+		EventTypes: webhook.EventTypes,
+		IsActive:   webhook.IsActive,
+		CreatedAt:  webhook.CreatedAt,
+		UpdatedAt:  webhook.UpdatedAt,
+	}
+}
+
+// NewWebhookListResponse creates a new WebhookListResponse from a slice of Webhook models
+func NewWebhookListResponse(webhooks []Webhook) WebhookListResponse {
+	webhookResponses := make([]WebhookResponse, len(webhooks))
+	for i, webhook := range webhooks {
+		webhookResponses[i] = NewWebhookResponse(&webhook)
+	}
+	return WebhookListResponse{
+		Webhooks: webhookResponses,
+	}
+}
+
+// NewWebhookDeliveryResponse creates a new WebhookDeliveryResponse from a WebhookDelivery model
+func NewWebhookDeliveryResponse(delivery *WebhookDelivery) WebhookDeliveryResponse {
+	// Only pending deliveries have a next attempt
+	var nextAttemptAt *time.Time
+	if delivery.Status == WebhookDeliveryPending {
+		nextAttemptAt = &delivery.NextAttemptAt
+	}
+	return WebhookDeliveryResponse{
+		ID:             delivery.ID.String(),
+		EventID:        delivery.EventID.String(),
+		EventType:      delivery.EventType,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		NextAttemptAt:  nextAttemptAt,
+		LastStatusCode: delivery.LastStatusCode,
+		LastError:      delivery.LastError,
+		CreatedAt:      delivery.CreatedAt,
+		UpdatedAt:      delivery.UpdatedAt,
+	}
+}
+
+// NewWebhookDeliveryListResponse creates a new WebhookDeliveryListResponse from a slice of WebhookDelivery models
+func NewWebhookDeliveryListResponse(deliveries []WebhookDelivery) WebhookDeliveryListResponse {
+	deliveryResponses := make([]WebhookDeliveryResponse, len(deliveries))
+	for i, delivery := range deliveries {
+		deliveryResponses[i] = NewWebhookDeliveryResponse(&delivery)
+	}
+	return WebhookDeliveryListResponse{
+		Deliveries: deliveryResponses,
+	}
+}
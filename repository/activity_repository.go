@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/yukimaterrace/todoms/model"
+)
+
+// selectActivityEventQuery selects activity events together with the actor's email
+const selectActivityEventQuery = `
+	SELECT e.id, e.actor_id, u.email AS actor_email, e.entity_type, e.entity_id, e.todo_id,
+		e.action, e.before, e.after, e.request_id, e.ip_address, e.created_at
+	FROM activity_events e
+	LEFT JOIN users u ON u.id = e.actor_id
+`
+
+// ActivityRepository defines the interface for activity log operations
+// Events are append-only, so there is no update or delete
+type ActivityRepository interface {
+	Create(ctx context.Context, event *model.ActivityEvent) error
+	GetByTodoID(ctx context.Context, todoID uuid.UUID, before *model.ActivityCursor, limit int) ([]model.ActivityEvent, error)
+	GetFeedByUserID(ctx context.Context, userID uuid.UUID, before *model.ActivityCursor, limit int) ([]model.ActivityEvent, error)
+}
+
+// PostgresActivityRepository implements ActivityRepository interface for PostgreSQL
+type PostgresActivityRepository struct {
+	db *sqlx.DB
+}
+
+// NewActivityRepository creates a new PostgresActivityRepository instance
+func NewActivityRepository(db *sqlx.DB) ActivityRepository {
+	return &PostgresActivityRepository{db: db}
+}
+
+// Create appends an event to the activity log
+func (r *PostgresActivityRepository) Create(ctx context.Context, event *model.ActivityEvent) error {
+	if event.ID == uuid.Nil {
+		event.ID = uuid.New()
+	}
+
+	query := `
+		INSERT INTO activity_events (id, actor_id, entity_type, entity_id, todo_id, action, before, after, request_id, ip_address)
+		VALUES (:id, :actor_id, :entity_type, :entity_id, :todo_id, :action, :before, :after, :request_id, :ip_address)
+	`
+
+	_, err := executor(ctx, r.db).NamedExecContext(ctx, query, event)
+	return err
+}
+
+// GetByTodoID retrieves the events of a todo, newest first, starting before the cursor if given
+func (r *PostgresActivityRepository) GetByTodoID(ctx context.Context, todoID uuid.UUID, before *model.ActivityCursor, limit int) ([]model.ActivityEvent, error) {
+	query := selectActivityEventQuery + `WHERE e.todo_id = $1`
+	args := []interface{}{todoID, limit}
+	if before != nil {
+		query += ` AND (e.created_at, e.id) < ($3, $4)`
+		args = append(args, before.CreatedAt, before.ID)
+	}
+	query += ` ORDER BY e.created_at DESC, e.id DESC LIMIT $2`
+
+	var events []model.ActivityEvent
+	err := executor(ctx, r.db).SelectContext(ctx, &events, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// GetFeedByUserID retrieves the events the user performed or that happened on todos the user can access,
+// newest first, starting before the cursor if given
+func (r *PostgresActivityRepository) GetFeedByUserID(ctx context.Context, userID uuid.UUID, before *model.ActivityCursor, limit int) ([]model.ActivityEvent, error) {
+	query := selectActivityEventQuery + `
+		WHERE (e.actor_id = $1
+			OR e.todo_id IN (SELECT id FROM todos WHERE user_id = $1)
+			OR e.todo_id IN (SELECT todo_id FROM todo_shares WHERE user_id = $1))
+	`
+	args := []interface{}{userID, limit}
+	if before != nil {
+		query += ` AND (e.created_at, e.id) < ($3, $4)`
+		args = append(args, before.CreatedAt, before.ID)
+	}
+	query += ` ORDER BY e.created_at DESC, e.id DESC LIMIT $2`
+
+	var events []model.ActivityEvent
+	err := executor(ctx, r.db).SelectContext(ctx, &events, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
@@ -0,0 +1,135 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+)
+
+func TestActivityRepository(t *testing.T) {
+	userRepo := repository.NewUserRepository(testDB)
+	todoRepo := repository.NewTodoRepository(testDB)
+	shareRepo := repository.NewTodoShareRepository(testDB)
+	activityRepo := repository.NewActivityRepository(testDB)
+	ctx := context.Background()
+
+	// Create an owner, a user the todo is shared with, and an outsider
+	owner := &model.User{Email: "activity-owner@example.com", PasswordHash: "hashedpassword"}
+	viewer := &model.User{Email: "activity-viewer@example.com", PasswordHash: "hashedpassword"}
+	outsider := &model.User{Email: "activity-outsider@example.com", PasswordHash: "hashedpassword"}
+	for _, user := range []*model.User{owner, viewer, outsider} {
+		require.NoError(t, userRepo.Create(ctx, user))
+	}
+
+	todo := &model.Todo{UserID: owner.ID, Title: "Audited Todo"}
+	require.NoError(t, todoRepo.Create(ctx, todo))
+	require.NoError(t, shareRepo.Upsert(ctx, &model.TodoShare{TodoID: todo.ID, UserID: viewer.ID, Role: model.ShareRoleViewer}))
+
+	// Test Create
+	for _, action := range []model.ActivityAction{model.ActivityActionCreated, model.ActivityActionUpdated, model.ActivityActionAssigned} {
+		event := &model.ActivityEvent{
+			ActorID:    owner.ID,
+			EntityType: model.ActivityEntityTodo,
+			EntityID:   todo.ID,
+			TodoID:     &todo.ID,
+			Action:     action,
+			After:      types.NullJSONText{JSONText: types.JSONText(`{"title": "Audited Todo"}`), Valid: true},
+			RequestID:  "request-1",
+			IPAddress:  "192.0.2.1",
+		}
+		require.NoError(t, activityRepo.Create(ctx, event))
+		assert.NotEqual(t, uuid.Nil, event.ID)
+	}
+	accountEvent := &model.ActivityEvent{
+		ActorID:    outsider.ID,
+		EntityType: model.ActivityEntityUser,
+		EntityID:   outsider.ID,
+		Action:     model.ActivityActionCreated,
+	}
+	require.NoError(t, activityRepo.Create(ctx, accountEvent))
+
+	// Test GetByTodoID returns the newest first, paging with a cursor
+	page, err := activityRepo.GetByTodoID(ctx, todo.ID, nil, 2)
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, model.ActivityActionAssigned, page[0].Action)
+	assert.Equal(t, owner.Email, *page[0].ActorEmail)
+	assert.False(t, page[0].Before.Valid)
+	assert.JSONEq(t, `{"title": "Audited Todo"}`, string(page[0].After.JSONText))
+	assert.Equal(t, "192.0.2.1", page[0].IPAddress)
+
+	page, err = activityRepo.GetByTodoID(ctx, todo.ID, &model.ActivityCursor{CreatedAt: page[1].CreatedAt, ID: page[1].ID}, 2)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, model.ActivityActionCreated, page[0].Action)
+
+	// Test GetFeedByUserID includes events on shared todos but not other users' account events
+	feed, err := activityRepo.GetFeedByUserID(ctx, viewer.ID, nil, 10)
+	require.NoError(t, err)
+	assert.Len(t, feed, 3)
+
+	feed, err = activityRepo.GetFeedByUserID(ctx, outsider.ID, nil, 10)
+	require.NoError(t, err)
+	require.Len(t, feed, 1)
+	assert.Equal(t, accountEvent.ID, feed[0].ID)
+
+	// Test events cannot be modified
+	_, err = testDB.ExecContext(ctx, "UPDATE activity_events SET action = 'deleted' WHERE id = $1", accountEvent.ID)
+	assert.Error(t, err)
+	_, err = testDB.ExecContext(ctx, "DELETE FROM activity_events WHERE id = $1", accountEvent.ID)
+	assert.Error(t, err)
+}
+
+func TestTransactor(t *testing.T) {
+	userRepo := repository.NewUserRepository(testDB)
+	todoRepo := repository.NewTodoRepository(testDB)
+	activityRepo := repository.NewActivityRepository(testDB)
+	transactor := repository.NewTransactor(testDB)
+	ctx := context.Background()
+
+	user := &model.User{Email: "transactor-test@example.com", PasswordHash: "hashedpassword"}
+	require.NoError(t, userRepo.Create(ctx, user))
+
+	// A failure rolls back every write made in the transaction
+	todo := &model.Todo{UserID: user.ID, Title: "Rolled Back Todo"}
+	err := transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := todoRepo.Create(ctx, todo); err != nil {
+			return err
+		}
+		err := activityRepo.Create(ctx, &model.ActivityEvent{
+			ActorID:    user.ID,
+			EntityType: model.ActivityEntityTodo,
+			EntityID:   todo.ID,
+			TodoID:     &todo.ID,
+			Action:     model.ActivityActionCreated,
+		})
+		if err != nil {
+			return err
+		}
+		return errors.New("abort")
+	})
+	assert.EqualError(t, err, "abort")
+
+	_, err = todoRepo.GetByID(ctx, todo.ID)
+	assert.Error(t, err)
+	events, err := activityRepo.GetByTodoID(ctx, todo.ID, nil, 10)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+
+	// A successful function commits
+	committed := &model.Todo{UserID: user.ID, Title: "Committed Todo"}
+	err = transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		return todoRepo.Create(ctx, committed)
+	})
+	require.NoError(t, err)
+
+	_, err = todoRepo.GetByID(ctx, committed.ID)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/yukimaterrace/todoms/model"
+)
+
+// AttachmentRepository defines the interface for attachment metadata operations
+type AttachmentRepository interface {
+	Create(ctx context.Context, attachment *model.Attachment) error
+	GetByID(ctx context.Context, id uuid.UUID) (*model.Attachment, error)
+	GetByTodoID(ctx context.Context, todoID uuid.UUID) ([]model.Attachment, error)
+	GetTotalSizeByUploaderID(ctx context.Context, uploaderID uuid.UUID) (int64, error)
+	GetTotalSizeByUploaderIDForUpdate(ctx context.Context, uploaderID uuid.UUID) (int64, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	GetPendingBlobDeletions(ctx context.Context, limit int) ([]string, error)
+	RemovePendingBlobDeletion(ctx context.Context, storageKey string) error
+}
+
+// PostgresAttachmentRepository implements AttachmentRepository interface for PostgreSQL
+type PostgresAttachmentRepository struct {
+	db *sqlx.DB
+}
+
+// NewAttachmentRepository creates a new PostgresAttachmentRepository instance
+func NewAttachmentRepository(db *sqlx.DB) AttachmentRepository {
+	return &PostgresAttachmentRepository{db: db}
+}
+
+// Create inserts new attachment metadata into the database
+func (r *PostgresAttachmentRepository) Create(ctx context.Context, attachment *model.Attachment) error {
+	if attachment.ID == uuid.Nil {
+		attachment.ID = uuid.New()
+	}
+
+	query := `
+		INSERT INTO todo_attachments (id, todo_id, uploader_id, file_name, content_type, size, storage_key, created_at)
+		VALUES (:id, :todo_id, :uploader_id, :file_name, :content_type, :size, :storage_key, NOW())
+	`
+
+	_, err := executor(ctx, r.db).NamedExecContext(ctx, query, attachment)
+	return err
+}
+
+// GetByID retrieves attachment metadata by its ID
+func (r *PostgresAttachmentRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Attachment, error) {
+	query := `
+		SELECT id, todo_id, uploader_id, file_name, content_type, size, storage_key, created_at
+		FROM todo_attachments
+		WHERE id = $1
+	`
+
+	var attachment model.Attachment
+	err := executor(ctx, r.db).GetContext(ctx, &attachment, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &attachment, nil
+}
+
+// GetByTodoID retrieves the metadata of all attachments of a todo
+func (r *PostgresAttachmentRepository) GetByTodoID(ctx context.Context, todoID uuid.UUID) ([]model.Attachment, error) {
+	query := `
+		SELECT id, todo_id, uploader_id, file_name, content_type, size, storage_key, created_at
+		FROM todo_attachments
+		WHERE todo_id = $1
+		ORDER BY created_at
+	`
+
+	var attachments []model.Attachment
+	err := executor(ctx, r.db).SelectContext(ctx, &attachments, query, todoID)
+	if err != nil {
+		return nil, err
+	}
+
+	return attachments, nil
+}
+
+// GetTotalSizeByUploaderID returns the total size in bytes of all attachments uploaded by a user
+func (r *PostgresAttachmentRepository) GetTotalSizeByUploaderID(ctx context.Context, uploaderID uuid.UUID) (int64, error) {
+	query := `
+		SELECT COALESCE(SUM(size), 0)
+		FROM todo_attachments
+		WHERE uploader_id = $1
+	`
+
+	var total int64
+	err := executor(ctx, r.db).GetContext(ctx, &total, query, uploaderID)
+	return total, err
+}
+
+// GetTotalSizeByUploaderIDForUpdate returns the total size in bytes of all attachments uploaded by a user
+// It locks the user until the transaction ends, so an attachment can be created against the total without racing another
+func (r *PostgresAttachmentRepository) GetTotalSizeByUploaderIDForUpdate(ctx context.Context, uploaderID uuid.UUID) (int64, error) {
+	if err := lockUser(ctx, r.db, uploaderID); err != nil {
+		return 0, err
+	}
+	return r.GetTotalSizeByUploaderID(ctx, uploaderID)
+}
+
+// Delete removes attachment metadata from the database
+// The blob is queued for deletion by a trigger and purged from the blob store later
+func (r *PostgresAttachmentRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `
+		DELETE FROM todo_attachments
+		WHERE id = $1
+	`
+
+	_, err := executor(ctx, r.db).ExecContext(ctx, query, id)
+	return err
+}
+
+// GetPendingBlobDeletions retrieves the storage keys of blobs waiting to be purged, oldest first
+func (r *PostgresAttachmentRepository) GetPendingBlobDeletions(ctx context.Context, limit int) ([]string, error) {
+	query := `
+		SELECT storage_key
+		FROM blob_deletions
+		ORDER BY created_at
+		LIMIT $1
+	`
+
+	var keys []string
+	err := executor(ctx, r.db).SelectContext(ctx, &keys, query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// RemovePendingBlobDeletion removes a purged blob from the deletion queue
+func (r *PostgresAttachmentRepository) RemovePendingBlobDeletion(ctx context.Context, storageKey string) error {
+	query := `
+		DELETE FROM blob_deletions
+		WHERE storage_key = $1
+	`
+
+	_, err := executor(ctx, r.db).ExecContext(ctx, query, storageKey)
+	return err
+}
@@ -0,0 +1,97 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+)
+
+func TestAttachmentRepository(t *testing.T) {
+	userRepo := repository.NewUserRepository(testDB)
+	todoRepo := repository.NewTodoRepository(testDB)
+	attachmentRepo := repository.NewAttachmentRepository(testDB)
+	ctx := context.Background()
+
+	// Create a user and a todo to attach files to
+	user := &model.User{
+		Email:        "attachment-test@example.com",
+		PasswordHash: "hashedpassword",
+	}
+	err := userRepo.Create(ctx, user)
+	require.NoError(t, err)
+
+	todo := &model.Todo{
+		UserID: user.ID,
+		Title:  "Todo With Files",
+	}
+	err = todoRepo.Create(ctx, todo)
+	require.NoError(t, err)
+
+	// Test Create
+	var attachments []*model.Attachment
+	for _, key := range []string{"attachments/test-a", "attachments/test-b", "attachments/test-c"} {
+		attachment := &model.Attachment{
+			TodoID:      todo.ID,
+			UploaderID:  user.ID,
+			FileName:    key + ".png",
+			ContentType: "image/png",
+			Size:        100,
+			StorageKey:  key,
+		}
+		err = attachmentRepo.Create(ctx, attachment)
+		require.NoError(t, err)
+		attachments = append(attachments, attachment)
+	}
+
+	// Test GetByID
+	fetched, err := attachmentRepo.GetByID(ctx, attachments[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "attachments/test-a", fetched.StorageKey)
+	assert.Equal(t, int64(100), fetched.Size)
+
+	// Test GetByTodoID
+	list, err := attachmentRepo.GetByTodoID(ctx, todo.ID)
+	require.NoError(t, err)
+	assert.Len(t, list, 3)
+
+	// Test GetTotalSizeByUploaderID
+	total, err := attachmentRepo.GetTotalSizeByUploaderID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(300), total)
+
+	// Test Delete queues the blob for deletion
+	err = attachmentRepo.Delete(ctx, attachments[0].ID)
+	require.NoError(t, err)
+
+	_, err = attachmentRepo.GetByID(ctx, attachments[0].ID)
+	assert.Error(t, err)
+
+	pending, err := attachmentRepo.GetPendingBlobDeletions(ctx, 10)
+	require.NoError(t, err)
+	assert.Contains(t, pending, "attachments/test-a")
+
+	// Test deleting the todo queues the remaining blobs through the cascade
+	err = todoRepo.Delete(ctx, todo.ID)
+	require.NoError(t, err)
+
+	pending, err = attachmentRepo.GetPendingBlobDeletions(ctx, 10)
+	require.NoError(t, err)
+	assert.Contains(t, pending, "attachments/test-b")
+	assert.Contains(t, pending, "attachments/test-c")
+
+	total, err = attachmentRepo.GetTotalSizeByUploaderID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), total)
+
+	// Test RemovePendingBlobDeletion
+	err = attachmentRepo.RemovePendingBlobDeletion(ctx, "attachments/test-a")
+	require.NoError(t, err)
+
+	pending, err = attachmentRepo.GetPendingBlobDeletions(ctx, 10)
+	require.NoError(t, err)
+	assert.NotContains(t, pending, "attachments/test-a")
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrBlobNotFound is returned when a blob with the specified key does not exist
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore defines the interface for storing file contents
+type BlobStore interface {
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// ConnectBlobStore creates the blob store selected by the ATTACHMENT_STORAGE environment variable
+// "local" (default) stores files under ATTACHMENT_DIR, "s3" uses the S3_* settings
+func ConnectBlobStore(ctx context.Context) (BlobStore, error) {
+	storage := GetEnvOrDefault("ATTACHMENT_STORAGE", "local")
+	switch storage {
+	case "local":
+		return NewLocalBlobStore(GetEnvOrDefault("ATTACHMENT_DIR", "./data/attachments"))
+	case "s3":
+		store := NewS3BlobStore(S3Config{
+			Endpoint:        GetEnvOrDefault("S3_ENDPOINT", "http://localhost:9000"),
+			Region:          GetEnvOrDefault("S3_REGION", "us-east-1"),
+			Bucket:          GetEnvOrDefault("S3_BUCKET", "todoms-attachments"),
+			AccessKeyID:     GetEnvOrDefault("S3_ACCESS_KEY", "minioadmin"),
+			SecretAccessKey: GetEnvOrDefault("S3_SECRET_KEY", "minioadmin"),
+			Timeout:         DefaultS3Timeout,
+		}, nil)
+		if err := store.CreateBucket(ctx); err != nil {
+			return nil, err
+		}
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown attachment storage: %s", storage)
+	}
+}
@@ -0,0 +1,125 @@
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"github.com/yukimaterrace/todoms/repository"
+)
+
+// testBlobStore runs the behaviour every BlobStore implementation must share
+func testBlobStore(t *testing.T, store repository.BlobStore) {
+	ctx := context.Background()
+	key := "attachments/blob-test"
+	content := "hello, attachment"
+
+	// Test Put and Get
+	err := store.Put(ctx, key, strings.NewReader(content), int64(len(content)), "text/plain")
+	require.NoError(t, err)
+
+	body, err := store.Get(ctx, key)
+	require.NoError(t, err)
+	data, err := io.ReadAll(body)
+	body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, content, string(data))
+
+	// Test Delete
+	err = store.Delete(ctx, key)
+	require.NoError(t, err)
+
+	_, err = store.Get(ctx, key)
+	assert.ErrorIs(t, err, repository.ErrBlobNotFound)
+
+	// Deleting a missing blob is not an error
+	err = store.Delete(ctx, key)
+	assert.NoError(t, err)
+}
+
+func TestLocalBlobStore(t *testing.T) {
+	store, err := repository.NewLocalBlobStore(t.TempDir())
+	require.NoError(t, err)
+
+	testBlobStore(t, store)
+
+	// Keys escaping the base directory are rejected
+	err = store.Put(context.Background(), "../escape", strings.NewReader("x"), 1, "text/plain")
+	assert.Error(t, err)
+}
+
+func TestS3BlobStore(t *testing.T) {
+	ctx := context.Background()
+
+	// Start a MinIO container as the S3-compatible storage
+	minioContainer, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "minio/minio:latest",
+			Cmd:          []string{"server", "/data"},
+			ExposedPorts: []string{"9000/tcp"},
+			Env: map[string]string{
+				"MINIO_ROOT_USER":     "testuser",
+				"MINIO_ROOT_PASSWORD": "testpassword",
+			},
+			WaitingFor: wait.ForHTTP("/minio/health/live").WithPort("9000/tcp"),
+		},
+		Started: true,
+	})
+	require.NoError(t, err)
+	defer minioContainer.Terminate(ctx)
+
+	host, err := minioContainer.Host(ctx)
+	require.NoError(t, err)
+	port, err := minioContainer.MappedPort(ctx, "9000/tcp")
+	require.NoError(t, err)
+
+	store := repository.NewS3BlobStore(repository.S3Config{
+		Endpoint:        fmt.Sprintf("http://%s:%s", host, port.Port()),
+		Region:          "us-east-1",
+		Bucket:          "todoms-test",
+		AccessKeyID:     "testuser",
+		SecretAccessKey: "testpassword",
+	}, nil)
+	err = store.CreateBucket(ctx)
+	require.NoError(t, err)
+
+	// Creating an existing bucket is not an error
+	err = store.CreateBucket(ctx)
+	require.NoError(t, err)
+
+	testBlobStore(t, store)
+}
+
+func TestS3BlobStoreTimeout(t *testing.T) {
+	// A storage that never answers
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	store := repository.NewS3BlobStore(repository.S3Config{
+		Endpoint:        server.URL,
+		Region:          "us-east-1",
+		Bucket:          "todoms-test",
+		AccessKeyID:     "testuser",
+		SecretAccessKey: "testpassword",
+		Timeout:         50 * time.Millisecond,
+	}, nil)
+
+	started := time.Now()
+	_, err := store.Get(context.Background(), "attachments/blob-test")
+
+	assert.Error(t, err)
+	assert.Less(t, time.Since(started), 5*time.Second)
+}
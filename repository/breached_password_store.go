@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// BreachedPasswordStore defines the interface for looking up passwords in a breached-password corpus
+// Lookups are by k-anonymity: only the first 5 hex characters of the SHA-1 hash of a password are given,
+// and the hashes sharing the prefix are returned
+type BreachedPasswordStore interface {
+	// GetRange returns the breach counts of the hashes with the prefix, keyed by the remaining 35 uppercase hex characters
+	GetRange(ctx context.Context, prefix string) (map[string]int, error)
+}
+
+// FileBreachedPasswordStore implements BreachedPasswordStore interface on a local copy of the corpus
+// The directory holds one file per prefix, named like 5BAA6.txt, with SUFFIX:COUNT lines as served by
+// the Have I Been Pwned range API
+type FileBreachedPasswordStore struct {
+	dir string
+}
+
+// NewFileBreachedPasswordStore creates a new FileBreachedPasswordStore reading the range files in dir
+func NewFileBreachedPasswordStore(dir string) (BreachedPasswordStore, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("breached password corpus is not a directory: %s", dir)
+	}
+	return &FileBreachedPasswordStore{dir: dir}, nil
+}
+
+// GetRange returns the breach counts of the hashes with the prefix, keyed by the remaining 35 uppercase hex characters
+// A prefix without a range file has no breached hashes
+func (s *FileBreachedPasswordStore) GetRange(ctx context.Context, prefix string) (map[string]int, error) {
+	prefix = strings.ToUpper(prefix)
+	if len(prefix) != 5 || strings.Trim(prefix, "0123456789ABCDEF") != "" {
+		return nil, fmt.Errorf("invalid hash prefix: %q", prefix)
+	}
+
+	file, err := os.Open(filepath.Join(s.dir, prefix+".txt"))
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]int{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	counts := map[string]int{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		suffix, count, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			continue
+		}
+		counts[strings.ToUpper(suffix)] = n
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
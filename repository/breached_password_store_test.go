@@ -0,0 +1,43 @@
+package repository_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/repository"
+)
+
+func TestFileBreachedPasswordStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	rangeFile := "0018A45C4D1DEF81644B54AB7F969B88D65:1\r\n00D4F6E8FA6EECAD2A3AA415EEC418D38EC:2\r\nmalformed\r\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "5BAA6.txt"), []byte(rangeFile), 0o600))
+
+	store, err := repository.NewFileBreachedPasswordStore(dir)
+	require.NoError(t, err)
+
+	// Test GetRange reads the range file of the prefix, in either case
+	counts, err := store.GetRange(ctx, "5baa6")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{
+		"0018A45C4D1DEF81644B54AB7F969B88D65": 1,
+		"00D4F6E8FA6EECAD2A3AA415EEC418D38EC": 2,
+	}, counts)
+
+	// Test a prefix without a range file has no breached hashes
+	counts, err = store.GetRange(ctx, "00000")
+	require.NoError(t, err)
+	assert.Empty(t, counts)
+
+	// Test prefixes that are not 5 hex characters are refused
+	_, err = store.GetRange(ctx, "../5B")
+	assert.Error(t, err)
+
+	// Test the corpus has to be a directory
+	_, err = repository.NewFileBreachedPasswordStore(filepath.Join(dir, "5BAA6.txt"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/yukimaterrace/todoms/model"
+)
+
+// CommentRepository defines the interface for comment data operations
+type CommentRepository interface {
+	Create(ctx context.Context, comment *model.Comment) error
+	GetByID(ctx context.Context, id uuid.UUID) (*model.Comment, error)
+	GetByTodoID(ctx context.Context, todoID uuid.UUID, after *model.CommentCursor, limit int) ([]model.Comment, error)
+	Update(ctx context.Context, comment *model.Comment) error
+	SoftDelete(ctx context.Context, id uuid.UUID) error
+}
+
+// PostgresCommentRepository implements CommentRepository interface for PostgreSQL
+type PostgresCommentRepository struct {
+	db *sqlx.DB
+}
+
+// NewCommentRepository creates a new PostgresCommentRepository instance
+func NewCommentRepository(db *sqlx.DB) CommentRepository {
+	return &PostgresCommentRepository{db: db}
+}
+
+// Create inserts a new comment into the database
+func (r *PostgresCommentRepository) Create(ctx context.Context, comment *model.Comment) error {
+	if comment.ID == uuid.Nil {
+		comment.ID = uuid.New()
+	}
+
+	query := `
+		INSERT INTO todo_comments (id, todo_id, author_id, body, body_html, created_at, updated_at)
+		VALUES (:id, :todo_id, :author_id, :body, :body_html, NOW(), NOW())
+	`
+
+	_, err := executor(ctx, r.db).NamedExecContext(ctx, query, comment)
+	return err
+}
+
+// GetByID retrieves a comment that has not been deleted by its ID
+func (r *PostgresCommentRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Comment, error) {
+	query := `
+		SELECT c.id, c.todo_id, c.author_id, u.email AS author_email, c.body, c.body_html,
+			c.created_at, c.updated_at, c.deleted_at
+		FROM todo_comments c
+		JOIN users u ON u.id = c.author_id
+		WHERE c.id = $1 AND c.deleted_at IS NULL
+	`
+
+	var comment model.Comment
+	err := executor(ctx, r.db).GetContext(ctx, &comment, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &comment, nil
+}
+
+// GetByTodoID retrieves up to limit comments of a todo, oldest first, starting after the cursor
+func (r *PostgresCommentRepository) GetByTodoID(ctx context.Context, todoID uuid.UUID, after *model.CommentCursor, limit int) ([]model.Comment, error) {
+	query := `
+		SELECT c.id, c.todo_id, c.author_id, u.email AS author_email, c.body, c.body_html,
+			c.created_at, c.updated_at, c.deleted_at
+		FROM todo_comments c
+		JOIN users u ON u.id = c.author_id
+		WHERE c.todo_id = $1 AND c.deleted_at IS NULL
+	`
+	args := []interface{}{todoID, limit}
+	if after != nil {
+		query += ` AND (c.created_at, c.id) > ($3, $4)`
+		args = append(args, after.CreatedAt, after.ID)
+	}
+	query += ` ORDER BY c.created_at, c.id LIMIT $2`
+
+	var comments []model.Comment
+	err := executor(ctx, r.db).SelectContext(ctx, &comments, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// Update updates the body of an existing comment in the database
+func (r *PostgresCommentRepository) Update(ctx context.Context, comment *model.Comment) error {
+	query := `
+		UPDATE todo_comments
+		SET body = :body, body_html = :body_html
+		WHERE id = :id AND deleted_at IS NULL
+	`
+
+	_, err := executor(ctx, r.db).NamedExecContext(ctx, query, comment)
+	return err
+}
+
+// SoftDelete marks a comment as deleted while keeping the row
+func (r *PostgresCommentRepository) SoftDelete(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE todo_comments
+		SET deleted_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
+	`
+
+	_, err := executor(ctx, r.db).ExecContext(ctx, query, id)
+	return err
+}
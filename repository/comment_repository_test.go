@@ -0,0 +1,88 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+)
+
+func TestCommentRepository(t *testing.T) {
+	userRepo := repository.NewUserRepository(testDB)
+	todoRepo := repository.NewTodoRepository(testDB)
+	commentRepo := repository.NewCommentRepository(testDB)
+	ctx := context.Background()
+
+	// Create a user and a todo to comment on
+	user := &model.User{
+		Email:        "comment-test@example.com",
+		PasswordHash: "hashedpassword",
+	}
+	err := userRepo.Create(ctx, user)
+	require.NoError(t, err)
+
+	todo := &model.Todo{
+		UserID: user.ID,
+		Title:  "Discussed Todo",
+	}
+	err = todoRepo.Create(ctx, todo)
+	require.NoError(t, err)
+
+	// Test Create
+	var ids []uuid.UUID
+	for _, body := range []string{"first", "second", "third"} {
+		comment := &model.Comment{
+			TodoID:   todo.ID,
+			AuthorID: user.ID,
+			Body:     body,
+			BodyHTML: "<p>" + body + "</p>",
+		}
+		err = commentRepo.Create(ctx, comment)
+		require.NoError(t, err)
+		assert.NotEqual(t, uuid.Nil, comment.ID)
+		ids = append(ids, comment.ID)
+	}
+
+	// Test GetByID
+	fetched, err := commentRepo.GetByID(ctx, ids[0])
+	require.NoError(t, err)
+	assert.Equal(t, "first", fetched.Body)
+	assert.Equal(t, user.Email, fetched.AuthorEmail)
+
+	// Test GetByTodoID with a cursor
+	page, err := commentRepo.GetByTodoID(ctx, todo.ID, nil, 2)
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, "first", page[0].Body)
+
+	cursor := &model.CommentCursor{CreatedAt: page[1].CreatedAt, ID: page[1].ID}
+	page, err = commentRepo.GetByTodoID(ctx, todo.ID, cursor, 2)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, "third", page[0].Body)
+
+	// Test Update
+	fetched.Body = "edited"
+	fetched.BodyHTML = "<p>edited</p>"
+	err = commentRepo.Update(ctx, fetched)
+	require.NoError(t, err)
+
+	updated, err := commentRepo.GetByID(ctx, fetched.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "edited", updated.Body)
+
+	// Test SoftDelete hides the comment
+	err = commentRepo.SoftDelete(ctx, ids[1])
+	require.NoError(t, err)
+
+	_, err = commentRepo.GetByID(ctx, ids[1])
+	assert.Error(t, err)
+
+	page, err = commentRepo.GetByTodoID(ctx, todo.ID, nil, 10)
+	require.NoError(t, err)
+	assert.Len(t, page, 2)
+}
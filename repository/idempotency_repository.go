@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/yukimaterrace/todoms/model"
+)
+
+// IdempotencyRepository defines the interface for Idempotency-Key operations
+type IdempotencyRepository interface {
+	Claim(ctx context.Context, record *model.IdempotencyRecord, ttl time.Duration, lockTimeout time.Duration) (bool, error)
+	Get(ctx context.Context, userID uuid.UUID, key string) (*model.IdempotencyRecord, error)
+	SaveResponse(ctx context.Context, record *model.IdempotencyRecord) error
+	Delete(ctx context.Context, userID uuid.UUID, key string) error
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// PostgresIdempotencyRepository implements IdempotencyRepository interface for PostgreSQL
+type PostgresIdempotencyRepository struct {
+	db *sqlx.DB
+}
+
+// NewIdempotencyRepository creates a new PostgresIdempotencyRepository instance
+func NewIdempotencyRepository(db *sqlx.DB) IdempotencyRepository {
+	return &PostgresIdempotencyRepository{db: db}
+}
+
+// Claim stores a key for a request that is about to be processed, reporting whether it was claimed
+// A key already stored is only claimed again once it has expired, or when the request holding it
+// has not finished within the lock timeout
+func (r *PostgresIdempotencyRepository) Claim(ctx context.Context, record *model.IdempotencyRecord, ttl time.Duration, lockTimeout time.Duration) (bool, error) {
+	query := `
+		INSERT INTO idempotency_keys (user_id, idempotency_key, fingerprint, expires_at)
+		VALUES ($1, $2, $3, NOW() + make_interval(secs => $4))
+		ON CONFLICT (user_id, idempotency_key) DO UPDATE
+		SET fingerprint = EXCLUDED.fingerprint,
+			response_status = NULL,
+			response_content_type = NULL,
+			response_body = NULL,
+			created_at = NOW(),
+			expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= NOW()
+			OR (idempotency_keys.response_status IS NULL
+				AND idempotency_keys.created_at <= NOW() - make_interval(secs => $5))
+	`
+
+	result, err := executor(ctx, r.db).ExecContext(ctx, query,
+		record.UserID, record.Key, record.Fingerprint, ttl.Seconds(), lockTimeout.Seconds())
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+// Get retrieves a stored key that has not expired
+func (r *PostgresIdempotencyRepository) Get(ctx context.Context, userID uuid.UUID, key string) (*model.IdempotencyRecord, error) {
+	query := `
+		SELECT user_id, idempotency_key, fingerprint, response_status, response_content_type, response_body,
+			created_at, expires_at
+		FROM idempotency_keys
+		WHERE user_id = $1 AND idempotency_key = $2 AND expires_at > NOW()
+	`
+
+	var record model.IdempotencyRecord
+	err := executor(ctx, r.db).GetContext(ctx, &record, query, userID, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// SaveResponse stores the response to the request holding a key
+func (r *PostgresIdempotencyRepository) SaveResponse(ctx context.Context, record *model.IdempotencyRecord) error {
+	query := `
+		UPDATE idempotency_keys
+		SET response_status = :response_status, response_content_type = :response_content_type,
+			response_body = :response_body
+		WHERE user_id = :user_id AND idempotency_key = :idempotency_key
+	`
+
+	_, err := executor(ctx, r.db).NamedExecContext(ctx, query, record)
+	return err
+}
+
+// Delete removes a key whose request is still being processed, so that the request can be retried
+func (r *PostgresIdempotencyRepository) Delete(ctx context.Context, userID uuid.UUID, key string) error {
+	query := `
+		DELETE FROM idempotency_keys
+		WHERE user_id = $1 AND idempotency_key = $2 AND response_status IS NULL
+	`
+
+	_, err := executor(ctx, r.db).ExecContext(ctx, query, userID, key)
+	return err
+}
+
+// DeleteExpired removes the keys that have expired, returning how many were removed
+func (r *PostgresIdempotencyRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	query := `
+		DELETE FROM idempotency_keys
+		WHERE expires_at <= NOW()
+	`
+
+	result, err := executor(ctx, r.db).ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,106 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+)
+
+func TestIdempotencyRepository(t *testing.T) {
+	userRepo := repository.NewUserRepository(testDB)
+	idempotencyRepo := repository.NewIdempotencyRepository(testDB)
+	ctx := context.Background()
+
+	user := &model.User{
+		Email:        "idempotency@example.com",
+		PasswordHash: "hashedpassword",
+	}
+	err := userRepo.Create(ctx, user)
+	require.NoError(t, err)
+
+	record := &model.IdempotencyRecord{
+		UserID:      user.ID,
+		Key:         "key-1",
+		Fingerprint: "fp-1",
+	}
+
+	// Test Claim of a new key
+	claimed, err := idempotencyRepo.Claim(ctx, record, time.Hour, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+
+	// Test a key in progress cannot be claimed again
+	claimed, err = idempotencyRepo.Claim(ctx, record, time.Hour, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, claimed)
+
+	stored, err := idempotencyRepo.Get(ctx, user.ID, "key-1")
+	require.NoError(t, err)
+	assert.Equal(t, "fp-1", stored.Fingerprint)
+	assert.False(t, stored.Completed())
+
+	// Test a key in progress past the lock timeout is claimed again
+	claimed, err = idempotencyRepo.Claim(ctx, record, time.Hour, 0)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+
+	// Test SaveResponse
+	status := http.StatusCreated
+	contentType := "application/json"
+	record.ResponseStatus = &status
+	record.ResponseContentType = &contentType
+	record.ResponseBody = []byte(`{"id":"1"}`)
+	err = idempotencyRepo.SaveResponse(ctx, record)
+	require.NoError(t, err)
+
+	stored, err = idempotencyRepo.Get(ctx, user.ID, "key-1")
+	require.NoError(t, err)
+	require.True(t, stored.Completed())
+	assert.Equal(t, http.StatusCreated, *stored.ResponseStatus)
+	assert.Equal(t, `{"id":"1"}`, string(stored.ResponseBody))
+
+	// Test a completed key is not claimed again, even past the lock timeout, and is not released
+	claimed, err = idempotencyRepo.Claim(ctx, record, time.Hour, 0)
+	require.NoError(t, err)
+	assert.False(t, claimed)
+
+	err = idempotencyRepo.Delete(ctx, user.ID, "key-1")
+	require.NoError(t, err)
+	_, err = idempotencyRepo.Get(ctx, user.ID, "key-1")
+	assert.NoError(t, err)
+
+	// Test Delete releases a key in progress
+	other := &model.IdempotencyRecord{UserID: user.ID, Key: "key-2", Fingerprint: "fp-2"}
+	claimed, err = idempotencyRepo.Claim(ctx, other, time.Hour, time.Minute)
+	require.NoError(t, err)
+	require.True(t, claimed)
+
+	err = idempotencyRepo.Delete(ctx, user.ID, "key-2")
+	require.NoError(t, err)
+	_, err = idempotencyRepo.Get(ctx, user.ID, "key-2")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	// Test an expired key is claimed again and purged
+	expired := &model.IdempotencyRecord{UserID: user.ID, Key: "key-3", Fingerprint: "fp-3"}
+	claimed, err = idempotencyRepo.Claim(ctx, expired, 0, time.Minute)
+	require.NoError(t, err)
+	require.True(t, claimed)
+
+	_, err = idempotencyRepo.Get(ctx, user.ID, "key-3")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	claimed, err = idempotencyRepo.Claim(ctx, expired, 0, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+
+	purged, err := idempotencyRepo.DeleteExpired(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+}
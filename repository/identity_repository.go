@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/yukimaterrace/todoms/model"
+)
+
+// ErrDuplicateIdentity is returned when linking an external account that is already linked to a user
+var ErrDuplicateIdentity = errors.New("duplicate identity")
+
+// IdentityRepository defines the interface for external identity and OpenID Connect state operations
+type IdentityRepository interface {
+	CreateState(ctx context.Context, state *model.OIDCState, ttl time.Duration) error
+	ConsumeState(ctx context.Context, stateHash []byte, provider string) (*model.OIDCState, error)
+	DeleteExpiredStates(ctx context.Context) (int64, error)
+	CreateIdentity(ctx context.Context, identity *model.UserIdentity) error
+	GetIdentity(ctx context.Context, provider, subject string) (*model.UserIdentity, error)
+	GetIdentitiesByUserID(ctx context.Context, userID uuid.UUID) ([]model.UserIdentity, error)
+	UpdateIdentityLogin(ctx context.Context, id uuid.UUID, email string) error
+	DeleteIdentity(ctx context.Context, userID, id uuid.UUID) (bool, error)
+}
+
+// PostgresIdentityRepository implements IdentityRepository interface for PostgreSQL
+type PostgresIdentityRepository struct {
+	db *sqlx.DB
+}
+
+// NewIdentityRepository creates a new PostgresIdentityRepository instance
+func NewIdentityRepository(db *sqlx.DB) IdentityRepository {
+	return &PostgresIdentityRepository{db: db}
+}
+
+// CreateState inserts a new state that expires after the ttl
+func (r *PostgresIdentityRepository) CreateState(ctx context.Context, state *model.OIDCState, ttl time.Duration) error {
+	query := `
+		INSERT INTO oidc_states (state_hash, provider, code_verifier, nonce, user_id, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, NOW(), NOW() + make_interval(secs => $6))
+		RETURNING state_hash, provider, code_verifier, nonce, user_id, created_at, expires_at
+	`
+
+	return executor(ctx, r.db).GetContext(ctx, state, query,
+		state.StateHash, state.Provider, state.CodeVerifier, state.Nonce, state.UserID, ttl.Seconds())
+}
+
+// ConsumeState deletes the state with the hash for the provider, returning it
+// A state that was already used or has expired cannot be consumed, and sql.ErrNoRows is returned
+func (r *PostgresIdentityRepository) ConsumeState(ctx context.Context, stateHash []byte, provider string) (*model.OIDCState, error) {
+	query := `
+		DELETE FROM oidc_states
+		WHERE state_hash = $1 AND provider = $2 AND expires_at > NOW()
+		RETURNING state_hash, provider, code_verifier, nonce, user_id, created_at, expires_at
+	`
+
+	var state model.OIDCState
+	err := executor(ctx, r.db).GetContext(ctx, &state, query, stateHash, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+// DeleteExpiredStates removes the states that have expired and returns how many were removed
+func (r *PostgresIdentityRepository) DeleteExpiredStates(ctx context.Context) (int64, error) {
+	query := `
+		DELETE FROM oidc_states
+		WHERE expires_at <= NOW()
+	`
+
+	result, err := executor(ctx, r.db).ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// CreateIdentity links an external account to a user, generating the ID of the link
+func (r *PostgresIdentityRepository) CreateIdentity(ctx context.Context, identity *model.UserIdentity) error {
+	identity.ID = uuid.New()
+
+	query := `
+		INSERT INTO user_identities (id, user_id, provider, subject, email, created_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		RETURNING id, user_id, provider, subject, email, created_at, last_login_at
+	`
+
+	err := executor(ctx, r.db).GetContext(ctx, identity, query,
+		identity.ID, identity.UserID, identity.Provider, identity.Subject, identity.Email)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrDuplicateIdentity
+	}
+	return err
+}
+
+// GetIdentity retrieves the link of the external account with the subject at the provider
+func (r *PostgresIdentityRepository) GetIdentity(ctx context.Context, provider, subject string) (*model.UserIdentity, error) {
+	query := `
+		SELECT id, user_id, provider, subject, email, created_at, last_login_at
+		FROM user_identities
+		WHERE provider = $1 AND subject = $2
+	`
+
+	var identity model.UserIdentity
+	err := executor(ctx, r.db).GetContext(ctx, &identity, query, provider, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	return &identity, nil
+}
+
+// GetIdentitiesByUserID retrieves the external accounts linked to a user, oldest first
+func (r *PostgresIdentityRepository) GetIdentitiesByUserID(ctx context.Context, userID uuid.UUID) ([]model.UserIdentity, error) {
+	query := `
+		SELECT id, user_id, provider, subject, email, created_at, last_login_at
+		FROM user_identities
+		WHERE user_id = $1
+		ORDER BY created_at, id
+	`
+
+	identities := []model.UserIdentity{}
+	err := executor(ctx, r.db).SelectContext(ctx, &identities, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return identities, nil
+}
+
+// UpdateIdentityLogin records a login with an external account and the email address the provider reported
+func (r *PostgresIdentityRepository) UpdateIdentityLogin(ctx context.Context, id uuid.UUID, email string) error {
+	query := `
+		UPDATE user_identities
+		SET email = $2, last_login_at = NOW()
+		WHERE id = $1
+	`
+
+	_, err := executor(ctx, r.db).ExecContext(ctx, query, id, email)
+	return err
+}
+
+// DeleteIdentity unlinks an external account from a user, reporting false when the user has no such identity
+func (r *PostgresIdentityRepository) DeleteIdentity(ctx context.Context, userID, id uuid.UUID) (bool, error) {
+	query := `
+		DELETE FROM user_identities
+		WHERE id = $1 AND user_id = $2
+	`
+
+	result, err := executor(ctx, r.db).ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
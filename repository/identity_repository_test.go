@@ -0,0 +1,99 @@
+package repository_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+)
+
+func TestIdentityRepository(t *testing.T) {
+	userRepo := repository.NewUserRepository(testDB)
+	identityRepo := repository.NewIdentityRepository(testDB)
+	ctx := context.Background()
+
+	user := &model.User{
+		Email:        "identity@example.com",
+		PasswordHash: "hashedpassword",
+	}
+	err := userRepo.Create(ctx, user)
+	require.NoError(t, err)
+
+	// Test CreateState and ConsumeState use a state once, for its provider
+	stateHash := sha256.Sum256([]byte("link-state"))
+	state := &model.OIDCState{StateHash: stateHash[:], Provider: "google", CodeVerifier: "verifier", Nonce: "nonce", UserID: &user.ID}
+	err = identityRepo.CreateState(ctx, state, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, state.ExpiresAt.After(state.CreatedAt))
+
+	_, err = identityRepo.ConsumeState(ctx, stateHash[:], "other")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	consumed, err := identityRepo.ConsumeState(ctx, stateHash[:], "google")
+	require.NoError(t, err)
+	assert.Equal(t, "verifier", consumed.CodeVerifier)
+	assert.Equal(t, "nonce", consumed.Nonce)
+	assert.Equal(t, user.ID, *consumed.UserID)
+	_, err = identityRepo.ConsumeState(ctx, stateHash[:], "google")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	// Test an expired state cannot be consumed and is purged
+	expiredHash := sha256.Sum256([]byte("expired-state"))
+	err = identityRepo.CreateState(ctx, &model.OIDCState{StateHash: expiredHash[:], Provider: "google"}, -time.Minute)
+	require.NoError(t, err)
+	_, err = identityRepo.ConsumeState(ctx, expiredHash[:], "google")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	purged, err := identityRepo.DeleteExpiredStates(ctx)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, purged, int64(1))
+
+	// A user has no identities until one is linked
+	identities, err := identityRepo.GetIdentitiesByUserID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, identities)
+
+	// Test CreateIdentity
+	identity := &model.UserIdentity{UserID: user.ID, Provider: "google", Subject: "subject-1", Email: "identity@example.com"}
+	err = identityRepo.CreateIdentity(ctx, identity)
+	require.NoError(t, err)
+	assert.NotEqual(t, uuid.Nil, identity.ID)
+	assert.Nil(t, identity.LastLoginAt)
+
+	// Test an external account can be linked once
+	err = identityRepo.CreateIdentity(ctx, &model.UserIdentity{UserID: user.ID, Provider: "google", Subject: "subject-1"})
+	assert.ErrorIs(t, err, repository.ErrDuplicateIdentity)
+
+	// Test GetIdentity
+	stored, err := identityRepo.GetIdentity(ctx, "google", "subject-1")
+	require.NoError(t, err)
+	assert.Equal(t, identity.ID, stored.ID)
+	assert.Equal(t, user.ID, stored.UserID)
+	_, err = identityRepo.GetIdentity(ctx, "other", "subject-1")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	// Test UpdateIdentityLogin
+	err = identityRepo.UpdateIdentityLogin(ctx, identity.ID, "changed@example.com")
+	require.NoError(t, err)
+	identities, err = identityRepo.GetIdentitiesByUserID(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, identities, 1)
+	assert.Equal(t, "changed@example.com", identities[0].Email)
+	assert.NotNil(t, identities[0].LastLoginAt)
+
+	// Test DeleteIdentity only deletes identities of the user
+	deleted, err := identityRepo.DeleteIdentity(ctx, uuid.New(), identity.ID)
+	require.NoError(t, err)
+	assert.False(t, deleted)
+	deleted, err = identityRepo.DeleteIdentity(ctx, user.ID, identity.ID)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	identities, err = identityRepo.GetIdentitiesByUserID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, identities)
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// LoginAttemptRepository defines the interface for failed login and lockout operations
+type LoginAttemptRepository interface {
+	GetLockout(ctx context.Context, email string) (time.Duration, error)
+	RecordFailure(ctx context.Context, email string, window time.Duration) (int, error)
+	Lock(ctx context.Context, email string, duration time.Duration) error
+	Reset(ctx context.Context, email string) error
+	DeleteStale(ctx context.Context, window time.Duration) (int64, error)
+}
+
+// PostgresLoginAttemptRepository implements LoginAttemptRepository interface for PostgreSQL
+type PostgresLoginAttemptRepository struct {
+	db *sqlx.DB
+}
+
+// NewLoginAttemptRepository creates a new PostgresLoginAttemptRepository instance
+func NewLoginAttemptRepository(db *sqlx.DB) LoginAttemptRepository {
+	return &PostgresLoginAttemptRepository{db: db}
+}
+
+// GetLockout returns how long logins for the email remain locked, or zero if they are not
+func (r *PostgresLoginAttemptRepository) GetLockout(ctx context.Context, email string) (time.Duration, error) {
+	query := `
+		SELECT EXTRACT(EPOCH FROM locked_until - NOW())
+		FROM login_attempts
+		WHERE email = $1 AND locked_until > NOW()
+	`
+
+	var seconds float64
+	err := executor(ctx, r.db).GetContext(ctx, &seconds, query, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// RecordFailure counts a failed login for the email, returning the number of consecutive failures
+// The count starts over when the previous failure is older than the window
+func (r *PostgresLoginAttemptRepository) RecordFailure(ctx context.Context, email string, window time.Duration) (int, error) {
+	query := `
+		INSERT INTO login_attempts AS a (email, failure_count, last_failure_at)
+		VALUES ($1, 1, NOW())
+		ON CONFLICT (email) DO UPDATE
+		SET failure_count = CASE
+				WHEN a.last_failure_at <= NOW() - make_interval(secs => $2) THEN 1
+				ELSE a.failure_count + 1
+			END,
+			last_failure_at = NOW()
+		RETURNING failure_count
+	`
+
+	var count int
+	err := executor(ctx, r.db).GetContext(ctx, &count, query, email, window.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Lock locks logins for the email for the duration
+func (r *PostgresLoginAttemptRepository) Lock(ctx context.Context, email string, duration time.Duration) error {
+	query := `
+		UPDATE login_attempts
+		SET locked_until = NOW() + make_interval(secs => $2)
+		WHERE email = $1
+	`
+
+	_, err := executor(ctx, r.db).ExecContext(ctx, query, email, duration.Seconds())
+	return err
+}
+
+// Reset clears the failed logins and lockout of the email
+func (r *PostgresLoginAttemptRepository) Reset(ctx context.Context, email string) error {
+	query := `
+		DELETE FROM login_attempts
+		WHERE email = $1
+	`
+
+	_, err := executor(ctx, r.db).ExecContext(ctx, query, email)
+	return err
+}
+
+// DeleteStale removes the emails whose last failure is older than the window and which are not locked,
+// returning how many were removed
+func (r *PostgresLoginAttemptRepository) DeleteStale(ctx context.Context, window time.Duration) (int64, error) {
+	query := `
+		DELETE FROM login_attempts
+		WHERE last_failure_at <= NOW() - make_interval(secs => $1)
+			AND (locked_until IS NULL OR locked_until <= NOW())
+	`
+
+	result, err := executor(ctx, r.db).ExecContext(ctx, query, window.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
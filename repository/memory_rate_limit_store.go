@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/yukimaterrace/todoms/model"
+)
+
+// memoryBucket is a token bucket held in memory
+type memoryBucket struct {
+	tokens    float64
+	updatedAt time.Time
+	fullAt    time.Time
+}
+
+// MemoryRateLimitStore implements RateLimitStore interface in memory
+// Buckets are not shared between instances, so each instance applies the limits on its own
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+}
+
+// NewMemoryRateLimitStore creates a new MemoryRateLimitStore instance
+func NewMemoryRateLimitStore() RateLimitStore {
+	return &MemoryRateLimitStore{buckets: make(map[string]*memoryBucket)}
+}
+
+// Take takes a token from the bucket stored under the key, creating a full bucket if there is none
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, limit model.RateLimit) (*model.RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &memoryBucket{tokens: float64(limit.Burst), updatedAt: now}
+		s.buckets[key] = bucket
+	}
+
+	tokens := math.Min(float64(limit.Burst), bucket.tokens+now.Sub(bucket.updatedAt).Seconds()*limit.RefillRate())
+	if tokens < 1 {
+		return newRateLimitResult(false, tokens, limit), nil
+	}
+
+	bucket.tokens = tokens - 1
+	bucket.updatedAt = now
+	result := newRateLimitResult(true, bucket.tokens, limit)
+	bucket.fullAt = now.Add(result.ResetAfter)
+	return result, nil
+}
+
+// DeleteFull removes the buckets that have refilled, which are the same as no bucket, returning how many were removed
+func (s *MemoryRateLimitStore) DeleteFull(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	now := time.Now()
+	for key, bucket := range s.buckets {
+		if !bucket.fullAt.After(now) {
+			delete(s.buckets, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/yukimaterrace/todoms/model"
+)
+
+// MFARepository defines the interface for TOTP authenticator and recovery code operations
+type MFARepository interface {
+	Save(ctx context.Context, mfa *model.UserMFA) error
+	GetByUserID(ctx context.Context, userID uuid.UUID) (*model.UserMFA, error)
+	Enable(ctx context.Context, userID uuid.UUID) error
+	UseStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error)
+	Delete(ctx context.Context, userID uuid.UUID) error
+	ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes [][]byte) error
+	ConsumeRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash []byte) (bool, error)
+	CountRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error)
+}
+
+// PostgresMFARepository implements MFARepository interface for PostgreSQL
+type PostgresMFARepository struct {
+	db *sqlx.DB
+}
+
+// NewMFARepository creates a new PostgresMFARepository instance
+func NewMFARepository(db *sqlx.DB) MFARepository {
+	return &PostgresMFARepository{db: db}
+}
+
+// Save stores a pending authenticator for a user, replacing the one they have
+func (r *PostgresMFARepository) Save(ctx context.Context, mfa *model.UserMFA) error {
+	query := `
+		INSERT INTO user_mfa (user_id, secret, enabled_at, last_used_step, created_at)
+		VALUES ($1, $2, NULL, 0, NOW())
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, enabled_at = NULL, last_used_step = 0, created_at = NOW()
+		RETURNING user_id, secret, enabled_at, last_used_step, created_at
+	`
+
+	return executor(ctx, r.db).GetContext(ctx, mfa, query, mfa.UserID, mfa.Secret)
+}
+
+// GetByUserID retrieves the authenticator of a user
+func (r *PostgresMFARepository) GetByUserID(ctx context.Context, userID uuid.UUID) (*model.UserMFA, error) {
+	query := `
+		SELECT user_id, secret, enabled_at, last_used_step, created_at
+		FROM user_mfa
+		WHERE user_id = $1
+	`
+
+	var mfa model.UserMFA
+	err := executor(ctx, r.db).GetContext(ctx, &mfa, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mfa, nil
+}
+
+// Enable marks the authenticator of a user as confirmed
+func (r *PostgresMFARepository) Enable(ctx context.Context, userID uuid.UUID) error {
+	query := `
+		UPDATE user_mfa
+		SET enabled_at = NOW()
+		WHERE user_id = $1
+	`
+
+	_, err := executor(ctx, r.db).ExecContext(ctx, query, userID)
+	return err
+}
+
+// UseStep records a code of the time step as used, reporting false when a code of the step or a later one
+// was already used, so that each code is accepted once
+func (r *PostgresMFARepository) UseStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	query := `
+		UPDATE user_mfa
+		SET last_used_step = $2
+		WHERE user_id = $1 AND last_used_step < $2
+	`
+
+	result, err := executor(ctx, r.db).ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+// Delete removes the authenticator and the recovery codes of a user
+// It should be called within a transaction, as it runs a statement for each
+func (r *PostgresMFARepository) Delete(ctx context.Context, userID uuid.UUID) error {
+	query := `
+		DELETE FROM mfa_recovery_codes
+		WHERE user_id = $1
+	`
+
+	if _, err := executor(ctx, r.db).ExecContext(ctx, query, userID); err != nil {
+		return err
+	}
+
+	query = `
+		DELETE FROM user_mfa
+		WHERE user_id = $1
+	`
+
+	_, err := executor(ctx, r.db).ExecContext(ctx, query, userID)
+	return err
+}
+
+// ReplaceRecoveryCodes replaces the recovery codes of a user with the ones with the hashes
+// It should be called within a transaction, as it runs a statement for each code
+func (r *PostgresMFARepository) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes [][]byte) error {
+	query := `
+		DELETE FROM mfa_recovery_codes
+		WHERE user_id = $1
+	`
+
+	if _, err := executor(ctx, r.db).ExecContext(ctx, query, userID); err != nil {
+		return err
+	}
+
+	query = `
+		INSERT INTO mfa_recovery_codes (id, user_id, code_hash, created_at)
+		VALUES ($1, $2, $3, NOW())
+	`
+
+	for _, codeHash := range codeHashes {
+		if _, err := executor(ctx, r.db).ExecContext(ctx, query, uuid.New(), userID, codeHash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConsumeRecoveryCode marks the unused recovery code of a user with the hash as used,
+// reporting false when there is no such code
+func (r *PostgresMFARepository) ConsumeRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash []byte) (bool, error) {
+	query := `
+		UPDATE mfa_recovery_codes
+		SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`
+
+	result, err := executor(ctx, r.db).ExecContext(ctx, query, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+// CountRecoveryCodes counts the unused recovery codes of a user
+func (r *PostgresMFARepository) CountRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM mfa_recovery_codes
+		WHERE user_id = $1 AND used_at IS NULL
+	`
+
+	var count int
+	err := executor(ctx, r.db).GetContext(ctx, &count, query, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
@@ -0,0 +1,107 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+)
+
+func TestMFARepository(t *testing.T) {
+	userRepo := repository.NewUserRepository(testDB)
+	mfaRepo := repository.NewMFARepository(testDB)
+	ctx := context.Background()
+
+	user := &model.User{
+		Email:        "mfa@example.com",
+		PasswordHash: "hashedpassword",
+	}
+	err := userRepo.Create(ctx, user)
+	require.NoError(t, err)
+
+	// A user has no authenticator until enrolment is started
+	_, err = mfaRepo.GetByUserID(ctx, user.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	// Test Save
+	mfa := &model.UserMFA{UserID: user.ID, Secret: []byte("secret-1")}
+	err = mfaRepo.Save(ctx, mfa)
+	require.NoError(t, err)
+	assert.False(t, mfa.Enabled())
+	assert.NotZero(t, mfa.CreatedAt)
+
+	// Test Save replaces a pending authenticator
+	err = mfaRepo.Save(ctx, &model.UserMFA{UserID: user.ID, Secret: []byte("secret-2")})
+	require.NoError(t, err)
+	stored, err := mfaRepo.GetByUserID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("secret-2"), stored.Secret)
+	assert.False(t, stored.Enabled())
+
+	// Test Enable
+	err = mfaRepo.Enable(ctx, user.ID)
+	require.NoError(t, err)
+	stored, err = mfaRepo.GetByUserID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.True(t, stored.Enabled())
+
+	// Test UseStep accepts each time step once, and not earlier ones
+	fresh, err := mfaRepo.UseStep(ctx, user.ID, 100)
+	require.NoError(t, err)
+	assert.True(t, fresh)
+	fresh, err = mfaRepo.UseStep(ctx, user.ID, 100)
+	require.NoError(t, err)
+	assert.False(t, fresh)
+	fresh, err = mfaRepo.UseStep(ctx, user.ID, 99)
+	require.NoError(t, err)
+	assert.False(t, fresh)
+	fresh, err = mfaRepo.UseStep(ctx, user.ID, 101)
+	require.NoError(t, err)
+	assert.True(t, fresh)
+
+	// Test ReplaceRecoveryCodes and CountRecoveryCodes
+	err = mfaRepo.ReplaceRecoveryCodes(ctx, user.ID, [][]byte{[]byte("code-1"), []byte("code-2")})
+	require.NoError(t, err)
+	count, err := mfaRepo.CountRecoveryCodes(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	// Test ConsumeRecoveryCode uses a code once
+	consumed, err := mfaRepo.ConsumeRecoveryCode(ctx, user.ID, []byte("code-1"))
+	require.NoError(t, err)
+	assert.True(t, consumed)
+	consumed, err = mfaRepo.ConsumeRecoveryCode(ctx, user.ID, []byte("code-1"))
+	require.NoError(t, err)
+	assert.False(t, consumed)
+	count, err = mfaRepo.CountRecoveryCodes(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	// Test ReplaceRecoveryCodes invalidates the old codes
+	err = mfaRepo.ReplaceRecoveryCodes(ctx, user.ID, [][]byte{[]byte("code-3")})
+	require.NoError(t, err)
+	consumed, err = mfaRepo.ConsumeRecoveryCode(ctx, user.ID, []byte("code-2"))
+	require.NoError(t, err)
+	assert.False(t, consumed)
+
+	// Test Delete
+	err = mfaRepo.Delete(ctx, user.ID)
+	require.NoError(t, err)
+	_, err = mfaRepo.GetByUserID(ctx, user.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	count, err = mfaRepo.CountRecoveryCodes(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	// Test the authenticator is deleted with the user
+	err = mfaRepo.Save(ctx, &model.UserMFA{UserID: user.ID, Secret: []byte("secret-3")})
+	require.NoError(t, err)
+	err = userRepo.Delete(ctx, user.ID)
+	require.NoError(t, err)
+	_, err = mfaRepo.GetByUserID(ctx, user.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
@@ -0,0 +1,339 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/yukimaterrace/todoms/model"
+)
+
+// OAuthRepository defines the interface for OAuth client, consent, authorization code and token operations
+type OAuthRepository interface {
+	CreateClient(ctx context.Context, client *model.OAuthClient) error
+	GetClient(ctx context.Context, id uuid.UUID) (*model.OAuthClient, error)
+	GetClientsByUserID(ctx context.Context, userID uuid.UUID) ([]model.OAuthClient, error)
+	DeleteClient(ctx context.Context, userID, id uuid.UUID) (bool, error)
+	UpsertConsent(ctx context.Context, consent *model.OAuthConsent) error
+	GetConsent(ctx context.Context, userID, clientID uuid.UUID) (*model.OAuthConsent, error)
+	GetConsentsByUserID(ctx context.Context, userID uuid.UUID) ([]model.OAuthConsent, error)
+	DeleteConsent(ctx context.Context, userID, clientID uuid.UUID) (bool, error)
+	CreateCode(ctx context.Context, code *model.OAuthAuthorizationCode, ttl time.Duration) error
+	ConsumeCode(ctx context.Context, codeHash []byte, clientID uuid.UUID) (*model.OAuthAuthorizationCode, error)
+	GetCode(ctx context.Context, codeHash []byte) (*model.OAuthAuthorizationCode, error)
+	CreateToken(ctx context.Context, token *model.OAuthToken, ttl time.Duration) error
+	GetToken(ctx context.Context, tokenHash []byte) (*model.OAuthToken, error)
+	ConsumeToken(ctx context.Context, tokenHash []byte, kind model.OAuthTokenKind, clientID uuid.UUID) (*model.OAuthToken, error)
+	DeleteToken(ctx context.Context, tokenHash []byte) error
+	DeleteGrant(ctx context.Context, grantID uuid.UUID) error
+	DeleteUserGrants(ctx context.Context, userID, clientID uuid.UUID) error
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// PostgresOAuthRepository implements OAuthRepository interface for PostgreSQL
+type PostgresOAuthRepository struct {
+	db *sqlx.DB
+}
+
+// NewOAuthRepository creates a new PostgresOAuthRepository instance
+func NewOAuthRepository(db *sqlx.DB) OAuthRepository {
+	return &PostgresOAuthRepository{db: db}
+}
+
+// CreateClient registers a client, generating its ID
+func (r *PostgresOAuthRepository) CreateClient(ctx context.Context, client *model.OAuthClient) error {
+	client.ID = uuid.New()
+
+	query := `
+		INSERT INTO oauth_clients (id, user_id, name, secret_hash, redirect_uris, scopes, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		RETURNING id, user_id, name, secret_hash, redirect_uris, scopes, created_at
+	`
+
+	return executor(ctx, r.db).GetContext(ctx, client, query,
+		client.ID, client.UserID, client.Name, client.SecretHash, client.RedirectURIs, client.Scopes)
+}
+
+// GetClient retrieves a client by its ID
+func (r *PostgresOAuthRepository) GetClient(ctx context.Context, id uuid.UUID) (*model.OAuthClient, error) {
+	query := `
+		SELECT id, user_id, name, secret_hash, redirect_uris, scopes, created_at
+		FROM oauth_clients
+		WHERE id = $1
+	`
+
+	var client model.OAuthClient
+	err := executor(ctx, r.db).GetContext(ctx, &client, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &client, nil
+}
+
+// GetClientsByUserID retrieves the clients a user registered, oldest first
+func (r *PostgresOAuthRepository) GetClientsByUserID(ctx context.Context, userID uuid.UUID) ([]model.OAuthClient, error) {
+	query := `
+		SELECT id, user_id, name, secret_hash, redirect_uris, scopes, created_at
+		FROM oauth_clients
+		WHERE user_id = $1
+		ORDER BY created_at, id
+	`
+
+	clients := []model.OAuthClient{}
+	err := executor(ctx, r.db).SelectContext(ctx, &clients, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return clients, nil
+}
+
+// DeleteClient removes a client a user registered, with its consents, codes and tokens,
+// reporting false when the user has no such client
+func (r *PostgresOAuthRepository) DeleteClient(ctx context.Context, userID, id uuid.UUID) (bool, error) {
+	query := `
+		DELETE FROM oauth_clients
+		WHERE id = $1 AND user_id = $2
+	`
+
+	result, err := executor(ctx, r.db).ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+// UpsertConsent records the scopes a user granted a client, replacing the ones granted before
+func (r *PostgresOAuthRepository) UpsertConsent(ctx context.Context, consent *model.OAuthConsent) error {
+	query := `
+		INSERT INTO oauth_consents (user_id, client_id, scopes, created_at, updated_at)
+		VALUES ($1, $2, $3, NOW(), NOW())
+		ON CONFLICT (user_id, client_id) DO UPDATE
+		SET scopes = EXCLUDED.scopes, updated_at = NOW()
+		RETURNING user_id, client_id, scopes, created_at, updated_at
+	`
+
+	return executor(ctx, r.db).GetContext(ctx, consent, query, consent.UserID, consent.ClientID, consent.Scopes)
+}
+
+// GetConsent retrieves the scopes a user granted a client
+func (r *PostgresOAuthRepository) GetConsent(ctx context.Context, userID, clientID uuid.UUID) (*model.OAuthConsent, error) {
+	query := `
+		SELECT c.user_id, c.client_id, c.scopes, c.created_at, c.updated_at, cl.name AS client_name
+		FROM oauth_consents c
+		JOIN oauth_clients cl ON cl.id = c.client_id
+		WHERE c.user_id = $1 AND c.client_id = $2
+	`
+
+	var consent model.OAuthConsent
+	err := executor(ctx, r.db).GetContext(ctx, &consent, query, userID, clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &consent, nil
+}
+
+// GetConsentsByUserID retrieves the clients a user granted access to, oldest first
+func (r *PostgresOAuthRepository) GetConsentsByUserID(ctx context.Context, userID uuid.UUID) ([]model.OAuthConsent, error) {
+	query := `
+		SELECT c.user_id, c.client_id, c.scopes, c.created_at, c.updated_at, cl.name AS client_name
+		FROM oauth_consents c
+		JOIN oauth_clients cl ON cl.id = c.client_id
+		WHERE c.user_id = $1
+		ORDER BY c.created_at, c.client_id
+	`
+
+	consents := []model.OAuthConsent{}
+	err := executor(ctx, r.db).SelectContext(ctx, &consents, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return consents, nil
+}
+
+// DeleteConsent removes the consent of a user to a client, reporting false when there is none
+func (r *PostgresOAuthRepository) DeleteConsent(ctx context.Context, userID, clientID uuid.UUID) (bool, error) {
+	query := `
+		DELETE FROM oauth_consents
+		WHERE user_id = $1 AND client_id = $2
+	`
+
+	result, err := executor(ctx, r.db).ExecContext(ctx, query, userID, clientID)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+// CreateCode inserts a new authorization code that expires after the ttl
+func (r *PostgresOAuthRepository) CreateCode(ctx context.Context, code *model.OAuthAuthorizationCode, ttl time.Duration) error {
+	query := `
+		INSERT INTO oauth_authorization_codes
+			(code_hash, grant_id, client_id, user_id, redirect_uri, scopes, code_challenge, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), NOW() + make_interval(secs => $8))
+		RETURNING code_hash, grant_id, client_id, user_id, redirect_uri, scopes, code_challenge, created_at, expires_at, used_at
+	`
+
+	return executor(ctx, r.db).GetContext(ctx, code, query,
+		code.CodeHash, code.GrantID, code.ClientID, code.UserID, code.RedirectURI, code.Scopes, code.CodeChallenge, ttl.Seconds())
+}
+
+// ConsumeCode marks the authorization code with the hash issued to the client as used, returning it
+// A code that was already used or has expired cannot be consumed, and sql.ErrNoRows is returned
+func (r *PostgresOAuthRepository) ConsumeCode(ctx context.Context, codeHash []byte, clientID uuid.UUID) (*model.OAuthAuthorizationCode, error) {
+	query := `
+		UPDATE oauth_authorization_codes
+		SET used_at = NOW()
+		WHERE code_hash = $1 AND client_id = $2 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING code_hash, grant_id, client_id, user_id, redirect_uri, scopes, code_challenge, created_at, expires_at, used_at
+	`
+
+	var code model.OAuthAuthorizationCode
+	err := executor(ctx, r.db).GetContext(ctx, &code, query, codeHash, clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &code, nil
+}
+
+// GetCode retrieves the authorization code with the hash, whether or not it was used or has expired
+func (r *PostgresOAuthRepository) GetCode(ctx context.Context, codeHash []byte) (*model.OAuthAuthorizationCode, error) {
+	query := `
+		SELECT code_hash, grant_id, client_id, user_id, redirect_uri, scopes, code_challenge, created_at, expires_at, used_at
+		FROM oauth_authorization_codes
+		WHERE code_hash = $1
+	`
+
+	var code model.OAuthAuthorizationCode
+	err := executor(ctx, r.db).GetContext(ctx, &code, query, codeHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return &code, nil
+}
+
+// CreateToken inserts a new access or refresh token that expires after the ttl
+func (r *PostgresOAuthRepository) CreateToken(ctx context.Context, token *model.OAuthToken, ttl time.Duration) error {
+	query := `
+		INSERT INTO oauth_tokens
+			(token_hash, kind, grant_id, client_id, user_id, scopes, session_version, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), NOW() + make_interval(secs => $8))
+		RETURNING token_hash, kind, grant_id, client_id, user_id, scopes, session_version, created_at, expires_at
+	`
+
+	return executor(ctx, r.db).GetContext(ctx, token, query,
+		token.TokenHash, token.Kind, token.GrantID, token.ClientID, token.UserID, token.Scopes, token.SessionVersion, ttl.Seconds())
+}
+
+// GetToken retrieves the token with the hash, whether or not it has expired
+func (r *PostgresOAuthRepository) GetToken(ctx context.Context, tokenHash []byte) (*model.OAuthToken, error) {
+	query := `
+		SELECT token_hash, kind, grant_id, client_id, user_id, scopes, session_version, created_at, expires_at
+		FROM oauth_tokens
+		WHERE token_hash = $1
+	`
+
+	var token model.OAuthToken
+	err := executor(ctx, r.db).GetContext(ctx, &token, query, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// ConsumeToken deletes the token of the kind with the hash issued to the client, returning it
+// A token that has expired cannot be consumed, and sql.ErrNoRows is returned
+func (r *PostgresOAuthRepository) ConsumeToken(ctx context.Context, tokenHash []byte, kind model.OAuthTokenKind, clientID uuid.UUID) (*model.OAuthToken, error) {
+	query := `
+		DELETE FROM oauth_tokens
+		WHERE token_hash = $1 AND kind = $2 AND client_id = $3 AND expires_at > NOW()
+		RETURNING token_hash, kind, grant_id, client_id, user_id, scopes, session_version, created_at, expires_at
+	`
+
+	var token model.OAuthToken
+	err := executor(ctx, r.db).GetContext(ctx, &token, query, tokenHash, kind, clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// DeleteToken removes the token with the hash
+func (r *PostgresOAuthRepository) DeleteToken(ctx context.Context, tokenHash []byte) error {
+	query := `
+		DELETE FROM oauth_tokens
+		WHERE token_hash = $1
+	`
+
+	_, err := executor(ctx, r.db).ExecContext(ctx, query, tokenHash)
+	return err
+}
+
+// DeleteGrant removes all the tokens issued for an authorization
+func (r *PostgresOAuthRepository) DeleteGrant(ctx context.Context, grantID uuid.UUID) error {
+	query := `
+		DELETE FROM oauth_tokens
+		WHERE grant_id = $1
+	`
+
+	_, err := executor(ctx, r.db).ExecContext(ctx, query, grantID)
+	return err
+}
+
+// DeleteUserGrants removes the authorization codes and tokens a user granted a client
+func (r *PostgresOAuthRepository) DeleteUserGrants(ctx context.Context, userID, clientID uuid.UUID) error {
+	codeQuery := `
+		DELETE FROM oauth_authorization_codes
+		WHERE user_id = $1 AND client_id = $2
+	`
+	if _, err := executor(ctx, r.db).ExecContext(ctx, codeQuery, userID, clientID); err != nil {
+		return err
+	}
+
+	tokenQuery := `
+		DELETE FROM oauth_tokens
+		WHERE user_id = $1 AND client_id = $2
+	`
+	_, err := executor(ctx, r.db).ExecContext(ctx, tokenQuery, userID, clientID)
+	return err
+}
+
+// DeleteExpired removes the authorization codes and tokens that have expired and returns how many were removed
+func (r *PostgresOAuthRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	var purged int64
+	for _, query := range []string{
+		`DELETE FROM oauth_authorization_codes WHERE expires_at <= NOW()`,
+		`DELETE FROM oauth_tokens WHERE expires_at <= NOW()`,
+	} {
+		result, err := executor(ctx, r.db).ExecContext(ctx, query)
+		if err != nil {
+			return purged, err
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return purged, err
+		}
+		purged += rows
+	}
+
+	return purged, nil
+}
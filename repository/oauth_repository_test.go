@@ -0,0 +1,161 @@
+package repository_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+)
+
+func TestOAuthRepository(t *testing.T) {
+	userRepo := repository.NewUserRepository(testDB)
+	oauthRepo := repository.NewOAuthRepository(testDB)
+	ctx := context.Background()
+
+	owner := &model.User{Email: "oauth-owner@example.com", PasswordHash: "hashedpassword"}
+	require.NoError(t, userRepo.Create(ctx, owner))
+	user := &model.User{Email: "oauth-user@example.com", PasswordHash: "hashedpassword"}
+	require.NoError(t, userRepo.Create(ctx, user))
+
+	// Test CreateClient and GetClient
+	secretHash := sha256.Sum256([]byte("secret"))
+	client := &model.OAuthClient{
+		UserID:       owner.ID,
+		Name:         "Test Client",
+		SecretHash:   secretHash[:],
+		RedirectURIs: []string{"https://client.example.com/callback"},
+		Scopes:       model.OAuthScopes,
+	}
+	require.NoError(t, oauthRepo.CreateClient(ctx, client))
+	assert.NotEqual(t, uuid.Nil, client.ID)
+	assert.True(t, client.Confidential())
+
+	stored, err := oauthRepo.GetClient(ctx, client.ID)
+	require.NoError(t, err)
+	assert.Equal(t, client.Name, stored.Name)
+	assert.Equal(t, client.RedirectURIs, stored.RedirectURIs)
+	assert.Equal(t, secretHash[:], stored.SecretHash)
+
+	public := &model.OAuthClient{UserID: owner.ID, Name: "Public Client", RedirectURIs: []string{"http://127.0.0.1/callback"}, Scopes: []string{model.ScopeTodosRead}}
+	require.NoError(t, oauthRepo.CreateClient(ctx, public))
+	assert.False(t, public.Confidential())
+
+	// Test GetClientsByUserID lists the clients of the owner only
+	clients, err := oauthRepo.GetClientsByUserID(ctx, owner.ID)
+	require.NoError(t, err)
+	require.Len(t, clients, 2)
+	assert.Equal(t, client.ID, clients[0].ID)
+	clients, err = oauthRepo.GetClientsByUserID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, clients)
+
+	// Test UpsertConsent replaces the scopes, and GetConsent joins the client name
+	consent := &model.OAuthConsent{UserID: user.ID, ClientID: client.ID, Scopes: []string{model.ScopeTodosRead}}
+	require.NoError(t, oauthRepo.UpsertConsent(ctx, consent))
+	consent.Scopes = model.OAuthScopes
+	require.NoError(t, oauthRepo.UpsertConsent(ctx, consent))
+	storedConsent, err := oauthRepo.GetConsent(ctx, user.ID, client.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.OAuthScopes, []string(storedConsent.Scopes))
+	assert.Equal(t, "Test Client", storedConsent.ClientName)
+	consents, err := oauthRepo.GetConsentsByUserID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Len(t, consents, 1)
+
+	// Test ConsumeCode uses a code once, for the client it was issued to
+	codeHash := sha256.Sum256([]byte("code"))
+	grantID := uuid.New()
+	code := &model.OAuthAuthorizationCode{
+		CodeHash:      codeHash[:],
+		GrantID:       grantID,
+		ClientID:      client.ID,
+		UserID:        user.ID,
+		RedirectURI:   "https://client.example.com/callback",
+		Scopes:        []string{model.ScopeTodosRead},
+		CodeChallenge: "challenge",
+	}
+	require.NoError(t, oauthRepo.CreateCode(ctx, code, time.Minute))
+	_, err = oauthRepo.ConsumeCode(ctx, codeHash[:], public.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	consumed, err := oauthRepo.ConsumeCode(ctx, codeHash[:], client.ID)
+	require.NoError(t, err)
+	assert.Equal(t, grantID, consumed.GrantID)
+	assert.NotNil(t, consumed.UsedAt)
+	_, err = oauthRepo.ConsumeCode(ctx, codeHash[:], client.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	used, err := oauthRepo.GetCode(ctx, codeHash[:])
+	require.NoError(t, err)
+	assert.NotNil(t, used.UsedAt)
+
+	// Test CreateToken, GetToken and ConsumeToken
+	accessHash := sha256.Sum256([]byte("access"))
+	refreshHash := sha256.Sum256([]byte("refresh"))
+	for _, token := range []*model.OAuthToken{
+		{TokenHash: accessHash[:], Kind: model.OAuthAccessToken, GrantID: grantID, ClientID: client.ID, UserID: user.ID, Scopes: []string{model.ScopeTodosRead}},
+		{TokenHash: refreshHash[:], Kind: model.OAuthRefreshToken, GrantID: grantID, ClientID: client.ID, UserID: user.ID, Scopes: []string{model.ScopeTodosRead}},
+	} {
+		require.NoError(t, oauthRepo.CreateToken(ctx, token, time.Hour))
+		assert.True(t, token.ExpiresAt.After(token.CreatedAt))
+	}
+	access, err := oauthRepo.GetToken(ctx, accessHash[:])
+	require.NoError(t, err)
+	assert.Equal(t, model.OAuthAccessToken, access.Kind)
+
+	_, err = oauthRepo.ConsumeToken(ctx, accessHash[:], model.OAuthRefreshToken, client.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	refresh, err := oauthRepo.ConsumeToken(ctx, refreshHash[:], model.OAuthRefreshToken, client.ID)
+	require.NoError(t, err)
+	assert.Equal(t, grantID, refresh.GrantID)
+	_, err = oauthRepo.GetToken(ctx, refreshHash[:])
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	// Test DeleteGrant revokes the tokens of the grant
+	require.NoError(t, oauthRepo.DeleteGrant(ctx, grantID))
+	_, err = oauthRepo.GetToken(ctx, accessHash[:])
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	// Test DeleteUserGrants revokes the codes and tokens a user granted the client
+	require.NoError(t, oauthRepo.CreateToken(ctx, &model.OAuthToken{TokenHash: accessHash[:], Kind: model.OAuthAccessToken, GrantID: uuid.New(), ClientID: client.ID, UserID: user.ID, Scopes: []string{model.ScopeTodosRead}}, time.Hour))
+	require.NoError(t, oauthRepo.DeleteUserGrants(ctx, user.ID, client.ID))
+	_, err = oauthRepo.GetToken(ctx, accessHash[:])
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	_, err = oauthRepo.GetCode(ctx, codeHash[:])
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	// Test expired codes and tokens cannot be used and are purged
+	expiredHash := sha256.Sum256([]byte("expired"))
+	require.NoError(t, oauthRepo.CreateToken(ctx, &model.OAuthToken{TokenHash: expiredHash[:], Kind: model.OAuthRefreshToken, GrantID: uuid.New(), ClientID: client.ID, UserID: user.ID, Scopes: []string{model.ScopeTodosRead}}, -time.Minute))
+	_, err = oauthRepo.ConsumeToken(ctx, expiredHash[:], model.OAuthRefreshToken, client.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	require.NoError(t, oauthRepo.CreateCode(ctx, &model.OAuthAuthorizationCode{CodeHash: expiredHash[:], GrantID: uuid.New(), ClientID: client.ID, UserID: user.ID, RedirectURI: "https://client.example.com/callback", Scopes: []string{model.ScopeTodosRead}, CodeChallenge: "challenge"}, -time.Minute))
+	_, err = oauthRepo.ConsumeCode(ctx, expiredHash[:], client.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	purged, err := oauthRepo.DeleteExpired(ctx)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, purged, int64(2))
+
+	// Test DeleteConsent
+	deleted, err := oauthRepo.DeleteConsent(ctx, user.ID, client.ID)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	deleted, err = oauthRepo.DeleteConsent(ctx, user.ID, client.ID)
+	require.NoError(t, err)
+	assert.False(t, deleted)
+
+	// Test DeleteClient only removes a client of the user
+	deleted, err = oauthRepo.DeleteClient(ctx, user.ID, client.ID)
+	require.NoError(t, err)
+	assert.False(t, deleted)
+	deleted, err = oauthRepo.DeleteClient(ctx, owner.ID, client.ID)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	_, err = oauthRepo.GetClient(ctx, client.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
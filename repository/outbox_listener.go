@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// outboxChannel is the channel the outbox notifies committed events on
+const outboxChannel = "outbox_events"
+
+// outboxListenerPingInterval is how often an idle listener checks its connection is alive
+const outboxListenerPingInterval = time.Minute
+
+// ListenOutbox listens for outbox events committed by any instance sharing the database
+// The returned channel receives the sequence of each event, and 0 after the connection has been
+// re-established, when notifications may have been missed. It is closed when ctx is cancelled
+func ListenOutbox(ctx context.Context, dsn string) (<-chan int64, error) {
+	listener := pq.NewListener(dsn, time.Second, time.Minute, nil)
+	if err := listener.Listen(outboxChannel); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	sequences := make(chan int64, 256)
+	go func() {
+		defer close(sequences)
+		defer listener.Close()
+
+		ticker := time.NewTicker(outboxListenerPingInterval)
+		defer ticker.Stop()
+		for {
+			var sequence int64
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// A failed ping makes the listener reconnect
+				go listener.Ping()
+				continue
+			case notification := <-listener.Notify:
+				// A nil notification is sent after reconnecting
+				if notification != nil {
+					parsed, err := strconv.ParseInt(notification.Extra, 10, 64)
+					if err != nil {
+						continue
+					}
+					sequence = parsed
+				}
+			}
+
+			select {
+			case sequences <- sequence:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return sequences, nil
+}
@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/yukimaterrace/todoms/model"
+)
+
+// OutboxRepository defines the interface for transactional outbox operations
+type OutboxRepository interface {
+	Create(ctx context.Context, event *model.OutboxEvent) error
+	ClaimPending(ctx context.Context, limit int) ([]model.OutboxEvent, error)
+	MarkPublished(ctx context.Context, id uuid.UUID) error
+	MarkFailed(ctx context.Context, id uuid.UUID, retryAfter time.Duration, lastError string) error
+	DeletePublishedOlderThan(ctx context.Context, age time.Duration) (int64, error)
+	GetPrunedSequence(ctx context.Context) (int64, error)
+	GetBySequence(ctx context.Context, sequence int64) (*model.OutboxEvent, error)
+	GetAfter(ctx context.Context, afterSequence int64, limit int) ([]model.OutboxEvent, error)
+	GetByAudienceAfter(ctx context.Context, userID uuid.UUID, afterSequence int64, limit int) ([]model.OutboxEvent, error)
+}
+
+// selectOutboxQuery selects outbox events with all their columns
+const selectOutboxQuery = `
+		SELECT o.id, o.sequence, o.aggregate_type, o.aggregate_id, o.event_type, o.payload, o.attempts,
+			o.next_attempt_at, o.last_error, o.created_at, o.published_at, o.audience
+		FROM outbox o
+`
+
+// PostgresOutboxRepository implements OutboxRepository interface for PostgreSQL
+type PostgresOutboxRepository struct {
+	db *sqlx.DB
+}
+
+// NewOutboxRepository creates a new PostgresOutboxRepository instance
+func NewOutboxRepository(db *sqlx.DB) OutboxRepository {
+	return &PostgresOutboxRepository{db: db}
+}
+
+// Create inserts a new event into the outbox
+// It must be called with the context of the transaction making the change the event describes
+func (r *PostgresOutboxRepository) Create(ctx context.Context, event *model.OutboxEvent) error {
+	if event.ID == uuid.Nil {
+		event.ID = uuid.New()
+	}
+
+	query := `
+		INSERT INTO outbox (id, aggregate_type, aggregate_id, event_type, payload, audience, next_attempt_at, created_at)
+		VALUES (:id, :aggregate_type, :aggregate_id, :event_type, :payload, :audience, NOW(), NOW())
+	`
+
+	_, err := executor(ctx, r.db).NamedExecContext(ctx, query, event)
+	return err
+}
+
+// ClaimPending locks the oldest unpublished events that are due, skipping those locked by other relays
+// Only the earliest unpublished event of each aggregate is returned, so events of one aggregate are
+// published in the order they were written even while an earlier one is waiting to be retried
+// It must be called inside a transaction, which holds the locks until the results are recorded
+func (r *PostgresOutboxRepository) ClaimPending(ctx context.Context, limit int) ([]model.OutboxEvent, error) {
+	query := selectOutboxQuery + `
+		WHERE o.published_at IS NULL
+			AND o.next_attempt_at <= NOW()
+			AND NOT EXISTS (
+				SELECT 1
+				FROM outbox e
+				WHERE e.aggregate_id = o.aggregate_id AND e.published_at IS NULL AND e.sequence < o.sequence
+			)
+		ORDER BY o.sequence
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`
+
+	var events []model.OutboxEvent
+	err := executor(ctx, r.db).SelectContext(ctx, &events, query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// MarkPublished records that an event has been published
+func (r *PostgresOutboxRepository) MarkPublished(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE outbox
+		SET published_at = NOW(), last_error = NULL
+		WHERE id = $1
+	`
+
+	_, err := executor(ctx, r.db).ExecContext(ctx, query, id)
+	return err
+}
+
+// MarkFailed records a failed attempt to publish an event, to be tried again after the specified delay
+func (r *PostgresOutboxRepository) MarkFailed(ctx context.Context, id uuid.UUID, retryAfter time.Duration, lastError string) error {
+	query := `
+		UPDATE outbox
+		SET attempts = attempts + 1, next_attempt_at = NOW() + make_interval(secs => $2), last_error = $3
+		WHERE id = $1
+	`
+
+	_, err := executor(ctx, r.db).ExecContext(ctx, query, id, retryAfter.Seconds(), lastError)
+	return err
+}
+
+// DeletePublishedOlderThan prunes events published longer ago than the specified age and returns how many were removed
+// The highest sequence pruned is recorded, see GetPrunedSequence
+func (r *PostgresOutboxRepository) DeletePublishedOlderThan(ctx context.Context, age time.Duration) (int64, error) {
+	query := `
+		WITH deleted AS (
+			DELETE FROM outbox
+			WHERE published_at < NOW() - make_interval(secs => $1)
+			RETURNING sequence
+		), watermark AS (
+			UPDATE outbox_prune_watermark
+			SET sequence = GREATEST(sequence, (SELECT MAX(sequence) FROM deleted))
+			WHERE EXISTS (SELECT 1 FROM deleted)
+		)
+		SELECT COUNT(*) FROM deleted
+	`
+
+	var deleted int64
+	if err := executor(ctx, r.db).GetContext(ctx, &deleted, query, age.Seconds()); err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+// GetPrunedSequence returns the highest sequence of the events pruned so far
+// Events after an older sequence may be missing, as events are not pruned in the order of their sequences
+func (r *PostgresOutboxRepository) GetPrunedSequence(ctx context.Context) (int64, error) {
+	query := `
+		SELECT sequence FROM outbox_prune_watermark
+	`
+
+	var sequence int64
+	if err := executor(ctx, r.db).GetContext(ctx, &sequence, query); err != nil {
+		return 0, err
+	}
+	return sequence, nil
+}
+
+// GetBySequence retrieves an event by its sequence
+func (r *PostgresOutboxRepository) GetBySequence(ctx context.Context, sequence int64) (*model.OutboxEvent, error) {
+	query := selectOutboxQuery + `
+		WHERE o.sequence = $1
+	`
+
+	var event model.OutboxEvent
+	err := executor(ctx, r.db).GetContext(ctx, &event, query, sequence)
+	if err != nil {
+		return nil, err
+	}
+
+	return &event, nil
+}
+
+// GetAfter retrieves the events written after the specified sequence, oldest first
+func (r *PostgresOutboxRepository) GetAfter(ctx context.Context, afterSequence int64, limit int) ([]model.OutboxEvent, error) {
+	query := selectOutboxQuery + `
+		WHERE o.sequence > $1
+		ORDER BY o.sequence
+		LIMIT $2
+	`
+
+	var events []model.OutboxEvent
+	err := executor(ctx, r.db).SelectContext(ctx, &events, query, afterSequence, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// GetByAudienceAfter retrieves the events a user can see written after the specified sequence, oldest first
+func (r *PostgresOutboxRepository) GetByAudienceAfter(ctx context.Context, userID uuid.UUID, afterSequence int64, limit int) ([]model.OutboxEvent, error) {
+	query := selectOutboxQuery + `
+		WHERE o.audience @> ARRAY[$1::uuid] AND o.sequence > $2
+		ORDER BY o.sequence
+		LIMIT $3
+	`
+
+	var events []model.OutboxEvent
+	err := executor(ctx, r.db).SelectContext(ctx, &events, query, userID, afterSequence, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
@@ -0,0 +1,212 @@
+package repository_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+)
+
+// claimOutboxEvents claims pending events and keeps those of the specified aggregates
+// Other tests write to the outbox too, so their events are filtered out
+func claimOutboxEvents(t *testing.T, ctx context.Context, outboxRepo repository.OutboxRepository, aggregateIDs ...uuid.UUID) []model.OutboxEvent {
+	events, err := outboxRepo.ClaimPending(ctx, 1000)
+	require.NoError(t, err)
+
+	var filtered []model.OutboxEvent
+	for _, event := range events {
+		for _, id := range aggregateIDs {
+			if event.AggregateID == id {
+				filtered = append(filtered, event)
+			}
+		}
+	}
+	return filtered
+}
+
+func TestOutboxRepository(t *testing.T) {
+	userRepo := repository.NewUserRepository(testDB)
+	todoRepo := repository.NewTodoRepository(testDB)
+	outboxRepo := repository.NewOutboxRepository(testDB)
+	transactor := repository.NewTransactor(testDB)
+	ctx := context.Background()
+
+	user := &model.User{Email: "outbox-test@example.com", PasswordHash: "hashedpassword"}
+	require.NoError(t, userRepo.Create(ctx, user))
+
+	// Todo writes record events in the outbox
+	first := &model.Todo{UserID: user.ID, Title: "First Todo"}
+	require.NoError(t, todoRepo.Create(ctx, first))
+	first.Title = "First Todo Updated"
+	require.NoError(t, todoRepo.Update(ctx, first))
+	second := &model.Todo{UserID: user.ID, Title: "Second Todo"}
+	require.NoError(t, todoRepo.Create(ctx, second))
+
+	// A rolled back write leaves no event behind
+	rolledBack := &model.Todo{UserID: user.ID, Title: "Rolled Back Todo"}
+	err := transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := todoRepo.Create(ctx, rolledBack); err != nil {
+			return err
+		}
+		return errors.New("abort")
+	})
+	assert.EqualError(t, err, "abort")
+
+	// Only the earliest unpublished event of each aggregate is claimed
+	events := claimOutboxEvents(t, ctx, outboxRepo, first.ID, second.ID, rolledBack.ID)
+	require.Len(t, events, 2)
+	assert.Equal(t, first.ID, events[0].AggregateID)
+	assert.Equal(t, model.DomainEventTodoCreated, events[0].EventType)
+	assert.Equal(t, model.AggregateTodo, events[0].AggregateType)
+	assert.Equal(t, second.ID, events[1].AggregateID)
+
+	var data model.TodoEventData
+	require.NoError(t, json.Unmarshal(events[0].Payload, &data))
+	assert.Equal(t, "First Todo", data.Title)
+	assert.Equal(t, user.ID.String(), data.UserID)
+
+	// Events locked by one relay are skipped by another
+	err = transactor.WithinTransaction(ctx, func(txCtx context.Context) error {
+		locked := claimOutboxEvents(t, txCtx, outboxRepo, first.ID, second.ID)
+		require.Len(t, locked, 2)
+
+		other := claimOutboxEvents(t, context.Background(), outboxRepo, first.ID, second.ID)
+		assert.Empty(t, other)
+		return nil
+	})
+	require.NoError(t, err)
+
+	// A failed event is not claimed before its retry, and holds back the later events of its aggregate
+	require.NoError(t, outboxRepo.MarkFailed(ctx, events[0].ID, time.Hour, "broker unavailable"))
+	require.NoError(t, outboxRepo.MarkPublished(ctx, events[1].ID))
+	assert.Empty(t, claimOutboxEvents(t, ctx, outboxRepo, first.ID, second.ID))
+
+	// Once published, the next event of the aggregate is claimed
+	require.NoError(t, outboxRepo.MarkPublished(ctx, events[0].ID))
+	events = claimOutboxEvents(t, ctx, outboxRepo, first.ID, second.ID)
+	require.Len(t, events, 1)
+	assert.Equal(t, model.DomainEventTodoUpdated, events[0].EventType)
+	require.NoError(t, json.Unmarshal(events[0].Payload, &data))
+	assert.Equal(t, "First Todo Updated", data.Title)
+	require.NoError(t, outboxRepo.MarkPublished(ctx, events[0].ID))
+
+	// Deleting a todo records its last state
+	require.NoError(t, todoRepo.Delete(ctx, second.ID))
+	events = claimOutboxEvents(t, ctx, outboxRepo, second.ID)
+	require.Len(t, events, 1)
+	assert.Equal(t, model.DomainEventTodoDeleted, events[0].EventType)
+	require.NoError(t, json.Unmarshal(events[0].Payload, &data))
+	assert.Equal(t, "Second Todo", data.Title)
+
+	// Events are readable by sequence and by the users in their audience
+	byID, err := outboxRepo.GetBySequence(ctx, events[0].Sequence)
+	require.NoError(t, err)
+	assert.Equal(t, events[0].ID, byID.ID)
+	assert.Equal(t, []string{user.ID.String()}, []string(byID.Audience))
+
+	after, err := outboxRepo.GetAfter(ctx, events[0].Sequence-1, 10)
+	require.NoError(t, err)
+	require.NotEmpty(t, after)
+	assert.Equal(t, events[0].ID, after[0].ID)
+
+	// Published events are pruned after their retention
+	_, err = testDB.ExecContext(ctx, "UPDATE outbox SET published_at = NOW() - INTERVAL '2 hours' WHERE aggregate_id = $1", first.ID)
+	require.NoError(t, err)
+	var lastPruned int64
+	require.NoError(t, testDB.GetContext(ctx, &lastPruned, "SELECT MAX(sequence) FROM outbox WHERE aggregate_id = $1", first.ID))
+	count, err := outboxRepo.DeletePublishedOlderThan(ctx, time.Hour)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, count, int64(2))
+
+	// The highest sequence pruned is kept, so clients resuming from an older event can be told to reload
+	pruned, err := outboxRepo.GetPrunedSequence(ctx)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, pruned, lastPruned)
+
+	// An update that completes a todo records a completed event after the updated event
+	third := &model.Todo{UserID: user.ID, Title: "Third Todo"}
+	require.NoError(t, todoRepo.Create(ctx, third))
+	third.IsCompleted = true
+	require.NoError(t, todoRepo.Update(ctx, third))
+	third.Title = "Third Todo Updated"
+	require.NoError(t, todoRepo.Update(ctx, third))
+
+	var eventTypes []string
+	for {
+		events = claimOutboxEvents(t, ctx, outboxRepo, third.ID)
+		if len(events) == 0 {
+			break
+		}
+		eventTypes = append(eventTypes, events[0].EventType)
+		require.NoError(t, outboxRepo.MarkPublished(ctx, events[0].ID))
+	}
+	assert.Equal(t, []string{
+		model.DomainEventTodoCreated,
+		model.DomainEventTodoUpdated,
+		model.DomainEventTodoCompleted,
+		model.DomainEventTodoUpdated,
+	}, eventTypes)
+}
+
+func TestOutboxStream(t *testing.T) {
+	userRepo := repository.NewUserRepository(testDB)
+	todoRepo := repository.NewTodoRepository(testDB)
+	shareRepo := repository.NewTodoShareRepository(testDB)
+	outboxRepo := repository.NewOutboxRepository(testDB)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	owner := &model.User{Email: "stream-owner@example.com", PasswordHash: "hashedpassword"}
+	viewer := &model.User{Email: "stream-viewer@example.com", PasswordHash: "hashedpassword"}
+	for _, user := range []*model.User{owner, viewer} {
+		require.NoError(t, userRepo.Create(ctx, user))
+	}
+
+	sequences, err := repository.ListenOutbox(ctx, testDSN)
+	require.NoError(t, err)
+
+	todo := &model.Todo{UserID: owner.ID, Title: "Streamed Todo"}
+	require.NoError(t, todoRepo.Create(ctx, todo))
+	require.NoError(t, shareRepo.Upsert(ctx, &model.TodoShare{TodoID: todo.ID, UserID: viewer.ID, Role: model.ShareRoleViewer}))
+	todo.Title = "Streamed Todo Updated"
+	require.NoError(t, todoRepo.Update(ctx, todo))
+
+	// The audience includes the users the todo was shared with at the time of the event
+	ownerEvents, err := outboxRepo.GetByAudienceAfter(ctx, owner.ID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, ownerEvents, 2)
+	viewerEvents, err := outboxRepo.GetByAudienceAfter(ctx, viewer.ID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, viewerEvents, 1)
+	assert.Equal(t, model.DomainEventTodoUpdated, viewerEvents[0].EventType)
+
+	// Resuming after an event returns only the later ones
+	resumed, err := outboxRepo.GetByAudienceAfter(ctx, owner.ID, ownerEvents[0].Sequence, 10)
+	require.NoError(t, err)
+	require.Len(t, resumed, 1)
+	assert.Equal(t, ownerEvents[1].ID, resumed[0].ID)
+
+	// Committed events are notified with their sequence
+	notified := map[int64]bool{}
+	timeout := time.After(5 * time.Second)
+	for !notified[ownerEvents[0].Sequence] || !notified[ownerEvents[1].Sequence] {
+		select {
+		case sequence := <-sequences:
+			notified[sequence] = true
+		case <-timeout:
+			require.FailNow(t, "outbox events were not notified")
+		}
+	}
+
+	// The channel is closed when the context is cancelled
+	cancel()
+	for range sequences {
+	}
+}
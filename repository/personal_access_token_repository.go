@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/yukimaterrace/todoms/model"
+)
+
+// PersonalAccessTokenRepository defines the interface for personal access token operations
+type PersonalAccessTokenRepository interface {
+	Create(ctx context.Context, token *model.PersonalAccessToken) error
+	GetByHash(ctx context.Context, tokenHash []byte) (*model.PersonalAccessToken, error)
+	GetByUserID(ctx context.Context, userID uuid.UUID) ([]model.PersonalAccessToken, error)
+	CountByUserIDForUpdate(ctx context.Context, userID uuid.UUID) (int, error)
+	Delete(ctx context.Context, userID, id uuid.UUID) (bool, error)
+	UpdateLastUsed(ctx context.Context, id uuid.UUID, interval time.Duration) error
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// PostgresPersonalAccessTokenRepository implements PersonalAccessTokenRepository interface for PostgreSQL
+type PostgresPersonalAccessTokenRepository struct {
+	db *sqlx.DB
+}
+
+// NewPersonalAccessTokenRepository creates a new PostgresPersonalAccessTokenRepository instance
+func NewPersonalAccessTokenRepository(db *sqlx.DB) PersonalAccessTokenRepository {
+	return &PostgresPersonalAccessTokenRepository{db: db}
+}
+
+// Create inserts a new token, generating its ID
+// The token records the current session version of its user, so a later password change revokes it
+func (r *PostgresPersonalAccessTokenRepository) Create(ctx context.Context, token *model.PersonalAccessToken) error {
+	token.ID = uuid.New()
+
+	query := `
+		INSERT INTO personal_access_tokens (id, user_id, name, token_hash, scopes, session_version, created_at, expires_at)
+		SELECT $1, $2, $3, $4, $5, u.session_version, NOW(), $6
+		FROM users u
+		WHERE u.id = $2
+		RETURNING id, user_id, name, token_hash, scopes, session_version, created_at, expires_at, last_used_at
+	`
+
+	return executor(ctx, r.db).GetContext(ctx, token, query,
+		token.ID, token.UserID, token.Name, token.TokenHash, token.Scopes, token.ExpiresAt)
+}
+
+// GetByHash retrieves the token with the hash, whether or not it has expired
+func (r *PostgresPersonalAccessTokenRepository) GetByHash(ctx context.Context, tokenHash []byte) (*model.PersonalAccessToken, error) {
+	query := `
+		SELECT id, user_id, name, token_hash, scopes, session_version, created_at, expires_at, last_used_at
+		FROM personal_access_tokens
+		WHERE token_hash = $1
+	`
+
+	var token model.PersonalAccessToken
+	err := executor(ctx, r.db).GetContext(ctx, &token, query, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// GetByUserID retrieves the tokens of a user, oldest first
+func (r *PostgresPersonalAccessTokenRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]model.PersonalAccessToken, error) {
+	query := `
+		SELECT id, user_id, name, token_hash, scopes, session_version, created_at, expires_at, last_used_at
+		FROM personal_access_tokens
+		WHERE user_id = $1
+		ORDER BY created_at, id
+	`
+
+	tokens := []model.PersonalAccessToken{}
+	err := executor(ctx, r.db).SelectContext(ctx, &tokens, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// CountByUserIDForUpdate returns the number of tokens of a user
+// It locks the user until the transaction ends, so a token can be created against the count without racing another
+func (r *PostgresPersonalAccessTokenRepository) CountByUserIDForUpdate(ctx context.Context, userID uuid.UUID) (int, error) {
+	if err := lockUser(ctx, r.db, userID); err != nil {
+		return 0, err
+	}
+
+	query := `
+		SELECT COUNT(*)
+		FROM personal_access_tokens
+		WHERE user_id = $1
+	`
+
+	var count int
+	err := executor(ctx, r.db).GetContext(ctx, &count, query, userID)
+	return count, err
+}
+
+// Delete removes a token of a user, reporting false when the user has no such token
+func (r *PostgresPersonalAccessTokenRepository) Delete(ctx context.Context, userID, id uuid.UUID) (bool, error) {
+	query := `
+		DELETE FROM personal_access_tokens
+		WHERE id = $1 AND user_id = $2
+	`
+
+	result, err := executor(ctx, r.db).ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+// UpdateLastUsed records that a token was used now, unless its last use was recorded within the interval
+func (r *PostgresPersonalAccessTokenRepository) UpdateLastUsed(ctx context.Context, id uuid.UUID, interval time.Duration) error {
+	query := `
+		UPDATE personal_access_tokens
+		SET last_used_at = NOW()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at <= NOW() - make_interval(secs => $2))
+	`
+
+	_, err := executor(ctx, r.db).ExecContext(ctx, query, id, interval.Seconds())
+	return err
+}
+
+// DeleteExpired removes the tokens that have expired and returns how many were removed
+func (r *PostgresPersonalAccessTokenRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	query := `
+		DELETE FROM personal_access_tokens
+		WHERE expires_at <= NOW()
+	`
+
+	result, err := executor(ctx, r.db).ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,83 @@
+package repository_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+)
+
+func TestPersonalAccessTokenRepository(t *testing.T) {
+	userRepo := repository.NewUserRepository(testDB)
+	tokenRepo := repository.NewPersonalAccessTokenRepository(testDB)
+	ctx := context.Background()
+
+	user := &model.User{Email: "pat@example.com", PasswordHash: "hashedpassword"}
+	require.NoError(t, userRepo.Create(ctx, user))
+	otherUser := &model.User{Email: "pat-other@example.com", PasswordHash: "hashedpassword"}
+	require.NoError(t, userRepo.Create(ctx, otherUser))
+
+	// Test Create and GetByHash
+	tokenHash := sha256.Sum256([]byte("token"))
+	token := &model.PersonalAccessToken{UserID: user.ID, Name: "CI", TokenHash: tokenHash[:], Scopes: []string{model.ScopeTodosRead}}
+	require.NoError(t, tokenRepo.Create(ctx, token))
+	assert.NotEqual(t, uuid.Nil, token.ID)
+	assert.Nil(t, token.ExpiresAt)
+	assert.Nil(t, token.LastUsedAt)
+
+	stored, err := tokenRepo.GetByHash(ctx, tokenHash[:])
+	require.NoError(t, err)
+	assert.Equal(t, token.ID, stored.ID)
+	assert.Equal(t, []string{model.ScopeTodosRead}, []string(stored.Scopes))
+	assert.Equal(t, user.SessionVersion, stored.SessionVersion)
+	unknownHash := sha256.Sum256([]byte("unknown"))
+	_, err = tokenRepo.GetByHash(ctx, unknownHash[:])
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	// Test UpdateLastUsed records a use once in the interval
+	require.NoError(t, tokenRepo.UpdateLastUsed(ctx, token.ID, time.Minute))
+	stored, err = tokenRepo.GetByHash(ctx, tokenHash[:])
+	require.NoError(t, err)
+	require.NotNil(t, stored.LastUsedAt)
+	firstUse := *stored.LastUsedAt
+	require.NoError(t, tokenRepo.UpdateLastUsed(ctx, token.ID, time.Minute))
+	stored, err = tokenRepo.GetByHash(ctx, tokenHash[:])
+	require.NoError(t, err)
+	assert.Equal(t, firstUse, *stored.LastUsedAt)
+
+	// Test GetByUserID and CountByUserIDForUpdate include expired tokens until they are purged
+	expiredHash := sha256.Sum256([]byte("expired"))
+	expiresAt := time.Now().Add(-time.Minute)
+	require.NoError(t, tokenRepo.Create(ctx, &model.PersonalAccessToken{UserID: user.ID, Name: "Old", TokenHash: expiredHash[:], Scopes: model.OAuthScopes, ExpiresAt: &expiresAt}))
+	tokens, err := tokenRepo.GetByUserID(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, tokens, 2)
+	assert.Equal(t, token.ID, tokens[0].ID)
+	count, err := tokenRepo.CountByUserIDForUpdate(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	purged, err := tokenRepo.DeleteExpired(ctx)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, purged, int64(1))
+	count, err = tokenRepo.CountByUserIDForUpdate(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	// Test Delete only removes a token of the user
+	deleted, err := tokenRepo.Delete(ctx, otherUser.ID, token.ID)
+	require.NoError(t, err)
+	assert.False(t, deleted)
+	deleted, err = tokenRepo.Delete(ctx, user.ID, token.ID)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	_, err = tokenRepo.GetByHash(ctx, tokenHash[:])
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/yukimaterrace/todoms/model"
+)
+
+// PostgresRateLimitStore implements RateLimitStore interface for PostgreSQL
+type PostgresRateLimitStore struct {
+	db *sqlx.DB
+}
+
+// NewPostgresRateLimitStore creates a new PostgresRateLimitStore instance
+func NewPostgresRateLimitStore(db *sqlx.DB) RateLimitStore {
+	return &PostgresRateLimitStore{db: db}
+}
+
+// Take takes a token from the bucket stored under the key, creating a full bucket if there is none
+// The bucket is only updated when a token is taken, so a refused request does not delay the refill
+func (s *PostgresRateLimitStore) Take(ctx context.Context, key string, limit model.RateLimit) (*model.RateLimitResult, error) {
+	query := `
+		INSERT INTO rate_limit_buckets AS b (bucket_key, tokens, updated_at, full_at)
+		VALUES ($1, $2::DOUBLE PRECISION - 1, NOW(), NOW() + make_interval(secs => 1 / $3::DOUBLE PRECISION))
+		ON CONFLICT (bucket_key) DO UPDATE
+		SET tokens = LEAST($2::DOUBLE PRECISION, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated_at) * $3::DOUBLE PRECISION) - 1,
+			updated_at = NOW(),
+			full_at = NOW() + make_interval(secs => ($2::DOUBLE PRECISION + 1
+				- LEAST($2::DOUBLE PRECISION, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated_at) * $3::DOUBLE PRECISION))
+				/ $3::DOUBLE PRECISION)
+		WHERE LEAST($2::DOUBLE PRECISION, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated_at) * $3::DOUBLE PRECISION) >= 1
+		RETURNING tokens
+	`
+
+	var tokens float64
+	err := executor(ctx, s.db).GetContext(ctx, &tokens, query, key, float64(limit.Burst), limit.RefillRate())
+	if err == nil {
+		return newRateLimitResult(true, tokens, limit), nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	// No token was taken, so look up how long the bucket takes to refill one
+	query = `
+		SELECT LEAST($2::DOUBLE PRECISION, tokens + EXTRACT(EPOCH FROM NOW() - updated_at) * $3::DOUBLE PRECISION)
+		FROM rate_limit_buckets
+		WHERE bucket_key = $1
+	`
+
+	err = executor(ctx, s.db).GetContext(ctx, &tokens, query, key, float64(limit.Burst), limit.RefillRate())
+	if err != nil {
+		return nil, err
+	}
+	return newRateLimitResult(false, tokens, limit), nil
+}
+
+// DeleteFull removes the buckets that have refilled, which are the same as no bucket, returning how many were removed
+func (s *PostgresRateLimitStore) DeleteFull(ctx context.Context) (int64, error) {
+	query := `
+		DELETE FROM rate_limit_buckets
+		WHERE full_at <= NOW()
+	`
+
+	result, err := executor(ctx, s.db).ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/yukimaterrace/todoms/model"
+)
+
+// RateLimitStore defines the interface for token buckets used to rate limit requests
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit model.RateLimit) (*model.RateLimitResult, error)
+	DeleteFull(ctx context.Context) (int64, error)
+}
+
+// ConnectRateLimitStore creates the rate limit store selected by the RATE_LIMIT_STORE environment variable
+// "memory" (default) keeps buckets in the process, "postgres" shares them between instances through the database
+func ConnectRateLimitStore(db *sqlx.DB) (RateLimitStore, error) {
+	store := GetEnvOrDefault("RATE_LIMIT_STORE", "memory")
+	switch store {
+	case "memory":
+		return NewMemoryRateLimitStore(), nil
+	case "postgres":
+		return NewPostgresRateLimitStore(db), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit store: %s", store)
+	}
+}
+
+// newRateLimitResult describes a bucket left holding the given tokens after a token was taken or refused
+func newRateLimitResult(allowed bool, tokens float64, limit model.RateLimit) *model.RateLimitResult {
+	result := &model.RateLimitResult{
+		Allowed:    allowed,
+		Limit:      limit.Burst,
+		Remaining:  int(tokens),
+		ResetAfter: refillTime(float64(limit.Burst)-tokens, limit),
+	}
+	if !allowed {
+		result.RetryAfter = refillTime(1-tokens, limit)
+	}
+	return result
+}
+
+// refillTime returns the time the bucket takes to refill the given tokens
+func refillTime(tokens float64, limit model.RateLimit) time.Duration {
+	return time.Duration(tokens / limit.RefillRate() * float64(time.Second))
+}
@@ -0,0 +1,106 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+)
+
+func TestRateLimitStores(t *testing.T) {
+	stores := map[string]repository.RateLimitStore{
+		"memory":   repository.NewMemoryRateLimitStore(),
+		"postgres": repository.NewPostgresRateLimitStore(testDB),
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			limit := model.RateLimit{Burst: 3, Period: time.Hour}
+
+			// Test the burst can be taken at once
+			for remaining := 2; remaining >= 0; remaining-- {
+				result, err := store.Take(ctx, "test:"+name, limit)
+				require.NoError(t, err)
+				assert.True(t, result.Allowed)
+				assert.Equal(t, 3, result.Limit)
+				assert.Equal(t, remaining, result.Remaining)
+			}
+
+			// Test an empty bucket refuses with the time until the next token
+			result, err := store.Take(ctx, "test:"+name, limit)
+			require.NoError(t, err)
+			assert.False(t, result.Allowed)
+			assert.InDelta(t, (20 * time.Minute).Seconds(), result.RetryAfter.Seconds(), 5)
+			assert.InDelta(t, time.Hour.Seconds(), result.ResetAfter.Seconds(), 5)
+
+			// Test buckets are independent
+			result, err = store.Take(ctx, "other:"+name, limit)
+			require.NoError(t, err)
+			assert.True(t, result.Allowed)
+
+			// Test buckets that have not refilled are kept
+			deleted, err := store.DeleteFull(ctx)
+			require.NoError(t, err)
+			assert.Zero(t, deleted)
+
+			// Test refilled buckets are deleted
+			fast := model.RateLimit{Burst: 1, Period: 10 * time.Millisecond}
+			_, err = store.Take(ctx, "fast:"+name, fast)
+			require.NoError(t, err)
+			time.Sleep(50 * time.Millisecond)
+
+			deleted, err = store.DeleteFull(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), deleted)
+		})
+	}
+}
+
+func TestLoginAttemptRepository(t *testing.T) {
+	loginAttemptRepo := repository.NewLoginAttemptRepository(testDB)
+	ctx := context.Background()
+	email := "locked@example.com"
+
+	// Test an email without failures is not locked
+	lockout, err := loginAttemptRepo.GetLockout(ctx, email)
+	require.NoError(t, err)
+	assert.Zero(t, lockout)
+
+	// Test failures are counted
+	for expected := 1; expected <= 3; expected++ {
+		count, err := loginAttemptRepo.RecordFailure(ctx, email, time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, expected, count)
+	}
+
+	// Test the count starts over after the window
+	count, err := loginAttemptRepo.RecordFailure(ctx, email, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	// Test Lock
+	err = loginAttemptRepo.Lock(ctx, email, time.Minute)
+	require.NoError(t, err)
+
+	lockout, err = loginAttemptRepo.GetLockout(ctx, email)
+	require.NoError(t, err)
+	assert.InDelta(t, time.Minute.Seconds(), lockout.Seconds(), 5)
+
+	// Test a locked email is not deleted as stale
+	deleted, err := loginAttemptRepo.DeleteStale(ctx, 0)
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+
+	// Test Reset
+	err = loginAttemptRepo.Reset(ctx, email)
+	require.NoError(t, err)
+
+	lockout, err = loginAttemptRepo.GetLockout(ctx, email)
+	require.NoError(t, err)
+	assert.Zero(t, lockout)
+}
@@ -9,14 +9,16 @@ import (
 
 // Global variables for test database
 var (
-	testDB *sqlx.DB
+	testDB  *sqlx.DB
+	testDSN string
 )
 
 // TestMain runs once before all tests in the package
 func TestMain(m *testing.M) {
 	// Setup test database
-	db, teardown := SetupTestDBForSuite()
+	db, dsn, teardown := SetupTestDBForSuite()
 	testDB = db
+	testDSN = dsn
 
 	// Run tests
 	code := m.Run()
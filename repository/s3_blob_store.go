@@ -0,0 +1,229 @@
+package repository
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// unsignedPayload tells S3 that the request body is not covered by the signature
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+
+	// emptyPayloadHash is the SHA-256 hash of an empty request body
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	// DefaultS3Timeout is the default time a request to the storage may take, including reading the response body
+	DefaultS3Timeout = time.Minute
+)
+
+// S3Config holds the connection settings for an S3-compatible object storage
+type S3Config struct {
+	// Endpoint is the base URL of the storage, e.g. https://s3.ap-northeast-1.amazonaws.com or http://localhost:9000
+	Endpoint string
+
+	// Region is the region used to sign requests
+	Region string
+
+	// Bucket is the bucket blobs are stored in
+	Bucket string
+
+	// AccessKeyID and SecretAccessKey are the credentials used to sign requests
+	AccessKeyID     string
+	SecretAccessKey string
+
+	// Timeout is the time a request to the storage may take, including reading the response body
+	// DefaultS3Timeout is used if it is zero
+	Timeout time.Duration
+}
+
+// S3BlobStore implements BlobStore interface on an S3-compatible object storage such as MinIO
+// Requests use path-style addressing and are signed with AWS Signature Version 4
+type S3BlobStore struct {
+	config S3Config
+	client *http.Client
+}
+
+// NewS3BlobStore creates a new S3BlobStore instance
+// If client is nil, a client with the configured timeout is used for requests to the storage
+func NewS3BlobStore(config S3Config, client *http.Client) *S3BlobStore {
+	if config.Timeout == 0 {
+		config.Timeout = DefaultS3Timeout
+	}
+	if client == nil {
+		client = &http.Client{Timeout: config.Timeout}
+	}
+	return &S3BlobStore{config: config, client: client}
+}
+
+// CreateBucket creates the configured bucket, succeeding if it already exists
+func (s *S3BlobStore) CreateBucket(ctx context.Context) error {
+	resp, err := s.do(ctx, http.MethodPut, "", nil, 0, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusConflict {
+		return nil
+	}
+	return s.errorFromResponse(resp)
+}
+
+// Put uploads a blob, replacing any existing blob with the same key
+func (s *S3BlobStore) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	resp, err := s.do(ctx, http.MethodPut, key, body, size, contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return s.errorFromResponse(resp)
+	}
+	return nil
+}
+
+// Get downloads a blob
+func (s *S3BlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, 0, "")
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrBlobNotFound
+	default:
+		defer resp.Body.Close()
+		return nil, s.errorFromResponse(resp)
+	}
+}
+
+// Delete removes a blob, succeeding if it does not exist
+func (s *S3BlobStore) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, 0, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	default:
+		return s.errorFromResponse(resp)
+	}
+}
+
+// do sends a signed request for the given object key, or for the bucket itself when key is empty
+func (s *S3BlobStore) do(ctx context.Context, method, key string, body io.Reader, size int64, contentType string) (*http.Response, error) {
+	endpoint, err := url.Parse(s.config.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	path := "/" + s3Escape(s.config.Bucket)
+	if key != "" {
+		path += "/" + s3Escape(key)
+	}
+	endpoint.Path, _ = url.PathUnescape(path)
+	endpoint.RawPath = path
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		// S3 does not accept chunked uploads, so the length must always be known
+		req.ContentLength = size
+		if size == 0 {
+			req.Body = http.NoBody
+		}
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	payloadHash := emptyPayloadHash
+	if body != nil {
+		payloadHash = unsignedPayload
+	}
+	s.sign(req, path, payloadHash, time.Now().UTC())
+
+	return s.client.Do(req)
+}
+
+// sign adds an AWS Signature Version 4 Authorization header to the request
+func (s *S3BlobStore) sign(req *http.Request, canonicalURI, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI,
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.config.Region + "/s3/aws4_request"
+	hashedRequest := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashedRequest[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretAccessKey), date)
+	key = hmacSHA256(key, s.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.config.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// errorFromResponse builds an error from an unexpected storage response
+func (s *S3BlobStore) errorFromResponse(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("s3 request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+// hmacSHA256 computes the HMAC-SHA256 of data with the given key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Escape percent-encodes everything except unreserved characters and slashes, as required by SigV4
+func s3Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
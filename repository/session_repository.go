@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/yukimaterrace/todoms/model"
+)
+
+// SessionRepository defines the interface for session operations
+type SessionRepository interface {
+	Create(ctx context.Context, session *model.Session, ttl time.Duration) error
+	GetActiveByUserID(ctx context.Context, userID uuid.UUID) ([]model.Session, error)
+	IsActive(ctx context.Context, userID, id uuid.UUID) (bool, error)
+	Rotate(ctx context.Context, session *model.Session, previousHash []byte, ttl time.Duration) (bool, error)
+	Delete(ctx context.Context, userID, id uuid.UUID) (bool, error)
+	DeleteByUserID(ctx context.Context, userID uuid.UUID) (int64, error)
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// PostgresSessionRepository implements SessionRepository interface for PostgreSQL
+type PostgresSessionRepository struct {
+	db *sqlx.DB
+}
+
+// NewSessionRepository creates a new PostgresSessionRepository instance
+func NewSessionRepository(db *sqlx.DB) SessionRepository {
+	return &PostgresSessionRepository{db: db}
+}
+
+// Create inserts a new session expiring after the ttl
+// The ID is given by the caller, as it is part of the refresh token whose hash is stored
+func (r *PostgresSessionRepository) Create(ctx context.Context, session *model.Session, ttl time.Duration) error {
+	query := `
+		INSERT INTO sessions (id, user_id, refresh_token_hash, session_version, user_agent, ip_address, created_at, last_used_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW(), NOW() + make_interval(secs => $7))
+		RETURNING id, user_id, refresh_token_hash, session_version, user_agent, ip_address, created_at, last_used_at, expires_at
+	`
+
+	return executor(ctx, r.db).GetContext(ctx, session, query,
+		session.ID, session.UserID, session.RefreshTokenHash, session.SessionVersion,
+		session.UserAgent, session.IPAddress, ttl.Seconds())
+}
+
+// GetActiveByUserID retrieves the sessions of a user that have neither expired nor been revoked by a password change,
+// most recently used first
+func (r *PostgresSessionRepository) GetActiveByUserID(ctx context.Context, userID uuid.UUID) ([]model.Session, error) {
+	query := `
+		SELECT s.id, s.user_id, s.refresh_token_hash, s.session_version, s.user_agent, s.ip_address,
+			s.created_at, s.last_used_at, s.expires_at
+		FROM sessions s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.user_id = $1 AND s.expires_at > NOW() AND s.session_version = u.session_version
+		ORDER BY s.last_used_at DESC, s.id
+	`
+
+	sessions := []model.Session{}
+	err := executor(ctx, r.db).SelectContext(ctx, &sessions, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// IsActive reports whether a session of a user exists and has neither expired nor been revoked by a password change
+func (r *PostgresSessionRepository) IsActive(ctx context.Context, userID, id uuid.UUID) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM sessions s
+			JOIN users u ON u.id = s.user_id
+			WHERE s.id = $1 AND s.user_id = $2 AND s.expires_at > NOW() AND s.session_version = u.session_version
+		)
+	`
+
+	var active bool
+	if err := executor(ctx, r.db).GetContext(ctx, &active, query, id, userID); err != nil {
+		return false, err
+	}
+	return active, nil
+}
+
+// Rotate replaces the refresh token of an unexpired session, recording the device it was refreshed from
+// and extending it by the ttl
+// It reports false when the session does not exist, has expired or its refresh token is no longer previousHash
+func (r *PostgresSessionRepository) Rotate(ctx context.Context, session *model.Session, previousHash []byte, ttl time.Duration) (bool, error) {
+	query := `
+		UPDATE sessions
+		SET refresh_token_hash = $2, user_agent = $3, ip_address = $4, last_used_at = NOW(),
+			expires_at = NOW() + make_interval(secs => $5)
+		WHERE id = $1 AND refresh_token_hash = $6 AND expires_at > NOW()
+	`
+
+	result, err := executor(ctx, r.db).ExecContext(ctx, query,
+		session.ID, session.RefreshTokenHash, session.UserAgent, session.IPAddress, ttl.Seconds(), previousHash)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+// Delete removes a session of a user, reporting false when the user has no such session
+func (r *PostgresSessionRepository) Delete(ctx context.Context, userID, id uuid.UUID) (bool, error) {
+	query := `
+		DELETE FROM sessions
+		WHERE id = $1 AND user_id = $2
+	`
+
+	result, err := executor(ctx, r.db).ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+// DeleteByUserID removes every session of a user and returns how many were removed
+func (r *PostgresSessionRepository) DeleteByUserID(ctx context.Context, userID uuid.UUID) (int64, error) {
+	query := `
+		DELETE FROM sessions
+		WHERE user_id = $1
+	`
+
+	result, err := executor(ctx, r.db).ExecContext(ctx, query, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// DeleteExpired removes the sessions that have expired or been revoked by a password change
+// and returns how many were removed
+func (r *PostgresSessionRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	query := `
+		DELETE FROM sessions s
+		USING users u
+		WHERE u.id = s.user_id AND (s.expires_at <= NOW() OR s.session_version <> u.session_version)
+	`
+
+	result, err := executor(ctx, r.db).ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,122 @@
+package repository_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+)
+
+func TestSessionRepository(t *testing.T) {
+	userRepo := repository.NewUserRepository(testDB)
+	sessionRepo := repository.NewSessionRepository(testDB)
+	ctx := context.Background()
+
+	user := &model.User{Email: "session@example.com", PasswordHash: "hashedpassword"}
+	require.NoError(t, userRepo.Create(ctx, user))
+	otherUser := &model.User{Email: "session-other@example.com", PasswordHash: "hashedpassword"}
+	require.NoError(t, userRepo.Create(ctx, otherUser))
+
+	// Test Create keeps the ID given by the caller
+	firstHash := sha256.Sum256([]byte("refresh-1"))
+	session := &model.Session{ID: uuid.New(), UserID: user.ID, RefreshTokenHash: firstHash[:], UserAgent: "agent-1", IPAddress: "192.0.2.1"}
+	sessionID := session.ID
+	require.NoError(t, sessionRepo.Create(ctx, session, time.Hour))
+	assert.Equal(t, sessionID, session.ID)
+	assert.True(t, session.ExpiresAt.After(session.CreatedAt))
+
+	// Test GetActiveByUserID lists the sessions of the user only
+	sessions, err := sessionRepo.GetActiveByUserID(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, "agent-1", sessions[0].UserAgent)
+	sessions, err = sessionRepo.GetActiveByUserID(ctx, otherUser.ID)
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+
+	// Test IsActive only finds the session for its user
+	active, err := sessionRepo.IsActive(ctx, user.ID, session.ID)
+	require.NoError(t, err)
+	assert.True(t, active)
+	active, err = sessionRepo.IsActive(ctx, otherUser.ID, session.ID)
+	require.NoError(t, err)
+	assert.False(t, active)
+
+	// Test Rotate replaces the refresh token once, and records the device
+	secondHash := sha256.Sum256([]byte("refresh-2"))
+	rotation := &model.Session{ID: session.ID, RefreshTokenHash: secondHash[:], UserAgent: "agent-2", IPAddress: "192.0.2.2"}
+	rotated, err := sessionRepo.Rotate(ctx, rotation, firstHash[:], time.Hour)
+	require.NoError(t, err)
+	assert.True(t, rotated)
+	rotated, err = sessionRepo.Rotate(ctx, rotation, firstHash[:], time.Hour)
+	require.NoError(t, err)
+	assert.False(t, rotated)
+	sessions, err = sessionRepo.GetActiveByUserID(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, secondHash[:], sessions[0].RefreshTokenHash)
+	assert.Equal(t, "agent-2", sessions[0].UserAgent)
+	assert.Equal(t, "192.0.2.2", sessions[0].IPAddress)
+
+	// Test Delete only removes a session of the user
+	deleted, err := sessionRepo.Delete(ctx, otherUser.ID, session.ID)
+	require.NoError(t, err)
+	assert.False(t, deleted)
+	deleted, err = sessionRepo.Delete(ctx, user.ID, session.ID)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	active, err = sessionRepo.IsActive(ctx, user.ID, session.ID)
+	require.NoError(t, err)
+	assert.False(t, active)
+	rotated, err = sessionRepo.Rotate(ctx, &model.Session{ID: session.ID, RefreshTokenHash: firstHash[:]}, secondHash[:], time.Hour)
+	require.NoError(t, err)
+	assert.False(t, rotated)
+
+	// Test expired sessions and sessions revoked by a password change are neither listed nor rotated, and are purged
+	expired := &model.Session{ID: uuid.New(), UserID: user.ID, RefreshTokenHash: firstHash[:]}
+	require.NoError(t, sessionRepo.Create(ctx, expired, -time.Minute))
+	rotated, err = sessionRepo.Rotate(ctx, &model.Session{ID: expired.ID, RefreshTokenHash: secondHash[:]}, firstHash[:], time.Hour)
+	require.NoError(t, err)
+	assert.False(t, rotated)
+	active, err = sessionRepo.IsActive(ctx, user.ID, expired.ID)
+	require.NoError(t, err)
+	assert.False(t, active)
+	stale := &model.Session{ID: uuid.New(), UserID: otherUser.ID, RefreshTokenHash: firstHash[:]}
+	require.NoError(t, sessionRepo.Create(ctx, stale, time.Hour))
+	require.NoError(t, userRepo.UpdatePassword(ctx, otherUser.ID, "newhashedpassword"))
+	active, err = sessionRepo.IsActive(ctx, otherUser.ID, stale.ID)
+	require.NoError(t, err)
+	assert.False(t, active)
+	sessions, err = sessionRepo.GetActiveByUserID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+	sessions, err = sessionRepo.GetActiveByUserID(ctx, otherUser.ID)
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+	purged, err := sessionRepo.DeleteExpired(ctx)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, purged, int64(2))
+
+	// Test DeleteByUserID removes every session of the user only
+	require.NoError(t, sessionRepo.Create(ctx, &model.Session{ID: uuid.New(), UserID: user.ID, RefreshTokenHash: firstHash[:]}, time.Hour))
+	require.NoError(t, sessionRepo.Create(ctx, &model.Session{ID: uuid.New(), UserID: user.ID, RefreshTokenHash: secondHash[:]}, time.Hour))
+	otherUser, err = userRepo.GetByID(ctx, otherUser.ID)
+	require.NoError(t, err)
+	kept := &model.Session{ID: uuid.New(), UserID: otherUser.ID, RefreshTokenHash: firstHash[:], SessionVersion: otherUser.SessionVersion}
+	require.NoError(t, sessionRepo.Create(ctx, kept, time.Hour))
+	revoked, err := sessionRepo.DeleteByUserID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), revoked)
+	sessions, err = sessionRepo.GetActiveByUserID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+	sessions, err = sessionRepo.GetActiveByUserID(ctx, otherUser.ID)
+	require.NoError(t, err)
+	assert.Len(t, sessions, 1)
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/yukimaterrace/todoms/model"
+)
+
+// SyncRepository defines the interface for reading the changes clients need to sync
+// Changes are numbered by next_todo_change_seq, which lets one transaction write changes at a time,
+// so the changes a client has seen always end below those still to be committed
+// Writes of all users therefore wait for each other from their first change until they commit, and a write that
+// waits for the clock longer than its lock timeout fails, so transactions writing todos have to stay short
+type SyncRepository interface {
+	GetChangedTodos(ctx context.Context, userID uuid.UUID, after int64, limit int) ([]model.SyncTodo, error)
+	GetTombstones(ctx context.Context, userID uuid.UUID, after int64, limit int) ([]model.TodoTombstone, error)
+}
+
+// PostgresSyncRepository implements SyncRepository interface for PostgreSQL
+type PostgresSyncRepository struct {
+	db *sqlx.DB
+}
+
+// NewSyncRepository creates a new PostgresSyncRepository instance
+func NewSyncRepository(db *sqlx.DB) SyncRepository {
+	return &PostgresSyncRepository{db: db}
+}
+
+// GetChangedTodos retrieves the todos owned by or shared with a user that changed after the given sequence,
+// oldest change first
+// A todo newly shared with the user counts as changed, even if the todo itself is older
+func (r *PostgresSyncRepository) GetChangedTodos(ctx context.Context, userID uuid.UUID, after int64, limit int) ([]model.SyncTodo, error) {
+	query := `
+		SELECT * FROM (
+			SELECT t.id, t.user_id, t.title, t.description, t.due_date, t.is_completed, t.assignee_id,
+				t.version, t.change_seq, t.created_at, t.updated_at, a.email AS assignee_email,
+				GREATEST(t.change_seq, COALESCE(s.change_seq, 0)) AS sync_seq
+			FROM todos t
+			LEFT JOIN users a ON a.id = t.assignee_id
+			LEFT JOIN todo_shares s ON s.todo_id = t.id AND s.user_id = $1
+			WHERE t.user_id = $1 OR s.user_id IS NOT NULL
+		) changed
+		WHERE sync_seq > $2
+		ORDER BY sync_seq
+		LIMIT $3
+	`
+
+	var todos []model.SyncTodo
+	err := executor(ctx, r.db).SelectContext(ctx, &todos, query, userID, after, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return todos, nil
+}
+
+// GetTombstones retrieves the todos removed for a user after the given sequence, oldest first
+func (r *PostgresSyncRepository) GetTombstones(ctx context.Context, userID uuid.UUID, after int64, limit int) ([]model.TodoTombstone, error) {
+	query := `
+		SELECT todo_id, user_id, change_seq, deleted_at
+		FROM todo_tombstones
+		WHERE user_id = $1 AND change_seq > $2
+		ORDER BY change_seq
+		LIMIT $3
+	`
+
+	var tombstones []model.TodoTombstone
+	err := executor(ctx, r.db).SelectContext(ctx, &tombstones, query, userID, after, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return tombstones, nil
+}
@@ -0,0 +1,216 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+)
+
+func TestSyncRepository(t *testing.T) {
+	userRepo := repository.NewUserRepository(testDB)
+	todoRepo := repository.NewTodoRepository(testDB)
+	shareRepo := repository.NewTodoShareRepository(testDB)
+	syncRepo := repository.NewSyncRepository(testDB)
+	ctx := context.Background()
+
+	owner := &model.User{
+		Email:        "sync-owner@example.com",
+		PasswordHash: "hashedpassword",
+	}
+	err := userRepo.Create(ctx, owner)
+	require.NoError(t, err)
+
+	friend := &model.User{
+		Email:        "sync-friend@example.com",
+		PasswordHash: "hashedpassword",
+	}
+	err = userRepo.Create(ctx, friend)
+	require.NoError(t, err)
+
+	todoIDs := func(todos []model.SyncTodo) []uuid.UUID {
+		ids := make([]uuid.UUID, len(todos))
+		for i, todo := range todos {
+			ids[i] = todo.ID
+		}
+		return ids
+	}
+
+	// Test a new todo starts at version 1
+	first := &model.Todo{UserID: owner.ID, Title: "First"}
+	err = todoRepo.Create(ctx, first)
+	require.NoError(t, err)
+	second := &model.Todo{UserID: owner.ID, Title: "Second"}
+	err = todoRepo.Create(ctx, second)
+	require.NoError(t, err)
+
+	created, err := todoRepo.GetByID(ctx, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), created.Version)
+
+	changes, err := syncRepo.GetChangedTodos(ctx, owner.ID, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{first.ID, second.ID}, todoIDs(changes))
+	since := changes[len(changes)-1].SyncSeq
+
+	// Test an update bumps the version and moves the todo after the token
+	created.Title = "First updated"
+	err = todoRepo.Update(ctx, created)
+	require.NoError(t, err)
+
+	updated, err := todoRepo.GetByID(ctx, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), updated.Version)
+
+	changes, err = syncRepo.GetChangedTodos(ctx, owner.ID, since, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{first.ID}, todoIDs(changes))
+
+	// Test sharing makes an old todo a change for the user it is shared with
+	changes, err = syncRepo.GetChangedTodos(ctx, friend.ID, since, 10)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	err = shareRepo.Upsert(ctx, &model.TodoShare{TodoID: second.ID, UserID: friend.ID, Role: model.ShareRoleViewer})
+	require.NoError(t, err)
+
+	changes, err = syncRepo.GetChangedTodos(ctx, friend.ID, since, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{second.ID}, todoIDs(changes))
+	friendSince := changes[0].SyncSeq
+
+	// Test revoking a share leaves a tombstone for the user only
+	err = shareRepo.Delete(ctx, second.ID, friend.ID)
+	require.NoError(t, err)
+
+	tombstones, err := syncRepo.GetTombstones(ctx, friend.ID, friendSince, 10)
+	require.NoError(t, err)
+	require.Len(t, tombstones, 1)
+	assert.Equal(t, second.ID, tombstones[0].TodoID)
+
+	tombstones, err = syncRepo.GetTombstones(ctx, owner.ID, since, 10)
+	require.NoError(t, err)
+	assert.Empty(t, tombstones)
+
+	// Test sharing again clears the tombstone
+	err = shareRepo.Upsert(ctx, &model.TodoShare{TodoID: second.ID, UserID: friend.ID, Role: model.ShareRoleEditor})
+	require.NoError(t, err)
+
+	tombstones, err = syncRepo.GetTombstones(ctx, friend.ID, friendSince, 10)
+	require.NoError(t, err)
+	assert.Empty(t, tombstones)
+
+	// Test deleting a todo leaves a tombstone for the owner and the users it was shared with
+	err = todoRepo.Delete(ctx, second.ID)
+	require.NoError(t, err)
+
+	for _, userID := range []uuid.UUID{owner.ID, friend.ID} {
+		tombstones, err = syncRepo.GetTombstones(ctx, userID, since, 10)
+		require.NoError(t, err)
+		require.Len(t, tombstones, 1)
+		assert.Equal(t, second.ID, tombstones[0].TodoID)
+	}
+
+	// Test creating a todo again with the same ID clears the owner's tombstone
+	err = todoRepo.Create(ctx, &model.Todo{ID: second.ID, UserID: owner.ID, Title: "Second again"})
+	require.NoError(t, err)
+
+	tombstones, err = syncRepo.GetTombstones(ctx, owner.ID, since, 10)
+	require.NoError(t, err)
+	assert.Empty(t, tombstones)
+
+	// Test creating a todo with an ID in use fails
+	err = todoRepo.Create(ctx, &model.Todo{ID: second.ID, UserID: friend.ID, Title: "Taken"})
+	assert.ErrorIs(t, err, repository.ErrDuplicateTodoID)
+
+	// Test lock within a transaction
+	transactor := repository.NewTransactor(testDB)
+	err = transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		locked, err := todoRepo.GetByIDForUpdate(ctx, first.ID)
+		if err != nil {
+			return err
+		}
+		assert.Equal(t, updated.Version, locked.Version)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestSyncRepositoryInterleavedTransactions(t *testing.T) {
+	userRepo := repository.NewUserRepository(testDB)
+	todoRepo := repository.NewTodoRepository(testDB)
+	syncRepo := repository.NewSyncRepository(testDB)
+	transactor := repository.NewTransactor(testDB)
+	ctx := context.Background()
+
+	owner := &model.User{
+		Email:        "sync-interleaved@example.com",
+		PasswordHash: "hashedpassword",
+	}
+	err := userRepo.Create(ctx, owner)
+	require.NoError(t, err)
+
+	baseline := &model.Todo{UserID: owner.ID, Title: "Baseline"}
+	err = todoRepo.Create(ctx, baseline)
+	require.NoError(t, err)
+	changes, err := syncRepo.GetChangedTodos(ctx, owner.ID, 0, 10)
+	require.NoError(t, err)
+	since := changes[len(changes)-1].SyncSeq
+
+	// The first transaction writes a change and stays open
+	first := &model.Todo{UserID: owner.ID, Title: "First"}
+	written := make(chan struct{})
+	release := make(chan struct{})
+	firstDone := make(chan error, 1)
+	go func() {
+		firstDone <- transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+			if err := todoRepo.Create(ctx, first); err != nil {
+				close(written)
+				return err
+			}
+			close(written)
+			<-release
+			return nil
+		})
+	}()
+	<-written
+
+	// The second transaction starts writing after the first, but would commit before it
+	second := &model.Todo{UserID: owner.ID, Title: "Second"}
+	secondDone := make(chan error, 1)
+	go func() {
+		secondDone <- transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+			return todoRepo.Create(ctx, second)
+		})
+	}()
+
+	// Test the second transaction waits for the first to number its change
+	select {
+	case err := <-secondDone:
+		t.Fatalf("second transaction committed while the first was open: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// Test a client syncing meanwhile sees neither change, so its token stays before both
+	changes, err = syncRepo.GetChangedTodos(ctx, owner.ID, since, 10)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	close(release)
+	require.NoError(t, <-firstDone)
+	require.NoError(t, <-secondDone)
+
+	// Test syncing from that token returns both changes in commit order
+	changes, err = syncRepo.GetChangedTodos(ctx, owner.ID, since, 10)
+	require.NoError(t, err)
+	ids := make([]uuid.UUID, len(changes))
+	for i, todo := range changes {
+		ids[i] = todo.ID
+	}
+	assert.Equal(t, []uuid.UUID{first.ID, second.ID}, ids)
+}
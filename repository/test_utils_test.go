@@ -17,7 +17,8 @@ import (
 )
 
 // SetupTestDBForSuite sets up a test database for the entire test suite
-func SetupTestDBForSuite() (*sqlx.DB, func()) {
+// It returns the connection, its connection string and a teardown function
+func SetupTestDBForSuite() (*sqlx.DB, string, func()) {
 	ctx := context.Background()
 	pgContainer, err := postgres.RunContainer(ctx,
 		postgres.WithDatabase("todoms"),
@@ -49,7 +50,7 @@ func SetupTestDBForSuite() (*sqlx.DB, func()) {
 		pgContainer.Terminate(ctx)
 	}
 
-	return db, teardown
+	return db, dsn, teardown
 }
 
 func waitForDB(dsn string, timeout time.Duration) (*sqlx.DB, error) {
@@ -2,33 +2,62 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/yukimaterrace/todoms/model"
 )
 
+// ErrDuplicateTodoID is returned when creating a todo with an ID that is already in use
+var ErrDuplicateTodoID = errors.New("duplicate todo ID")
+
 // TodoRepository defines the interface for todo data operations
 type TodoRepository interface {
 	Create(ctx context.Context, todo *model.Todo) error
 	GetByID(ctx context.Context, id uuid.UUID) (*model.Todo, error)
+	GetByIDForUpdate(ctx context.Context, id uuid.UUID) (*model.Todo, error)
 	GetByUserID(ctx context.Context, userID uuid.UUID) ([]model.Todo, error)
+	CountByUserIDForUpdate(ctx context.Context, userID uuid.UUID) (int, error)
+	GetStatsByUserID(ctx context.Context, userID uuid.UUID) (*model.TodoStats, error)
+	GetSharedWithUserID(ctx context.Context, userID uuid.UUID) ([]model.Todo, error)
+	GetByAssigneeID(ctx context.Context, assigneeID uuid.UUID) ([]model.Todo, error)
 	Update(ctx context.Context, todo *model.Todo) error
 	Delete(ctx context.Context, id uuid.UUID) error
 	MarkAsCompleted(ctx context.Context, id uuid.UUID) error
+	Assign(ctx context.Context, assignment *model.TodoAssignment) error
+	GetAssignments(ctx context.Context, todoID uuid.UUID) ([]model.TodoAssignment, error)
 }
 
+// selectTodoQuery selects todos together with the email of their assignee
+const selectTodoQuery = `
+		SELECT t.id, t.user_id, t.title, t.description, t.due_date, t.is_completed, t.assignee_id,
+			t.version, t.change_seq, t.created_at, t.updated_at, a.email AS assignee_email
+		FROM todos t
+		LEFT JOIN users a ON a.id = t.assignee_id
+`
+
 // PostgresTodoRepository implements TodoRepository interface for PostgreSQL
+// Every write records a domain event in the outbox in the same transaction
 type PostgresTodoRepository struct {
-	db *sqlx.DB
+	db         *sqlx.DB
+	transactor Transactor
+	outboxRepo OutboxRepository
 }
 
 // NewTodoRepository creates a new PostgresTodoRepository instance
 func NewTodoRepository(db *sqlx.DB) TodoRepository {
-	return &PostgresTodoRepository{db: db}
+	return &PostgresTodoRepository{
+		db:         db,
+		transactor: NewTransactor(db),
+		outboxRepo: NewOutboxRepository(db),
+	}
 }
 
-// Create inserts a new todo into the database
+// Create inserts a new todo into the database, generating its ID unless one is given
 func (r *PostgresTodoRepository) Create(ctx context.Context, todo *model.Todo) error {
 	if todo.ID == uuid.Nil {
 		todo.ID = uuid.New()
@@ -39,20 +68,44 @@ func (r *PostgresTodoRepository) Create(ctx context.Context, todo *model.Todo) e
 		VALUES (:id, :user_id, :title, :description, :due_date, :is_completed, NOW(), NOW())
 	`
 
-	_, err := r.db.NamedExecContext(ctx, query, todo)
-	return err
+	return r.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		_, err := executor(ctx, r.db).NamedExecContext(ctx, query, todo)
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrDuplicateTodoID
+		}
+		if err != nil {
+			return err
+		}
+		return r.recordEvent(ctx, model.DomainEventTodoCreated, todo.ID)
+	})
 }
 
 // GetByID retrieves a todo by its ID
 func (r *PostgresTodoRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Todo, error) {
-	query := `
-		SELECT id, user_id, title, description, due_date, is_completed, created_at, updated_at
-		FROM todos
-		WHERE id = $1
+	query := selectTodoQuery + `
+		WHERE t.id = $1
 	`
 
 	var todo model.Todo
-	err := r.db.GetContext(ctx, &todo, query, id)
+	err := executor(ctx, r.db).GetContext(ctx, &todo, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &todo, nil
+}
+
+// GetByIDForUpdate retrieves a todo by its ID and locks it until the transaction ends
+// It must be called within a transaction
+func (r *PostgresTodoRepository) GetByIDForUpdate(ctx context.Context, id uuid.UUID) (*model.Todo, error) {
+	query := selectTodoQuery + `
+		WHERE t.id = $1
+		FOR UPDATE OF t
+	`
+
+	var todo model.Todo
+	err := executor(ctx, r.db).GetContext(ctx, &todo, query, id)
 	if err != nil {
 		return nil, err
 	}
@@ -62,15 +115,89 @@ func (r *PostgresTodoRepository) GetByID(ctx context.Context, id uuid.UUID) (*mo
 
 // GetByUserID retrieves all todos for a user
 func (r *PostgresTodoRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]model.Todo, error) {
+	query := selectTodoQuery + `
+		WHERE t.user_id = $1
+		ORDER BY t.created_at DESC
+	`
+
+	var todos []model.Todo
+	err := executor(ctx, r.db).SelectContext(ctx, &todos, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return todos, nil
+}
+
+// CountByUserIDForUpdate counts the todos owned by a specific user
+// It locks the user until the transaction ends, so a todo can be created against the count without racing another
+func (r *PostgresTodoRepository) CountByUserIDForUpdate(ctx context.Context, userID uuid.UUID) (int, error) {
+	if err := lockUser(ctx, r.db, userID); err != nil {
+		return 0, err
+	}
+
 	query := `
-		SELECT id, user_id, title, description, due_date, is_completed, created_at, updated_at
+		SELECT COUNT(*)
 		FROM todos
 		WHERE user_id = $1
-		ORDER BY created_at DESC
+	`
+
+	var count int
+	err := executor(ctx, r.db).GetContext(ctx, &count, query, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// GetStatsByUserID counts the todos owned by a specific user by their state, and the todos assigned to them
+func (r *PostgresTodoRepository) GetStatsByUserID(ctx context.Context, userID uuid.UUID) (*model.TodoStats, error) {
+	query := `
+		SELECT
+			COUNT(*) AS total,
+			COUNT(*) FILTER (WHERE is_completed) AS completed,
+			COUNT(*) FILTER (WHERE NOT is_completed AND due_date < CURRENT_DATE) AS overdue,
+			(SELECT COUNT(*) FROM todos WHERE assignee_id = $1) AS assigned
+		FROM todos
+		WHERE user_id = $1
+	`
+
+	var stats model.TodoStats
+	err := executor(ctx, r.db).GetContext(ctx, &stats, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
+
+// GetSharedWithUserID retrieves all todos other users have shared with a user
+func (r *PostgresTodoRepository) GetSharedWithUserID(ctx context.Context, userID uuid.UUID) ([]model.Todo, error) {
+	query := selectTodoQuery + `
+		JOIN todo_shares s ON s.todo_id = t.id
+		WHERE s.user_id = $1
+		ORDER BY t.created_at DESC
 	`
 
 	var todos []model.Todo
-	err := r.db.SelectContext(ctx, &todos, query, userID)
+	err := executor(ctx, r.db).SelectContext(ctx, &todos, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return todos, nil
+}
+
+// GetByAssigneeID retrieves all todos assigned to a user
+func (r *PostgresTodoRepository) GetByAssigneeID(ctx context.Context, assigneeID uuid.UUID) ([]model.Todo, error) {
+	query := selectTodoQuery + `
+		WHERE t.assignee_id = $1
+		ORDER BY t.created_at DESC
+	`
+
+	var todos []model.Todo
+	err := executor(ctx, r.db).SelectContext(ctx, &todos, query, assigneeID)
 	if err != nil {
 		return nil, err
 	}
@@ -79,26 +206,59 @@ func (r *PostgresTodoRepository) GetByUserID(ctx context.Context, userID uuid.UU
 }
 
 // Update updates an existing todo in the database
+// A todo.completed event follows the todo.updated event when the update completes the todo
 func (r *PostgresTodoRepository) Update(ctx context.Context, todo *model.Todo) error {
+	completedQuery := `
+		SELECT is_completed
+		FROM todos
+		WHERE id = $1
+		FOR UPDATE
+	`
+
 	query := `
 		UPDATE todos
 		SET title = :title, description = :description, due_date = :due_date, is_completed = :is_completed
 		WHERE id = :id
 	`
 
-	_, err := r.db.NamedExecContext(ctx, query, todo)
-	return err
+	return r.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var wasCompleted bool
+		err := executor(ctx, r.db).GetContext(ctx, &wasCompleted, completedQuery, todo.ID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		_, err = executor(ctx, r.db).NamedExecContext(ctx, query, todo)
+		if err != nil {
+			return err
+		}
+		if err := r.recordEvent(ctx, model.DomainEventTodoUpdated, todo.ID); err != nil {
+			return err
+		}
+		if todo.IsCompleted && !wasCompleted {
+			return r.recordEvent(ctx, model.DomainEventTodoCompleted, todo.ID)
+		}
+		return nil
+	})
 }
 
 // Delete removes a todo from the database
+// Shares, comments and attachments are removed by cascade, and the blobs of the attachments
+// are queued in blob_deletions to be purged from the blob store
 func (r *PostgresTodoRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	query := `
 		DELETE FROM todos
 		WHERE id = $1
 	`
 
-	_, err := r.db.ExecContext(ctx, query, id)
-	return err
+	return r.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		// Record the event first, while the todo can still be loaded for its payload
+		if err := r.recordEvent(ctx, model.DomainEventTodoDeleted, id); err != nil {
+			return err
+		}
+		_, err := executor(ctx, r.db).ExecContext(ctx, query, id)
+		return err
+	})
 }
 
 // MarkAsCompleted sets a todo's is_completed status to true
@@ -109,6 +269,98 @@ func (r *PostgresTodoRepository) MarkAsCompleted(ctx context.Context, id uuid.UU
 		WHERE id = $1
 	`
 
-	_, err := r.db.ExecContext(ctx, query, id)
-	return err
+	return r.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		_, err := executor(ctx, r.db).ExecContext(ctx, query, id)
+		if err != nil {
+			return err
+		}
+		return r.recordEvent(ctx, model.DomainEventTodoCompleted, id)
+	})
+}
+
+// Assign sets the assignee of a todo and records the change in the assignment history
+func (r *PostgresTodoRepository) Assign(ctx context.Context, assignment *model.TodoAssignment) error {
+	if assignment.ID == uuid.Nil {
+		assignment.ID = uuid.New()
+	}
+
+	query := `
+		WITH updated AS (
+			UPDATE todos
+			SET assignee_id = :assignee_id
+			WHERE id = :todo_id
+			RETURNING id
+		)
+		INSERT INTO todo_assignments (id, todo_id, assignee_id, assigned_by, created_at)
+		SELECT :id, id, :assignee_id, :assigned_by, NOW()
+		FROM updated
+	`
+
+	return r.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		_, err := executor(ctx, r.db).NamedExecContext(ctx, query, assignment)
+		if err != nil {
+			return err
+		}
+		return r.recordEvent(ctx, model.DomainEventTodoAssigned, assignment.TodoID)
+	})
+}
+
+// GetAssignments retrieves the assignment history of a todo, oldest first
+func (r *PostgresTodoRepository) GetAssignments(ctx context.Context, todoID uuid.UUID) ([]model.TodoAssignment, error) {
+	query := `
+		SELECT h.id, h.todo_id, h.assignee_id, a.email AS assignee_email,
+			h.assigned_by, b.email AS assigned_by_email, h.created_at
+		FROM todo_assignments h
+		LEFT JOIN users a ON a.id = h.assignee_id
+		LEFT JOIN users b ON b.id = h.assigned_by
+		WHERE h.todo_id = $1
+		ORDER BY h.created_at
+	`
+
+	var assignments []model.TodoAssignment
+	err := executor(ctx, r.db).SelectContext(ctx, &assignments, query, todoID)
+	if err != nil {
+		return nil, err
+	}
+
+	return assignments, nil
+}
+
+// recordEvent writes a domain event carrying the current state of a todo to the outbox
+// The owner and the users the todo is shared with are recorded as the audience of the event
+// Nothing is recorded when the todo does not exist, as the write it follows changed nothing
+func (r *PostgresTodoRepository) recordEvent(ctx context.Context, eventType string, todoID uuid.UUID) error {
+	todo, err := r.GetByID(ctx, todoID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(model.NewTodoEventData(todo))
+	if err != nil {
+		return err
+	}
+
+	query := `
+		SELECT user_id::text
+		FROM todo_shares
+		WHERE todo_id = $1
+	`
+
+	audience := []string{todo.UserID.String()}
+	var sharedWith []string
+	err = executor(ctx, r.db).SelectContext(ctx, &sharedWith, query, todoID)
+	if err != nil {
+		return err
+	}
+
+	return r.outboxRepo.Create(ctx, &model.OutboxEvent{
+		AggregateType: model.AggregateTodo,
+		AggregateID:   todoID,
+		EventType:     eventType,
+		Payload:       payload,
+		Audience:      append(audience, sharedWith...),
+	})
 }
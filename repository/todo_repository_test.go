@@ -53,6 +53,11 @@ func TestTodoRepository(t *testing.T) {
 	assert.Len(t, todos, 1)
 	assert.Equal(t, todo.ID, todos[0].ID)
 
+	// Test CountByUserIDForUpdate
+	count, err := todoRepo.CountByUserIDForUpdate(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
 	// Test Update
 	todo.Title = "Updated Todo"
 	newDescription := "Updated description"
@@ -80,3 +85,114 @@ func TestTodoRepository(t *testing.T) {
 	_, err = todoRepo.GetByID(ctx, todo.ID)
 	assert.Error(t, err) // Should error as todo is deleted
 }
+
+func TestTodoRepositoryAssign(t *testing.T) {
+	userRepo := repository.NewUserRepository(testDB)
+	todoRepo := repository.NewTodoRepository(testDB)
+	shareRepo := repository.NewTodoShareRepository(testDB)
+	ctx := context.Background()
+
+	// Create an owner and an assignee the todo is shared with
+	owner := &model.User{
+		Email:        "assign-owner@example.com",
+		PasswordHash: "hashedpassword",
+	}
+	err := userRepo.Create(ctx, owner)
+	require.NoError(t, err)
+
+	assignee := &model.User{
+		Email:        "assign-assignee@example.com",
+		PasswordHash: "hashedpassword",
+	}
+	err = userRepo.Create(ctx, assignee)
+	require.NoError(t, err)
+
+	todo := &model.Todo{
+		UserID: owner.ID,
+		Title:  "Assigned Todo",
+	}
+	err = todoRepo.Create(ctx, todo)
+	require.NoError(t, err)
+
+	err = shareRepo.Upsert(ctx, &model.TodoShare{
+		TodoID: todo.ID,
+		UserID: assignee.ID,
+		Role:   model.ShareRoleEditor,
+	})
+	require.NoError(t, err)
+
+	// Test Assign
+	err = todoRepo.Assign(ctx, &model.TodoAssignment{
+		TodoID:     todo.ID,
+		AssigneeID: &assignee.ID,
+		AssignedBy: &owner.ID,
+	})
+	require.NoError(t, err)
+
+	assignedTodo, err := todoRepo.GetByID(ctx, todo.ID)
+	require.NoError(t, err)
+	require.NotNil(t, assignedTodo.AssigneeID)
+	assert.Equal(t, assignee.ID, *assignedTodo.AssigneeID)
+	assert.Equal(t, assignee.Email, *assignedTodo.AssigneeEmail)
+
+	// Test GetByAssigneeID
+	todos, err := todoRepo.GetByAssigneeID(ctx, assignee.ID)
+	require.NoError(t, err)
+	assert.Len(t, todos, 1)
+	assert.Equal(t, todo.ID, todos[0].ID)
+
+	// Test GetAssignments
+	assignments, err := todoRepo.GetAssignments(ctx, todo.ID)
+	require.NoError(t, err)
+	assert.Len(t, assignments, 1)
+	assert.Equal(t, owner.Email, *assignments[0].AssignedByEmail)
+
+	// Test removing the share unassigns the todo
+	err = shareRepo.Delete(ctx, todo.ID, assignee.ID)
+	require.NoError(t, err)
+
+	unassignedTodo, err := todoRepo.GetByID(ctx, todo.ID)
+	require.NoError(t, err)
+	assert.Nil(t, unassignedTodo.AssigneeID)
+	assert.Nil(t, unassignedTodo.AssigneeEmail)
+}
+
+func TestTodoRepositoryGetStats(t *testing.T) {
+	userRepo := repository.NewUserRepository(testDB)
+	todoRepo := repository.NewTodoRepository(testDB)
+	shareRepo := repository.NewTodoShareRepository(testDB)
+	ctx := context.Background()
+
+	owner := &model.User{Email: "stats-owner@example.com", PasswordHash: "hashedpassword"}
+	require.NoError(t, userRepo.Create(ctx, owner))
+	assignee := &model.User{Email: "stats-assignee@example.com", PasswordHash: "hashedpassword"}
+	require.NoError(t, userRepo.Create(ctx, assignee))
+
+	// Create a completed todo, an overdue todo, a todo due tomorrow and a completed todo that was due yesterday
+	yesterday := time.Now().Add(-24 * time.Hour)
+	tomorrow := time.Now().Add(24 * time.Hour)
+	todos := []*model.Todo{
+		{UserID: owner.ID, Title: "Completed"},
+		{UserID: owner.ID, Title: "Overdue", DueDate: &yesterday},
+		{UserID: owner.ID, Title: "Due tomorrow", DueDate: &tomorrow},
+		{UserID: owner.ID, Title: "Completed late", DueDate: &yesterday},
+	}
+	for _, todo := range todos {
+		require.NoError(t, todoRepo.Create(ctx, todo))
+	}
+	require.NoError(t, todoRepo.MarkAsCompleted(ctx, todos[0].ID))
+	require.NoError(t, todoRepo.MarkAsCompleted(ctx, todos[3].ID))
+
+	// Assign the overdue todo to the assignee
+	require.NoError(t, shareRepo.Upsert(ctx, &model.TodoShare{TodoID: todos[1].ID, UserID: assignee.ID, Role: model.ShareRoleEditor}))
+	require.NoError(t, todoRepo.Assign(ctx, &model.TodoAssignment{TodoID: todos[1].ID, AssigneeID: &assignee.ID, AssignedBy: &owner.ID}))
+
+	// Test GetStatsByUserID
+	stats, err := todoRepo.GetStatsByUserID(ctx, owner.ID)
+	require.NoError(t, err)
+	assert.Equal(t, &model.TodoStats{Total: 4, Completed: 2, Overdue: 1, Assigned: 0}, stats)
+
+	stats, err = todoRepo.GetStatsByUserID(ctx, assignee.ID)
+	require.NoError(t, err)
+	assert.Equal(t, &model.TodoStats{Total: 0, Completed: 0, Overdue: 0, Assigned: 1}, stats)
+}
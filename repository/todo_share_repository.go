@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/yukimaterrace/todoms/model"
+)
+
+// TodoShareRepository defines the interface for todo share data operations
+type TodoShareRepository interface {
+	Upsert(ctx context.Context, share *model.TodoShare) error
+	Get(ctx context.Context, todoID, userID uuid.UUID) (*model.TodoShare, error)
+	GetByTodoID(ctx context.Context, todoID uuid.UUID) ([]model.TodoShare, error)
+	Delete(ctx context.Context, todoID, userID uuid.UUID) error
+}
+
+// PostgresTodoShareRepository implements TodoShareRepository interface for PostgreSQL
+type PostgresTodoShareRepository struct {
+	db *sqlx.DB
+}
+
+// NewTodoShareRepository creates a new PostgresTodoShareRepository instance
+func NewTodoShareRepository(db *sqlx.DB) TodoShareRepository {
+	return &PostgresTodoShareRepository{db: db}
+}
+
+// Upsert shares a todo with a user, or changes the role if it is already shared
+func (r *PostgresTodoShareRepository) Upsert(ctx context.Context, share *model.TodoShare) error {
+	query := `
+		INSERT INTO todo_shares (todo_id, user_id, role, created_at, updated_at)
+		VALUES (:todo_id, :user_id, :role, NOW(), NOW())
+		ON CONFLICT (todo_id, user_id) DO UPDATE SET role = EXCLUDED.role
+	`
+
+	_, err := executor(ctx, r.db).NamedExecContext(ctx, query, share)
+	return err
+}
+
+// Get retrieves the share of a todo for a specific user
+func (r *PostgresTodoShareRepository) Get(ctx context.Context, todoID, userID uuid.UUID) (*model.TodoShare, error) {
+	query := `
+		SELECT s.todo_id, s.user_id, u.email, s.role, s.created_at, s.updated_at
+		FROM todo_shares s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.todo_id = $1 AND s.user_id = $2
+	`
+
+	var share model.TodoShare
+	err := executor(ctx, r.db).GetContext(ctx, &share, query, todoID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &share, nil
+}
+
+// GetByTodoID retrieves all shares of a todo
+func (r *PostgresTodoShareRepository) GetByTodoID(ctx context.Context, todoID uuid.UUID) ([]model.TodoShare, error) {
+	query := `
+		SELECT s.todo_id, s.user_id, u.email, s.role, s.created_at, s.updated_at
+		FROM todo_shares s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.todo_id = $1
+		ORDER BY s.created_at
+	`
+
+	var shares []model.TodoShare
+	err := executor(ctx, r.db).SelectContext(ctx, &shares, query, todoID)
+	if err != nil {
+		return nil, err
+	}
+
+	return shares, nil
+}
+
+// Delete removes the share of a todo for a specific user
+// The todo is unassigned as well if it was assigned to that user, since they can no longer access it
+func (r *PostgresTodoShareRepository) Delete(ctx context.Context, todoID, userID uuid.UUID) error {
+	query := `
+		WITH deleted AS (
+			DELETE FROM todo_shares
+			WHERE todo_id = $1 AND user_id = $2
+			RETURNING todo_id, user_id
+		)
+		UPDATE todos
+		SET assignee_id = NULL
+		FROM deleted
+		WHERE todos.id = deleted.todo_id AND todos.assignee_id = deleted.user_id
+	`
+
+	_, err := executor(ctx, r.db).ExecContext(ctx, query, todoID, userID)
+	return err
+}
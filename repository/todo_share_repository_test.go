@@ -0,0 +1,96 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+)
+
+func TestTodoShareRepository(t *testing.T) {
+	userRepo := repository.NewUserRepository(testDB)
+	todoRepo := repository.NewTodoRepository(testDB)
+	shareRepo := repository.NewTodoShareRepository(testDB)
+	ctx := context.Background()
+
+	// Create an owner and a user to share with
+	owner := &model.User{
+		Email:        "share-owner@example.com",
+		PasswordHash: "hashedpassword",
+	}
+	err := userRepo.Create(ctx, owner)
+	require.NoError(t, err)
+
+	friend := &model.User{
+		Email:        "share-friend@example.com",
+		PasswordHash: "hashedpassword",
+	}
+	err = userRepo.Create(ctx, friend)
+	require.NoError(t, err)
+
+	todo := &model.Todo{
+		UserID: owner.ID,
+		Title:  "Shared Todo",
+	}
+	err = todoRepo.Create(ctx, todo)
+	require.NoError(t, err)
+
+	// Test Upsert
+	err = shareRepo.Upsert(ctx, &model.TodoShare{
+		TodoID: todo.ID,
+		UserID: friend.ID,
+		Role:   model.ShareRoleViewer,
+	})
+	require.NoError(t, err)
+
+	// Test Get
+	share, err := shareRepo.Get(ctx, todo.ID, friend.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.ShareRoleViewer, share.Role)
+	assert.Equal(t, friend.Email, share.Email)
+
+	// Test Upsert changes the role of an existing share
+	err = shareRepo.Upsert(ctx, &model.TodoShare{
+		TodoID: todo.ID,
+		UserID: friend.ID,
+		Role:   model.ShareRoleEditor,
+	})
+	require.NoError(t, err)
+
+	shares, err := shareRepo.GetByTodoID(ctx, todo.ID)
+	require.NoError(t, err)
+	assert.Len(t, shares, 1)
+	assert.Equal(t, model.ShareRoleEditor, shares[0].Role)
+
+	// Test GetSharedWithUserID
+	sharedTodos, err := todoRepo.GetSharedWithUserID(ctx, friend.ID)
+	require.NoError(t, err)
+	assert.Len(t, sharedTodos, 1)
+	assert.Equal(t, todo.ID, sharedTodos[0].ID)
+
+	// Test Delete
+	err = shareRepo.Delete(ctx, todo.ID, friend.ID)
+	require.NoError(t, err)
+
+	_, err = shareRepo.Get(ctx, todo.ID, friend.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	// Test shares are removed together with the todo
+	err = shareRepo.Upsert(ctx, &model.TodoShare{
+		TodoID: todo.ID,
+		UserID: friend.ID,
+		Role:   model.ShareRoleViewer,
+	})
+	require.NoError(t, err)
+
+	err = todoRepo.Delete(ctx, todo.ID)
+	require.NoError(t, err)
+
+	shares, err = shareRepo.GetByTodoID(ctx, todo.ID)
+	require.NoError(t, err)
+	assert.Empty(t, shares)
+}
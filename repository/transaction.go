@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// txKey is the context key the current transaction is stored under
+type txKey struct{}
+
+// Transactor runs a function inside a database transaction
+// Repository calls made with the context passed to the function join the transaction
+type Transactor interface {
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// SQLTransactor implements Transactor interface with sqlx
+type SQLTransactor struct {
+	db *sqlx.DB
+}
+
+// NewTransactor creates a new SQLTransactor instance
+func NewTransactor(db *sqlx.DB) Transactor {
+	return &SQLTransactor{db: db}
+}
+
+// WithinTransaction commits when fn succeeds and rolls back when it returns an error or panics
+// If the context already carries a transaction, fn joins it instead of starting a new one
+func (t *SQLTransactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if _, ok := ctx.Value(txKey{}).(*sqlx.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := t.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err = fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// dbExecutor is the subset of query methods shared by sqlx.DB and sqlx.Tx
+type dbExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error)
+}
+
+// executor returns the transaction carried by the context, or db when there is none
+func executor(ctx context.Context, db *sqlx.DB) dbExecutor {
+	if tx, ok := ctx.Value(txKey{}).(*sqlx.Tx); ok {
+		return tx
+	}
+	return db
+}
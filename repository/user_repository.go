@@ -2,18 +2,32 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/yukimaterrace/todoms/model"
 )
 
+// ErrDuplicateEmail is returned when changing the email of a user to one that is already in use
+var ErrDuplicateEmail = errors.New("duplicate email")
+
 // UserRepository defines the interface for user data operations
 type UserRepository interface {
 	Create(ctx context.Context, user *model.User) error
 	GetByID(ctx context.Context, id uuid.UUID) (*model.User, error)
 	GetByEmail(ctx context.Context, email string) (*model.User, error)
+	List(ctx context.Context, filter *model.UserFilter, after *model.UserCursor, limit int) ([]model.User, error)
 	Update(ctx context.Context, user *model.User) error
+	MarkEmailVerified(ctx context.Context, id uuid.UUID) error
+	UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error
+	UpdatePasswordHash(ctx context.Context, id uuid.UUID, currentHash, passwordHash string) error
+	UpdateProfile(ctx context.Context, id uuid.UUID, profile *model.UserProfile) error
+	UpdateEmail(ctx context.Context, id uuid.UUID, email string) error
+	Disable(ctx context.Context, id uuid.UUID) error
+	Enable(ctx context.Context, id uuid.UUID) error
 	Delete(ctx context.Context, id uuid.UUID) error
 }
 
@@ -32,26 +46,30 @@ func (r *PostgresUserRepository) Create(ctx context.Context, user *model.User) e
 	if user.ID == uuid.Nil {
 		user.ID = uuid.New()
 	}
+	if user.Role == "" {
+		user.Role = model.RoleUser
+	}
 
 	query := `
-		INSERT INTO users (id, email, password_hash, created_at, updated_at)
-		VALUES (:id, :email, :password_hash, NOW(), NOW())
+		INSERT INTO users (id, email, password_hash, role, display_name, timezone, locale, created_at, updated_at)
+		VALUES (:id, :email, :password_hash, :role, :display_name, :timezone, :locale, NOW(), NOW())
 	`
 
-	_, err := r.db.NamedExecContext(ctx, query, user)
+	_, err := executor(ctx, r.db).NamedExecContext(ctx, query, user)
 	return err
 }
 
 // GetByID retrieves a user by their ID
 func (r *PostgresUserRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.User, error) {
 	query := `
-		SELECT id, email, password_hash, created_at, updated_at
+		SELECT id, email, password_hash, email_verified_at, session_version, role, disabled_at,
+			display_name, timezone, locale, created_at, updated_at
 		FROM users
 		WHERE id = $1
 	`
 
 	var user model.User
-	err := r.db.GetContext(ctx, &user, query, id)
+	err := executor(ctx, r.db).GetContext(ctx, &user, query, id)
 	if err != nil {
 		return nil, err
 	}
@@ -62,13 +80,14 @@ func (r *PostgresUserRepository) GetByID(ctx context.Context, id uuid.UUID) (*mo
 // GetByEmail retrieves a user by their email
 func (r *PostgresUserRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
 	query := `
-		SELECT id, email, password_hash, created_at, updated_at
+		SELECT id, email, password_hash, email_verified_at, session_version, role, disabled_at,
+			display_name, timezone, locale, created_at, updated_at
 		FROM users
 		WHERE email = $1
 	`
 
 	var user model.User
-	err := r.db.GetContext(ctx, &user, query, email)
+	err := executor(ctx, r.db).GetContext(ctx, &user, query, email)
 	if err != nil {
 		return nil, err
 	}
@@ -76,6 +95,42 @@ func (r *PostgresUserRepository) GetByEmail(ctx context.Context, email string) (
 	return &user, nil
 }
 
+// List retrieves up to limit users matching the filter, newest first, starting after the cursor
+func (r *PostgresUserRepository) List(ctx context.Context, filter *model.UserFilter, after *model.UserCursor, limit int) ([]model.User, error) {
+	query := `
+		SELECT id, email, password_hash, email_verified_at, session_version, role, disabled_at,
+			display_name, timezone, locale, created_at, updated_at
+		FROM users
+		WHERE TRUE
+	`
+	args := []interface{}{limit}
+	if filter.Query != "" {
+		args = append(args, "%"+escapeLikePattern(filter.Query)+"%")
+		query += fmt.Sprintf(` AND (email ILIKE $%d OR display_name ILIKE $%d)`, len(args), len(args))
+	}
+	if filter.Role != "" {
+		args = append(args, filter.Role)
+		query += fmt.Sprintf(` AND role = $%d`, len(args))
+	}
+	if filter.Disabled != nil {
+		args = append(args, *filter.Disabled)
+		query += fmt.Sprintf(` AND (disabled_at IS NOT NULL) = $%d`, len(args))
+	}
+	if after != nil {
+		args = append(args, after.CreatedAt, after.ID)
+		query += fmt.Sprintf(` AND (created_at, id) < ($%d, $%d)`, len(args)-1, len(args))
+	}
+	query += ` ORDER BY created_at DESC, id DESC LIMIT $1`
+
+	var users []model.User
+	err := executor(ctx, r.db).SelectContext(ctx, &users, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
 // Update updates an existing user in the database
 func (r *PostgresUserRepository) Update(ctx context.Context, user *model.User) error {
 	query := `
@@ -84,17 +139,134 @@ func (r *PostgresUserRepository) Update(ctx context.Context, user *model.User) e
 		WHERE id = :id
 	`
 
-	_, err := r.db.NamedExecContext(ctx, query, user)
+	_, err := executor(ctx, r.db).NamedExecContext(ctx, query, user)
+	return err
+}
+
+// MarkEmailVerified records that a user verified their email address, keeping the time of the first verification
+func (r *PostgresUserRepository) MarkEmailVerified(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE users
+		SET email_verified_at = COALESCE(email_verified_at, NOW())
+		WHERE id = $1
+	`
+
+	_, err := executor(ctx, r.db).ExecContext(ctx, query, id)
+	return err
+}
+
+// UpdatePassword sets the password hash of a user and increments the session version,
+// which revokes the tokens issued before
+func (r *PostgresUserRepository) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	query := `
+		UPDATE users
+		SET password_hash = $2, session_version = session_version + 1, updated_at = NOW()
+		WHERE id = $1
+	`
+
+	_, err := executor(ctx, r.db).ExecContext(ctx, query, id, passwordHash)
 	return err
 }
 
-// Delete removes a user from the database
+// UpdatePasswordHash replaces the password hash of a user with one made again from the same password,
+// keeping the sessions of the user
+// The hash is left as it is when it is no longer currentHash, such as when the password was changed meanwhile
+func (r *PostgresUserRepository) UpdatePasswordHash(ctx context.Context, id uuid.UUID, currentHash, passwordHash string) error {
+	query := `
+		UPDATE users
+		SET password_hash = $3
+		WHERE id = $1 AND password_hash = $2
+	`
+
+	_, err := executor(ctx, r.db).ExecContext(ctx, query, id, currentHash, passwordHash)
+	return err
+}
+
+// UpdateProfile sets the profile of a user
+func (r *PostgresUserRepository) UpdateProfile(ctx context.Context, id uuid.UUID, profile *model.UserProfile) error {
+	query := `
+		UPDATE users
+		SET display_name = $2, timezone = $3, locale = $4
+		WHERE id = $1
+	`
+
+	_, err := executor(ctx, r.db).ExecContext(ctx, query, id, profile.DisplayName, profile.Timezone, profile.Locale)
+	return err
+}
+
+// UpdateEmail sets the email address of a user, which has to be verified again
+func (r *PostgresUserRepository) UpdateEmail(ctx context.Context, id uuid.UUID, email string) error {
+	query := `
+		UPDATE users
+		SET email = $2, email_verified_at = NULL
+		WHERE id = $1
+	`
+
+	_, err := executor(ctx, r.db).ExecContext(ctx, query, id, email)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrDuplicateEmail
+	}
+	return err
+}
+
+// Disable records that a user was disabled and increments the session version,
+// which revokes the tokens issued before
+func (r *PostgresUserRepository) Disable(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE users
+		SET disabled_at = COALESCE(disabled_at, NOW()), session_version = session_version + 1
+		WHERE id = $1
+	`
+
+	_, err := executor(ctx, r.db).ExecContext(ctx, query, id)
+	return err
+}
+
+// Enable lets a disabled user log in again
+func (r *PostgresUserRepository) Enable(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE users
+		SET disabled_at = NULL
+		WHERE id = $1
+	`
+
+	_, err := executor(ctx, r.db).ExecContext(ctx, query, id)
+	return err
+}
+
+// lockUser locks the row of a user until the transaction carried by the context ends, so that checks of the limits
+// of the user wait for each other instead of counting the same rows
+// Rows counted by later statements include those committed while waiting, as each statement reads the latest commits
+func lockUser(ctx context.Context, db *sqlx.DB, userID uuid.UUID) error {
+	query := `
+		SELECT id FROM users
+		WHERE id = $1
+		FOR NO KEY UPDATE
+	`
+
+	_, err := executor(ctx, db).ExecContext(ctx, query, userID)
+	return err
+}
+
+// Delete removes a user from the database, together with their todos and everything else that belongs to them
+// It should be called within a transaction, as it also removes the tombstones written for the user meanwhile
 func (r *PostgresUserRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	query := `
 		DELETE FROM users
 		WHERE id = $1
 	`
 
-	_, err := r.db.ExecContext(ctx, query, id)
+	if _, err := executor(ctx, r.db).ExecContext(ctx, query, id); err != nil {
+		return err
+	}
+
+	// No client of the user will sync again
+	query = `
+		DELETE FROM todo_tombstones
+		WHERE user_id = $1
+	`
+
+	_, err := executor(ctx, r.db).ExecContext(ctx, query, id)
 	return err
 }
@@ -2,6 +2,8 @@ package repository_test
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
 	"testing"
 
 	"github.com/google/uuid"
@@ -44,6 +46,48 @@ func TestUserRepository(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, "updated@example.com", updatedUser.Email)
 
+	// Test UpdateProfile
+	err = repo.UpdateProfile(ctx, user.ID, &model.UserProfile{DisplayName: "Test User", Timezone: "Asia/Tokyo", Locale: "ja-JP"})
+	require.NoError(t, err)
+
+	updatedUser, err = repo.GetByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.UserProfile{DisplayName: "Test User", Timezone: "Asia/Tokyo", Locale: "ja-JP"}, updatedUser.UserProfile)
+
+	// Test UpdatePasswordHash keeps the session version
+	err = repo.UpdatePasswordHash(ctx, user.ID, "hashedpassword", "rehashedpassword")
+	require.NoError(t, err)
+
+	updatedUser, err = repo.GetByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "rehashedpassword", updatedUser.PasswordHash)
+	assert.Equal(t, fetchedUser.SessionVersion, updatedUser.SessionVersion)
+
+	// Test UpdatePasswordHash leaves a hash changed meanwhile
+	err = repo.UpdatePasswordHash(ctx, user.ID, "hashedpassword", "stalepassword")
+	require.NoError(t, err)
+
+	updatedUser, err = repo.GetByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "rehashedpassword", updatedUser.PasswordHash)
+
+	// Test UpdateEmail requires the new email to be verified
+	err = repo.MarkEmailVerified(ctx, user.ID)
+	require.NoError(t, err)
+	err = repo.UpdateEmail(ctx, user.ID, "changed@example.com")
+	require.NoError(t, err)
+
+	updatedUser, err = repo.GetByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "changed@example.com", updatedUser.Email)
+	assert.False(t, updatedUser.EmailVerified())
+
+	// Test UpdateEmail to an email in use
+	other := &model.User{Email: "other@example.com", PasswordHash: "hashedpassword"}
+	require.NoError(t, repo.Create(ctx, other))
+	err = repo.UpdateEmail(ctx, user.ID, other.Email)
+	assert.ErrorIs(t, err, repository.ErrDuplicateEmail)
+
 	// Test Delete
 	err = repo.Delete(ctx, user.ID)
 	require.NoError(t, err)
@@ -51,3 +95,83 @@ func TestUserRepository(t *testing.T) {
 	_, err = repo.GetByID(ctx, user.ID)
 	assert.Error(t, err) // Should error as user is deleted
 }
+
+func TestUserRepositoryList(t *testing.T) {
+	repo := repository.NewUserRepository(testDB)
+	ctx := context.Background()
+
+	// Create users newest last, one of them an admin
+	var users []*model.User
+	for i, role := range []string{model.RoleUser, model.RoleAdmin, model.RoleUser} {
+		user := &model.User{Email: fmt.Sprintf("list-%d@example.com", i), PasswordHash: "hashedpassword", Role: role}
+		require.NoError(t, repo.Create(ctx, user))
+		users = append(users, user)
+	}
+	assert.Equal(t, model.RoleUser, users[0].Role)
+
+	// Test Disable revokes the tokens of the user
+	err := repo.Disable(ctx, users[2].ID)
+	require.NoError(t, err)
+	disabledUser, err := repo.GetByID(ctx, users[2].ID)
+	require.NoError(t, err)
+	assert.True(t, disabledUser.Disabled())
+	assert.Equal(t, users[2].SessionVersion+1, disabledUser.SessionVersion)
+
+	// Test List returns the matching users newest first, a page at a time
+	listed, err := repo.List(ctx, &model.UserFilter{Query: "LIST-"}, nil, 2)
+	require.NoError(t, err)
+	require.Len(t, listed, 2)
+	assert.Equal(t, users[2].ID, listed[0].ID)
+	assert.Equal(t, users[1].ID, listed[1].ID)
+	listed, err = repo.List(ctx, &model.UserFilter{Query: "list-"}, &model.UserCursor{CreatedAt: listed[1].CreatedAt, ID: listed[1].ID}, 2)
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.Equal(t, users[0].ID, listed[0].ID)
+
+	// Test List by role and status
+	listed, err = repo.List(ctx, &model.UserFilter{Query: "list-", Role: model.RoleAdmin}, nil, 10)
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.Equal(t, users[1].ID, listed[0].ID)
+	disabled := true
+	listed, err = repo.List(ctx, &model.UserFilter{Query: "list-", Disabled: &disabled}, nil, 10)
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.Equal(t, users[2].ID, listed[0].ID)
+
+	// Test the query matches wildcard characters literally
+	listed, err = repo.List(ctx, &model.UserFilter{Query: "list_"}, nil, 10)
+	require.NoError(t, err)
+	assert.Empty(t, listed)
+
+	// Test Enable
+	err = repo.Enable(ctx, users[2].ID)
+	require.NoError(t, err)
+	enabledUser, err := repo.GetByID(ctx, users[2].ID)
+	require.NoError(t, err)
+	assert.False(t, enabledUser.Disabled())
+}
+
+func TestUserRepositoryDeleteCascade(t *testing.T) {
+	userRepo := repository.NewUserRepository(testDB)
+	todoRepo := repository.NewTodoRepository(testDB)
+	ctx := context.Background()
+
+	user := &model.User{Email: "delete-cascade@example.com", PasswordHash: "hashedpassword"}
+	require.NoError(t, userRepo.Create(ctx, user))
+	todo := &model.Todo{UserID: user.ID, Title: "Owned todo"}
+	require.NoError(t, todoRepo.Create(ctx, todo))
+
+	// The todos of a deleted user are deleted with them
+	err := userRepo.Delete(ctx, user.ID)
+	require.NoError(t, err)
+
+	_, err = todoRepo.GetByID(ctx, todo.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	// No tombstones are left for the deleted user
+	var tombstones int
+	err = testDB.GetContext(ctx, &tombstones, "SELECT COUNT(*) FROM todo_tombstones WHERE user_id = $1", user.ID)
+	require.NoError(t, err)
+	assert.Zero(t, tombstones)
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/yukimaterrace/todoms/model"
+)
+
+// UserTokenRepository defines the interface for single-use user token operations
+type UserTokenRepository interface {
+	Create(ctx context.Context, token *model.UserToken, ttl time.Duration) error
+	HasRecent(ctx context.Context, userID uuid.UUID, purpose model.UserTokenPurpose, within time.Duration) (bool, error)
+	Consume(ctx context.Context, tokenHash []byte, purpose model.UserTokenPurpose) (*model.UserToken, error)
+	DeleteUnused(ctx context.Context, userID uuid.UUID, purpose model.UserTokenPurpose) error
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// PostgresUserTokenRepository implements UserTokenRepository interface for PostgreSQL
+type PostgresUserTokenRepository struct {
+	db *sqlx.DB
+}
+
+// NewUserTokenRepository creates a new PostgresUserTokenRepository instance
+func NewUserTokenRepository(db *sqlx.DB) UserTokenRepository {
+	return &PostgresUserTokenRepository{db: db}
+}
+
+// Create inserts a new token that expires after the ttl, generating its ID
+func (r *PostgresUserTokenRepository) Create(ctx context.Context, token *model.UserToken, ttl time.Duration) error {
+	token.ID = uuid.New()
+
+	query := `
+		INSERT INTO user_tokens (id, user_id, purpose, token_hash, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW() + make_interval(secs => $5))
+		RETURNING id, user_id, purpose, token_hash, created_at, expires_at, used_at
+	`
+
+	return executor(ctx, r.db).GetContext(ctx, token, query, token.ID, token.UserID, token.Purpose, token.TokenHash, ttl.Seconds())
+}
+
+// HasRecent reports whether a token of a user for the purpose was created within the duration
+func (r *PostgresUserTokenRepository) HasRecent(ctx context.Context, userID uuid.UUID, purpose model.UserTokenPurpose, within time.Duration) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM user_tokens
+			WHERE user_id = $1 AND purpose = $2 AND created_at > NOW() - make_interval(secs => $3)
+		)
+	`
+
+	var exists bool
+	err := executor(ctx, r.db).GetContext(ctx, &exists, query, userID, purpose, within.Seconds())
+	if err != nil {
+		return false, err
+	}
+
+	return exists, nil
+}
+
+// Consume marks the token with the hash for the purpose as used, returning it
+// A token that was already used or has expired cannot be consumed, and sql.ErrNoRows is returned
+func (r *PostgresUserTokenRepository) Consume(ctx context.Context, tokenHash []byte, purpose model.UserTokenPurpose) (*model.UserToken, error) {
+	query := `
+		UPDATE user_tokens
+		SET used_at = NOW()
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING id, user_id, purpose, token_hash, created_at, expires_at, used_at
+	`
+
+	var token model.UserToken
+	err := executor(ctx, r.db).GetContext(ctx, &token, query, tokenHash, purpose)
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// DeleteUnused removes the tokens of a user for the purpose that have not been used
+func (r *PostgresUserTokenRepository) DeleteUnused(ctx context.Context, userID uuid.UUID, purpose model.UserTokenPurpose) error {
+	query := `
+		DELETE FROM user_tokens
+		WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL
+	`
+
+	_, err := executor(ctx, r.db).ExecContext(ctx, query, userID, purpose)
+	return err
+}
+
+// DeleteExpired removes the tokens that have expired, returning how many were removed
+func (r *PostgresUserTokenRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	query := `
+		DELETE FROM user_tokens
+		WHERE expires_at <= NOW()
+	`
+
+	result, err := executor(ctx, r.db).ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
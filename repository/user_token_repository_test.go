@@ -0,0 +1,103 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+)
+
+func TestUserTokenRepository(t *testing.T) {
+	userRepo := repository.NewUserRepository(testDB)
+	userTokenRepo := repository.NewUserTokenRepository(testDB)
+	ctx := context.Background()
+
+	user := &model.User{
+		Email:        "user-token@example.com",
+		PasswordHash: "hashedpassword",
+	}
+	err := userRepo.Create(ctx, user)
+	require.NoError(t, err)
+
+	// A new user has not verified the email
+	stored, err := userRepo.GetByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.False(t, stored.EmailVerified())
+
+	// Test Create
+	token := &model.UserToken{UserID: user.ID, Purpose: model.UserTokenEmailVerification, TokenHash: []byte("hash-1")}
+	err = userTokenRepo.Create(ctx, token, time.Hour)
+	require.NoError(t, err)
+	assert.NotEmpty(t, token.ID)
+	assert.True(t, token.ExpiresAt.After(token.CreatedAt))
+
+	// Test HasRecent
+	recent, err := userTokenRepo.HasRecent(ctx, user.ID, model.UserTokenEmailVerification, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, recent)
+	recent, err = userTokenRepo.HasRecent(ctx, user.ID, "other", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, recent)
+
+	// Test a token is not consumed for another purpose
+	_, err = userTokenRepo.Consume(ctx, token.TokenHash, "other")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	// Test Consume
+	consumed, err := userTokenRepo.Consume(ctx, []byte("hash-1"), model.UserTokenEmailVerification)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, consumed.UserID)
+	assert.NotNil(t, consumed.UsedAt)
+
+	// Test a token is consumed only once
+	_, err = userTokenRepo.Consume(ctx, token.TokenHash, model.UserTokenEmailVerification)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	// Test an expired token is not consumed and is deleted by DeleteExpired
+	expired := &model.UserToken{UserID: user.ID, Purpose: model.UserTokenEmailVerification, TokenHash: []byte("hash-2")}
+	err = userTokenRepo.Create(ctx, expired, 0)
+	require.NoError(t, err)
+	_, err = userTokenRepo.Consume(ctx, expired.TokenHash, model.UserTokenEmailVerification)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	deleted, err := userTokenRepo.DeleteExpired(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	// Test DeleteUnused keeps used tokens
+	unused := &model.UserToken{UserID: user.ID, Purpose: model.UserTokenEmailVerification, TokenHash: []byte("hash-3")}
+	err = userTokenRepo.Create(ctx, unused, time.Hour)
+	require.NoError(t, err)
+	err = userTokenRepo.DeleteUnused(ctx, user.ID, model.UserTokenEmailVerification)
+	require.NoError(t, err)
+	_, err = userTokenRepo.Consume(ctx, unused.TokenHash, model.UserTokenEmailVerification)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	// Test MarkEmailVerified keeps the time of the first verification
+	err = userRepo.MarkEmailVerified(ctx, user.ID)
+	require.NoError(t, err)
+	stored, err = userRepo.GetByID(ctx, user.ID)
+	require.NoError(t, err)
+	require.True(t, stored.EmailVerified())
+	firstVerifiedAt := *stored.EmailVerifiedAt
+
+	err = userRepo.MarkEmailVerified(ctx, user.ID)
+	require.NoError(t, err)
+	stored, err = userRepo.GetByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, firstVerifiedAt, *stored.EmailVerifiedAt)
+
+	// Test UpdatePassword increments the session version
+	assert.Equal(t, 0, stored.SessionVersion)
+	err = userRepo.UpdatePassword(ctx, user.ID, "newhashedpassword")
+	require.NoError(t, err)
+	stored, err = userRepo.GetByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "newhashedpassword", stored.PasswordHash)
+	assert.Equal(t, 1, stored.SessionVersion)
+}
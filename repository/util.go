@@ -3,6 +3,7 @@ package repository
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
@@ -10,10 +11,22 @@ import (
 
 // ConnectDB establishes a connection to the database
 func ConnectDB() (*sqlx.DB, error) {
+	return sqlx.Connect("postgres", DatabaseURL())
+}
+
+// DatabaseURL returns the connection string of the database
+func DatabaseURL() string {
 	dbUser := GetEnvOrDefault("DB_USER", "admin")
 	dbPassword := GetEnvOrDefault("DB_PASSWORD", "admin")
-	dbURL := fmt.Sprintf("postgres://%s:%s@localhost:5432/todoms?sslmode=disable", dbUser, dbPassword)
-	return sqlx.Connect("postgres", dbURL)
+	return fmt.Sprintf("postgres://%s:%s@localhost:5432/todoms?sslmode=disable", dbUser, dbPassword)
+}
+
+// likePatternEscaper escapes the characters LIKE patterns give a special meaning
+var likePatternEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLikePattern escapes s to match itself literally within a LIKE pattern
+func escapeLikePattern(s string) string {
+	return likePatternEscaper.Replace(s)
 }
 
 // GetEnvOrDefault returns the value of an environment variable or the default if not set
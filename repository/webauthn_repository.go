@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/yukimaterrace/todoms/model"
+)
+
+// ErrDuplicateCredential is returned when registering a passkey whose credential ID is already registered
+var ErrDuplicateCredential = errors.New("duplicate credential")
+
+// WebAuthnRepository defines the interface for passkey and WebAuthn challenge operations
+type WebAuthnRepository interface {
+	CreateChallenge(ctx context.Context, challenge *model.WebAuthnChallenge, ttl time.Duration) error
+	ConsumeChallenge(ctx context.Context, challengeHash []byte, ceremony model.WebAuthnCeremony) (*model.WebAuthnChallenge, error)
+	DeleteExpiredChallenges(ctx context.Context) (int64, error)
+	CreateCredential(ctx context.Context, credential *model.WebAuthnCredential) error
+	GetCredentialByCredentialID(ctx context.Context, credentialID []byte) (*model.WebAuthnCredential, error)
+	GetCredentialsByUserID(ctx context.Context, userID uuid.UUID) ([]model.WebAuthnCredential, error)
+	UpdateCredentialUsage(ctx context.Context, id uuid.UUID, signCount int64) error
+	DeleteCredential(ctx context.Context, userID, id uuid.UUID) (bool, error)
+}
+
+// PostgresWebAuthnRepository implements WebAuthnRepository interface for PostgreSQL
+type PostgresWebAuthnRepository struct {
+	db *sqlx.DB
+}
+
+// NewWebAuthnRepository creates a new PostgresWebAuthnRepository instance
+func NewWebAuthnRepository(db *sqlx.DB) WebAuthnRepository {
+	return &PostgresWebAuthnRepository{db: db}
+}
+
+// CreateChallenge inserts a new challenge that expires after the ttl
+func (r *PostgresWebAuthnRepository) CreateChallenge(ctx context.Context, challenge *model.WebAuthnChallenge, ttl time.Duration) error {
+	query := `
+		INSERT INTO webauthn_challenges (challenge_hash, user_id, ceremony, created_at, expires_at)
+		VALUES ($1, $2, $3, NOW(), NOW() + make_interval(secs => $4))
+		RETURNING challenge_hash, user_id, ceremony, created_at, expires_at
+	`
+
+	return executor(ctx, r.db).GetContext(ctx, challenge, query, challenge.ChallengeHash, challenge.UserID, challenge.Ceremony, ttl.Seconds())
+}
+
+// ConsumeChallenge deletes the challenge with the hash for the ceremony, returning it
+// A challenge that was already used or has expired cannot be consumed, and sql.ErrNoRows is returned
+func (r *PostgresWebAuthnRepository) ConsumeChallenge(ctx context.Context, challengeHash []byte, ceremony model.WebAuthnCeremony) (*model.WebAuthnChallenge, error) {
+	query := `
+		DELETE FROM webauthn_challenges
+		WHERE challenge_hash = $1 AND ceremony = $2 AND expires_at > NOW()
+		RETURNING challenge_hash, user_id, ceremony, created_at, expires_at
+	`
+
+	var challenge model.WebAuthnChallenge
+	err := executor(ctx, r.db).GetContext(ctx, &challenge, query, challengeHash, ceremony)
+	if err != nil {
+		return nil, err
+	}
+
+	return &challenge, nil
+}
+
+// DeleteExpiredChallenges removes the challenges that have expired and returns how many were removed
+func (r *PostgresWebAuthnRepository) DeleteExpiredChallenges(ctx context.Context) (int64, error) {
+	query := `
+		DELETE FROM webauthn_challenges
+		WHERE expires_at <= NOW()
+	`
+
+	result, err := executor(ctx, r.db).ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// CreateCredential inserts a new passkey, generating its ID
+func (r *PostgresWebAuthnRepository) CreateCredential(ctx context.Context, credential *model.WebAuthnCredential) error {
+	credential.ID = uuid.New()
+
+	query := `
+		INSERT INTO webauthn_credentials (id, user_id, credential_id, public_key, sign_count, aaguid, name, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+		RETURNING id, user_id, credential_id, public_key, sign_count, aaguid, name, created_at, last_used_at
+	`
+
+	err := executor(ctx, r.db).GetContext(ctx, credential, query,
+		credential.ID, credential.UserID, credential.CredentialID, credential.PublicKey,
+		credential.SignCount, credential.AAGUID, credential.Name)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrDuplicateCredential
+	}
+	return err
+}
+
+// GetCredentialByCredentialID retrieves the passkey with the ID the authenticator returns
+func (r *PostgresWebAuthnRepository) GetCredentialByCredentialID(ctx context.Context, credentialID []byte) (*model.WebAuthnCredential, error) {
+	query := `
+		SELECT id, user_id, credential_id, public_key, sign_count, aaguid, name, created_at, last_used_at
+		FROM webauthn_credentials
+		WHERE credential_id = $1
+	`
+
+	var credential model.WebAuthnCredential
+	err := executor(ctx, r.db).GetContext(ctx, &credential, query, credentialID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &credential, nil
+}
+
+// GetCredentialsByUserID retrieves the passkeys of a user, oldest first
+func (r *PostgresWebAuthnRepository) GetCredentialsByUserID(ctx context.Context, userID uuid.UUID) ([]model.WebAuthnCredential, error) {
+	query := `
+		SELECT id, user_id, credential_id, public_key, sign_count, aaguid, name, created_at, last_used_at
+		FROM webauthn_credentials
+		WHERE user_id = $1
+		ORDER BY created_at, id
+	`
+
+	credentials := []model.WebAuthnCredential{}
+	err := executor(ctx, r.db).SelectContext(ctx, &credentials, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return credentials, nil
+}
+
+// UpdateCredentialUsage records a login with a passkey and the signature counter it reported
+func (r *PostgresWebAuthnRepository) UpdateCredentialUsage(ctx context.Context, id uuid.UUID, signCount int64) error {
+	query := `
+		UPDATE webauthn_credentials
+		SET sign_count = $2, last_used_at = NOW()
+		WHERE id = $1
+	`
+
+	_, err := executor(ctx, r.db).ExecContext(ctx, query, id, signCount)
+	return err
+}
+
+// DeleteCredential removes a passkey of a user, reporting false when the user has no such passkey
+func (r *PostgresWebAuthnRepository) DeleteCredential(ctx context.Context, userID, id uuid.UUID) (bool, error) {
+	query := `
+		DELETE FROM webauthn_credentials
+		WHERE id = $1 AND user_id = $2
+	`
+
+	result, err := executor(ctx, r.db).ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
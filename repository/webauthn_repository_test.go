@@ -0,0 +1,109 @@
+package repository_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+)
+
+func TestWebAuthnRepository(t *testing.T) {
+	userRepo := repository.NewUserRepository(testDB)
+	webAuthnRepo := repository.NewWebAuthnRepository(testDB)
+	ctx := context.Background()
+
+	user := &model.User{
+		Email:        "passkey@example.com",
+		PasswordHash: "hashedpassword",
+	}
+	err := userRepo.Create(ctx, user)
+	require.NoError(t, err)
+
+	// Test CreateChallenge and ConsumeChallenge use a challenge once
+	registrationHash := sha256.Sum256([]byte("registration-challenge"))
+	challenge := &model.WebAuthnChallenge{ChallengeHash: registrationHash[:], UserID: &user.ID, Ceremony: model.WebAuthnRegistration}
+	err = webAuthnRepo.CreateChallenge(ctx, challenge, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, challenge.ExpiresAt.After(challenge.CreatedAt))
+
+	_, err = webAuthnRepo.ConsumeChallenge(ctx, registrationHash[:], model.WebAuthnLogin)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	consumed, err := webAuthnRepo.ConsumeChallenge(ctx, registrationHash[:], model.WebAuthnRegistration)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, *consumed.UserID)
+	_, err = webAuthnRepo.ConsumeChallenge(ctx, registrationHash[:], model.WebAuthnRegistration)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	// Test an expired challenge cannot be consumed and is purged
+	expiredHash := sha256.Sum256([]byte("expired-challenge"))
+	err = webAuthnRepo.CreateChallenge(ctx, &model.WebAuthnChallenge{ChallengeHash: expiredHash[:], Ceremony: model.WebAuthnLogin}, -time.Minute)
+	require.NoError(t, err)
+	_, err = webAuthnRepo.ConsumeChallenge(ctx, expiredHash[:], model.WebAuthnLogin)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	purged, err := webAuthnRepo.DeleteExpiredChallenges(ctx)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, purged, int64(1))
+
+	// A user has no passkeys until one is registered
+	credentials, err := webAuthnRepo.GetCredentialsByUserID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, credentials)
+
+	// Test CreateCredential
+	credential := &model.WebAuthnCredential{
+		UserID:       user.ID,
+		CredentialID: []byte("credential-1"),
+		PublicKey:    []byte("public-key"),
+		AAGUID:       make([]byte, 16),
+		Name:         "Laptop",
+	}
+	err = webAuthnRepo.CreateCredential(ctx, credential)
+	require.NoError(t, err)
+	assert.NotEqual(t, uuid.Nil, credential.ID)
+	assert.Nil(t, credential.LastUsedAt)
+
+	// Test a credential ID can be registered once
+	err = webAuthnRepo.CreateCredential(ctx, &model.WebAuthnCredential{
+		UserID:       user.ID,
+		CredentialID: []byte("credential-1"),
+		PublicKey:    []byte("other-key"),
+		AAGUID:       make([]byte, 16),
+		Name:         "Phone",
+	})
+	assert.ErrorIs(t, err, repository.ErrDuplicateCredential)
+
+	// Test GetCredentialByCredentialID
+	stored, err := webAuthnRepo.GetCredentialByCredentialID(ctx, []byte("credential-1"))
+	require.NoError(t, err)
+	assert.Equal(t, credential.ID, stored.ID)
+	assert.Equal(t, []byte("public-key"), stored.PublicKey)
+	_, err = webAuthnRepo.GetCredentialByCredentialID(ctx, []byte("unknown"))
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	// Test UpdateCredentialUsage
+	err = webAuthnRepo.UpdateCredentialUsage(ctx, credential.ID, 7)
+	require.NoError(t, err)
+	credentials, err = webAuthnRepo.GetCredentialsByUserID(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, credentials, 1)
+	assert.Equal(t, int64(7), credentials[0].SignCount)
+	assert.NotNil(t, credentials[0].LastUsedAt)
+
+	// Test DeleteCredential only deletes passkeys of the user
+	deleted, err := webAuthnRepo.DeleteCredential(ctx, uuid.New(), credential.ID)
+	require.NoError(t, err)
+	assert.False(t, deleted)
+	deleted, err = webAuthnRepo.DeleteCredential(ctx, user.ID, credential.ID)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	credentials, err = webAuthnRepo.GetCredentialsByUserID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, credentials)
+}
@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/yukimaterrace/todoms/model"
+)
+
+// selectWebhookDeliveryColumns lists the columns of a delivery without the joined webhook settings
+const selectWebhookDeliveryColumns = `
+	d.id, d.webhook_id, d.event_id, d.event_type, d.payload, d.status, d.attempts, d.next_attempt_at,
+	d.last_status_code, d.last_error, d.created_at, d.updated_at
+`
+
+// WebhookRepository defines the interface for webhook subscription and delivery operations
+type WebhookRepository interface {
+	Create(ctx context.Context, webhook *model.Webhook) error
+	GetByID(ctx context.Context, id uuid.UUID) (*model.Webhook, error)
+	GetByUserID(ctx context.Context, userID uuid.UUID) ([]model.Webhook, error)
+	Update(ctx context.Context, webhook *model.Webhook) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	EnqueueDeliveries(ctx context.Context, audience []string, eventID uuid.UUID, eventType string, payload []byte) error
+	ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]model.WebhookDelivery, error)
+	UpdateDelivery(ctx context.Context, delivery *model.WebhookDelivery) error
+	GetDeliveryByID(ctx context.Context, id uuid.UUID) (*model.WebhookDelivery, error)
+	GetDeliveriesByWebhookID(ctx context.Context, webhookID uuid.UUID, limit int) ([]model.WebhookDelivery, error)
+	ResetDelivery(ctx context.Context, id uuid.UUID) error
+}
+
+// PostgresWebhookRepository implements WebhookRepository interface for PostgreSQL
+type PostgresWebhookRepository struct {
+	db *sqlx.DB
+}
+
+// NewWebhookRepository creates a new PostgresWebhookRepository instance
+func NewWebhookRepository(db *sqlx.DB) WebhookRepository {
+	return &PostgresWebhookRepository{db: db}
+}
+
+// Create inserts a new webhook into the database
+func (r *PostgresWebhookRepository) Create(ctx context.Context, webhook *model.Webhook) error {
+	if webhook.ID == uuid.Nil {
+		webhook.ID = uuid.New()
+	}
+
+	query := `
+		INSERT INTO webhooks (id, user_id, url, secret, event_types, is_active, created_at, updated_at)
+		VALUES (:id, :user_id, :url, :secret, :event_types, :is_active, NOW(), NOW())
+	`
+
+	_, err := executor(ctx, r.db).NamedExecContext(ctx, query, webhook)
+	return err
+}
+
+// GetByID retrieves a webhook by its ID
+func (r *PostgresWebhookRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Webhook, error) {
+	query := `
+		SELECT id, user_id, url, secret, event_types, is_active, created_at, updated_at
+		FROM webhooks
+		WHERE id = $1
+	`
+
+	var webhook model.Webhook
+	err := executor(ctx, r.db).GetContext(ctx, &webhook, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &webhook, nil
+}
+
+// GetByUserID retrieves all webhooks of a user
+func (r *PostgresWebhookRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]model.Webhook, error) {
+	query := `
+		SELECT id, user_id, url, secret, event_types, is_active, created_at, updated_at
+		FROM webhooks
+		WHERE user_id = $1
+		ORDER BY created_at
+	`
+
+	var webhooks []model.Webhook
+	err := executor(ctx, r.db).SelectContext(ctx, &webhooks, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+// Update updates the URL, event types and active flag of a webhook
+func (r *PostgresWebhookRepository) Update(ctx context.Context, webhook *model.Webhook) error {
+	query := `
+		UPDATE webhooks
+		SET url = :url, event_types = :event_types, is_active = :is_active
+		WHERE id = :id
+	`
+
+	_, err := executor(ctx, r.db).NamedExecContext(ctx, query, webhook)
+	return err
+}
+
+// Delete removes a webhook and its delivery log from the database
+func (r *PostgresWebhookRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `
+		DELETE FROM webhooks
+		WHERE id = $1
+	`
+
+	_, err := executor(ctx, r.db).ExecContext(ctx, query, id)
+	return err
+}
+
+// EnqueueDeliveries queues an event for every active webhook subscribed to its type
+// whose owner is one of the audience, the IDs of the users who could see the todo when the event occurred
+// Queueing an event again is ignored, so it can be retried
+func (r *PostgresWebhookRepository) EnqueueDeliveries(ctx context.Context, audience []string, eventID uuid.UUID, eventType string, payload []byte) error {
+	query := `
+		INSERT INTO webhook_deliveries (id, webhook_id, event_id, event_type, payload)
+		SELECT gen_random_uuid(), w.id, $2, $3, $4
+		FROM webhooks w
+		WHERE w.is_active AND $3 = ANY(w.event_types) AND w.user_id = ANY($1::uuid[])
+		ON CONFLICT (webhook_id, event_id) DO NOTHING
+	`
+
+	_, err := executor(ctx, r.db).ExecContext(ctx, query, pq.Array(audience), eventID, eventType, string(payload))
+	return err
+}
+
+// ClaimDueDeliveries picks up to limit pending deliveries whose next attempt is due, together with
+// the URL and secret of their webhook
+// Claimed deliveries are leased by pushing their next attempt back, so concurrent workers skip them
+// and they are retried if the worker dies before recording the result
+func (r *PostgresWebhookRepository) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]model.WebhookDelivery, error) {
+	query := `
+		WITH due AS (
+			SELECT d.id
+			FROM webhook_deliveries d
+			JOIN webhooks w ON w.id = d.webhook_id
+			WHERE d.status = 'pending' AND d.next_attempt_at <= NOW() AND w.is_active
+			ORDER BY d.next_attempt_at
+			LIMIT $1
+			FOR UPDATE OF d SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = NOW() + make_interval(secs => $2)
+		FROM due, webhooks w
+		WHERE d.id = due.id AND w.id = d.webhook_id
+		RETURNING ` + selectWebhookDeliveryColumns + `, w.url, w.secret
+	`
+
+	var deliveries []model.WebhookDelivery
+	err := executor(ctx, r.db).SelectContext(ctx, &deliveries, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// UpdateDelivery records the result of a delivery attempt
+func (r *PostgresWebhookRepository) UpdateDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = :status, attempts = :attempts, next_attempt_at = :next_attempt_at,
+			last_status_code = :last_status_code, last_error = :last_error
+		WHERE id = :id
+	`
+
+	_, err := executor(ctx, r.db).NamedExecContext(ctx, query, delivery)
+	return err
+}
+
+// GetDeliveryByID retrieves a delivery by its ID
+func (r *PostgresWebhookRepository) GetDeliveryByID(ctx context.Context, id uuid.UUID) (*model.WebhookDelivery, error) {
+	query := `SELECT ` + selectWebhookDeliveryColumns + ` FROM webhook_deliveries d WHERE d.id = $1`
+
+	var delivery model.WebhookDelivery
+	err := executor(ctx, r.db).GetContext(ctx, &delivery, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &delivery, nil
+}
+
+// GetDeliveriesByWebhookID retrieves the latest deliveries of a webhook, newest first
+func (r *PostgresWebhookRepository) GetDeliveriesByWebhookID(ctx context.Context, webhookID uuid.UUID, limit int) ([]model.WebhookDelivery, error) {
+	query := `
+		SELECT ` + selectWebhookDeliveryColumns + `
+		FROM webhook_deliveries d
+		WHERE d.webhook_id = $1
+		ORDER BY d.created_at DESC, d.id DESC
+		LIMIT $2
+	`
+
+	var deliveries []model.WebhookDelivery
+	err := executor(ctx, r.db).SelectContext(ctx, &deliveries, query, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// ResetDelivery puts a delivery back in the queue with a fresh set of attempts
+func (r *PostgresWebhookRepository) ResetDelivery(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = 'pending', attempts = 0, next_attempt_at = NOW()
+		WHERE id = $1
+	`
+
+	_, err := executor(ctx, r.db).ExecContext(ctx, query, id)
+	return err
+}
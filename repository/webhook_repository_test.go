@@ -0,0 +1,138 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+)
+
+func TestWebhookRepository(t *testing.T) {
+	userRepo := repository.NewUserRepository(testDB)
+	webhookRepo := repository.NewWebhookRepository(testDB)
+	ctx := context.Background()
+
+	// Create an owner, a user the todo is shared with, and an outsider
+	// The owner and the viewer are the audience of the events
+	owner := &model.User{Email: "webhook-owner@example.com", PasswordHash: "hashedpassword"}
+	viewer := &model.User{Email: "webhook-viewer@example.com", PasswordHash: "hashedpassword"}
+	outsider := &model.User{Email: "webhook-outsider@example.com", PasswordHash: "hashedpassword"}
+	for _, user := range []*model.User{owner, viewer, outsider} {
+		require.NoError(t, userRepo.Create(ctx, user))
+	}
+
+	audience := []string{owner.ID.String(), viewer.ID.String()}
+
+	// Test Create
+	ownerHook := &model.Webhook{
+		UserID:     owner.ID,
+		URL:        "https://owner.example.com/hook",
+		Secret:     "whsec_owner",
+		EventTypes: pq.StringArray{model.WebhookEventTodoUpdated, model.WebhookEventTodoCompleted},
+		IsActive:   true,
+	}
+	viewerHook := &model.Webhook{
+		UserID:     viewer.ID,
+		URL:        "https://viewer.example.com/hook",
+		Secret:     "whsec_viewer",
+		EventTypes: pq.StringArray{model.WebhookEventTodoCompleted},
+		IsActive:   true,
+	}
+	outsiderHook := &model.Webhook{
+		UserID:     outsider.ID,
+		URL:        "https://outsider.example.com/hook",
+		Secret:     "whsec_outsider",
+		EventTypes: pq.StringArray{model.WebhookEventTodoUpdated, model.WebhookEventTodoCompleted},
+		IsActive:   true,
+	}
+	for _, webhook := range []*model.Webhook{ownerHook, viewerHook, outsiderHook} {
+		require.NoError(t, webhookRepo.Create(ctx, webhook))
+	}
+
+	// Test GetByID and GetByUserID
+	found, err := webhookRepo.GetByID(ctx, ownerHook.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "whsec_owner", found.Secret)
+	assert.Equal(t, ownerHook.EventTypes, found.EventTypes)
+
+	hooks, err := webhookRepo.GetByUserID(ctx, owner.ID)
+	require.NoError(t, err)
+	assert.Len(t, hooks, 1)
+
+	// Test EnqueueDeliveries targets the active subscribers in the audience
+	updatedEventID := uuid.New()
+	require.NoError(t, webhookRepo.EnqueueDeliveries(ctx, audience, updatedEventID, model.WebhookEventTodoUpdated, []byte(`{"type":"todo.updated"}`)))
+	completedEventID := uuid.New()
+	require.NoError(t, webhookRepo.EnqueueDeliveries(ctx, audience, completedEventID, model.WebhookEventTodoCompleted, []byte(`{"type":"todo.completed"}`)))
+	// Enqueueing the same event again is ignored
+	require.NoError(t, webhookRepo.EnqueueDeliveries(ctx, audience, completedEventID, model.WebhookEventTodoCompleted, []byte(`{"type":"todo.completed"}`)))
+
+	ownerDeliveries, err := webhookRepo.GetDeliveriesByWebhookID(ctx, ownerHook.ID, 10)
+	require.NoError(t, err)
+	assert.Len(t, ownerDeliveries, 2)
+	viewerDeliveries, err := webhookRepo.GetDeliveriesByWebhookID(ctx, viewerHook.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, viewerDeliveries, 1)
+	assert.Equal(t, completedEventID, viewerDeliveries[0].EventID)
+	outsiderDeliveries, err := webhookRepo.GetDeliveriesByWebhookID(ctx, outsiderHook.ID, 10)
+	require.NoError(t, err)
+	assert.Empty(t, outsiderDeliveries)
+
+	// Inactive webhooks receive nothing
+	viewerHook.IsActive = false
+	require.NoError(t, webhookRepo.Update(ctx, viewerHook))
+	require.NoError(t, webhookRepo.EnqueueDeliveries(ctx, audience, uuid.New(), model.WebhookEventTodoCompleted, []byte(`{}`)))
+	viewerDeliveries, err = webhookRepo.GetDeliveriesByWebhookID(ctx, viewerHook.ID, 10)
+	require.NoError(t, err)
+	assert.Len(t, viewerDeliveries, 1)
+
+	// Test ClaimDueDeliveries leases the claimed deliveries so they are not claimed twice
+	claimed, err := webhookRepo.ClaimDueDeliveries(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	assert.Len(t, claimed, 4)
+	for _, delivery := range claimed {
+		assert.NotEmpty(t, delivery.URL)
+		assert.NotEmpty(t, delivery.Secret)
+	}
+	claimedAgain, err := webhookRepo.ClaimDueDeliveries(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, claimedAgain)
+
+	// Test UpdateDelivery
+	delivery := claimed[0]
+	statusCode := 500
+	lastError := "unexpected status 500"
+	delivery.Status = model.WebhookDeliveryDead
+	delivery.Attempts = 8
+	delivery.LastStatusCode = &statusCode
+	delivery.LastError = &lastError
+	require.NoError(t, webhookRepo.UpdateDelivery(ctx, &delivery))
+
+	stored, err := webhookRepo.GetDeliveryByID(ctx, delivery.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.WebhookDeliveryDead, stored.Status)
+	assert.Equal(t, 8, stored.Attempts)
+	assert.Equal(t, 500, *stored.LastStatusCode)
+
+	// Test ResetDelivery makes the delivery due again
+	require.NoError(t, webhookRepo.ResetDelivery(ctx, delivery.ID))
+	claimed, err = webhookRepo.ClaimDueDeliveries(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, delivery.ID, claimed[0].ID)
+	assert.Equal(t, 0, claimed[0].Attempts)
+	assert.Equal(t, model.WebhookDeliveryPending, claimed[0].Status)
+
+	// Test Delete cascades to the deliveries
+	require.NoError(t, webhookRepo.Delete(ctx, ownerHook.ID))
+	_, err = webhookRepo.GetByID(ctx, ownerHook.ID)
+	assert.Error(t, err)
+	_, err = webhookRepo.GetDeliveryByID(ctx, ownerDeliveries[0].ID)
+	assert.Error(t, err)
+}
@@ -0,0 +1,269 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx/types"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultActivityPageSize is the number of events returned when no limit is given
+	DefaultActivityPageSize = 50
+
+	// MaxActivityPageSize is the maximum number of events returned in a single page
+	MaxActivityPageSize = 100
+)
+
+// requestMetadataKey is the context key request metadata is stored under
+type requestMetadataKey struct{}
+
+// requestMetadata identifies the HTTP request a change was made in
+type requestMetadata struct {
+	requestID string
+	ipAddress string
+	userAgent string
+}
+
+// WithRequestMetadata returns a context carrying the request ID and client IP recorded in activity events,
+// and the user agent recorded with the IP in login sessions
+func WithRequestMetadata(ctx context.Context, requestID, ipAddress, userAgent string) context.Context {
+	return context.WithValue(ctx, requestMetadataKey{}, requestMetadata{requestID: requestID, ipAddress: ipAddress, userAgent: userAgent})
+}
+
+// activitySnapshot is the JSON representation of an entity's state recorded in the activity log
+type activitySnapshot map[string]interface{}
+
+// ActivityService defines the interface for reading the activity log
+type ActivityService interface {
+	// GetTodoActivity retrieves a page of events of a todo the specified user can view, newest first
+	// It returns the cursor of the next page, or nil if there are no more events
+	GetTodoActivity(ctx context.Context, userID uuid.UUID, todoID uuid.UUID, cursor string, limit int) ([]model.ActivityEvent, *string, error)
+
+	// GetFeed retrieves a page of events the specified user performed or that happened on todos they can access
+	// It returns the cursor of the next page, or nil if there are no more events
+	GetFeed(ctx context.Context, userID uuid.UUID, cursor string, limit int) ([]model.ActivityEvent, *string, error)
+}
+
+// DefaultActivityService implements the ActivityService interface
+type DefaultActivityService struct {
+	todoService  TodoService
+	activityRepo repository.ActivityRepository
+	logger       *zap.Logger
+}
+
+// NewActivityService creates a new DefaultActivityService instance
+func NewActivityService(todoService TodoService, activityRepo repository.ActivityRepository, logger *zap.Logger) ActivityService {
+	return &DefaultActivityService{
+		todoService:  todoService,
+		activityRepo: activityRepo,
+		logger:       logger,
+	}
+}
+
+// GetTodoActivity retrieves a page of events of a todo the specified user can view
+func (s *DefaultActivityService) GetTodoActivity(ctx context.Context, userID uuid.UUID, todoID uuid.UUID, cursor string, limit int) ([]model.ActivityEvent, *string, error) {
+	_, _, err := s.todoService.AuthorizeTodo(ctx, userID, todoID, model.ShareRoleViewer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	before, err := decodeActivityCursor(cursor)
+	if err != nil {
+		return nil, nil, err
+	}
+	limit = activityPageSize(limit)
+
+	// Fetch one extra event to find out whether there is a next page
+	events, err := s.activityRepo.GetByTodoID(ctx, todoID, before, limit+1)
+	if err != nil {
+		s.logger.Error("failed to get todo activity",
+			zap.String("user_id", userID.String()),
+			zap.String("todo_id", todoID.String()),
+			zap.Error(err))
+		return nil, nil, err
+	}
+
+	events, nextCursor := paginateActivity(events, limit)
+	return events, nextCursor, nil
+}
+
+// GetFeed retrieves a page of events the specified user performed or that happened on todos they can access
+func (s *DefaultActivityService) GetFeed(ctx context.Context, userID uuid.UUID, cursor string, limit int) ([]model.ActivityEvent, *string, error) {
+	before, err := decodeActivityCursor(cursor)
+	if err != nil {
+		return nil, nil, err
+	}
+	limit = activityPageSize(limit)
+
+	// Fetch one extra event to find out whether there is a next page
+	events, err := s.activityRepo.GetFeedByUserID(ctx, userID, before, limit+1)
+	if err != nil {
+		s.logger.Error("failed to get activity feed",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, nil, err
+	}
+
+	events, nextCursor := paginateActivity(events, limit)
+	return events, nextCursor, nil
+}
+
+// recordActivity appends an event describing a change to the activity log
+// It must be called with the context of the transaction making the change, so the event is written atomically with it
+// before and after are nil when the entity did not exist before or after the change
+func recordActivity(
+	ctx context.Context,
+	activityRepo repository.ActivityRepository,
+	actorID uuid.UUID,
+	entityType model.ActivityEntity,
+	entityID uuid.UUID,
+	todoID *uuid.UUID,
+	action model.ActivityAction,
+	before, after activitySnapshot,
+) error {
+	beforeJSON, afterJSON, err := diffSnapshots(before, after)
+	if err != nil {
+		return err
+	}
+
+	event := &model.ActivityEvent{
+		ActorID:    actorID,
+		EntityType: entityType,
+		EntityID:   entityID,
+		TodoID:     todoID,
+		Action:     action,
+		Before:     beforeJSON,
+		After:      afterJSON,
+	}
+	if meta, ok := ctx.Value(requestMetadataKey{}).(requestMetadata); ok {
+		event.RequestID = meta.requestID
+		event.IPAddress = meta.ipAddress
+	}
+
+	return activityRepo.Create(ctx, event)
+}
+
+// diffSnapshots encodes the fields that differ between two snapshots
+// A nil snapshot is encoded as null, and the other side is then recorded in full
+func diffSnapshots(before, after activitySnapshot) (types.NullJSONText, types.NullJSONText, error) {
+	beforeFields, err := snapshotFields(before)
+	if err != nil {
+		return types.NullJSONText{}, types.NullJSONText{}, err
+	}
+	afterFields, err := snapshotFields(after)
+	if err != nil {
+		return types.NullJSONText{}, types.NullJSONText{}, err
+	}
+
+	if beforeFields != nil && afterFields != nil {
+		for key, value := range beforeFields {
+			if other, ok := afterFields[key]; ok && bytes.Equal(value, other) {
+				delete(beforeFields, key)
+				delete(afterFields, key)
+			}
+		}
+	}
+
+	beforeJSON, err := encodeSnapshotFields(beforeFields)
+	if err != nil {
+		return types.NullJSONText{}, types.NullJSONText{}, err
+	}
+	afterJSON, err := encodeSnapshotFields(afterFields)
+	if err != nil {
+		return types.NullJSONText{}, types.NullJSONText{}, err
+	}
+	return beforeJSON, afterJSON, nil
+}
+
+// snapshotFields encodes each field of a snapshot so that values can be compared by their JSON form
+func snapshotFields(snapshot activitySnapshot) (map[string]json.RawMessage, error) {
+	if snapshot == nil {
+		return nil, nil
+	}
+	fields := make(map[string]json.RawMessage, len(snapshot))
+	for key, value := range snapshot {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		fields[key] = encoded
+	}
+	return fields, nil
+}
+
+// encodeSnapshotFields encodes snapshot fields as a nullable JSON column
+func encodeSnapshotFields(fields map[string]json.RawMessage) (types.NullJSONText, error) {
+	if fields == nil {
+		return types.NullJSONText{}, nil
+	}
+	encoded, err := json.Marshal(fields)
+	if err != nil {
+		return types.NullJSONText{}, err
+	}
+	return types.NullJSONText{JSONText: encoded, Valid: true}, nil
+}
+
+// todoSnapshot returns the recorded state of a todo
+func todoSnapshot(todo *model.Todo) activitySnapshot {
+	return activitySnapshot{
+		"title":       todo.Title,
+		"description": todo.Description,
+		"dueDate":     todo.DueDate,
+		"isCompleted": todo.IsCompleted,
+		"assigneeId":  todo.AssigneeID,
+	}
+}
+
+// activityPageSize clamps the requested page size
+func activityPageSize(limit int) int {
+	if limit <= 0 {
+		return DefaultActivityPageSize
+	}
+	if limit > MaxActivityPageSize {
+		return MaxActivityPageSize
+	}
+	return limit
+}
+
+// paginateActivity trims a page fetched with one extra event and returns the cursor of the next page
+func paginateActivity(events []model.ActivityEvent, limit int) ([]model.ActivityEvent, *string) {
+	if len(events) <= limit {
+		return events, nil
+	}
+	events = events[:limit]
+	last := events[limit-1]
+	encoded := encodeActivityCursor(&model.ActivityCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	return events, &encoded
+}
+
+// encodeActivityCursor encodes a cursor into an opaque string for clients
+func encodeActivityCursor(cursor *model.ActivityCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeActivityCursor decodes a cursor received from a client, returning nil for the first page
+func decodeActivityCursor(cursor string) (*model.ActivityCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var decoded model.ActivityCursor
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.ID == uuid.Nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &decoded, nil
+}
@@ -0,0 +1,265 @@
+package service_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+	"go.uber.org/zap"
+)
+
+func TestRecordTodoActivity(t *testing.T) {
+	logger := zap.NewNop()
+	ownerID := uuid.New()
+	todoID := uuid.New()
+	description := "Low fat"
+
+	t.Run("update records only the changed fields with request metadata", func(t *testing.T) {
+		mockTodoRepo := new(MockTodoRepository)
+		mockTodoRepo.On("GetByIDForUpdate", mock.Anything, todoID).Return(&model.Todo{
+			ID:          todoID,
+			UserID:      ownerID,
+			Title:       "Buy milk",
+			Description: &description,
+		}, nil)
+		mockTodoRepo.On("Update", mock.Anything, mock.Anything).Return(nil)
+		mockActivityRepo := new(MockActivityRepository)
+		var recorded *model.ActivityEvent
+		mockActivityRepo.On("Create", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+			recorded = args.Get(1).(*model.ActivityEvent)
+		})
+
+		todoService := service.NewTodoService(mockTodoRepo, new(MockTodoShareRepository), mockActivityRepo, new(MockTransactor), config.DefaultTodoQuotaConfig(), logger)
+		ctx := service.WithRequestMetadata(context.Background(), "request-1", "192.0.2.1", "test-agent")
+		_, err := todoService.UpdateTodo(ctx, ownerID, todoID, model.UpdateTodoRequest{
+			Title:       "Buy milk",
+			Description: &description,
+			IsCompleted: true,
+		})
+
+		require.NoError(t, err)
+		require.NotNil(t, recorded)
+		assert.Equal(t, ownerID, recorded.ActorID)
+		assert.Equal(t, model.ActivityEntityTodo, recorded.EntityType)
+		assert.Equal(t, model.ActivityActionUpdated, recorded.Action)
+		assert.Equal(t, todoID, *recorded.TodoID)
+		assert.Equal(t, "request-1", recorded.RequestID)
+		assert.Equal(t, "192.0.2.1", recorded.IPAddress)
+		assert.JSONEq(t, `{"isCompleted": false}`, string(recorded.Before.JSONText))
+		assert.JSONEq(t, `{"isCompleted": true}`, string(recorded.After.JSONText))
+	})
+
+	t.Run("create records the full state and no previous state", func(t *testing.T) {
+		mockTodoRepo := new(MockTodoRepository)
+		mockTodoRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+		mockTodoRepo.On("CountByUserIDForUpdate", mock.Anything, ownerID).Return(0, nil)
+		mockActivityRepo := new(MockActivityRepository)
+		var recorded *model.ActivityEvent
+		mockActivityRepo.On("Create", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+			recorded = args.Get(1).(*model.ActivityEvent)
+		})
+
+		todoService := service.NewTodoService(mockTodoRepo, new(MockTodoShareRepository), mockActivityRepo, new(MockTransactor), config.DefaultTodoQuotaConfig(), logger)
+		_, err := todoService.CreateTodo(context.Background(), ownerID, model.CreateTodoRequest{Title: "Buy milk"})
+
+		require.NoError(t, err)
+		require.NotNil(t, recorded)
+		assert.Equal(t, model.ActivityActionCreated, recorded.Action)
+		assert.False(t, recorded.Before.Valid)
+		var after map[string]interface{}
+		require.NoError(t, json.Unmarshal(recorded.After.JSONText, &after))
+		assert.Equal(t, "Buy milk", after["title"])
+		assert.Contains(t, after, "isCompleted")
+	})
+
+	t.Run("failure to record fails the change", func(t *testing.T) {
+		mockTodoRepo := new(MockTodoRepository)
+		mockTodoRepo.On("GetByIDForUpdate", mock.Anything, todoID).Return(&model.Todo{ID: todoID, UserID: ownerID}, nil)
+		mockTodoRepo.On("Delete", mock.Anything, todoID).Return(nil)
+		mockActivityRepo := new(MockActivityRepository)
+		mockActivityRepo.On("Create", mock.Anything, mock.Anything).Return(errors.New("database error"))
+
+		todoService := service.NewTodoService(mockTodoRepo, new(MockTodoShareRepository), mockActivityRepo, new(MockTransactor), config.DefaultTodoQuotaConfig(), logger)
+		err := todoService.DeleteTodo(context.Background(), ownerID, todoID)
+
+		assert.EqualError(t, err, "database error")
+	})
+}
+
+func TestRecordPasswordActivity(t *testing.T) {
+	logger := zap.NewNop()
+	passwordPolicy := service.NewPasswordPolicy(nil, config.DefaultPasswordPolicyConfig(), logger)
+	authConfig := config.NewAuthConfig("test-secret", time.Minute, time.Hour)
+
+	// newRecordingActivityRepository returns a MockActivityRepository with the events it records
+	newRecordingActivityRepository := func() (*MockActivityRepository, *[]*model.ActivityEvent) {
+		activityRepo := new(MockActivityRepository)
+		recorded := &[]*model.ActivityEvent{}
+		activityRepo.On("Create", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+			*recorded = append(*recorded, args.Get(1).(*model.ActivityEvent))
+		})
+		return activityRepo, recorded
+	}
+
+	t.Run("password change is recorded without the password", func(t *testing.T) {
+		user := newUserWithPassword(t, "current-password")
+		userRepo := new(MockUserRepository)
+		userTokenRepo := new(MockUserTokenRepository)
+		userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+		userRepo.On("UpdatePassword", mock.Anything, user.ID, mock.Anything).Return(nil)
+		userTokenRepo.On("DeleteUnused", mock.Anything, user.ID, model.UserTokenPasswordReset).Return(nil)
+		activityRepo, recorded := newRecordingActivityRepository()
+
+		userService := service.NewUserService(nil, passwordPolicy, newTestPasswordHasher(), userRepo, userTokenRepo,
+			new(MockIdentityRepository), activityRepo, new(MockTransactor), authConfig, logger)
+		_, err := userService.ChangePassword(context.Background(), user.ID, "current-password", "amber-falcon-orbit-91")
+
+		require.NoError(t, err)
+		require.Len(t, *recorded, 1)
+		event := (*recorded)[0]
+		assert.Equal(t, user.ID, event.ActorID)
+		assert.Equal(t, model.ActivityEntityUser, event.EntityType)
+		assert.Equal(t, user.ID, event.EntityID)
+		assert.Equal(t, model.ActivityActionPasswordChanged, event.Action)
+		assert.False(t, event.Before.Valid)
+		assert.False(t, event.After.Valid)
+	})
+
+	t.Run("password reset is recorded", func(t *testing.T) {
+		user := &model.User{ID: uuid.New(), Email: "test@example.com", PasswordHash: "old-hash"}
+		token, issued := requestResetToken(t, user)
+		userRepo := new(MockUserRepository)
+		userTokenRepo := new(MockUserTokenRepository)
+		userTokenRepo.On("Consume", mock.Anything, issued.TokenHash, model.UserTokenPasswordReset).Return(issued, nil)
+		userRepo.On("UpdatePassword", mock.Anything, user.ID, mock.Anything).Return(nil)
+		userTokenRepo.On("DeleteUnused", mock.Anything, user.ID, model.UserTokenPasswordReset).Return(nil)
+		userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+		activityRepo, recorded := newRecordingActivityRepository()
+
+		passwordResetService := service.NewPasswordResetService(passwordPolicy, newTestPasswordHasher(), userRepo, userTokenRepo,
+			activityRepo, new(MockTransactor), new(MockMailer), authConfig, config.DefaultPasswordResetConfig(), logger)
+		_, err := passwordResetService.ResetPassword(context.Background(), token, "amber-falcon-orbit-91")
+
+		require.NoError(t, err)
+		require.Len(t, *recorded, 1)
+		assert.Equal(t, user.ID, (*recorded)[0].ActorID)
+		assert.Equal(t, user.ID, (*recorded)[0].EntityID)
+		assert.Equal(t, model.ActivityActionPasswordChanged, (*recorded)[0].Action)
+	})
+
+	t.Run("password reset forced by an admin is recorded as the admin's", func(t *testing.T) {
+		adminID := uuid.New()
+		user := &model.User{ID: uuid.New(), Email: "test@example.com", PasswordHash: "hash"}
+		userRepo := new(MockUserRepository)
+		userTokenRepo := new(MockUserTokenRepository)
+		mailer := new(MockMailer)
+		userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+		userRepo.On("UpdatePassword", mock.Anything, user.ID, model.PasswordResetRequiredHash).Return(nil)
+		userRepo.On("GetByEmail", mock.Anything, user.Email).Return(user, nil)
+		userTokenRepo.On("HasRecent", mock.Anything, user.ID, model.UserTokenPasswordReset, config.DefaultPasswordResetCooldown).
+			Return(false, nil)
+		expectTokenIssued(userTokenRepo, user.ID, model.UserTokenPasswordReset, config.DefaultPasswordResetTokenTTL)
+		mailer.On("Send", mock.Anything, mock.Anything).Return(nil)
+		activityRepo, recorded := newRecordingActivityRepository()
+
+		userService := newUserService(userRepo, userTokenRepo, new(MockTodoRepository))
+		adminService := service.NewAdminService(userService, service.NewSessionService(new(MockSessionRepository), logger),
+			newPasswordResetService(userRepo, userTokenRepo, mailer), userRepo, new(MockTodoRepository), activityRepo, new(MockTransactor), logger)
+		err := adminService.ForcePasswordReset(context.Background(), adminID, user.ID)
+
+		require.NoError(t, err)
+		require.Len(t, *recorded, 1)
+		assert.Equal(t, adminID, (*recorded)[0].ActorID)
+		assert.Equal(t, user.ID, (*recorded)[0].EntityID)
+		assert.Equal(t, model.ActivityActionPasswordChanged, (*recorded)[0].Action)
+	})
+
+	t.Run("failure to record fails the password change", func(t *testing.T) {
+		user := newUserWithPassword(t, "current-password")
+		userRepo := new(MockUserRepository)
+		userTokenRepo := new(MockUserTokenRepository)
+		userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+		userRepo.On("UpdatePassword", mock.Anything, user.ID, mock.Anything).Return(nil)
+		userTokenRepo.On("DeleteUnused", mock.Anything, user.ID, model.UserTokenPasswordReset).Return(nil)
+		activityRepo := new(MockActivityRepository)
+		activityRepo.On("Create", mock.Anything, mock.Anything).Return(errors.New("database error"))
+
+		userService := service.NewUserService(nil, passwordPolicy, newTestPasswordHasher(), userRepo, userTokenRepo,
+			new(MockIdentityRepository), activityRepo, new(MockTransactor), authConfig, logger)
+		updated, err := userService.ChangePassword(context.Background(), user.ID, "current-password", "amber-falcon-orbit-91")
+
+		assert.EqualError(t, err, "database error")
+		assert.Nil(t, updated)
+	})
+}
+
+func TestGetTodoActivity(t *testing.T) {
+	logger := zap.NewNop()
+	ctx := context.Background()
+	ownerID := uuid.New()
+	todoID := uuid.New()
+
+	setup := func() (service.ActivityService, *MockActivityRepository) {
+		mockTodoRepo := new(MockTodoRepository)
+		mockTodoRepo.On("GetByID", mock.Anything, todoID).Return(&model.Todo{ID: todoID, UserID: ownerID}, nil)
+		mockShareRepo := new(MockTodoShareRepository)
+		mockShareRepo.On("Get", mock.Anything, todoID, mock.Anything).Return(nil, sql.ErrNoRows)
+		mockActivityRepo := new(MockActivityRepository)
+		todoService := service.NewTodoService(mockTodoRepo, mockShareRepo, mockActivityRepo, new(MockTransactor), config.DefaultTodoQuotaConfig(), logger)
+		return service.NewActivityService(todoService, mockActivityRepo, logger), mockActivityRepo
+	}
+
+	t.Run("pages through events", func(t *testing.T) {
+		activityService, activityRepo := setup()
+
+		now := time.Now()
+		events := []model.ActivityEvent{
+			{ID: uuid.New(), CreatedAt: now},
+			{ID: uuid.New(), CreatedAt: now.Add(-time.Minute)},
+			{ID: uuid.New(), CreatedAt: now.Add(-2 * time.Minute)},
+		}
+		activityRepo.On("GetByTodoID", mock.Anything, todoID, (*model.ActivityCursor)(nil), 3).Return(events, nil)
+
+		page, nextCursor, err := activityService.GetTodoActivity(ctx, ownerID, todoID, "", 2)
+		require.NoError(t, err)
+		assert.Len(t, page, 2)
+		require.NotNil(t, nextCursor)
+
+		// The cursor points at the last event of the page
+		activityRepo.On("GetByTodoID", mock.Anything, todoID, mock.MatchedBy(func(cursor *model.ActivityCursor) bool {
+			return cursor != nil && cursor.ID == events[1].ID
+		}), 3).Return(events[2:], nil)
+
+		page, nextCursor, err = activityService.GetTodoActivity(ctx, ownerID, todoID, *nextCursor, 2)
+		require.NoError(t, err)
+		assert.Len(t, page, 1)
+		assert.Nil(t, nextCursor)
+	})
+
+	t.Run("user without access cannot read", func(t *testing.T) {
+		activityService, activityRepo := setup()
+
+		_, _, err := activityService.GetTodoActivity(ctx, uuid.New(), todoID, "", 0)
+
+		assert.Equal(t, service.ErrUnauthorized, err)
+		activityRepo.AssertNotCalled(t, "GetByTodoID", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		activityService, _ := setup()
+
+		_, _, err := activityService.GetFeed(ctx, ownerID, "not a cursor", 0)
+
+		assert.Equal(t, service.ErrInvalidCursor, err)
+	})
+}
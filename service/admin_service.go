@@ -0,0 +1,284 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultUserPageSize is the number of users returned when no limit is given
+	DefaultUserPageSize = 50
+
+	// MaxUserPageSize is the maximum number of users returned in a single page
+	MaxUserPageSize = 200
+)
+
+// ErrCannotDisableSelf is returned when an admin tries to disable their own account
+var ErrCannotDisableSelf = errors.New("cannot disable own account")
+
+// AdminService defines the interface for the management of users by admins
+type AdminService interface {
+	// ListUsers retrieves a page of the users matching the filter, newest first
+	// It returns the cursor of the next page, or nil if there are no more users
+	ListUsers(ctx context.Context, filter *model.UserFilter, cursor string, limit int) ([]model.User, *string, error)
+
+	// GetUser retrieves the account of the specified user
+	GetUser(ctx context.Context, userID uuid.UUID) (*model.User, error)
+
+	// DisableUser stops the specified user from logging in, revoking their sessions and tokens
+	DisableUser(ctx context.Context, adminID, userID uuid.UUID) (*model.User, error)
+
+	// EnableUser lets the specified disabled user log in again
+	EnableUser(ctx context.Context, adminID, userID uuid.UUID) (*model.User, error)
+
+	// ForcePasswordReset removes the password of the specified user, revoking their sessions,
+	// and emails them a link to choose a new one
+	ForcePasswordReset(ctx context.Context, adminID, userID uuid.UUID) error
+
+	// ListSessions retrieves the sessions of the specified user that can still be refreshed
+	ListSessions(ctx context.Context, userID uuid.UUID) ([]model.Session, error)
+
+	// RevokeSession ends a session of the specified user
+	RevokeSession(ctx context.Context, adminID, userID, sessionID uuid.UUID) error
+
+	// RevokeAllSessions ends every session of the specified user
+	RevokeAllSessions(ctx context.Context, adminID, userID uuid.UUID) error
+
+	// GetTodoStats counts the todos of the specified user
+	GetTodoStats(ctx context.Context, userID uuid.UUID) (*model.TodoStats, error)
+}
+
+// DefaultAdminService implements the AdminService interface
+type DefaultAdminService struct {
+	userService          UserService
+	sessionService       SessionService
+	passwordResetService PasswordResetService
+	userRepo             repository.UserRepository
+	todoRepo             repository.TodoRepository
+	activityRepo         repository.ActivityRepository
+	transactor           repository.Transactor
+	logger               *zap.Logger
+}
+
+// NewAdminService creates a new DefaultAdminService instance
+func NewAdminService(
+	userService UserService,
+	sessionService SessionService,
+	passwordResetService PasswordResetService,
+	userRepo repository.UserRepository,
+	todoRepo repository.TodoRepository,
+	activityRepo repository.ActivityRepository,
+	transactor repository.Transactor,
+	logger *zap.Logger,
+) AdminService {
+	return &DefaultAdminService{
+		userService:          userService,
+		sessionService:       sessionService,
+		passwordResetService: passwordResetService,
+		userRepo:             userRepo,
+		todoRepo:             todoRepo,
+		activityRepo:         activityRepo,
+		transactor:           transactor,
+		logger:               logger,
+	}
+}
+
+// ListUsers retrieves a page of the users matching the filter, newest first
+func (s *DefaultAdminService) ListUsers(ctx context.Context, filter *model.UserFilter, cursor string, limit int) ([]model.User, *string, error) {
+	after, err := decodeUserCursor(cursor)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if limit <= 0 {
+		limit = DefaultUserPageSize
+	}
+	if limit > MaxUserPageSize {
+		limit = MaxUserPageSize
+	}
+
+	// Fetch one extra user to find out whether there is a next page
+	users, err := s.userRepo.List(ctx, filter, after, limit+1)
+	if err != nil {
+		s.logger.Error("failed to list users",
+			zap.Error(err))
+		return nil, nil, err
+	}
+
+	var nextCursor *string
+	if len(users) > limit {
+		users = users[:limit]
+		last := users[limit-1]
+		encoded := encodeUserCursor(&model.UserCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+		nextCursor = &encoded
+	}
+
+	return users, nextCursor, nil
+}
+
+// GetUser retrieves the account of the specified user
+func (s *DefaultAdminService) GetUser(ctx context.Context, userID uuid.UUID) (*model.User, error) {
+	return s.userService.GetUser(ctx, userID)
+}
+
+// DisableUser stops the specified user from logging in, revoking their sessions and tokens
+// Access tokens already issued to the user's sessions stay valid until they expire
+func (s *DefaultAdminService) DisableUser(ctx context.Context, adminID, userID uuid.UUID) (*model.User, error) {
+	if adminID == userID {
+		s.logger.Warn("admin tried to disable own account",
+			zap.String("admin_id", adminID.String()))
+		return nil, ErrCannotDisableSelf
+	}
+
+	return s.updateUser(ctx, adminID, userID, "user disabled", s.userRepo.Disable)
+}
+
+// EnableUser lets the specified disabled user log in again
+func (s *DefaultAdminService) EnableUser(ctx context.Context, adminID, userID uuid.UUID) (*model.User, error) {
+	return s.updateUser(ctx, adminID, userID, "user enabled", s.userRepo.Enable)
+}
+
+// updateUser applies update to the specified user and returns the updated account
+func (s *DefaultAdminService) updateUser(
+	ctx context.Context,
+	adminID, userID uuid.UUID,
+	action string,
+	update func(ctx context.Context, id uuid.UUID) error,
+) (*model.User, error) {
+	if _, err := s.userService.GetUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	if err := update(ctx, userID); err != nil {
+		s.logger.Error("failed to update user in repository",
+			zap.String("admin_id", adminID.String()),
+			zap.String("user_id", userID.String()),
+			zap.String("action", action),
+			zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info(action,
+		zap.String("admin_id", adminID.String()),
+		zap.String("user_id", userID.String()))
+	return s.userService.GetUser(ctx, userID)
+}
+
+// ForcePasswordReset removes the password of the specified user, revoking their sessions,
+// and emails them a link to choose a new one
+// The password is replaced with one that matches nothing rather than removed, so that the user does not look like
+// one without a password, who can change their account with a recent login alone
+// No new link is sent while one sent recently can still be used
+func (s *DefaultAdminService) ForcePasswordReset(ctx context.Context, adminID, userID uuid.UUID) error {
+	user, err := s.userService.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.UpdatePassword(ctx, userID, model.PasswordResetRequiredHash); err != nil {
+			return err
+		}
+		return recordActivity(ctx, s.activityRepo, adminID, model.ActivityEntityUser, userID, nil,
+			model.ActivityActionPasswordChanged, nil, nil)
+	})
+	if err != nil {
+		s.logger.Error("failed to remove password in repository",
+			zap.String("admin_id", adminID.String()),
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return err
+	}
+
+	s.logger.Info("password reset forced",
+		zap.String("admin_id", adminID.String()),
+		zap.String("user_id", userID.String()))
+	return s.passwordResetService.RequestReset(ctx, user.Email)
+}
+
+// ListSessions retrieves the sessions of the specified user that can still be refreshed
+func (s *DefaultAdminService) ListSessions(ctx context.Context, userID uuid.UUID) ([]model.Session, error) {
+	if _, err := s.userService.GetUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	return s.sessionService.List(ctx, userID)
+}
+
+// RevokeSession ends a session of the specified user
+func (s *DefaultAdminService) RevokeSession(ctx context.Context, adminID, userID, sessionID uuid.UUID) error {
+	if err := s.sessionService.Revoke(ctx, userID, sessionID); err != nil {
+		return err
+	}
+
+	s.logger.Info("session revoked by admin",
+		zap.String("admin_id", adminID.String()),
+		zap.String("user_id", userID.String()),
+		zap.String("session_id", sessionID.String()))
+	return nil
+}
+
+// RevokeAllSessions ends every session of the specified user
+func (s *DefaultAdminService) RevokeAllSessions(ctx context.Context, adminID, userID uuid.UUID) error {
+	if _, err := s.userService.GetUser(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := s.sessionService.RevokeAll(ctx, userID); err != nil {
+		return err
+	}
+
+	s.logger.Info("all sessions revoked by admin",
+		zap.String("admin_id", adminID.String()),
+		zap.String("user_id", userID.String()))
+	return nil
+}
+
+// GetTodoStats counts the todos of the specified user
+func (s *DefaultAdminService) GetTodoStats(ctx context.Context, userID uuid.UUID) (*model.TodoStats, error) {
+	if _, err := s.userService.GetUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	stats, err := s.todoRepo.GetStatsByUserID(ctx, userID)
+	if err != nil {
+		s.logger.Error("failed to get todo stats",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// encodeUserCursor encodes a cursor into an opaque string for clients
+func encodeUserCursor(cursor *model.UserCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeUserCursor decodes a cursor received from a client, returning nil for the first page
+func decodeUserCursor(cursor string) (*model.UserCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var decoded model.UserCursor
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.ID == uuid.Nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &decoded, nil
+}
@@ -0,0 +1,232 @@
+package service_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+	"go.uber.org/zap"
+)
+
+// newAdminService creates an AdminService with the mocks
+func newAdminService(
+	userRepo *MockUserRepository,
+	sessionRepo *MockSessionRepository,
+	userTokenRepo *MockUserTokenRepository,
+	mailer *MockMailer,
+	todoRepo *MockTodoRepository,
+) service.AdminService {
+	userService := newUserService(userRepo, userTokenRepo, todoRepo)
+	sessionService := service.NewSessionService(sessionRepo, zap.NewNop())
+	passwordResetService := newPasswordResetService(userRepo, userTokenRepo, mailer)
+	return service.NewAdminService(userService, sessionService, passwordResetService, userRepo, todoRepo,
+		newMockActivityRepository(), new(MockTransactor), zap.NewNop())
+}
+
+func TestAdminListUsers(t *testing.T) {
+	now := time.Now()
+	users := []model.User{
+		{ID: uuid.New(), Email: "c@example.com", CreatedAt: now},
+		{ID: uuid.New(), Email: "b@example.com", CreatedAt: now.Add(-time.Minute)},
+		{ID: uuid.New(), Email: "a@example.com", CreatedAt: now.Add(-2 * time.Minute)},
+	}
+	filter := &model.UserFilter{Query: "example"}
+
+	t.Run("Pages through the users", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		userRepo.On("List", mock.Anything, filter, (*model.UserCursor)(nil), 3).Return(users, nil)
+		userRepo.On("List", mock.Anything, filter, mock.MatchedBy(func(after *model.UserCursor) bool {
+			return after.ID == users[1].ID && after.CreatedAt.Equal(users[1].CreatedAt)
+		}), 3).Return(users[2:], nil)
+		adminService := newAdminService(userRepo, new(MockSessionRepository), new(MockUserTokenRepository), new(MockMailer), new(MockTodoRepository))
+
+		page, nextCursor, err := adminService.ListUsers(context.Background(), filter, "", 2)
+		require.NoError(t, err)
+		assert.Equal(t, users[:2], page)
+		require.NotNil(t, nextCursor)
+
+		page, nextCursor, err = adminService.ListUsers(context.Background(), filter, *nextCursor, 2)
+		require.NoError(t, err)
+		assert.Equal(t, users[2:], page)
+		assert.Nil(t, nextCursor)
+	})
+
+	t.Run("Limit capped", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		userRepo.On("List", mock.Anything, filter, (*model.UserCursor)(nil), service.MaxUserPageSize+1).Return(users, nil)
+		adminService := newAdminService(userRepo, new(MockSessionRepository), new(MockUserTokenRepository), new(MockMailer), new(MockTodoRepository))
+
+		_, _, err := adminService.ListUsers(context.Background(), filter, "", service.MaxUserPageSize+1)
+		assert.NoError(t, err)
+		userRepo.AssertExpectations(t)
+	})
+
+	t.Run("Invalid cursor", func(t *testing.T) {
+		adminService := newAdminService(new(MockUserRepository), new(MockSessionRepository), new(MockUserTokenRepository), new(MockMailer), new(MockTodoRepository))
+
+		_, _, err := adminService.ListUsers(context.Background(), filter, "not-a-cursor", 0)
+		assert.Equal(t, service.ErrInvalidCursor, err)
+	})
+}
+
+func TestAdminDisableUser(t *testing.T) {
+	adminID := uuid.New()
+	userID := uuid.New()
+	disabledAt := time.Now()
+
+	testCases := []struct {
+		name          string
+		userID        uuid.UUID
+		setupMocks    func(*MockUserRepository)
+		expectedError error
+	}{
+		{
+			name:   "Success",
+			userID: userID,
+			setupMocks: func(userRepo *MockUserRepository) {
+				userRepo.On("GetByID", mock.Anything, userID).Return(&model.User{ID: userID}, nil).Once()
+				userRepo.On("Disable", mock.Anything, userID).Return(nil)
+				userRepo.On("GetByID", mock.Anything, userID).Return(&model.User{ID: userID, DisabledAt: &disabledAt}, nil).Once()
+			},
+		},
+		{
+			name:          "Own account",
+			userID:        adminID,
+			setupMocks:    func(userRepo *MockUserRepository) {},
+			expectedError: service.ErrCannotDisableSelf,
+		},
+		{
+			name:   "User not found",
+			userID: userID,
+			setupMocks: func(userRepo *MockUserRepository) {
+				userRepo.On("GetByID", mock.Anything, userID).Return(nil, sql.ErrNoRows)
+			},
+			expectedError: service.ErrUserNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			userRepo := new(MockUserRepository)
+			tc.setupMocks(userRepo)
+			adminService := newAdminService(userRepo, new(MockSessionRepository), new(MockUserTokenRepository), new(MockMailer), new(MockTodoRepository))
+
+			user, err := adminService.DisableUser(context.Background(), adminID, tc.userID)
+
+			assert.Equal(t, tc.expectedError, err)
+			if tc.expectedError == nil {
+				assert.True(t, user.Disabled())
+			}
+			userRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAdminEnableUser(t *testing.T) {
+	adminID := uuid.New()
+	userID := uuid.New()
+	disabledAt := time.Now()
+
+	userRepo := new(MockUserRepository)
+	userRepo.On("GetByID", mock.Anything, userID).Return(&model.User{ID: userID, DisabledAt: &disabledAt}, nil).Once()
+	userRepo.On("Enable", mock.Anything, userID).Return(nil)
+	userRepo.On("GetByID", mock.Anything, userID).Return(&model.User{ID: userID}, nil).Once()
+	adminService := newAdminService(userRepo, new(MockSessionRepository), new(MockUserTokenRepository), new(MockMailer), new(MockTodoRepository))
+
+	user, err := adminService.EnableUser(context.Background(), adminID, userID)
+
+	require.NoError(t, err)
+	assert.False(t, user.Disabled())
+	userRepo.AssertExpectations(t)
+}
+
+func TestAdminForcePasswordReset(t *testing.T) {
+	adminID := uuid.New()
+	user := &model.User{ID: uuid.New(), Email: "test@example.com", PasswordHash: "hash"}
+
+	userRepo := new(MockUserRepository)
+	userTokenRepo := new(MockUserTokenRepository)
+	mailer := new(MockMailer)
+	userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+	userRepo.On("UpdatePassword", mock.Anything, user.ID, model.PasswordResetRequiredHash).Return(nil)
+	userRepo.On("GetByEmail", mock.Anything, user.Email).Return(user, nil)
+	userTokenRepo.On("HasRecent", mock.Anything, user.ID, model.UserTokenPasswordReset, config.DefaultPasswordResetCooldown).
+		Return(false, nil)
+	expectTokenIssued(userTokenRepo, user.ID, model.UserTokenPasswordReset, config.DefaultPasswordResetTokenTTL)
+	var sent *service.Email
+	mailer.On("Send", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		sent = args.Get(1).(*service.Email)
+	}).Return(nil)
+	adminService := newAdminService(userRepo, new(MockSessionRepository), userTokenRepo, mailer, new(MockTodoRepository))
+
+	err := adminService.ForcePasswordReset(context.Background(), adminID, user.ID)
+
+	require.NoError(t, err)
+	require.NotNil(t, sent)
+	assert.Equal(t, user.Email, sent.To)
+	userRepo.AssertExpectations(t)
+}
+
+func TestAdminRevokeAllSessions(t *testing.T) {
+	adminID := uuid.New()
+	userID := uuid.New()
+
+	testCases := []struct {
+		name          string
+		setupMocks    func(*MockUserRepository, *MockSessionRepository)
+		expectedError error
+	}{
+		{
+			name: "Success",
+			setupMocks: func(userRepo *MockUserRepository, sessionRepo *MockSessionRepository) {
+				userRepo.On("GetByID", mock.Anything, userID).Return(&model.User{ID: userID}, nil)
+				sessionRepo.On("DeleteByUserID", mock.Anything, userID).Return(int64(2), nil)
+			},
+		},
+		{
+			name: "User not found",
+			setupMocks: func(userRepo *MockUserRepository, sessionRepo *MockSessionRepository) {
+				userRepo.On("GetByID", mock.Anything, userID).Return(nil, sql.ErrNoRows)
+			},
+			expectedError: service.ErrUserNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			userRepo := new(MockUserRepository)
+			sessionRepo := new(MockSessionRepository)
+			tc.setupMocks(userRepo, sessionRepo)
+			adminService := newAdminService(userRepo, sessionRepo, new(MockUserTokenRepository), new(MockMailer), new(MockTodoRepository))
+
+			err := adminService.RevokeAllSessions(context.Background(), adminID, userID)
+
+			assert.Equal(t, tc.expectedError, err)
+			sessionRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAdminGetTodoStats(t *testing.T) {
+	userID := uuid.New()
+	stats := &model.TodoStats{Total: 5, Completed: 2, Overdue: 1, Assigned: 3}
+
+	userRepo := new(MockUserRepository)
+	todoRepo := new(MockTodoRepository)
+	userRepo.On("GetByID", mock.Anything, userID).Return(&model.User{ID: userID}, nil)
+	todoRepo.On("GetStatsByUserID", mock.Anything, userID).Return(stats, nil)
+	adminService := newAdminService(userRepo, new(MockSessionRepository), new(MockUserTokenRepository), new(MockMailer), todoRepo)
+
+	result, err := adminService.GetTodoStats(context.Background(), userID)
+
+	require.NoError(t, err)
+	assert.Equal(t, stats, result)
+}
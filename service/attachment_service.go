@@ -0,0 +1,380 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+	"go.uber.org/zap"
+)
+
+// sniffLength is the number of bytes http.DetectContentType looks at
+const sniffLength = 512
+
+var (
+	// ErrAttachmentNotFound is returned when an attachment with the specified ID is not found on the todo
+	ErrAttachmentNotFound = errors.New("attachment not found")
+
+	// ErrEmptyAttachment is returned when the uploaded file is empty
+	ErrEmptyAttachment = errors.New("attachment is empty")
+
+	// ErrAttachmentTooLarge is returned when the uploaded file exceeds the maximum file size
+	ErrAttachmentTooLarge = errors.New("attachment is too large")
+
+	// ErrAttachmentQuotaExceeded is returned when the upload would exceed the user's quota
+	ErrAttachmentQuotaExceeded = errors.New("attachment quota exceeded")
+
+	// ErrUnsupportedAttachmentType is returned when the sniffed content type is not allowed
+	ErrUnsupportedAttachmentType = errors.New("unsupported attachment type")
+)
+
+// AttachmentService defines the interface for attachment-related business logic
+type AttachmentService interface {
+	// UploadAttachment stores a file and attaches it to a todo the specified user can edit
+	UploadAttachment(ctx context.Context, userID uuid.UUID, todoID uuid.UUID, fileName string, size int64, body io.Reader) (*model.Attachment, error)
+
+	// GetAttachments retrieves the attachments of a todo the specified user can view
+	GetAttachments(ctx context.Context, userID uuid.UUID, todoID uuid.UUID) ([]model.Attachment, error)
+
+	// DownloadAttachment opens an attachment of a todo the specified user can view
+	// The caller must close the returned reader
+	DownloadAttachment(ctx context.Context, userID uuid.UUID, todoID uuid.UUID, attachmentID uuid.UUID) (*model.Attachment, io.ReadCloser, error)
+
+	// DeleteAttachment removes an attachment, ensuring the specified user uploaded it or owns the todo
+	DeleteAttachment(ctx context.Context, userID uuid.UUID, todoID uuid.UUID, attachmentID uuid.UUID) error
+
+	// PurgeDeletedBlobs removes the blobs of deleted attachments from the blob store
+	// It returns the number of blobs purged
+	PurgeDeletedBlobs(ctx context.Context) (int, error)
+}
+
+// DefaultAttachmentService implements the AttachmentService interface
+type DefaultAttachmentService struct {
+	todoService      TodoService
+	attachmentRepo   repository.AttachmentRepository
+	activityRepo     repository.ActivityRepository
+	transactor       repository.Transactor
+	blobStore        repository.BlobStore
+	attachmentConfig *config.AttachmentConfig
+	logger           *zap.Logger
+}
+
+// NewAttachmentService creates a new DefaultAttachmentService instance
+func NewAttachmentService(
+	todoService TodoService,
+	attachmentRepo repository.AttachmentRepository,
+	activityRepo repository.ActivityRepository,
+	transactor repository.Transactor,
+	blobStore repository.BlobStore,
+	attachmentConfig *config.AttachmentConfig,
+	logger *zap.Logger,
+) AttachmentService {
+	return &DefaultAttachmentService{
+		todoService:      todoService,
+		attachmentRepo:   attachmentRepo,
+		activityRepo:     activityRepo,
+		transactor:       transactor,
+		blobStore:        blobStore,
+		attachmentConfig: attachmentConfig,
+		logger:           logger,
+	}
+}
+
+// UploadAttachment stores a file and attaches it to a todo the specified user can edit
+func (s *DefaultAttachmentService) UploadAttachment(ctx context.Context, userID uuid.UUID, todoID uuid.UUID, fileName string, size int64, body io.Reader) (*model.Attachment, error) {
+	_, _, err := s.todoService.AuthorizeTodo(ctx, userID, todoID, model.ShareRoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	if size <= 0 {
+		return nil, ErrEmptyAttachment
+	}
+	if size > s.attachmentConfig.MaxFileSize {
+		return nil, ErrAttachmentTooLarge
+	}
+
+	// Refuse early what cannot fit, before the file is stored
+	if err := s.checkQuota(ctx, userID, size, s.attachmentRepo.GetTotalSizeByUploaderID); err != nil {
+		return nil, err
+	}
+
+	// Sniff the content type from the file contents instead of trusting the client
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(body, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	head = head[:n]
+	contentType := http.DetectContentType(head)
+	if !s.isAllowedContentType(contentType) {
+		s.logger.Warn("unsupported attachment type",
+			zap.String("user_id", userID.String()),
+			zap.String("content_type", contentType))
+		return nil, ErrUnsupportedAttachmentType
+	}
+
+	attachment := &model.Attachment{
+		ID:          uuid.New(),
+		TodoID:      todoID,
+		UploaderID:  userID,
+		FileName:    sanitizeFileName(fileName),
+		ContentType: contentType,
+		Size:        size,
+	}
+	attachment.StorageKey = "attachments/" + attachment.ID.String()
+
+	// Never store more than the declared size, which the limits were checked against
+	content := io.LimitReader(io.MultiReader(bytes.NewReader(head), body), size)
+	err = s.blobStore.Put(ctx, attachment.StorageKey, content, size, contentType)
+	if err != nil {
+		s.logger.Error("failed to store attachment blob",
+			zap.String("user_id", userID.String()),
+			zap.String("todo_id", todoID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		// Check the quota again with the user locked, as concurrent uploads may have used it meanwhile
+		if err := s.checkQuota(ctx, userID, size, s.attachmentRepo.GetTotalSizeByUploaderIDForUpdate); err != nil {
+			return err
+		}
+		if err := s.attachmentRepo.Create(ctx, attachment); err != nil {
+			return err
+		}
+		return recordActivity(ctx, s.activityRepo, userID, model.ActivityEntityAttachment, attachment.ID, &todoID,
+			model.ActivityActionCreated, nil, attachmentSnapshot(attachment))
+	})
+	if err != nil {
+		if err != ErrAttachmentQuotaExceeded {
+			s.logger.Error("failed to create attachment",
+				zap.String("user_id", userID.String()),
+				zap.String("todo_id", todoID.String()),
+				zap.Error(err))
+		}
+		if deleteErr := s.blobStore.Delete(ctx, attachment.StorageKey); deleteErr != nil {
+			s.logger.Error("failed to remove blob of failed attachment",
+				zap.String("storage_key", attachment.StorageKey),
+				zap.Error(deleteErr))
+		}
+		return nil, err
+	}
+
+	s.logger.Info("attachment uploaded successfully",
+		zap.String("user_id", userID.String()),
+		zap.String("todo_id", todoID.String()),
+		zap.String("attachment_id", attachment.ID.String()),
+		zap.Int64("size", size))
+
+	// Reload to get the timestamps
+	return s.getAttachment(ctx, todoID, attachment.ID)
+}
+
+// GetAttachments retrieves the attachments of a todo the specified user can view
+func (s *DefaultAttachmentService) GetAttachments(ctx context.Context, userID uuid.UUID, todoID uuid.UUID) ([]model.Attachment, error) {
+	_, _, err := s.todoService.AuthorizeTodo(ctx, userID, todoID, model.ShareRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	attachments, err := s.attachmentRepo.GetByTodoID(ctx, todoID)
+	if err != nil {
+		s.logger.Error("failed to get attachments",
+			zap.String("user_id", userID.String()),
+			zap.String("todo_id", todoID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	return attachments, nil
+}
+
+// DownloadAttachment opens an attachment of a todo the specified user can view
+func (s *DefaultAttachmentService) DownloadAttachment(ctx context.Context, userID uuid.UUID, todoID uuid.UUID, attachmentID uuid.UUID) (*model.Attachment, io.ReadCloser, error) {
+	_, _, err := s.todoService.AuthorizeTodo(ctx, userID, todoID, model.ShareRoleViewer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	attachment, err := s.getAttachment(ctx, todoID, attachmentID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	body, err := s.blobStore.Get(ctx, attachment.StorageKey)
+	if err != nil {
+		s.logger.Error("failed to get attachment blob",
+			zap.String("attachment_id", attachmentID.String()),
+			zap.String("storage_key", attachment.StorageKey),
+			zap.Error(err))
+		if errors.Is(err, repository.ErrBlobNotFound) {
+			return nil, nil, ErrAttachmentNotFound
+		}
+		return nil, nil, err
+	}
+
+	return attachment, body, nil
+}
+
+// DeleteAttachment removes an attachment, ensuring the specified user uploaded it or owns the todo
+func (s *DefaultAttachmentService) DeleteAttachment(ctx context.Context, userID uuid.UUID, todoID uuid.UUID, attachmentID uuid.UUID) error {
+	_, role, err := s.todoService.AuthorizeTodo(ctx, userID, todoID, model.ShareRoleEditor)
+	if err != nil {
+		return err
+	}
+
+	attachment, err := s.getAttachment(ctx, todoID, attachmentID)
+	if err != nil {
+		return err
+	}
+
+	if attachment.UploaderID != userID && role != model.ShareRoleOwner {
+		s.logger.Warn("attempt to delete another user's attachment",
+			zap.String("user_id", userID.String()),
+			zap.String("attachment_id", attachmentID.String()),
+			zap.String("uploader_id", attachment.UploaderID.String()))
+		return ErrUnauthorized
+	}
+
+	// The blob is queued for deletion in the same statement and purged in the background
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.attachmentRepo.Delete(ctx, attachmentID); err != nil {
+			return err
+		}
+		return recordActivity(ctx, s.activityRepo, userID, model.ActivityEntityAttachment, attachmentID, &todoID,
+			model.ActivityActionDeleted, attachmentSnapshot(attachment), nil)
+	})
+	if err != nil {
+		s.logger.Error("failed to delete attachment",
+			zap.String("user_id", userID.String()),
+			zap.String("attachment_id", attachmentID.String()),
+			zap.Error(err))
+		return err
+	}
+
+	s.logger.Info("attachment deleted successfully",
+		zap.String("user_id", userID.String()),
+		zap.String("todo_id", todoID.String()),
+		zap.String("attachment_id", attachmentID.String()))
+	return nil
+}
+
+// PurgeDeletedBlobs removes the blobs of deleted attachments from the blob store
+func (s *DefaultAttachmentService) PurgeDeletedBlobs(ctx context.Context) (int, error) {
+	keys, err := s.attachmentRepo.GetPendingBlobDeletions(ctx, 100)
+	if err != nil {
+		s.logger.Error("failed to get pending blob deletions",
+			zap.Error(err))
+		return 0, err
+	}
+
+	purged := 0
+	for _, key := range keys {
+		if err := s.blobStore.Delete(ctx, key); err != nil {
+			// Leave it in the queue to be retried on the next run
+			s.logger.Error("failed to purge blob",
+				zap.String("storage_key", key),
+				zap.Error(err))
+			continue
+		}
+		if err := s.attachmentRepo.RemovePendingBlobDeletion(ctx, key); err != nil {
+			s.logger.Error("failed to remove purged blob from queue",
+				zap.String("storage_key", key),
+				zap.Error(err))
+			continue
+		}
+		purged++
+	}
+
+	if purged > 0 {
+		s.logger.Info("purged deleted blobs",
+			zap.Int("count", purged))
+	}
+	return purged, nil
+}
+
+// getAttachment retrieves attachment metadata, ensuring it belongs to the todo
+func (s *DefaultAttachmentService) getAttachment(ctx context.Context, todoID uuid.UUID, attachmentID uuid.UUID) (*model.Attachment, error) {
+	attachment, err := s.attachmentRepo.GetByID(ctx, attachmentID)
+	if err != nil {
+		s.logger.Error("failed to get attachment",
+			zap.String("todo_id", todoID.String()),
+			zap.String("attachment_id", attachmentID.String()),
+			zap.Error(err))
+		return nil, ErrAttachmentNotFound
+	}
+
+	if attachment.TodoID != todoID {
+		return nil, ErrAttachmentNotFound
+	}
+
+	return attachment, nil
+}
+
+// checkQuota ensures an attachment of the size fits in the quota of the user, given the total size
+// of the attachments the user uploaded
+func (s *DefaultAttachmentService) checkQuota(
+	ctx context.Context,
+	userID uuid.UUID,
+	size int64,
+	usage func(ctx context.Context, uploaderID uuid.UUID) (int64, error),
+) error {
+	used, err := usage(ctx, userID)
+	if err != nil {
+		s.logger.Error("failed to get attachment usage",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return err
+	}
+	if used+size > s.attachmentConfig.UserQuota {
+		s.logger.Warn("attachment quota exceeded",
+			zap.String("user_id", userID.String()),
+			zap.Int64("used", used),
+			zap.Int64("size", size))
+		return ErrAttachmentQuotaExceeded
+	}
+	return nil
+}
+
+// attachmentSnapshot returns the recorded state of an attachment
+func attachmentSnapshot(attachment *model.Attachment) activitySnapshot {
+	return activitySnapshot{
+		"fileName":    attachment.FileName,
+		"contentType": attachment.ContentType,
+		"size":        attachment.Size,
+	}
+}
+
+// isAllowedContentType reports whether the sniffed content type can be uploaded
+func (s *DefaultAttachmentService) isAllowedContentType(contentType string) bool {
+	for _, allowed := range s.attachmentConfig.AllowedContentTypes {
+		if strings.EqualFold(allowed, contentType) {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizeFileName strips any directory part and control characters from a client supplied file name
+func sanitizeFileName(fileName string) string {
+	fileName = filepath.Base(strings.ReplaceAll(fileName, "\\", "/"))
+	fileName = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || r == '"' {
+			return -1
+		}
+		return r
+	}, fileName)
+	if fileName == "" || fileName == "." || fileName == "/" {
+		return "attachment"
+	}
+	return fileName
+}
@@ -0,0 +1,308 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+	"github.com/yukimaterrace/todoms/service"
+	"go.uber.org/zap"
+)
+
+// pngHeader is the signature http.DetectContentType recognises as image/png
+var pngHeader = []byte("\x89PNG\x0D\x0A\x1A\x0A")
+
+// setupAttachmentService creates an attachment service for a todo owned by ownerID,
+// shared with editorID as editor and with viewerID as viewer
+func setupAttachmentService(ownerID, editorID, viewerID, todoID uuid.UUID) (service.AttachmentService, *MockAttachmentRepository, *MockBlobStore) {
+	logger := zap.NewNop()
+
+	mockTodoRepo := new(MockTodoRepository)
+	mockTodoRepo.On("GetByID", mock.Anything, todoID).Return(&model.Todo{ID: todoID, UserID: ownerID}, nil)
+	mockShareRepo := new(MockTodoShareRepository)
+	mockShareRepo.On("Get", mock.Anything, todoID, editorID).Return(&model.TodoShare{Role: model.ShareRoleEditor}, nil)
+	mockShareRepo.On("Get", mock.Anything, todoID, viewerID).Return(&model.TodoShare{Role: model.ShareRoleViewer}, nil)
+	mockShareRepo.On("Get", mock.Anything, todoID, mock.Anything).Return(nil, sql.ErrNoRows)
+	mockAttachmentRepo := new(MockAttachmentRepository)
+	mockBlobStore := new(MockBlobStore)
+
+	attachmentConfig := &config.AttachmentConfig{
+		MaxFileSize:         1024,
+		UserQuota:           4096,
+		AllowedContentTypes: []string{"image/png", "text/plain; charset=utf-8"},
+	}
+
+	todoService := service.NewTodoService(mockTodoRepo, mockShareRepo, newMockActivityRepository(), new(MockTransactor), config.DefaultTodoQuotaConfig(), logger)
+	attachmentService := service.NewAttachmentService(todoService, mockAttachmentRepo, newMockActivityRepository(), new(MockTransactor), mockBlobStore, attachmentConfig, logger)
+	return attachmentService, mockAttachmentRepo, mockBlobStore
+}
+
+func TestUploadAttachment(t *testing.T) {
+	ctx := context.Background()
+	ownerID := uuid.New()
+	editorID := uuid.New()
+	viewerID := uuid.New()
+	todoID := uuid.New()
+	png := append(append([]byte{}, pngHeader...), bytes.Repeat([]byte{0}, 100)...)
+
+	t.Run("editor uploads a sniffed png", func(t *testing.T) {
+		attachmentService, attachmentRepo, blobStore := setupAttachmentService(ownerID, editorID, viewerID, todoID)
+
+		var created *model.Attachment
+		attachmentRepo.On("GetTotalSizeByUploaderID", mock.Anything, editorID).Return(int64(0), nil)
+		attachmentRepo.On("GetTotalSizeByUploaderIDForUpdate", mock.Anything, editorID).Return(int64(0), nil)
+		blobStore.On("Put", mock.Anything, mock.AnythingOfType("string"), int64(len(png)), "image/png").Return(nil)
+		attachmentRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.Attachment")).Return(nil).Run(func(args mock.Arguments) {
+			created = args.Get(1).(*model.Attachment)
+		})
+		attachmentRepo.On("GetByID", mock.Anything, mock.AnythingOfType("uuid.UUID")).Return(&model.Attachment{TodoID: todoID, UploaderID: editorID}, nil)
+
+		attachment, err := attachmentService.UploadAttachment(ctx, editorID, todoID, "../../etc/photo.txt", int64(len(png)), bytes.NewReader(png))
+
+		require.NoError(t, err)
+		assert.Equal(t, editorID, attachment.UploaderID)
+		assert.Equal(t, "photo.txt", created.FileName)
+		assert.Equal(t, "image/png", created.ContentType)
+		assert.Equal(t, "attachments/"+created.ID.String(), created.StorageKey)
+		assert.Equal(t, png, blobStore.stored)
+	})
+
+	t.Run("content beyond the declared size is not stored", func(t *testing.T) {
+		attachmentService, attachmentRepo, blobStore := setupAttachmentService(ownerID, editorID, viewerID, todoID)
+
+		attachmentRepo.On("GetTotalSizeByUploaderID", mock.Anything, ownerID).Return(int64(0), nil)
+		attachmentRepo.On("GetTotalSizeByUploaderIDForUpdate", mock.Anything, ownerID).Return(int64(0), nil)
+		blobStore.On("Put", mock.Anything, mock.Anything, int64(5), "text/plain; charset=utf-8").Return(nil)
+		attachmentRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+		attachmentRepo.On("GetByID", mock.Anything, mock.Anything).Return(&model.Attachment{TodoID: todoID}, nil)
+
+		_, err := attachmentService.UploadAttachment(ctx, ownerID, todoID, "note.txt", 5, strings.NewReader("hello, world"))
+
+		require.NoError(t, err)
+		assert.Equal(t, []byte("hello"), blobStore.stored)
+	})
+
+	tests := []struct {
+		name      string
+		userID    uuid.UUID
+		size      int64
+		content   []byte
+		used      int64
+		wantError error
+	}{
+		{
+			name:      "viewer cannot upload",
+			userID:    viewerID,
+			size:      int64(len(png)),
+			content:   png,
+			wantError: service.ErrUnauthorized,
+		},
+		{
+			name:      "empty file",
+			userID:    editorID,
+			size:      0,
+			content:   nil,
+			wantError: service.ErrEmptyAttachment,
+		},
+		{
+			name:      "file too large",
+			userID:    editorID,
+			size:      1025,
+			content:   png,
+			wantError: service.ErrAttachmentTooLarge,
+		},
+		{
+			name:      "quota exceeded",
+			userID:    editorID,
+			size:      int64(len(png)),
+			content:   png,
+			used:      4000,
+			wantError: service.ErrAttachmentQuotaExceeded,
+		},
+		{
+			name:      "disallowed type",
+			userID:    editorID,
+			size:      5,
+			content:   []byte("%PDF-1.7"),
+			wantError: service.ErrUnsupportedAttachmentType,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			attachmentService, attachmentRepo, blobStore := setupAttachmentService(ownerID, editorID, viewerID, todoID)
+			attachmentRepo.On("GetTotalSizeByUploaderID", mock.Anything, tc.userID).Return(tc.used, nil).Maybe()
+
+			attachment, err := attachmentService.UploadAttachment(ctx, tc.userID, todoID, "file", tc.size, bytes.NewReader(tc.content))
+
+			assert.Equal(t, tc.wantError, err)
+			assert.Nil(t, attachment)
+			blobStore.AssertNotCalled(t, "Put", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			attachmentRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+		})
+	}
+
+	t.Run("quota used by a concurrent upload is checked before saving", func(t *testing.T) {
+		attachmentService, attachmentRepo, blobStore := setupAttachmentService(ownerID, editorID, viewerID, todoID)
+
+		var key string
+		attachmentRepo.On("GetTotalSizeByUploaderID", mock.Anything, editorID).Return(int64(0), nil)
+		blobStore.On("Put", mock.Anything, mock.AnythingOfType("string"), mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+			key = args.String(1)
+		})
+		attachmentRepo.On("GetTotalSizeByUploaderIDForUpdate", mock.Anything, editorID).Return(int64(4000), nil)
+		blobStore.On("Delete", mock.Anything, mock.AnythingOfType("string")).Return(nil)
+
+		attachment, err := attachmentService.UploadAttachment(ctx, editorID, todoID, "image.png", int64(len(png)), bytes.NewReader(png))
+
+		assert.Equal(t, service.ErrAttachmentQuotaExceeded, err)
+		assert.Nil(t, attachment)
+		attachmentRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+		blobStore.AssertCalled(t, "Delete", mock.Anything, key)
+	})
+
+	t.Run("blob is removed when metadata cannot be saved", func(t *testing.T) {
+		attachmentService, attachmentRepo, blobStore := setupAttachmentService(ownerID, editorID, viewerID, todoID)
+
+		var key string
+		attachmentRepo.On("GetTotalSizeByUploaderID", mock.Anything, editorID).Return(int64(0), nil)
+		attachmentRepo.On("GetTotalSizeByUploaderIDForUpdate", mock.Anything, editorID).Return(int64(0), nil)
+		blobStore.On("Put", mock.Anything, mock.AnythingOfType("string"), mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+			key = args.String(1)
+		})
+		attachmentRepo.On("Create", mock.Anything, mock.Anything).Return(errors.New("database error"))
+		blobStore.On("Delete", mock.Anything, mock.AnythingOfType("string")).Return(nil)
+
+		_, err := attachmentService.UploadAttachment(ctx, editorID, todoID, "image.png", int64(len(png)), bytes.NewReader(png))
+
+		assert.Error(t, err)
+		blobStore.AssertCalled(t, "Delete", mock.Anything, key)
+	})
+}
+
+func TestDownloadAttachment(t *testing.T) {
+	ctx := context.Background()
+	ownerID := uuid.New()
+	editorID := uuid.New()
+	viewerID := uuid.New()
+	todoID := uuid.New()
+	attachmentID := uuid.New()
+
+	t.Run("viewer downloads attachment", func(t *testing.T) {
+		attachmentService, attachmentRepo, blobStore := setupAttachmentService(ownerID, editorID, viewerID, todoID)
+		attachmentRepo.On("GetByID", mock.Anything, attachmentID).Return(&model.Attachment{ID: attachmentID, TodoID: todoID, StorageKey: "attachments/a"}, nil)
+		blobStore.On("Get", mock.Anything, "attachments/a").Return(io.NopCloser(strings.NewReader("data")), nil)
+
+		attachment, body, err := attachmentService.DownloadAttachment(ctx, viewerID, todoID, attachmentID)
+
+		require.NoError(t, err)
+		defer body.Close()
+		assert.Equal(t, attachmentID, attachment.ID)
+		data, _ := io.ReadAll(body)
+		assert.Equal(t, "data", string(data))
+	})
+
+	t.Run("attachment of another todo is not found", func(t *testing.T) {
+		attachmentService, attachmentRepo, blobStore := setupAttachmentService(ownerID, editorID, viewerID, todoID)
+		attachmentRepo.On("GetByID", mock.Anything, attachmentID).Return(&model.Attachment{ID: attachmentID, TodoID: uuid.New()}, nil)
+
+		_, _, err := attachmentService.DownloadAttachment(ctx, viewerID, todoID, attachmentID)
+
+		assert.Equal(t, service.ErrAttachmentNotFound, err)
+		blobStore.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+	})
+
+	t.Run("missing blob is not found", func(t *testing.T) {
+		attachmentService, attachmentRepo, blobStore := setupAttachmentService(ownerID, editorID, viewerID, todoID)
+		attachmentRepo.On("GetByID", mock.Anything, attachmentID).Return(&model.Attachment{ID: attachmentID, TodoID: todoID, StorageKey: "attachments/a"}, nil)
+		blobStore.On("Get", mock.Anything, "attachments/a").Return(nil, repository.ErrBlobNotFound)
+
+		_, _, err := attachmentService.DownloadAttachment(ctx, viewerID, todoID, attachmentID)
+
+		assert.Equal(t, service.ErrAttachmentNotFound, err)
+	})
+}
+
+func TestDeleteAttachment(t *testing.T) {
+	ctx := context.Background()
+	ownerID := uuid.New()
+	editorID := uuid.New()
+	viewerID := uuid.New()
+	todoID := uuid.New()
+	attachmentID := uuid.New()
+
+	tests := []struct {
+		name       string
+		userID     uuid.UUID
+		uploaderID uuid.UUID
+		wantError  error
+	}{
+		{
+			name:       "uploader can delete",
+			userID:     editorID,
+			uploaderID: editorID,
+		},
+		{
+			name:       "owner can delete any attachment",
+			userID:     ownerID,
+			uploaderID: editorID,
+		},
+		{
+			name:       "editor cannot delete another user's attachment",
+			userID:     editorID,
+			uploaderID: ownerID,
+			wantError:  service.ErrUnauthorized,
+		},
+		{
+			name:       "viewer cannot delete",
+			userID:     viewerID,
+			uploaderID: viewerID,
+			wantError:  service.ErrUnauthorized,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			attachmentService, attachmentRepo, _ := setupAttachmentService(ownerID, editorID, viewerID, todoID)
+			attachmentRepo.On("GetByID", mock.Anything, attachmentID).Return(&model.Attachment{ID: attachmentID, TodoID: todoID, UploaderID: tc.uploaderID}, nil).Maybe()
+			attachmentRepo.On("Delete", mock.Anything, attachmentID).Return(nil).Maybe()
+
+			err := attachmentService.DeleteAttachment(ctx, tc.userID, todoID, attachmentID)
+
+			assert.Equal(t, tc.wantError, err)
+			if tc.wantError == nil {
+				attachmentRepo.AssertCalled(t, "Delete", mock.Anything, attachmentID)
+			} else {
+				attachmentRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestPurgeDeletedBlobs(t *testing.T) {
+	ctx := context.Background()
+	attachmentService, attachmentRepo, blobStore := setupAttachmentService(uuid.New(), uuid.New(), uuid.New(), uuid.New())
+
+	attachmentRepo.On("GetPendingBlobDeletions", mock.Anything, mock.Anything).Return([]string{"attachments/a", "attachments/b"}, nil)
+	blobStore.On("Delete", mock.Anything, "attachments/a").Return(nil)
+	blobStore.On("Delete", mock.Anything, "attachments/b").Return(errors.New("storage unavailable"))
+	attachmentRepo.On("RemovePendingBlobDeletion", mock.Anything, "attachments/a").Return(nil)
+
+	purged, err := attachmentService.PurgeDeletedBlobs(ctx)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	// A failed deletion stays queued for the next run
+	attachmentRepo.AssertNotCalled(t, "RemovePendingBlobDeletion", mock.Anything, "attachments/b")
+}
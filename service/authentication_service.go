@@ -2,15 +2,18 @@ package service
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
 	"github.com/yukimaterrace/todoms/repository"
 	"go.uber.org/zap"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // TokenPair represents a pair of access and refresh tokens
@@ -19,6 +22,14 @@ type TokenPair struct {
 	RefreshToken string `json:"refresh_token"`
 }
 
+// LoginResult represents the result of checking a user's password
+// It holds a token pair, or a challenge token to complete the login with an MFA code when the user has MFA enabled
+type LoginResult struct {
+	*TokenPair
+	MFARequired    bool   `json:"mfa_required,omitempty"`
+	ChallengeToken string `json:"challenge_token,omitempty"`
+}
+
 // TokenType represents the type of JWT token
 type TokenType string
 
@@ -28,6 +39,9 @@ const (
 
 	// RefreshToken is a long-lived token used for obtaining new access tokens
 	RefreshToken TokenType = "refresh"
+
+	// MFAChallengeToken is a short-lived token exchanged for a token pair with an MFA code
+	MFAChallengeToken TokenType = "mfa_challenge"
 )
 
 // Custom errors for authentication service
@@ -38,70 +52,213 @@ var (
 	ErrInvalidToken       = errors.New("invalid token")
 	ErrExpiredToken       = errors.New("token has expired")
 	ErrInvalidTokenType   = errors.New("invalid token type")
+	ErrEmailNotVerified   = errors.New("email address is not verified")
+	ErrAccountDisabled    = errors.New("account is disabled")
 )
 
 // AuthenticationService defines the interface for authentication operations
 type AuthenticationService interface {
-	// Authenticate validates user credentials and returns a token pair if valid
-	Authenticate(ctx context.Context, email, password string) (*TokenPair, error)
+	// Authenticate validates user credentials and returns a token pair if valid,
+	// or an MFA challenge token instead when the user has MFA enabled
+	Authenticate(ctx context.Context, email, password string) (*LoginResult, error)
+
+	// ValidateMFAChallenge validates an MFA challenge token and returns the claims
+	ValidateMFAChallenge(challengeToken string) (*Claims, error)
+
+	// VerifyMFA exchanges an MFA challenge token and a TOTP or recovery code for a token pair
+	VerifyMFA(ctx context.Context, challengeToken, code string) (*TokenPair, error)
+
+	// AuthenticatePasskey verifies the response of an authenticator to a passkey login and returns a token pair
+	AuthenticatePasskey(ctx context.Context, credential *model.AssertionCredential) (*TokenPair, error)
+
+	// AuthenticateOIDC completes a login with an external OpenID Connect provider and returns a token pair,
+	// or an MFA challenge token instead when the user has MFA enabled
+	AuthenticateOIDC(ctx context.Context, provider, code, state string) (*LoginResult, error)
 
 	// ValidateToken validates a JWT token and returns the claims
 	ValidateToken(tokenString string) (*Claims, error)
 
 	// RefreshToken takes a refresh token and returns a new token pair
 	RefreshToken(ctx context.Context, refreshToken string) (*TokenPair, error)
+
+	// IssueTokenPair starts a new session for a user who has already been authenticated and returns its token pair
+	IssueTokenPair(ctx context.Context, user *model.User) (*TokenPair, error)
+
+	// CurrentUser loads the user of validated claims as they are now,
+	// checking that the token and its session have not been revoked and the account is not disabled
+	CurrentUser(ctx context.Context, claims *Claims) (*model.User, error)
 }
 
 // Claims represents the JWT claims structure
+// SessionID is set for the token pairs of a login session, and Roles for the tokens the user logged in for
+// AuthTime is when the user logged in for the session, and is kept when the token pair is refreshed
+// ClientID and Scopes are only set for access tokens issued to OAuth clients, which are opaque rather than JWTs
 type Claims struct {
-	UserID string `json:"user_id"`
-	Email  string `json:"email"`
-	Type   string `json:"type"`
+	UserID         string           `json:"user_id"`
+	Email          string           `json:"email"`
+	EmailVerified  bool             `json:"email_verified"`
+	SessionVersion int              `json:"session_version"`
+	Type           string           `json:"type"`
+	SessionID      string           `json:"sid,omitempty"`
+	AuthTime       *jwt.NumericDate `json:"auth_time,omitempty"`
+	Roles          []string         `json:"roles,omitempty"`
+	ClientID       string           `json:"client_id,omitempty"`
+	TokenID        string           `json:"token_id,omitempty"`
+	Scopes         []string         `json:"scopes,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// Delegated reports whether the token was issued to a third-party client or is a personal access token,
+// which only have access within their scopes
+func (c *Claims) Delegated() bool {
+	return c.ClientID != "" || c.TokenID != ""
+}
+
+// HasScope reports whether the token grants the scope
+// Tokens the user logged in for grant every scope
+func (c *Claims) HasScope(scope string) bool {
+	return !c.Delegated() || slices.Contains(c.Scopes, scope)
+}
+
+// AuthenticatedAt returns when the user logged in for the session of the token
+// It is the zero time for tokens that do not belong to a login session
+func (c *Claims) AuthenticatedAt() time.Time {
+	if c.AuthTime == nil {
+		return time.Time{}
+	}
+	return c.AuthTime.Time
+}
+
+// HasRole reports whether the token grants the role
+// Tokens issued to third-party clients and personal access tokens grant no role
+func (c *Claims) HasRole(role string) bool {
+	return slices.Contains(c.Roles, role)
+}
+
 // JWTAuthService implements the AuthenticationService interface using JWT
 type JWTAuthService struct {
-	userRepo   repository.UserRepository
-	authConfig *config.AuthConfig
-	logger     *zap.Logger
+	userRepo        repository.UserRepository
+	sessionRepo     repository.SessionRepository
+	passwordHasher  PasswordHasher
+	mfaService      MFAService
+	webAuthnService WebAuthnService
+	oidcService     OIDCService
+	authConfig      *config.AuthConfig
+	logger          *zap.Logger
 }
 
 // NewJWTAuthService creates a new JWT authentication service
 func NewJWTAuthService(
 	userRepo repository.UserRepository,
+	sessionRepo repository.SessionRepository,
+	passwordHasher PasswordHasher,
+	mfaService MFAService,
+	webAuthnService WebAuthnService,
+	oidcService OIDCService,
 	authConfig *config.AuthConfig,
 	logger *zap.Logger,
 ) AuthenticationService {
 	return &JWTAuthService{
-		userRepo:   userRepo,
-		authConfig: authConfig,
-		logger:     logger,
+		userRepo:        userRepo,
+		sessionRepo:     sessionRepo,
+		passwordHasher:  passwordHasher,
+		mfaService:      mfaService,
+		webAuthnService: webAuthnService,
+		oidcService:     oidcService,
+		authConfig:      authConfig,
+		logger:          logger,
 	}
 }
 
-// Authenticate validates user credentials and returns a token pair if valid
-func (s *JWTAuthService) Authenticate(ctx context.Context, email, password string) (*TokenPair, error) {
+// Authenticate validates user credentials and returns a token pair if valid,
+// or an MFA challenge token instead when the user has MFA enabled
+func (s *JWTAuthService) Authenticate(ctx context.Context, email, password string) (*LoginResult, error) {
 	// Get user by email
+	// An unknown email fails the same way as a wrong password, after the same hashing work,
+	// so that it does not reveal whether the email is registered
 	user, err := s.userRepo.GetByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		s.passwordHasher.Hash(password)
+		s.logger.Warn("invalid credentials attempt",
+			zap.String("email", email))
+		return nil, ErrInvalidCredentials
+	}
 	if err != nil {
 		s.logger.Error("failed to get user by email",
 			zap.String("email", email),
 			zap.Error(err))
-		return nil, ErrUserNotFound
+		return nil, err
 	}
 
 	// Compare password with stored hash
-	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
-	if err != nil {
+	match, err := s.passwordHasher.Verify(password, user.PasswordHash)
+	if err != nil || !match {
 		s.logger.Warn("invalid credentials attempt",
 			zap.String("email", email),
 			zap.Error(err))
 		return nil, ErrInvalidCredentials
 	}
 
-	// Generate token pair
-	tokenPair, err := s.generateTokenPair(user.ID.String(), user.Email)
+	// A hash made with an outdated algorithm or parameters is made again while the password is at hand
+	if s.passwordHasher.NeedsRehash(user.PasswordHash) {
+		s.rehashPassword(ctx, user, password)
+	}
+
+	return s.completeLogin(ctx, user)
+}
+
+// rehashPassword replaces the password hash of a user with one made with the current algorithm and parameters
+// A failure is only logged, as the user has proved their password regardless
+func (s *JWTAuthService) rehashPassword(ctx context.Context, user *model.User, password string) {
+	passwordHash, err := s.passwordHasher.Hash(password)
+	if err == nil {
+		err = s.userRepo.UpdatePasswordHash(ctx, user.ID, user.PasswordHash, passwordHash)
+	}
+	if err != nil {
+		s.logger.Error("failed to upgrade password hash",
+			zap.String("user_id", user.ID.String()),
+			zap.Error(err))
+		return
+	}
+
+	user.PasswordHash = passwordHash
+	s.logger.Info("password hash upgraded",
+		zap.String("user_id", user.ID.String()))
+}
+
+// completeLogin returns a token pair for a user who proved their identity with a first factor,
+// or an MFA challenge token instead when the user has MFA enabled
+func (s *JWTAuthService) completeLogin(ctx context.Context, user *model.User) (*LoginResult, error) {
+	if user.Disabled() {
+		s.logger.Info("login refused for disabled account",
+			zap.String("user_id", user.ID.String()))
+		return nil, ErrAccountDisabled
+	}
+
+	if !user.EmailVerified() && s.authConfig.UnverifiedAccess == config.UnverifiedAccessNone {
+		s.logger.Info("login refused for unverified email",
+			zap.String("user_id", user.ID.String()))
+		return nil, ErrEmailNotVerified
+	}
+
+	// A user with MFA enabled has to complete the login with a code
+	status, err := s.mfaService.GetStatus(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if status.Enabled {
+		challengeToken, err := s.generateToken(user, "", time.Time{}, MFAChallengeToken, s.authConfig.MFAChallengeExpiry)
+		if err != nil {
+			return nil, err
+		}
+
+		s.logger.Info("MFA challenge issued",
+			zap.String("user_id", user.ID.String()))
+		return &LoginResult{MFARequired: true, ChallengeToken: challengeToken}, nil
+	}
+
+	// Start a session with a new token pair
+	tokenPair, err := s.startSession(ctx, user)
 	if err != nil {
 		s.logger.Error("failed to generate token pair",
 			zap.String("user_id", user.ID.String()),
@@ -110,15 +267,238 @@ func (s *JWTAuthService) Authenticate(ctx context.Context, email, password strin
 	}
 
 	s.logger.Info("user authenticated successfully",
-		zap.String("email", email),
+		zap.String("email", user.Email),
+		zap.String("user_id", user.ID.String()))
+	return &LoginResult{TokenPair: tokenPair}, nil
+}
+
+// ValidateMFAChallenge validates an MFA challenge token and returns the claims
+func (s *JWTAuthService) ValidateMFAChallenge(challengeToken string) (*Claims, error) {
+	claims, err := s.ValidateToken(challengeToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Type != string(MFAChallengeToken) {
+		s.logger.Warn("invalid token type for MFA verification",
+			zap.String("user_id", claims.UserID),
+			zap.String("expected", string(MFAChallengeToken)),
+			zap.String("actual", claims.Type))
+		return nil, ErrInvalidTokenType
+	}
+
+	return claims, nil
+}
+
+// VerifyMFA exchanges an MFA challenge token and a TOTP or recovery code for a token pair
+func (s *JWTAuthService) VerifyMFA(ctx context.Context, challengeToken, code string) (*TokenPair, error) {
+	claims, err := s.ValidateMFAChallenge(challengeToken)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userFromClaims(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.mfaService.VerifyCode(ctx, user.ID, code); err != nil {
+		// MFA turned off after the challenge was issued does not let the login through without a code
+		if errors.Is(err, ErrMFANotEnabled) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	tokenPair, err := s.startSession(ctx, user)
+	if err != nil {
+		s.logger.Error("failed to generate token pair",
+			zap.String("user_id", user.ID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("user authenticated successfully with MFA",
+		zap.String("user_id", user.ID.String()))
+	return tokenPair, nil
+}
+
+// AuthenticatePasskey verifies the response of an authenticator to a passkey login and returns a token pair
+// A passkey proves both possession and user verification, so no MFA code is asked for
+func (s *JWTAuthService) AuthenticatePasskey(ctx context.Context, credential *model.AssertionCredential) (*TokenPair, error) {
+	user, err := s.webAuthnService.FinishLogin(ctx, credential)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Disabled() {
+		s.logger.Info("login refused for disabled account",
+			zap.String("user_id", user.ID.String()))
+		return nil, ErrAccountDisabled
+	}
+
+	if !user.EmailVerified() && s.authConfig.UnverifiedAccess == config.UnverifiedAccessNone {
+		s.logger.Info("login refused for unverified email",
+			zap.String("user_id", user.ID.String()))
+		return nil, ErrEmailNotVerified
+	}
+
+	tokenPair, err := s.startSession(ctx, user)
+	if err != nil {
+		s.logger.Error("failed to generate token pair",
+			zap.String("user_id", user.ID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("user authenticated successfully with passkey",
 		zap.String("user_id", user.ID.String()))
 	return tokenPair, nil
 }
 
-// generateTokenPair creates a new access and refresh token pair
-func (s *JWTAuthService) generateTokenPair(userID, email string) (*TokenPair, error) {
+// AuthenticateOIDC completes a login with an external OpenID Connect provider and returns a token pair,
+// or an MFA challenge token instead when the user has MFA enabled
+// The provider stands in for the password only, so MFA is still asked for
+func (s *JWTAuthService) AuthenticateOIDC(ctx context.Context, provider, code, state string) (*LoginResult, error) {
+	user, err := s.oidcService.FinishLogin(ctx, provider, code, state)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.completeLogin(ctx, user)
+}
+
+// userFromClaims retrieves the user a token was issued to, ensuring the token has not been revoked
+// The user is looked up by ID, as the email may have been changed since the token was issued
+func (s *JWTAuthService) userFromClaims(ctx context.Context, claims *Claims) (*model.User, error) {
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		s.logger.Warn("invalid user ID in token",
+			zap.String("user_id", claims.UserID))
+		return nil, ErrInvalidToken
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		s.logger.Error("user of token not found",
+			zap.String("email", claims.Email),
+			zap.String("user_id", claims.UserID),
+			zap.Error(err))
+		return nil, ErrUserNotFound
+	}
+
+	// Tokens issued before the password was changed are revoked
+	if claims.SessionVersion != user.SessionVersion {
+		s.logger.Warn("revoked token",
+			zap.String("user_id", claims.UserID),
+			zap.String("token_type", claims.Type),
+			zap.Int("session_version", claims.SessionVersion),
+			zap.Int("current_session_version", user.SessionVersion))
+		return nil, ErrInvalidToken
+	}
+
+	return user, nil
+}
+
+// IssueTokenPair starts a new session for a user who has already been authenticated and returns its token pair
+func (s *JWTAuthService) IssueTokenPair(ctx context.Context, user *model.User) (*TokenPair, error) {
+	return s.startSession(ctx, user)
+}
+
+// CurrentUser loads the user of validated claims as they are now,
+// checking that the token has not been revoked and the account is not disabled
+// A token the user logged in for is also refused once its session has been ended
+func (s *JWTAuthService) CurrentUser(ctx context.Context, claims *Claims) (*model.User, error) {
+	user, err := s.userFromClaims(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Disabled() {
+		s.logger.Info("token refused for disabled account",
+			zap.String("user_id", user.ID.String()))
+		return nil, ErrAccountDisabled
+	}
+
+	if claims.SessionID != "" {
+		if err := s.checkSession(ctx, user.ID, claims.SessionID); err != nil {
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+// checkSession ensures the session of a token is still active
+func (s *JWTAuthService) checkSession(ctx context.Context, userID uuid.UUID, sessionID string) error {
+	id, err := uuid.Parse(sessionID)
+	if err != nil {
+		s.logger.Warn("invalid session ID in token",
+			zap.String("session_id", sessionID))
+		return ErrInvalidToken
+	}
+
+	active, err := s.sessionRepo.IsActive(ctx, userID, id)
+	if err != nil {
+		s.logger.Error("failed to get session from repository",
+			zap.String("user_id", userID.String()),
+			zap.String("session_id", sessionID),
+			zap.Error(err))
+		return err
+	}
+	if !active {
+		s.logger.Warn("token of ended session",
+			zap.String("user_id", userID.String()),
+			zap.String("session_id", sessionID))
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// startSession records a new session of the user on the device the request came from and returns its token pair
+// No session is started for a disabled user, who may still hold an access token issued before
+func (s *JWTAuthService) startSession(ctx context.Context, user *model.User) (*TokenPair, error) {
+	if user.Disabled() {
+		s.logger.Info("session refused for disabled account",
+			zap.String("user_id", user.ID.String()))
+		return nil, ErrAccountDisabled
+	}
+
+	sessionID := uuid.New()
+	tokenPair, err := s.generateTokenPair(user, sessionID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	meta, _ := ctx.Value(requestMetadataKey{}).(requestMetadata)
+	session := &model.Session{
+		ID:               sessionID,
+		UserID:           user.ID,
+		RefreshTokenHash: hashOAuthSecret(tokenPair.RefreshToken),
+		SessionVersion:   user.SessionVersion,
+		UserAgent:        meta.userAgent,
+		IPAddress:        meta.ipAddress,
+	}
+	if err := s.sessionRepo.Create(ctx, session, s.authConfig.RefreshTokenExpiry); err != nil {
+		s.logger.Error("failed to create session in repository",
+			zap.String("user_id", user.ID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("session started",
+		zap.String("user_id", user.ID.String()),
+		zap.String("session_id", sessionID.String()))
+	return tokenPair, nil
+}
+
+// generateTokenPair creates a new access and refresh token pair of a session for the user,
+// who logged in for the session at authTime
+func (s *JWTAuthService) generateTokenPair(user *model.User, sessionID uuid.UUID, authTime time.Time) (*TokenPair, error) {
+	userID := user.ID.String()
+
 	// Create access token
-	accessToken, err := s.generateToken(userID, email, AccessToken, s.authConfig.AccessTokenExpiry)
+	accessToken, err := s.generateToken(user, sessionID.String(), authTime, AccessToken, s.authConfig.AccessTokenExpiry)
 	if err != nil {
 		s.logger.Error("failed to generate access token",
 			zap.String("user_id", userID),
@@ -127,7 +507,7 @@ func (s *JWTAuthService) generateTokenPair(userID, email string) (*TokenPair, er
 	}
 
 	// Create refresh token
-	refreshToken, err := s.generateToken(userID, email, RefreshToken, s.authConfig.RefreshTokenExpiry)
+	refreshToken, err := s.generateToken(user, sessionID.String(), authTime, RefreshToken, s.authConfig.RefreshTokenExpiry)
 	if err != nil {
 		s.logger.Error("failed to generate refresh token",
 			zap.String("user_id", userID),
@@ -145,19 +525,30 @@ func (s *JWTAuthService) generateTokenPair(userID, email string) (*TokenPair, er
 }
 
 // generateToken creates a new JWT token
-func (s *JWTAuthService) generateToken(userID, email string, tokenType TokenType, expiry time.Duration) (string, error) {
+// Every token gets a unique ID, so that the tokens of a session refreshed twice within a second differ
+// authTime is left out of the token when it is the zero time
+func (s *JWTAuthService) generateToken(user *model.User, sessionID string, authTime time.Time, tokenType TokenType, expiry time.Duration) (string, error) {
 	now := time.Now()
+	userID := user.ID.String()
 
 	claims := &Claims{
-		UserID: userID,
-		Email:  email,
-		Type:   string(tokenType),
+		UserID:         userID,
+		Email:          user.Email,
+		EmailVerified:  user.EmailVerified(),
+		SessionVersion: user.SessionVersion,
+		Type:           string(tokenType),
+		SessionID:      sessionID,
+		Roles:          user.Roles(),
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
 			ExpiresAt: jwt.NewNumericDate(now.Add(expiry)),
 			IssuedAt:  jwt.NewNumericDate(now),
 			NotBefore: jwt.NewNumericDate(now),
 		},
 	}
+	if !authTime.IsZero() {
+		claims.AuthTime = jwt.NewNumericDate(authTime)
+	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, err := token.SignedString([]byte(s.authConfig.JWTSecret))
@@ -217,6 +608,7 @@ func (s *JWTAuthService) ValidateToken(tokenString string) (*Claims, error) {
 }
 
 // RefreshToken takes a refresh token and returns a new token pair
+// The refresh token is rotated, so it fails once it has been used, or when its session has expired or been revoked
 func (s *JWTAuthService) RefreshToken(ctx context.Context, refreshToken string) (*TokenPair, error) {
 	// Validate the refresh token
 	claims, err := s.ValidateToken(refreshToken)
@@ -235,18 +627,28 @@ func (s *JWTAuthService) RefreshToken(ctx context.Context, refreshToken string)
 		return nil, ErrInvalidTokenType
 	}
 
-	// Verify the user still exists
-	user, err := s.userRepo.GetByEmail(ctx, claims.Email)
+	// Verify the user still exists and the token has not been revoked
+	user, err := s.userFromClaims(ctx, claims)
 	if err != nil {
-		s.logger.Error("user not found during token refresh",
-			zap.String("email", claims.Email),
-			zap.String("user_id", claims.UserID),
-			zap.Error(err))
-		return nil, ErrUserNotFound
+		return nil, err
+	}
+
+	if !user.EmailVerified() && s.authConfig.UnverifiedAccess == config.UnverifiedAccessNone {
+		s.logger.Info("token refresh refused for unverified email",
+			zap.String("user_id", user.ID.String()))
+		return nil, ErrEmailNotVerified
+	}
+
+	sessionID, err := uuid.Parse(claims.SessionID)
+	if err != nil {
+		s.logger.Warn("refresh token without session",
+			zap.String("user_id", claims.UserID))
+		return nil, ErrInvalidToken
 	}
 
 	// Generate a new token pair
-	tokenPair, err := s.generateTokenPair(user.ID.String(), user.Email)
+	// The claims pick up an email verified since the previous pair was issued, and keep the time of the login
+	tokenPair, err := s.generateTokenPair(user, sessionID, claims.AuthenticatedAt())
 	if err != nil {
 		s.logger.Error("failed to generate new token pair during refresh",
 			zap.String("user_id", claims.UserID),
@@ -254,6 +656,29 @@ func (s *JWTAuthService) RefreshToken(ctx context.Context, refreshToken string)
 		return nil, err
 	}
 
+	// Replace the refresh token of the session, unless the session has been revoked or the token already used
+	meta, _ := ctx.Value(requestMetadataKey{}).(requestMetadata)
+	session := &model.Session{
+		ID:               sessionID,
+		RefreshTokenHash: hashOAuthSecret(tokenPair.RefreshToken),
+		UserAgent:        meta.userAgent,
+		IPAddress:        meta.ipAddress,
+	}
+	rotated, err := s.sessionRepo.Rotate(ctx, session, hashOAuthSecret(refreshToken), s.authConfig.RefreshTokenExpiry)
+	if err != nil {
+		s.logger.Error("failed to rotate session in repository",
+			zap.String("user_id", claims.UserID),
+			zap.String("session_id", claims.SessionID),
+			zap.Error(err))
+		return nil, err
+	}
+	if !rotated {
+		s.logger.Warn("refresh token of revoked session or already used",
+			zap.String("user_id", claims.UserID),
+			zap.String("session_id", claims.SessionID))
+		return nil, ErrInvalidToken
+	}
+
 	s.logger.Info("token refreshed successfully",
 		zap.String("user_id", claims.UserID),
 		zap.String("email", claims.Email))
@@ -2,13 +2,17 @@ package service_test
 
 import (
 	"context"
+	"crypto/sha256"
+	"database/sql"
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"github.com/yukimaterrace/todoms/config"
 	"github.com/yukimaterrace/todoms/model"
@@ -17,6 +21,18 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// newAuthService creates an AuthenticationService with the mocks, for users without passkeys or external identities
+func newAuthService(userRepo *MockUserRepository, mfaRepo *MockMFARepository, authConfig *config.AuthConfig) service.AuthenticationService {
+	return newAuthServiceWithSessions(userRepo, newMockSessionRepository(), mfaRepo, authConfig)
+}
+
+// newAuthServiceWithSessions creates an AuthenticationService with the mocks, recording sessions in sessionRepo
+func newAuthServiceWithSessions(userRepo *MockUserRepository, sessionRepo *MockSessionRepository, mfaRepo *MockMFARepository, authConfig *config.AuthConfig) service.AuthenticationService {
+	return service.NewJWTAuthService(userRepo, sessionRepo, newTestPasswordHasher(), newMFAService(userRepo, mfaRepo),
+		newWebAuthnService(userRepo, new(MockWebAuthnRepository)),
+		newOIDCService(userRepo, new(MockIdentityRepository), nil), authConfig, zap.NewNop())
+}
+
 func TestAuthenticate(t *testing.T) {
 	// Setup
 	userRepo := new(MockUserRepository)
@@ -25,19 +41,18 @@ func TestAuthenticate(t *testing.T) {
 		15*time.Minute,
 		24*time.Hour,
 	)
-	testLogger := zap.NewNop() // テスト用にログを出力しないロガーを使用
-	authService := service.NewJWTAuthService(userRepo, authConfig, testLogger)
+	authService := newAuthService(userRepo, newMockMFARepository(), authConfig)
 	ctx := context.Background()
 
 	// Hash a password for our mock user
 	password := "password123"
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hashedPassword, err := newTestPasswordHasher().Hash(password)
 	require.NoError(t, err)
 
 	mockUser := &model.User{
 		ID:           uuid.New(),
 		Email:        "test@example.com",
-		PasswordHash: string(hashedPassword),
+		PasswordHash: hashedPassword,
 	}
 
 	t.Run("successful authentication", func(t *testing.T) {
@@ -57,16 +72,124 @@ func TestAuthenticate(t *testing.T) {
 		userRepo.AssertExpectations(t)
 	})
 
+	t.Run("session recorded", func(t *testing.T) {
+		sessionRepo := new(MockSessionRepository)
+		authService := newAuthServiceWithSessions(userRepo, sessionRepo, newMockMFARepository(), authConfig)
+		ctx := service.WithRequestMetadata(ctx, "request-1", "192.0.2.1", "test-agent")
+		userRepo.On("GetByEmail", ctx, "test@example.com").Return(mockUser, nil).Once()
+		var session *model.Session
+		sessionRepo.On("Create", ctx, mock.AnythingOfType("*model.Session"), 24*time.Hour).
+			Run(func(args mock.Arguments) { session = args.Get(1).(*model.Session) }).
+			Return(nil).Once()
+
+		result, err := authService.Authenticate(ctx, "test@example.com", password)
+
+		require.NoError(t, err)
+		refreshHash := sha256.Sum256([]byte(result.RefreshToken))
+		assert.Equal(t, mockUser.ID, session.UserID)
+		assert.Equal(t, refreshHash[:], session.RefreshTokenHash)
+		assert.Equal(t, "test-agent", session.UserAgent)
+		assert.Equal(t, "192.0.2.1", session.IPAddress)
+		claims, err := authService.ValidateToken(result.AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, session.ID.String(), claims.SessionID)
+		assert.WithinDuration(t, time.Now(), claims.AuthenticatedAt(), 5*time.Second)
+		sessionRepo.AssertExpectations(t)
+	})
+
+	t.Run("outdated password hash upgraded", func(t *testing.T) {
+		legacyHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+		require.NoError(t, err)
+		legacyUser := &model.User{ID: uuid.New(), Email: "legacy@example.com", PasswordHash: string(legacyHash)}
+		userRepo.On("GetByEmail", ctx, "legacy@example.com").Return(legacyUser, nil).Once()
+		userRepo.On("UpdatePasswordHash", ctx, legacyUser.ID, string(legacyHash), mock.MatchedBy(func(passwordHash string) bool {
+			return strings.HasPrefix(passwordHash, "$argon2id$") && passwordMatches(passwordHash, password)
+		})).Return(nil).Once()
+
+		tokenPair, err := authService.Authenticate(ctx, "legacy@example.com", password)
+
+		assert.NoError(t, err)
+		assert.NotNil(t, tokenPair)
+		userRepo.AssertExpectations(t)
+	})
+
+	t.Run("failed upgrade does not fail the login", func(t *testing.T) {
+		legacyHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+		require.NoError(t, err)
+		legacyUser := &model.User{ID: uuid.New(), Email: "legacy@example.com", PasswordHash: string(legacyHash)}
+		userRepo.On("GetByEmail", ctx, "legacy@example.com").Return(legacyUser, nil).Once()
+		userRepo.On("UpdatePasswordHash", ctx, legacyUser.ID, string(legacyHash), mock.Anything).
+			Return(errors.New("database error")).Once()
+
+		tokenPair, err := authService.Authenticate(ctx, "legacy@example.com", password)
+
+		assert.NoError(t, err)
+		assert.NotNil(t, tokenPair)
+		userRepo.AssertExpectations(t)
+	})
+
+	t.Run("roles in access token", func(t *testing.T) {
+		adminUser := &model.User{ID: uuid.New(), Email: "admin@example.com", PasswordHash: hashedPassword, Role: model.RoleAdmin}
+		userRepo.On("GetByEmail", ctx, "admin@example.com").Return(adminUser, nil).Once()
+
+		result, err := authService.Authenticate(ctx, "admin@example.com", password)
+
+		require.NoError(t, err)
+		claims, err := authService.ValidateToken(result.AccessToken)
+		require.NoError(t, err)
+		assert.True(t, claims.HasRole(model.RoleAdmin))
+		assert.True(t, claims.HasRole(model.RoleUser))
+	})
+
+	t.Run("disabled account", func(t *testing.T) {
+		disabledAt := time.Now()
+		disabledUser := &model.User{ID: uuid.New(), Email: "disabled@example.com", PasswordHash: hashedPassword, DisabledAt: &disabledAt}
+		userRepo.On("GetByEmail", ctx, "disabled@example.com").Return(disabledUser, nil).Once()
+
+		result, err := authService.Authenticate(ctx, "disabled@example.com", password)
+
+		assert.Equal(t, service.ErrAccountDisabled, err)
+		assert.Nil(t, result)
+		userRepo.AssertExpectations(t)
+	})
+
+	t.Run("password removed by an admin", func(t *testing.T) {
+		resetUser := &model.User{ID: uuid.New(), Email: "reset@example.com", PasswordHash: model.PasswordResetRequiredHash}
+		userRepo.On("GetByEmail", ctx, "reset@example.com").Return(resetUser, nil).Once()
+
+		result, err := authService.Authenticate(ctx, "reset@example.com", model.PasswordResetRequiredHash)
+
+		assert.Equal(t, service.ErrInvalidCredentials, err)
+		assert.Nil(t, result)
+		userRepo.AssertExpectations(t)
+	})
+
 	t.Run("user not found", func(t *testing.T) {
 		// Set up mock expectations
-		userRepo.On("GetByEmail", ctx, "nonexistent@example.com").Return(nil, errors.New("user not found")).Once()
+		userRepo.On("GetByEmail", ctx, "nonexistent@example.com").Return(nil, sql.ErrNoRows).Once()
 
 		// Call the service method
 		tokenPair, err := authService.Authenticate(ctx, "nonexistent@example.com", password)
 
-		// Assert results
+		// Assert results: an unknown email is indistinguishable from a wrong password
 		assert.Error(t, err)
-		assert.Equal(t, service.ErrUserNotFound, err)
+		assert.Equal(t, service.ErrInvalidCredentials, err)
+		assert.Nil(t, tokenPair)
+
+		// Verify the mock
+		userRepo.AssertExpectations(t)
+	})
+
+	t.Run("repository error", func(t *testing.T) {
+		// Set up mock expectations
+		dbErr := errors.New("database error")
+		userRepo.On("GetByEmail", ctx, "test@example.com").Return(nil, dbErr).Once()
+
+		// Call the service method
+		tokenPair, err := authService.Authenticate(ctx, "test@example.com", password)
+
+		// Assert results
+		assert.Equal(t, dbErr, err)
 		assert.Nil(t, tokenPair)
 
 		// Verify the mock
@@ -88,6 +211,33 @@ func TestAuthenticate(t *testing.T) {
 		// Verify the mock
 		userRepo.AssertExpectations(t)
 	})
+
+	t.Run("email verified claim", func(t *testing.T) {
+		verifiedAt := time.Now()
+		verifiedUser := *mockUser
+		verifiedUser.EmailVerifiedAt = &verifiedAt
+		userRepo.On("GetByEmail", ctx, "test@example.com").Return(&verifiedUser, nil).Once()
+
+		tokenPair, err := authService.Authenticate(ctx, "test@example.com", password)
+		require.NoError(t, err)
+
+		claims, err := authService.ValidateToken(tokenPair.AccessToken)
+		require.NoError(t, err)
+		assert.True(t, claims.EmailVerified)
+	})
+
+	t.Run("unverified email refused when unverified access is none", func(t *testing.T) {
+		noneConfig := config.NewAuthConfig("test-secret-key", 15*time.Minute, 24*time.Hour)
+		noneConfig.UnverifiedAccess = config.UnverifiedAccessNone
+		noneAuthService := newAuthService(userRepo, newMockMFARepository(), noneConfig)
+		userRepo.On("GetByEmail", ctx, "test@example.com").Return(mockUser, nil).Once()
+
+		tokenPair, err := noneAuthService.Authenticate(ctx, "test@example.com", password)
+
+		assert.Equal(t, service.ErrEmailNotVerified, err)
+		assert.Nil(t, tokenPair)
+		userRepo.AssertExpectations(t)
+	})
 }
 
 func TestValidateToken(t *testing.T) {
@@ -98,8 +248,7 @@ func TestValidateToken(t *testing.T) {
 		15*time.Minute,
 		24*time.Hour,
 	)
-	testLogger := zap.NewNop() // テスト用にログを出力しないロガーを使用
-	authService := service.NewJWTAuthService(userRepo, authConfig, testLogger)
+	authService := newAuthService(userRepo, newMockMFARepository(), authConfig)
 	ctx := context.Background()
 
 	// Create a user for our test
@@ -116,7 +265,7 @@ func TestValidateToken(t *testing.T) {
 	// First authenticate to get a token
 	tokenPair, err := authService.Authenticate(ctx, "test@example.com", "password123")
 	// In a real test, we'd validate the token differently, but for this mock test we'll skip this check
-	// as bcrypt comparison will fail
+	// as the password hash is not a real one
 	if err == service.ErrInvalidCredentials {
 		// Manually generate token for testing
 		now := time.Now()
@@ -133,10 +282,10 @@ func TestValidateToken(t *testing.T) {
 		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 		accessToken, err := token.SignedString([]byte("test-secret-key"))
 		require.NoError(t, err)
-		tokenPair = &service.TokenPair{
+		tokenPair = &service.LoginResult{TokenPair: &service.TokenPair{
 			AccessToken:  accessToken,
 			RefreshToken: "", // Not needed for this test
-		}
+		}}
 	} else {
 		require.NoError(t, err)
 	}
@@ -197,9 +346,9 @@ func TestRefreshToken(t *testing.T) {
 		15*time.Minute,
 		24*time.Hour,
 	)
-	testLogger := zap.NewNop() // テスト用にログを出力しないロガーを使用
-	authService := service.NewJWTAuthService(userRepo, authConfig, testLogger)
-	ctx := context.Background()
+	sessionRepo := new(MockSessionRepository)
+	authService := newAuthServiceWithSessions(userRepo, sessionRepo, newMockMFARepository(), authConfig)
+	ctx := service.WithRequestMetadata(context.Background(), "request-1", "192.0.2.1", "test-agent")
 
 	userID := uuid.New()
 	mockUser := &model.User{
@@ -208,12 +357,14 @@ func TestRefreshToken(t *testing.T) {
 		PasswordHash: "hashedpassword",
 	}
 
-	// Create a refresh token for testing
+	// Create a refresh token of a session for testing
 	now := time.Now()
+	sessionID := uuid.New()
 	refreshClaims := &service.Claims{
-		UserID: userID.String(),
-		Email:  "test@example.com",
-		Type:   string(service.RefreshToken),
+		UserID:    userID.String(),
+		Email:     "test@example.com",
+		Type:      string(service.RefreshToken),
+		SessionID: sessionID.String(),
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(now.Add(24 * time.Hour)),
 			IssuedAt:  jwt.NewNumericDate(now),
@@ -223,6 +374,7 @@ func TestRefreshToken(t *testing.T) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
 	refreshToken, err := token.SignedString([]byte("test-secret-key"))
 	require.NoError(t, err)
+	refreshHash := sha256.Sum256([]byte(refreshToken))
 
 	// Create an access token (wrong type) for negative testing
 	accessClaims := &service.Claims{
@@ -240,19 +392,82 @@ func TestRefreshToken(t *testing.T) {
 
 	t.Run("successful token refresh", func(t *testing.T) {
 		// Set up mock expectations
-		userRepo.On("GetByEmail", ctx, "test@example.com").Return(mockUser, nil).Once()
+		userRepo.On("GetByID", ctx, userID).Return(mockUser, nil).Once()
+		var rotated *model.Session
+		sessionRepo.On("Rotate", ctx, mock.AnythingOfType("*model.Session"), refreshHash[:], 24*time.Hour).
+			Run(func(args mock.Arguments) { rotated = args.Get(1).(*model.Session) }).
+			Return(true, nil).Once()
 
 		// Call the service method
 		tokenPair, err := authService.RefreshToken(ctx, refreshToken)
 
 		// Assert results
-		assert.NoError(t, err)
-		assert.NotNil(t, tokenPair)
+		require.NoError(t, err)
 		assert.NotEmpty(t, tokenPair.AccessToken)
 		assert.NotEmpty(t, tokenPair.RefreshToken)
 
+		// The session now holds the new refresh token, and records the device it was refreshed from
+		newHash := sha256.Sum256([]byte(tokenPair.RefreshToken))
+		assert.Equal(t, sessionID, rotated.ID)
+		assert.Equal(t, newHash[:], rotated.RefreshTokenHash)
+		assert.Equal(t, "test-agent", rotated.UserAgent)
+		assert.Equal(t, "192.0.2.1", rotated.IPAddress)
+		claims, err := authService.ValidateToken(tokenPair.AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, sessionID.String(), claims.SessionID)
+
 		// Verify the mock
 		userRepo.AssertExpectations(t)
+		sessionRepo.AssertExpectations(t)
+	})
+
+	t.Run("revoked or already used session", func(t *testing.T) {
+		userRepo.On("GetByID", ctx, userID).Return(mockUser, nil).Once()
+		sessionRepo.On("Rotate", ctx, mock.Anything, refreshHash[:], 24*time.Hour).Return(false, nil).Once()
+
+		tokenPair, err := authService.RefreshToken(ctx, refreshToken)
+
+		assert.Equal(t, service.ErrInvalidToken, err)
+		assert.Nil(t, tokenPair)
+		userRepo.AssertExpectations(t)
+		sessionRepo.AssertExpectations(t)
+	})
+
+	t.Run("login time carried over", func(t *testing.T) {
+		loggedInAt := now.Add(-time.Hour).Truncate(time.Second)
+		claims := *refreshClaims
+		claims.AuthTime = jwt.NewNumericDate(loggedInAt)
+		loggedInToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &claims).SignedString([]byte("test-secret-key"))
+		require.NoError(t, err)
+		loggedInHash := sha256.Sum256([]byte(loggedInToken))
+		userRepo.On("GetByID", ctx, userID).Return(mockUser, nil).Once()
+		sessionRepo.On("Rotate", ctx, mock.Anything, loggedInHash[:], 24*time.Hour).Return(true, nil).Once()
+
+		tokenPair, err := authService.RefreshToken(ctx, loggedInToken)
+		require.NoError(t, err)
+
+		// Refreshing does not count as logging in again
+		accessClaims, err := authService.ValidateToken(tokenPair.AccessToken)
+		require.NoError(t, err)
+		assert.True(t, loggedInAt.Equal(accessClaims.AuthenticatedAt()))
+		refreshed, err := authService.ValidateToken(tokenPair.RefreshToken)
+		require.NoError(t, err)
+		assert.True(t, loggedInAt.Equal(refreshed.AuthenticatedAt()))
+		sessionRepo.AssertExpectations(t)
+	})
+
+	t.Run("refresh token without session", func(t *testing.T) {
+		userRepo.On("GetByID", ctx, userID).Return(mockUser, nil).Once()
+		claims := *refreshClaims
+		claims.SessionID = ""
+		legacyToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &claims).SignedString([]byte("test-secret-key"))
+		require.NoError(t, err)
+
+		tokenPair, err := authService.RefreshToken(ctx, legacyToken)
+
+		assert.Equal(t, service.ErrInvalidToken, err)
+		assert.Nil(t, tokenPair)
+		userRepo.AssertExpectations(t)
 	})
 
 	t.Run("wrong token type", func(t *testing.T) {
@@ -267,7 +482,7 @@ func TestRefreshToken(t *testing.T) {
 
 	t.Run("user not found", func(t *testing.T) {
 		// Set up mock expectations
-		userRepo.On("GetByEmail", ctx, "test@example.com").Return(nil, errors.New("user not found")).Once()
+		userRepo.On("GetByID", ctx, userID).Return(nil, errors.New("user not found")).Once()
 
 		// Call the service method
 		tokenPair, err := authService.RefreshToken(ctx, refreshToken)
@@ -281,6 +496,34 @@ func TestRefreshToken(t *testing.T) {
 		userRepo.AssertExpectations(t)
 	})
 
+	t.Run("email changed since issued", func(t *testing.T) {
+		changedUser := *mockUser
+		changedUser.Email = "changed@example.com"
+		userRepo.On("GetByID", ctx, userID).Return(&changedUser, nil).Once()
+		sessionRepo.On("Rotate", ctx, mock.Anything, refreshHash[:], 24*time.Hour).Return(true, nil).Once()
+
+		tokenPair, err := authService.RefreshToken(ctx, refreshToken)
+		require.NoError(t, err)
+
+		claims, err := authService.ValidateToken(tokenPair.AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, "changed@example.com", claims.Email)
+		userRepo.AssertExpectations(t)
+	})
+
+	t.Run("revoked after password change", func(t *testing.T) {
+		// The password was changed after the refresh token was issued
+		changedUser := *mockUser
+		changedUser.SessionVersion = 1
+		userRepo.On("GetByID", ctx, userID).Return(&changedUser, nil).Once()
+
+		tokenPair, err := authService.RefreshToken(ctx, refreshToken)
+
+		assert.Equal(t, service.ErrInvalidToken, err)
+		assert.Nil(t, tokenPair)
+		userRepo.AssertExpectations(t)
+	})
+
 	t.Run("invalid token", func(t *testing.T) {
 		// Call the service method with an invalid token
 		tokenPair, err := authService.RefreshToken(ctx, "invalid-token")
@@ -290,3 +533,216 @@ func TestRefreshToken(t *testing.T) {
 		assert.Nil(t, tokenPair)
 	})
 }
+
+func TestCurrentUser(t *testing.T) {
+	// Setup
+	userRepo := new(MockUserRepository)
+	authConfig := config.NewAuthConfig(
+		"test-secret-key",
+		15*time.Minute,
+		24*time.Hour,
+	)
+	authService := newAuthService(userRepo, newMockMFARepository(), authConfig)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	claims := &service.Claims{UserID: userID.String(), SessionVersion: 1, Type: string(service.AccessToken)}
+
+	t.Run("current user", func(t *testing.T) {
+		user := &model.User{ID: userID, SessionVersion: 1, Role: model.RoleAdmin}
+		userRepo.On("GetByID", ctx, userID).Return(user, nil).Once()
+
+		current, err := authService.CurrentUser(ctx, claims)
+
+		assert.NoError(t, err)
+		assert.Equal(t, user, current)
+		userRepo.AssertExpectations(t)
+	})
+
+	t.Run("revoked token", func(t *testing.T) {
+		userRepo.On("GetByID", ctx, userID).Return(&model.User{ID: userID, SessionVersion: 2}, nil).Once()
+
+		current, err := authService.CurrentUser(ctx, claims)
+
+		assert.Equal(t, service.ErrInvalidToken, err)
+		assert.Nil(t, current)
+		userRepo.AssertExpectations(t)
+	})
+
+	t.Run("session of the token", func(t *testing.T) {
+		sessionID := uuid.New()
+		sessionClaims := *claims
+		sessionClaims.SessionID = sessionID.String()
+
+		for _, active := range []bool{true, false} {
+			sessionRepo := new(MockSessionRepository)
+			authService := newAuthServiceWithSessions(userRepo, sessionRepo, newMockMFARepository(), authConfig)
+			user := &model.User{ID: userID, SessionVersion: 1}
+			userRepo.On("GetByID", ctx, userID).Return(user, nil).Once()
+			sessionRepo.On("IsActive", ctx, userID, sessionID).Return(active, nil).Once()
+
+			current, err := authService.CurrentUser(ctx, &sessionClaims)
+
+			if active {
+				assert.NoError(t, err)
+				assert.Equal(t, user, current)
+			} else {
+				// The session was ended, e.g. from the session list
+				assert.Equal(t, service.ErrInvalidToken, err)
+				assert.Nil(t, current)
+			}
+			sessionRepo.AssertExpectations(t)
+		}
+	})
+
+	t.Run("disabled account", func(t *testing.T) {
+		disabledAt := time.Now()
+		userRepo.On("GetByID", ctx, userID).Return(&model.User{ID: userID, SessionVersion: 1, DisabledAt: &disabledAt}, nil).Once()
+
+		current, err := authService.CurrentUser(ctx, claims)
+
+		assert.Equal(t, service.ErrAccountDisabled, err)
+		assert.Nil(t, current)
+		userRepo.AssertExpectations(t)
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		userRepo.On("GetByID", ctx, userID).Return(nil, sql.ErrNoRows).Once()
+
+		current, err := authService.CurrentUser(ctx, claims)
+
+		assert.Equal(t, service.ErrUserNotFound, err)
+		assert.Nil(t, current)
+		userRepo.AssertExpectations(t)
+	})
+}
+
+func TestAuthenticateWithMFA(t *testing.T) {
+	authConfig := config.NewAuthConfig("test-secret-key", 15*time.Minute, 24*time.Hour)
+	ctx := context.Background()
+	user := newUserWithPassword(t, "password123")
+	_, enabled := enrollMFA(t, user)
+
+	userRepo := new(MockUserRepository)
+	mfaRepo := new(MockMFARepository)
+	userRepo.On("GetByEmail", ctx, user.Email).Return(user, nil)
+	mfaRepo.On("GetByUserID", ctx, user.ID).Return(enabled, nil)
+	mfaRepo.On("CountRecoveryCodes", ctx, user.ID).Return(10, nil)
+	authService := newAuthService(userRepo, mfaRepo, authConfig)
+
+	result, err := authService.Authenticate(ctx, user.Email, "password123")
+	require.NoError(t, err)
+
+	// No tokens are issued until the code is verified
+	assert.True(t, result.MFARequired)
+	assert.Nil(t, result.TokenPair)
+	require.NotEmpty(t, result.ChallengeToken)
+
+	claims, err := authService.ValidateMFAChallenge(result.ChallengeToken)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID.String(), claims.UserID)
+	assert.Equal(t, string(service.MFAChallengeToken), claims.Type)
+	assert.WithinDuration(t, time.Now().Add(authConfig.MFAChallengeExpiry), claims.ExpiresAt.Time, 5*time.Second)
+
+	// The challenge token cannot be used to get a token pair otherwise
+	_, err = authService.RefreshToken(ctx, result.ChallengeToken)
+	assert.Equal(t, service.ErrInvalidTokenType, err)
+}
+
+func TestVerifyMFA(t *testing.T) {
+	authConfig := config.NewAuthConfig("test-secret-key", 15*time.Minute, 24*time.Hour)
+	ctx := context.Background()
+	user := newUserWithPassword(t, "password123")
+	secret, enabled := enrollMFA(t, user)
+
+	// issueChallenge logs the user in with the password to get a challenge token
+	issueChallenge := func(t *testing.T) string {
+		userRepo := new(MockUserRepository)
+		mfaRepo := new(MockMFARepository)
+		userRepo.On("GetByEmail", ctx, user.Email).Return(user, nil)
+		mfaRepo.On("GetByUserID", ctx, user.ID).Return(enabled, nil)
+		mfaRepo.On("CountRecoveryCodes", ctx, user.ID).Return(10, nil)
+
+		result, err := newAuthService(userRepo, mfaRepo, authConfig).
+			Authenticate(ctx, user.Email, "password123")
+		require.NoError(t, err)
+		return result.ChallengeToken
+	}
+
+	testCases := []struct {
+		name          string
+		token         func(t *testing.T) string
+		code          string
+		setupMocks    func(*MockUserRepository, *MockMFARepository)
+		expectedError error
+	}{
+		{
+			name:  "Success",
+			token: issueChallenge,
+			code:  totpCodeAt(secret, time.Now()),
+			setupMocks: func(userRepo *MockUserRepository, mfaRepo *MockMFARepository) {
+				userRepo.On("GetByID", ctx, user.ID).Return(user, nil)
+				mfaRepo.On("GetByUserID", ctx, user.ID).Return(enabled, nil)
+				mfaRepo.On("UseStep", ctx, user.ID, mock.AnythingOfType("int64")).Return(true, nil)
+			},
+		},
+		{
+			name:  "Wrong code",
+			token: issueChallenge,
+			code:  wrongTOTPCode(secret),
+			setupMocks: func(userRepo *MockUserRepository, mfaRepo *MockMFARepository) {
+				userRepo.On("GetByID", ctx, user.ID).Return(user, nil)
+				mfaRepo.On("GetByUserID", ctx, user.ID).Return(enabled, nil)
+				mfaRepo.On("ConsumeRecoveryCode", ctx, user.ID, mock.Anything).Return(false, nil)
+			},
+			expectedError: service.ErrInvalidMFACode,
+		},
+		{
+			name:  "MFA disabled since the challenge was issued",
+			token: issueChallenge,
+			code:  totpCodeAt(secret, time.Now()),
+			setupMocks: func(userRepo *MockUserRepository, mfaRepo *MockMFARepository) {
+				userRepo.On("GetByID", ctx, user.ID).Return(user, nil)
+				mfaRepo.On("GetByUserID", ctx, user.ID).Return(nil, sql.ErrNoRows)
+			},
+			expectedError: service.ErrInvalidToken,
+		},
+		{
+			name: "Access token instead of challenge token",
+			token: func(t *testing.T) string {
+				tokenPair, err := newAuthService(new(MockUserRepository), newMockMFARepository(), authConfig).
+					IssueTokenPair(ctx, user)
+				require.NoError(t, err)
+				return tokenPair.AccessToken
+			},
+			code:          totpCodeAt(secret, time.Now()),
+			setupMocks:    func(*MockUserRepository, *MockMFARepository) {},
+			expectedError: service.ErrInvalidTokenType,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			challengeToken := tc.token(t)
+			userRepo := new(MockUserRepository)
+			mfaRepo := new(MockMFARepository)
+			tc.setupMocks(userRepo, mfaRepo)
+			authService := newAuthService(userRepo, mfaRepo, authConfig)
+
+			tokenPair, err := authService.VerifyMFA(ctx, challengeToken, tc.code)
+
+			if tc.expectedError != nil {
+				assert.Equal(t, tc.expectedError, err)
+				assert.Nil(t, tokenPair)
+			} else {
+				require.NoError(t, err)
+				claims, err := authService.ValidateToken(tokenPair.AccessToken)
+				require.NoError(t, err)
+				assert.Equal(t, user.ID.String(), claims.UserID)
+				assert.Equal(t, string(service.AccessToken), claims.Type)
+			}
+			userRepo.AssertExpectations(t)
+			mfaRepo.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// cborMaxDepth is the deepest nesting of arrays and maps decodeCBOR accepts
+const cborMaxDepth = 16
+
+// errMalformedCBOR is returned when data is not CBOR decodeCBOR supports
+var errMalformedCBOR = errors.New("malformed CBOR")
+
+// decodeCBOR decodes the first CBOR data item of data (RFC 8949) and returns it with the number of bytes it took
+// It supports what WebAuthn uses: integers as int64, byte strings as []byte, text strings as string,
+// arrays as []any, maps as map[any]any with integer or text keys, booleans and null
+// Indefinite lengths, tags and floating-point numbers are not supported
+func decodeCBOR(data []byte) (any, int, error) {
+	d := &cborDecoder{data: data}
+	value, err := d.decode(0)
+	if err != nil {
+		return nil, 0, err
+	}
+	return value, d.offset, nil
+}
+
+// cborDecoder reads CBOR data items from data
+type cborDecoder struct {
+	data   []byte
+	offset int
+}
+
+// decode reads the next data item
+func (d *cborDecoder) decode(depth int) (any, error) {
+	if depth > cborMaxDepth {
+		return nil, errMalformedCBOR
+	}
+
+	major, arg, err := d.readHead()
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, errMalformedCBOR
+		}
+		return int64(arg), nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, errMalformedCBOR
+		}
+		return -1 - int64(arg), nil
+	case 2:
+		b, err := d.readBytes(arg)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), b...), nil
+	case 3:
+		b, err := d.readBytes(arg)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case 4:
+		// Every item takes at least a byte, which bounds the allocation by the remaining data
+		if arg > uint64(len(d.data)-d.offset) {
+			return nil, errMalformedCBOR
+		}
+		items := make([]any, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			item, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case 5:
+		if arg > uint64(len(d.data)-d.offset)/2 {
+			return nil, errMalformedCBOR
+		}
+		entries := make(map[any]any, arg)
+		for i := uint64(0); i < arg; i++ {
+			key, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, errMalformedCBOR
+			}
+			if _, ok := entries[key]; ok {
+				return nil, errMalformedCBOR
+			}
+
+			value, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			entries[key] = value
+		}
+		return entries, nil
+	case 7:
+		switch arg {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		case 22:
+			return nil, nil
+		}
+	}
+	return nil, errMalformedCBOR
+}
+
+// readHead reads the major type and argument of the next data item
+func (d *cborDecoder) readHead() (byte, uint64, error) {
+	if d.offset >= len(d.data) {
+		return 0, 0, errMalformedCBOR
+	}
+	initial := d.data[d.offset]
+	d.offset++
+
+	major, info := initial>>5, initial&0x1f
+	switch {
+	case info < 24:
+		return major, uint64(info), nil
+	case info == 24:
+		b, err := d.readBytes(1)
+		if err != nil {
+			return 0, 0, err
+		}
+		return major, uint64(b[0]), nil
+	case info == 25:
+		b, err := d.readBytes(2)
+		if err != nil {
+			return 0, 0, err
+		}
+		return major, uint64(binary.BigEndian.Uint16(b)), nil
+	case info == 26:
+		b, err := d.readBytes(4)
+		if err != nil {
+			return 0, 0, err
+		}
+		return major, uint64(binary.BigEndian.Uint32(b)), nil
+	case info == 27:
+		b, err := d.readBytes(8)
+		if err != nil {
+			return 0, 0, err
+		}
+		return major, binary.BigEndian.Uint64(b), nil
+	}
+	return 0, 0, errMalformedCBOR
+}
+
+// readBytes reads the next n bytes
+func (d *cborDecoder) readBytes(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.offset) {
+		return nil, errMalformedCBOR
+	}
+	b := d.data[d.offset : d.offset+int(n)]
+	d.offset += int(n)
+	return b, nil
+}
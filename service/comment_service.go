@@ -0,0 +1,309 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultCommentPageSize is the number of comments returned when no limit is given
+	DefaultCommentPageSize = 20
+
+	// MaxCommentPageSize is the maximum number of comments returned in a single page
+	MaxCommentPageSize = 100
+)
+
+var (
+	// ErrCommentNotFound is returned when a comment with the specified ID is not found on the todo
+	ErrCommentNotFound = errors.New("comment not found")
+
+	// ErrNotCommentAuthor is returned when a user tries to modify another user's comment
+	ErrNotCommentAuthor = errors.New("not the author of this comment")
+
+	// ErrInvalidCursor is returned when a pagination cursor cannot be decoded
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// CommentService defines the interface for comment-related business logic
+type CommentService interface {
+	// ListComments retrieves a page of comments on a todo the specified user can view
+	// It returns the cursor of the next page, or nil if there are no more comments
+	ListComments(ctx context.Context, userID uuid.UUID, todoID uuid.UUID, cursor string, limit int) ([]model.Comment, *string, error)
+
+	// CreateComment adds a comment to a todo the specified user can view
+	CreateComment(ctx context.Context, userID uuid.UUID, todoID uuid.UUID, req model.CreateCommentRequest) (*model.Comment, error)
+
+	// UpdateComment edits a comment, ensuring the specified user is its author
+	UpdateComment(ctx context.Context, userID uuid.UUID, todoID uuid.UUID, commentID uuid.UUID, req model.UpdateCommentRequest) (*model.Comment, error)
+
+	// DeleteComment soft-deletes a comment, ensuring the specified user is its author or the todo owner
+	DeleteComment(ctx context.Context, userID uuid.UUID, todoID uuid.UUID, commentID uuid.UUID) error
+}
+
+// DefaultCommentService implements the CommentService interface
+type DefaultCommentService struct {
+	todoService  TodoService
+	commentRepo  repository.CommentRepository
+	activityRepo repository.ActivityRepository
+	transactor   repository.Transactor
+	logger       *zap.Logger
+}
+
+// NewCommentService creates a new DefaultCommentService instance
+func NewCommentService(
+	todoService TodoService,
+	commentRepo repository.CommentRepository,
+	activityRepo repository.ActivityRepository,
+	transactor repository.Transactor,
+	logger *zap.Logger,
+) CommentService {
+	return &DefaultCommentService{
+		todoService:  todoService,
+		commentRepo:  commentRepo,
+		activityRepo: activityRepo,
+		transactor:   transactor,
+		logger:       logger,
+	}
+}
+
+// ListComments retrieves a page of comments on a todo the specified user can view
+func (s *DefaultCommentService) ListComments(ctx context.Context, userID uuid.UUID, todoID uuid.UUID, cursor string, limit int) ([]model.Comment, *string, error) {
+	_, _, err := s.todoService.AuthorizeTodo(ctx, userID, todoID, model.ShareRoleViewer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	after, err := decodeCommentCursor(cursor)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if limit <= 0 {
+		limit = DefaultCommentPageSize
+	}
+	if limit > MaxCommentPageSize {
+		limit = MaxCommentPageSize
+	}
+
+	// Fetch one extra comment to find out whether there is a next page
+	comments, err := s.commentRepo.GetByTodoID(ctx, todoID, after, limit+1)
+	if err != nil {
+		s.logger.Error("failed to get comments",
+			zap.String("user_id", userID.String()),
+			zap.String("todo_id", todoID.String()),
+			zap.Error(err))
+		return nil, nil, err
+	}
+
+	var nextCursor *string
+	if len(comments) > limit {
+		comments = comments[:limit]
+		last := comments[limit-1]
+		encoded := encodeCommentCursor(&model.CommentCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+		nextCursor = &encoded
+	}
+
+	return comments, nextCursor, nil
+}
+
+// CreateComment adds a comment to a todo the specified user can view
+func (s *DefaultCommentService) CreateComment(ctx context.Context, userID uuid.UUID, todoID uuid.UUID, req model.CreateCommentRequest) (*model.Comment, error) {
+	_, _, err := s.todoService.AuthorizeTodo(ctx, userID, todoID, model.ShareRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	bodyHTML, err := renderMarkdown(req.Body)
+	if err != nil {
+		s.logger.Error("failed to render comment body",
+			zap.String("user_id", userID.String()),
+			zap.String("todo_id", todoID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	comment := &model.Comment{
+		TodoID:   todoID,
+		AuthorID: userID,
+		Body:     req.Body,
+		BodyHTML: bodyHTML,
+	}
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.commentRepo.Create(ctx, comment); err != nil {
+			return err
+		}
+		return recordActivity(ctx, s.activityRepo, userID, model.ActivityEntityComment, comment.ID, &todoID,
+			model.ActivityActionCreated, nil, commentSnapshot(comment))
+	})
+	if err != nil {
+		s.logger.Error("failed to create comment",
+			zap.String("user_id", userID.String()),
+			zap.String("todo_id", todoID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("comment created successfully",
+		zap.String("user_id", userID.String()),
+		zap.String("todo_id", todoID.String()),
+		zap.String("comment_id", comment.ID.String()))
+
+	// Reload to get the author and timestamps
+	return s.getComment(ctx, todoID, comment.ID)
+}
+
+// UpdateComment edits a comment, ensuring the specified user is its author
+func (s *DefaultCommentService) UpdateComment(ctx context.Context, userID uuid.UUID, todoID uuid.UUID, commentID uuid.UUID, req model.UpdateCommentRequest) (*model.Comment, error) {
+	_, _, err := s.todoService.AuthorizeTodo(ctx, userID, todoID, model.ShareRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	comment, err := s.getComment(ctx, todoID, commentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if comment.AuthorID != userID {
+		s.logger.Warn("attempt to edit another user's comment",
+			zap.String("user_id", userID.String()),
+			zap.String("comment_id", commentID.String()),
+			zap.String("author_id", comment.AuthorID.String()))
+		return nil, ErrNotCommentAuthor
+	}
+
+	bodyHTML, err := renderMarkdown(req.Body)
+	if err != nil {
+		s.logger.Error("failed to render comment body",
+			zap.String("user_id", userID.String()),
+			zap.String("comment_id", commentID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	before := commentSnapshot(comment)
+	comment.Body = req.Body
+	comment.BodyHTML = bodyHTML
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.commentRepo.Update(ctx, comment); err != nil {
+			return err
+		}
+		return recordActivity(ctx, s.activityRepo, userID, model.ActivityEntityComment, commentID, &todoID,
+			model.ActivityActionUpdated, before, commentSnapshot(comment))
+	})
+	if err != nil {
+		s.logger.Error("failed to update comment",
+			zap.String("user_id", userID.String()),
+			zap.String("comment_id", commentID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("comment updated successfully",
+		zap.String("user_id", userID.String()),
+		zap.String("todo_id", todoID.String()),
+		zap.String("comment_id", commentID.String()))
+
+	// Reload to get the new updated_at
+	return s.getComment(ctx, todoID, commentID)
+}
+
+// DeleteComment soft-deletes a comment, ensuring the specified user is its author or the todo owner
+func (s *DefaultCommentService) DeleteComment(ctx context.Context, userID uuid.UUID, todoID uuid.UUID, commentID uuid.UUID) error {
+	_, role, err := s.todoService.AuthorizeTodo(ctx, userID, todoID, model.ShareRoleViewer)
+	if err != nil {
+		return err
+	}
+
+	comment, err := s.getComment(ctx, todoID, commentID)
+	if err != nil {
+		return err
+	}
+
+	// The owner of the todo can moderate the thread
+	if comment.AuthorID != userID && role != model.ShareRoleOwner {
+		s.logger.Warn("attempt to delete another user's comment",
+			zap.String("user_id", userID.String()),
+			zap.String("comment_id", commentID.String()),
+			zap.String("author_id", comment.AuthorID.String()))
+		return ErrNotCommentAuthor
+	}
+
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.commentRepo.SoftDelete(ctx, commentID); err != nil {
+			return err
+		}
+		return recordActivity(ctx, s.activityRepo, userID, model.ActivityEntityComment, commentID, &todoID,
+			model.ActivityActionDeleted, commentSnapshot(comment), nil)
+	})
+	if err != nil {
+		s.logger.Error("failed to delete comment",
+			zap.String("user_id", userID.String()),
+			zap.String("comment_id", commentID.String()),
+			zap.Error(err))
+		return err
+	}
+
+	s.logger.Info("comment deleted successfully",
+		zap.String("user_id", userID.String()),
+		zap.String("todo_id", todoID.String()),
+		zap.String("comment_id", commentID.String()))
+	return nil
+}
+
+// getComment retrieves a comment, ensuring it belongs to the todo
+func (s *DefaultCommentService) getComment(ctx context.Context, todoID uuid.UUID, commentID uuid.UUID) (*model.Comment, error) {
+	comment, err := s.commentRepo.GetByID(ctx, commentID)
+	if err != nil {
+		s.logger.Error("failed to get comment",
+			zap.String("todo_id", todoID.String()),
+			zap.String("comment_id", commentID.String()),
+			zap.Error(err))
+		return nil, ErrCommentNotFound
+	}
+
+	if comment.TodoID != todoID {
+		return nil, ErrCommentNotFound
+	}
+
+	return comment, nil
+}
+
+// commentSnapshot returns the recorded state of a comment
+func commentSnapshot(comment *model.Comment) activitySnapshot {
+	return activitySnapshot{
+		"body": comment.Body,
+	}
+}
+
+// encodeCommentCursor encodes a cursor into an opaque string for clients
+func encodeCommentCursor(cursor *model.CommentCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCommentCursor decodes a cursor received from a client, returning nil for the first page
+func decodeCommentCursor(cursor string) (*model.CommentCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var decoded model.CommentCursor
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.ID == uuid.Nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &decoded, nil
+}
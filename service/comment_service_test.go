@@ -0,0 +1,185 @@
+package service_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+	"go.uber.org/zap"
+)
+
+// setupCommentService creates a comment service for a todo owned by ownerID and shared with viewerID
+func setupCommentService(ownerID, viewerID, todoID uuid.UUID) (service.CommentService, *MockCommentRepository) {
+	logger := zap.NewNop()
+
+	mockTodoRepo := new(MockTodoRepository)
+	mockTodoRepo.On("GetByID", mock.Anything, todoID).Return(&model.Todo{ID: todoID, UserID: ownerID}, nil)
+	mockShareRepo := new(MockTodoShareRepository)
+	mockShareRepo.On("Get", mock.Anything, todoID, viewerID).Return(&model.TodoShare{Role: model.ShareRoleViewer}, nil)
+	mockShareRepo.On("Get", mock.Anything, todoID, mock.Anything).Return(nil, sql.ErrNoRows)
+	mockCommentRepo := new(MockCommentRepository)
+
+	todoService := service.NewTodoService(mockTodoRepo, mockShareRepo, newMockActivityRepository(), new(MockTransactor), config.DefaultTodoQuotaConfig(), logger)
+	return service.NewCommentService(todoService, mockCommentRepo, newMockActivityRepository(), new(MockTransactor), logger), mockCommentRepo
+}
+
+func TestCreateComment(t *testing.T) {
+	ctx := context.Background()
+	ownerID := uuid.New()
+	viewerID := uuid.New()
+	todoID := uuid.New()
+
+	t.Run("viewer can comment and markdown is sanitised", func(t *testing.T) {
+		commentService, commentRepo := setupCommentService(ownerID, viewerID, todoID)
+
+		commentID := uuid.New()
+		var created *model.Comment
+		commentRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.Comment")).Return(nil).Run(func(args mock.Arguments) {
+			// Set ID when Create is called, simulating DB behavior
+			created = args.Get(1).(*model.Comment)
+			created.ID = commentID
+		})
+		commentRepo.On("GetByID", mock.Anything, commentID).Return(&model.Comment{ID: commentID, TodoID: todoID, AuthorID: viewerID}, nil)
+
+		comment, err := commentService.CreateComment(ctx, viewerID, todoID, model.CreateCommentRequest{
+			Body: "**done** <script>alert(1)</script> [link](javascript:alert(1))",
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, viewerID, comment.AuthorID)
+		assert.Equal(t, viewerID, created.AuthorID)
+		assert.Contains(t, created.BodyHTML, "<strong>done</strong>")
+		assert.NotContains(t, created.BodyHTML, "<script>")
+		assert.NotContains(t, created.BodyHTML, "javascript:")
+	})
+
+	t.Run("user without access cannot comment", func(t *testing.T) {
+		commentService, commentRepo := setupCommentService(ownerID, viewerID, todoID)
+
+		comment, err := commentService.CreateComment(ctx, uuid.New(), todoID, model.CreateCommentRequest{Body: "hello"})
+
+		assert.Equal(t, service.ErrUnauthorized, err)
+		assert.Nil(t, comment)
+		commentRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+}
+
+func TestUpdateComment(t *testing.T) {
+	ctx := context.Background()
+	ownerID := uuid.New()
+	viewerID := uuid.New()
+	todoID := uuid.New()
+	commentID := uuid.New()
+
+	t.Run("author can edit", func(t *testing.T) {
+		commentService, commentRepo := setupCommentService(ownerID, viewerID, todoID)
+		commentRepo.On("GetByID", mock.Anything, commentID).Return(&model.Comment{ID: commentID, TodoID: todoID, AuthorID: viewerID}, nil)
+		commentRepo.On("Update", mock.Anything, mock.MatchedBy(func(c *model.Comment) bool {
+			return c.Body == "_edited_" && c.BodyHTML == "<p><em>edited</em></p>\n"
+		})).Return(nil)
+
+		comment, err := commentService.UpdateComment(ctx, viewerID, todoID, commentID, model.UpdateCommentRequest{Body: "_edited_"})
+
+		assert.NoError(t, err)
+		assert.NotNil(t, comment)
+		commentRepo.AssertExpectations(t)
+	})
+
+	t.Run("owner cannot edit another user's comment", func(t *testing.T) {
+		commentService, commentRepo := setupCommentService(ownerID, viewerID, todoID)
+		commentRepo.On("GetByID", mock.Anything, commentID).Return(&model.Comment{ID: commentID, TodoID: todoID, AuthorID: viewerID}, nil)
+
+		comment, err := commentService.UpdateComment(ctx, ownerID, todoID, commentID, model.UpdateCommentRequest{Body: "edited"})
+
+		assert.Equal(t, service.ErrNotCommentAuthor, err)
+		assert.Nil(t, comment)
+		commentRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	})
+
+	t.Run("comment on another todo", func(t *testing.T) {
+		commentService, commentRepo := setupCommentService(ownerID, viewerID, todoID)
+		commentRepo.On("GetByID", mock.Anything, commentID).Return(&model.Comment{ID: commentID, TodoID: uuid.New(), AuthorID: viewerID}, nil)
+
+		_, err := commentService.UpdateComment(ctx, viewerID, todoID, commentID, model.UpdateCommentRequest{Body: "edited"})
+
+		assert.Equal(t, service.ErrCommentNotFound, err)
+	})
+}
+
+func TestDeleteComment(t *testing.T) {
+	ctx := context.Background()
+	ownerID := uuid.New()
+	viewerID := uuid.New()
+	todoID := uuid.New()
+	commentID := uuid.New()
+
+	t.Run("todo owner can delete any comment", func(t *testing.T) {
+		commentService, commentRepo := setupCommentService(ownerID, viewerID, todoID)
+		commentRepo.On("GetByID", mock.Anything, commentID).Return(&model.Comment{ID: commentID, TodoID: todoID, AuthorID: viewerID}, nil)
+		commentRepo.On("SoftDelete", mock.Anything, commentID).Return(nil)
+
+		err := commentService.DeleteComment(ctx, ownerID, todoID, commentID)
+
+		assert.NoError(t, err)
+		commentRepo.AssertExpectations(t)
+	})
+
+	t.Run("viewer cannot delete the owner's comment", func(t *testing.T) {
+		commentService, commentRepo := setupCommentService(ownerID, viewerID, todoID)
+		commentRepo.On("GetByID", mock.Anything, commentID).Return(&model.Comment{ID: commentID, TodoID: todoID, AuthorID: ownerID}, nil)
+
+		err := commentService.DeleteComment(ctx, viewerID, todoID, commentID)
+
+		assert.Equal(t, service.ErrNotCommentAuthor, err)
+		commentRepo.AssertNotCalled(t, "SoftDelete", mock.Anything, mock.Anything)
+	})
+}
+
+func TestListComments(t *testing.T) {
+	ctx := context.Background()
+	ownerID := uuid.New()
+	viewerID := uuid.New()
+	todoID := uuid.New()
+
+	now := time.Now()
+	comments := []model.Comment{
+		{ID: uuid.New(), TodoID: todoID, CreatedAt: now},
+		{ID: uuid.New(), TodoID: todoID, CreatedAt: now.Add(time.Second)},
+		{ID: uuid.New(), TodoID: todoID, CreatedAt: now.Add(2 * time.Second)},
+	}
+
+	t.Run("pages through comments with a cursor", func(t *testing.T) {
+		commentService, commentRepo := setupCommentService(ownerID, viewerID, todoID)
+		commentRepo.On("GetByTodoID", mock.Anything, todoID, (*model.CommentCursor)(nil), 3).Return(comments, nil)
+		commentRepo.On("GetByTodoID", mock.Anything, todoID, mock.MatchedBy(func(c *model.CommentCursor) bool {
+			return c != nil && c.ID == comments[1].ID
+		}), 3).Return(comments[2:], nil)
+
+		page, nextCursor, err := commentService.ListComments(ctx, viewerID, todoID, "", 2)
+		require.NoError(t, err)
+		assert.Len(t, page, 2)
+		require.NotNil(t, nextCursor)
+
+		page, nextCursor, err = commentService.ListComments(ctx, viewerID, todoID, *nextCursor, 2)
+		require.NoError(t, err)
+		assert.Len(t, page, 1)
+		assert.Nil(t, nextCursor)
+		commentRepo.AssertExpectations(t)
+	})
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		commentService, _ := setupCommentService(ownerID, viewerID, todoID)
+
+		_, _, err := commentService.ListComments(ctx, viewerID, todoID, "not-a-cursor", 0)
+
+		assert.Equal(t, service.ErrInvalidCursor, err)
+	})
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrInvalidVerificationToken is returned when a verification token is forged, expired or already used
+	ErrInvalidVerificationToken = errors.New("invalid verification token")
+)
+
+// EmailVerificationService defines the interface for verifying the email addresses of users
+type EmailVerificationService interface {
+	// SendVerification sends a verification link to a user whose email address is not verified yet
+	SendVerification(ctx context.Context, user *model.User) error
+
+	// ResendVerification sends a new verification link to the user with the email address
+	// It does nothing when there is no such unverified user, or a link was sent within the cooldown,
+	// so that the result does not reveal whether the email is registered
+	ResendVerification(ctx context.Context, email string) error
+
+	// VerifyEmail consumes a verification token and marks the email address of its user as verified
+	VerifyEmail(ctx context.Context, token string) (*model.User, error)
+
+	// PurgeExpired deletes expired user tokens and returns how many were deleted
+	PurgeExpired(ctx context.Context) (int64, error)
+}
+
+// DefaultEmailVerificationService implements the EmailVerificationService interface
+type DefaultEmailVerificationService struct {
+	userRepo      repository.UserRepository
+	userTokenRepo repository.UserTokenRepository
+	activityRepo  repository.ActivityRepository
+	transactor    repository.Transactor
+	mailer        Mailer
+	authConfig    *config.AuthConfig
+	config        *config.EmailVerificationConfig
+	logger        *zap.Logger
+}
+
+// NewEmailVerificationService creates a new DefaultEmailVerificationService instance
+func NewEmailVerificationService(
+	userRepo repository.UserRepository,
+	userTokenRepo repository.UserTokenRepository,
+	activityRepo repository.ActivityRepository,
+	transactor repository.Transactor,
+	mailer Mailer,
+	authConfig *config.AuthConfig,
+	cfg *config.EmailVerificationConfig,
+	logger *zap.Logger,
+) EmailVerificationService {
+	return &DefaultEmailVerificationService{
+		userRepo:      userRepo,
+		userTokenRepo: userTokenRepo,
+		activityRepo:  activityRepo,
+		transactor:    transactor,
+		mailer:        mailer,
+		authConfig:    authConfig,
+		config:        cfg,
+		logger:        logger,
+	}
+}
+
+// SendVerification sends a verification link to a user whose email address is not verified yet
+// Links sent before are invalidated
+func (s *DefaultEmailVerificationService) SendVerification(ctx context.Context, user *model.User) error {
+	if user.EmailVerified() {
+		return nil
+	}
+
+	token, err := issueUserToken(ctx, s.userTokenRepo, s.transactor, s.authConfig.JWTSecret,
+		user.ID, model.UserTokenEmailVerification, s.config.TokenTTL)
+	if err != nil {
+		s.logger.Error("failed to issue verification token",
+			zap.String("user_id", user.ID.String()),
+			zap.Error(err))
+		return err
+	}
+
+	link, err := userTokenLink(s.config.VerificationURL, token)
+	if err != nil {
+		s.logger.Error("failed to build verification link",
+			zap.String("verification_url", s.config.VerificationURL),
+			zap.Error(err))
+		return err
+	}
+
+	email := &Email{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Open the link below to verify your email address.\n\n%s\n\n"+
+			"The link expires in %s. If you did not sign up, you can ignore this email.\n",
+			link, s.config.TokenTTL),
+	}
+	if err := s.mailer.Send(ctx, email); err != nil {
+		s.logger.Error("failed to send verification email",
+			zap.String("user_id", user.ID.String()),
+			zap.Error(err))
+		return err
+	}
+
+	s.logger.Info("verification email sent",
+		zap.String("user_id", user.ID.String()))
+	return nil
+}
+
+// ResendVerification sends a new verification link to the user with the email address
+func (s *DefaultEmailVerificationService) ResendVerification(ctx context.Context, email string) error {
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		s.logger.Info("verification resend requested for unknown email",
+			zap.String("email", email))
+		return nil
+	}
+	if err != nil {
+		s.logger.Error("failed to get user by email",
+			zap.String("email", email),
+			zap.Error(err))
+		return err
+	}
+	if user.EmailVerified() {
+		return nil
+	}
+
+	recent, err := s.userTokenRepo.HasRecent(ctx, user.ID, model.UserTokenEmailVerification, s.config.ResendCooldown)
+	if err != nil {
+		s.logger.Error("failed to check recent verification tokens",
+			zap.String("user_id", user.ID.String()),
+			zap.Error(err))
+		return err
+	}
+	if recent {
+		s.logger.Info("verification resend within cooldown ignored",
+			zap.String("user_id", user.ID.String()))
+		return nil
+	}
+
+	return s.SendVerification(ctx, user)
+}
+
+// VerifyEmail consumes a verification token and marks the email address of its user as verified
+func (s *DefaultEmailVerificationService) VerifyEmail(ctx context.Context, token string) (*model.User, error) {
+	tokenHash, ok := parseUserToken(s.authConfig.JWTSecret, model.UserTokenEmailVerification, token)
+	if !ok {
+		s.logger.Warn("verification token with invalid signature")
+		return nil, ErrInvalidVerificationToken
+	}
+
+	var user *model.User
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		consumed, err := s.userTokenRepo.Consume(ctx, tokenHash, model.UserTokenEmailVerification)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidVerificationToken
+		}
+		if err != nil {
+			return err
+		}
+
+		before, err := s.userRepo.GetByID(ctx, consumed.UserID)
+		if err != nil {
+			return err
+		}
+		if err := s.userRepo.MarkEmailVerified(ctx, consumed.UserID); err != nil {
+			return err
+		}
+		user, err = s.userRepo.GetByID(ctx, consumed.UserID)
+		if err != nil {
+			return err
+		}
+
+		return recordActivity(ctx, s.activityRepo, user.ID, model.ActivityEntityUser, user.ID, nil,
+			model.ActivityActionUpdated, userSnapshot(before), userSnapshot(user))
+	})
+	if errors.Is(err, ErrInvalidVerificationToken) {
+		s.logger.Warn("verification token expired, used or unknown")
+		return nil, err
+	}
+	if err != nil {
+		s.logger.Error("failed to verify email",
+			zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("email verified",
+		zap.String("user_id", user.ID.String()))
+	return user, nil
+}
+
+// PurgeExpired deletes expired user tokens and returns how many were deleted
+func (s *DefaultEmailVerificationService) PurgeExpired(ctx context.Context) (int64, error) {
+	deleted, err := s.userTokenRepo.DeleteExpired(ctx)
+	if err != nil {
+		s.logger.Error("failed to purge expired user tokens",
+			zap.Error(err))
+		return 0, err
+	}
+	return deleted, nil
+}
@@ -0,0 +1,287 @@
+package service_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+	"go.uber.org/zap"
+)
+
+// newEmailVerificationService creates an EmailVerificationService with the mocks
+func newEmailVerificationService(
+	userRepo *MockUserRepository,
+	userTokenRepo *MockUserTokenRepository,
+	mailer *MockMailer,
+) service.EmailVerificationService {
+	return service.NewEmailVerificationService(userRepo, userTokenRepo, newMockActivityRepository(), new(MockTransactor),
+		mailer, config.NewAuthConfig("test-secret", time.Minute, time.Hour), config.DefaultEmailVerificationConfig(), zap.NewNop())
+}
+
+// expectTokenIssued expects a token to be issued for the user and returns the token as stored once it is
+func expectTokenIssued(
+	userTokenRepo *MockUserTokenRepository,
+	userID uuid.UUID,
+	purpose model.UserTokenPurpose,
+	ttl time.Duration,
+) *model.UserToken {
+	issued := &model.UserToken{}
+	userTokenRepo.On("DeleteUnused", mock.Anything, userID, purpose).Return(nil)
+	userTokenRepo.On("Create", mock.Anything, mock.MatchedBy(func(token *model.UserToken) bool {
+		return token.UserID == userID && token.Purpose == purpose && len(token.TokenHash) > 0
+	}), ttl).Run(func(args mock.Arguments) {
+		*issued = *args.Get(1).(*model.UserToken)
+	}).Return(nil)
+	return issued
+}
+
+// tokenFromEmail returns the token of the link to the page in the email
+func tokenFromEmail(t *testing.T, email *service.Email, pageURL string) string {
+	for _, line := range strings.Split(email.Body, "\n") {
+		if strings.HasPrefix(line, pageURL) {
+			link, err := url.Parse(line)
+			require.NoError(t, err)
+			return link.Query().Get("token")
+		}
+	}
+	t.Fatalf("no link to %s in %q", pageURL, email.Body)
+	return ""
+}
+
+// tamperToken changes the first character of a token
+func tamperToken(token string) string {
+	if token[0] == 'A' {
+		return "B" + token[1:]
+	}
+	return "A" + token[1:]
+}
+
+func TestSendVerification(t *testing.T) {
+	t.Run("Sends a link that verifies the email", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		userTokenRepo := new(MockUserTokenRepository)
+		mailer := new(MockMailer)
+		user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+		issued := expectTokenIssued(userTokenRepo, user.ID, model.UserTokenEmailVerification, config.DefaultVerificationTokenTTL)
+
+		var sent *service.Email
+		mailer.On("Send", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			sent = args.Get(1).(*service.Email)
+		}).Return(nil)
+
+		verificationService := newEmailVerificationService(userRepo, userTokenRepo, mailer)
+		err := verificationService.SendVerification(context.Background(), user)
+		require.NoError(t, err)
+		require.NotNil(t, sent)
+		assert.Equal(t, "test@example.com", sent.To)
+
+		// The token in the link is consumed to verify the email
+		verifiedAt := time.Now()
+		userTokenRepo.On("Consume", mock.Anything, issued.TokenHash, model.UserTokenEmailVerification).
+			Return(issued, nil)
+		userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil).Once()
+		userRepo.On("MarkEmailVerified", mock.Anything, user.ID).Return(nil)
+		userRepo.On("GetByID", mock.Anything, user.ID).
+			Return(&model.User{ID: user.ID, Email: user.Email, EmailVerifiedAt: &verifiedAt}, nil).Once()
+
+		verified, err := verificationService.VerifyEmail(context.Background(), tokenFromEmail(t, sent, config.DefaultVerificationURL))
+		require.NoError(t, err)
+		assert.True(t, verified.EmailVerified())
+		userRepo.AssertExpectations(t)
+		userTokenRepo.AssertExpectations(t)
+	})
+
+	t.Run("Verified user", func(t *testing.T) {
+		userTokenRepo := new(MockUserTokenRepository)
+		mailer := new(MockMailer)
+		verifiedAt := time.Now()
+		user := &model.User{ID: uuid.New(), Email: "test@example.com", EmailVerifiedAt: &verifiedAt}
+
+		err := newEmailVerificationService(new(MockUserRepository), userTokenRepo, mailer).
+			SendVerification(context.Background(), user)
+		assert.NoError(t, err)
+		userTokenRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything)
+		mailer.AssertNotCalled(t, "Send", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Mailer error", func(t *testing.T) {
+		userTokenRepo := new(MockUserTokenRepository)
+		mailer := new(MockMailer)
+		user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+		expectTokenIssued(userTokenRepo, user.ID, model.UserTokenEmailVerification, config.DefaultVerificationTokenTTL)
+		mailer.On("Send", mock.Anything, mock.Anything).Return(errors.New("smtp error"))
+
+		err := newEmailVerificationService(new(MockUserRepository), userTokenRepo, mailer).
+			SendVerification(context.Background(), user)
+		assert.EqualError(t, err, "smtp error")
+	})
+}
+
+func TestResendVerification(t *testing.T) {
+	userID := uuid.New()
+	verifiedAt := time.Now()
+
+	testCases := []struct {
+		name          string
+		setupMocks    func(*MockUserRepository, *MockUserTokenRepository)
+		expectSent    bool
+		expectedError error
+	}{
+		{
+			name: "Sends a new link",
+			setupMocks: func(userRepo *MockUserRepository, userTokenRepo *MockUserTokenRepository) {
+				userRepo.On("GetByEmail", mock.Anything, "test@example.com").
+					Return(&model.User{ID: userID, Email: "test@example.com"}, nil)
+				userTokenRepo.On("HasRecent", mock.Anything, userID, model.UserTokenEmailVerification,
+					config.DefaultVerificationResendCooldown).Return(false, nil)
+				expectTokenIssued(userTokenRepo, userID, model.UserTokenEmailVerification, config.DefaultVerificationTokenTTL)
+			},
+			expectSent: true,
+		},
+		{
+			name: "Within cooldown",
+			setupMocks: func(userRepo *MockUserRepository, userTokenRepo *MockUserTokenRepository) {
+				userRepo.On("GetByEmail", mock.Anything, "test@example.com").
+					Return(&model.User{ID: userID, Email: "test@example.com"}, nil)
+				userTokenRepo.On("HasRecent", mock.Anything, userID, model.UserTokenEmailVerification,
+					config.DefaultVerificationResendCooldown).Return(true, nil)
+			},
+		},
+		{
+			name: "Unknown email",
+			setupMocks: func(userRepo *MockUserRepository, userTokenRepo *MockUserTokenRepository) {
+				userRepo.On("GetByEmail", mock.Anything, "test@example.com").Return(nil, sql.ErrNoRows)
+			},
+		},
+		{
+			name: "Already verified",
+			setupMocks: func(userRepo *MockUserRepository, userTokenRepo *MockUserTokenRepository) {
+				userRepo.On("GetByEmail", mock.Anything, "test@example.com").
+					Return(&model.User{ID: userID, Email: "test@example.com", EmailVerifiedAt: &verifiedAt}, nil)
+			},
+		},
+		{
+			name: "Repository error",
+			setupMocks: func(userRepo *MockUserRepository, userTokenRepo *MockUserTokenRepository) {
+				userRepo.On("GetByEmail", mock.Anything, "test@example.com").Return(nil, errors.New("database error"))
+			},
+			expectedError: errors.New("database error"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			userRepo := new(MockUserRepository)
+			userTokenRepo := new(MockUserTokenRepository)
+			mailer := new(MockMailer)
+			tc.setupMocks(userRepo, userTokenRepo)
+			mailer.On("Send", mock.Anything, mock.Anything).Return(nil).Maybe()
+
+			err := newEmailVerificationService(userRepo, userTokenRepo, mailer).
+				ResendVerification(context.Background(), "test@example.com")
+
+			if tc.expectedError != nil {
+				assert.EqualError(t, err, tc.expectedError.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+			if tc.expectSent {
+				mailer.AssertNumberOfCalls(t, "Send", 1)
+			} else {
+				mailer.AssertNotCalled(t, "Send", mock.Anything, mock.Anything)
+			}
+			userRepo.AssertExpectations(t)
+			userTokenRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	// signedToken issues a token through SendVerification and returns it as sent, with the token as stored
+	signedToken := func(t *testing.T, authSecret string) (string, *model.UserToken) {
+		userTokenRepo := new(MockUserTokenRepository)
+		mailer := new(MockMailer)
+		userID := uuid.New()
+		issued := expectTokenIssued(userTokenRepo, userID, model.UserTokenEmailVerification, config.DefaultVerificationTokenTTL)
+		var sent *service.Email
+		mailer.On("Send", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			sent = args.Get(1).(*service.Email)
+		}).Return(nil)
+
+		verificationService := service.NewEmailVerificationService(new(MockUserRepository), userTokenRepo,
+			newMockActivityRepository(), new(MockTransactor), mailer,
+			config.NewAuthConfig(authSecret, time.Minute, time.Hour), config.DefaultEmailVerificationConfig(), zap.NewNop())
+		require.NoError(t, verificationService.SendVerification(context.Background(), &model.User{ID: userID, Email: "test@example.com"}))
+		return tokenFromEmail(t, sent, config.DefaultVerificationURL), issued
+	}
+
+	testCases := []struct {
+		name          string
+		authSecret    string
+		token         func(token string) string
+		consumeResult error
+		expectedError error
+	}{
+		{
+			name:          "Malformed token",
+			authSecret:    "test-secret",
+			token:         func(token string) string { return "not-a-token" },
+			expectedError: service.ErrInvalidVerificationToken,
+		},
+		{
+			name:          "Tampered token",
+			authSecret:    "test-secret",
+			token:         tamperToken,
+			expectedError: service.ErrInvalidVerificationToken,
+		},
+		{
+			name:          "Signed with another secret",
+			authSecret:    "other-secret",
+			token:         func(token string) string { return token },
+			expectedError: service.ErrInvalidVerificationToken,
+		},
+		{
+			name:          "Expired or used token",
+			authSecret:    "test-secret",
+			token:         func(token string) string { return token },
+			consumeResult: sql.ErrNoRows,
+			expectedError: service.ErrInvalidVerificationToken,
+		},
+		{
+			name:          "Repository error",
+			authSecret:    "test-secret",
+			token:         func(token string) string { return token },
+			consumeResult: errors.New("database error"),
+			expectedError: errors.New("database error"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			token, issued := signedToken(t, tc.authSecret)
+			userTokenRepo := new(MockUserTokenRepository)
+			if tc.consumeResult != nil {
+				userTokenRepo.On("Consume", mock.Anything, issued.TokenHash, model.UserTokenEmailVerification).
+					Return(nil, tc.consumeResult)
+			}
+
+			user, err := newEmailVerificationService(new(MockUserRepository), userTokenRepo, new(MockMailer)).
+				VerifyEmail(context.Background(), tc.token(token))
+
+			assert.EqualError(t, err, tc.expectedError.Error())
+			assert.Nil(t, user)
+			userTokenRepo.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/yukimaterrace/todoms/model"
+	"go.uber.org/zap"
+)
+
+// EventPublisher publishes domain events relayed from the outbox
+// An event may be published more than once, so consumers must discard duplicates by its idempotency key
+type EventPublisher interface {
+	Publish(ctx context.Context, event *model.OutboxEvent) error
+}
+
+// EventHandler handles a domain event delivered by InMemoryEventPublisher
+type EventHandler func(ctx context.Context, event *model.OutboxEvent) error
+
+// maxRememberedEvents is the number of idempotency keys InMemoryEventPublisher remembers
+const maxRememberedEvents = 10000
+
+// InMemoryEventPublisher delivers events to handlers subscribed in the same process
+// Events whose handlers all succeeded are remembered by their idempotency key and not handled again
+type InMemoryEventPublisher struct {
+	mu       sync.Mutex
+	handlers map[string][]EventHandler
+	handled  map[string]struct{}
+	keys     []string
+}
+
+// NewInMemoryEventPublisher creates a new InMemoryEventPublisher instance
+func NewInMemoryEventPublisher() *InMemoryEventPublisher {
+	return &InMemoryEventPublisher{
+		handlers: make(map[string][]EventHandler),
+		handled:  make(map[string]struct{}),
+	}
+}
+
+// Subscribe registers a handler for the specified event type, or for every event type with "*"
+func (p *InMemoryEventPublisher) Subscribe(eventType string, handler EventHandler) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.handlers[eventType] = append(p.handlers[eventType], handler)
+}
+
+// Publish calls the handlers subscribed to the event, stopping at the first one that fails
+func (p *InMemoryEventPublisher) Publish(ctx context.Context, event *model.OutboxEvent) error {
+	key := event.IdempotencyKey()
+
+	p.mu.Lock()
+	if _, ok := p.handled[key]; ok {
+		p.mu.Unlock()
+		return nil
+	}
+	handlers := append(append([]EventHandler{}, p.handlers[event.EventType]...), p.handlers["*"]...)
+	p.mu.Unlock()
+
+	for _, handler := range handlers {
+		if err := handler(ctx, event); err != nil {
+			return err
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.keys) >= maxRememberedEvents {
+		delete(p.handled, p.keys[0])
+		p.keys = p.keys[1:]
+	}
+	p.handled[key] = struct{}{}
+	p.keys = append(p.keys, key)
+	return nil
+}
+
+// MultiEventPublisher publishes events to several publishers in turn, stopping at the first one that fails
+// Unlike InMemoryEventPublisher it remembers nothing, so an event relayed again reaches every publisher again
+type MultiEventPublisher struct {
+	publishers []EventPublisher
+}
+
+// NewMultiEventPublisher creates a new MultiEventPublisher instance
+func NewMultiEventPublisher(publishers ...EventPublisher) EventPublisher {
+	return &MultiEventPublisher{publishers: publishers}
+}
+
+// Publish passes the event to each publisher in order
+func (p *MultiEventPublisher) Publish(ctx context.Context, event *model.OutboxEvent) error {
+	for _, publisher := range p.publishers {
+		if err := publisher.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LogEventPublisher writes events to the log, for development and as a default without a broker
+type LogEventPublisher struct {
+	logger *zap.Logger
+}
+
+// NewLogEventPublisher creates a new LogEventPublisher instance
+func NewLogEventPublisher(logger *zap.Logger) EventPublisher {
+	return &LogEventPublisher{logger: logger}
+}
+
+// Publish logs the event
+func (p *LogEventPublisher) Publish(ctx context.Context, event *model.OutboxEvent) error {
+	p.logger.Info("domain event published",
+		zap.String("idempotency_key", event.IdempotencyKey()),
+		zap.String("event_type", event.EventType),
+		zap.String("aggregate_type", event.AggregateType),
+		zap.String("aggregate_id", event.AggregateID.String()),
+		zap.ByteString("payload", event.Payload))
+	return nil
+}
+
+// Headers set on messages sent by BrokerEventPublisher
+const (
+	EventIdempotencyKeyHeader = "Idempotency-Key"
+	EventTypeHeader           = "Event-Type"
+	EventAggregateTypeHeader  = "Aggregate-Type"
+	EventOccurredAtHeader     = "Occurred-At"
+)
+
+// BrokerMessage is a message sent to a MessageBroker
+type BrokerMessage struct {
+	// Topic is the subject on NATS or the topic on Kafka
+	Topic string
+
+	// Key is the aggregate ID, used as the partition key so the events of one aggregate stay in order
+	Key string
+
+	// ID is the idempotency key, to be set as Nats-Msg-Id for JetStream deduplication
+	// It is also sent in the Idempotency-Key header for consumers of brokers without deduplication
+	ID string
+
+	Headers map[string]string
+	Value   []byte
+}
+
+// MessageBroker is the client of a message broker such as NATS JetStream or Kafka
+// Publish must return only once the broker has acknowledged the message
+type MessageBroker interface {
+	Publish(ctx context.Context, msg BrokerMessage) error
+}
+
+// BrokerEventPublisher publishes events to a message broker, on one topic per event type
+type BrokerEventPublisher struct {
+	broker      MessageBroker
+	topicPrefix string
+}
+
+// NewBrokerEventPublisher creates a new BrokerEventPublisher instance
+// Events are published to "<topicPrefix>.<event type>", such as "todoms.todo.created"
+func NewBrokerEventPublisher(broker MessageBroker, topicPrefix string) EventPublisher {
+	return &BrokerEventPublisher{
+		broker:      broker,
+		topicPrefix: topicPrefix,
+	}
+}
+
+// Publish sends the event to the broker
+func (p *BrokerEventPublisher) Publish(ctx context.Context, event *model.OutboxEvent) error {
+	return p.broker.Publish(ctx, BrokerMessage{
+		Topic: p.topicPrefix + "." + event.EventType,
+		Key:   event.AggregateID.String(),
+		ID:    event.IdempotencyKey(),
+		Headers: map[string]string{
+			EventIdempotencyKeyHeader: event.IdempotencyKey(),
+			EventTypeHeader:           event.EventType,
+			EventAggregateTypeHeader:  event.AggregateType,
+			EventOccurredAtHeader:     event.CreatedAt.UTC().Format(time.RFC3339Nano),
+		},
+		Value: event.Payload,
+	})
+}
@@ -0,0 +1,13 @@
+package service
+
+// Unexported functions exposed to the tests in package service_test
+
+var (
+	PasswordStrength  = passwordStrength
+	PatternGuesses    = patternGuesses
+	RepeatGuesses     = repeatGuesses
+	SequenceGuesses   = sequenceGuesses
+	DictionaryGuesses = dictionaryGuesses
+)
+
+const MaxPatternLength = maxPatternLength
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrIdempotencyKeyReused is returned when an Idempotency-Key is sent again with a different request
+	ErrIdempotencyKeyReused = errors.New("idempotency key reused with a different request")
+
+	// ErrIdempotencyKeyInProgress is returned when an Idempotency-Key is sent again before its request has finished
+	ErrIdempotencyKeyInProgress = errors.New("idempotency key in progress")
+)
+
+// IdempotencyService defines the interface for Idempotency-Key business logic
+type IdempotencyService interface {
+	// Begin claims a key for a request identified by its fingerprint
+	// It returns the stored response to replay if the request was made before, or nil if it should be processed
+	Begin(ctx context.Context, userID uuid.UUID, key string, fingerprint string) (*model.IdempotencyRecord, error)
+
+	// Complete stores the response to a request claimed with Begin
+	Complete(ctx context.Context, userID uuid.UUID, key string, status int, contentType string, body []byte) error
+
+	// Release gives up a key claimed with Begin without storing a response, so that the request can be retried
+	Release(ctx context.Context, userID uuid.UUID, key string) error
+
+	// PurgeExpired removes the keys whose responses are no longer kept
+	PurgeExpired(ctx context.Context) (int64, error)
+}
+
+// DefaultIdempotencyService implements the IdempotencyService interface
+type DefaultIdempotencyService struct {
+	idempotencyRepo repository.IdempotencyRepository
+	config          *config.IdempotencyConfig
+	logger          *zap.Logger
+}
+
+// NewIdempotencyService creates a new DefaultIdempotencyService instance
+func NewIdempotencyService(idempotencyRepo repository.IdempotencyRepository, cfg *config.IdempotencyConfig, logger *zap.Logger) IdempotencyService {
+	return &DefaultIdempotencyService{
+		idempotencyRepo: idempotencyRepo,
+		config:          cfg,
+		logger:          logger,
+	}
+}
+
+// Begin claims a key for a request identified by its fingerprint
+func (s *DefaultIdempotencyService) Begin(ctx context.Context, userID uuid.UUID, key string, fingerprint string) (*model.IdempotencyRecord, error) {
+	// A key that expires between the claim and the lookup can be claimed on the second attempt
+	for attempt := 0; attempt < 2; attempt++ {
+		claimed, err := s.idempotencyRepo.Claim(ctx, &model.IdempotencyRecord{
+			UserID:      userID,
+			Key:         key,
+			Fingerprint: fingerprint,
+		}, s.config.TTL, s.config.LockTimeout)
+		if err != nil {
+			s.logger.Error("failed to claim idempotency key",
+				zap.String("user_id", userID.String()),
+				zap.Error(err))
+			return nil, err
+		}
+		if claimed {
+			return nil, nil
+		}
+
+		record, err := s.idempotencyRepo.Get(ctx, userID, key)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			s.logger.Error("failed to get idempotency key",
+				zap.String("user_id", userID.String()),
+				zap.Error(err))
+			return nil, err
+		}
+
+		if record.Fingerprint != fingerprint {
+			s.logger.Warn("idempotency key reused with a different request",
+				zap.String("user_id", userID.String()),
+				zap.String("idempotency_key", key))
+			return nil, ErrIdempotencyKeyReused
+		}
+		if !record.Completed() {
+			return nil, ErrIdempotencyKeyInProgress
+		}
+
+		s.logger.Info("replaying response for idempotency key",
+			zap.String("user_id", userID.String()),
+			zap.String("idempotency_key", key),
+			zap.Int("status", *record.ResponseStatus))
+		return record, nil
+	}
+
+	return nil, ErrIdempotencyKeyInProgress
+}
+
+// Complete stores the response to a request claimed with Begin
+func (s *DefaultIdempotencyService) Complete(ctx context.Context, userID uuid.UUID, key string, status int, contentType string, body []byte) error {
+	err := s.idempotencyRepo.SaveResponse(ctx, &model.IdempotencyRecord{
+		UserID:              userID,
+		Key:                 key,
+		ResponseStatus:      &status,
+		ResponseContentType: &contentType,
+		ResponseBody:        body,
+	})
+	if err != nil {
+		s.logger.Error("failed to save response for idempotency key",
+			zap.String("user_id", userID.String()),
+			zap.String("idempotency_key", key),
+			zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// Release gives up a key claimed with Begin without storing a response
+func (s *DefaultIdempotencyService) Release(ctx context.Context, userID uuid.UUID, key string) error {
+	if err := s.idempotencyRepo.Delete(ctx, userID, key); err != nil {
+		s.logger.Error("failed to release idempotency key",
+			zap.String("user_id", userID.String()),
+			zap.String("idempotency_key", key),
+			zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// PurgeExpired removes the keys whose responses are no longer kept
+func (s *DefaultIdempotencyService) PurgeExpired(ctx context.Context) (int64, error) {
+	purged, err := s.idempotencyRepo.DeleteExpired(ctx)
+	if err != nil {
+		s.logger.Error("failed to purge expired idempotency keys",
+			zap.Error(err))
+		return 0, err
+	}
+
+	if purged > 0 {
+		s.logger.Info("purged expired idempotency keys",
+			zap.Int64("count", purged))
+	}
+	return purged, nil
+}
@@ -0,0 +1,108 @@
+package service_test
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+	"go.uber.org/zap"
+)
+
+func TestBeginIdempotentRequest(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+	cfg := config.DefaultIdempotencyConfig()
+	status := http.StatusCreated
+
+	tests := []struct {
+		name           string
+		setupMock      func(repo *MockIdempotencyRepository)
+		expectedRecord bool
+		expectedError  error
+	}{
+		{
+			name: "new key is claimed",
+			setupMock: func(repo *MockIdempotencyRepository) {
+				repo.On("Claim", mock.Anything, mock.MatchedBy(func(r *model.IdempotencyRecord) bool {
+					return r.UserID == userID && r.Key == "key-1" && r.Fingerprint == "fp-1"
+				}), cfg.TTL, cfg.LockTimeout).Return(true, nil)
+			},
+		},
+		{
+			name: "completed request is replayed",
+			setupMock: func(repo *MockIdempotencyRepository) {
+				repo.On("Claim", mock.Anything, mock.Anything, cfg.TTL, cfg.LockTimeout).Return(false, nil)
+				repo.On("Get", mock.Anything, userID, "key-1").Return(&model.IdempotencyRecord{
+					Fingerprint:    "fp-1",
+					ResponseStatus: &status,
+				}, nil)
+			},
+			expectedRecord: true,
+		},
+		{
+			name: "key reused with a different request",
+			setupMock: func(repo *MockIdempotencyRepository) {
+				repo.On("Claim", mock.Anything, mock.Anything, cfg.TTL, cfg.LockTimeout).Return(false, nil)
+				repo.On("Get", mock.Anything, userID, "key-1").Return(&model.IdempotencyRecord{
+					Fingerprint:    "fp-2",
+					ResponseStatus: &status,
+				}, nil)
+			},
+			expectedError: service.ErrIdempotencyKeyReused,
+		},
+		{
+			name: "request still in progress",
+			setupMock: func(repo *MockIdempotencyRepository) {
+				repo.On("Claim", mock.Anything, mock.Anything, cfg.TTL, cfg.LockTimeout).Return(false, nil)
+				repo.On("Get", mock.Anything, userID, "key-1").Return(&model.IdempotencyRecord{Fingerprint: "fp-1"}, nil)
+			},
+			expectedError: service.ErrIdempotencyKeyInProgress,
+		},
+		{
+			name: "key expired between claim and lookup is claimed again",
+			setupMock: func(repo *MockIdempotencyRepository) {
+				repo.On("Claim", mock.Anything, mock.Anything, cfg.TTL, cfg.LockTimeout).Return(false, nil).Once()
+				repo.On("Get", mock.Anything, userID, "key-1").Return(nil, sql.ErrNoRows).Once()
+				repo.On("Claim", mock.Anything, mock.Anything, cfg.TTL, cfg.LockTimeout).Return(true, nil).Once()
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(MockIdempotencyRepository)
+			tt.setupMock(repo)
+			idempotencyService := service.NewIdempotencyService(repo, cfg, zap.NewNop())
+
+			record, err := idempotencyService.Begin(ctx, userID, "key-1", "fp-1")
+
+			assert.Equal(t, tt.expectedError, err)
+			assert.Equal(t, tt.expectedRecord, record != nil)
+			repo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCompleteIdempotentRequest(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+	repo := new(MockIdempotencyRepository)
+	repo.On("SaveResponse", mock.Anything, mock.MatchedBy(func(r *model.IdempotencyRecord) bool {
+		return r.UserID == userID && r.Key == "key-1" && *r.ResponseStatus == http.StatusCreated &&
+			*r.ResponseContentType == "application/json" && string(r.ResponseBody) == `{"id":"1"}`
+	})).Return(nil)
+	idempotencyService := service.NewIdempotencyService(repo, config.DefaultIdempotencyConfig(), zap.NewNop())
+
+	err := idempotencyService.Complete(ctx, userID, "key-1", http.StatusCreated, "application/json", []byte(`{"id":"1"}`))
+
+	require.NoError(t, err)
+	repo.AssertExpectations(t)
+}
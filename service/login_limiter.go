@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrTooManyLoginAttempts is returned when a client IP or email has used up its login attempts
+	ErrTooManyLoginAttempts = errors.New("too many login attempts")
+
+	// ErrAccountLocked is returned when logins for an email are locked after repeated failures
+	ErrAccountLocked = errors.New("account temporarily locked")
+)
+
+// LoginLimiter defines the interface for protecting logins against brute force
+type LoginLimiter interface {
+	// Check takes a login attempt from the allowances of the client IP and email, and checks the email is not locked
+	// When the attempt is refused it returns ErrTooManyLoginAttempts or ErrAccountLocked with the time until it can be retried
+	Check(ctx context.Context, ip, email string) (time.Duration, error)
+
+	// RecordFailure counts a failed login for the email, locking it once the failures reach the threshold
+	RecordFailure(ctx context.Context, email string) error
+
+	// RecordSuccess clears the failed logins of the email
+	RecordSuccess(ctx context.Context, email string) error
+
+	// PurgeExpired removes failed logins that no longer have an effect
+	PurgeExpired(ctx context.Context) error
+}
+
+// DefaultLoginLimiter implements the LoginLimiter interface
+type DefaultLoginLimiter struct {
+	store            repository.RateLimitStore
+	loginAttemptRepo repository.LoginAttemptRepository
+	config           *config.LoginProtectionConfig
+	logger           *zap.Logger
+}
+
+// NewLoginLimiter creates a new DefaultLoginLimiter instance
+func NewLoginLimiter(store repository.RateLimitStore, loginAttemptRepo repository.LoginAttemptRepository, cfg *config.LoginProtectionConfig, logger *zap.Logger) LoginLimiter {
+	return &DefaultLoginLimiter{
+		store:            store,
+		loginAttemptRepo: loginAttemptRepo,
+		config:           cfg,
+		logger:           logger,
+	}
+}
+
+// Check takes a login attempt from the allowances of the client IP and email, and checks the email is not locked
+func (l *DefaultLoginLimiter) Check(ctx context.Context, ip, email string) (time.Duration, error) {
+	email = normalizeLoginEmail(email)
+
+	buckets := []struct {
+		key   string
+		limit model.RateLimit
+	}{
+		{key: "login:ip:" + ip, limit: model.RateLimit{Burst: l.config.IPBurst, Period: l.config.IPPeriod}},
+		{key: "login:account:" + email, limit: model.RateLimit{Burst: l.config.AccountBurst, Period: l.config.AccountPeriod}},
+	}
+	for _, bucket := range buckets {
+		result, err := l.store.Take(ctx, bucket.key, bucket.limit)
+		if err != nil {
+			l.logger.Error("failed to take login rate limit token",
+				zap.String("bucket", bucket.key),
+				zap.Error(err))
+			return 0, err
+		}
+		if !result.Allowed {
+			l.logger.Warn("login rate limit exceeded",
+				zap.String("bucket", bucket.key),
+				zap.Duration("retry_after", result.RetryAfter))
+			return result.RetryAfter, ErrTooManyLoginAttempts
+		}
+	}
+
+	lockout, err := l.loginAttemptRepo.GetLockout(ctx, email)
+	if err != nil {
+		l.logger.Error("failed to get login lockout",
+			zap.String("email", email),
+			zap.Error(err))
+		return 0, err
+	}
+	if lockout > 0 {
+		l.logger.Warn("login attempted for locked account",
+			zap.String("email", email),
+			zap.Duration("retry_after", lockout))
+		return lockout, ErrAccountLocked
+	}
+
+	return 0, nil
+}
+
+// RecordFailure counts a failed login for the email, locking it once the failures reach the threshold
+// Every failure past the threshold doubles the lockout, up to the maximum
+func (l *DefaultLoginLimiter) RecordFailure(ctx context.Context, email string) error {
+	email = normalizeLoginEmail(email)
+
+	failures, err := l.loginAttemptRepo.RecordFailure(ctx, email, l.config.LockoutFailureWindow)
+	if err != nil {
+		l.logger.Error("failed to record failed login",
+			zap.String("email", email),
+			zap.Error(err))
+		return err
+	}
+	if failures < l.config.LockoutThreshold {
+		return nil
+	}
+
+	duration := lockoutDuration(failures-l.config.LockoutThreshold, l.config.LockoutBaseDuration, l.config.LockoutMaxDuration)
+	if err := l.loginAttemptRepo.Lock(ctx, email, duration); err != nil {
+		l.logger.Error("failed to lock account",
+			zap.String("email", email),
+			zap.Error(err))
+		return err
+	}
+
+	l.logger.Warn("account locked after failed logins",
+		zap.String("email", email),
+		zap.Int("failures", failures),
+		zap.Duration("duration", duration))
+	return nil
+}
+
+// RecordSuccess clears the failed logins of the email
+func (l *DefaultLoginLimiter) RecordSuccess(ctx context.Context, email string) error {
+	email = normalizeLoginEmail(email)
+
+	if err := l.loginAttemptRepo.Reset(ctx, email); err != nil {
+		l.logger.Error("failed to reset failed logins",
+			zap.String("email", email),
+			zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// PurgeExpired removes failed logins that no longer have an effect
+func (l *DefaultLoginLimiter) PurgeExpired(ctx context.Context) error {
+	purged, err := l.loginAttemptRepo.DeleteStale(ctx, l.config.LockoutFailureWindow)
+	if err != nil {
+		l.logger.Error("failed to purge stale failed logins",
+			zap.Error(err))
+		return err
+	}
+
+	if purged > 0 {
+		l.logger.Info("purged stale failed logins",
+			zap.Int64("count", purged))
+	}
+	return nil
+}
+
+// lockoutDuration returns the lockout for the given number of failures past the threshold
+func lockoutDuration(excess int, base, maxDuration time.Duration) time.Duration {
+	duration := base
+	for i := 0; i < excess && duration < maxDuration; i++ {
+		duration *= 2
+	}
+	return min(duration, maxDuration)
+}
+
+// normalizeLoginEmail returns the form of an email failed logins are counted under
+func normalizeLoginEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
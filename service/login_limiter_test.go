@@ -0,0 +1,144 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+	"go.uber.org/zap"
+)
+
+func TestCheckLogin(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.DefaultLoginProtectionConfig()
+	ipLimit := model.RateLimit{Burst: cfg.IPBurst, Period: cfg.IPPeriod}
+	accountLimit := model.RateLimit{Burst: cfg.AccountBurst, Period: cfg.AccountPeriod}
+
+	tests := []struct {
+		name               string
+		setupMock          func(store *MockRateLimitStore, repo *MockLoginAttemptRepository)
+		expectedRetryAfter time.Duration
+		expectedError      error
+	}{
+		{
+			name: "attempt within limits is allowed",
+			setupMock: func(store *MockRateLimitStore, repo *MockLoginAttemptRepository) {
+				store.On("Take", mock.Anything, "login:ip:192.0.2.1", ipLimit).Return(&model.RateLimitResult{Allowed: true}, nil)
+				store.On("Take", mock.Anything, "login:account:user@example.com", accountLimit).Return(&model.RateLimitResult{Allowed: true}, nil)
+				repo.On("GetLockout", mock.Anything, "user@example.com").Return(time.Duration(0), nil)
+			},
+		},
+		{
+			name: "IP out of attempts",
+			setupMock: func(store *MockRateLimitStore, repo *MockLoginAttemptRepository) {
+				store.On("Take", mock.Anything, "login:ip:192.0.2.1", ipLimit).Return(&model.RateLimitResult{RetryAfter: 3 * time.Second}, nil)
+			},
+			expectedRetryAfter: 3 * time.Second,
+			expectedError:      service.ErrTooManyLoginAttempts,
+		},
+		{
+			name: "account out of attempts",
+			setupMock: func(store *MockRateLimitStore, repo *MockLoginAttemptRepository) {
+				store.On("Take", mock.Anything, "login:ip:192.0.2.1", ipLimit).Return(&model.RateLimitResult{Allowed: true}, nil)
+				store.On("Take", mock.Anything, "login:account:user@example.com", accountLimit).Return(&model.RateLimitResult{RetryAfter: 12 * time.Second}, nil)
+			},
+			expectedRetryAfter: 12 * time.Second,
+			expectedError:      service.ErrTooManyLoginAttempts,
+		},
+		{
+			name: "locked account",
+			setupMock: func(store *MockRateLimitStore, repo *MockLoginAttemptRepository) {
+				store.On("Take", mock.Anything, mock.Anything, mock.Anything).Return(&model.RateLimitResult{Allowed: true}, nil)
+				repo.On("GetLockout", mock.Anything, "user@example.com").Return(2*time.Minute, nil)
+			},
+			expectedRetryAfter: 2 * time.Minute,
+			expectedError:      service.ErrAccountLocked,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := new(MockRateLimitStore)
+			repo := new(MockLoginAttemptRepository)
+			tt.setupMock(store, repo)
+			limiter := service.NewLoginLimiter(store, repo, cfg, zap.NewNop())
+
+			// The email is counted in a normalized form
+			retryAfter, err := limiter.Check(ctx, "192.0.2.1", " User@Example.com ")
+
+			assert.Equal(t, tt.expectedError, err)
+			assert.Equal(t, tt.expectedRetryAfter, retryAfter)
+			store.AssertExpectations(t)
+			repo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRecordLoginFailure(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.DefaultLoginProtectionConfig()
+
+	tests := []struct {
+		name             string
+		failures         int
+		expectedLockout  time.Duration
+		expectLockCalled bool
+	}{
+		{
+			name:     "failure below the threshold",
+			failures: cfg.LockoutThreshold - 1,
+		},
+		{
+			name:             "failure reaching the threshold locks for the base duration",
+			failures:         cfg.LockoutThreshold,
+			expectedLockout:  cfg.LockoutBaseDuration,
+			expectLockCalled: true,
+		},
+		{
+			name:             "further failures double the lockout",
+			failures:         cfg.LockoutThreshold + 2,
+			expectedLockout:  4 * cfg.LockoutBaseDuration,
+			expectLockCalled: true,
+		},
+		{
+			name:             "lockout is capped",
+			failures:         cfg.LockoutThreshold + 100,
+			expectedLockout:  cfg.LockoutMaxDuration,
+			expectLockCalled: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := new(MockRateLimitStore)
+			repo := new(MockLoginAttemptRepository)
+			repo.On("RecordFailure", mock.Anything, "user@example.com", cfg.LockoutFailureWindow).Return(tt.failures, nil)
+			if tt.expectLockCalled {
+				repo.On("Lock", mock.Anything, "user@example.com", tt.expectedLockout).Return(nil)
+			}
+			limiter := service.NewLoginLimiter(store, repo, cfg, zap.NewNop())
+
+			err := limiter.RecordFailure(ctx, "User@example.com")
+
+			assert.NoError(t, err)
+			repo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRecordLoginSuccess(t *testing.T) {
+	store := new(MockRateLimitStore)
+	repo := new(MockLoginAttemptRepository)
+	repo.On("Reset", mock.Anything, "user@example.com").Return(nil)
+	limiter := service.NewLoginLimiter(store, repo, config.DefaultLoginProtectionConfig(), zap.NewNop())
+
+	err := limiter.RecordSuccess(context.Background(), "user@example.com")
+
+	assert.NoError(t, err)
+	repo.AssertExpectations(t)
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yukimaterrace/todoms/config"
+	"go.uber.org/zap"
+)
+
+// Email represents a plain text email
+type Email struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails
+type Mailer interface {
+	Send(ctx context.Context, email *Email) error
+}
+
+// NewMailer creates the Mailer selected by the driver of the configuration
+func NewMailer(cfg *config.MailConfig, logger *zap.Logger) (Mailer, error) {
+	if _, err := mail.ParseAddress(cfg.From); err != nil {
+		return nil, fmt.Errorf("invalid mail from address %q: %w", cfg.From, err)
+	}
+
+	switch cfg.Driver {
+	case config.MailDriverSMTP:
+		return NewSMTPMailer(cfg), nil
+	case config.MailDriverLog, "":
+		return NewFileMailer(cfg.From, cfg.Dir, logger), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.Driver)
+	}
+}
+
+// SMTPMailer sends emails through an SMTP server
+// STARTTLS is used when the server offers it, and authentication when a username is configured
+type SMTPMailer struct {
+	cfg *config.MailConfig
+}
+
+// NewSMTPMailer creates a new SMTPMailer instance
+func NewSMTPMailer(cfg *config.MailConfig) *SMTPMailer {
+	return &SMTPMailer{cfg: cfg}
+}
+
+// Send sends the email through the SMTP server
+func (m *SMTPMailer) Send(ctx context.Context, email *Email) error {
+	from, err := mail.ParseAddress(m.cfg.From)
+	if err != nil {
+		return err
+	}
+	to, err := mail.ParseAddress(email.To)
+	if err != nil {
+		return err
+	}
+	message, err := buildMessage(m.cfg.From, email)
+	if err != nil {
+		return err
+	}
+
+	timeout := m.cfg.SMTPTimeout
+	if timeout <= 0 {
+		timeout = config.DefaultSMTPTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(m.cfg.SMTPHost, m.cfg.SMTPPort))
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, m.cfg.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: m.cfg.SMTPHost}); err != nil {
+			return err
+		}
+	}
+	if m.cfg.SMTPUsername != "" {
+		auth := smtp.PlainAuth("", m.cfg.SMTPUsername, m.cfg.SMTPPassword, m.cfg.SMTPHost)
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return err
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(message); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// FileMailer logs emails instead of sending them, for development
+// When a directory is set, each email is also written to it as an .eml file
+type FileMailer struct {
+	from   string
+	dir    string
+	logger *zap.Logger
+}
+
+// NewFileMailer creates a new FileMailer instance
+func NewFileMailer(from, dir string, logger *zap.Logger) *FileMailer {
+	return &FileMailer{
+		from:   from,
+		dir:    dir,
+		logger: logger,
+	}
+}
+
+// Send logs the email and writes it to the directory when one is set
+func (m *FileMailer) Send(ctx context.Context, email *Email) error {
+	message, err := buildMessage(m.from, email)
+	if err != nil {
+		return err
+	}
+
+	fields := []zap.Field{
+		zap.String("to", email.To),
+		zap.String("subject", email.Subject),
+		zap.String("body", email.Body),
+	}
+
+	if m.dir != "" {
+		if err := os.MkdirAll(m.dir, 0o755); err != nil {
+			return err
+		}
+		name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405Z"), uuid.New())
+		path := filepath.Join(m.dir, name)
+		if err := os.WriteFile(path, message, 0o600); err != nil {
+			return err
+		}
+		fields = append(fields, zap.String("path", path))
+	}
+
+	m.logger.Info("email not sent, logged for development", fields...)
+	return nil
+}
+
+// buildMessage formats the email as an RFC 5322 message with CRLF line endings
+func buildMessage(from string, email *Email) ([]byte, error) {
+	if _, err := mail.ParseAddress(email.To); err != nil {
+		return nil, err
+	}
+	// Addresses have been parsed, but a subject with line breaks could inject headers
+	subject := strings.NewReplacer("\r", " ", "\n", " ").Replace(email.Subject)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", email.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@todoms>\r\n", uuid.New())
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	buf.WriteString("\r\n")
+
+	body := strings.ReplaceAll(email.Body, "\r\n", "\n")
+	buf.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return buf.Bytes(), nil
+}
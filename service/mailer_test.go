@@ -0,0 +1,194 @@
+package service_test
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/service"
+	"go.uber.org/zap"
+)
+
+// fakeSMTPMessage is a message received by fakeSMTPServer
+type fakeSMTPMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+// fakeSMTPServer is a minimal SMTP server on localhost that accepts every message
+// It does not offer STARTTLS or AUTH
+type fakeSMTPServer struct {
+	listener net.Listener
+	messages chan fakeSMTPMessage
+}
+
+// newFakeSMTPServer starts a fakeSMTPServer that is closed when the test ends
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &fakeSMTPServer{listener: listener, messages: make(chan fakeSMTPMessage, 10)}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+// serve handles an SMTP session
+func (s *fakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 fake ESMTP")
+	var message fakeSMTPMessage
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 fake")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			message.from = strings.Trim(strings.TrimSpace(line)[len("MAIL FROM:"):], "<>")
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			message.to = append(message.to, strings.Trim(strings.TrimSpace(line)[len("RCPT TO:"):], "<>"))
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(dataLine)
+			}
+			message.data = data.String()
+			s.messages <- message
+			message = fakeSMTPMessage{}
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func TestSMTPMailer(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	host, port, err := net.SplitHostPort(server.listener.Addr().String())
+	require.NoError(t, err)
+
+	cfg := config.DefaultMailConfig()
+	cfg.Driver = config.MailDriverSMTP
+	cfg.From = "todoms <no-reply@example.com>"
+	cfg.SMTPHost = host
+	cfg.SMTPPort = port
+	mailer, err := service.NewMailer(cfg, zap.NewNop())
+	require.NoError(t, err)
+
+	err = mailer.Send(context.Background(), &service.Email{
+		To:      "test@example.com",
+		Subject: "Verify your email address\r\nBcc: attacker@example.com",
+		Body:    "First line\nSecond line\n",
+	})
+	require.NoError(t, err)
+
+	message := <-server.messages
+	assert.Equal(t, "no-reply@example.com", message.from)
+	assert.Equal(t, []string{"test@example.com"}, message.to)
+	assert.Contains(t, message.data, "From: todoms <no-reply@example.com>\r\n")
+	assert.Contains(t, message.data, "To: test@example.com\r\n")
+	assert.Contains(t, message.data, "\r\n\r\nFirst line\r\nSecond line\r\n")
+	// A line break in the subject must not start a new header
+	assert.NotContains(t, message.data, "\r\nBcc:")
+}
+
+func TestSMTPMailerUnreachable(t *testing.T) {
+	// Find a free port and close it so that nothing is listening
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	listener.Close()
+
+	cfg := config.DefaultMailConfig()
+	cfg.SMTPHost = host
+	cfg.SMTPPort = port
+	err = service.NewSMTPMailer(cfg).Send(context.Background(), &service.Email{To: "test@example.com"})
+	assert.Error(t, err)
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	mailer := service.NewFileMailer("todoms <no-reply@example.com>", dir, zap.NewNop())
+
+	err := mailer.Send(context.Background(), &service.Email{
+		To:      "test@example.com",
+		Subject: "Verify your email address",
+		Body:    "https://example.com/verify-email?token=abc\n",
+	})
+	require.NoError(t, err)
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.True(t, strings.HasSuffix(files[0].Name(), ".eml"))
+
+	content, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "To: test@example.com\r\n")
+	assert.Contains(t, string(content), "https://example.com/verify-email?token=abc\r\n")
+}
+
+func TestNewMailer(t *testing.T) {
+	testCases := []struct {
+		name        string
+		configure   func(*config.MailConfig)
+		expectError bool
+	}{
+		{name: "Log driver", configure: func(cfg *config.MailConfig) {}},
+		{name: "SMTP driver", configure: func(cfg *config.MailConfig) { cfg.Driver = config.MailDriverSMTP }},
+		{name: "Unknown driver", configure: func(cfg *config.MailConfig) { cfg.Driver = "carrier-pigeon" }, expectError: true},
+		{name: "Invalid from address", configure: func(cfg *config.MailConfig) { cfg.From = "not an address" }, expectError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.DefaultMailConfig()
+			tc.configure(cfg)
+
+			mailer, err := service.NewMailer(cfg, zap.NewNop())
+			if tc.expectError {
+				assert.Error(t, err)
+				assert.Nil(t, mailer)
+			} else {
+				assert.NoError(t, err)
+				assert.NotNil(t, mailer)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package service
+
+import (
+	"bytes"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+var (
+	// markdown converts markdown to HTML, including GitHub flavored extensions
+	markdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+	// htmlPolicy only lets through HTML that is safe to embed in a page
+	htmlPolicy = bluemonday.UGCPolicy()
+)
+
+// renderMarkdown converts user supplied markdown to sanitised HTML
+func renderMarkdown(source string) (string, error) {
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+	return htmlPolicy.Sanitize(buf.String()), nil
+}
@@ -0,0 +1,335 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrMFAAlreadyEnabled is returned when starting MFA enrolment for a user who has MFA enabled
+	ErrMFAAlreadyEnabled = errors.New("MFA is already enabled")
+
+	// ErrMFANotEnrolled is returned when confirming MFA enrolment before it was started
+	ErrMFANotEnrolled = errors.New("MFA enrolment has not been started")
+
+	// ErrMFANotEnabled is returned when managing MFA for a user who does not have it enabled
+	ErrMFANotEnabled = errors.New("MFA is not enabled")
+
+	// ErrInvalidMFACode is returned when a TOTP or recovery code is wrong or was already used
+	ErrInvalidMFACode = errors.New("invalid MFA code")
+)
+
+// MFAEnrollment is a pending TOTP authenticator to be added to an authenticator app
+type MFAEnrollment struct {
+	Secret     string
+	OTPAuthURI string
+}
+
+// MFAService defines the interface for TOTP two-factor authentication
+type MFAService interface {
+	// GetStatus retrieves whether the user has MFA enabled and how many recovery codes they have left
+	GetStatus(ctx context.Context, userID uuid.UUID) (*model.MFAStatus, error)
+
+	// BeginEnrollment generates a new TOTP secret for the user, which is pending until confirmed
+	BeginEnrollment(ctx context.Context, userID uuid.UUID) (*MFAEnrollment, error)
+
+	// ConfirmEnrollment enables MFA with the first code from the pending secret and returns new recovery codes
+	ConfirmEnrollment(ctx context.Context, userID uuid.UUID, code string) ([]string, error)
+
+	// VerifyCode checks a TOTP or recovery code of a user with MFA enabled, using it up
+	VerifyCode(ctx context.Context, userID uuid.UUID, code string) error
+
+	// RegenerateRecoveryCodes replaces the recovery codes of the user after checking a code
+	RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, code string) ([]string, error)
+
+	// Disable turns off MFA for the user after checking a code
+	Disable(ctx context.Context, userID uuid.UUID, code string) error
+}
+
+// DefaultMFAService implements the MFAService interface
+type DefaultMFAService struct {
+	userRepo   repository.UserRepository
+	mfaRepo    repository.MFARepository
+	transactor repository.Transactor
+	config     *config.MFAConfig
+	logger     *zap.Logger
+}
+
+// NewMFAService creates a new DefaultMFAService instance
+func NewMFAService(
+	userRepo repository.UserRepository,
+	mfaRepo repository.MFARepository,
+	transactor repository.Transactor,
+	cfg *config.MFAConfig,
+	logger *zap.Logger,
+) MFAService {
+	return &DefaultMFAService{
+		userRepo:   userRepo,
+		mfaRepo:    mfaRepo,
+		transactor: transactor,
+		config:     cfg,
+		logger:     logger,
+	}
+}
+
+// GetStatus retrieves whether the user has MFA enabled and how many recovery codes they have left
+func (s *DefaultMFAService) GetStatus(ctx context.Context, userID uuid.UUID) (*model.MFAStatus, error) {
+	mfa, err := s.getMFA(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa == nil || !mfa.Enabled() {
+		return &model.MFAStatus{}, nil
+	}
+
+	remaining, err := s.mfaRepo.CountRecoveryCodes(ctx, userID)
+	if err != nil {
+		s.logger.Error("failed to count recovery codes",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	return &model.MFAStatus{Enabled: true, RemainingRecoveryCodes: remaining}, nil
+}
+
+// BeginEnrollment generates a new TOTP secret for the user
+// A pending secret generated before is replaced, so only the last one shown can be confirmed
+func (s *DefaultMFAService) BeginEnrollment(ctx context.Context, userID uuid.UUID) (*MFAEnrollment, error) {
+	mfa, err := s.getMFA(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa != nil && mfa.Enabled() {
+		s.logger.Warn("attempt to enrol MFA when already enabled",
+			zap.String("user_id", userID.String()))
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		s.logger.Error("failed to get user",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := encryptMFASecret(s.config.EncryptionKey, secret)
+	if err != nil {
+		s.logger.Error("failed to encrypt MFA secret",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	if err := s.mfaRepo.Save(ctx, &model.UserMFA{UserID: userID, Secret: encrypted}); err != nil {
+		s.logger.Error("failed to save pending MFA secret",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("MFA enrolment started",
+		zap.String("user_id", userID.String()))
+	return &MFAEnrollment{
+		Secret:     totpEncoding.EncodeToString(secret),
+		OTPAuthURI: otpauthURI(s.config.Issuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmEnrollment enables MFA with the first code from the pending secret and returns new recovery codes
+func (s *DefaultMFAService) ConfirmEnrollment(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	mfa, err := s.getMFA(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa == nil {
+		return nil, ErrMFANotEnrolled
+	}
+	if mfa.Enabled() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	var recoveryCodes []string
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.useTOTPCode(ctx, mfa, code); err != nil {
+			return err
+		}
+		if err := s.mfaRepo.Enable(ctx, userID); err != nil {
+			return err
+		}
+
+		var err error
+		recoveryCodes, err = s.replaceRecoveryCodes(ctx, userID)
+		return err
+	})
+	if errors.Is(err, ErrInvalidMFACode) {
+		s.logger.Warn("MFA enrolment confirmed with invalid code",
+			zap.String("user_id", userID.String()))
+		return nil, err
+	}
+	if err != nil {
+		s.logger.Error("failed to confirm MFA enrolment",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("MFA enabled",
+		zap.String("user_id", userID.String()))
+	return recoveryCodes, nil
+}
+
+// VerifyCode checks a TOTP or recovery code of a user with MFA enabled, using it up
+func (s *DefaultMFAService) VerifyCode(ctx context.Context, userID uuid.UUID, code string) error {
+	mfa, err := s.getMFA(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if mfa == nil || !mfa.Enabled() {
+		return ErrMFANotEnabled
+	}
+
+	err = s.useTOTPCode(ctx, mfa, code)
+	if errors.Is(err, ErrInvalidMFACode) {
+		var consumed bool
+		consumed, err = s.mfaRepo.ConsumeRecoveryCode(ctx, userID, hashRecoveryCode(code))
+		if err == nil && !consumed {
+			err = ErrInvalidMFACode
+		}
+		if consumed {
+			s.logger.Info("recovery code used",
+				zap.String("user_id", userID.String()))
+		}
+	}
+	if errors.Is(err, ErrInvalidMFACode) {
+		s.logger.Warn("invalid MFA code",
+			zap.String("user_id", userID.String()))
+		return err
+	}
+	if err != nil {
+		s.logger.Error("failed to verify MFA code",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the user after checking a code
+func (s *DefaultMFAService) RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	if err := s.VerifyCode(ctx, userID, code); err != nil {
+		return nil, err
+	}
+
+	var recoveryCodes []string
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		recoveryCodes, err = s.replaceRecoveryCodes(ctx, userID)
+		return err
+	})
+	if err != nil {
+		s.logger.Error("failed to regenerate recovery codes",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("recovery codes regenerated",
+		zap.String("user_id", userID.String()))
+	return recoveryCodes, nil
+}
+
+// Disable turns off MFA for the user after checking a code, removing the secret and the recovery codes
+func (s *DefaultMFAService) Disable(ctx context.Context, userID uuid.UUID, code string) error {
+	if err := s.VerifyCode(ctx, userID, code); err != nil {
+		return err
+	}
+
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		return s.mfaRepo.Delete(ctx, userID)
+	})
+	if err != nil {
+		s.logger.Error("failed to disable MFA",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return err
+	}
+
+	s.logger.Info("MFA disabled",
+		zap.String("user_id", userID.String()))
+	return nil
+}
+
+// getMFA retrieves the authenticator of a user, or nil when they have none
+func (s *DefaultMFAService) getMFA(ctx context.Context, userID uuid.UUID) (*model.UserMFA, error) {
+	mfa, err := s.mfaRepo.GetByUserID(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		s.logger.Error("failed to get MFA",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+	return mfa, nil
+}
+
+// useTOTPCode checks a TOTP code against the secret of an authenticator and records its time step as used
+func (s *DefaultMFAService) useTOTPCode(ctx context.Context, mfa *model.UserMFA, code string) error {
+	secret, err := decryptMFASecret(s.config.EncryptionKey, mfa.Secret)
+	if err != nil {
+		return err
+	}
+
+	step, ok := matchTOTPCode(secret, code, time.Now())
+	if !ok {
+		return ErrInvalidMFACode
+	}
+
+	// A code is accepted once, and neither are codes older than the last one used
+	fresh, err := s.mfaRepo.UseStep(ctx, mfa.UserID, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// replaceRecoveryCodes generates new recovery codes for a user, storing their hashes
+func (s *DefaultMFAService) replaceRecoveryCodes(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	codes := make([]string, s.config.RecoveryCodeCount)
+	hashes := make([][]byte, s.config.RecoveryCodeCount)
+	for i := range codes {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		hashes[i] = hashRecoveryCode(code)
+	}
+
+	if err := s.mfaRepo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
@@ -0,0 +1,465 @@
+package service_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+	"go.uber.org/zap"
+)
+
+// newMFAService creates an MFAService with the mocks
+func newMFAService(userRepo *MockUserRepository, mfaRepo *MockMFARepository) service.MFAService {
+	cfg := &config.MFAConfig{
+		Issuer:            "todoms",
+		EncryptionKey:     "test-mfa-key",
+		RecoveryCodeCount: 10,
+	}
+	return service.NewMFAService(userRepo, mfaRepo, new(MockTransactor), cfg, zap.NewNop())
+}
+
+// enrollMFA enrols an authenticator for a user, returning its secret and the stored authenticator, enabled
+func enrollMFA(t *testing.T, user *model.User) ([]byte, *model.UserMFA) {
+	userRepo := new(MockUserRepository)
+	mfaRepo := new(MockMFARepository)
+	userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+	mfaRepo.On("GetByUserID", mock.Anything, user.ID).Return(nil, sql.ErrNoRows)
+
+	var stored *model.UserMFA
+	mfaRepo.On("Save", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		stored = args.Get(1).(*model.UserMFA)
+	}).Return(nil)
+
+	enrollment, err := newMFAService(userRepo, mfaRepo).BeginEnrollment(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(enrollment.Secret)
+	require.NoError(t, err)
+
+	enabledAt := time.Now()
+	stored.EnabledAt = &enabledAt
+	return secret, stored
+}
+
+// totpCodeAt computes the RFC 6238 code of a secret at a time, independently of the service
+func totpCodeAt(secret []byte, at time.Time) string {
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(at.Unix()/30))
+
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+
+	offset := sum[19] & 0x0f
+	value := (uint32(sum[offset])&0x7f)<<24 | uint32(sum[offset+1])<<16 | uint32(sum[offset+2])<<8 | uint32(sum[offset+3])
+	return fmt.Sprintf("%06d", value%1000000)
+}
+
+// wrongTOTPCode returns a well-formed code that is not valid for the secret around now
+func wrongTOTPCode(secret []byte) string {
+	now := time.Now()
+	valid := map[string]bool{}
+	for _, d := range []time.Duration{-30 * time.Second, 0, 30 * time.Second} {
+		valid[totpCodeAt(secret, now.Add(d))] = true
+	}
+	for i := 0; ; i++ {
+		code := fmt.Sprintf("%06d", i)
+		if !valid[code] {
+			return code
+		}
+	}
+}
+
+func TestGetMFAStatus(t *testing.T) {
+	user := newUserWithPassword(t, "password123")
+	_, enabled := enrollMFA(t, user)
+	pending := *enabled
+	pending.EnabledAt = nil
+
+	testCases := []struct {
+		name           string
+		setupMock      func(*MockMFARepository)
+		expectedStatus *model.MFAStatus
+	}{
+		{
+			name: "Not enrolled",
+			setupMock: func(mfaRepo *MockMFARepository) {
+				mfaRepo.On("GetByUserID", mock.Anything, user.ID).Return(nil, sql.ErrNoRows)
+			},
+			expectedStatus: &model.MFAStatus{},
+		},
+		{
+			name: "Pending enrolment",
+			setupMock: func(mfaRepo *MockMFARepository) {
+				mfaRepo.On("GetByUserID", mock.Anything, user.ID).Return(&pending, nil)
+			},
+			expectedStatus: &model.MFAStatus{},
+		},
+		{
+			name: "Enabled",
+			setupMock: func(mfaRepo *MockMFARepository) {
+				mfaRepo.On("GetByUserID", mock.Anything, user.ID).Return(enabled, nil)
+				mfaRepo.On("CountRecoveryCodes", mock.Anything, user.ID).Return(7, nil)
+			},
+			expectedStatus: &model.MFAStatus{Enabled: true, RemainingRecoveryCodes: 7},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mfaRepo := new(MockMFARepository)
+			tc.setupMock(mfaRepo)
+
+			status, err := newMFAService(new(MockUserRepository), mfaRepo).GetStatus(context.Background(), user.ID)
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedStatus, status)
+			mfaRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestBeginMFAEnrollment(t *testing.T) {
+	user := newUserWithPassword(t, "password123")
+
+	t.Run("Success", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		mfaRepo := new(MockMFARepository)
+		userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+		mfaRepo.On("GetByUserID", mock.Anything, user.ID).Return(nil, sql.ErrNoRows)
+
+		var stored *model.UserMFA
+		mfaRepo.On("Save", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			stored = args.Get(1).(*model.UserMFA)
+		}).Return(nil)
+
+		enrollment, err := newMFAService(userRepo, mfaRepo).BeginEnrollment(context.Background(), user.ID)
+		require.NoError(t, err)
+
+		secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(enrollment.Secret)
+		require.NoError(t, err)
+		assert.Len(t, secret, 20)
+
+		// The secret is stored encrypted, and the authenticator is pending
+		require.NotNil(t, stored)
+		assert.Equal(t, user.ID, stored.UserID)
+		assert.NotContains(t, string(stored.Secret), string(secret))
+		assert.False(t, stored.Enabled())
+
+		uri, err := url.Parse(enrollment.OTPAuthURI)
+		require.NoError(t, err)
+		assert.Equal(t, "otpauth", uri.Scheme)
+		assert.Equal(t, "totp", uri.Host)
+		assert.Equal(t, "/todoms:test@example.com", uri.Path)
+		assert.Equal(t, enrollment.Secret, uri.Query().Get("secret"))
+		assert.Equal(t, "todoms", uri.Query().Get("issuer"))
+		assert.Equal(t, "6", uri.Query().Get("digits"))
+		assert.Equal(t, "30", uri.Query().Get("period"))
+		userRepo.AssertExpectations(t)
+		mfaRepo.AssertExpectations(t)
+	})
+
+	t.Run("Already enabled", func(t *testing.T) {
+		_, enabled := enrollMFA(t, user)
+		mfaRepo := new(MockMFARepository)
+		mfaRepo.On("GetByUserID", mock.Anything, user.ID).Return(enabled, nil)
+
+		enrollment, err := newMFAService(new(MockUserRepository), mfaRepo).BeginEnrollment(context.Background(), user.ID)
+
+		assert.Equal(t, service.ErrMFAAlreadyEnabled, err)
+		assert.Nil(t, enrollment)
+		mfaRepo.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	})
+
+	t.Run("User not found", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		mfaRepo := new(MockMFARepository)
+		userRepo.On("GetByID", mock.Anything, user.ID).Return(nil, sql.ErrNoRows)
+		mfaRepo.On("GetByUserID", mock.Anything, user.ID).Return(nil, sql.ErrNoRows)
+
+		enrollment, err := newMFAService(userRepo, mfaRepo).BeginEnrollment(context.Background(), user.ID)
+
+		assert.Equal(t, service.ErrUserNotFound, err)
+		assert.Nil(t, enrollment)
+	})
+}
+
+func TestConfirmMFAEnrollment(t *testing.T) {
+	user := newUserWithPassword(t, "password123")
+	secret, enabled := enrollMFA(t, user)
+	pending := *enabled
+	pending.EnabledAt = nil
+
+	testCases := []struct {
+		name          string
+		code          string
+		setupMock     func(*MockMFARepository)
+		expectedError error
+	}{
+		{
+			name: "Success",
+			code: totpCodeAt(secret, time.Now()),
+			setupMock: func(mfaRepo *MockMFARepository) {
+				mfaRepo.On("GetByUserID", mock.Anything, user.ID).Return(&pending, nil)
+				mfaRepo.On("UseStep", mock.Anything, user.ID, mock.AnythingOfType("int64")).Return(true, nil)
+				mfaRepo.On("Enable", mock.Anything, user.ID).Return(nil)
+				mfaRepo.On("ReplaceRecoveryCodes", mock.Anything, user.ID, mock.MatchedBy(func(hashes [][]byte) bool {
+					return len(hashes) == 10
+				})).Return(nil)
+			},
+		},
+		{
+			name: "Code from the previous time step",
+			code: totpCodeAt(secret, time.Now().Add(-30*time.Second)),
+			setupMock: func(mfaRepo *MockMFARepository) {
+				mfaRepo.On("GetByUserID", mock.Anything, user.ID).Return(&pending, nil)
+				mfaRepo.On("UseStep", mock.Anything, user.ID, mock.AnythingOfType("int64")).Return(true, nil)
+				mfaRepo.On("Enable", mock.Anything, user.ID).Return(nil)
+				mfaRepo.On("ReplaceRecoveryCodes", mock.Anything, user.ID, mock.Anything).Return(nil)
+			},
+		},
+		{
+			name: "Wrong code",
+			code: wrongTOTPCode(secret),
+			setupMock: func(mfaRepo *MockMFARepository) {
+				mfaRepo.On("GetByUserID", mock.Anything, user.ID).Return(&pending, nil)
+			},
+			expectedError: service.ErrInvalidMFACode,
+		},
+		{
+			name: "Code already used",
+			code: totpCodeAt(secret, time.Now()),
+			setupMock: func(mfaRepo *MockMFARepository) {
+				mfaRepo.On("GetByUserID", mock.Anything, user.ID).Return(&pending, nil)
+				mfaRepo.On("UseStep", mock.Anything, user.ID, mock.AnythingOfType("int64")).Return(false, nil)
+			},
+			expectedError: service.ErrInvalidMFACode,
+		},
+		{
+			name: "Not enrolled",
+			code: "123456",
+			setupMock: func(mfaRepo *MockMFARepository) {
+				mfaRepo.On("GetByUserID", mock.Anything, user.ID).Return(nil, sql.ErrNoRows)
+			},
+			expectedError: service.ErrMFANotEnrolled,
+		},
+		{
+			name: "Already enabled",
+			code: totpCodeAt(secret, time.Now()),
+			setupMock: func(mfaRepo *MockMFARepository) {
+				mfaRepo.On("GetByUserID", mock.Anything, user.ID).Return(enabled, nil)
+			},
+			expectedError: service.ErrMFAAlreadyEnabled,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mfaRepo := new(MockMFARepository)
+			tc.setupMock(mfaRepo)
+
+			recoveryCodes, err := newMFAService(new(MockUserRepository), mfaRepo).
+				ConfirmEnrollment(context.Background(), user.ID, tc.code)
+
+			if tc.expectedError != nil {
+				assert.Equal(t, tc.expectedError, err)
+				assert.Nil(t, recoveryCodes)
+				mfaRepo.AssertNotCalled(t, "Enable", mock.Anything, mock.Anything)
+			} else {
+				require.NoError(t, err)
+				assert.Len(t, recoveryCodes, 10)
+				seen := map[string]bool{}
+				for _, code := range recoveryCodes {
+					assert.Regexp(t, `^[a-z2-7]{8}-[a-z2-7]{8}$`, code)
+					assert.False(t, seen[code], "recovery codes should be unique")
+					seen[code] = true
+				}
+			}
+			mfaRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestVerifyMFACode(t *testing.T) {
+	user := newUserWithPassword(t, "password123")
+	secret, enabled := enrollMFA(t, user)
+
+	testCases := []struct {
+		name          string
+		code          string
+		setupMock     func(*MockMFARepository)
+		expectedError error
+	}{
+		{
+			name: "TOTP code",
+			code: totpCodeAt(secret, time.Now()),
+			setupMock: func(mfaRepo *MockMFARepository) {
+				mfaRepo.On("GetByUserID", mock.Anything, user.ID).Return(enabled, nil)
+				mfaRepo.On("UseStep", mock.Anything, user.ID, mock.AnythingOfType("int64")).Return(true, nil)
+			},
+		},
+		{
+			name: "Recovery code typed without the dash and in upper case",
+			code: "ABCDEFGHIJKLMNOP",
+			setupMock: func(mfaRepo *MockMFARepository) {
+				mfaRepo.On("GetByUserID", mock.Anything, user.ID).Return(enabled, nil)
+				mfaRepo.On("ConsumeRecoveryCode", mock.Anything, user.ID, mock.Anything).Return(true, nil)
+			},
+		},
+		{
+			name: "Replayed TOTP code is not accepted as a recovery code",
+			code: totpCodeAt(secret, time.Now()),
+			setupMock: func(mfaRepo *MockMFARepository) {
+				mfaRepo.On("GetByUserID", mock.Anything, user.ID).Return(enabled, nil)
+				mfaRepo.On("UseStep", mock.Anything, user.ID, mock.AnythingOfType("int64")).Return(false, nil)
+				mfaRepo.On("ConsumeRecoveryCode", mock.Anything, user.ID, mock.Anything).Return(false, nil)
+			},
+			expectedError: service.ErrInvalidMFACode,
+		},
+		{
+			name: "Wrong code",
+			code: wrongTOTPCode(secret),
+			setupMock: func(mfaRepo *MockMFARepository) {
+				mfaRepo.On("GetByUserID", mock.Anything, user.ID).Return(enabled, nil)
+				mfaRepo.On("ConsumeRecoveryCode", mock.Anything, user.ID, mock.Anything).Return(false, nil)
+			},
+			expectedError: service.ErrInvalidMFACode,
+		},
+		{
+			name: "Not enabled",
+			code: "123456",
+			setupMock: func(mfaRepo *MockMFARepository) {
+				mfaRepo.On("GetByUserID", mock.Anything, user.ID).Return(nil, sql.ErrNoRows)
+			},
+			expectedError: service.ErrMFANotEnabled,
+		},
+		{
+			name: "Repository error",
+			code: totpCodeAt(secret, time.Now()),
+			setupMock: func(mfaRepo *MockMFARepository) {
+				mfaRepo.On("GetByUserID", mock.Anything, user.ID).Return(enabled, nil)
+				mfaRepo.On("UseStep", mock.Anything, user.ID, mock.AnythingOfType("int64")).Return(false, errors.New("database error"))
+			},
+			expectedError: errors.New("database error"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mfaRepo := new(MockMFARepository)
+			tc.setupMock(mfaRepo)
+
+			err := newMFAService(new(MockUserRepository), mfaRepo).VerifyCode(context.Background(), user.ID, tc.code)
+
+			if tc.expectedError != nil {
+				assert.EqualError(t, err, tc.expectedError.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+			mfaRepo.AssertExpectations(t)
+		})
+	}
+
+	t.Run("Recovery codes are matched after normalising", func(t *testing.T) {
+		var hashes [][]byte
+		for _, code := range []string{"abcdefgh-ijklmnop", "ABCDEFGHIJKLMNOP", "abcd efgh ijkl mnop"} {
+			mfaRepo := new(MockMFARepository)
+			mfaRepo.On("GetByUserID", mock.Anything, user.ID).Return(enabled, nil)
+			mfaRepo.On("ConsumeRecoveryCode", mock.Anything, user.ID, mock.Anything).Run(func(args mock.Arguments) {
+				hashes = append(hashes, args.Get(2).([]byte))
+			}).Return(true, nil)
+
+			require.NoError(t, newMFAService(new(MockUserRepository), mfaRepo).VerifyCode(context.Background(), user.ID, code))
+		}
+
+		require.Len(t, hashes, 3)
+		assert.Equal(t, hashes[0], hashes[1])
+		assert.Equal(t, hashes[0], hashes[2])
+	})
+}
+
+func TestRegenerateRecoveryCodes(t *testing.T) {
+	user := newUserWithPassword(t, "password123")
+	secret, enabled := enrollMFA(t, user)
+
+	t.Run("Success", func(t *testing.T) {
+		mfaRepo := new(MockMFARepository)
+		mfaRepo.On("GetByUserID", mock.Anything, user.ID).Return(enabled, nil)
+		mfaRepo.On("UseStep", mock.Anything, user.ID, mock.AnythingOfType("int64")).Return(true, nil)
+		mfaRepo.On("ReplaceRecoveryCodes", mock.Anything, user.ID, mock.Anything).Return(nil)
+
+		recoveryCodes, err := newMFAService(new(MockUserRepository), mfaRepo).
+			RegenerateRecoveryCodes(context.Background(), user.ID, totpCodeAt(secret, time.Now()))
+
+		require.NoError(t, err)
+		assert.Len(t, recoveryCodes, 10)
+		mfaRepo.AssertExpectations(t)
+	})
+
+	t.Run("Wrong code", func(t *testing.T) {
+		mfaRepo := new(MockMFARepository)
+		mfaRepo.On("GetByUserID", mock.Anything, user.ID).Return(enabled, nil)
+		mfaRepo.On("ConsumeRecoveryCode", mock.Anything, user.ID, mock.Anything).Return(false, nil)
+
+		recoveryCodes, err := newMFAService(new(MockUserRepository), mfaRepo).
+			RegenerateRecoveryCodes(context.Background(), user.ID, wrongTOTPCode(secret))
+
+		assert.Equal(t, service.ErrInvalidMFACode, err)
+		assert.Nil(t, recoveryCodes)
+		mfaRepo.AssertNotCalled(t, "ReplaceRecoveryCodes", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestDisableMFA(t *testing.T) {
+	user := newUserWithPassword(t, "password123")
+	secret, enabled := enrollMFA(t, user)
+
+	t.Run("Success", func(t *testing.T) {
+		mfaRepo := new(MockMFARepository)
+		mfaRepo.On("GetByUserID", mock.Anything, user.ID).Return(enabled, nil)
+		mfaRepo.On("UseStep", mock.Anything, user.ID, mock.AnythingOfType("int64")).Return(true, nil)
+		mfaRepo.On("Delete", mock.Anything, user.ID).Return(nil)
+
+		err := newMFAService(new(MockUserRepository), mfaRepo).
+			Disable(context.Background(), user.ID, totpCodeAt(secret, time.Now()))
+
+		require.NoError(t, err)
+		mfaRepo.AssertExpectations(t)
+	})
+
+	t.Run("Wrong code", func(t *testing.T) {
+		mfaRepo := new(MockMFARepository)
+		mfaRepo.On("GetByUserID", mock.Anything, user.ID).Return(enabled, nil)
+		mfaRepo.On("ConsumeRecoveryCode", mock.Anything, user.ID, mock.Anything).Return(false, nil)
+
+		err := newMFAService(new(MockUserRepository), mfaRepo).
+			Disable(context.Background(), user.ID, wrongTOTPCode(secret))
+
+		assert.Equal(t, service.ErrInvalidMFACode, err)
+		mfaRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Not enabled", func(t *testing.T) {
+		mfaRepo := new(MockMFARepository)
+		mfaRepo.On("GetByUserID", mock.Anything, user.ID).Return(nil, sql.ErrNoRows)
+
+		err := newMFAService(new(MockUserRepository), mfaRepo).Disable(context.Background(), user.ID, "123456")
+
+		assert.Equal(t, service.ErrMFANotEnabled, err)
+	})
+}
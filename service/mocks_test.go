@@ -2,10 +2,14 @@ package service_test
 
 import (
 	"context"
+	"database/sql"
+	"io"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/mock"
 	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
 )
 
 // MockUserRepository is a mock implementation of UserRepository
@@ -34,11 +38,54 @@ func (m *MockUserRepository) GetByEmail(ctx context.Context, email string) (*mod
 	return args.Get(0).(*model.User), args.Error(1)
 }
 
+func (m *MockUserRepository) List(ctx context.Context, filter *model.UserFilter, after *model.UserCursor, limit int) ([]model.User, error) {
+	args := m.Called(ctx, filter, after, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.User), args.Error(1)
+}
+
 func (m *MockUserRepository) Update(ctx context.Context, user *model.User) error {
 	args := m.Called(ctx, user)
 	return args.Error(0)
 }
 
+func (m *MockUserRepository) MarkEmailVerified(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	args := m.Called(ctx, id, passwordHash)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) Disable(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) Enable(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) UpdatePasswordHash(ctx context.Context, id uuid.UUID, currentHash, passwordHash string) error {
+	args := m.Called(ctx, id, currentHash, passwordHash)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) UpdateProfile(ctx context.Context, id uuid.UUID, profile *model.UserProfile) error {
+	args := m.Called(ctx, id, profile)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) UpdateEmail(ctx context.Context, id uuid.UUID, email string) error {
+	args := m.Called(ctx, id, email)
+	return args.Error(0)
+}
+
 func (m *MockUserRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	args := m.Called(ctx, id)
 	return args.Error(0)
@@ -62,6 +109,14 @@ func (m *MockTodoRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.
 	return args.Get(0).(*model.Todo), args.Error(1)
 }
 
+func (m *MockTodoRepository) GetByIDForUpdate(ctx context.Context, id uuid.UUID) (*model.Todo, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Todo), args.Error(1)
+}
+
 func (m *MockTodoRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]model.Todo, error) {
 	args := m.Called(ctx, userID)
 	if args.Get(0) == nil {
@@ -70,6 +125,35 @@ func (m *MockTodoRepository) GetByUserID(ctx context.Context, userID uuid.UUID)
 	return args.Get(0).([]model.Todo), args.Error(1)
 }
 
+func (m *MockTodoRepository) CountByUserIDForUpdate(ctx context.Context, userID uuid.UUID) (int, error) {
+	args := m.Called(ctx, userID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockTodoRepository) GetStatsByUserID(ctx context.Context, userID uuid.UUID) (*model.TodoStats, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.TodoStats), args.Error(1)
+}
+
+func (m *MockTodoRepository) GetSharedWithUserID(ctx context.Context, userID uuid.UUID) ([]model.Todo, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Todo), args.Error(1)
+}
+
+func (m *MockTodoRepository) GetByAssigneeID(ctx context.Context, assigneeID uuid.UUID) ([]model.Todo, error) {
+	args := m.Called(ctx, assigneeID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Todo), args.Error(1)
+}
+
 func (m *MockTodoRepository) Update(ctx context.Context, todo *model.Todo) error {
 	args := m.Called(ctx, todo)
 	return args.Error(0)
@@ -84,3 +168,880 @@ func (m *MockTodoRepository) MarkAsCompleted(ctx context.Context, id uuid.UUID)
 	args := m.Called(ctx, id)
 	return args.Error(0)
 }
+
+func (m *MockTodoRepository) Assign(ctx context.Context, assignment *model.TodoAssignment) error {
+	args := m.Called(ctx, assignment)
+	return args.Error(0)
+}
+
+func (m *MockTodoRepository) GetAssignments(ctx context.Context, todoID uuid.UUID) ([]model.TodoAssignment, error) {
+	args := m.Called(ctx, todoID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.TodoAssignment), args.Error(1)
+}
+
+// MockTodoShareRepository is a mock implementation of TodoShareRepository
+type MockTodoShareRepository struct {
+	mock.Mock
+}
+
+func (m *MockTodoShareRepository) Upsert(ctx context.Context, share *model.TodoShare) error {
+	args := m.Called(ctx, share)
+	return args.Error(0)
+}
+
+func (m *MockTodoShareRepository) Get(ctx context.Context, todoID, userID uuid.UUID) (*model.TodoShare, error) {
+	args := m.Called(ctx, todoID, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.TodoShare), args.Error(1)
+}
+
+func (m *MockTodoShareRepository) GetByTodoID(ctx context.Context, todoID uuid.UUID) ([]model.TodoShare, error) {
+	args := m.Called(ctx, todoID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.TodoShare), args.Error(1)
+}
+
+func (m *MockTodoShareRepository) Delete(ctx context.Context, todoID, userID uuid.UUID) error {
+	args := m.Called(ctx, todoID, userID)
+	return args.Error(0)
+}
+
+// MockCommentRepository is a mock implementation of CommentRepository
+type MockCommentRepository struct {
+	mock.Mock
+}
+
+func (m *MockCommentRepository) Create(ctx context.Context, comment *model.Comment) error {
+	args := m.Called(ctx, comment)
+	return args.Error(0)
+}
+
+func (m *MockCommentRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Comment, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Comment), args.Error(1)
+}
+
+func (m *MockCommentRepository) GetByTodoID(ctx context.Context, todoID uuid.UUID, after *model.CommentCursor, limit int) ([]model.Comment, error) {
+	args := m.Called(ctx, todoID, after, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Comment), args.Error(1)
+}
+
+func (m *MockCommentRepository) Update(ctx context.Context, comment *model.Comment) error {
+	args := m.Called(ctx, comment)
+	return args.Error(0)
+}
+
+func (m *MockCommentRepository) SoftDelete(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+// MockAttachmentRepository is a mock implementation of AttachmentRepository
+type MockAttachmentRepository struct {
+	mock.Mock
+}
+
+func (m *MockAttachmentRepository) Create(ctx context.Context, attachment *model.Attachment) error {
+	args := m.Called(ctx, attachment)
+	return args.Error(0)
+}
+
+func (m *MockAttachmentRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Attachment, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Attachment), args.Error(1)
+}
+
+func (m *MockAttachmentRepository) GetByTodoID(ctx context.Context, todoID uuid.UUID) ([]model.Attachment, error) {
+	args := m.Called(ctx, todoID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Attachment), args.Error(1)
+}
+
+func (m *MockAttachmentRepository) GetTotalSizeByUploaderID(ctx context.Context, uploaderID uuid.UUID) (int64, error) {
+	args := m.Called(ctx, uploaderID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockAttachmentRepository) GetTotalSizeByUploaderIDForUpdate(ctx context.Context, uploaderID uuid.UUID) (int64, error) {
+	args := m.Called(ctx, uploaderID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockAttachmentRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockAttachmentRepository) GetPendingBlobDeletions(ctx context.Context, limit int) ([]string, error) {
+	args := m.Called(ctx, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockAttachmentRepository) RemovePendingBlobDeletion(ctx context.Context, storageKey string) error {
+	args := m.Called(ctx, storageKey)
+	return args.Error(0)
+}
+
+// MockBlobStore is a mock implementation of BlobStore
+// Put reads the whole body so that tests can assert on the stored contents
+type MockBlobStore struct {
+	mock.Mock
+	stored []byte
+}
+
+func (m *MockBlobStore) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	m.stored = data
+	args := m.Called(ctx, key, size, contentType)
+	return args.Error(0)
+}
+
+func (m *MockBlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	args := m.Called(ctx, key)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(io.ReadCloser), args.Error(1)
+}
+
+func (m *MockBlobStore) Delete(ctx context.Context, key string) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+// MockActivityRepository is a mock implementation of ActivityRepository
+type MockActivityRepository struct {
+	mock.Mock
+}
+
+// newMockActivityRepository creates a MockActivityRepository that accepts any recorded event
+func newMockActivityRepository() *MockActivityRepository {
+	m := new(MockActivityRepository)
+	m.On("Create", mock.Anything, mock.Anything).Return(nil).Maybe()
+	return m
+}
+
+func (m *MockActivityRepository) Create(ctx context.Context, event *model.ActivityEvent) error {
+	args := m.Called(ctx, event)
+	return args.Error(0)
+}
+
+func (m *MockActivityRepository) GetByTodoID(ctx context.Context, todoID uuid.UUID, before *model.ActivityCursor, limit int) ([]model.ActivityEvent, error) {
+	args := m.Called(ctx, todoID, before, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.ActivityEvent), args.Error(1)
+}
+
+func (m *MockActivityRepository) GetFeedByUserID(ctx context.Context, userID uuid.UUID, before *model.ActivityCursor, limit int) ([]model.ActivityEvent, error) {
+	args := m.Called(ctx, userID, before, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.ActivityEvent), args.Error(1)
+}
+
+// MockTransactor is a Transactor that runs the function without a database transaction
+type MockTransactor struct{}
+
+func (m *MockTransactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// MockWebhookRepository is a mock implementation of WebhookRepository
+type MockWebhookRepository struct {
+	mock.Mock
+}
+
+func (m *MockWebhookRepository) Create(ctx context.Context, webhook *model.Webhook) error {
+	args := m.Called(ctx, webhook)
+	return args.Error(0)
+}
+
+func (m *MockWebhookRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Webhook, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Webhook), args.Error(1)
+}
+
+func (m *MockWebhookRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]model.Webhook, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Webhook), args.Error(1)
+}
+
+func (m *MockWebhookRepository) Update(ctx context.Context, webhook *model.Webhook) error {
+	args := m.Called(ctx, webhook)
+	return args.Error(0)
+}
+
+func (m *MockWebhookRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockWebhookRepository) EnqueueDeliveries(ctx context.Context, audience []string, eventID uuid.UUID, eventType string, payload []byte) error {
+	args := m.Called(ctx, audience, eventID, eventType, payload)
+	return args.Error(0)
+}
+
+func (m *MockWebhookRepository) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]model.WebhookDelivery, error) {
+	args := m.Called(ctx, limit, lease)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockWebhookRepository) UpdateDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	args := m.Called(ctx, delivery)
+	return args.Error(0)
+}
+
+func (m *MockWebhookRepository) GetDeliveryByID(ctx context.Context, id uuid.UUID) (*model.WebhookDelivery, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockWebhookRepository) GetDeliveriesByWebhookID(ctx context.Context, webhookID uuid.UUID, limit int) ([]model.WebhookDelivery, error) {
+	args := m.Called(ctx, webhookID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockWebhookRepository) ResetDelivery(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+// MockOutboxRepository is a mock implementation of OutboxRepository
+type MockOutboxRepository struct {
+	mock.Mock
+}
+
+func (m *MockOutboxRepository) Create(ctx context.Context, event *model.OutboxEvent) error {
+	args := m.Called(ctx, event)
+	return args.Error(0)
+}
+
+func (m *MockOutboxRepository) ClaimPending(ctx context.Context, limit int) ([]model.OutboxEvent, error) {
+	args := m.Called(ctx, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.OutboxEvent), args.Error(1)
+}
+
+func (m *MockOutboxRepository) MarkPublished(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockOutboxRepository) MarkFailed(ctx context.Context, id uuid.UUID, retryAfter time.Duration, lastError string) error {
+	args := m.Called(ctx, id, retryAfter, lastError)
+	return args.Error(0)
+}
+
+func (m *MockOutboxRepository) DeletePublishedOlderThan(ctx context.Context, age time.Duration) (int64, error) {
+	args := m.Called(ctx, age)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockOutboxRepository) GetPrunedSequence(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockOutboxRepository) GetBySequence(ctx context.Context, sequence int64) (*model.OutboxEvent, error) {
+	args := m.Called(ctx, sequence)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.OutboxEvent), args.Error(1)
+}
+
+func (m *MockOutboxRepository) GetAfter(ctx context.Context, afterSequence int64, limit int) ([]model.OutboxEvent, error) {
+	args := m.Called(ctx, afterSequence, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.OutboxEvent), args.Error(1)
+}
+
+func (m *MockOutboxRepository) GetByAudienceAfter(ctx context.Context, userID uuid.UUID, afterSequence int64, limit int) ([]model.OutboxEvent, error) {
+	args := m.Called(ctx, userID, afterSequence, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.OutboxEvent), args.Error(1)
+}
+
+// MockSyncRepository is a mock implementation of SyncRepository
+type MockSyncRepository struct {
+	mock.Mock
+}
+
+func (m *MockSyncRepository) GetChangedTodos(ctx context.Context, userID uuid.UUID, after int64, limit int) ([]model.SyncTodo, error) {
+	args := m.Called(ctx, userID, after, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.SyncTodo), args.Error(1)
+}
+
+func (m *MockSyncRepository) GetTombstones(ctx context.Context, userID uuid.UUID, after int64, limit int) ([]model.TodoTombstone, error) {
+	args := m.Called(ctx, userID, after, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.TodoTombstone), args.Error(1)
+}
+
+// MockIdempotencyRepository is a mock implementation of IdempotencyRepository
+type MockIdempotencyRepository struct {
+	mock.Mock
+}
+
+func (m *MockIdempotencyRepository) Claim(ctx context.Context, record *model.IdempotencyRecord, ttl time.Duration, lockTimeout time.Duration) (bool, error) {
+	args := m.Called(ctx, record, ttl, lockTimeout)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockIdempotencyRepository) Get(ctx context.Context, userID uuid.UUID, key string) (*model.IdempotencyRecord, error) {
+	args := m.Called(ctx, userID, key)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.IdempotencyRecord), args.Error(1)
+}
+
+func (m *MockIdempotencyRepository) SaveResponse(ctx context.Context, record *model.IdempotencyRecord) error {
+	args := m.Called(ctx, record)
+	return args.Error(0)
+}
+
+func (m *MockIdempotencyRepository) Delete(ctx context.Context, userID uuid.UUID, key string) error {
+	args := m.Called(ctx, userID, key)
+	return args.Error(0)
+}
+
+func (m *MockIdempotencyRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// MockRateLimitStore is a mock implementation of RateLimitStore
+type MockRateLimitStore struct {
+	mock.Mock
+}
+
+func (m *MockRateLimitStore) Take(ctx context.Context, key string, limit model.RateLimit) (*model.RateLimitResult, error) {
+	args := m.Called(ctx, key, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.RateLimitResult), args.Error(1)
+}
+
+func (m *MockRateLimitStore) DeleteFull(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// MockLoginAttemptRepository is a mock implementation of LoginAttemptRepository
+type MockLoginAttemptRepository struct {
+	mock.Mock
+}
+
+func (m *MockLoginAttemptRepository) GetLockout(ctx context.Context, email string) (time.Duration, error) {
+	args := m.Called(ctx, email)
+	return args.Get(0).(time.Duration), args.Error(1)
+}
+
+func (m *MockLoginAttemptRepository) RecordFailure(ctx context.Context, email string, window time.Duration) (int, error) {
+	args := m.Called(ctx, email, window)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockLoginAttemptRepository) Lock(ctx context.Context, email string, duration time.Duration) error {
+	args := m.Called(ctx, email, duration)
+	return args.Error(0)
+}
+
+func (m *MockLoginAttemptRepository) Reset(ctx context.Context, email string) error {
+	args := m.Called(ctx, email)
+	return args.Error(0)
+}
+
+func (m *MockLoginAttemptRepository) DeleteStale(ctx context.Context, window time.Duration) (int64, error) {
+	args := m.Called(ctx, window)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// MockUserTokenRepository is a mock implementation of UserTokenRepository
+type MockUserTokenRepository struct {
+	mock.Mock
+}
+
+func (m *MockUserTokenRepository) Create(ctx context.Context, token *model.UserToken, ttl time.Duration) error {
+	args := m.Called(ctx, token, ttl)
+	return args.Error(0)
+}
+
+func (m *MockUserTokenRepository) HasRecent(ctx context.Context, userID uuid.UUID, purpose model.UserTokenPurpose, within time.Duration) (bool, error) {
+	args := m.Called(ctx, userID, purpose, within)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockUserTokenRepository) Consume(ctx context.Context, tokenHash []byte, purpose model.UserTokenPurpose) (*model.UserToken, error) {
+	args := m.Called(ctx, tokenHash, purpose)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.UserToken), args.Error(1)
+}
+
+func (m *MockUserTokenRepository) DeleteUnused(ctx context.Context, userID uuid.UUID, purpose model.UserTokenPurpose) error {
+	args := m.Called(ctx, userID, purpose)
+	return args.Error(0)
+}
+
+func (m *MockUserTokenRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// MockMailer is a mock implementation of Mailer
+type MockMailer struct {
+	mock.Mock
+}
+
+func (m *MockMailer) Send(ctx context.Context, email *service.Email) error {
+	args := m.Called(ctx, email)
+	return args.Error(0)
+}
+
+// MockMFARepository is a mock implementation of MFARepository
+type MockMFARepository struct {
+	mock.Mock
+}
+
+// newMockMFARepository returns a MockMFARepository for users without MFA unless told otherwise
+func newMockMFARepository() *MockMFARepository {
+	m := new(MockMFARepository)
+	m.On("GetByUserID", mock.Anything, mock.Anything).Return(nil, sql.ErrNoRows).Maybe()
+	return m
+}
+
+func (m *MockMFARepository) Save(ctx context.Context, mfa *model.UserMFA) error {
+	args := m.Called(ctx, mfa)
+	return args.Error(0)
+}
+
+func (m *MockMFARepository) GetByUserID(ctx context.Context, userID uuid.UUID) (*model.UserMFA, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.UserMFA), args.Error(1)
+}
+
+func (m *MockMFARepository) Enable(ctx context.Context, userID uuid.UUID) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockMFARepository) UseStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	args := m.Called(ctx, userID, step)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockMFARepository) Delete(ctx context.Context, userID uuid.UUID) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockMFARepository) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes [][]byte) error {
+	args := m.Called(ctx, userID, codeHashes)
+	return args.Error(0)
+}
+
+func (m *MockMFARepository) ConsumeRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash []byte) (bool, error) {
+	args := m.Called(ctx, userID, codeHash)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockMFARepository) CountRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error) {
+	args := m.Called(ctx, userID)
+	return args.Int(0), args.Error(1)
+}
+
+// MockWebAuthnRepository is a mock implementation of WebAuthnRepository
+type MockWebAuthnRepository struct {
+	mock.Mock
+}
+
+func (m *MockWebAuthnRepository) CreateChallenge(ctx context.Context, challenge *model.WebAuthnChallenge, ttl time.Duration) error {
+	args := m.Called(ctx, challenge, ttl)
+	return args.Error(0)
+}
+
+func (m *MockWebAuthnRepository) ConsumeChallenge(ctx context.Context, challengeHash []byte, ceremony model.WebAuthnCeremony) (*model.WebAuthnChallenge, error) {
+	args := m.Called(ctx, challengeHash, ceremony)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.WebAuthnChallenge), args.Error(1)
+}
+
+func (m *MockWebAuthnRepository) DeleteExpiredChallenges(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockWebAuthnRepository) CreateCredential(ctx context.Context, credential *model.WebAuthnCredential) error {
+	args := m.Called(ctx, credential)
+	return args.Error(0)
+}
+
+func (m *MockWebAuthnRepository) GetCredentialByCredentialID(ctx context.Context, credentialID []byte) (*model.WebAuthnCredential, error) {
+	args := m.Called(ctx, credentialID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.WebAuthnCredential), args.Error(1)
+}
+
+func (m *MockWebAuthnRepository) GetCredentialsByUserID(ctx context.Context, userID uuid.UUID) ([]model.WebAuthnCredential, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.WebAuthnCredential), args.Error(1)
+}
+
+func (m *MockWebAuthnRepository) UpdateCredentialUsage(ctx context.Context, id uuid.UUID, signCount int64) error {
+	args := m.Called(ctx, id, signCount)
+	return args.Error(0)
+}
+
+func (m *MockWebAuthnRepository) DeleteCredential(ctx context.Context, userID, id uuid.UUID) (bool, error) {
+	args := m.Called(ctx, userID, id)
+	return args.Bool(0), args.Error(1)
+}
+
+// MockIdentityRepository is a mock implementation of IdentityRepository
+type MockIdentityRepository struct {
+	mock.Mock
+}
+
+func (m *MockIdentityRepository) CreateState(ctx context.Context, state *model.OIDCState, ttl time.Duration) error {
+	args := m.Called(ctx, state, ttl)
+	return args.Error(0)
+}
+
+func (m *MockIdentityRepository) ConsumeState(ctx context.Context, stateHash []byte, provider string) (*model.OIDCState, error) {
+	args := m.Called(ctx, stateHash, provider)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.OIDCState), args.Error(1)
+}
+
+func (m *MockIdentityRepository) DeleteExpiredStates(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockIdentityRepository) CreateIdentity(ctx context.Context, identity *model.UserIdentity) error {
+	args := m.Called(ctx, identity)
+	return args.Error(0)
+}
+
+func (m *MockIdentityRepository) GetIdentity(ctx context.Context, provider, subject string) (*model.UserIdentity, error) {
+	args := m.Called(ctx, provider, subject)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.UserIdentity), args.Error(1)
+}
+
+func (m *MockIdentityRepository) GetIdentitiesByUserID(ctx context.Context, userID uuid.UUID) ([]model.UserIdentity, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.UserIdentity), args.Error(1)
+}
+
+func (m *MockIdentityRepository) UpdateIdentityLogin(ctx context.Context, id uuid.UUID, email string) error {
+	args := m.Called(ctx, id, email)
+	return args.Error(0)
+}
+
+func (m *MockIdentityRepository) DeleteIdentity(ctx context.Context, userID, id uuid.UUID) (bool, error) {
+	args := m.Called(ctx, userID, id)
+	return args.Bool(0), args.Error(1)
+}
+
+// MockOAuthRepository is a mock implementation of OAuthRepository
+type MockOAuthRepository struct {
+	mock.Mock
+}
+
+func (m *MockOAuthRepository) CreateClient(ctx context.Context, client *model.OAuthClient) error {
+	args := m.Called(ctx, client)
+	return args.Error(0)
+}
+
+func (m *MockOAuthRepository) GetClient(ctx context.Context, id uuid.UUID) (*model.OAuthClient, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.OAuthClient), args.Error(1)
+}
+
+func (m *MockOAuthRepository) GetClientsByUserID(ctx context.Context, userID uuid.UUID) ([]model.OAuthClient, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.OAuthClient), args.Error(1)
+}
+
+func (m *MockOAuthRepository) DeleteClient(ctx context.Context, userID, id uuid.UUID) (bool, error) {
+	args := m.Called(ctx, userID, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockOAuthRepository) UpsertConsent(ctx context.Context, consent *model.OAuthConsent) error {
+	args := m.Called(ctx, consent)
+	return args.Error(0)
+}
+
+func (m *MockOAuthRepository) GetConsent(ctx context.Context, userID, clientID uuid.UUID) (*model.OAuthConsent, error) {
+	args := m.Called(ctx, userID, clientID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.OAuthConsent), args.Error(1)
+}
+
+func (m *MockOAuthRepository) GetConsentsByUserID(ctx context.Context, userID uuid.UUID) ([]model.OAuthConsent, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.OAuthConsent), args.Error(1)
+}
+
+func (m *MockOAuthRepository) DeleteConsent(ctx context.Context, userID, clientID uuid.UUID) (bool, error) {
+	args := m.Called(ctx, userID, clientID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockOAuthRepository) CreateCode(ctx context.Context, code *model.OAuthAuthorizationCode, ttl time.Duration) error {
+	args := m.Called(ctx, code, ttl)
+	return args.Error(0)
+}
+
+func (m *MockOAuthRepository) ConsumeCode(ctx context.Context, codeHash []byte, clientID uuid.UUID) (*model.OAuthAuthorizationCode, error) {
+	args := m.Called(ctx, codeHash, clientID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.OAuthAuthorizationCode), args.Error(1)
+}
+
+func (m *MockOAuthRepository) GetCode(ctx context.Context, codeHash []byte) (*model.OAuthAuthorizationCode, error) {
+	args := m.Called(ctx, codeHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.OAuthAuthorizationCode), args.Error(1)
+}
+
+func (m *MockOAuthRepository) CreateToken(ctx context.Context, token *model.OAuthToken, ttl time.Duration) error {
+	args := m.Called(ctx, token, ttl)
+	return args.Error(0)
+}
+
+func (m *MockOAuthRepository) GetToken(ctx context.Context, tokenHash []byte) (*model.OAuthToken, error) {
+	args := m.Called(ctx, tokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.OAuthToken), args.Error(1)
+}
+
+func (m *MockOAuthRepository) ConsumeToken(ctx context.Context, tokenHash []byte, kind model.OAuthTokenKind, clientID uuid.UUID) (*model.OAuthToken, error) {
+	args := m.Called(ctx, tokenHash, kind, clientID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.OAuthToken), args.Error(1)
+}
+
+func (m *MockOAuthRepository) DeleteToken(ctx context.Context, tokenHash []byte) error {
+	args := m.Called(ctx, tokenHash)
+	return args.Error(0)
+}
+
+func (m *MockOAuthRepository) DeleteGrant(ctx context.Context, grantID uuid.UUID) error {
+	args := m.Called(ctx, grantID)
+	return args.Error(0)
+}
+
+func (m *MockOAuthRepository) DeleteUserGrants(ctx context.Context, userID, clientID uuid.UUID) error {
+	args := m.Called(ctx, userID, clientID)
+	return args.Error(0)
+}
+
+func (m *MockOAuthRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// MockPersonalAccessTokenRepository is a mock implementation of PersonalAccessTokenRepository
+type MockPersonalAccessTokenRepository struct {
+	mock.Mock
+}
+
+func (m *MockPersonalAccessTokenRepository) Create(ctx context.Context, token *model.PersonalAccessToken) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+func (m *MockPersonalAccessTokenRepository) GetByHash(ctx context.Context, tokenHash []byte) (*model.PersonalAccessToken, error) {
+	args := m.Called(ctx, tokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.PersonalAccessToken), args.Error(1)
+}
+
+func (m *MockPersonalAccessTokenRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]model.PersonalAccessToken, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.PersonalAccessToken), args.Error(1)
+}
+
+func (m *MockPersonalAccessTokenRepository) CountByUserIDForUpdate(ctx context.Context, userID uuid.UUID) (int, error) {
+	args := m.Called(ctx, userID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockPersonalAccessTokenRepository) Delete(ctx context.Context, userID, id uuid.UUID) (bool, error) {
+	args := m.Called(ctx, userID, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockPersonalAccessTokenRepository) UpdateLastUsed(ctx context.Context, id uuid.UUID, interval time.Duration) error {
+	args := m.Called(ctx, id, interval)
+	return args.Error(0)
+}
+
+func (m *MockPersonalAccessTokenRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// MockSessionRepository is a mock implementation of SessionRepository
+type MockSessionRepository struct {
+	mock.Mock
+}
+
+// newMockSessionRepository creates a MockSessionRepository that accepts any new session
+func newMockSessionRepository() *MockSessionRepository {
+	m := new(MockSessionRepository)
+	m.On("Create", mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	return m
+}
+
+func (m *MockSessionRepository) Create(ctx context.Context, session *model.Session, ttl time.Duration) error {
+	args := m.Called(ctx, session, ttl)
+	return args.Error(0)
+}
+
+func (m *MockSessionRepository) GetActiveByUserID(ctx context.Context, userID uuid.UUID) ([]model.Session, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Session), args.Error(1)
+}
+
+func (m *MockSessionRepository) IsActive(ctx context.Context, userID, id uuid.UUID) (bool, error) {
+	args := m.Called(ctx, userID, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockSessionRepository) Rotate(ctx context.Context, session *model.Session, previousHash []byte, ttl time.Duration) (bool, error) {
+	args := m.Called(ctx, session, previousHash, ttl)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockSessionRepository) Delete(ctx context.Context, userID, id uuid.UUID) (bool, error) {
+	args := m.Called(ctx, userID, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockSessionRepository) DeleteByUserID(ctx context.Context, userID uuid.UUID) (int64, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockSessionRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// MockBreachedPasswordStore is a mock implementation of BreachedPasswordStore
+type MockBreachedPasswordStore struct {
+	mock.Mock
+}
+
+func (m *MockBreachedPasswordStore) GetRange(ctx context.Context, prefix string) (map[string]int, error) {
+	args := m.Called(ctx, prefix)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]int), args.Error(1)
+}
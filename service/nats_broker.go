@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/yukimaterrace/todoms/config"
+)
+
+// JetStreamPublisher is the part of a JetStream client NATSBroker uses
+type JetStreamPublisher interface {
+	PublishMsg(ctx context.Context, msg *nats.Msg, opts ...jetstream.PublishOpt) (*jetstream.PubAck, error)
+}
+
+// NATSBroker implements MessageBroker on NATS JetStream
+// The ID of a message is sent as Nats-Msg-Id, so the stream discards a message published again
+// within its duplicate window
+// NATS has no partition key: a stream keeps the messages in the order they were published, which
+// the outbox relay already follows for the events of one aggregate
+type NATSBroker struct {
+	js      JetStreamPublisher
+	timeout time.Duration
+}
+
+// NewNATSBroker creates a new NATSBroker instance
+// timeout bounds the wait for the stream to acknowledge a message
+func NewNATSBroker(js JetStreamPublisher, timeout time.Duration) MessageBroker {
+	return &NATSBroker{
+		js:      js,
+		timeout: timeout,
+	}
+}
+
+// ConnectJetStream connects to the configured NATS server and creates or updates the stream
+// that stores the domain events
+func ConnectJetStream(ctx context.Context, brokerConfig *config.BrokerConfig) (jetstream.JetStream, error) {
+	nc, err := nats.Connect(brokerConfig.URL,
+		nats.Name("todoms"),
+		nats.Timeout(brokerConfig.Timeout),
+		nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, err
+	}
+
+	js, err := jetstream.New(nc)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, brokerConfig.Timeout)
+	defer cancel()
+	_, err = js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:       brokerConfig.Stream,
+		Subjects:   []string{brokerConfig.TopicPrefix + ".>"},
+		Storage:    jetstream.FileStorage,
+		Duplicates: brokerConfig.DuplicateWindow,
+	})
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+
+	return js, nil
+}
+
+// Publish sends the message to the stream and waits for the stream to store it
+func (b *NATSBroker) Publish(ctx context.Context, msg BrokerMessage) error {
+	natsMsg := nats.NewMsg(msg.Topic)
+	for key, value := range msg.Headers {
+		natsMsg.Header.Set(key, value)
+	}
+	natsMsg.Header.Set(jetstream.MsgIDHeader, msg.ID)
+	natsMsg.Data = msg.Value
+
+	ctx, cancel := context.WithTimeout(ctx, b.timeout)
+	defer cancel()
+	_, err := b.js.PublishMsg(ctx, natsMsg)
+	return err
+}
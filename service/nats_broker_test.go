@@ -0,0 +1,68 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/service"
+)
+
+// recordingJetStream is a JetStreamPublisher that records the messages it receives and fails on demand
+type recordingJetStream struct {
+	messages  []*nats.Msg
+	deadlines []bool
+	err       error
+}
+
+func (js *recordingJetStream) PublishMsg(ctx context.Context, msg *nats.Msg, opts ...jetstream.PublishOpt) (*jetstream.PubAck, error) {
+	_, hasDeadline := ctx.Deadline()
+	js.deadlines = append(js.deadlines, hasDeadline)
+	if js.err != nil {
+		return nil, js.err
+	}
+	js.messages = append(js.messages, msg)
+	return &jetstream.PubAck{Stream: "TODOMS", Sequence: uint64(len(js.messages))}, nil
+}
+
+func TestNATSBroker(t *testing.T) {
+	ctx := context.Background()
+	msg := service.BrokerMessage{
+		Topic: "todoms.todo.created",
+		Key:   "aggregate",
+		ID:    "event-1",
+		Headers: map[string]string{
+			service.EventIdempotencyKeyHeader: "event-1",
+			service.EventTypeHeader:           "todo.created",
+		},
+		Value: []byte(`{"id":"todo"}`),
+	}
+
+	t.Run("publishes with the message ID for deduplication", func(t *testing.T) {
+		js := &recordingJetStream{}
+		broker := service.NewNATSBroker(js, time.Second)
+
+		require.NoError(t, broker.Publish(ctx, msg))
+
+		require.Len(t, js.messages, 1)
+		published := js.messages[0]
+		assert.Equal(t, "todoms.todo.created", published.Subject)
+		assert.Equal(t, "event-1", published.Header.Get(jetstream.MsgIDHeader))
+		assert.Equal(t, "event-1", published.Header.Get(service.EventIdempotencyKeyHeader))
+		assert.Equal(t, "todo.created", published.Header.Get(service.EventTypeHeader))
+		assert.JSONEq(t, `{"id":"todo"}`, string(published.Data))
+		assert.Equal(t, []bool{true}, js.deadlines)
+	})
+
+	t.Run("fails when the stream does not acknowledge", func(t *testing.T) {
+		js := &recordingJetStream{err: errors.New("no responders")}
+		broker := service.NewNATSBroker(js, time.Second)
+
+		assert.Error(t, broker.Publish(ctx, msg))
+	})
+}
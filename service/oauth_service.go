@@ -0,0 +1,850 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+	"go.uber.org/zap"
+)
+
+// Errors of the OAuth service
+// The errors of the token, introspection and revocation endpoints correspond to the error codes of RFC 6749 section 5.2
+var (
+	// ErrOAuthClientNotFound is returned when there is no client with the ID, or the user did not register it
+	ErrOAuthClientNotFound = errors.New("oauth client not found")
+
+	// ErrOAuthConsentNotFound is returned when a user did not grant a client access
+	ErrOAuthConsentNotFound = errors.New("oauth consent not found")
+
+	// ErrInvalidRedirectURI is returned when an authorization request has a redirect URI the client did not register
+	ErrInvalidRedirectURI = errors.New("redirect URI is not registered for the client")
+
+	// ErrInvalidOAuthScope is returned when a client asks for a scope that is unknown or that it cannot be granted
+	ErrInvalidOAuthScope = errors.New("invalid oauth scope")
+
+	// ErrInvalidOAuthRequest is returned when a token request is missing a parameter the grant needs
+	ErrInvalidOAuthRequest = errors.New("invalid oauth request")
+
+	// ErrInvalidOAuthClient is returned when a client fails to authenticate
+	ErrInvalidOAuthClient = errors.New("invalid oauth client")
+
+	// ErrInvalidOAuthGrant is returned when an authorization code or refresh token is invalid, expired, used,
+	// or was issued to another client
+	ErrInvalidOAuthGrant = errors.New("invalid oauth grant")
+
+	// ErrUnauthorizedOAuthClient is returned when a client uses a grant type it cannot use
+	ErrUnauthorizedOAuthClient = errors.New("client is not authorized to use the grant type")
+
+	// ErrUnsupportedGrantType is returned for a grant type the server does not support
+	ErrUnsupportedGrantType = errors.New("unsupported grant type")
+)
+
+// OAuth grant types the token endpoint supports
+const (
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeRefreshToken      = "refresh_token"
+	GrantTypeClientCredentials = "client_credentials"
+)
+
+// Prefixes of the tokens issued to clients, which tell them apart from the JWTs users log in with
+const (
+	OAuthAccessTokenPrefix  = "todoms_at_"
+	OAuthRefreshTokenPrefix = "todoms_rt_"
+)
+
+// oauthSecretSize is the number of random bytes in client secrets, authorization codes and tokens
+const oauthSecretSize = 32
+
+// OAuthService defines the interface for todoms as an OAuth 2.0 authorization server for third-party clients
+type OAuthService interface {
+	// CreateClient registers a client for the user, returning it with its secret, which is empty for public clients
+	CreateClient(ctx context.Context, userID uuid.UUID, req model.CreateOAuthClientRequest) (*model.OAuthClient, string, error)
+
+	// ListClients retrieves the clients the user registered
+	ListClients(ctx context.Context, userID uuid.UUID) ([]model.OAuthClient, error)
+
+	// DeleteClient removes a client the user registered, revoking everything it was granted
+	DeleteClient(ctx context.Context, userID, clientID uuid.UUID) error
+
+	// GetAuthorization validates an authorization request and returns what the client asks the user to grant
+	GetAuthorization(ctx context.Context, userID uuid.UUID, req *model.OAuthAuthorizeRequest) (*model.OAuthAuthorizationResponse, error)
+
+	// Authorize records the consent of the user to an authorization request,
+	// and returns the redirect URI with an authorization code for the client
+	Authorize(ctx context.Context, userID uuid.UUID, req *model.OAuthAuthorizeRequest) (string, error)
+
+	// Token authenticates a client and exchanges a grant for tokens
+	Token(ctx context.Context, clientID, clientSecret string, req *model.OAuthTokenRequest) (*model.OAuthTokenResponse, error)
+
+	// Introspect returns whether a token issued to the authenticated client is active, and what it grants
+	Introspect(ctx context.Context, clientID, clientSecret, token string) (*model.OAuthIntrospectionResponse, error)
+
+	// Revoke revokes a token issued to the authenticated client, and the whole grant for a refresh token
+	Revoke(ctx context.Context, clientID, clientSecret, token string) error
+
+	// ValidateAccessToken validates an access token issued to a client and returns the claims of its user and scopes
+	ValidateAccessToken(ctx context.Context, token string) (*Claims, error)
+
+	// ListConsents retrieves the clients the user granted access to
+	ListConsents(ctx context.Context, userID uuid.UUID) ([]model.OAuthConsent, error)
+
+	// RevokeConsent withdraws the consent of the user to a client, revoking the tokens it was issued
+	RevokeConsent(ctx context.Context, userID, clientID uuid.UUID) error
+
+	// PurgeExpired deletes expired authorization codes and tokens and returns how many were deleted
+	PurgeExpired(ctx context.Context) (int64, error)
+}
+
+// DefaultOAuthService implements the OAuthService interface
+type DefaultOAuthService struct {
+	userRepo   repository.UserRepository
+	oauthRepo  repository.OAuthRepository
+	transactor repository.Transactor
+	config     *config.OAuthConfig
+	logger     *zap.Logger
+}
+
+// NewOAuthService creates a new DefaultOAuthService instance
+func NewOAuthService(
+	userRepo repository.UserRepository,
+	oauthRepo repository.OAuthRepository,
+	transactor repository.Transactor,
+	cfg *config.OAuthConfig,
+	logger *zap.Logger,
+) OAuthService {
+	return &DefaultOAuthService{
+		userRepo:   userRepo,
+		oauthRepo:  oauthRepo,
+		transactor: transactor,
+		config:     cfg,
+		logger:     logger,
+	}
+}
+
+// CreateClient registers a client for the user, returning it with its secret, which is empty for public clients
+// Only the hash of the secret is stored, so it cannot be shown again
+func (s *DefaultOAuthService) CreateClient(ctx context.Context, userID uuid.UUID, req model.CreateOAuthClientRequest) (*model.OAuthClient, string, error) {
+	client := &model.OAuthClient{
+		UserID:       userID,
+		Name:         req.Name,
+		RedirectURIs: req.RedirectURIs,
+		Scopes:       normalizeScopes(req.Scopes),
+	}
+
+	var secret string
+	if !req.Public {
+		var err error
+		secret, err = randomOAuthSecret()
+		if err != nil {
+			return nil, "", err
+		}
+		client.SecretHash = hashOAuthSecret(secret)
+	}
+
+	if err := s.oauthRepo.CreateClient(ctx, client); err != nil {
+		s.logger.Error("failed to create oauth client in repository",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, "", err
+	}
+
+	s.logger.Info("oauth client registered",
+		zap.String("user_id", userID.String()),
+		zap.String("client_id", client.ID.String()),
+		zap.Bool("confidential", client.Confidential()))
+	return client, secret, nil
+}
+
+// ListClients retrieves the clients the user registered
+func (s *DefaultOAuthService) ListClients(ctx context.Context, userID uuid.UUID) ([]model.OAuthClient, error) {
+	clients, err := s.oauthRepo.GetClientsByUserID(ctx, userID)
+	if err != nil {
+		s.logger.Error("failed to get oauth clients from repository",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	return clients, nil
+}
+
+// DeleteClient removes a client the user registered, revoking everything it was granted
+func (s *DefaultOAuthService) DeleteClient(ctx context.Context, userID, clientID uuid.UUID) error {
+	deleted, err := s.oauthRepo.DeleteClient(ctx, userID, clientID)
+	if err != nil {
+		s.logger.Error("failed to delete oauth client in repository",
+			zap.String("user_id", userID.String()),
+			zap.String("client_id", clientID.String()),
+			zap.Error(err))
+		return err
+	}
+	if !deleted {
+		return ErrOAuthClientNotFound
+	}
+
+	s.logger.Info("oauth client deleted",
+		zap.String("user_id", userID.String()),
+		zap.String("client_id", clientID.String()))
+	return nil
+}
+
+// GetAuthorization validates an authorization request and returns what the client asks the user to grant
+func (s *DefaultOAuthService) GetAuthorization(ctx context.Context, userID uuid.UUID, req *model.OAuthAuthorizeRequest) (*model.OAuthAuthorizationResponse, error) {
+	client, scopes, err := s.validateAuthorization(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	consented := false
+	consent, err := s.oauthRepo.GetConsent(ctx, userID, client.ID)
+	switch {
+	case err == nil:
+		consented = containsScopes(consent.Scopes, scopes)
+	case !errors.Is(err, sql.ErrNoRows):
+		s.logger.Error("failed to get oauth consent from repository",
+			zap.String("user_id", userID.String()),
+			zap.String("client_id", client.ID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	return &model.OAuthAuthorizationResponse{
+		ClientID:   client.ID.String(),
+		ClientName: client.Name,
+		Scopes:     scopes,
+		Consented:  consented,
+	}, nil
+}
+
+// Authorize records the consent of the user to an authorization request,
+// and returns the redirect URI with an authorization code for the client
+// The scopes are added to the ones the user granted the client before
+func (s *DefaultOAuthService) Authorize(ctx context.Context, userID uuid.UUID, req *model.OAuthAuthorizeRequest) (string, error) {
+	client, scopes, err := s.validateAuthorization(ctx, req)
+	if err != nil {
+		return "", err
+	}
+
+	code, err := randomOAuthSecret()
+	if err != nil {
+		return "", err
+	}
+
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		granted := scopes
+		consent, err := s.oauthRepo.GetConsent(ctx, userID, client.ID)
+		if err == nil {
+			granted = normalizeScopes(append(slices.Clone(consent.Scopes), scopes...))
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		err = s.oauthRepo.UpsertConsent(ctx, &model.OAuthConsent{UserID: userID, ClientID: client.ID, Scopes: granted})
+		if err != nil {
+			return err
+		}
+
+		return s.oauthRepo.CreateCode(ctx, &model.OAuthAuthorizationCode{
+			CodeHash:      hashOAuthSecret(code),
+			GrantID:       uuid.New(),
+			ClientID:      client.ID,
+			UserID:        userID,
+			RedirectURI:   req.RedirectURI,
+			Scopes:        scopes,
+			CodeChallenge: req.CodeChallenge,
+		}, s.config.CodeTTL)
+	})
+	if err != nil {
+		s.logger.Error("failed to authorize oauth client",
+			zap.String("user_id", userID.String()),
+			zap.String("client_id", client.ID.String()),
+			zap.Error(err))
+		return "", err
+	}
+
+	// The redirect URI was registered as a valid URL, and its own query parameters are kept
+	redirect, err := url.Parse(req.RedirectURI)
+	if err != nil {
+		return "", err
+	}
+	query := redirect.Query()
+	query.Set("code", code)
+	if req.State != "" {
+		query.Set("state", req.State)
+	}
+	redirect.RawQuery = query.Encode()
+
+	s.logger.Info("oauth client authorized",
+		zap.String("user_id", userID.String()),
+		zap.String("client_id", client.ID.String()),
+		zap.Strings("scopes", scopes))
+	return redirect.String(), nil
+}
+
+// validateAuthorization returns the client of an authorization request and the scopes it asks for,
+// which are all the scopes the client can be granted when it asks for none
+func (s *DefaultOAuthService) validateAuthorization(ctx context.Context, req *model.OAuthAuthorizeRequest) (*model.OAuthClient, []string, error) {
+	clientID, err := uuid.Parse(req.ClientID)
+	if err != nil {
+		return nil, nil, ErrOAuthClientNotFound
+	}
+
+	client, err := s.oauthRepo.GetClient(ctx, clientID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, ErrOAuthClientNotFound
+	}
+	if err != nil {
+		s.logger.Error("failed to get oauth client from repository",
+			zap.String("client_id", req.ClientID),
+			zap.Error(err))
+		return nil, nil, err
+	}
+
+	// Redirect URIs are compared exactly, so that codes are only ever sent where the client registered
+	if !slices.Contains(client.RedirectURIs, req.RedirectURI) {
+		s.logger.Warn("authorization request with unregistered redirect URI",
+			zap.String("client_id", req.ClientID),
+			zap.String("redirect_uri", req.RedirectURI))
+		return nil, nil, ErrInvalidRedirectURI
+	}
+
+	scopes, err := requestedScopes(req.Scope, client.Scopes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return client, scopes, nil
+}
+
+// Token authenticates a client and exchanges a grant for tokens
+func (s *DefaultOAuthService) Token(ctx context.Context, clientID, clientSecret string, req *model.OAuthTokenRequest) (*model.OAuthTokenResponse, error) {
+	client, err := s.authenticateClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.GrantType {
+	case GrantTypeAuthorizationCode:
+		return s.exchangeCode(ctx, client, req)
+	case GrantTypeRefreshToken:
+		return s.refresh(ctx, client, req)
+	case GrantTypeClientCredentials:
+		return s.clientCredentials(ctx, client, req)
+	case "":
+		return nil, ErrInvalidOAuthRequest
+	default:
+		return nil, ErrUnsupportedGrantType
+	}
+}
+
+// exchangeCode redeems an authorization code with its PKCE verifier for an access and refresh token
+// A code used a second time revokes the tokens issued for it, as it has been intercepted or replayed
+func (s *DefaultOAuthService) exchangeCode(ctx context.Context, client *model.OAuthClient, req *model.OAuthTokenRequest) (*model.OAuthTokenResponse, error) {
+	if req.Code == "" || req.CodeVerifier == "" || req.RedirectURI == "" {
+		return nil, ErrInvalidOAuthRequest
+	}
+	// A malformed verifier is refused before the code is used up, so the client can still retry with the right one
+	if !validPKCEVerifier(req.CodeVerifier) {
+		return nil, ErrInvalidOAuthRequest
+	}
+
+	codeHash := hashOAuthSecret(req.Code)
+	code, err := s.oauthRepo.ConsumeCode(ctx, codeHash, client.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		if used, err := s.oauthRepo.GetCode(ctx, codeHash); err == nil && used.UsedAt != nil && used.ClientID == client.ID {
+			s.logger.Warn("authorization code reused, revoking its grant",
+				zap.String("client_id", client.ID.String()),
+				zap.String("user_id", used.UserID.String()))
+			if err := s.oauthRepo.DeleteGrant(ctx, used.GrantID); err != nil {
+				s.logger.Error("failed to revoke oauth grant",
+					zap.String("client_id", client.ID.String()),
+					zap.Error(err))
+			}
+		}
+		return nil, ErrInvalidOAuthGrant
+	}
+	if err != nil {
+		s.logger.Error("failed to consume authorization code",
+			zap.String("client_id", client.ID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	challenge := pkceChallenge(req.CodeVerifier)
+	if code.RedirectURI != req.RedirectURI || subtle.ConstantTimeCompare([]byte(challenge), []byte(code.CodeChallenge)) != 1 {
+		s.logger.Warn("authorization code redeemed with wrong redirect URI or verifier",
+			zap.String("client_id", client.ID.String()),
+			zap.String("user_id", code.UserID.String()))
+		return nil, ErrInvalidOAuthGrant
+	}
+
+	user, err := s.grantUser(ctx, code.UserID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(ctx, client, user, code.GrantID, code.Scopes, true)
+}
+
+// refresh exchanges a refresh token for a new access and refresh token of the same grant
+// The scopes can be narrowed, and the refresh token is replaced, so that each one is used once
+func (s *DefaultOAuthService) refresh(ctx context.Context, client *model.OAuthClient, req *model.OAuthTokenRequest) (*model.OAuthTokenResponse, error) {
+	if req.RefreshToken == "" {
+		return nil, ErrInvalidOAuthRequest
+	}
+
+	var response *model.OAuthTokenResponse
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		token, err := s.oauthRepo.ConsumeToken(ctx, hashOAuthSecret(req.RefreshToken), model.OAuthRefreshToken, client.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidOAuthGrant
+		}
+		if err != nil {
+			s.logger.Error("failed to consume refresh token",
+				zap.String("client_id", client.ID.String()),
+				zap.Error(err))
+			return err
+		}
+
+		scopes := []string(token.Scopes)
+		if req.Scope != "" {
+			if scopes, err = requestedScopes(req.Scope, token.Scopes); err != nil {
+				return err
+			}
+		}
+
+		user, err := s.grantUser(ctx, token.UserID, token)
+		if err != nil {
+			return err
+		}
+
+		response, err = s.issueTokens(ctx, client, user, token.GrantID, scopes, true)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// clientCredentials issues an access token for a confidential client to act as the user who registered it
+// No refresh token is issued, as the client can authenticate again
+func (s *DefaultOAuthService) clientCredentials(ctx context.Context, client *model.OAuthClient, req *model.OAuthTokenRequest) (*model.OAuthTokenResponse, error) {
+	if !client.Confidential() {
+		return nil, ErrUnauthorizedOAuthClient
+	}
+
+	scopes, err := requestedScopes(req.Scope, client.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.grantUser(ctx, client.UserID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(ctx, client, user, uuid.New(), scopes, false)
+}
+
+// grantUser retrieves the user tokens are issued for, who must not have been disabled
+// For a grant continued from a token, tokens issued before the password of the user was changed are revoked
+func (s *DefaultOAuthService) grantUser(ctx context.Context, userID uuid.UUID, token *model.OAuthToken) (*model.User, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidOAuthGrant
+	}
+	if err != nil {
+		s.logger.Error("failed to get user of oauth grant",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	if user.Disabled() {
+		s.logger.Warn("oauth grant refused for disabled account",
+			zap.String("user_id", userID.String()))
+		return nil, ErrInvalidOAuthGrant
+	}
+
+	if token != nil && token.SessionVersion != user.SessionVersion {
+		s.logger.Warn("refresh token revoked by password change",
+			zap.String("user_id", userID.String()),
+			zap.String("client_id", token.ClientID.String()))
+		return nil, ErrInvalidOAuthGrant
+	}
+
+	return user, nil
+}
+
+// issueTokens issues an access token, and a refresh token when withRefresh is set, for the grant
+func (s *DefaultOAuthService) issueTokens(
+	ctx context.Context,
+	client *model.OAuthClient,
+	user *model.User,
+	grantID uuid.UUID,
+	scopes []string,
+	withRefresh bool,
+) (*model.OAuthTokenResponse, error) {
+	kinds := []model.OAuthTokenKind{model.OAuthAccessToken}
+	if withRefresh {
+		kinds = append(kinds, model.OAuthRefreshToken)
+	}
+
+	tokens := make(map[model.OAuthTokenKind]string, len(kinds))
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		for _, kind := range kinds {
+			secret, err := randomOAuthSecret()
+			if err != nil {
+				return err
+			}
+
+			prefix, ttl := OAuthAccessTokenPrefix, s.config.AccessTokenTTL
+			if kind == model.OAuthRefreshToken {
+				prefix, ttl = OAuthRefreshTokenPrefix, s.config.RefreshTokenTTL
+			}
+			tokens[kind] = prefix + secret
+
+			err = s.oauthRepo.CreateToken(ctx, &model.OAuthToken{
+				TokenHash:      hashOAuthSecret(tokens[kind]),
+				Kind:           kind,
+				GrantID:        grantID,
+				ClientID:       client.ID,
+				UserID:         user.ID,
+				Scopes:         scopes,
+				SessionVersion: user.SessionVersion,
+			}, ttl)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("failed to issue oauth tokens",
+			zap.String("client_id", client.ID.String()),
+			zap.String("user_id", user.ID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("oauth tokens issued",
+		zap.String("client_id", client.ID.String()),
+		zap.String("user_id", user.ID.String()),
+		zap.Strings("scopes", scopes),
+		zap.Bool("refresh_token", withRefresh))
+	return &model.OAuthTokenResponse{
+		AccessToken:  tokens[model.OAuthAccessToken],
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.config.AccessTokenTTL.Seconds()),
+		RefreshToken: tokens[model.OAuthRefreshToken],
+		Scope:        strings.Join(scopes, " "),
+	}, nil
+}
+
+// Introspect returns whether a token issued to the authenticated client is active, and what it grants
+// Only confidential clients can introspect, and tokens issued to other clients are reported as not active
+func (s *DefaultOAuthService) Introspect(ctx context.Context, clientID, clientSecret, token string) (*model.OAuthIntrospectionResponse, error) {
+	client, err := s.authenticateClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+	if !client.Confidential() {
+		return nil, ErrInvalidOAuthClient
+	}
+
+	stored, user, err := s.activeToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil || stored.ClientID != client.ID {
+		return &model.OAuthIntrospectionResponse{Active: false}, nil
+	}
+
+	response := &model.OAuthIntrospectionResponse{
+		Active:    true,
+		Scope:     strings.Join(stored.Scopes, " "),
+		ClientID:  stored.ClientID.String(),
+		Subject:   user.ID.String(),
+		ExpiresAt: stored.ExpiresAt.Unix(),
+		IssuedAt:  stored.CreatedAt.Unix(),
+	}
+	if stored.Kind == model.OAuthAccessToken {
+		response.TokenType = "Bearer"
+	}
+	return response, nil
+}
+
+// Revoke revokes a token issued to the authenticated client, and the whole grant for a refresh token
+// Revoking a token that is invalid or was issued to another client succeeds without doing anything (RFC 7009 section 2.2)
+func (s *DefaultOAuthService) Revoke(ctx context.Context, clientID, clientSecret, token string) error {
+	client, err := s.authenticateClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return err
+	}
+
+	stored, err := s.oauthRepo.GetToken(ctx, hashOAuthSecret(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		s.logger.Error("failed to get oauth token from repository",
+			zap.String("client_id", clientID),
+			zap.Error(err))
+		return err
+	}
+	if stored.ClientID != client.ID {
+		s.logger.Warn("attempt to revoke token of another client",
+			zap.String("client_id", clientID),
+			zap.String("token_client_id", stored.ClientID.String()))
+		return nil
+	}
+
+	if stored.Kind == model.OAuthRefreshToken {
+		err = s.oauthRepo.DeleteGrant(ctx, stored.GrantID)
+	} else {
+		err = s.oauthRepo.DeleteToken(ctx, stored.TokenHash)
+	}
+	if err != nil {
+		s.logger.Error("failed to revoke oauth token",
+			zap.String("client_id", clientID),
+			zap.Error(err))
+		return err
+	}
+
+	s.logger.Info("oauth token revoked",
+		zap.String("client_id", clientID),
+		zap.String("user_id", stored.UserID.String()),
+		zap.String("kind", string(stored.Kind)))
+	return nil
+}
+
+// ValidateAccessToken validates an access token issued to a client and returns the claims of its user and scopes
+func (s *DefaultOAuthService) ValidateAccessToken(ctx context.Context, token string) (*Claims, error) {
+	if !strings.HasPrefix(token, OAuthAccessTokenPrefix) {
+		return nil, ErrInvalidToken
+	}
+
+	stored, err := s.oauthRepo.GetToken(ctx, hashOAuthSecret(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		s.logger.Error("failed to get oauth token from repository",
+			zap.Error(err))
+		return nil, err
+	}
+	if stored.Kind != model.OAuthAccessToken {
+		return nil, ErrInvalidToken
+	}
+	if !stored.ExpiresAt.After(time.Now()) {
+		return nil, ErrExpiredToken
+	}
+
+	user, err := s.userRepo.GetByID(ctx, stored.UserID)
+	if err != nil {
+		s.logger.Warn("user of oauth token not found",
+			zap.String("user_id", stored.UserID.String()),
+			zap.Error(err))
+		return nil, ErrInvalidToken
+	}
+	if stored.SessionVersion != user.SessionVersion {
+		return nil, ErrInvalidToken
+	}
+
+	return &Claims{
+		UserID:         user.ID.String(),
+		Email:          user.Email,
+		EmailVerified:  user.EmailVerified(),
+		SessionVersion: user.SessionVersion,
+		Type:           string(AccessToken),
+		ClientID:       stored.ClientID.String(),
+		Scopes:         stored.Scopes,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(stored.ExpiresAt),
+			IssuedAt:  jwt.NewNumericDate(stored.CreatedAt),
+		},
+	}, nil
+}
+
+// activeToken retrieves a token that has not expired or been revoked, with its user
+// It returns nil without an error for a token that is not active
+func (s *DefaultOAuthService) activeToken(ctx context.Context, token string) (*model.OAuthToken, *model.User, error) {
+	stored, err := s.oauthRepo.GetToken(ctx, hashOAuthSecret(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		s.logger.Error("failed to get oauth token from repository",
+			zap.Error(err))
+		return nil, nil, err
+	}
+	if !stored.ExpiresAt.After(time.Now()) {
+		return nil, nil, nil
+	}
+
+	user, err := s.userRepo.GetByID(ctx, stored.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if stored.SessionVersion != user.SessionVersion {
+		return nil, nil, nil
+	}
+
+	return stored, user, nil
+}
+
+// ListConsents retrieves the clients the user granted access to
+func (s *DefaultOAuthService) ListConsents(ctx context.Context, userID uuid.UUID) ([]model.OAuthConsent, error) {
+	consents, err := s.oauthRepo.GetConsentsByUserID(ctx, userID)
+	if err != nil {
+		s.logger.Error("failed to get oauth consents from repository",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	return consents, nil
+}
+
+// RevokeConsent withdraws the consent of the user to a client, revoking the codes and tokens it was issued
+func (s *DefaultOAuthService) RevokeConsent(ctx context.Context, userID, clientID uuid.UUID) error {
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		deleted, err := s.oauthRepo.DeleteConsent(ctx, userID, clientID)
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			return ErrOAuthConsentNotFound
+		}
+
+		return s.oauthRepo.DeleteUserGrants(ctx, userID, clientID)
+	})
+	if errors.Is(err, ErrOAuthConsentNotFound) {
+		return err
+	}
+	if err != nil {
+		s.logger.Error("failed to revoke oauth consent",
+			zap.String("user_id", userID.String()),
+			zap.String("client_id", clientID.String()),
+			zap.Error(err))
+		return err
+	}
+
+	s.logger.Info("oauth consent revoked",
+		zap.String("user_id", userID.String()),
+		zap.String("client_id", clientID.String()))
+	return nil
+}
+
+// PurgeExpired deletes expired authorization codes and tokens and returns how many were deleted
+func (s *DefaultOAuthService) PurgeExpired(ctx context.Context) (int64, error) {
+	purged, err := s.oauthRepo.DeleteExpired(ctx)
+	if err != nil {
+		s.logger.Error("failed to purge expired oauth codes and tokens",
+			zap.Error(err))
+		return purged, err
+	}
+
+	return purged, nil
+}
+
+// authenticateClient retrieves a client by its ID, checking the secret of confidential clients
+// Public clients authenticate with their ID only, and must not send a secret
+func (s *DefaultOAuthService) authenticateClient(ctx context.Context, clientID, clientSecret string) (*model.OAuthClient, error) {
+	id, err := uuid.Parse(clientID)
+	if err != nil {
+		return nil, ErrInvalidOAuthClient
+	}
+
+	client, err := s.oauthRepo.GetClient(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidOAuthClient
+	}
+	if err != nil {
+		s.logger.Error("failed to get oauth client from repository",
+			zap.String("client_id", clientID),
+			zap.Error(err))
+		return nil, err
+	}
+
+	if client.Confidential() != (clientSecret != "") ||
+		client.Confidential() && subtle.ConstantTimeCompare(hashOAuthSecret(clientSecret), client.SecretHash) != 1 {
+		s.logger.Warn("oauth client authentication failed",
+			zap.String("client_id", clientID))
+		return nil, ErrInvalidOAuthClient
+	}
+
+	return client, nil
+}
+
+// requestedScopes parses a space-delimited scope parameter (RFC 6749 section 3.3) into the scopes asked for,
+// which have to be among the allowed ones
+// All the allowed scopes are asked for when the parameter is empty
+func requestedScopes(scope string, allowed []string) ([]string, error) {
+	scopes := strings.Fields(scope)
+	if len(scopes) == 0 {
+		return normalizeScopes(allowed), nil
+	}
+
+	for _, s := range scopes {
+		if !slices.Contains(allowed, s) {
+			return nil, ErrInvalidOAuthScope
+		}
+	}
+	return normalizeScopes(scopes), nil
+}
+
+// normalizeScopes returns the known scopes among the given ones, without duplicates and in the order they are listed in
+func normalizeScopes(scopes []string) []string {
+	normalized := []string{}
+	for _, scope := range model.OAuthScopes {
+		if slices.Contains(scopes, scope) {
+			normalized = append(normalized, scope)
+		}
+	}
+	return normalized
+}
+
+// containsScopes reports whether the granted scopes include all the requested ones
+func containsScopes(granted, requested []string) bool {
+	for _, scope := range requested {
+		if !slices.Contains(granted, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// randomOAuthSecret returns a random base64url encoded secret for a client secret, authorization code or token
+func randomOAuthSecret() (string, error) {
+	secret := make([]byte, oauthSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// hashOAuthSecret returns the SHA-256 hash a client secret, authorization code or token is stored by
+func hashOAuthSecret(secret string) []byte {
+	hash := sha256.Sum256([]byte(secret))
+	return hash[:]
+}
@@ -0,0 +1,751 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+	"go.uber.org/zap"
+)
+
+const (
+	testRedirectURI  = "https://client.example.com/callback"
+	testCodeVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+)
+
+// newOAuthService creates an OAuthService with the mocks and the default configuration
+func newOAuthService(userRepo *MockUserRepository, oauthRepo *MockOAuthRepository) service.OAuthService {
+	return service.NewOAuthService(userRepo, oauthRepo, new(MockTransactor), config.DefaultOAuthConfig(), zap.NewNop())
+}
+
+// hashSecret hashes a secret, code or token the way the service stores it
+func hashSecret(secret string) []byte {
+	hash := sha256.Sum256([]byte(secret))
+	return hash[:]
+}
+
+// codeChallenge returns the PKCE challenge (S256) of the verifier
+func codeChallenge(verifier string) string {
+	hash := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// newOAuthClient creates a client of the user allowed all the scopes, confidential with the secret when it is not empty
+func newOAuthClient(userID uuid.UUID, secret string) *model.OAuthClient {
+	client := &model.OAuthClient{
+		ID:           uuid.New(),
+		UserID:       userID,
+		Name:         "Test Client",
+		RedirectURIs: []string{testRedirectURI},
+		Scopes:       model.OAuthScopes,
+	}
+	if secret != "" {
+		client.SecretHash = hashSecret(secret)
+	}
+	return client
+}
+
+// expectOAuthTokens records the tokens the service issues by their kind
+func expectOAuthTokens(oauthRepo *MockOAuthRepository) map[model.OAuthTokenKind][]*model.OAuthToken {
+	issued := make(map[model.OAuthTokenKind][]*model.OAuthToken)
+	oauthRepo.On("CreateToken", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		token := args.Get(1).(*model.OAuthToken)
+		issued[token.Kind] = append(issued[token.Kind], token)
+	}).Return(nil)
+	return issued
+}
+
+func TestCreateOAuthClient(t *testing.T) {
+	userID := uuid.New()
+
+	testCases := []struct {
+		name   string
+		public bool
+	}{
+		{name: "Confidential client", public: false},
+		{name: "Public client", public: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			oauthRepo := new(MockOAuthRepository)
+			oauthRepo.On("CreateClient", mock.Anything, mock.AnythingOfType("*model.OAuthClient")).Return(nil)
+
+			client, secret, err := newOAuthService(nil, oauthRepo).CreateClient(context.Background(), userID, model.CreateOAuthClientRequest{
+				Name:         "Test Client",
+				RedirectURIs: []string{testRedirectURI},
+				Scopes:       []string{model.ScopeTodosWrite, model.ScopeTodosRead, model.ScopeTodosRead},
+				Public:       tc.public,
+			})
+
+			require.NoError(t, err)
+			assert.Equal(t, userID, client.UserID)
+			assert.Equal(t, []string{model.ScopeTodosRead, model.ScopeTodosWrite}, []string(client.Scopes))
+			if tc.public {
+				assert.Empty(t, secret)
+				assert.False(t, client.Confidential())
+			} else {
+				assert.NotEmpty(t, secret)
+				assert.Equal(t, hashSecret(secret), client.SecretHash)
+			}
+		})
+	}
+}
+
+func TestOAuthAuthorize(t *testing.T) {
+	userID := uuid.New()
+	client := newOAuthClient(uuid.New(), "")
+	readOnly := newOAuthClient(uuid.New(), "")
+	readOnly.Scopes = []string{model.ScopeTodosRead}
+
+	testCases := []struct {
+		name           string
+		client         *model.OAuthClient
+		redirectURI    string
+		scope          string
+		consent        *model.OAuthConsent
+		expectedScopes []string
+		expectedGrant  []string
+		expectedError  error
+	}{
+		{
+			name:           "All the scopes of the client",
+			client:         client,
+			redirectURI:    testRedirectURI,
+			expectedScopes: []string{model.ScopeTodosRead, model.ScopeTodosWrite},
+			expectedGrant:  []string{model.ScopeTodosRead, model.ScopeTodosWrite},
+		},
+		{
+			name:           "Adds to the earlier consent",
+			client:         client,
+			redirectURI:    testRedirectURI,
+			scope:          model.ScopeTodosWrite,
+			consent:        &model.OAuthConsent{Scopes: []string{model.ScopeTodosRead}},
+			expectedScopes: []string{model.ScopeTodosWrite},
+			expectedGrant:  []string{model.ScopeTodosRead, model.ScopeTodosWrite},
+		},
+		{
+			name:          "Unregistered redirect URI",
+			client:        client,
+			redirectURI:   "https://attacker.example.com/callback",
+			expectedError: service.ErrInvalidRedirectURI,
+		},
+		{
+			name:          "Scope the client is not allowed",
+			client:        readOnly,
+			redirectURI:   testRedirectURI,
+			scope:         model.ScopeTodosWrite,
+			expectedError: service.ErrInvalidOAuthScope,
+		},
+		{
+			name:          "Unknown scope",
+			client:        client,
+			redirectURI:   testRedirectURI,
+			scope:         "admin",
+			expectedError: service.ErrInvalidOAuthScope,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			oauthRepo := new(MockOAuthRepository)
+			oauthRepo.On("GetClient", mock.Anything, tc.client.ID).Return(tc.client, nil)
+			if tc.consent != nil {
+				oauthRepo.On("GetConsent", mock.Anything, userID, tc.client.ID).Return(tc.consent, nil)
+			} else {
+				oauthRepo.On("GetConsent", mock.Anything, userID, tc.client.ID).Return(nil, sql.ErrNoRows)
+			}
+			oauthRepo.On("UpsertConsent", mock.Anything, mock.MatchedBy(func(consent *model.OAuthConsent) bool {
+				return consent.UserID == userID && consent.ClientID == tc.client.ID &&
+					assert.ObjectsAreEqual(tc.expectedGrant, []string(consent.Scopes))
+			})).Return(nil)
+			var issued *model.OAuthAuthorizationCode
+			oauthRepo.On("CreateCode", mock.Anything, mock.Anything, 5*time.Minute).Run(func(args mock.Arguments) {
+				issued = args.Get(1).(*model.OAuthAuthorizationCode)
+			}).Return(nil)
+
+			redirectURL, err := newOAuthService(nil, oauthRepo).Authorize(context.Background(), userID, &model.OAuthAuthorizeRequest{
+				ResponseType:        "code",
+				ClientID:            tc.client.ID.String(),
+				RedirectURI:         tc.redirectURI,
+				Scope:               tc.scope,
+				State:               "client-state",
+				CodeChallenge:       codeChallenge(testCodeVerifier),
+				CodeChallengeMethod: "S256",
+			})
+
+			assert.Equal(t, tc.expectedError, err)
+			if tc.expectedError != nil {
+				oauthRepo.AssertNotCalled(t, "CreateCode", mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			oauthRepo.AssertExpectations(t)
+
+			redirect, err := url.Parse(redirectURL)
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(redirectURL, testRedirectURI+"?"))
+			assert.Equal(t, "client-state", redirect.Query().Get("state"))
+			assert.Equal(t, hashSecret(redirect.Query().Get("code")), issued.CodeHash)
+			assert.Equal(t, tc.expectedScopes, []string(issued.Scopes))
+			assert.Equal(t, codeChallenge(testCodeVerifier), issued.CodeChallenge)
+		})
+	}
+}
+
+func TestGetOAuthAuthorization(t *testing.T) {
+	userID := uuid.New()
+	client := newOAuthClient(uuid.New(), "")
+	request := &model.OAuthAuthorizeRequest{
+		ResponseType:        "code",
+		ClientID:            client.ID.String(),
+		RedirectURI:         testRedirectURI,
+		Scope:               model.ScopeTodosRead + " " + model.ScopeTodosWrite,
+		CodeChallenge:       codeChallenge(testCodeVerifier),
+		CodeChallengeMethod: "S256",
+	}
+
+	testCases := []struct {
+		name              string
+		consent           *model.OAuthConsent
+		expectedConsented bool
+	}{
+		{name: "Not consented yet", consent: nil, expectedConsented: false},
+		{name: "Consented to fewer scopes", consent: &model.OAuthConsent{Scopes: []string{model.ScopeTodosRead}}, expectedConsented: false},
+		{name: "Consented to all the scopes", consent: &model.OAuthConsent{Scopes: model.OAuthScopes}, expectedConsented: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			oauthRepo := new(MockOAuthRepository)
+			oauthRepo.On("GetClient", mock.Anything, client.ID).Return(client, nil)
+			if tc.consent != nil {
+				oauthRepo.On("GetConsent", mock.Anything, userID, client.ID).Return(tc.consent, nil)
+			} else {
+				oauthRepo.On("GetConsent", mock.Anything, userID, client.ID).Return(nil, sql.ErrNoRows)
+			}
+
+			authorization, err := newOAuthService(nil, oauthRepo).GetAuthorization(context.Background(), userID, request)
+
+			require.NoError(t, err)
+			assert.Equal(t, client.Name, authorization.ClientName)
+			assert.Equal(t, []string{model.ScopeTodosRead, model.ScopeTodosWrite}, authorization.Scopes)
+			assert.Equal(t, tc.expectedConsented, authorization.Consented)
+		})
+	}
+
+	t.Run("Unknown client", func(t *testing.T) {
+		oauthRepo := new(MockOAuthRepository)
+		oauthRepo.On("GetClient", mock.Anything, client.ID).Return(nil, sql.ErrNoRows)
+
+		_, err := newOAuthService(nil, oauthRepo).GetAuthorization(context.Background(), userID, request)
+
+		assert.Equal(t, service.ErrOAuthClientNotFound, err)
+	})
+}
+
+func TestOAuthExchangeCode(t *testing.T) {
+	user := &model.User{ID: uuid.New(), Email: "test@example.com", SessionVersion: 2}
+	const secret = "client-secret"
+	client := newOAuthClient(uuid.New(), secret)
+	code := "authorization-code"
+	stored := &model.OAuthAuthorizationCode{
+		CodeHash:      hashSecret(code),
+		GrantID:       uuid.New(),
+		ClientID:      client.ID,
+		UserID:        user.ID,
+		RedirectURI:   testRedirectURI,
+		Scopes:        []string{model.ScopeTodosRead},
+		CodeChallenge: codeChallenge(testCodeVerifier),
+	}
+	request := func() *model.OAuthTokenRequest {
+		return &model.OAuthTokenRequest{
+			GrantType:    service.GrantTypeAuthorizationCode,
+			Code:         code,
+			RedirectURI:  testRedirectURI,
+			CodeVerifier: testCodeVerifier,
+		}
+	}
+
+	// setup returns the mocks with the code redeemable once
+	setup := func() (*MockUserRepository, *MockOAuthRepository) {
+		userRepo := new(MockUserRepository)
+		oauthRepo := new(MockOAuthRepository)
+		userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+		oauthRepo.On("GetClient", mock.Anything, client.ID).Return(client, nil)
+		oauthRepo.On("ConsumeCode", mock.Anything, stored.CodeHash, client.ID).Return(stored, nil).Once()
+		return userRepo, oauthRepo
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		userRepo, oauthRepo := setup()
+		issued := expectOAuthTokens(oauthRepo)
+
+		response, err := newOAuthService(userRepo, oauthRepo).Token(context.Background(), client.ID.String(), secret, request())
+
+		require.NoError(t, err)
+		assert.Equal(t, "Bearer", response.TokenType)
+		assert.Equal(t, 3600, response.ExpiresIn)
+		assert.Equal(t, model.ScopeTodosRead, response.Scope)
+		assert.True(t, strings.HasPrefix(response.AccessToken, service.OAuthAccessTokenPrefix))
+		assert.True(t, strings.HasPrefix(response.RefreshToken, service.OAuthRefreshTokenPrefix))
+		require.Len(t, issued[model.OAuthAccessToken], 1)
+		require.Len(t, issued[model.OAuthRefreshToken], 1)
+		access := issued[model.OAuthAccessToken][0]
+		assert.Equal(t, hashSecret(response.AccessToken), access.TokenHash)
+		assert.Equal(t, stored.GrantID, access.GrantID)
+		assert.Equal(t, user.SessionVersion, access.SessionVersion)
+		assert.Equal(t, hashSecret(response.RefreshToken), issued[model.OAuthRefreshToken][0].TokenHash)
+	})
+
+	t.Run("Wrong code verifier", func(t *testing.T) {
+		userRepo, oauthRepo := setup()
+		req := request()
+		req.CodeVerifier = strings.Repeat("x", 43)
+
+		_, err := newOAuthService(userRepo, oauthRepo).Token(context.Background(), client.ID.String(), secret, req)
+
+		assert.Equal(t, service.ErrInvalidOAuthGrant, err)
+		oauthRepo.AssertNotCalled(t, "CreateToken", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Wrong redirect URI", func(t *testing.T) {
+		userRepo, oauthRepo := setup()
+		req := request()
+		req.RedirectURI = "https://client.example.com/other"
+
+		_, err := newOAuthService(userRepo, oauthRepo).Token(context.Background(), client.ID.String(), secret, req)
+
+		assert.Equal(t, service.ErrInvalidOAuthGrant, err)
+		oauthRepo.AssertNotCalled(t, "CreateToken", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Reused code revokes its grant", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		oauthRepo := new(MockOAuthRepository)
+		usedAt := time.Now()
+		used := *stored
+		used.UsedAt = &usedAt
+		oauthRepo.On("GetClient", mock.Anything, client.ID).Return(client, nil)
+		oauthRepo.On("ConsumeCode", mock.Anything, stored.CodeHash, client.ID).Return(nil, sql.ErrNoRows)
+		oauthRepo.On("GetCode", mock.Anything, stored.CodeHash).Return(&used, nil)
+		oauthRepo.On("DeleteGrant", mock.Anything, stored.GrantID).Return(nil)
+
+		_, err := newOAuthService(userRepo, oauthRepo).Token(context.Background(), client.ID.String(), secret, request())
+
+		assert.Equal(t, service.ErrInvalidOAuthGrant, err)
+		oauthRepo.AssertExpectations(t)
+	})
+
+	t.Run("Missing code verifier", func(t *testing.T) {
+		userRepo, oauthRepo := setup()
+		req := request()
+		req.CodeVerifier = ""
+
+		_, err := newOAuthService(userRepo, oauthRepo).Token(context.Background(), client.ID.String(), secret, req)
+
+		assert.Equal(t, service.ErrInvalidOAuthRequest, err)
+	})
+
+	t.Run("Malformed code verifier", func(t *testing.T) {
+		verifiers := map[string]string{
+			"too short":             testCodeVerifier[:42],
+			"too long":              strings.Repeat("a", 129),
+			"disallowed characters": testCodeVerifier[:42] + "+",
+			"spaces":                strings.Repeat("a", 42) + " ",
+			"non-ASCII":             strings.Repeat("a", 42) + "é",
+		}
+		for name, verifier := range verifiers {
+			t.Run(name, func(t *testing.T) {
+				userRepo, oauthRepo := setup()
+				req := request()
+				req.CodeVerifier = verifier
+
+				_, err := newOAuthService(userRepo, oauthRepo).Token(context.Background(), client.ID.String(), secret, req)
+
+				assert.Equal(t, service.ErrInvalidOAuthRequest, err)
+				oauthRepo.AssertNotCalled(t, "ConsumeCode", mock.Anything, mock.Anything, mock.Anything)
+			})
+		}
+	})
+
+	t.Run("Longest code verifier", func(t *testing.T) {
+		verifier := strings.Repeat("a-._~Z9", 19)[:128]
+		userRepo := new(MockUserRepository)
+		oauthRepo := new(MockOAuthRepository)
+		withVerifier := *stored
+		withVerifier.CodeChallenge = codeChallenge(verifier)
+		userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+		oauthRepo.On("GetClient", mock.Anything, client.ID).Return(client, nil)
+		oauthRepo.On("ConsumeCode", mock.Anything, stored.CodeHash, client.ID).Return(&withVerifier, nil)
+		expectOAuthTokens(oauthRepo)
+		req := request()
+		req.CodeVerifier = verifier
+
+		_, err := newOAuthService(userRepo, oauthRepo).Token(context.Background(), client.ID.String(), secret, req)
+
+		require.NoError(t, err)
+	})
+
+	t.Run("Wrong client secret", func(t *testing.T) {
+		userRepo, oauthRepo := setup()
+
+		_, err := newOAuthService(userRepo, oauthRepo).Token(context.Background(), client.ID.String(), "wrong-secret", request())
+
+		assert.Equal(t, service.ErrInvalidOAuthClient, err)
+		oauthRepo.AssertNotCalled(t, "ConsumeCode", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Unsupported grant type", func(t *testing.T) {
+		userRepo, oauthRepo := setup()
+		req := request()
+		req.GrantType = "password"
+
+		_, err := newOAuthService(userRepo, oauthRepo).Token(context.Background(), client.ID.String(), secret, req)
+
+		assert.Equal(t, service.ErrUnsupportedGrantType, err)
+	})
+}
+
+func TestOAuthPublicClientAuthentication(t *testing.T) {
+	client := newOAuthClient(uuid.New(), "")
+	oauthRepo := new(MockOAuthRepository)
+	oauthRepo.On("GetClient", mock.Anything, client.ID).Return(client, nil)
+	oauthService := newOAuthService(nil, oauthRepo)
+
+	_, err := oauthService.Token(context.Background(), client.ID.String(), "any-secret", &model.OAuthTokenRequest{GrantType: service.GrantTypeRefreshToken})
+	assert.Equal(t, service.ErrInvalidOAuthClient, err, "public clients have no secret")
+
+	_, err = oauthService.Token(context.Background(), client.ID.String(), "", &model.OAuthTokenRequest{GrantType: service.GrantTypeClientCredentials})
+	assert.Equal(t, service.ErrUnauthorizedOAuthClient, err, "public clients cannot use client credentials")
+
+	_, err = oauthService.Token(context.Background(), "not-a-uuid", "", &model.OAuthTokenRequest{GrantType: service.GrantTypeRefreshToken})
+	assert.Equal(t, service.ErrInvalidOAuthClient, err)
+}
+
+func TestOAuthRefresh(t *testing.T) {
+	user := &model.User{ID: uuid.New(), Email: "test@example.com", SessionVersion: 1}
+	client := newOAuthClient(uuid.New(), "")
+	refreshToken := service.OAuthRefreshTokenPrefix + "refresh"
+	stored := &model.OAuthToken{
+		TokenHash:      hashSecret(refreshToken),
+		Kind:           model.OAuthRefreshToken,
+		GrantID:        uuid.New(),
+		ClientID:       client.ID,
+		UserID:         user.ID,
+		Scopes:         []string{model.ScopeTodosRead, model.ScopeTodosWrite},
+		SessionVersion: 1,
+	}
+	revoked := *stored
+	revoked.SessionVersion = 0
+
+	testCases := []struct {
+		name           string
+		token          *model.OAuthToken
+		scope          string
+		expectedScopes []string
+		expectedError  error
+	}{
+		{name: "Rotates the refresh token", token: stored, expectedScopes: []string{model.ScopeTodosRead, model.ScopeTodosWrite}},
+		{name: "Narrows the scopes", token: stored, scope: model.ScopeTodosRead, expectedScopes: []string{model.ScopeTodosRead}},
+		{name: "Revoked by a password change", token: &revoked, expectedError: service.ErrInvalidOAuthGrant},
+		{name: "Used, expired or unknown", token: nil, expectedError: service.ErrInvalidOAuthGrant},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			userRepo := new(MockUserRepository)
+			oauthRepo := new(MockOAuthRepository)
+			userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+			oauthRepo.On("GetClient", mock.Anything, client.ID).Return(client, nil)
+			if tc.token != nil {
+				oauthRepo.On("ConsumeToken", mock.Anything, stored.TokenHash, model.OAuthRefreshToken, client.ID).Return(tc.token, nil)
+			} else {
+				oauthRepo.On("ConsumeToken", mock.Anything, stored.TokenHash, model.OAuthRefreshToken, client.ID).Return(nil, sql.ErrNoRows)
+			}
+			issued := expectOAuthTokens(oauthRepo)
+
+			response, err := newOAuthService(userRepo, oauthRepo).Token(context.Background(), client.ID.String(), "", &model.OAuthTokenRequest{
+				GrantType:    service.GrantTypeRefreshToken,
+				RefreshToken: refreshToken,
+				Scope:        tc.scope,
+			})
+
+			assert.Equal(t, tc.expectedError, err)
+			if tc.expectedError != nil {
+				assert.Empty(t, issued)
+				return
+			}
+			assert.NotEqual(t, refreshToken, response.RefreshToken)
+			assert.Equal(t, strings.Join(tc.expectedScopes, " "), response.Scope)
+			require.Len(t, issued[model.OAuthRefreshToken], 1)
+			assert.Equal(t, stored.GrantID, issued[model.OAuthRefreshToken][0].GrantID)
+			assert.Equal(t, tc.expectedScopes, []string(issued[model.OAuthAccessToken][0].Scopes))
+		})
+	}
+
+	t.Run("Cannot widen the scopes", func(t *testing.T) {
+		readOnly := *stored
+		readOnly.Scopes = []string{model.ScopeTodosRead}
+		oauthRepo := new(MockOAuthRepository)
+		oauthRepo.On("GetClient", mock.Anything, client.ID).Return(client, nil)
+		oauthRepo.On("ConsumeToken", mock.Anything, stored.TokenHash, model.OAuthRefreshToken, client.ID).Return(&readOnly, nil)
+
+		_, err := newOAuthService(new(MockUserRepository), oauthRepo).Token(context.Background(), client.ID.String(), "", &model.OAuthTokenRequest{
+			GrantType:    service.GrantTypeRefreshToken,
+			RefreshToken: refreshToken,
+			Scope:        model.ScopeTodosWrite,
+		})
+
+		assert.Equal(t, service.ErrInvalidOAuthScope, err)
+	})
+}
+
+func TestOAuthClientCredentials(t *testing.T) {
+	owner := &model.User{ID: uuid.New(), Email: "owner@example.com"}
+	const secret = "client-secret"
+	client := newOAuthClient(owner.ID, secret)
+	userRepo := new(MockUserRepository)
+	oauthRepo := new(MockOAuthRepository)
+	userRepo.On("GetByID", mock.Anything, owner.ID).Return(owner, nil)
+	oauthRepo.On("GetClient", mock.Anything, client.ID).Return(client, nil)
+	issued := expectOAuthTokens(oauthRepo)
+
+	response, err := newOAuthService(userRepo, oauthRepo).Token(context.Background(), client.ID.String(), secret, &model.OAuthTokenRequest{
+		GrantType: service.GrantTypeClientCredentials,
+		Scope:     model.ScopeTodosRead,
+	})
+
+	require.NoError(t, err)
+	assert.Empty(t, response.RefreshToken)
+	assert.Equal(t, model.ScopeTodosRead, response.Scope)
+	require.Len(t, issued[model.OAuthAccessToken], 1)
+	assert.Empty(t, issued[model.OAuthRefreshToken])
+	assert.Equal(t, owner.ID, issued[model.OAuthAccessToken][0].UserID)
+}
+
+func TestOAuthIntrospect(t *testing.T) {
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+	const secret = "client-secret"
+	client := newOAuthClient(uuid.New(), secret)
+	publicClient := newOAuthClient(uuid.New(), "")
+	token := service.OAuthAccessTokenPrefix + "access"
+	active := &model.OAuthToken{
+		TokenHash: hashSecret(token),
+		Kind:      model.OAuthAccessToken,
+		ClientID:  client.ID,
+		UserID:    user.ID,
+		Scopes:    []string{model.ScopeTodosRead},
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	expired := *active
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	otherClient := *active
+	otherClient.ClientID = uuid.New()
+
+	testCases := []struct {
+		name           string
+		token          *model.OAuthToken
+		expectedActive bool
+	}{
+		{name: "Active token", token: active, expectedActive: true},
+		{name: "Expired token", token: &expired, expectedActive: false},
+		{name: "Token of another client", token: &otherClient, expectedActive: false},
+		{name: "Unknown token", token: nil, expectedActive: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			userRepo := new(MockUserRepository)
+			oauthRepo := new(MockOAuthRepository)
+			userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+			oauthRepo.On("GetClient", mock.Anything, client.ID).Return(client, nil)
+			if tc.token != nil {
+				oauthRepo.On("GetToken", mock.Anything, active.TokenHash).Return(tc.token, nil)
+			} else {
+				oauthRepo.On("GetToken", mock.Anything, active.TokenHash).Return(nil, sql.ErrNoRows)
+			}
+
+			response, err := newOAuthService(userRepo, oauthRepo).Introspect(context.Background(), client.ID.String(), secret, token)
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedActive, response.Active)
+			if !tc.expectedActive {
+				assert.Equal(t, model.OAuthIntrospectionResponse{}, *response)
+				return
+			}
+			assert.Equal(t, model.ScopeTodosRead, response.Scope)
+			assert.Equal(t, client.ID.String(), response.ClientID)
+			assert.Equal(t, user.ID.String(), response.Subject)
+			assert.Equal(t, "Bearer", response.TokenType)
+			assert.Equal(t, active.ExpiresAt.Unix(), response.ExpiresAt)
+		})
+	}
+
+	t.Run("Public client", func(t *testing.T) {
+		oauthRepo := new(MockOAuthRepository)
+		oauthRepo.On("GetClient", mock.Anything, publicClient.ID).Return(publicClient, nil)
+
+		_, err := newOAuthService(nil, oauthRepo).Introspect(context.Background(), publicClient.ID.String(), "", token)
+
+		assert.Equal(t, service.ErrInvalidOAuthClient, err)
+	})
+}
+
+func TestOAuthRevoke(t *testing.T) {
+	client := newOAuthClient(uuid.New(), "")
+	token := "token-to-revoke"
+	grantID := uuid.New()
+
+	testCases := []struct {
+		name         string
+		token        *model.OAuthToken
+		expectDelete string
+	}{
+		{
+			name:         "Refresh token revokes its grant",
+			token:        &model.OAuthToken{TokenHash: hashSecret(token), Kind: model.OAuthRefreshToken, GrantID: grantID, ClientID: client.ID},
+			expectDelete: "DeleteGrant",
+		},
+		{
+			name:         "Access token is revoked alone",
+			token:        &model.OAuthToken{TokenHash: hashSecret(token), Kind: model.OAuthAccessToken, GrantID: grantID, ClientID: client.ID},
+			expectDelete: "DeleteToken",
+		},
+		{
+			name:  "Token of another client is left alone",
+			token: &model.OAuthToken{TokenHash: hashSecret(token), Kind: model.OAuthRefreshToken, GrantID: grantID, ClientID: uuid.New()},
+		},
+		{
+			name:  "Unknown token",
+			token: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			oauthRepo := new(MockOAuthRepository)
+			oauthRepo.On("GetClient", mock.Anything, client.ID).Return(client, nil)
+			if tc.token != nil {
+				oauthRepo.On("GetToken", mock.Anything, hashSecret(token)).Return(tc.token, nil)
+			} else {
+				oauthRepo.On("GetToken", mock.Anything, hashSecret(token)).Return(nil, sql.ErrNoRows)
+			}
+			oauthRepo.On("DeleteGrant", mock.Anything, grantID).Return(nil)
+			oauthRepo.On("DeleteToken", mock.Anything, hashSecret(token)).Return(nil)
+
+			err := newOAuthService(nil, oauthRepo).Revoke(context.Background(), client.ID.String(), "", token)
+
+			require.NoError(t, err)
+			for _, method := range []string{"DeleteGrant", "DeleteToken"} {
+				if method == tc.expectDelete {
+					oauthRepo.AssertCalled(t, method, mock.Anything, mock.Anything)
+				} else {
+					oauthRepo.AssertNotCalled(t, method, mock.Anything, mock.Anything)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateOAuthAccessToken(t *testing.T) {
+	user := &model.User{ID: uuid.New(), Email: "test@example.com", SessionVersion: 3}
+	clientID := uuid.New()
+	token := service.OAuthAccessTokenPrefix + "access"
+	valid := &model.OAuthToken{
+		TokenHash:      hashSecret(token),
+		Kind:           model.OAuthAccessToken,
+		ClientID:       clientID,
+		UserID:         user.ID,
+		Scopes:         []string{model.ScopeTodosRead},
+		SessionVersion: 3,
+		CreatedAt:      time.Now(),
+		ExpiresAt:      time.Now().Add(time.Hour),
+	}
+	expired := *valid
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	refresh := *valid
+	refresh.Kind = model.OAuthRefreshToken
+	oldSession := *valid
+	oldSession.SessionVersion = 2
+
+	testCases := []struct {
+		name          string
+		token         string
+		stored        *model.OAuthToken
+		expectedError error
+	}{
+		{name: "Valid token", token: token, stored: valid},
+		{name: "Expired token", token: token, stored: &expired, expectedError: service.ErrExpiredToken},
+		{name: "Refresh token", token: token, stored: &refresh, expectedError: service.ErrInvalidToken},
+		{name: "Revoked by a password change", token: token, stored: &oldSession, expectedError: service.ErrInvalidToken},
+		{name: "Unknown token", token: token, stored: nil, expectedError: service.ErrInvalidToken},
+		{name: "Not an OAuth access token", token: "eyJhbGciOiJIUzI1NiJ9.e30.sig", stored: valid, expectedError: service.ErrInvalidToken},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			userRepo := new(MockUserRepository)
+			oauthRepo := new(MockOAuthRepository)
+			userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+			if tc.stored != nil {
+				oauthRepo.On("GetToken", mock.Anything, mock.MatchedBy(func(hash []byte) bool {
+					return bytes.Equal(hash, hashSecret(token))
+				})).Return(tc.stored, nil)
+			} else {
+				oauthRepo.On("GetToken", mock.Anything, mock.Anything).Return(nil, sql.ErrNoRows)
+			}
+
+			claims, err := newOAuthService(userRepo, oauthRepo).ValidateAccessToken(context.Background(), tc.token)
+
+			assert.Equal(t, tc.expectedError, err)
+			if tc.expectedError != nil {
+				return
+			}
+			assert.Equal(t, user.ID.String(), claims.UserID)
+			assert.Equal(t, clientID.String(), claims.ClientID)
+			assert.True(t, claims.Delegated())
+			assert.True(t, claims.HasScope(model.ScopeTodosRead))
+			assert.False(t, claims.HasScope(model.ScopeTodosWrite))
+		})
+	}
+}
+
+func TestRevokeOAuthConsent(t *testing.T) {
+	userID := uuid.New()
+	clientID := uuid.New()
+
+	t.Run("Success", func(t *testing.T) {
+		oauthRepo := new(MockOAuthRepository)
+		oauthRepo.On("DeleteConsent", mock.Anything, userID, clientID).Return(true, nil)
+		oauthRepo.On("DeleteUserGrants", mock.Anything, userID, clientID).Return(nil)
+
+		err := newOAuthService(nil, oauthRepo).RevokeConsent(context.Background(), userID, clientID)
+
+		require.NoError(t, err)
+		oauthRepo.AssertExpectations(t)
+	})
+
+	t.Run("Not consented", func(t *testing.T) {
+		oauthRepo := new(MockOAuthRepository)
+		oauthRepo.On("DeleteConsent", mock.Anything, userID, clientID).Return(false, nil)
+
+		err := newOAuthService(nil, oauthRepo).RevokeConsent(context.Background(), userID, clientID)
+
+		assert.Equal(t, service.ErrOAuthConsentNotFound, err)
+		oauthRepo.AssertNotCalled(t, "DeleteUserGrants", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
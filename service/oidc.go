@@ -0,0 +1,387 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/yukimaterrace/todoms/config"
+)
+
+// oidcDiscoveryPath is where providers publish their configuration (OpenID Connect Discovery 1.0)
+const oidcDiscoveryPath = "/.well-known/openid-configuration"
+
+// oidcKeyRefreshInterval is the shortest time between fetches of the signing keys of a provider,
+// so that ID tokens with unknown key IDs cannot make the server flood the provider with requests
+const oidcKeyRefreshInterval = time.Minute
+
+// oidcClockSkew is the difference between the clocks of the server and a provider tolerated for ID tokens
+const oidcClockSkew = time.Minute
+
+// oidcMaxResponseSize is the largest response read from a provider
+const oidcMaxResponseSize = 1 << 20
+
+// oidcIDTokenAlgorithms are the signature algorithms accepted for ID tokens
+var oidcIDTokenAlgorithms = []string{"RS256", "ES256"}
+
+var (
+	// errOIDCProvider is returned when a provider cannot be reached or responds unexpectedly
+	errOIDCProvider = errors.New("unexpected response from OpenID Connect provider")
+
+	// errOIDCCodeRejected is returned when a provider refuses to redeem an authorization code
+	errOIDCCodeRejected = errors.New("authorization code rejected by OpenID Connect provider")
+
+	// errInvalidIDToken is returned when an ID token fails verification
+	errInvalidIDToken = errors.New("invalid ID token")
+)
+
+// oidcMetadata is the part of the configuration of a provider the authorization code flow uses
+type oidcMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcIDTokenClaims are the claims of an ID token the server uses
+type oidcIDTokenClaims struct {
+	Nonce           string   `json:"nonce"`
+	AuthorizedParty string   `json:"azp"`
+	Email           string   `json:"email"`
+	EmailVerified   oidcBool `json:"email_verified"`
+	Name            string   `json:"name"`
+	jwt.RegisteredClaims
+}
+
+// oidcBool is a boolean claim, which some providers send as a string
+type oidcBool bool
+
+// UnmarshalJSON decodes a JSON boolean, or the strings "true" and "false"
+func (b *oidcBool) UnmarshalJSON(data []byte) error {
+	switch string(data) {
+	case `true`, `"true"`:
+		*b = true
+	case `false`, `"false"`, `null`:
+		*b = false
+	default:
+		return errInvalidIDToken
+	}
+	return nil
+}
+
+// jsonWebKey is a public key in a JSON Web Key Set (RFC 7517)
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// oidcProvider is a client of an OpenID Connect provider, caching its configuration and signing keys
+type oidcProvider struct {
+	config config.OIDCProviderConfig
+	client *http.Client
+
+	mu            sync.Mutex
+	metadata      *oidcMetadata
+	keys          map[string]crypto.PublicKey
+	keysFetchedAt time.Time
+}
+
+// newOIDCProvider creates a client of the provider, which discovers its configuration on first use
+func newOIDCProvider(cfg config.OIDCProviderConfig, client *http.Client) *oidcProvider {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = config.DefaultOIDCScopes
+	}
+	return &oidcProvider{config: cfg, client: client}
+}
+
+// discover returns the configuration of the provider, fetching it on first use
+func (p *oidcProvider) discover(ctx context.Context) (*oidcMetadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	var metadata oidcMetadata
+	if err := p.getJSON(ctx, strings.TrimSuffix(p.config.Issuer, "/")+oidcDiscoveryPath, &metadata); err != nil {
+		return nil, err
+	}
+	// The configuration has to be that of the issuer it was fetched from (OpenID Connect Discovery 1.0 section 4.3)
+	if metadata.Issuer != p.config.Issuer || metadata.AuthorizationEndpoint == "" ||
+		metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, errOIDCProvider
+	}
+
+	p.metadata = &metadata
+	return p.metadata, nil
+}
+
+// authorizationURL returns the URL of the authorization request to redirect the browser to,
+// with the PKCE challenge of the verifier (RFC 7636)
+func (p *oidcProvider) authorizationURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(metadata.AuthorizationEndpoint)
+	if err != nil {
+		return "", errOIDCProvider
+	}
+	// Parameters the endpoint already has are kept
+	query := u.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.config.ClientID)
+	query.Set("redirect_uri", p.config.RedirectURL)
+	query.Set("scope", strings.Join(p.config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", pkceChallenge(codeVerifier))
+	query.Set("code_challenge_method", "S256")
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// exchange redeems an authorization code with its PKCE verifier and returns the ID token
+func (p *oidcProvider) exchange(ctx context.Context, code, codeVerifier string) (string, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"code_verifier": {codeVerifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	// client_secret_basic, with the credentials form-encoded first (RFC 6749 section 2.3.1)
+	req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errOIDCProvider, err)
+	}
+	defer resp.Body.Close()
+
+	// An invalid, expired or used code or a wrong verifier is refused with 400 (RFC 6749 section 5.2)
+	if resp.StatusCode == http.StatusBadRequest {
+		return "", errOIDCCodeRejected
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%w: token endpoint returned %d", errOIDCProvider, resp.StatusCode)
+	}
+
+	var token struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, oidcMaxResponseSize)).Decode(&token); err != nil || token.IDToken == "" {
+		return "", fmt.Errorf("%w: token response has no ID token", errOIDCProvider)
+	}
+	return token.IDToken, nil
+}
+
+// verifyIDToken verifies the signature and claims of an ID token issued to the client for the nonce
+// (OpenID Connect Core 1.0 section 3.1.3.7)
+func (p *oidcProvider) verifyIDToken(ctx context.Context, rawIDToken, nonce string) (*oidcIDTokenClaims, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := new(oidcIDTokenClaims)
+	_, err = jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.signingKey(ctx, kid)
+	},
+		jwt.WithValidMethods(oidcIDTokenAlgorithms),
+		jwt.WithIssuer(metadata.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(oidcClockSkew),
+	)
+	if errors.Is(err, errOIDCProvider) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidIDToken, err)
+	}
+
+	// A token for several audiences has to have been issued to this client
+	if (len(claims.Audience) > 1 || claims.AuthorizedParty != "") && claims.AuthorizedParty != p.config.ClientID {
+		return nil, fmt.Errorf("%w: issued to another client", errInvalidIDToken)
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, fmt.Errorf("%w: nonce does not match", errInvalidIDToken)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: no subject", errInvalidIDToken)
+	}
+	return claims, nil
+}
+
+// signingKey returns the signing key of the provider with the key ID
+// The keys are fetched again for an unknown key ID, as providers rotate them, at most once per oidcKeyRefreshInterval
+func (p *oidcProvider) signingKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	if p.keys != nil && time.Since(p.keysFetchedAt) < oidcKeyRefreshInterval {
+		return nil, errInvalidIDToken
+	}
+
+	var keySet struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.getJSON(ctx, p.metadata.JWKSURI, &keySet); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey, len(keySet.Keys))
+	for _, jwk := range keySet.Keys {
+		// Encryption keys and keys of unsupported types are ignored
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if key, err := parseJWK(jwk); err == nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, errInvalidIDToken
+}
+
+// lookupKey returns the cached key with the key ID, or the only key when the token names none
+func (p *oidcProvider) lookupKey(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+// getJSON fetches a JSON document from the provider
+func (p *oidcProvider) getJSON(ctx context.Context, rawURL string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errOIDCProvider, err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errOIDCProvider, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s returned %d", errOIDCProvider, rawURL, resp.StatusCode)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, oidcMaxResponseSize)).Decode(v); err != nil {
+		return fmt.Errorf("%w: %v", errOIDCProvider, err)
+	}
+	return nil
+}
+
+// parseJWK parses an RSA key of at least minRSAKeyBits or a P-256 key
+func parseJWK(jwk jsonWebKey) (crypto.PublicKey, error) {
+	switch {
+	case jwk.Kty == "RSA":
+		n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+		e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+		if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errInvalidIDToken
+		}
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if key.N.BitLen() < minRSAKeyBits || key.E < 3 || key.E%2 == 0 {
+			return nil, errInvalidIDToken
+		}
+		return key, nil
+
+	case jwk.Kty == "EC" && jwk.Crv == "P-256":
+		x, errX := base64.RawURLEncoding.DecodeString(jwk.X)
+		y, errY := base64.RawURLEncoding.DecodeString(jwk.Y)
+		if errX != nil || errY != nil || len(x) != 32 || len(y) != 32 {
+			return nil, errInvalidIDToken
+		}
+		// Reject points that are not on the curve
+		if _, err := ecdh.P256().NewPublicKey(slices.Concat([]byte{0x04}, x, y)); err != nil {
+			return nil, errInvalidIDToken
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, errInvalidIDToken
+}
+
+// randomOIDCToken returns a random unpadded base64url string for a state, nonce or PKCE verifier
+func randomOIDCToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// validPKCEVerifier reports whether a PKCE verifier has the form RFC 7636 requires,
+// 43 to 128 characters of letters, digits and "-._~"
+func validPKCEVerifier(codeVerifier string) bool {
+	if len(codeVerifier) < 43 || len(codeVerifier) > 128 {
+		return false
+	}
+	for _, c := range codeVerifier {
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+		case c == '-', c == '.', c == '_', c == '~':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// pkceChallenge returns the S256 code challenge of a PKCE verifier
+func pkceChallenge(codeVerifier string) string {
+	hash := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
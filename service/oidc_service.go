@@ -0,0 +1,398 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrOIDCProviderNotFound is returned when no provider with the given name is configured
+	ErrOIDCProviderNotFound = errors.New("OpenID Connect provider not found")
+
+	// ErrInvalidOIDCState is returned when a login or link is completed with a state that was not issued for it,
+	// or was already used or has expired
+	ErrInvalidOIDCState = errors.New("invalid or expired OpenID Connect state")
+
+	// ErrOIDCAuthenticationFailed is returned when the provider refuses the authorization code or its ID token fails verification
+	ErrOIDCAuthenticationFailed = errors.New("OpenID Connect authentication failed")
+
+	// ErrOIDCEmailNotVerified is returned when the first login with an external account cannot create a user,
+	// as the provider reported no verified email address
+	ErrOIDCEmailNotVerified = errors.New("provider reported no verified email address")
+
+	// ErrOIDCAccountExists is returned when the first login with an external account would create a user
+	// with the email address of an existing one, who has to log in and link the provider instead
+	ErrOIDCAccountExists = errors.New("an account with the email address already exists")
+
+	// ErrIdentityNotFound is returned when a user has no linked identity with the given ID
+	ErrIdentityNotFound = errors.New("identity not found")
+
+	// ErrLastLoginMethod is returned when unlinking the only identity of a user without a password
+	ErrLastLoginMethod = errors.New("cannot unlink the only way to log in")
+)
+
+// OIDCService defines the interface for logging in with external OpenID Connect providers
+// and linking their accounts to users
+type OIDCService interface {
+	// Providers returns the providers users can log in with
+	Providers() []model.OIDCProvider
+
+	// BeginLogin starts an authorization request to the provider to log in
+	BeginLogin(ctx context.Context, provider string) (*model.OIDCAuthorizationResponse, error)
+
+	// FinishLogin redeems the authorization code and returns the user the external account is linked to,
+	// creating one on the first login
+	FinishLogin(ctx context.Context, provider, code, state string) (*model.User, error)
+
+	// BeginLink starts an authorization request to the provider to link an account to the user
+	BeginLink(ctx context.Context, userID uuid.UUID, provider string) (*model.OIDCAuthorizationResponse, error)
+
+	// FinishLink redeems the authorization code and links the external account to the user
+	FinishLink(ctx context.Context, userID uuid.UUID, provider, code, state string) (*model.UserIdentity, error)
+
+	// ListIdentities retrieves the external accounts linked to the user
+	ListIdentities(ctx context.Context, userID uuid.UUID) ([]model.UserIdentity, error)
+
+	// Unlink removes a linked external account of the user
+	Unlink(ctx context.Context, userID, id uuid.UUID) error
+
+	// PurgeExpired deletes expired states and returns how many were deleted
+	PurgeExpired(ctx context.Context) (int64, error)
+}
+
+// DefaultOIDCService implements the OIDCService interface with the authorization code flow and PKCE
+type DefaultOIDCService struct {
+	userService  UserService
+	userRepo     repository.UserRepository
+	identityRepo repository.IdentityRepository
+	providers    map[string]*oidcProvider
+	config       *config.OIDCConfig
+	logger       *zap.Logger
+}
+
+// NewOIDCService creates a new DefaultOIDCService instance
+// If client is nil, a client with the configured timeout is used for requests to the providers
+func NewOIDCService(
+	userService UserService,
+	userRepo repository.UserRepository,
+	identityRepo repository.IdentityRepository,
+	client *http.Client,
+	cfg *config.OIDCConfig,
+	logger *zap.Logger,
+) OIDCService {
+	if client == nil {
+		client = &http.Client{Timeout: cfg.HTTPTimeout}
+	}
+	providers := make(map[string]*oidcProvider, len(cfg.Providers))
+	for _, providerConfig := range cfg.Providers {
+		providers[providerConfig.Name] = newOIDCProvider(providerConfig, client)
+	}
+	return &DefaultOIDCService{
+		userService:  userService,
+		userRepo:     userRepo,
+		identityRepo: identityRepo,
+		providers:    providers,
+		config:       cfg,
+		logger:       logger,
+	}
+}
+
+// Providers returns the providers users can log in with, in the configured order
+func (s *DefaultOIDCService) Providers() []model.OIDCProvider {
+	providers := make([]model.OIDCProvider, len(s.config.Providers))
+	for i, providerConfig := range s.config.Providers {
+		providers[i] = model.OIDCProvider{Name: providerConfig.Name}
+	}
+	return providers
+}
+
+// BeginLogin starts an authorization request to the provider to log in
+func (s *DefaultOIDCService) BeginLogin(ctx context.Context, provider string) (*model.OIDCAuthorizationResponse, error) {
+	return s.authorize(ctx, provider, nil)
+}
+
+// FinishLogin redeems the authorization code and returns the user the external account is linked to
+// On the first login a user is created with the email address the provider verified, unless it is taken:
+// an existing account is never linked by email, as that would hand it to whoever controls the external account
+func (s *DefaultOIDCService) FinishLogin(ctx context.Context, provider, code, state string) (*model.User, error) {
+	claims, err := s.authenticate(ctx, provider, code, state, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := s.identityRepo.GetIdentity(ctx, provider, claims.Subject)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s.createUser(ctx, provider, claims)
+	}
+	if err != nil {
+		s.logger.Error("failed to get identity",
+			zap.String("provider", provider),
+			zap.Error(err))
+		return nil, err
+	}
+
+	if err := s.identityRepo.UpdateIdentityLogin(ctx, identity.ID, claims.Email); err != nil {
+		s.logger.Error("failed to record login with identity",
+			zap.String("identity_id", identity.ID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, identity.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		s.logger.Error("failed to get user",
+			zap.String("user_id", identity.UserID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("user logged in with external identity",
+		zap.String("user_id", user.ID.String()),
+		zap.String("provider", provider))
+	return user, nil
+}
+
+// createUser creates a user for the first login with an external account
+func (s *DefaultOIDCService) createUser(ctx context.Context, provider string, claims *oidcIDTokenClaims) (*model.User, error) {
+	if claims.Email == "" || !claims.EmailVerified {
+		s.logger.Warn("first login with external identity without verified email",
+			zap.String("provider", provider))
+		return nil, ErrOIDCEmailNotVerified
+	}
+
+	identity := &model.UserIdentity{Provider: provider, Subject: claims.Subject, Email: claims.Email}
+	user, err := s.userService.CreateExternalUser(ctx, claims.Email, claims.Name, identity)
+	if errors.Is(err, ErrEmailAlreadyExists) {
+		return nil, ErrOIDCAccountExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// BeginLink starts an authorization request to the provider to link an account to the user
+func (s *DefaultOIDCService) BeginLink(ctx context.Context, userID uuid.UUID, provider string) (*model.OIDCAuthorizationResponse, error) {
+	return s.authorize(ctx, provider, &userID)
+}
+
+// FinishLink redeems the authorization code and links the external account to the user
+func (s *DefaultOIDCService) FinishLink(ctx context.Context, userID uuid.UUID, provider, code, state string) (*model.UserIdentity, error) {
+	claims, err := s.authenticate(ctx, provider, code, state, &userID)
+	if err != nil {
+		return nil, err
+	}
+
+	identity := &model.UserIdentity{UserID: userID, Provider: provider, Subject: claims.Subject, Email: claims.Email}
+	err = s.identityRepo.CreateIdentity(ctx, identity)
+	if errors.Is(err, repository.ErrDuplicateIdentity) {
+		s.logger.Warn("attempt to link identity that is already linked",
+			zap.String("user_id", userID.String()),
+			zap.String("provider", provider))
+		return nil, ErrIdentityAlreadyLinked
+	}
+	if err != nil {
+		s.logger.Error("failed to link identity",
+			zap.String("user_id", userID.String()),
+			zap.String("provider", provider),
+			zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("identity linked successfully",
+		zap.String("user_id", userID.String()),
+		zap.String("provider", provider),
+		zap.String("identity_id", identity.ID.String()))
+	return identity, nil
+}
+
+// ListIdentities retrieves the external accounts linked to the user
+func (s *DefaultOIDCService) ListIdentities(ctx context.Context, userID uuid.UUID) ([]model.UserIdentity, error) {
+	identities, err := s.identityRepo.GetIdentitiesByUserID(ctx, userID)
+	if err != nil {
+		s.logger.Error("failed to get identities",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	return identities, nil
+}
+
+// Unlink removes a linked external account of the user
+// A user without a password has to keep an identity to log in with
+func (s *DefaultOIDCService) Unlink(ctx context.Context, userID, id uuid.UUID) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		s.logger.Error("failed to get user",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return err
+	}
+
+	identities, err := s.ListIdentities(ctx, userID)
+	if err != nil {
+		return err
+	}
+	linked := false
+	for _, identity := range identities {
+		linked = linked || identity.ID == id
+	}
+	if !linked {
+		return ErrIdentityNotFound
+	}
+	if !user.HasPassword() && len(identities) == 1 {
+		s.logger.Warn("attempt to unlink the only login method",
+			zap.String("user_id", userID.String()))
+		return ErrLastLoginMethod
+	}
+
+	deleted, err := s.identityRepo.DeleteIdentity(ctx, userID, id)
+	if err != nil {
+		s.logger.Error("failed to unlink identity",
+			zap.String("user_id", userID.String()),
+			zap.String("identity_id", id.String()),
+			zap.Error(err))
+		return err
+	}
+	if !deleted {
+		return ErrIdentityNotFound
+	}
+
+	s.logger.Info("identity unlinked successfully",
+		zap.String("user_id", userID.String()),
+		zap.String("identity_id", id.String()))
+	return nil
+}
+
+// PurgeExpired deletes expired states and returns how many were deleted
+func (s *DefaultOIDCService) PurgeExpired(ctx context.Context) (int64, error) {
+	purged, err := s.identityRepo.DeleteExpiredStates(ctx)
+	if err != nil {
+		s.logger.Error("failed to purge expired OpenID Connect states", zap.Error(err))
+		return 0, err
+	}
+
+	if purged > 0 {
+		s.logger.Info("purged expired OpenID Connect states", zap.Int64("count", purged))
+	}
+	return purged, nil
+}
+
+// authorize stores a new state with its PKCE verifier and nonce, and returns the authorization request for it
+func (s *DefaultOIDCService) authorize(ctx context.Context, provider string, userID *uuid.UUID) (*model.OIDCAuthorizationResponse, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return nil, ErrOIDCProviderNotFound
+	}
+
+	state, err := randomOIDCToken()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomOIDCToken()
+	if err != nil {
+		return nil, err
+	}
+	codeVerifier, err := randomOIDCToken()
+	if err != nil {
+		return nil, err
+	}
+
+	authorizationURL, err := p.authorizationURL(ctx, state, nonce, codeVerifier)
+	if err != nil {
+		s.logger.Error("failed to discover OpenID Connect provider",
+			zap.String("provider", provider),
+			zap.Error(err))
+		return nil, err
+	}
+
+	stateHash := sha256.Sum256([]byte(state))
+	err = s.identityRepo.CreateState(ctx, &model.OIDCState{
+		StateHash:    stateHash[:],
+		Provider:     provider,
+		CodeVerifier: codeVerifier,
+		Nonce:        nonce,
+		UserID:       userID,
+	}, s.config.StateTTL)
+	if err != nil {
+		s.logger.Error("failed to store OpenID Connect state",
+			zap.String("provider", provider),
+			zap.Error(err))
+		return nil, err
+	}
+
+	return &model.OIDCAuthorizationResponse{AuthorizationURL: authorizationURL, State: state}, nil
+}
+
+// authenticate consumes the state, redeems the authorization code and returns the verified claims of the ID token
+// A state issued to link a provider to a user completes only that link, and one issued to log in only a login
+func (s *DefaultOIDCService) authenticate(ctx context.Context, provider, code, state string, userID *uuid.UUID) (*oidcIDTokenClaims, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return nil, ErrOIDCProviderNotFound
+	}
+
+	stateHash := sha256.Sum256([]byte(state))
+	stored, err := s.identityRepo.ConsumeState(ctx, stateHash[:], provider)
+	if errors.Is(err, sql.ErrNoRows) {
+		s.logger.Warn("OpenID Connect callback with unknown state",
+			zap.String("provider", provider))
+		return nil, ErrInvalidOIDCState
+	}
+	if err != nil {
+		s.logger.Error("failed to consume OpenID Connect state",
+			zap.String("provider", provider),
+			zap.Error(err))
+		return nil, err
+	}
+	if (stored.UserID == nil) != (userID == nil) || (userID != nil && *stored.UserID != *userID) {
+		s.logger.Warn("OpenID Connect callback with state issued for another request",
+			zap.String("provider", provider))
+		return nil, ErrInvalidOIDCState
+	}
+
+	rawIDToken, err := p.exchange(ctx, code, stored.CodeVerifier)
+	if errors.Is(err, errOIDCCodeRejected) {
+		s.logger.Warn("authorization code rejected",
+			zap.String("provider", provider))
+		return nil, ErrOIDCAuthenticationFailed
+	}
+	if err != nil {
+		s.logger.Error("failed to redeem authorization code",
+			zap.String("provider", provider),
+			zap.Error(err))
+		return nil, err
+	}
+
+	claims, err := p.verifyIDToken(ctx, rawIDToken, stored.Nonce)
+	if errors.Is(err, errInvalidIDToken) {
+		s.logger.Warn("ID token failed verification",
+			zap.String("provider", provider),
+			zap.Error(err))
+		return nil, ErrOIDCAuthenticationFailed
+	}
+	if err != nil {
+		s.logger.Error("failed to verify ID token",
+			zap.String("provider", provider),
+			zap.Error(err))
+		return nil, err
+	}
+
+	return claims, nil
+}
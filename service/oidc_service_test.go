@@ -0,0 +1,661 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+	"github.com/yukimaterrace/todoms/service"
+	"go.uber.org/zap"
+)
+
+// newOIDCService creates an OIDCService with the mocks, logging in with the mock provider when it is given
+func newOIDCService(userRepo *MockUserRepository, identityRepo *MockIdentityRepository, provider *mockOIDCProvider) service.OIDCService {
+	logger := zap.NewNop()
+	todoService := service.NewTodoService(new(MockTodoRepository), new(MockTodoShareRepository), newMockActivityRepository(),
+		new(MockTransactor), config.DefaultTodoQuotaConfig(), logger)
+	passwordPolicy := service.NewPasswordPolicy(nil, config.DefaultPasswordPolicyConfig(), logger)
+	userService := service.NewUserService(todoService, passwordPolicy, newTestPasswordHasher(), userRepo, new(MockUserTokenRepository), identityRepo,
+		newMockActivityRepository(), new(MockTransactor), config.DefaultAuthConfig(), logger)
+
+	cfg := config.DefaultOIDCConfig()
+	if provider != nil {
+		cfg.Providers = []config.OIDCProviderConfig{provider.providerConfig()}
+	}
+	return service.NewOIDCService(userService, userRepo, identityRepo, nil, cfg, logger)
+}
+
+// expectOIDCState expects a state for the provider to be issued and lets it be consumed at most once
+// Consuming any other state fails as it would in the database
+func expectOIDCState(identityRepo *MockIdentityRepository, provider string) {
+	issued := new(model.OIDCState)
+	identityRepo.On("CreateState", mock.Anything, mock.MatchedBy(func(state *model.OIDCState) bool {
+		return state.Provider == provider
+	}), 10*time.Minute).Run(func(args mock.Arguments) {
+		*issued = *args.Get(1).(*model.OIDCState)
+	}).Return(nil).Once()
+	consumed := false
+	identityRepo.On("ConsumeState", mock.Anything, mock.MatchedBy(func(stateHash []byte) bool {
+		return !consumed && bytes.Equal(stateHash, issued.StateHash)
+	}), provider).Run(func(mock.Arguments) {
+		consumed = true
+	}).Return(issued, nil).Maybe()
+	identityRepo.On("ConsumeState", mock.Anything, mock.Anything, mock.Anything).Return(nil, sql.ErrNoRows).Maybe()
+}
+
+// mockOIDCProvider is a local OpenID Connect provider supporting the authorization code flow with PKCE
+type mockOIDCProvider struct {
+	server       *httptest.Server
+	key          *rsa.PrivateKey
+	clientID     string
+	clientSecret string
+	redirectURL  string
+
+	// The account that logs in at the provider
+	subject       string
+	email         string
+	emailVerified bool
+	name          string
+
+	// modifyIDToken changes the claims of the ID tokens the provider issues, when set
+	modifyIDToken func(claims jwt.MapClaims)
+
+	// signingKey signs the ID tokens instead of the published key, when set
+	signingKey *rsa.PrivateKey
+
+	mu       sync.Mutex
+	requests map[string]url.Values
+}
+
+// newMockOIDCProvider starts a mock provider, with an account whose email address is verified
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	p := &mockOIDCProvider{
+		key:           key,
+		clientID:      "todoms-client",
+		clientSecret:  "client secret/with+symbols",
+		redirectURL:   "https://app.example.com/oidc/callback",
+		subject:       "provider-user-1",
+		email:         "social@example.com",
+		emailVerified: true,
+		name:          "Social User",
+		requests:      map[string]url.Values{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", p.handleDiscovery)
+	mux.HandleFunc("GET /jwks", p.handleJWKS)
+	mux.HandleFunc("POST /token", p.handleToken)
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+// providerConfig returns the configuration of the provider as the client registered with it
+func (p *mockOIDCProvider) providerConfig() config.OIDCProviderConfig {
+	return config.OIDCProviderConfig{
+		Name:         "mock",
+		Issuer:       p.server.URL,
+		ClientID:     p.clientID,
+		ClientSecret: p.clientSecret,
+		RedirectURL:  p.redirectURL,
+	}
+}
+
+// authorize lets the account log in at the authorization endpoint,
+// returning the code and state the browser is redirected back with
+func (p *mockOIDCProvider) authorize(t *testing.T, authorizationURL string) (string, string) {
+	u, err := url.Parse(authorizationURL)
+	require.NoError(t, err)
+	require.Equal(t, p.server.URL+"/authorize", u.Scheme+"://"+u.Host+u.Path)
+
+	query := u.Query()
+	require.Equal(t, "code", query.Get("response_type"))
+	require.Equal(t, p.clientID, query.Get("client_id"))
+	require.Equal(t, p.redirectURL, query.Get("redirect_uri"))
+	require.Equal(t, "S256", query.Get("code_challenge_method"))
+	require.Contains(t, strings.Fields(query.Get("scope")), "openid")
+	require.NotEmpty(t, query.Get("nonce"))
+
+	code := uuid.NewString()
+	p.mu.Lock()
+	p.requests[code] = query
+	p.mu.Unlock()
+	return code, query.Get("state")
+}
+
+func (p *mockOIDCProvider) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                 p.server.URL,
+		"authorization_endpoint": p.server.URL + "/authorize",
+		"token_endpoint":         p.server.URL + "/token",
+		"jwks_uri":               p.server.URL + "/jwks",
+	})
+}
+
+func (p *mockOIDCProvider) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "key-1",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+		}},
+	})
+}
+
+// handleToken redeems an authorization code once, with the client credentials and the PKCE verifier
+func (p *mockOIDCProvider) handleToken(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	clientID, _ = url.QueryUnescape(clientID)
+	clientSecret, _ = url.QueryUnescape(clientSecret)
+	if !ok || clientID != p.clientID || clientSecret != p.clientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	p.mu.Lock()
+	request, ok := p.requests[r.PostFormValue("code")]
+	delete(p.requests, r.PostFormValue("code"))
+	p.mu.Unlock()
+
+	verifierHash := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !ok || r.PostFormValue("grant_type") != "authorization_code" ||
+		r.PostFormValue("redirect_uri") != request.Get("redirect_uri") ||
+		base64.RawURLEncoding.EncodeToString(verifierHash[:]) != request.Get("code_challenge") {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            p.server.URL,
+		"aud":            p.clientID,
+		"sub":            p.subject,
+		"email":          p.email,
+		"email_verified": p.emailVerified,
+		"name":           p.name,
+		"nonce":          request.Get("nonce"),
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+	}
+	if p.modifyIDToken != nil {
+		p.modifyIDToken(claims)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "key-1"
+	signingKey := p.key
+	if p.signingKey != nil {
+		signingKey = p.signingKey
+	}
+	idToken, err := token.SignedString(signingKey)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": "provider-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+// writeJSON writes a JSON response of the mock provider
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func TestOIDCBeginLogin(t *testing.T) {
+	provider := newMockOIDCProvider(t)
+
+	t.Run("Success", func(t *testing.T) {
+		identityRepo := new(MockIdentityRepository)
+		expectOIDCState(identityRepo, "mock")
+
+		authorization, err := newOIDCService(new(MockUserRepository), identityRepo, provider).
+			BeginLogin(context.Background(), "mock")
+
+		require.NoError(t, err)
+		_, state := provider.authorize(t, authorization.AuthorizationURL)
+		assert.Equal(t, authorization.State, state)
+
+		// Only the hash of the state is stored, with the verifier of the challenge sent to the provider
+		u, err := url.Parse(authorization.AuthorizationURL)
+		require.NoError(t, err)
+		stateHash := sha256.Sum256([]byte(state))
+		identityRepo.AssertCalled(t, "CreateState", mock.Anything, mock.MatchedBy(func(stored *model.OIDCState) bool {
+			verifierHash := sha256.Sum256([]byte(stored.CodeVerifier))
+			return bytes.Equal(stored.StateHash, stateHash[:]) && stored.UserID == nil &&
+				stored.Nonce == u.Query().Get("nonce") &&
+				base64.RawURLEncoding.EncodeToString(verifierHash[:]) == u.Query().Get("code_challenge")
+		}), 10*time.Minute)
+	})
+
+	t.Run("Unknown provider", func(t *testing.T) {
+		identityRepo := new(MockIdentityRepository)
+
+		authorization, err := newOIDCService(new(MockUserRepository), identityRepo, provider).
+			BeginLogin(context.Background(), "unknown")
+
+		assert.Equal(t, service.ErrOIDCProviderNotFound, err)
+		assert.Nil(t, authorization)
+		identityRepo.AssertNotCalled(t, "CreateState", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Configuration of another issuer", func(t *testing.T) {
+		identityRepo := new(MockIdentityRepository)
+		cfg := config.DefaultOIDCConfig()
+		providerConfig := provider.providerConfig()
+		providerConfig.Issuer = provider.server.URL + "/"
+		cfg.Providers = []config.OIDCProviderConfig{providerConfig}
+		oidcService := service.NewOIDCService(nil, new(MockUserRepository), identityRepo, nil, cfg, zap.NewNop())
+
+		authorization, err := oidcService.BeginLogin(context.Background(), "mock")
+
+		assert.Error(t, err)
+		assert.Nil(t, authorization)
+		identityRepo.AssertNotCalled(t, "CreateState", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestOIDCFinishLogin(t *testing.T) {
+	linkedUser := newUserWithPassword(t, "password123")
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name string
+		// setupProvider changes the account or the ID tokens of the provider
+		setupProvider func(*mockOIDCProvider)
+		// respond changes the code and state the browser is redirected back with
+		respond       func(code, state string) (string, string)
+		setupMocks    func(*MockUserRepository, *MockIdentityRepository)
+		expectedError error
+	}{
+		{
+			name: "Linked identity",
+			setupMocks: func(userRepo *MockUserRepository, identityRepo *MockIdentityRepository) {
+				identity := &model.UserIdentity{ID: uuid.New(), UserID: linkedUser.ID, Provider: "mock", Subject: "provider-user-1"}
+				identityRepo.On("GetIdentity", mock.Anything, "mock", "provider-user-1").Return(identity, nil)
+				identityRepo.On("UpdateIdentityLogin", mock.Anything, identity.ID, "social@example.com").Return(nil)
+				userRepo.On("GetByID", mock.Anything, linkedUser.ID).Return(linkedUser, nil)
+			},
+		},
+		{
+			name: "First login creates a user",
+			setupMocks: func(userRepo *MockUserRepository, identityRepo *MockIdentityRepository) {
+				identityRepo.On("GetIdentity", mock.Anything, "mock", "provider-user-1").Return(nil, sql.ErrNoRows)
+				userRepo.On("GetByEmail", mock.Anything, "social@example.com").Return(nil, sql.ErrNoRows)
+				userRepo.On("Create", mock.Anything, mock.MatchedBy(func(user *model.User) bool {
+					return user.Email == "social@example.com" && !user.HasPassword() && user.DisplayName == "Social User"
+				})).Return(nil)
+				userRepo.On("MarkEmailVerified", mock.Anything, mock.Anything).Return(nil)
+				identityRepo.On("CreateIdentity", mock.Anything, mock.MatchedBy(func(identity *model.UserIdentity) bool {
+					return identity.Provider == "mock" && identity.Subject == "provider-user-1" && identity.UserID != uuid.Nil
+				})).Return(nil)
+				verifiedAt := time.Now()
+				created := &model.User{Email: "social@example.com", EmailVerifiedAt: &verifiedAt}
+				userRepo.On("GetByID", mock.Anything, mock.Anything).Return(created, nil)
+			},
+		},
+		{
+			name: "Email address of an existing account",
+			setupMocks: func(userRepo *MockUserRepository, identityRepo *MockIdentityRepository) {
+				identityRepo.On("GetIdentity", mock.Anything, "mock", "provider-user-1").Return(nil, sql.ErrNoRows)
+				userRepo.On("GetByEmail", mock.Anything, "social@example.com").Return(linkedUser, nil)
+			},
+			expectedError: service.ErrOIDCAccountExists,
+		},
+		{
+			name: "First login without a verified email address",
+			setupProvider: func(p *mockOIDCProvider) {
+				p.emailVerified = false
+			},
+			setupMocks: func(userRepo *MockUserRepository, identityRepo *MockIdentityRepository) {
+				identityRepo.On("GetIdentity", mock.Anything, "mock", "provider-user-1").Return(nil, sql.ErrNoRows)
+			},
+			expectedError: service.ErrOIDCEmailNotVerified,
+		},
+		{
+			name: "Email verified as a string",
+			setupProvider: func(p *mockOIDCProvider) {
+				p.modifyIDToken = func(claims jwt.MapClaims) {
+					claims["email_verified"] = "true"
+				}
+			},
+			setupMocks: func(userRepo *MockUserRepository, identityRepo *MockIdentityRepository) {
+				identity := &model.UserIdentity{ID: uuid.New(), UserID: linkedUser.ID}
+				identityRepo.On("GetIdentity", mock.Anything, "mock", "provider-user-1").Return(identity, nil)
+				identityRepo.On("UpdateIdentityLogin", mock.Anything, identity.ID, "social@example.com").Return(nil)
+				userRepo.On("GetByID", mock.Anything, linkedUser.ID).Return(linkedUser, nil)
+			},
+		},
+		{
+			name: "Nonce of another request",
+			setupProvider: func(p *mockOIDCProvider) {
+				p.modifyIDToken = func(claims jwt.MapClaims) {
+					claims["nonce"] = "other-nonce"
+				}
+			},
+			setupMocks:    func(*MockUserRepository, *MockIdentityRepository) {},
+			expectedError: service.ErrOIDCAuthenticationFailed,
+		},
+		{
+			name: "ID token issued to another client",
+			setupProvider: func(p *mockOIDCProvider) {
+				p.modifyIDToken = func(claims jwt.MapClaims) {
+					claims["aud"] = "other-client"
+				}
+			},
+			setupMocks:    func(*MockUserRepository, *MockIdentityRepository) {},
+			expectedError: service.ErrOIDCAuthenticationFailed,
+		},
+		{
+			name: "ID token for several clients authorized to another",
+			setupProvider: func(p *mockOIDCProvider) {
+				p.modifyIDToken = func(claims jwt.MapClaims) {
+					claims["aud"] = []string{p.clientID, "other-client"}
+					claims["azp"] = "other-client"
+				}
+			},
+			setupMocks:    func(*MockUserRepository, *MockIdentityRepository) {},
+			expectedError: service.ErrOIDCAuthenticationFailed,
+		},
+		{
+			name: "ID token of another issuer",
+			setupProvider: func(p *mockOIDCProvider) {
+				p.modifyIDToken = func(claims jwt.MapClaims) {
+					claims["iss"] = "https://evil.example"
+				}
+			},
+			setupMocks:    func(*MockUserRepository, *MockIdentityRepository) {},
+			expectedError: service.ErrOIDCAuthenticationFailed,
+		},
+		{
+			name: "Expired ID token",
+			setupProvider: func(p *mockOIDCProvider) {
+				p.modifyIDToken = func(claims jwt.MapClaims) {
+					claims["exp"] = time.Now().Add(-time.Hour).Unix()
+				}
+			},
+			setupMocks:    func(*MockUserRepository, *MockIdentityRepository) {},
+			expectedError: service.ErrOIDCAuthenticationFailed,
+		},
+		{
+			name: "ID token signed with another key",
+			setupProvider: func(p *mockOIDCProvider) {
+				p.signingKey = otherKey
+			},
+			setupMocks:    func(*MockUserRepository, *MockIdentityRepository) {},
+			expectedError: service.ErrOIDCAuthenticationFailed,
+		},
+		{
+			name: "Code rejected by the provider",
+			respond: func(code, state string) (string, string) {
+				return "forged-code", state
+			},
+			setupMocks:    func(*MockUserRepository, *MockIdentityRepository) {},
+			expectedError: service.ErrOIDCAuthenticationFailed,
+		},
+		{
+			name: "State that was not issued",
+			respond: func(code, state string) (string, string) {
+				return code, "forged-state"
+			},
+			setupMocks:    func(*MockUserRepository, *MockIdentityRepository) {},
+			expectedError: service.ErrInvalidOIDCState,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := newMockOIDCProvider(t)
+			if tc.setupProvider != nil {
+				tc.setupProvider(provider)
+			}
+			userRepo := new(MockUserRepository)
+			identityRepo := new(MockIdentityRepository)
+			expectOIDCState(identityRepo, "mock")
+			tc.setupMocks(userRepo, identityRepo)
+			oidcService := newOIDCService(userRepo, identityRepo, provider)
+
+			authorization, err := oidcService.BeginLogin(context.Background(), "mock")
+			require.NoError(t, err)
+			code, state := provider.authorize(t, authorization.AuthorizationURL)
+			if tc.respond != nil {
+				code, state = tc.respond(code, state)
+			}
+
+			user, err := oidcService.FinishLogin(context.Background(), "mock", code, state)
+
+			if tc.expectedError != nil {
+				assert.Equal(t, tc.expectedError, err)
+				assert.Nil(t, user)
+				identityRepo.AssertNotCalled(t, "CreateIdentity", mock.Anything, mock.Anything)
+			} else {
+				require.NoError(t, err)
+				assert.NotNil(t, user)
+			}
+			userRepo.AssertExpectations(t)
+			identityRepo.AssertExpectations(t)
+		})
+	}
+
+	t.Run("State is used once", func(t *testing.T) {
+		provider := newMockOIDCProvider(t)
+		userRepo := new(MockUserRepository)
+		identityRepo := new(MockIdentityRepository)
+		expectOIDCState(identityRepo, "mock")
+		identity := &model.UserIdentity{ID: uuid.New(), UserID: linkedUser.ID}
+		identityRepo.On("GetIdentity", mock.Anything, "mock", "provider-user-1").Return(identity, nil).Once()
+		identityRepo.On("UpdateIdentityLogin", mock.Anything, identity.ID, mock.Anything).Return(nil).Once()
+		userRepo.On("GetByID", mock.Anything, linkedUser.ID).Return(linkedUser, nil).Once()
+		oidcService := newOIDCService(userRepo, identityRepo, provider)
+
+		authorization, err := oidcService.BeginLogin(context.Background(), "mock")
+		require.NoError(t, err)
+		code, state := provider.authorize(t, authorization.AuthorizationURL)
+
+		_, err = oidcService.FinishLogin(context.Background(), "mock", code, state)
+		require.NoError(t, err)
+		_, err = oidcService.FinishLogin(context.Background(), "mock", code, state)
+		assert.Equal(t, service.ErrInvalidOIDCState, err)
+	})
+
+	t.Run("State issued to link a provider", func(t *testing.T) {
+		provider := newMockOIDCProvider(t)
+		identityRepo := new(MockIdentityRepository)
+		expectOIDCState(identityRepo, "mock")
+		oidcService := newOIDCService(new(MockUserRepository), identityRepo, provider)
+
+		authorization, err := oidcService.BeginLink(context.Background(), linkedUser.ID, "mock")
+		require.NoError(t, err)
+		code, state := provider.authorize(t, authorization.AuthorizationURL)
+
+		user, err := oidcService.FinishLogin(context.Background(), "mock", code, state)
+
+		assert.Equal(t, service.ErrInvalidOIDCState, err)
+		assert.Nil(t, user)
+	})
+}
+
+func TestOIDCFinishLink(t *testing.T) {
+	userID := uuid.New()
+
+	testCases := []struct {
+		name string
+		// linkingUserID is the user who completes the link
+		linkingUserID uuid.UUID
+		setupMocks    func(*MockIdentityRepository)
+		expectedError error
+	}{
+		{
+			name:          "Success",
+			linkingUserID: userID,
+			setupMocks: func(identityRepo *MockIdentityRepository) {
+				identityRepo.On("CreateIdentity", mock.Anything, mock.MatchedBy(func(identity *model.UserIdentity) bool {
+					return identity.UserID == userID && identity.Provider == "mock" &&
+						identity.Subject == "provider-user-1" && identity.Email == "social@example.com"
+				})).Return(nil)
+			},
+		},
+		{
+			name:          "Already linked",
+			linkingUserID: userID,
+			setupMocks: func(identityRepo *MockIdentityRepository) {
+				identityRepo.On("CreateIdentity", mock.Anything, mock.Anything).Return(repository.ErrDuplicateIdentity)
+			},
+			expectedError: service.ErrIdentityAlreadyLinked,
+		},
+		{
+			name:          "State issued to another user",
+			linkingUserID: uuid.New(),
+			setupMocks:    func(*MockIdentityRepository) {},
+			expectedError: service.ErrInvalidOIDCState,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := newMockOIDCProvider(t)
+			identityRepo := new(MockIdentityRepository)
+			expectOIDCState(identityRepo, "mock")
+			tc.setupMocks(identityRepo)
+			oidcService := newOIDCService(new(MockUserRepository), identityRepo, provider)
+
+			authorization, err := oidcService.BeginLink(context.Background(), userID, "mock")
+			require.NoError(t, err)
+			code, state := provider.authorize(t, authorization.AuthorizationURL)
+
+			identity, err := oidcService.FinishLink(context.Background(), tc.linkingUserID, "mock", code, state)
+
+			if tc.expectedError != nil {
+				assert.Equal(t, tc.expectedError, err)
+				assert.Nil(t, identity)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, "mock", identity.Provider)
+			}
+			identityRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUnlinkIdentity(t *testing.T) {
+	withPassword := newUserWithPassword(t, "password123")
+	withoutPassword := &model.User{ID: uuid.New(), Email: "social@example.com"}
+	identity := model.UserIdentity{ID: uuid.New(), Provider: "mock"}
+	otherIdentity := model.UserIdentity{ID: uuid.New(), Provider: "other"}
+
+	testCases := []struct {
+		name          string
+		user          *model.User
+		identities    []model.UserIdentity
+		expectDelete  bool
+		expectedError error
+	}{
+		{name: "User with a password", user: withPassword, identities: []model.UserIdentity{identity}, expectDelete: true},
+		{name: "User with another identity", user: withoutPassword, identities: []model.UserIdentity{identity, otherIdentity}, expectDelete: true},
+		{name: "Only way to log in", user: withoutPassword, identities: []model.UserIdentity{identity}, expectedError: service.ErrLastLoginMethod},
+		{name: "Not linked", user: withPassword, identities: []model.UserIdentity{otherIdentity}, expectedError: service.ErrIdentityNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			userRepo := new(MockUserRepository)
+			identityRepo := new(MockIdentityRepository)
+			userRepo.On("GetByID", mock.Anything, tc.user.ID).Return(tc.user, nil)
+			identityRepo.On("GetIdentitiesByUserID", mock.Anything, tc.user.ID).Return(tc.identities, nil)
+			if tc.expectDelete {
+				identityRepo.On("DeleteIdentity", mock.Anything, tc.user.ID, identity.ID).Return(true, nil)
+			}
+
+			err := newOIDCService(userRepo, identityRepo, nil).Unlink(context.Background(), tc.user.ID, identity.ID)
+
+			assert.Equal(t, tc.expectedError, err)
+			identityRepo.AssertExpectations(t)
+			if !tc.expectDelete {
+				identityRepo.AssertNotCalled(t, "DeleteIdentity", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestAuthenticateOIDC(t *testing.T) {
+	user := newUserWithPassword(t, "password123")
+	verifiedAt := time.Now()
+	user.EmailVerifiedAt = &verifiedAt
+	authConfig := config.NewAuthConfig("test-secret-key", 15*time.Minute, 24*time.Hour)
+
+	// login logs the user in with the mock provider through an AuthenticationService with the MFA repository
+	login := func(t *testing.T, mfaRepo *MockMFARepository) (*service.LoginResult, error) {
+		provider := newMockOIDCProvider(t)
+		userRepo := new(MockUserRepository)
+		identityRepo := new(MockIdentityRepository)
+		expectOIDCState(identityRepo, "mock")
+		identity := &model.UserIdentity{ID: uuid.New(), UserID: user.ID}
+		identityRepo.On("GetIdentity", mock.Anything, "mock", "provider-user-1").Return(identity, nil)
+		identityRepo.On("UpdateIdentityLogin", mock.Anything, identity.ID, mock.Anything).Return(nil)
+		userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+		oidcService := newOIDCService(userRepo, identityRepo, provider)
+		authService := service.NewJWTAuthService(userRepo, newMockSessionRepository(), newTestPasswordHasher(), newMFAService(userRepo, mfaRepo),
+			nil, oidcService, authConfig, zap.NewNop())
+
+		authorization, err := oidcService.BeginLogin(context.Background(), "mock")
+		require.NoError(t, err)
+		code, state := provider.authorize(t, authorization.AuthorizationURL)
+		return authService.AuthenticateOIDC(context.Background(), "mock", code, state)
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		result, err := login(t, newMockMFARepository())
+
+		require.NoError(t, err)
+		assert.False(t, result.MFARequired)
+		claims, err := service.NewJWTAuthService(nil, nil, nil, nil, nil, nil, authConfig, zap.NewNop()).ValidateToken(result.AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, user.ID.String(), claims.UserID)
+	})
+
+	t.Run("MFA is still asked for", func(t *testing.T) {
+		_, enabled := enrollMFA(t, user)
+		mfaRepo := new(MockMFARepository)
+		mfaRepo.On("GetByUserID", mock.Anything, user.ID).Return(enabled, nil)
+		mfaRepo.On("CountRecoveryCodes", mock.Anything, user.ID).Return(10, nil)
+
+		result, err := login(t, mfaRepo)
+
+		require.NoError(t, err)
+		assert.True(t, result.MFARequired)
+		assert.NotEmpty(t, result.ChallengeToken)
+		assert.Nil(t, result.TokenPair)
+	})
+}
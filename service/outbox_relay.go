@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+	"go.uber.org/zap"
+)
+
+// maxOutboxErrorLength is the maximum length of the error recorded for a failed attempt
+const maxOutboxErrorLength = 500
+
+// outboxPruneInterval is how often published events past their retention are pruned
+const outboxPruneInterval = time.Hour
+
+// OutboxRelay publishes the domain events written to the outbox
+// An event is marked as published in the same transaction that claimed it, after the publisher
+// accepted it, so a crash in between publishes it again: delivery is at-least-once
+type OutboxRelay struct {
+	outboxRepo   repository.OutboxRepository
+	transactor   repository.Transactor
+	publisher    EventPublisher
+	outboxConfig *config.OutboxConfig
+	logger       *zap.Logger
+}
+
+// NewOutboxRelay creates a new OutboxRelay instance
+func NewOutboxRelay(outboxRepo repository.OutboxRepository, transactor repository.Transactor, publisher EventPublisher, outboxConfig *config.OutboxConfig, logger *zap.Logger) *OutboxRelay {
+	return &OutboxRelay{
+		outboxRepo:   outboxRepo,
+		transactor:   transactor,
+		publisher:    publisher,
+		outboxConfig: outboxConfig,
+		logger:       logger,
+	}
+}
+
+// Run relays pending events every interval and prunes published events hourly until the context is cancelled
+func (r *OutboxRelay) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	pruneTicker := time.NewTicker(outboxPruneInterval)
+	defer pruneTicker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Keep going while full batches are found, so a backlog drains without waiting for the next tick
+			for {
+				count, err := r.RelayPending(ctx)
+				if err != nil || count < r.outboxConfig.BatchSize {
+					break
+				}
+			}
+		case <-pruneTicker.C:
+			r.PrunePublished(ctx)
+		}
+	}
+}
+
+// RelayPending publishes one batch of pending events and records the results
+// It returns the number of events claimed
+func (r *OutboxRelay) RelayPending(ctx context.Context) (int, error) {
+	var count int
+	err := r.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		events, err := r.outboxRepo.ClaimPending(ctx, r.outboxConfig.BatchSize)
+		if err != nil {
+			return err
+		}
+		count = len(events)
+
+		for i := range events {
+			if err := r.publish(ctx, &events[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		// The claimed events are unlocked by the rollback and relayed again, including those already published
+		r.logger.Error("failed to relay outbox events",
+			zap.Error(err))
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// publish makes one attempt to publish an event and records the result
+// It returns an error only when the result cannot be recorded
+func (r *OutboxRelay) publish(ctx context.Context, event *model.OutboxEvent) error {
+	err := r.publisher.Publish(ctx, event)
+	if err == nil {
+		return r.outboxRepo.MarkPublished(ctx, event.ID)
+	}
+
+	retryAfter := r.backoff(event.Attempts + 1)
+	r.logger.Warn("failed to publish outbox event, will retry",
+		zap.String("event_id", event.ID.String()),
+		zap.String("event_type", event.EventType),
+		zap.Int("attempts", event.Attempts+1),
+		zap.Duration("retry_after", retryAfter),
+		zap.Error(err))
+
+	message := err.Error()
+	if len(message) > maxOutboxErrorLength {
+		message = message[:maxOutboxErrorLength]
+	}
+	return r.outboxRepo.MarkFailed(ctx, event.ID, retryAfter, message)
+}
+
+// PrunePublished removes events published longer ago than the retention period
+func (r *OutboxRelay) PrunePublished(ctx context.Context) {
+	count, err := r.outboxRepo.DeletePublishedOlderThan(ctx, r.outboxConfig.Retention)
+	if err != nil {
+		r.logger.Error("failed to prune published outbox events",
+			zap.Error(err))
+		return
+	}
+	if count > 0 {
+		r.logger.Info("pruned published outbox events",
+			zap.Int64("count", count))
+	}
+}
+
+// backoff returns the delay before the next attempt after the given number of failed attempts
+func (r *OutboxRelay) backoff(attempts int) time.Duration {
+	delay := r.outboxConfig.BaseBackoff
+	for i := 1; i < attempts && delay < r.outboxConfig.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > r.outboxConfig.MaxBackoff {
+		delay = r.outboxConfig.MaxBackoff
+	}
+	return delay
+}
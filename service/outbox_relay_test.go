@@ -0,0 +1,206 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+	"go.uber.org/zap"
+)
+
+// recordingBroker is a MessageBroker that records the messages it receives and fails on demand
+type recordingBroker struct {
+	messages []service.BrokerMessage
+	err      error
+}
+
+func (b *recordingBroker) Publish(ctx context.Context, msg service.BrokerMessage) error {
+	if b.err != nil {
+		return b.err
+	}
+	b.messages = append(b.messages, msg)
+	return nil
+}
+
+func newTestOutboxEvent(eventType string, attempts int) model.OutboxEvent {
+	return model.OutboxEvent{
+		ID:            uuid.New(),
+		AggregateType: model.AggregateTodo,
+		AggregateID:   uuid.New(),
+		EventType:     eventType,
+		Payload:       []byte(`{"id":"todo"}`),
+		Attempts:      attempts,
+		CreatedAt:     time.Date(2025, 4, 20, 10, 30, 0, 0, time.UTC),
+	}
+}
+
+func TestOutboxRelay(t *testing.T) {
+	ctx := context.Background()
+	outboxConfig := &config.OutboxConfig{
+		BatchSize:   10,
+		BaseBackoff: time.Second,
+		MaxBackoff:  10 * time.Second,
+		Retention:   time.Hour,
+	}
+
+	tests := []struct {
+		name           string
+		attempts       int
+		brokerErr      error
+		wantPublished  bool
+		wantRetryAfter time.Duration
+	}{
+		{
+			name:          "published event is marked as published",
+			wantPublished: true,
+		},
+		{
+			name:           "first failure retries after the base backoff",
+			attempts:       0,
+			brokerErr:      errors.New("broker unavailable"),
+			wantRetryAfter: time.Second,
+		},
+		{
+			name:           "repeated failures double the backoff",
+			attempts:       2,
+			brokerErr:      errors.New("broker unavailable"),
+			wantRetryAfter: 4 * time.Second,
+		},
+		{
+			name:           "backoff is capped but the event is never given up",
+			attempts:       20,
+			brokerErr:      errors.New("broker unavailable"),
+			wantRetryAfter: 10 * time.Second,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			event := newTestOutboxEvent(model.DomainEventTodoCreated, tc.attempts)
+			mockOutboxRepo := new(MockOutboxRepository)
+			mockOutboxRepo.On("ClaimPending", mock.Anything, 10).Return([]model.OutboxEvent{event}, nil)
+			mockOutboxRepo.On("MarkPublished", mock.Anything, event.ID).Return(nil)
+			mockOutboxRepo.On("MarkFailed", mock.Anything, event.ID, mock.Anything, mock.Anything).Return(nil)
+			broker := &recordingBroker{err: tc.brokerErr}
+			publisher := service.NewBrokerEventPublisher(broker, "todoms")
+
+			relay := service.NewOutboxRelay(mockOutboxRepo, new(MockTransactor), publisher, outboxConfig, zap.NewNop())
+			count, err := relay.RelayPending(ctx)
+
+			require.NoError(t, err)
+			assert.Equal(t, 1, count)
+			if tc.wantPublished {
+				mockOutboxRepo.AssertCalled(t, "MarkPublished", mock.Anything, event.ID)
+				mockOutboxRepo.AssertNotCalled(t, "MarkFailed", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				mockOutboxRepo.AssertCalled(t, "MarkFailed", mock.Anything, event.ID, tc.wantRetryAfter, "broker unavailable")
+				mockOutboxRepo.AssertNotCalled(t, "MarkPublished", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestOutboxRelayRollsBackWhenResultCannotBeRecorded(t *testing.T) {
+	ctx := context.Background()
+	event := newTestOutboxEvent(model.DomainEventTodoUpdated, 0)
+	mockOutboxRepo := new(MockOutboxRepository)
+	mockOutboxRepo.On("ClaimPending", mock.Anything, mock.Anything).Return([]model.OutboxEvent{event}, nil)
+	mockOutboxRepo.On("MarkPublished", mock.Anything, event.ID).Return(errors.New("connection lost"))
+
+	relay := service.NewOutboxRelay(mockOutboxRepo, new(MockTransactor), service.NewInMemoryEventPublisher(), config.DefaultOutboxConfig(), zap.NewNop())
+	_, err := relay.RelayPending(ctx)
+
+	// The transaction is rolled back, so the event is relayed again
+	assert.Error(t, err)
+}
+
+func TestBrokerEventPublisher(t *testing.T) {
+	broker := &recordingBroker{}
+	publisher := service.NewBrokerEventPublisher(broker, "todoms")
+	event := newTestOutboxEvent(model.DomainEventTodoCompleted, 0)
+
+	require.NoError(t, publisher.Publish(context.Background(), &event))
+
+	require.Len(t, broker.messages, 1)
+	msg := broker.messages[0]
+	assert.Equal(t, "todoms.todo.completed", msg.Topic)
+	assert.Equal(t, event.AggregateID.String(), msg.Key)
+	assert.Equal(t, event.ID.String(), msg.ID)
+	assert.Equal(t, event.ID.String(), msg.Headers[service.EventIdempotencyKeyHeader])
+	assert.Equal(t, model.DomainEventTodoCompleted, msg.Headers[service.EventTypeHeader])
+	assert.Equal(t, "2025-04-20T10:30:00Z", msg.Headers[service.EventOccurredAtHeader])
+	assert.JSONEq(t, `{"id":"todo"}`, string(msg.Value))
+}
+
+func TestInMemoryEventPublisher(t *testing.T) {
+	ctx := context.Background()
+	publisher := service.NewInMemoryEventPublisher()
+
+	var created, all int
+	failing := true
+	publisher.Subscribe(model.DomainEventTodoCreated, func(ctx context.Context, event *model.OutboxEvent) error {
+		created++
+		if failing {
+			return errors.New("handler failed")
+		}
+		return nil
+	})
+	publisher.Subscribe("*", func(ctx context.Context, event *model.OutboxEvent) error {
+		all++
+		return nil
+	})
+
+	event := newTestOutboxEvent(model.DomainEventTodoCreated, 0)
+
+	// A failing handler makes the publish fail, so the relay retries it
+	assert.Error(t, publisher.Publish(ctx, &event))
+	assert.Equal(t, 1, created)
+	assert.Equal(t, 0, all)
+
+	failing = false
+	require.NoError(t, publisher.Publish(ctx, &event))
+	assert.Equal(t, 2, created)
+	assert.Equal(t, 1, all)
+
+	// Publishing the same event again is discarded by its idempotency key
+	require.NoError(t, publisher.Publish(ctx, &event))
+	assert.Equal(t, 2, created)
+	assert.Equal(t, 1, all)
+
+	// Other event types only reach the wildcard handler
+	updated := newTestOutboxEvent(model.DomainEventTodoUpdated, 0)
+	require.NoError(t, publisher.Publish(ctx, &updated))
+	assert.Equal(t, 2, created)
+	assert.Equal(t, 2, all)
+}
+
+func TestMultiEventPublisher(t *testing.T) {
+	ctx := context.Background()
+	first := &recordingBroker{}
+	second := &recordingBroker{}
+	publisher := service.NewMultiEventPublisher(
+		service.NewBrokerEventPublisher(first, "first"),
+		service.NewBrokerEventPublisher(second, "second"),
+	)
+	event := newTestOutboxEvent(model.DomainEventTodoCreated, 0)
+
+	// A failing publisher stops the ones after it, so the relay retries the event
+	first.err = errors.New("broker unavailable")
+	assert.Error(t, publisher.Publish(ctx, &event))
+	assert.Empty(t, second.messages)
+
+	// Nothing is remembered, so the retried event reaches every publisher
+	first.err = nil
+	require.NoError(t, publisher.Publish(ctx, &event))
+	require.NoError(t, publisher.Publish(ctx, &event))
+	assert.Len(t, first.messages, 2)
+	assert.Len(t, second.messages, 2)
+}
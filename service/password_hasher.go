@@ -0,0 +1,237 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/yukimaterrace/todoms/config"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrUnsupportedPasswordHash is returned when checking a password against a hash of an unknown or malformed format
+var ErrUnsupportedPasswordHash = errors.New("unsupported password hash")
+
+// bcryptMaxPasswordLength is the longest password, in bytes, bcrypt hashes in full
+const bcryptMaxPasswordLength = 72
+
+// PasswordHasher defines the interface for hashing passwords
+// Hashes are PHC strings, such as $argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>, which record the algorithm
+// and parameters they were made with
+type PasswordHasher interface {
+	// Hash returns the hash of the password
+	Hash(password string) (string, error)
+
+	// Verify reports whether the password matches the hash
+	Verify(password, hash string) (bool, error)
+
+	// NeedsRehash reports whether the hash was made with another algorithm or other parameters than new hashes
+	NeedsRehash(hash string) bool
+}
+
+// DefaultPasswordHasher implements the PasswordHasher interface
+// New hashes are made with the configured algorithm, and passwords are checked against hashes of any supported algorithm
+type DefaultPasswordHasher struct {
+	current  PasswordHasher
+	argon2id PasswordHasher
+	bcrypt   PasswordHasher
+}
+
+// NewPasswordHasher creates a new DefaultPasswordHasher instance
+func NewPasswordHasher(cfg *config.PasswordHashConfig) PasswordHasher {
+	hasher := &DefaultPasswordHasher{
+		argon2id: NewArgon2idHasher(cfg),
+		bcrypt:   NewBcryptHasher(cfg),
+	}
+	hasher.current = hasher.argon2id
+	if cfg.Algorithm == config.PasswordHashBcrypt {
+		hasher.current = hasher.bcrypt
+	}
+	return hasher
+}
+
+// Hash returns the hash of the password made with the configured algorithm
+func (h *DefaultPasswordHasher) Hash(password string) (string, error) {
+	return h.current.Hash(password)
+}
+
+// Verify reports whether the password matches the hash, whichever supported algorithm made it
+func (h *DefaultPasswordHasher) Verify(password, hash string) (bool, error) {
+	switch {
+	case isArgon2idHash(hash):
+		return h.argon2id.Verify(password, hash)
+	case isBcryptHash(hash):
+		return h.bcrypt.Verify(password, hash)
+	default:
+		return false, ErrUnsupportedPasswordHash
+	}
+}
+
+// NeedsRehash reports whether the hash was made with another algorithm or other parameters than the configured ones
+func (h *DefaultPasswordHasher) NeedsRehash(hash string) bool {
+	return h.current.NeedsRehash(hash)
+}
+
+// Argon2idHasher implements the PasswordHasher interface with Argon2id
+type Argon2idHasher struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	saltLength  uint32
+	keyLength   uint32
+}
+
+// NewArgon2idHasher creates a new Argon2idHasher instance with the Argon2id parameters of cfg
+func NewArgon2idHasher(cfg *config.PasswordHashConfig) PasswordHasher {
+	return &Argon2idHasher{
+		memory:      cfg.Argon2Memory,
+		iterations:  cfg.Argon2Iterations,
+		parallelism: cfg.Argon2Parallelism,
+		saltLength:  cfg.Argon2SaltLength,
+		keyLength:   cfg.Argon2KeyLength,
+	}
+}
+
+// argon2idHash is a parsed Argon2id PHC string
+type argon2idHash struct {
+	version     int
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+// Hash returns the Argon2id hash of the password with a random salt
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.iterations, h.memory, h.parallelism, h.keyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.memory, h.iterations, h.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify reports whether the password matches the Argon2id hash, which is checked with the parameters it records
+func (h *Argon2idHasher) Verify(password, hash string) (bool, error) {
+	parsed, err := parseArgon2idHash(hash)
+	if err != nil {
+		return false, err
+	}
+
+	key := argon2.IDKey([]byte(password), parsed.salt, parsed.iterations, parsed.memory, parsed.parallelism,
+		uint32(len(parsed.key)))
+	return subtle.ConstantTimeCompare(key, parsed.key) == 1, nil
+}
+
+// NeedsRehash reports whether the hash is not an Argon2id hash with the current parameters
+func (h *Argon2idHasher) NeedsRehash(hash string) bool {
+	parsed, err := parseArgon2idHash(hash)
+	if err != nil {
+		return true
+	}
+
+	return parsed.memory != h.memory ||
+		parsed.iterations != h.iterations ||
+		parsed.parallelism != h.parallelism ||
+		uint32(len(parsed.salt)) != h.saltLength ||
+		uint32(len(parsed.key)) != h.keyLength
+}
+
+// isArgon2idHash reports whether the hash looks like an Argon2id PHC string
+func isArgon2idHash(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
+}
+
+// parseArgon2idHash parses an Argon2id PHC string of the version this package implements
+func parseArgon2idHash(hash string) (*argon2idHash, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || !isArgon2idHash(hash) {
+		return nil, ErrUnsupportedPasswordHash
+	}
+
+	parsed := &argon2idHash{}
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &parsed.version); err != nil || parsed.version != argon2.Version {
+		return nil, ErrUnsupportedPasswordHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &parsed.memory, &parsed.iterations, &parsed.parallelism); err != nil {
+		return nil, ErrUnsupportedPasswordHash
+	}
+
+	var err error
+	if parsed.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, ErrUnsupportedPasswordHash
+	}
+	if parsed.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(parsed.key) == 0 {
+		return nil, ErrUnsupportedPasswordHash
+	}
+
+	return parsed, nil
+}
+
+// BcryptHasher implements the PasswordHasher interface with bcrypt
+// Its hashes are in the modular crypt format of bcrypt, such as $2a$10$<salt and hash>, which PHC strings extend
+type BcryptHasher struct {
+	cost int
+}
+
+// NewBcryptHasher creates a new BcryptHasher instance with the bcrypt cost of cfg
+func NewBcryptHasher(cfg *config.PasswordHashConfig) PasswordHasher {
+	return &BcryptHasher{cost: cfg.BcryptCost}
+}
+
+// Hash returns the bcrypt hash of the password
+// bcrypt would only hash the first 72 bytes of a longer password, so one is refused with a *PasswordPolicyError
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	if len(password) > bcryptMaxPasswordLength {
+		return "", &PasswordPolicyError{Violations: []PasswordViolation{{
+			Reason:  PasswordTooLong,
+			Message: fmt.Sprintf("Password must be at most %d bytes", bcryptMaxPasswordLength),
+		}}}
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Verify reports whether the password matches the bcrypt hash
+func (h *BcryptHasher) Verify(password, hash string) (bool, error) {
+	if !isBcryptHash(hash) {
+		return false, ErrUnsupportedPasswordHash
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// NeedsRehash reports whether the hash is not a bcrypt hash with the current cost
+func (h *BcryptHasher) NeedsRehash(hash string) bool {
+	if !isBcryptHash(hash) {
+		return true
+	}
+
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.cost
+}
+
+// isBcryptHash reports whether the hash looks like a bcrypt hash
+func isBcryptHash(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
@@ -0,0 +1,163 @@
+package service_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/service"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testPasswordHashConfig returns a PasswordHashConfig with cheap parameters, which keep the tests fast
+func testPasswordHashConfig() *config.PasswordHashConfig {
+	cfg := config.DefaultPasswordHashConfig()
+	cfg.Argon2Memory = 64
+	cfg.Argon2Iterations = 1
+	cfg.Argon2Parallelism = 1
+	cfg.BcryptCost = bcrypt.MinCost
+	return cfg
+}
+
+// newTestPasswordHasher creates a PasswordHasher with cheap parameters
+func newTestPasswordHasher() service.PasswordHasher {
+	return service.NewPasswordHasher(testPasswordHashConfig())
+}
+
+// passwordMatches reports whether the password matches the hash
+func passwordMatches(hash, password string) bool {
+	match, err := newTestPasswordHasher().Verify(password, hash)
+	return err == nil && match
+}
+
+func TestPasswordHasher(t *testing.T) {
+	password := "amber-falcon-orbit-91"
+
+	bcryptConfig := testPasswordHashConfig()
+	bcryptConfig.Algorithm = config.PasswordHashBcrypt
+	bcryptHash, err := service.NewPasswordHasher(bcryptConfig).Hash(password)
+	require.NoError(t, err)
+
+	argon2idHash, err := newTestPasswordHasher().Hash(password)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name                string
+		configure           func(*config.PasswordHashConfig)
+		hash                string
+		password            string
+		expectedMatch       bool
+		expectedError       error
+		expectedNeedsRehash bool
+	}{
+		{
+			name:          "Argon2id hash",
+			hash:          argon2idHash,
+			password:      password,
+			expectedMatch: true,
+		},
+		{
+			name:     "Argon2id hash with a wrong password",
+			hash:     argon2idHash,
+			password: "wrong-password",
+		},
+		{
+			name:                "Bcrypt hash is checked and upgraded",
+			hash:                bcryptHash,
+			password:            password,
+			expectedMatch:       true,
+			expectedNeedsRehash: true,
+		},
+		{
+			name: "Bcrypt hash kept while bcrypt is configured",
+			configure: func(cfg *config.PasswordHashConfig) {
+				cfg.Algorithm = config.PasswordHashBcrypt
+			},
+			hash:          bcryptHash,
+			password:      password,
+			expectedMatch: true,
+		},
+		{
+			name: "Bcrypt hash with a lower cost",
+			configure: func(cfg *config.PasswordHashConfig) {
+				cfg.Algorithm = config.PasswordHashBcrypt
+				cfg.BcryptCost = bcrypt.MinCost + 1
+			},
+			hash:                bcryptHash,
+			password:            password,
+			expectedMatch:       true,
+			expectedNeedsRehash: true,
+		},
+		{
+			name: "Argon2id hash with other parameters",
+			configure: func(cfg *config.PasswordHashConfig) {
+				cfg.Argon2Iterations = 2
+			},
+			hash:                argon2idHash,
+			password:            password,
+			expectedMatch:       true,
+			expectedNeedsRehash: true,
+		},
+		{
+			name:                "Unknown format",
+			hash:                "$md5$hash",
+			password:            password,
+			expectedError:       service.ErrUnsupportedPasswordHash,
+			expectedNeedsRehash: true,
+		},
+		{
+			name:                "Malformed Argon2id hash",
+			hash:                "$argon2id$v=19$m=64,t=1,p=1$salt",
+			password:            password,
+			expectedError:       service.ErrUnsupportedPasswordHash,
+			expectedNeedsRehash: true,
+		},
+		{
+			name:                "No password",
+			hash:                "",
+			password:            password,
+			expectedError:       service.ErrUnsupportedPasswordHash,
+			expectedNeedsRehash: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := testPasswordHashConfig()
+			if tc.configure != nil {
+				tc.configure(cfg)
+			}
+			hasher := service.NewPasswordHasher(cfg)
+
+			match, err := hasher.Verify(tc.password, tc.hash)
+
+			assert.ErrorIs(t, err, tc.expectedError)
+			assert.Equal(t, tc.expectedMatch, match)
+			assert.Equal(t, tc.expectedNeedsRehash, hasher.NeedsRehash(tc.hash))
+		})
+	}
+
+	t.Run("Argon2id hash is a PHC string", func(t *testing.T) {
+		assert.True(t, strings.HasPrefix(argon2idHash, "$argon2id$v=19$m=64,t=1,p=1$"))
+		assert.Len(t, strings.Split(argon2idHash, "$"), 6)
+	})
+
+	t.Run("Argon2id hashes a long password in full", func(t *testing.T) {
+		long := strings.Repeat("a", 80)
+		hash, err := newTestPasswordHasher().Hash(long)
+		require.NoError(t, err)
+
+		assert.True(t, passwordMatches(hash, long))
+		assert.False(t, passwordMatches(hash, long[:72]))
+	})
+
+	t.Run("Bcrypt refuses a password it would truncate", func(t *testing.T) {
+		_, err := service.NewPasswordHasher(bcryptConfig).Hash(strings.Repeat("a", 73))
+
+		var policyErr *service.PasswordPolicyError
+		require.ErrorAs(t, err, &policyErr)
+		assert.Equal(t, service.PasswordTooLong, policyErr.Violations[0].Reason)
+	})
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/repository"
+	"go.uber.org/zap"
+)
+
+// Reasons a password breaks the password policy
+const (
+	PasswordTooShort         = "too_short"
+	PasswordTooLong          = "too_long"
+	PasswordCharacterClasses = "character_classes"
+	PasswordContainsEmail    = "contains_email"
+	PasswordTooWeak          = "too_weak"
+	PasswordBreached         = "breached"
+)
+
+// PasswordViolation describes a rule of the password policy a password breaks
+type PasswordViolation struct {
+	Reason  string
+	Message string
+}
+
+// PasswordPolicyError is returned when a password breaks the password policy, listing every rule it breaks
+type PasswordPolicyError struct {
+	Violations []PasswordViolation
+}
+
+// Error returns the reasons the password was refused
+func (e *PasswordPolicyError) Error() string {
+	reasons := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		reasons[i] = violation.Reason
+	}
+	return "password does not meet the policy: " + strings.Join(reasons, ", ")
+}
+
+// PasswordPolicy defines the interface for checking new passwords
+type PasswordPolicy interface {
+	// Check returns a *PasswordPolicyError when the password of the user with the email breaks the policy
+	Check(ctx context.Context, password, email string) error
+}
+
+// DefaultPasswordPolicy implements the PasswordPolicy interface
+type DefaultPasswordPolicy struct {
+	breachedStore repository.BreachedPasswordStore
+	config        *config.PasswordPolicyConfig
+	logger        *zap.Logger
+}
+
+// NewPasswordPolicy creates a new DefaultPasswordPolicy instance
+// Passwords are not checked against a breached-password corpus when breachedStore is nil
+func NewPasswordPolicy(
+	breachedStore repository.BreachedPasswordStore,
+	cfg *config.PasswordPolicyConfig,
+	logger *zap.Logger,
+) PasswordPolicy {
+	return &DefaultPasswordPolicy{
+		breachedStore: breachedStore,
+		config:        cfg,
+		logger:        logger,
+	}
+}
+
+// Check returns a *PasswordPolicyError when the password of the user with the email breaks the policy
+func (p *DefaultPasswordPolicy) Check(ctx context.Context, password, email string) error {
+	var violations []PasswordViolation
+
+	length := utf8.RuneCountInString(password)
+	if length < p.config.MinLength {
+		violations = append(violations, PasswordViolation{
+			Reason:  PasswordTooShort,
+			Message: fmt.Sprintf("Password must be at least %d characters", p.config.MinLength),
+		})
+	}
+	if length > p.config.MaxLength {
+		violations = append(violations, PasswordViolation{
+			Reason:  PasswordTooLong,
+			Message: fmt.Sprintf("Password must be at most %d characters", p.config.MaxLength),
+		})
+	}
+
+	if characterClasses(password) < p.config.MinCharacterClasses {
+		violations = append(violations, PasswordViolation{
+			Reason: PasswordCharacterClasses,
+			Message: fmt.Sprintf("Password must mix at least %d of lowercase letters, uppercase letters, digits and symbols",
+				p.config.MinCharacterClasses),
+		})
+	}
+
+	userInputs := emailInputs(email)
+	if p.config.ForbidEmail && containsAny(strings.ToLower(password), userInputs) {
+		violations = append(violations, PasswordViolation{
+			Reason:  PasswordContainsEmail,
+			Message: "Password must not contain the email address",
+		})
+	}
+
+	if passwordStrength(password, userInputs) < p.config.MinStrength {
+		violations = append(violations, PasswordViolation{
+			Reason:  PasswordTooWeak,
+			Message: "Password is too easy to guess",
+		})
+	}
+
+	if p.breached(ctx, password) {
+		violations = append(violations, PasswordViolation{
+			Reason:  PasswordBreached,
+			Message: "Password has appeared in a data breach",
+		})
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// breached reports whether the password appears in the breached-password corpus
+// Only the first 5 characters of its SHA-1 hash are looked up, and a corpus that cannot be read lets the password through
+func (p *DefaultPasswordPolicy) breached(ctx context.Context, password string) bool {
+	if p.breachedStore == nil {
+		return false
+	}
+
+	hash := sha1.Sum([]byte(password))
+	hexHash := strings.ToUpper(hex.EncodeToString(hash[:]))
+	counts, err := p.breachedStore.GetRange(ctx, hexHash[:5])
+	if err != nil {
+		p.logger.Error("failed to look up breached password range",
+			zap.String("prefix", hexHash[:5]),
+			zap.Error(err))
+		return false
+	}
+
+	return counts[hexHash[5:]] >= p.config.BreachedMinCount
+}
+
+// characterClasses returns how many of lowercase letters, uppercase letters, digits and symbols the password mixes
+// Letters of scripts without case count as lowercase
+func characterClasses(password string) int {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLetter(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	count := 0
+	for _, present := range []bool{lower, upper, digit, symbol} {
+		if present {
+			count++
+		}
+	}
+	return count
+}
+
+// emailInputs returns the lowercase email address and its local part, which make a password easy to guess
+// A local part shorter than 3 characters is left out, as it would match too many passwords
+func emailInputs(email string) []string {
+	email = strings.ToLower(email)
+	if email == "" {
+		return nil
+	}
+
+	inputs := []string{email}
+	if local, _, ok := strings.Cut(email, "@"); ok && utf8.RuneCountInString(local) >= 3 {
+		inputs = append(inputs, local)
+	}
+	return inputs
+}
+
+// containsAny reports whether s contains any of the substrings
+func containsAny(s string, substrings []string) bool {
+	for _, substring := range substrings {
+		if strings.Contains(s, substring) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package service_test
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/service"
+	"go.uber.org/zap"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	strong := "amber-falcon-orbit-91"
+	hash := sha1.Sum([]byte(strong))
+	hexHash := strings.ToUpper(hex.EncodeToString(hash[:]))
+
+	testCases := []struct {
+		name            string
+		password        string
+		configure       func(*config.PasswordPolicyConfig)
+		setupMocks      func(*MockBreachedPasswordStore)
+		expectedReasons []string
+	}{
+		{
+			name:     "Strong password",
+			password: strong,
+		},
+		{
+			name:            "Too short and easy to guess",
+			password:        "Ab1!",
+			expectedReasons: []string{service.PasswordTooShort, service.PasswordTooWeak},
+		},
+		{
+			name:            "Too long",
+			password:        strings.Repeat("a", 129),
+			expectedReasons: []string{service.PasswordTooLong},
+		},
+		{
+			name:     "Too few character classes",
+			password: "amberfalconorbit",
+			configure: func(cfg *config.PasswordPolicyConfig) {
+				cfg.MinCharacterClasses = 3
+			},
+			expectedReasons: []string{service.PasswordCharacterClasses},
+		},
+		{
+			name:            "Contains the local part of the email",
+			password:        "Alice-was-here-2024",
+			expectedReasons: []string{service.PasswordContainsEmail},
+		},
+		{
+			name:     "Email allowed",
+			password: "Alice-was-here-2024",
+			configure: func(cfg *config.PasswordPolicyConfig) {
+				cfg.ForbidEmail = false
+			},
+		},
+		{
+			name:            "Common password with substitutions",
+			password:        "P@ssw0rd",
+			expectedReasons: []string{service.PasswordTooWeak},
+		},
+		{
+			name:            "Sequences",
+			password:        "abcdefgh12345678",
+			expectedReasons: []string{service.PasswordTooWeak},
+		},
+		{
+			name:            "Repeated characters",
+			password:        "zzzzzzzzzz",
+			expectedReasons: []string{service.PasswordTooWeak},
+		},
+		{
+			name:     "Breached password",
+			password: strong,
+			setupMocks: func(store *MockBreachedPasswordStore) {
+				store.On("GetRange", mock.Anything, hexHash[:5]).Return(map[string]int{hexHash[5:]: 3}, nil)
+			},
+			expectedReasons: []string{service.PasswordBreached},
+		},
+		{
+			name:     "Breached fewer times than the minimum",
+			password: strong,
+			configure: func(cfg *config.PasswordPolicyConfig) {
+				cfg.BreachedMinCount = 10
+			},
+			setupMocks: func(store *MockBreachedPasswordStore) {
+				store.On("GetRange", mock.Anything, hexHash[:5]).Return(map[string]int{hexHash[5:]: 3}, nil)
+			},
+		},
+		{
+			name:     "Corpus cannot be read",
+			password: strong,
+			setupMocks: func(store *MockBreachedPasswordStore) {
+				store.On("GetRange", mock.Anything, hexHash[:5]).Return(nil, errors.New("read error"))
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.DefaultPasswordPolicyConfig()
+			if tc.configure != nil {
+				tc.configure(cfg)
+			}
+			var policy service.PasswordPolicy
+			if tc.setupMocks != nil {
+				store := new(MockBreachedPasswordStore)
+				tc.setupMocks(store)
+				policy = service.NewPasswordPolicy(store, cfg, zap.NewNop())
+			} else {
+				policy = service.NewPasswordPolicy(nil, cfg, zap.NewNop())
+			}
+
+			err := policy.Check(context.Background(), tc.password, "alice@example.com")
+
+			if tc.expectedReasons == nil {
+				assert.NoError(t, err)
+				return
+			}
+			var policyErr *service.PasswordPolicyError
+			require.ErrorAs(t, err, &policyErr)
+			reasons := make([]string, len(policyErr.Violations))
+			for i, violation := range policyErr.Violations {
+				reasons[i] = violation.Reason
+				assert.NotEmpty(t, violation.Message)
+			}
+			assert.Equal(t, tc.expectedReasons, reasons)
+		})
+	}
+}
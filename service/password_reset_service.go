@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrInvalidPasswordResetToken is returned when a password reset token is forged, expired or already used
+	ErrInvalidPasswordResetToken = errors.New("invalid password reset token")
+)
+
+// PasswordResetService defines the interface for resetting forgotten passwords
+type PasswordResetService interface {
+	// RequestReset sends a password reset link to the user with the email address
+	// It does nothing when there is no such user, or a link was sent within the cooldown
+	RequestReset(ctx context.Context, email string) error
+
+	// ResetPassword consumes a password reset token and sets the password of its user,
+	// revoking every session of the user
+	// A password that breaks the password policy is refused with a *PasswordPolicyError, and the token can be used again
+	ResetPassword(ctx context.Context, token, password string) (*model.User, error)
+}
+
+// DefaultPasswordResetService implements the PasswordResetService interface
+type DefaultPasswordResetService struct {
+	passwordPolicy PasswordPolicy
+	passwordHasher PasswordHasher
+	userRepo       repository.UserRepository
+	userTokenRepo  repository.UserTokenRepository
+	activityRepo   repository.ActivityRepository
+	transactor     repository.Transactor
+	mailer         Mailer
+	authConfig     *config.AuthConfig
+	config         *config.PasswordResetConfig
+	logger         *zap.Logger
+}
+
+// NewPasswordResetService creates a new DefaultPasswordResetService instance
+func NewPasswordResetService(
+	passwordPolicy PasswordPolicy,
+	passwordHasher PasswordHasher,
+	userRepo repository.UserRepository,
+	userTokenRepo repository.UserTokenRepository,
+	activityRepo repository.ActivityRepository,
+	transactor repository.Transactor,
+	mailer Mailer,
+	authConfig *config.AuthConfig,
+	cfg *config.PasswordResetConfig,
+	logger *zap.Logger,
+) PasswordResetService {
+	return &DefaultPasswordResetService{
+		passwordPolicy: passwordPolicy,
+		passwordHasher: passwordHasher,
+		userRepo:       userRepo,
+		userTokenRepo:  userTokenRepo,
+		activityRepo:   activityRepo,
+		transactor:     transactor,
+		mailer:         mailer,
+		authConfig:     authConfig,
+		config:         cfg,
+		logger:         logger,
+	}
+}
+
+// RequestReset sends a password reset link to the user with the email address
+// Links sent before are invalidated
+func (s *DefaultPasswordResetService) RequestReset(ctx context.Context, email string) error {
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		s.logger.Info("password reset requested for unknown email",
+			zap.String("email", email))
+		return nil
+	}
+	if err != nil {
+		s.logger.Error("failed to get user by email",
+			zap.String("email", email),
+			zap.Error(err))
+		return err
+	}
+
+	recent, err := s.userTokenRepo.HasRecent(ctx, user.ID, model.UserTokenPasswordReset, s.config.Cooldown)
+	if err != nil {
+		s.logger.Error("failed to check recent password reset tokens",
+			zap.String("user_id", user.ID.String()),
+			zap.Error(err))
+		return err
+	}
+	if recent {
+		s.logger.Info("password reset within cooldown ignored",
+			zap.String("user_id", user.ID.String()))
+		return nil
+	}
+
+	token, err := issueUserToken(ctx, s.userTokenRepo, s.transactor, s.authConfig.JWTSecret,
+		user.ID, model.UserTokenPasswordReset, s.config.TokenTTL)
+	if err != nil {
+		s.logger.Error("failed to issue password reset token",
+			zap.String("user_id", user.ID.String()),
+			zap.Error(err))
+		return err
+	}
+
+	link, err := userTokenLink(s.config.ResetURL, token)
+	if err != nil {
+		s.logger.Error("failed to build password reset link",
+			zap.String("reset_url", s.config.ResetURL),
+			zap.Error(err))
+		return err
+	}
+
+	message := &Email{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Open the link below to choose a new password.\n\n%s\n\n"+
+			"The link expires in %s and can be used once. If you did not ask to reset your password, "+
+			"you can ignore this email.\n",
+			link, s.config.TokenTTL),
+	}
+	if err := s.mailer.Send(ctx, message); err != nil {
+		s.logger.Error("failed to send password reset email",
+			zap.String("user_id", user.ID.String()),
+			zap.Error(err))
+		return err
+	}
+
+	s.logger.Info("password reset email sent",
+		zap.String("user_id", user.ID.String()))
+	return nil
+}
+
+// ResetPassword consumes a password reset token and sets the password of its user
+// Other unused reset links of the user are invalidated as well
+// The password is checked against the policy once the token tells whose it is, and refusing it rolls back the consumption
+func (s *DefaultPasswordResetService) ResetPassword(ctx context.Context, token, password string) (*model.User, error) {
+	tokenHash, ok := parseUserToken(s.authConfig.JWTSecret, model.UserTokenPasswordReset, token)
+	if !ok {
+		s.logger.Warn("password reset token with invalid signature")
+		return nil, ErrInvalidPasswordResetToken
+	}
+
+	var user *model.User
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		consumed, err := s.userTokenRepo.Consume(ctx, tokenHash, model.UserTokenPasswordReset)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidPasswordResetToken
+		}
+		if err != nil {
+			return err
+		}
+
+		user, err = s.userRepo.GetByID(ctx, consumed.UserID)
+		if err != nil {
+			return err
+		}
+		if err := s.passwordPolicy.Check(ctx, password, user.Email); err != nil {
+			return err
+		}
+
+		passwordHash, err := s.passwordHasher.Hash(password)
+		if err != nil {
+			return err
+		}
+
+		if err := s.userRepo.UpdatePassword(ctx, consumed.UserID, passwordHash); err != nil {
+			return err
+		}
+		if err := s.userTokenRepo.DeleteUnused(ctx, consumed.UserID, model.UserTokenPasswordReset); err != nil {
+			return err
+		}
+		if err := recordActivity(ctx, s.activityRepo, consumed.UserID, model.ActivityEntityUser, consumed.UserID, nil,
+			model.ActivityActionPasswordChanged, nil, nil); err != nil {
+			return err
+		}
+
+		user, err = s.userRepo.GetByID(ctx, consumed.UserID)
+		return err
+	})
+	if errors.Is(err, ErrInvalidPasswordResetToken) {
+		s.logger.Warn("password reset token expired, used or unknown")
+		return nil, err
+	}
+	var policyErr *PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		s.logger.Info("password refused by policy",
+			zap.String("user_id", user.ID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+	if err != nil {
+		s.logger.Error("failed to reset password",
+			zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("password reset",
+		zap.String("user_id", user.ID.String()))
+	return user, nil
+}
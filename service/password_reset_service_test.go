@@ -0,0 +1,190 @@
+package service_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+	"go.uber.org/zap"
+)
+
+// newPasswordResetService creates a PasswordResetService with the mocks
+func newPasswordResetService(
+	userRepo *MockUserRepository,
+	userTokenRepo *MockUserTokenRepository,
+	mailer *MockMailer,
+) service.PasswordResetService {
+	passwordPolicy := service.NewPasswordPolicy(nil, config.DefaultPasswordPolicyConfig(), zap.NewNop())
+	return service.NewPasswordResetService(passwordPolicy, newTestPasswordHasher(), userRepo, userTokenRepo, newMockActivityRepository(), new(MockTransactor), mailer,
+		config.NewAuthConfig("test-secret", time.Minute, time.Hour), config.DefaultPasswordResetConfig(), zap.NewNop())
+}
+
+// requestResetToken requests a password reset for the user and returns the token sent, with the token as stored
+func requestResetToken(t *testing.T, user *model.User) (string, *model.UserToken) {
+	userRepo := new(MockUserRepository)
+	userTokenRepo := new(MockUserTokenRepository)
+	mailer := new(MockMailer)
+	userRepo.On("GetByEmail", mock.Anything, user.Email).Return(user, nil)
+	userTokenRepo.On("HasRecent", mock.Anything, user.ID, model.UserTokenPasswordReset, config.DefaultPasswordResetCooldown).
+		Return(false, nil)
+	issued := expectTokenIssued(userTokenRepo, user.ID, model.UserTokenPasswordReset, config.DefaultPasswordResetTokenTTL)
+	var sent *service.Email
+	mailer.On("Send", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		sent = args.Get(1).(*service.Email)
+	}).Return(nil)
+
+	err := newPasswordResetService(userRepo, userTokenRepo, mailer).RequestReset(context.Background(), user.Email)
+	require.NoError(t, err)
+	require.NotNil(t, sent)
+	assert.Equal(t, user.Email, sent.To)
+	userTokenRepo.AssertExpectations(t)
+	return tokenFromEmail(t, sent, config.DefaultPasswordResetURL), issued
+}
+
+func TestRequestReset(t *testing.T) {
+	userID := uuid.New()
+
+	testCases := []struct {
+		name          string
+		setupMocks    func(*MockUserRepository, *MockUserTokenRepository)
+		expectedError error
+	}{
+		{
+			name: "Within cooldown",
+			setupMocks: func(userRepo *MockUserRepository, userTokenRepo *MockUserTokenRepository) {
+				userRepo.On("GetByEmail", mock.Anything, "test@example.com").
+					Return(&model.User{ID: userID, Email: "test@example.com"}, nil)
+				userTokenRepo.On("HasRecent", mock.Anything, userID, model.UserTokenPasswordReset,
+					config.DefaultPasswordResetCooldown).Return(true, nil)
+			},
+		},
+		{
+			name: "Unknown email",
+			setupMocks: func(userRepo *MockUserRepository, userTokenRepo *MockUserTokenRepository) {
+				userRepo.On("GetByEmail", mock.Anything, "test@example.com").Return(nil, sql.ErrNoRows)
+			},
+		},
+		{
+			name: "Repository error",
+			setupMocks: func(userRepo *MockUserRepository, userTokenRepo *MockUserTokenRepository) {
+				userRepo.On("GetByEmail", mock.Anything, "test@example.com").Return(nil, errors.New("database error"))
+			},
+			expectedError: errors.New("database error"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			userRepo := new(MockUserRepository)
+			userTokenRepo := new(MockUserTokenRepository)
+			mailer := new(MockMailer)
+			tc.setupMocks(userRepo, userTokenRepo)
+
+			err := newPasswordResetService(userRepo, userTokenRepo, mailer).RequestReset(context.Background(), "test@example.com")
+
+			if tc.expectedError != nil {
+				assert.EqualError(t, err, tc.expectedError.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+			mailer.AssertNotCalled(t, "Send", mock.Anything, mock.Anything)
+			userRepo.AssertExpectations(t)
+			userTokenRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	user := &model.User{ID: uuid.New(), Email: "test@example.com", PasswordHash: "old-hash"}
+
+	t.Run("Sets the password with the emailed token", func(t *testing.T) {
+		token, issued := requestResetToken(t, user)
+
+		userRepo := new(MockUserRepository)
+		userTokenRepo := new(MockUserTokenRepository)
+		userTokenRepo.On("Consume", mock.Anything, issued.TokenHash, model.UserTokenPasswordReset).Return(issued, nil)
+		userRepo.On("UpdatePassword", mock.Anything, user.ID, mock.MatchedBy(func(passwordHash string) bool {
+			return passwordMatches(passwordHash, "amber-falcon-orbit-91")
+		})).Return(nil)
+		userTokenRepo.On("DeleteUnused", mock.Anything, user.ID, model.UserTokenPasswordReset).Return(nil)
+		userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+
+		result, err := newPasswordResetService(userRepo, userTokenRepo, new(MockMailer)).
+			ResetPassword(context.Background(), token, "amber-falcon-orbit-91")
+
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, result.ID)
+		userRepo.AssertExpectations(t)
+		userTokenRepo.AssertExpectations(t)
+	})
+
+	t.Run("Password refused by policy", func(t *testing.T) {
+		token, issued := requestResetToken(t, user)
+
+		userRepo := new(MockUserRepository)
+		userTokenRepo := new(MockUserTokenRepository)
+		userTokenRepo.On("Consume", mock.Anything, issued.TokenHash, model.UserTokenPasswordReset).Return(issued, nil)
+		userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+
+		result, err := newPasswordResetService(userRepo, userTokenRepo, new(MockMailer)).
+			ResetPassword(context.Background(), token, "short")
+
+		var policyErr *service.PasswordPolicyError
+		require.ErrorAs(t, err, &policyErr)
+		assert.Equal(t, service.PasswordTooShort, policyErr.Violations[0].Reason)
+		assert.Nil(t, result)
+		userRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Verification token is not accepted", func(t *testing.T) {
+		userTokenRepo := new(MockUserTokenRepository)
+		mailer := new(MockMailer)
+		expectTokenIssued(userTokenRepo, user.ID, model.UserTokenEmailVerification, config.DefaultVerificationTokenTTL)
+		var sent *service.Email
+		mailer.On("Send", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			sent = args.Get(1).(*service.Email)
+		}).Return(nil)
+		require.NoError(t, newEmailVerificationService(new(MockUserRepository), userTokenRepo, mailer).
+			SendVerification(context.Background(), user))
+
+		result, err := newPasswordResetService(new(MockUserRepository), new(MockUserTokenRepository), new(MockMailer)).
+			ResetPassword(context.Background(), tokenFromEmail(t, sent, config.DefaultVerificationURL), "new-password")
+
+		assert.Equal(t, service.ErrInvalidPasswordResetToken, err)
+		assert.Nil(t, result)
+	})
+
+	t.Run("Expired or used token", func(t *testing.T) {
+		token, issued := requestResetToken(t, user)
+
+		userRepo := new(MockUserRepository)
+		userTokenRepo := new(MockUserTokenRepository)
+		userTokenRepo.On("Consume", mock.Anything, issued.TokenHash, model.UserTokenPasswordReset).Return(nil, sql.ErrNoRows)
+
+		result, err := newPasswordResetService(userRepo, userTokenRepo, new(MockMailer)).
+			ResetPassword(context.Background(), token, "new-password")
+
+		assert.Equal(t, service.ErrInvalidPasswordResetToken, err)
+		assert.Nil(t, result)
+		userRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Tampered token", func(t *testing.T) {
+		token, _ := requestResetToken(t, user)
+
+		result, err := newPasswordResetService(new(MockUserRepository), new(MockUserTokenRepository), new(MockMailer)).
+			ResetPassword(context.Background(), tamperToken(token), "new-password")
+
+		assert.Equal(t, service.ErrInvalidPasswordResetToken, err)
+		assert.Nil(t, result)
+	})
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// strengthThresholds are the numbers of guesses, as powers of ten, a password has to take to reach each score above 0
+// They follow zxcvbn: below 10^3 guesses is too guessable, and 10^10 or more is very unguessable
+var strengthThresholds = []float64{3, 6, 8, 10}
+
+// maxPatternLength is the longest run of characters matched as a single pattern
+// Longer runs are split into several patterns, which keeps estimating long passwords cheap
+const maxPatternLength = 32
+
+// commonPasswords are some of the most used passwords and words of this service, most common first
+// An attacker tries them first, so a password made of them takes as many guesses as its rank
+var commonPasswords = []string{
+	"123456", "password", "123456789", "12345678", "12345", "qwerty", "1234567", "111111", "123123", "abc123",
+	"1234567890", "password1", "iloveyou", "1q2w3e4r", "000000", "qwerty123", "zaq12wsx", "dragon", "sunshine", "princess",
+	"letmein", "654321", "monkey", "1qaz2wsx", "123321", "qwertyuiop", "superman", "asdfghjkl", "trustno1", "welcome",
+	"admin", "login", "master", "hello", "freedom", "whatever", "shadow", "football", "baseball", "michael",
+	"jennifer", "jordan", "hunter", "charlie", "starwars", "passw0rd", "access", "flower", "secret", "summer",
+	"winter", "love", "ninja", "mustang", "batman", "killer", "pokemon", "cheese", "computer", "internet",
+	"matrix", "soccer", "hockey", "ranger", "daniel", "thomas", "robert", "andrew", "harley", "orange",
+	"buster", "ginger", "pepper", "maggie", "cookie", "chocolate", "banana", "apple", "test", "guest",
+	"root", "changeme", "default", "todo", "todoms",
+}
+
+// sequenceAlphabets are the orders people walk along when making up a password, such as abc, 321 or qwerty
+var sequenceAlphabets = []string{
+	"abcdefghijklmnopqrstuvwxyz",
+	"0123456789",
+	"qwertyuiop",
+	"asdfghjkl",
+	"zxcvbnm",
+}
+
+// leetSubstitutions maps the characters substituted for letters in passwords back to the letters
+var leetSubstitutions = map[rune]rune{
+	'4': 'a', '@': 'a', '8': 'b', '(': 'c', '3': 'e', '6': 'g', '1': 'i', '!': 'i', '|': 'l',
+	'0': 'o', '$': 's', '5': 's', '7': 't', '+': 't', '2': 'z',
+}
+
+// passwordStrength estimates how hard a password is to guess on a score from 0 to 4, in the style of zxcvbn
+// The password is split into the patterns that take the fewest guesses: common passwords and the user inputs,
+// sequences and repeated characters, and any other character is guessed by brute force
+func passwordStrength(password string, userInputs []string) int {
+	runes := []rune(password)
+
+	// best[j] is the fewest guesses, as a power of ten, it takes to guess the first j characters
+	best := make([]float64, len(runes)+1)
+	for j := 1; j <= len(runes); j++ {
+		best[j] = best[j-1] + 1 // One in ten characters by brute force
+		for i := max(0, j-maxPatternLength); i < j; i++ {
+			if guesses, ok := patternGuesses(runes[i:j], userInputs); ok {
+				best[j] = min(best[j], best[i]+math.Log10(guesses))
+			}
+		}
+	}
+
+	score := 0
+	for _, threshold := range strengthThresholds {
+		if best[len(runes)] >= threshold {
+			score++
+		}
+	}
+	return score
+}
+
+// patternGuesses returns the guesses it takes to guess the characters as a single pattern,
+// or false when they do not make up a pattern
+func patternGuesses(runes []rune, userInputs []string) (float64, bool) {
+	guesses := math.Inf(1)
+
+	if len(runes) >= 3 {
+		if g, ok := repeatGuesses(runes); ok {
+			guesses = min(guesses, g)
+		}
+		if g, ok := sequenceGuesses(runes); ok {
+			guesses = min(guesses, g)
+		}
+	}
+	if g, ok := dictionaryGuesses(runes, userInputs); ok {
+		guesses = min(guesses, g)
+	}
+
+	return guesses, !math.IsInf(guesses, 1)
+}
+
+// repeatGuesses returns the guesses it takes to guess a character repeated, such as aaa
+func repeatGuesses(runes []rune) (float64, bool) {
+	for _, r := range runes[1:] {
+		if r != runes[0] {
+			return 0, false
+		}
+	}
+
+	cardinality := 33.0
+	switch {
+	case unicode.IsDigit(runes[0]):
+		cardinality = 10
+	case unicode.IsLetter(runes[0]):
+		cardinality = 26
+	}
+	return cardinality * float64(len(runes)), true
+}
+
+// sequenceGuesses returns the guesses it takes to guess a walk along a sequence alphabet, either way
+// Walks from the start of an alphabet, such as abc or 123, are tried first
+func sequenceGuesses(runes []rune) (float64, bool) {
+	lower := strings.ToLower(string(runes))
+	for _, alphabet := range sequenceAlphabets {
+		reversed := []rune(alphabet)
+		slices.Reverse(reversed)
+
+		descending := false
+		index := strings.Index(alphabet, lower)
+		if index < 0 {
+			index = strings.Index(string(reversed), lower)
+			descending = true
+		}
+		if index < 0 {
+			continue
+		}
+
+		base := 26.0
+		if alphabet == "0123456789" {
+			base = 10
+		}
+		if index == 0 {
+			base = 4
+		}
+		guesses := base * float64(len(runes))
+		if descending {
+			guesses *= 2
+		}
+		return guesses, true
+	}
+	return 0, false
+}
+
+// dictionaryGuesses returns the guesses it takes to guess one of the user inputs or common passwords,
+// which are tried first in that order
+// Capitalizing it or substituting characters for its letters doubles the guesses each
+func dictionaryGuesses(runes []rune, userInputs []string) (float64, bool) {
+	word := string(runes)
+	lower := strings.ToLower(word)
+	unleet := strings.Map(func(r rune) rune {
+		if letter, ok := leetSubstitutions[r]; ok {
+			return letter
+		}
+		return r
+	}, lower)
+
+	for rank, candidate := range slices.Concat(userInputs, commonPasswords) {
+		var guesses float64
+		switch candidate {
+		case lower:
+			guesses = float64(rank + 1)
+		case unleet:
+			guesses = float64(rank+1) * 2
+		default:
+			continue
+		}
+		if word != lower {
+			guesses *= 2
+		}
+		return guesses, true
+	}
+	return 0, false
+}
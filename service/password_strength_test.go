@@ -0,0 +1,162 @@
+package service_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yukimaterrace/todoms/service"
+)
+
+func TestRepeatGuesses(t *testing.T) {
+	testCases := []struct {
+		name            string
+		password        string
+		expectedGuesses float64
+		expectedOK      bool
+	}{
+		{name: "Repeated letter", password: "aaa", expectedGuesses: 78, expectedOK: true},
+		{name: "Repeated capital letter", password: "ZZZZ", expectedGuesses: 104, expectedOK: true},
+		{name: "Repeated digit", password: "111", expectedGuesses: 30, expectedOK: true},
+		{name: "Repeated symbol", password: "!!!!", expectedGuesses: 132, expectedOK: true},
+		{name: "Different character at the end", password: "aab", expectedOK: false},
+		{name: "Same letter in another case", password: "aaA", expectedOK: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			guesses, ok := service.RepeatGuesses([]rune(tc.password))
+
+			assert.Equal(t, tc.expectedOK, ok)
+			if tc.expectedOK {
+				assert.Equal(t, tc.expectedGuesses, guesses)
+			}
+		})
+	}
+}
+
+func TestSequenceGuesses(t *testing.T) {
+	testCases := []struct {
+		name            string
+		password        string
+		expectedGuesses float64
+		expectedOK      bool
+	}{
+		{name: "From the start of the alphabet", password: "abc", expectedGuesses: 12, expectedOK: true},
+		{name: "Capital letters", password: "ABCD", expectedGuesses: 16, expectedOK: true},
+		{name: "Middle of the alphabet", password: "cde", expectedGuesses: 78, expectedOK: true},
+		{name: "Middle of the digits", password: "345", expectedGuesses: 30, expectedOK: true},
+		{name: "Descending letters", password: "cba", expectedGuesses: 156, expectedOK: true},
+		{name: "Descending from the end of the digits", password: "987", expectedGuesses: 24, expectedOK: true},
+		{name: "Keyboard row", password: "qwerty", expectedGuesses: 24, expectedOK: true},
+		{name: "Middle of a keyboard row", password: "sdf", expectedGuesses: 78, expectedOK: true},
+		{name: "Whole alphabet", password: "abcdefghijklmnopqrstuvwxyz", expectedGuesses: 104, expectedOK: true},
+		{name: "Skipping characters", password: "ace", expectedOK: false},
+		{name: "Across alphabets", password: "xyz0", expectedOK: false},
+		{name: "Wrapping around", password: "yza", expectedOK: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			guesses, ok := service.SequenceGuesses([]rune(tc.password))
+
+			assert.Equal(t, tc.expectedOK, ok)
+			if tc.expectedOK {
+				assert.Equal(t, tc.expectedGuesses, guesses)
+			}
+		})
+	}
+}
+
+func TestDictionaryGuesses(t *testing.T) {
+	testCases := []struct {
+		name            string
+		password        string
+		userInputs      []string
+		expectedGuesses float64
+		expectedOK      bool
+	}{
+		{name: "Most common password", password: "123456", expectedGuesses: 1, expectedOK: true},
+		{name: "Common password", password: "password", expectedGuesses: 2, expectedOK: true},
+		{name: "Capitalized", password: "Password", expectedGuesses: 4, expectedOK: true},
+		{name: "Substituted characters", password: "p@ssw0rd", expectedGuesses: 4, expectedOK: true},
+		{name: "Capitalized with substituted characters", password: "P4ssword", expectedGuesses: 8, expectedOK: true},
+		{name: "Word of this service", password: "todoms", expectedGuesses: 85, expectedOK: true},
+		{name: "User input tried first", password: "alice", userInputs: []string{"alice"}, expectedGuesses: 1, expectedOK: true},
+		{name: "Common password after the user inputs", password: "password", userInputs: []string{"alice"}, expectedGuesses: 3, expectedOK: true},
+		{name: "Part of a word", password: "passwor", expectedOK: false},
+		{name: "Unknown word", password: "zebra", expectedOK: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			guesses, ok := service.DictionaryGuesses([]rune(tc.password), tc.userInputs)
+
+			assert.Equal(t, tc.expectedOK, ok)
+			if tc.expectedOK {
+				assert.Equal(t, tc.expectedGuesses, guesses)
+			}
+		})
+	}
+}
+
+func TestPatternGuesses(t *testing.T) {
+	testCases := []struct {
+		name            string
+		password        string
+		expectedGuesses float64
+		expectedOK      bool
+	}{
+		{name: "Fewest guesses of the matching patterns", password: "123456", expectedGuesses: 1, expectedOK: true},
+		{name: "Sequence", password: "abc", expectedGuesses: 12, expectedOK: true},
+		{name: "Repeat", password: "aaa", expectedGuesses: 78, expectedOK: true},
+		{name: "Too short for a repeat", password: "aa", expectedOK: false},
+		{name: "Too short for a sequence", password: "ab", expectedOK: false},
+		{name: "No pattern", password: "x7q", expectedOK: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			guesses, ok := service.PatternGuesses([]rune(tc.password), nil)
+
+			assert.Equal(t, tc.expectedOK, ok)
+			if tc.expectedOK {
+				assert.Equal(t, tc.expectedGuesses, guesses)
+			}
+		})
+	}
+}
+
+func TestPasswordStrength(t *testing.T) {
+	testCases := []struct {
+		name          string
+		password      string
+		userInputs    []string
+		expectedScore int
+	}{
+		{name: "Empty", password: "", expectedScore: 0},
+		{name: "Below 10^3 guesses", password: "x7", expectedScore: 0},
+		{name: "10^3 guesses", password: "x7q", expectedScore: 1},
+		{name: "10^6 guesses", password: "x7q!k2", expectedScore: 2},
+		{name: "10^8 guesses", password: "x7q!k2m9", expectedScore: 3},
+		{name: "10^10 guesses", password: "x7q!k2m9w%", expectedScore: 4},
+		{name: "Common password", password: "password", expectedScore: 0},
+		{name: "Common password with substituted characters", password: "P@ssw0rd", expectedScore: 0},
+		{name: "Common passwords joined", password: "dragonmonkeyshadow", expectedScore: 1},
+		{name: "User input", password: "alice-smith", userInputs: []string{"alice", "smith"}, expectedScore: 0},
+		{name: "Same password without the user inputs", password: "alice-smith", expectedScore: 4},
+		{name: "Sequence", password: "abcdefghijklmnopqrstuvwxyz", expectedScore: 0},
+		{name: "Descending keyboard row", password: "poiuytrewq", expectedScore: 0},
+		{name: "Repeat", password: "aaaaaaaaaaaa", expectedScore: 0},
+		{name: "Repeat up to the longest pattern", password: strings.Repeat("a", service.MaxPatternLength), expectedScore: 0},
+		{name: "Repeat longer than the longest pattern", password: strings.Repeat("a", service.MaxPatternLength+1), expectedScore: 1},
+		{name: "Long repeat split into patterns", password: strings.Repeat("a", 4*service.MaxPatternLength), expectedScore: 4},
+		{name: "Patterns joined by random characters", password: "password!x7q2monkey", expectedScore: 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedScore, service.PasswordStrength(tc.password, tc.userInputs))
+		})
+	}
+}
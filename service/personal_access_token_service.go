@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+	"go.uber.org/zap"
+)
+
+// Errors of the personal access token service
+var (
+	// ErrPersonalAccessTokenNotFound is returned when a user has no token with the ID
+	ErrPersonalAccessTokenNotFound = errors.New("personal access token not found")
+
+	// ErrPersonalAccessTokenLimit is returned when a user already has as many tokens as they can
+	ErrPersonalAccessTokenLimit = errors.New("personal access token limit reached")
+)
+
+// PersonalAccessTokenPrefix is the prefix of personal access tokens, which tells them apart from the JWTs
+// users log in with and the tokens issued to OAuth clients
+const PersonalAccessTokenPrefix = "todoms_pat_"
+
+// PersonalAccessTokenService defines the interface for the long-lived tokens users create for scripts and CLIs
+type PersonalAccessTokenService interface {
+	// Create creates a token for the user, returning it with the token itself, which cannot be retrieved again
+	Create(ctx context.Context, userID uuid.UUID, req model.CreatePersonalAccessTokenRequest) (*model.PersonalAccessToken, string, error)
+
+	// List retrieves the tokens of the user
+	List(ctx context.Context, userID uuid.UUID) ([]model.PersonalAccessToken, error)
+
+	// Delete revokes a token of the user
+	Delete(ctx context.Context, userID, tokenID uuid.UUID) error
+
+	// ValidateToken validates a personal access token and returns the claims of its user and scopes
+	ValidateToken(ctx context.Context, token string) (*Claims, error)
+
+	// PurgeExpired deletes expired tokens and returns how many were deleted
+	PurgeExpired(ctx context.Context) (int64, error)
+}
+
+// DefaultPersonalAccessTokenService implements the PersonalAccessTokenService interface
+type DefaultPersonalAccessTokenService struct {
+	userRepo   repository.UserRepository
+	tokenRepo  repository.PersonalAccessTokenRepository
+	transactor repository.Transactor
+	config     *config.PersonalAccessTokenConfig
+	logger     *zap.Logger
+}
+
+// NewPersonalAccessTokenService creates a new DefaultPersonalAccessTokenService instance
+func NewPersonalAccessTokenService(
+	userRepo repository.UserRepository,
+	tokenRepo repository.PersonalAccessTokenRepository,
+	transactor repository.Transactor,
+	cfg *config.PersonalAccessTokenConfig,
+	logger *zap.Logger,
+) PersonalAccessTokenService {
+	return &DefaultPersonalAccessTokenService{
+		userRepo:   userRepo,
+		tokenRepo:  tokenRepo,
+		transactor: transactor,
+		config:     cfg,
+		logger:     logger,
+	}
+}
+
+// Create creates a token for the user, returning it with the token itself, which cannot be retrieved again
+// Only the hash of the token is stored
+// The tokens of the user are counted in the transaction that inserts the token, so concurrent creations cannot
+// exceed the limit
+func (s *DefaultPersonalAccessTokenService) Create(ctx context.Context, userID uuid.UUID, req model.CreatePersonalAccessTokenRequest) (*model.PersonalAccessToken, string, error) {
+	secret, err := randomOAuthSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	raw := PersonalAccessTokenPrefix + secret
+
+	token := &model.PersonalAccessToken{
+		UserID:    userID,
+		Name:      req.Name,
+		TokenHash: hashOAuthSecret(raw),
+		Scopes:    normalizeScopes(req.Scopes),
+	}
+	if req.ExpiresInDays != nil {
+		expiresAt := time.Now().AddDate(0, 0, *req.ExpiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		count, err := s.tokenRepo.CountByUserIDForUpdate(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if count >= s.config.MaxTokens {
+			s.logger.Warn("personal access token limit reached",
+				zap.String("user_id", userID.String()),
+				zap.Int("count", count))
+			return ErrPersonalAccessTokenLimit
+		}
+
+		return s.tokenRepo.Create(ctx, token)
+	})
+	if errors.Is(err, ErrPersonalAccessTokenLimit) {
+		return nil, "", err
+	}
+	if err != nil {
+		s.logger.Error("failed to create personal access token in repository",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, "", err
+	}
+
+	s.logger.Info("personal access token created",
+		zap.String("user_id", userID.String()),
+		zap.String("token_id", token.ID.String()),
+		zap.Strings("scopes", token.Scopes))
+	return token, raw, nil
+}
+
+// List retrieves the tokens of the user
+func (s *DefaultPersonalAccessTokenService) List(ctx context.Context, userID uuid.UUID) ([]model.PersonalAccessToken, error) {
+	tokens, err := s.tokenRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		s.logger.Error("failed to get personal access tokens from repository",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Delete revokes a token of the user
+func (s *DefaultPersonalAccessTokenService) Delete(ctx context.Context, userID, tokenID uuid.UUID) error {
+	deleted, err := s.tokenRepo.Delete(ctx, userID, tokenID)
+	if err != nil {
+		s.logger.Error("failed to delete personal access token in repository",
+			zap.String("user_id", userID.String()),
+			zap.String("token_id", tokenID.String()),
+			zap.Error(err))
+		return err
+	}
+	if !deleted {
+		return ErrPersonalAccessTokenNotFound
+	}
+
+	s.logger.Info("personal access token deleted",
+		zap.String("user_id", userID.String()),
+		zap.String("token_id", tokenID.String()))
+	return nil
+}
+
+// ValidateToken validates a personal access token and returns the claims of its user and scopes
+// Tokens created before the last password change of their user are rejected
+// The last use of the token is recorded, at most once in the configured interval
+func (s *DefaultPersonalAccessTokenService) ValidateToken(ctx context.Context, token string) (*Claims, error) {
+	if !strings.HasPrefix(token, PersonalAccessTokenPrefix) {
+		return nil, ErrInvalidToken
+	}
+
+	stored, err := s.tokenRepo.GetByHash(ctx, hashOAuthSecret(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		s.logger.Error("failed to get personal access token from repository",
+			zap.Error(err))
+		return nil, err
+	}
+	if stored.Expired(time.Now()) {
+		return nil, ErrExpiredToken
+	}
+
+	user, err := s.userRepo.GetByID(ctx, stored.UserID)
+	if err != nil {
+		s.logger.Warn("user of personal access token not found",
+			zap.String("user_id", stored.UserID.String()),
+			zap.Error(err))
+		return nil, ErrInvalidToken
+	}
+	if user.Disabled() || stored.SessionVersion != user.SessionVersion {
+		return nil, ErrInvalidToken
+	}
+
+	if err := s.tokenRepo.UpdateLastUsed(ctx, stored.ID, s.config.LastUsedInterval); err != nil {
+		// The token is still valid, so the request goes on without its last use recorded
+		s.logger.Error("failed to record last use of personal access token",
+			zap.String("token_id", stored.ID.String()),
+			zap.Error(err))
+	}
+
+	claims := &Claims{
+		UserID:         user.ID.String(),
+		Email:          user.Email,
+		EmailVerified:  user.EmailVerified(),
+		SessionVersion: user.SessionVersion,
+		Type:           string(AccessToken),
+		TokenID:        stored.ID.String(),
+		Scopes:         stored.Scopes,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt: jwt.NewNumericDate(stored.CreatedAt),
+		},
+	}
+	if stored.ExpiresAt != nil {
+		claims.ExpiresAt = jwt.NewNumericDate(*stored.ExpiresAt)
+	}
+	return claims, nil
+}
+
+// PurgeExpired deletes expired tokens and returns how many were deleted
+func (s *DefaultPersonalAccessTokenService) PurgeExpired(ctx context.Context) (int64, error) {
+	purged, err := s.tokenRepo.DeleteExpired(ctx)
+	if err != nil {
+		s.logger.Error("failed to purge expired personal access tokens",
+			zap.Error(err))
+		return purged, err
+	}
+
+	return purged, nil
+}
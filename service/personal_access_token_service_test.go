@@ -0,0 +1,189 @@
+package service_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+	"go.uber.org/zap"
+)
+
+// newPersonalAccessTokenService creates a PersonalAccessTokenService with the mocks and the default configuration
+func newPersonalAccessTokenService(userRepo *MockUserRepository, tokenRepo *MockPersonalAccessTokenRepository) service.PersonalAccessTokenService {
+	return service.NewPersonalAccessTokenService(userRepo, tokenRepo, new(MockTransactor), config.DefaultPersonalAccessTokenConfig(), zap.NewNop())
+}
+
+func TestCreatePersonalAccessToken(t *testing.T) {
+	userID := uuid.New()
+	thirtyDays := 30
+
+	testCases := []struct {
+		name          string
+		count         int
+		expiresInDays *int
+		expectedError error
+	}{
+		{name: "Token that does not expire", count: 0},
+		{name: "Token that expires", count: 3, expiresInDays: &thirtyDays},
+		{name: "Limit reached", count: config.DefaultMaxPersonalAccessTokens, expectedError: service.ErrPersonalAccessTokenLimit},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tokenRepo := new(MockPersonalAccessTokenRepository)
+			tokenRepo.On("CountByUserIDForUpdate", mock.Anything, userID).Return(tc.count, nil)
+			tokenRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.PersonalAccessToken")).Return(nil)
+
+			token, raw, err := newPersonalAccessTokenService(nil, tokenRepo).Create(context.Background(), userID, model.CreatePersonalAccessTokenRequest{
+				Name:          "CI",
+				Scopes:        []string{model.ScopeTodosWrite, model.ScopeTodosRead},
+				ExpiresInDays: tc.expiresInDays,
+			})
+
+			assert.Equal(t, tc.expectedError, err)
+			if tc.expectedError != nil {
+				tokenRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+				return
+			}
+			assert.True(t, strings.HasPrefix(raw, service.PersonalAccessTokenPrefix))
+			assert.Equal(t, hashSecret(raw), token.TokenHash)
+			assert.Equal(t, userID, token.UserID)
+			assert.Equal(t, []string{model.ScopeTodosRead, model.ScopeTodosWrite}, []string(token.Scopes))
+			if tc.expiresInDays == nil {
+				assert.Nil(t, token.ExpiresAt)
+			} else {
+				require.NotNil(t, token.ExpiresAt)
+				assert.WithinDuration(t, time.Now().AddDate(0, 0, *tc.expiresInDays), *token.ExpiresAt, time.Minute)
+			}
+		})
+	}
+}
+
+func TestDeletePersonalAccessToken(t *testing.T) {
+	userID := uuid.New()
+	tokenID := uuid.New()
+
+	testCases := []struct {
+		name          string
+		deleted       bool
+		expectedError error
+	}{
+		{name: "Success", deleted: true},
+		{name: "Not found", deleted: false, expectedError: service.ErrPersonalAccessTokenNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tokenRepo := new(MockPersonalAccessTokenRepository)
+			tokenRepo.On("Delete", mock.Anything, userID, tokenID).Return(tc.deleted, nil)
+
+			err := newPersonalAccessTokenService(nil, tokenRepo).Delete(context.Background(), userID, tokenID)
+
+			assert.Equal(t, tc.expectedError, err)
+		})
+	}
+}
+
+func TestValidatePersonalAccessToken(t *testing.T) {
+	verifiedAt := time.Now()
+	user := &model.User{ID: uuid.New(), Email: "test@example.com", EmailVerifiedAt: &verifiedAt, SessionVersion: 2}
+	raw := service.PersonalAccessTokenPrefix + "token"
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Minute)
+	valid := &model.PersonalAccessToken{
+		ID:             uuid.New(),
+		UserID:         user.ID,
+		TokenHash:      hashSecret(raw),
+		Scopes:         []string{model.ScopeTodosRead},
+		SessionVersion: user.SessionVersion,
+		CreatedAt:      time.Now(),
+	}
+	beforePasswordChange := *valid
+	beforePasswordChange.SessionVersion = user.SessionVersion - 1
+	expiring := *valid
+	expiring.ExpiresAt = &future
+	expired := *valid
+	expired.ExpiresAt = &past
+
+	testCases := []struct {
+		name          string
+		token         string
+		stored        *model.PersonalAccessToken
+		expectedError error
+	}{
+		{name: "Token that does not expire", token: raw, stored: valid},
+		{name: "Token that has not expired yet", token: raw, stored: &expiring},
+		{name: "Expired token", token: raw, stored: &expired, expectedError: service.ErrExpiredToken},
+		{name: "Unknown token", token: raw, stored: nil, expectedError: service.ErrInvalidToken},
+		{name: "Token created before a password change", token: raw, stored: &beforePasswordChange, expectedError: service.ErrInvalidToken},
+		{name: "Not a personal access token", token: service.OAuthAccessTokenPrefix + "token", stored: valid, expectedError: service.ErrInvalidToken},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			userRepo := new(MockUserRepository)
+			tokenRepo := new(MockPersonalAccessTokenRepository)
+			userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+			if tc.stored != nil {
+				tokenRepo.On("GetByHash", mock.Anything, hashSecret(raw)).Return(tc.stored, nil)
+			} else {
+				tokenRepo.On("GetByHash", mock.Anything, hashSecret(raw)).Return(nil, sql.ErrNoRows)
+			}
+			tokenRepo.On("UpdateLastUsed", mock.Anything, valid.ID, time.Minute).Return(nil)
+
+			claims, err := newPersonalAccessTokenService(userRepo, tokenRepo).ValidateToken(context.Background(), tc.token)
+
+			assert.Equal(t, tc.expectedError, err)
+			if tc.expectedError != nil {
+				tokenRepo.AssertNotCalled(t, "UpdateLastUsed", mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			tokenRepo.AssertExpectations(t)
+			assert.Equal(t, user.ID.String(), claims.UserID)
+			assert.True(t, claims.EmailVerified)
+			assert.Equal(t, string(service.AccessToken), claims.Type)
+			assert.Equal(t, valid.ID.String(), claims.TokenID)
+			assert.True(t, claims.Delegated())
+			assert.True(t, claims.HasScope(model.ScopeTodosRead))
+			assert.False(t, claims.HasScope(model.ScopeTodosWrite))
+		})
+	}
+
+	t.Run("Failing to record the last use", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		tokenRepo := new(MockPersonalAccessTokenRepository)
+		userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+		tokenRepo.On("GetByHash", mock.Anything, hashSecret(raw)).Return(valid, nil)
+		tokenRepo.On("UpdateLastUsed", mock.Anything, valid.ID, time.Minute).Return(errors.New("database error"))
+
+		claims, err := newPersonalAccessTokenService(userRepo, tokenRepo).ValidateToken(context.Background(), raw)
+
+		require.NoError(t, err)
+		assert.Equal(t, user.ID.String(), claims.UserID)
+	})
+
+	t.Run("Disabled user", func(t *testing.T) {
+		disabledAt := time.Now()
+		disabledUser := *user
+		disabledUser.DisabledAt = &disabledAt
+		userRepo := new(MockUserRepository)
+		tokenRepo := new(MockPersonalAccessTokenRepository)
+		userRepo.On("GetByID", mock.Anything, user.ID).Return(&disabledUser, nil)
+		tokenRepo.On("GetByHash", mock.Anything, hashSecret(raw)).Return(valid, nil)
+
+		claims, err := newPersonalAccessTokenService(userRepo, tokenRepo).ValidateToken(context.Background(), raw)
+
+		assert.Equal(t, service.ErrInvalidToken, err)
+		assert.Nil(t, claims)
+	})
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+	"go.uber.org/zap"
+)
+
+// RateLimitService defines the interface for rate limiting the API requests of users
+type RateLimitService interface {
+	// Take takes a request to the route from the allowance of the specified user
+	// The route is the path pattern the request was routed to
+	Take(ctx context.Context, userID uuid.UUID, route string) (*model.RateLimitResult, error)
+
+	// PurgeFull removes the buckets that have refilled, including those of the login limits
+	PurgeFull(ctx context.Context) error
+}
+
+// DefaultRateLimitService implements the RateLimitService interface
+type DefaultRateLimitService struct {
+	store  repository.RateLimitStore
+	config *config.RateLimitConfig
+	logger *zap.Logger
+}
+
+// NewRateLimitService creates a new DefaultRateLimitService instance
+func NewRateLimitService(store repository.RateLimitStore, cfg *config.RateLimitConfig, logger *zap.Logger) RateLimitService {
+	return &DefaultRateLimitService{
+		store:  store,
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// Take takes a request to the route from the allowance of the specified user
+func (s *DefaultRateLimitService) Take(ctx context.Context, userID uuid.UUID, route string) (*model.RateLimitResult, error) {
+	group, rule := s.ruleFor(route)
+	key := "api:" + userID.String()
+	if group != "" {
+		key += ":" + group
+	}
+
+	result, err := s.store.Take(ctx, key, model.RateLimit{Burst: rule.Burst, Period: rule.Period})
+	if err != nil {
+		s.logger.Error("failed to take rate limit token",
+			zap.String("user_id", userID.String()),
+			zap.String("route", route),
+			zap.Error(err))
+		return nil, err
+	}
+	if !result.Allowed {
+		s.logger.Warn("rate limit exceeded",
+			zap.String("user_id", userID.String()),
+			zap.String("route", route),
+			zap.Duration("retry_after", result.RetryAfter))
+	}
+	return result, nil
+}
+
+// PurgeFull removes the buckets that have refilled, including those of the login limits
+func (s *DefaultRateLimitService) PurgeFull(ctx context.Context) error {
+	purged, err := s.store.DeleteFull(ctx)
+	if err != nil {
+		s.logger.Error("failed to purge rate limit buckets",
+			zap.Error(err))
+		return err
+	}
+
+	s.logger.Debug("purged rate limit buckets",
+		zap.Int64("count", purged))
+	return nil
+}
+
+// ruleFor returns the route group with the longest prefix matching the route and its limit,
+// or an empty group and the default limit if none matches
+func (s *DefaultRateLimitService) ruleFor(route string) (string, config.RateLimitRule) {
+	group, rule := "", s.config.Default
+	for prefix, override := range s.config.Routes {
+		if route != prefix && !strings.HasPrefix(route, prefix+"/") {
+			continue
+		}
+		if len(prefix) > len(group) {
+			group, rule = prefix, override
+		}
+	}
+	return group, rule
+}
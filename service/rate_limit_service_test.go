@@ -0,0 +1,73 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+	"go.uber.org/zap"
+)
+
+func TestTakeRateLimit(t *testing.T) {
+	userID := uuid.New()
+	cfg := &config.RateLimitConfig{
+		Default: config.RateLimitRule{Burst: 100, Period: time.Minute},
+		Routes: map[string]config.RateLimitRule{
+			"/api/todos":                 {Burst: 50, Period: time.Minute},
+			"/api/todos/:id/attachments": {Burst: 10, Period: time.Minute},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		route         string
+		expectedKey   string
+		expectedLimit model.RateLimit
+	}{
+		{
+			name:          "route without an override uses the default",
+			route:         "/api/webhooks/:id",
+			expectedKey:   "api:" + userID.String(),
+			expectedLimit: model.RateLimit{Burst: 100, Period: time.Minute},
+		},
+		{
+			name:          "route group override",
+			route:         "/api/todos/:id",
+			expectedKey:   "api:" + userID.String() + ":/api/todos",
+			expectedLimit: model.RateLimit{Burst: 50, Period: time.Minute},
+		},
+		{
+			name:          "longest prefix wins",
+			route:         "/api/todos/:id/attachments/:attachmentId",
+			expectedKey:   "api:" + userID.String() + ":/api/todos/:id/attachments",
+			expectedLimit: model.RateLimit{Burst: 10, Period: time.Minute},
+		},
+		{
+			name:          "prefix only matches whole path segments",
+			route:         "/api/todosearch",
+			expectedKey:   "api:" + userID.String(),
+			expectedLimit: model.RateLimit{Burst: 100, Period: time.Minute},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := new(MockRateLimitStore)
+			expected := &model.RateLimitResult{Allowed: true, Limit: tt.expectedLimit.Burst}
+			store.On("Take", context.Background(), tt.expectedKey, tt.expectedLimit).Return(expected, nil)
+			rateLimitService := service.NewRateLimitService(store, cfg, zap.NewNop())
+
+			result, err := rateLimitService.Take(context.Background(), userID, tt.route)
+
+			require.NoError(t, err)
+			assert.Equal(t, expected, result)
+			store.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+	"go.uber.org/zap"
+)
+
+// ErrSessionNotFound is returned when a user has no session with the ID
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionService defines the interface for the login sessions of users on their devices
+type SessionService interface {
+	// List retrieves the sessions of the user that can still be refreshed
+	List(ctx context.Context, userID uuid.UUID) ([]model.Session, error)
+
+	// Revoke ends a session of the user, so that its refresh token can no longer be used
+	Revoke(ctx context.Context, userID, sessionID uuid.UUID) error
+
+	// RevokeAll ends every session of the user
+	RevokeAll(ctx context.Context, userID uuid.UUID) error
+
+	// PurgeExpired deletes expired and revoked sessions and returns how many were deleted
+	PurgeExpired(ctx context.Context) (int64, error)
+}
+
+// DefaultSessionService implements the SessionService interface
+type DefaultSessionService struct {
+	sessionRepo repository.SessionRepository
+	logger      *zap.Logger
+}
+
+// NewSessionService creates a new DefaultSessionService instance
+func NewSessionService(sessionRepo repository.SessionRepository, logger *zap.Logger) SessionService {
+	return &DefaultSessionService{
+		sessionRepo: sessionRepo,
+		logger:      logger,
+	}
+}
+
+// List retrieves the sessions of the user that can still be refreshed
+func (s *DefaultSessionService) List(ctx context.Context, userID uuid.UUID) ([]model.Session, error) {
+	sessions, err := s.sessionRepo.GetActiveByUserID(ctx, userID)
+	if err != nil {
+		s.logger.Error("failed to get sessions from repository",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// Revoke ends a session of the user, so that its refresh token can no longer be used
+// Access tokens already issued to the session stay valid until they expire
+func (s *DefaultSessionService) Revoke(ctx context.Context, userID, sessionID uuid.UUID) error {
+	deleted, err := s.sessionRepo.Delete(ctx, userID, sessionID)
+	if err != nil {
+		s.logger.Error("failed to delete session in repository",
+			zap.String("user_id", userID.String()),
+			zap.String("session_id", sessionID.String()),
+			zap.Error(err))
+		return err
+	}
+	if !deleted {
+		return ErrSessionNotFound
+	}
+
+	s.logger.Info("session revoked",
+		zap.String("user_id", userID.String()),
+		zap.String("session_id", sessionID.String()))
+	return nil
+}
+
+// RevokeAll ends every session of the user
+// Access tokens already issued to the sessions stay valid until they expire
+func (s *DefaultSessionService) RevokeAll(ctx context.Context, userID uuid.UUID) error {
+	revoked, err := s.sessionRepo.DeleteByUserID(ctx, userID)
+	if err != nil {
+		s.logger.Error("failed to delete sessions in repository",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return err
+	}
+
+	s.logger.Info("all sessions revoked",
+		zap.String("user_id", userID.String()),
+		zap.Int64("revoked", revoked))
+	return nil
+}
+
+// PurgeExpired deletes expired and revoked sessions and returns how many were deleted
+func (s *DefaultSessionService) PurgeExpired(ctx context.Context) (int64, error) {
+	purged, err := s.sessionRepo.DeleteExpired(ctx)
+	if err != nil {
+		s.logger.Error("failed to purge expired sessions",
+			zap.Error(err))
+		return purged, err
+	}
+
+	return purged, nil
+}
@@ -0,0 +1,121 @@
+package service_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+	"go.uber.org/zap"
+)
+
+func TestRevokeSession(t *testing.T) {
+	userID := uuid.New()
+	otherUserID := uuid.New()
+	sessionID := uuid.New()
+	repositoryError := errors.New("database error")
+
+	testCases := []struct {
+		name          string
+		userID        uuid.UUID
+		deleted       bool
+		deleteError   error
+		expectedError error
+	}{
+		{name: "Success", userID: userID, deleted: true},
+		{name: "Not found", userID: userID, deleted: false, expectedError: service.ErrSessionNotFound},
+		// The repository only deletes a session of the given user
+		{name: "Session of another user", userID: otherUserID, deleted: false, expectedError: service.ErrSessionNotFound},
+		{name: "Repository error", userID: userID, deleteError: repositoryError, expectedError: repositoryError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sessionRepo := new(MockSessionRepository)
+			sessionRepo.On("Delete", mock.Anything, tc.userID, sessionID).Return(tc.deleted, tc.deleteError)
+
+			err := service.NewSessionService(sessionRepo, zap.NewNop()).Revoke(context.Background(), tc.userID, sessionID)
+
+			assert.Equal(t, tc.expectedError, err)
+			sessionRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRevokeCurrentSession(t *testing.T) {
+	ctx := context.Background()
+	authConfig := config.NewAuthConfig("test-secret-key", 15*time.Minute, 24*time.Hour)
+	password := "password123"
+	hashedPassword, err := newTestPasswordHasher().Hash(password)
+	require.NoError(t, err)
+	user := &model.User{ID: uuid.New(), Email: "test@example.com", PasswordHash: hashedPassword}
+
+	// Log in, recording the session the tokens belong to
+	userRepo := new(MockUserRepository)
+	sessionRepo := new(MockSessionRepository)
+	authService := newAuthServiceWithSessions(userRepo, sessionRepo, newMockMFARepository(), authConfig)
+	sessionService := service.NewSessionService(sessionRepo, zap.NewNop())
+	userRepo.On("GetByEmail", ctx, "test@example.com").Return(user, nil)
+	userRepo.On("GetByID", ctx, user.ID).Return(user, nil)
+	var session *model.Session
+	sessionRepo.On("Create", ctx, mock.AnythingOfType("*model.Session"), 24*time.Hour).
+		Run(func(args mock.Arguments) { session = args.Get(1).(*model.Session) }).
+		Return(nil).Once()
+
+	result, err := authService.Authenticate(ctx, "test@example.com", password)
+	require.NoError(t, err)
+	refreshToken := result.RefreshToken
+	claims, err := authService.ValidateToken(result.AccessToken)
+	require.NoError(t, err)
+	currentSessionID, err := uuid.Parse(claims.SessionID)
+	require.NoError(t, err)
+	require.Equal(t, session.ID, currentSessionID)
+
+	ofCurrentSession := mock.MatchedBy(func(s *model.Session) bool { return s.ID == currentSessionID })
+
+	t.Run("another user cannot revoke it", func(t *testing.T) {
+		otherUserID := uuid.New()
+		sessionRepo.On("Delete", ctx, otherUserID, currentSessionID).Return(false, nil).Once()
+
+		err := sessionService.Revoke(ctx, otherUserID, currentSessionID)
+		assert.Equal(t, service.ErrSessionNotFound, err)
+
+		// The session can still be refreshed
+		refreshHash := sha256.Sum256([]byte(refreshToken))
+		sessionRepo.On("Rotate", ctx, ofCurrentSession, refreshHash[:], 24*time.Hour).Return(true, nil).Once()
+
+		tokenPair, err := authService.RefreshToken(ctx, refreshToken)
+		require.NoError(t, err)
+		refreshToken = tokenPair.RefreshToken
+		sessionRepo.AssertExpectations(t)
+	})
+
+	t.Run("the user revokes the session of the request", func(t *testing.T) {
+		sessionRepo.On("Delete", ctx, user.ID, currentSessionID).Return(true, nil).Once()
+
+		err := sessionService.Revoke(ctx, user.ID, currentSessionID)
+
+		require.NoError(t, err)
+		sessionRepo.AssertExpectations(t)
+	})
+
+	t.Run("refresh with the revoked session is rejected", func(t *testing.T) {
+		// The revoked session no longer exists, so it cannot be rotated
+		refreshHash := sha256.Sum256([]byte(refreshToken))
+		sessionRepo.On("Rotate", ctx, ofCurrentSession, refreshHash[:], 24*time.Hour).Return(false, nil).Once()
+
+		refreshed, err := authService.RefreshToken(ctx, refreshToken)
+
+		assert.Equal(t, service.ErrInvalidToken, err)
+		assert.Nil(t, refreshed)
+		sessionRepo.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrShareTargetNotFound is returned when the user to share a todo with does not exist
+	ErrShareTargetNotFound = errors.New("user to share with not found")
+
+	// ErrCannotShareWithOwner is returned when trying to share a todo with its owner
+	ErrCannotShareWithOwner = errors.New("cannot share a todo with its owner")
+
+	// ErrShareNotFound is returned when a todo is not shared with the specified user
+	ErrShareNotFound = errors.New("share not found")
+)
+
+// ShareService defines the interface for todo sharing business logic
+type ShareService interface {
+	// ShareTodo shares a todo owned by the specified user with the user having the given email
+	ShareTodo(ctx context.Context, userID uuid.UUID, todoID uuid.UUID, req model.ShareTodoRequest) (*model.TodoShare, error)
+
+	// GetShares retrieves all shares of a todo the specified user can view
+	GetShares(ctx context.Context, userID uuid.UUID, todoID uuid.UUID) ([]model.TodoShare, error)
+
+	// RemoveShare revokes the share of a todo for the target user
+	// The owner can revoke any share, other users can only remove their own
+	RemoveShare(ctx context.Context, userID uuid.UUID, todoID uuid.UUID, targetUserID uuid.UUID) error
+}
+
+// DefaultShareService implements the ShareService interface
+type DefaultShareService struct {
+	todoService  TodoService
+	shareRepo    repository.TodoShareRepository
+	userRepo     repository.UserRepository
+	activityRepo repository.ActivityRepository
+	transactor   repository.Transactor
+	logger       *zap.Logger
+}
+
+// NewShareService creates a new DefaultShareService instance
+func NewShareService(
+	todoService TodoService,
+	shareRepo repository.TodoShareRepository,
+	userRepo repository.UserRepository,
+	activityRepo repository.ActivityRepository,
+	transactor repository.Transactor,
+	logger *zap.Logger,
+) ShareService {
+	return &DefaultShareService{
+		todoService:  todoService,
+		shareRepo:    shareRepo,
+		userRepo:     userRepo,
+		activityRepo: activityRepo,
+		transactor:   transactor,
+		logger:       logger,
+	}
+}
+
+// ShareTodo shares a todo owned by the specified user with the user having the given email
+func (s *DefaultShareService) ShareTodo(ctx context.Context, userID uuid.UUID, todoID uuid.UUID, req model.ShareTodoRequest) (*model.TodoShare, error) {
+	// Only the owner can manage shares
+	todo, _, err := s.todoService.AuthorizeTodo(ctx, userID, todoID, model.ShareRoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := s.userRepo.GetByEmail(ctx, req.Email)
+	if err != nil {
+		s.logger.Warn("share target user not found",
+			zap.String("user_id", userID.String()),
+			zap.String("todo_id", todoID.String()),
+			zap.String("email", req.Email),
+			zap.Error(err))
+		return nil, ErrShareTargetNotFound
+	}
+
+	if target.ID == todo.UserID {
+		return nil, ErrCannotShareWithOwner
+	}
+
+	var share *model.TodoShare
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		// Look up the current share, if any, to record the role change
+		var before activitySnapshot
+		action := model.ActivityActionCreated
+		existing, err := s.shareRepo.Get(ctx, todoID, target.ID)
+		if err == nil {
+			before = shareSnapshot(existing)
+			action = model.ActivityActionUpdated
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		err = s.shareRepo.Upsert(ctx, &model.TodoShare{
+			TodoID: todoID,
+			UserID: target.ID,
+			Role:   model.ShareRole(req.Role),
+		})
+		if err != nil {
+			return err
+		}
+
+		// Reload to get the timestamps of an existing share
+		share, err = s.shareRepo.Get(ctx, todoID, target.ID)
+		if err != nil {
+			return err
+		}
+
+		return recordActivity(ctx, s.activityRepo, userID, model.ActivityEntityShare, target.ID, &todoID,
+			action, before, shareSnapshot(share))
+	})
+	if err != nil {
+		s.logger.Error("failed to share todo",
+			zap.String("user_id", userID.String()),
+			zap.String("todo_id", todoID.String()),
+			zap.String("target_user_id", target.ID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("todo shared successfully",
+		zap.String("user_id", userID.String()),
+		zap.String("todo_id", todoID.String()),
+		zap.String("target_user_id", target.ID.String()),
+		zap.String("role", req.Role))
+	return share, nil
+}
+
+// GetShares retrieves all shares of a todo the specified user can view
+func (s *DefaultShareService) GetShares(ctx context.Context, userID uuid.UUID, todoID uuid.UUID) ([]model.TodoShare, error) {
+	_, _, err := s.todoService.AuthorizeTodo(ctx, userID, todoID, model.ShareRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	shares, err := s.shareRepo.GetByTodoID(ctx, todoID)
+	if err != nil {
+		s.logger.Error("failed to get todo shares",
+			zap.String("user_id", userID.String()),
+			zap.String("todo_id", todoID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	return shares, nil
+}
+
+// RemoveShare revokes the share of a todo for the target user
+func (s *DefaultShareService) RemoveShare(ctx context.Context, userID uuid.UUID, todoID uuid.UUID, targetUserID uuid.UUID) error {
+	// Users may leave a todo shared with them, otherwise only the owner can revoke shares
+	required := model.ShareRoleOwner
+	if targetUserID == userID {
+		required = model.ShareRoleViewer
+	}
+	_, _, err := s.todoService.AuthorizeTodo(ctx, userID, todoID, required)
+	if err != nil {
+		return err
+	}
+
+	share, err := s.shareRepo.Get(ctx, todoID, targetUserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrShareNotFound
+		}
+		s.logger.Error("failed to get todo share",
+			zap.String("todo_id", todoID.String()),
+			zap.String("target_user_id", targetUserID.String()),
+			zap.Error(err))
+		return err
+	}
+
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.shareRepo.Delete(ctx, todoID, targetUserID); err != nil {
+			return err
+		}
+		return recordActivity(ctx, s.activityRepo, userID, model.ActivityEntityShare, targetUserID, &todoID,
+			model.ActivityActionDeleted, shareSnapshot(share), nil)
+	})
+	if err != nil {
+		s.logger.Error("failed to remove todo share",
+			zap.String("user_id", userID.String()),
+			zap.String("todo_id", todoID.String()),
+			zap.String("target_user_id", targetUserID.String()),
+			zap.Error(err))
+		return err
+	}
+
+	s.logger.Info("todo share removed successfully",
+		zap.String("user_id", userID.String()),
+		zap.String("todo_id", todoID.String()),
+		zap.String("target_user_id", targetUserID.String()))
+	return nil
+}
+
+// shareSnapshot returns the recorded state of a share
+func shareSnapshot(share *model.TodoShare) activitySnapshot {
+	return activitySnapshot{
+		"email": share.Email,
+		"role":  share.Role,
+	}
+}
@@ -0,0 +1,212 @@
+package service_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+	"go.uber.org/zap"
+)
+
+func TestShareTodo(t *testing.T) {
+	// Create a test logger
+	logger := zap.NewNop()
+	ctx := context.Background()
+
+	// Setup common test data
+	ownerID := uuid.New()
+	targetID := uuid.New()
+	todoID := uuid.New()
+	request := model.ShareTodoRequest{
+		Email: "friend@example.com",
+		Role:  string(model.ShareRoleEditor),
+	}
+
+	// Test cases
+	testCases := []struct {
+		name          string
+		userID        uuid.UUID
+		setupMock     func(*MockTodoShareRepository, *MockUserRepository)
+		expectedError error
+	}{
+		{
+			name:   "Success",
+			userID: ownerID,
+			setupMock: func(s *MockTodoShareRepository, u *MockUserRepository) {
+				u.On("GetByEmail", mock.Anything, request.Email).Return(&model.User{ID: targetID, Email: request.Email}, nil)
+				s.On("Upsert", mock.Anything, mock.MatchedBy(func(share *model.TodoShare) bool {
+					return share.TodoID == todoID && share.UserID == targetID && share.Role == model.ShareRoleEditor
+				})).Return(nil)
+				s.On("Get", mock.Anything, todoID, targetID).Return(&model.TodoShare{
+					TodoID: todoID,
+					UserID: targetID,
+					Email:  request.Email,
+					Role:   model.ShareRoleEditor,
+				}, nil)
+			},
+			expectedError: nil,
+		},
+		{
+			name:   "Not Owner",
+			userID: targetID,
+			setupMock: func(s *MockTodoShareRepository, u *MockUserRepository) {
+				s.On("Get", mock.Anything, todoID, targetID).Return(&model.TodoShare{Role: model.ShareRoleEditor}, nil)
+			},
+			expectedError: service.ErrUnauthorized,
+		},
+		{
+			name:   "Target User Not Found",
+			userID: ownerID,
+			setupMock: func(s *MockTodoShareRepository, u *MockUserRepository) {
+				u.On("GetByEmail", mock.Anything, request.Email).Return(nil, sql.ErrNoRows)
+			},
+			expectedError: service.ErrShareTargetNotFound,
+		},
+		{
+			name:   "Share With Owner",
+			userID: ownerID,
+			setupMock: func(s *MockTodoShareRepository, u *MockUserRepository) {
+				u.On("GetByEmail", mock.Anything, request.Email).Return(&model.User{ID: ownerID, Email: request.Email}, nil)
+			},
+			expectedError: service.ErrCannotShareWithOwner,
+		},
+		{
+			name:   "Repository Error",
+			userID: ownerID,
+			setupMock: func(s *MockTodoShareRepository, u *MockUserRepository) {
+				u.On("GetByEmail", mock.Anything, request.Email).Return(&model.User{ID: targetID, Email: request.Email}, nil)
+				s.On("Get", mock.Anything, todoID, targetID).Return(nil, sql.ErrNoRows)
+				s.On("Upsert", mock.Anything, mock.Anything).Return(errors.New("database error"))
+			},
+			expectedError: errors.New("database error"),
+		},
+	}
+
+	// Run test cases
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Setup
+			mockTodoRepo := new(MockTodoRepository)
+			mockTodoRepo.On("GetByID", mock.Anything, todoID).Return(&model.Todo{ID: todoID, UserID: ownerID}, nil)
+			mockShareRepo := new(MockTodoShareRepository)
+			mockUserRepo := new(MockUserRepository)
+			tc.setupMock(mockShareRepo, mockUserRepo)
+
+			todoService := service.NewTodoService(mockTodoRepo, mockShareRepo, newMockActivityRepository(), new(MockTransactor), config.DefaultTodoQuotaConfig(), logger)
+			shareService := service.NewShareService(todoService, mockShareRepo, mockUserRepo, newMockActivityRepository(), new(MockTransactor), logger)
+
+			// Execute
+			share, err := shareService.ShareTodo(ctx, tc.userID, todoID, request)
+
+			// Verify
+			if tc.expectedError != nil {
+				assert.Error(t, err)
+				assert.Equal(t, tc.expectedError.Error(), err.Error())
+				assert.Nil(t, share)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, targetID, share.UserID)
+				assert.Equal(t, model.ShareRoleEditor, share.Role)
+			}
+
+			// Verify mock expectations
+			mockShareRepo.AssertExpectations(t)
+			mockUserRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRemoveShare(t *testing.T) {
+	// Create a test logger
+	logger := zap.NewNop()
+	ctx := context.Background()
+
+	// Setup common test data
+	ownerID := uuid.New()
+	sharedUserID := uuid.New()
+	otherUserID := uuid.New()
+	todoID := uuid.New()
+	viewerShare := &model.TodoShare{TodoID: todoID, UserID: sharedUserID, Role: model.ShareRoleViewer}
+
+	// Test cases
+	testCases := []struct {
+		name          string
+		userID        uuid.UUID
+		targetUserID  uuid.UUID
+		setupMock     func(*MockTodoShareRepository)
+		expectedError error
+	}{
+		{
+			name:         "Owner Revokes Share",
+			userID:       ownerID,
+			targetUserID: sharedUserID,
+			setupMock: func(m *MockTodoShareRepository) {
+				m.On("Get", mock.Anything, todoID, sharedUserID).Return(viewerShare, nil)
+				m.On("Delete", mock.Anything, todoID, sharedUserID).Return(nil)
+			},
+			expectedError: nil,
+		},
+		{
+			name:         "Shared User Leaves",
+			userID:       sharedUserID,
+			targetUserID: sharedUserID,
+			setupMock: func(m *MockTodoShareRepository) {
+				m.On("Get", mock.Anything, todoID, sharedUserID).Return(viewerShare, nil)
+				m.On("Delete", mock.Anything, todoID, sharedUserID).Return(nil)
+			},
+			expectedError: nil,
+		},
+		{
+			name:         "Shared User Cannot Revoke Others",
+			userID:       sharedUserID,
+			targetUserID: otherUserID,
+			setupMock: func(m *MockTodoShareRepository) {
+				m.On("Get", mock.Anything, todoID, sharedUserID).Return(viewerShare, nil)
+			},
+			expectedError: service.ErrUnauthorized,
+		},
+		{
+			name:         "Share Not Found",
+			userID:       ownerID,
+			targetUserID: otherUserID,
+			setupMock: func(m *MockTodoShareRepository) {
+				m.On("Get", mock.Anything, todoID, otherUserID).Return(nil, sql.ErrNoRows)
+			},
+			expectedError: service.ErrShareNotFound,
+		},
+	}
+
+	// Run test cases
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Setup
+			mockTodoRepo := new(MockTodoRepository)
+			mockTodoRepo.On("GetByID", mock.Anything, todoID).Return(&model.Todo{ID: todoID, UserID: ownerID}, nil)
+			mockShareRepo := new(MockTodoShareRepository)
+			tc.setupMock(mockShareRepo)
+
+			todoService := service.NewTodoService(mockTodoRepo, mockShareRepo, newMockActivityRepository(), new(MockTransactor), config.DefaultTodoQuotaConfig(), logger)
+			shareService := service.NewShareService(todoService, mockShareRepo, new(MockUserRepository), newMockActivityRepository(), new(MockTransactor), logger)
+
+			// Execute
+			err := shareService.RemoveShare(ctx, tc.userID, todoID, tc.targetUserID)
+
+			// Verify
+			if tc.expectedError != nil {
+				assert.Equal(t, tc.expectedError, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			// Verify mock expectations
+			mockShareRepo.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrInvalidStreamTicket is returned when a stream ticket is forged, expired or already used
+	ErrInvalidStreamTicket = errors.New("invalid stream ticket")
+)
+
+// StreamTicketService defines the interface for the tickets that open real-time streams
+// Browsers cannot set headers on EventSource and WebSocket connections, so they pass a ticket in the URL
+// instead of an access token, which would end up in access logs
+type StreamTicketService interface {
+	// IssueTicket issues a short-lived, single-use ticket that opens a stream for the user
+	IssueTicket(ctx context.Context, userID uuid.UUID) (string, error)
+
+	// RedeemTicket consumes a stream ticket and returns the access token claims of its user as they are now
+	RedeemTicket(ctx context.Context, ticket string) (*Claims, error)
+}
+
+// DefaultStreamTicketService implements the StreamTicketService interface
+type DefaultStreamTicketService struct {
+	userRepo      repository.UserRepository
+	userTokenRepo repository.UserTokenRepository
+	authConfig    *config.AuthConfig
+	config        *config.StreamConfig
+	logger        *zap.Logger
+}
+
+// NewStreamTicketService creates a new DefaultStreamTicketService instance
+func NewStreamTicketService(
+	userRepo repository.UserRepository,
+	userTokenRepo repository.UserTokenRepository,
+	authConfig *config.AuthConfig,
+	cfg *config.StreamConfig,
+	logger *zap.Logger,
+) StreamTicketService {
+	return &DefaultStreamTicketService{
+		userRepo:      userRepo,
+		userTokenRepo: userTokenRepo,
+		authConfig:    authConfig,
+		config:        cfg,
+		logger:        logger,
+	}
+}
+
+// IssueTicket issues a short-lived, single-use ticket that opens a stream for the user
+// Tickets issued before are kept, so that several tabs can connect at once
+func (s *DefaultStreamTicketService) IssueTicket(ctx context.Context, userID uuid.UUID) (string, error) {
+	token, ticket, err := newUserToken(s.authConfig.JWTSecret, userID, model.UserTokenStreamTicket)
+	if err != nil {
+		s.logger.Error("failed to generate stream ticket",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return "", err
+	}
+
+	if err := s.userTokenRepo.Create(ctx, token, s.config.TicketTTL); err != nil {
+		s.logger.Error("failed to store stream ticket",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return "", err
+	}
+
+	s.logger.Info("stream ticket issued",
+		zap.String("user_id", userID.String()))
+	return ticket, nil
+}
+
+// RedeemTicket consumes a stream ticket and returns the access token claims of its user as they are now
+// Disabled users are refused with ErrAccountDisabled
+func (s *DefaultStreamTicketService) RedeemTicket(ctx context.Context, ticket string) (*Claims, error) {
+	tokenHash, ok := parseUserToken(s.authConfig.JWTSecret, model.UserTokenStreamTicket, ticket)
+	if !ok {
+		s.logger.Warn("stream ticket with invalid signature")
+		return nil, ErrInvalidStreamTicket
+	}
+
+	consumed, err := s.userTokenRepo.Consume(ctx, tokenHash, model.UserTokenStreamTicket)
+	if errors.Is(err, sql.ErrNoRows) {
+		s.logger.Warn("expired or used stream ticket")
+		return nil, ErrInvalidStreamTicket
+	}
+	if err != nil {
+		s.logger.Error("failed to consume stream ticket",
+			zap.Error(err))
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, consumed.UserID)
+	if err != nil {
+		s.logger.Error("failed to get user of stream ticket",
+			zap.String("user_id", consumed.UserID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+	if user.Disabled() {
+		s.logger.Info("stream ticket refused for disabled account",
+			zap.String("user_id", user.ID.String()))
+		return nil, ErrAccountDisabled
+	}
+
+	return &Claims{
+		UserID:         user.ID.String(),
+		Email:          user.Email,
+		EmailVerified:  user.EmailVerified(),
+		SessionVersion: user.SessionVersion,
+		Type:           string(AccessToken),
+		Roles:          user.Roles(),
+	}, nil
+}
@@ -0,0 +1,126 @@
+package service_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+	"go.uber.org/zap"
+)
+
+// newStreamTicketService creates a StreamTicketService with the mocks
+func newStreamTicketService(userRepo *MockUserRepository, userTokenRepo *MockUserTokenRepository) service.StreamTicketService {
+	return service.NewStreamTicketService(userRepo, userTokenRepo,
+		config.NewAuthConfig("test-secret", time.Minute, time.Hour), config.DefaultStreamConfig(), zap.NewNop())
+}
+
+// issueStreamTicket issues a stream ticket for the user and returns it, with the ticket as stored
+func issueStreamTicket(t *testing.T, userID uuid.UUID) (string, *model.UserToken) {
+	userTokenRepo := new(MockUserTokenRepository)
+	issued := &model.UserToken{}
+	userTokenRepo.On("Create", mock.Anything, mock.MatchedBy(func(token *model.UserToken) bool {
+		return token.UserID == userID && token.Purpose == model.UserTokenStreamTicket && len(token.TokenHash) > 0
+	}), config.DefaultStreamTicketTTL).Run(func(args mock.Arguments) {
+		*issued = *args.Get(1).(*model.UserToken)
+	}).Return(nil)
+
+	ticket, err := newStreamTicketService(new(MockUserRepository), userTokenRepo).IssueTicket(context.Background(), userID)
+
+	require.NoError(t, err)
+	require.NotEmpty(t, ticket)
+	userTokenRepo.AssertExpectations(t)
+	return ticket, issued
+}
+
+func TestIssueStreamTicket(t *testing.T) {
+	t.Run("Earlier tickets are kept", func(t *testing.T) {
+		// DeleteUnused is not expected, so several tabs can connect at once
+		first, _ := issueStreamTicket(t, uuid.New())
+		second, _ := issueStreamTicket(t, uuid.New())
+
+		assert.NotEqual(t, first, second)
+	})
+
+	t.Run("Repository error", func(t *testing.T) {
+		userTokenRepo := new(MockUserTokenRepository)
+		userTokenRepo.On("Create", mock.Anything, mock.Anything, config.DefaultStreamTicketTTL).Return(errors.New("database error"))
+
+		ticket, err := newStreamTicketService(new(MockUserRepository), userTokenRepo).IssueTicket(context.Background(), uuid.New())
+
+		assert.Error(t, err)
+		assert.Empty(t, ticket)
+	})
+}
+
+func TestRedeemStreamTicket(t *testing.T) {
+	verifiedAt := time.Now()
+	user := &model.User{ID: uuid.New(), Email: "test@example.com", EmailVerifiedAt: &verifiedAt, SessionVersion: 3, Role: model.RoleAdmin}
+
+	t.Run("Returns the claims of the user", func(t *testing.T) {
+		ticket, issued := issueStreamTicket(t, user.ID)
+
+		userRepo := new(MockUserRepository)
+		userTokenRepo := new(MockUserTokenRepository)
+		userTokenRepo.On("Consume", mock.Anything, issued.TokenHash, model.UserTokenStreamTicket).Return(issued, nil)
+		userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+
+		claims, err := newStreamTicketService(userRepo, userTokenRepo).RedeemTicket(context.Background(), ticket)
+
+		require.NoError(t, err)
+		assert.Equal(t, user.ID.String(), claims.UserID)
+		assert.Equal(t, string(service.AccessToken), claims.Type)
+		assert.True(t, claims.EmailVerified)
+		assert.Equal(t, 3, claims.SessionVersion)
+		assert.True(t, claims.HasRole(model.RoleAdmin))
+		assert.False(t, claims.Delegated())
+		userRepo.AssertExpectations(t)
+		userTokenRepo.AssertExpectations(t)
+	})
+
+	t.Run("Used or expired ticket", func(t *testing.T) {
+		ticket, issued := issueStreamTicket(t, user.ID)
+
+		userTokenRepo := new(MockUserTokenRepository)
+		userTokenRepo.On("Consume", mock.Anything, issued.TokenHash, model.UserTokenStreamTicket).Return(nil, sql.ErrNoRows)
+
+		claims, err := newStreamTicketService(new(MockUserRepository), userTokenRepo).RedeemTicket(context.Background(), ticket)
+
+		assert.Equal(t, service.ErrInvalidStreamTicket, err)
+		assert.Nil(t, claims)
+	})
+
+	t.Run("Forged ticket is not looked up", func(t *testing.T) {
+		userTokenRepo := new(MockUserTokenRepository)
+
+		claims, err := newStreamTicketService(new(MockUserRepository), userTokenRepo).RedeemTicket(context.Background(), "forged.ticket")
+
+		assert.Equal(t, service.ErrInvalidStreamTicket, err)
+		assert.Nil(t, claims)
+		userTokenRepo.AssertNotCalled(t, "Consume", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Disabled account", func(t *testing.T) {
+		ticket, issued := issueStreamTicket(t, user.ID)
+		disabled := *user
+		disabled.DisabledAt = &verifiedAt
+
+		userRepo := new(MockUserRepository)
+		userTokenRepo := new(MockUserTokenRepository)
+		userTokenRepo.On("Consume", mock.Anything, issued.TokenHash, model.UserTokenStreamTicket).Return(issued, nil)
+		userRepo.On("GetByID", mock.Anything, user.ID).Return(&disabled, nil)
+
+		claims, err := newStreamTicketService(userRepo, userTokenRepo).RedeemTicket(context.Background(), ticket)
+
+		assert.Equal(t, service.ErrAccountDisabled, err)
+		assert.Nil(t, claims)
+	})
+}
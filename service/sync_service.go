@@ -0,0 +1,277 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultSyncPageSize is the number of changes returned when no limit is given
+	DefaultSyncPageSize = 500
+
+	// MaxSyncPageSize is the maximum number of changes returned in a single page
+	MaxSyncPageSize = 1000
+)
+
+// ErrInvalidSyncToken is returned when a sync token cannot be decoded
+var ErrInvalidSyncToken = errors.New("invalid sync token")
+
+// SyncService defines the interface for offline sync business logic
+type SyncService interface {
+	// GetChanges retrieves a page of the changes to the todos of the specified user since the sync token
+	// An empty token returns every todo the user can see, without deletions
+	GetChanges(ctx context.Context, userID uuid.UUID, since string, limit int) (*model.SyncChanges, error)
+
+	// ApplyMutations applies a batch of client mutations in order, each in its own transaction
+	// A mutation based on an outdated version of a todo is not applied and is reported as a conflict
+	ApplyMutations(ctx context.Context, userID uuid.UUID, mutations []model.SyncMutation) ([]model.SyncMutationResult, error)
+}
+
+// DefaultSyncService implements the SyncService interface
+type DefaultSyncService struct {
+	todoService  TodoService
+	syncRepo     repository.SyncRepository
+	todoRepo     repository.TodoRepository
+	activityRepo repository.ActivityRepository
+	transactor   repository.Transactor
+	logger       *zap.Logger
+}
+
+// NewSyncService creates a new DefaultSyncService instance
+func NewSyncService(
+	todoService TodoService,
+	syncRepo repository.SyncRepository,
+	todoRepo repository.TodoRepository,
+	activityRepo repository.ActivityRepository,
+	transactor repository.Transactor,
+	logger *zap.Logger,
+) SyncService {
+	return &DefaultSyncService{
+		todoService:  todoService,
+		syncRepo:     syncRepo,
+		todoRepo:     todoRepo,
+		activityRepo: activityRepo,
+		transactor:   transactor,
+		logger:       logger,
+	}
+}
+
+// GetChanges retrieves a page of the changes to the todos of the specified user since the sync token
+func (s *DefaultSyncService) GetChanges(ctx context.Context, userID uuid.UUID, since string, limit int) (*model.SyncChanges, error) {
+	after, err := decodeSyncToken(since)
+	if err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = DefaultSyncPageSize
+	}
+	if limit > MaxSyncPageSize {
+		limit = MaxSyncPageSize
+	}
+
+	// Fetch one extra change of each kind to find out whether there is a next page
+	todos, err := s.syncRepo.GetChangedTodos(ctx, userID, after, limit+1)
+	if err != nil {
+		s.logger.Error("failed to get changed todos",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	// A client syncing for the first time has nothing to delete
+	var tombstones []model.TodoTombstone
+	if after > 0 {
+		tombstones, err = s.syncRepo.GetTombstones(ctx, userID, after, limit+1)
+		if err != nil {
+			s.logger.Error("failed to get todo tombstones",
+				zap.String("user_id", userID.String()),
+				zap.Error(err))
+			return nil, err
+		}
+	}
+
+	// Merge both kinds of change in sequence order, stopping at the page size
+	changes := &model.SyncChanges{
+		Todos:   []model.SyncTodo{},
+		Deleted: []model.TodoTombstone{},
+	}
+	last := after
+	i, j := 0, 0
+	for n := 0; n < limit && (i < len(todos) || j < len(tombstones)); n++ {
+		if j >= len(tombstones) || (i < len(todos) && todos[i].SyncSeq < tombstones[j].ChangeSeq) {
+			changes.Todos = append(changes.Todos, todos[i])
+			last = todos[i].SyncSeq
+			i++
+		} else {
+			changes.Deleted = append(changes.Deleted, tombstones[j])
+			last = tombstones[j].ChangeSeq
+			j++
+		}
+	}
+	changes.HasMore = i < len(todos) || j < len(tombstones)
+	changes.NextToken = encodeSyncToken(&model.SyncToken{Seq: last})
+
+	s.logger.Info("retrieved sync changes successfully",
+		zap.String("user_id", userID.String()),
+		zap.Int("todos", len(changes.Todos)),
+		zap.Int("deleted", len(changes.Deleted)),
+		zap.Bool("has_more", changes.HasMore))
+	return changes, nil
+}
+
+// ApplyMutations applies a batch of client mutations in order, each in its own transaction
+func (s *DefaultSyncService) ApplyMutations(ctx context.Context, userID uuid.UUID, mutations []model.SyncMutation) ([]model.SyncMutationResult, error) {
+	results := make([]model.SyncMutationResult, len(mutations))
+	for i, mutation := range mutations {
+		results[i] = s.applyMutation(ctx, userID, mutation)
+	}
+
+	s.logger.Info("applied sync mutations",
+		zap.String("user_id", userID.String()),
+		zap.Int("count", len(mutations)))
+	return results, nil
+}
+
+// applyMutation applies a single mutation, reporting the state of the todo on the server afterwards
+// The todo is locked while its version is compared, so a concurrent change cannot slip in between
+func (s *DefaultSyncService) applyMutation(ctx context.Context, userID uuid.UUID, mutation model.SyncMutation) model.SyncMutationResult {
+	result := model.SyncMutationResult{ID: mutation.ID}
+
+	todoID, err := uuid.Parse(mutation.ID)
+	if err != nil {
+		result.Status = model.SyncStatusRejected
+		result.Error = model.InvalidTodoIDFormatResponse
+		return result
+	}
+
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		current, err := s.todoRepo.GetByIDForUpdate(ctx, todoID)
+		if errors.Is(err, sql.ErrNoRows) {
+			current = nil
+		} else if err != nil {
+			return err
+		}
+
+		// The todo must be visible to the user, so the IDs of other users' todos are not revealed
+		if current != nil {
+			if _, _, err := s.todoService.AuthorizeTodo(ctx, userID, todoID, model.ShareRoleViewer); err != nil {
+				return err
+			}
+		}
+
+		switch {
+		case current == nil && mutation.Op == model.SyncOpDelete:
+			// Already deleted, which is what the client wants
+			result.Status = model.SyncStatusApplied
+			return nil
+		case current == nil && mutation.BaseVersion > 0:
+			// Updated by the client but deleted on the server
+			result.Status = model.SyncStatusConflict
+			return nil
+		case current != nil && mutation.BaseVersion != current.Version:
+			// Changed on the server since the client saw it, or created by the client with an ID already in use
+			result.Status = model.SyncStatusConflict
+			result.Todo = newSyncTodoResponse(current)
+			return nil
+		}
+
+		switch {
+		case mutation.Op == model.SyncOpDelete:
+			if err := s.todoService.DeleteTodo(ctx, userID, todoID); err != nil {
+				return err
+			}
+		case current == nil:
+			todo := &model.Todo{
+				ID:          todoID,
+				UserID:      userID,
+				Title:       mutation.Todo.Title,
+				Description: mutation.Todo.Description,
+				DueDate:     mutation.Todo.DueDate,
+				IsCompleted: mutation.Todo.IsCompleted,
+			}
+			if err := s.todoService.CheckQuota(ctx, userID, todo.Description, true); err != nil {
+				return err
+			}
+			if err := insertTodo(ctx, s.todoRepo, s.activityRepo, todo); err != nil {
+				return err
+			}
+		default:
+			if _, err := s.todoService.UpdateTodo(ctx, userID, todoID, *mutation.Todo); err != nil {
+				return err
+			}
+		}
+
+		// Reload to get the version assigned by the database
+		todo, err := s.todoRepo.GetByID(ctx, todoID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		result.Status = model.SyncStatusApplied
+		result.Todo = newSyncTodoResponse(todo)
+		return nil
+	})
+	if err != nil {
+		result.Status = model.SyncStatusRejected
+		result.Todo = nil
+		switch err {
+		case ErrUnauthorized:
+			result.Error = model.NoPermissionToAccessTodoResponse
+		case ErrTodoQuotaExceeded:
+			result.Error = model.TodoQuotaExceededResponse
+		case ErrDescriptionTooLong:
+			result.Error = model.DescriptionTooLongResponse
+		default:
+			s.logger.Error("failed to apply sync mutation",
+				zap.String("user_id", userID.String()),
+				zap.String("todo_id", todoID.String()),
+				zap.String("op", mutation.Op),
+				zap.Error(err))
+			result.Error = model.FailedToOperateResponse
+		}
+	}
+
+	return result
+}
+
+// newSyncTodoResponse creates a SyncTodoResponse for a mutation result, or nil if the todo does not exist
+func newSyncTodoResponse(todo *model.Todo) *model.SyncTodoResponse {
+	if todo == nil {
+		return nil
+	}
+	response := model.NewSyncTodoResponse(todo)
+	return &response
+}
+
+// encodeSyncToken encodes a sync token as an opaque string for clients
+func encodeSyncToken(token *model.SyncToken) string {
+	data, _ := json.Marshal(token)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeSyncToken decodes a sync token received from a client, returning 0 for a first sync
+func decodeSyncToken(token string) (int64, error) {
+	if token == "" {
+		return 0, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, ErrInvalidSyncToken
+	}
+
+	var decoded model.SyncToken
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.Seq < 0 {
+		return 0, ErrInvalidSyncToken
+	}
+
+	return decoded.Seq, nil
+}
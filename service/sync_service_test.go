@@ -0,0 +1,288 @@
+package service_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+	"github.com/yukimaterrace/todoms/service"
+	"go.uber.org/zap"
+)
+
+// setupSyncService creates a sync service for todos owned by ownerID and shared with viewerID
+func setupSyncService(ownerID, viewerID uuid.UUID, todoRepo repository.TodoRepository) (service.SyncService, *MockSyncRepository) {
+	logger := zap.NewNop()
+
+	mockShareRepo := new(MockTodoShareRepository)
+	mockShareRepo.On("Get", mock.Anything, mock.Anything, viewerID).Return(&model.TodoShare{Role: model.ShareRoleViewer}, nil)
+	mockShareRepo.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(nil, sql.ErrNoRows)
+	mockSyncRepo := new(MockSyncRepository)
+
+	activityRepo := newMockActivityRepository()
+	todoService := service.NewTodoService(todoRepo, mockShareRepo, activityRepo, new(MockTransactor), config.DefaultTodoQuotaConfig(), logger)
+	return service.NewSyncService(todoService, mockSyncRepo, todoRepo, activityRepo, new(MockTransactor), logger), mockSyncRepo
+}
+
+// storedTodoRepository is a MockTodoRepository that holds a single todo, so that reads return what was written
+type storedTodoRepository struct {
+	*MockTodoRepository
+	stored *model.Todo
+}
+
+func newStoredTodoRepository(todo *model.Todo) *storedTodoRepository {
+	r := &storedTodoRepository{MockTodoRepository: new(MockTodoRepository), stored: todo}
+	r.On("CountByUserIDForUpdate", mock.Anything, mock.Anything).Return(0, nil)
+	r.On("Create", mock.Anything, mock.AnythingOfType("*model.Todo")).Return(nil).Run(func(args mock.Arguments) {
+		created := *args.Get(1).(*model.Todo)
+		created.Version = 1
+		r.stored = &created
+	})
+	r.On("Update", mock.Anything, mock.AnythingOfType("*model.Todo")).Return(nil).Run(func(args mock.Arguments) {
+		updated := *args.Get(1).(*model.Todo)
+		updated.Version = r.stored.Version + 1
+		r.stored = &updated
+	})
+	r.On("Delete", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		r.stored = nil
+	})
+	return r
+}
+
+func (r *storedTodoRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Todo, error) {
+	if r.stored == nil || r.stored.ID != id {
+		return nil, sql.ErrNoRows
+	}
+	todo := *r.stored
+	return &todo, nil
+}
+
+func (r *storedTodoRepository) GetByIDForUpdate(ctx context.Context, id uuid.UUID) (*model.Todo, error) {
+	return r.GetByID(ctx, id)
+}
+
+func TestGetChanges(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+
+	changed := func(seq int64) model.SyncTodo {
+		return model.SyncTodo{Todo: model.Todo{ID: uuid.New(), UserID: userID}, SyncSeq: seq}
+	}
+	deleted := func(seq int64) model.TodoTombstone {
+		return model.TodoTombstone{TodoID: uuid.New(), UserID: userID, ChangeSeq: seq}
+	}
+
+	t.Run("changes are merged in sequence order and paged", func(t *testing.T) {
+		syncService, syncRepo := setupSyncService(userID, uuid.New(), new(MockTodoRepository))
+
+		todos := []model.SyncTodo{changed(11), changed(14), changed(15)}
+		tombstones := []model.TodoTombstone{deleted(12), deleted(13)}
+		syncRepo.On("GetChangedTodos", mock.Anything, userID, int64(0), 2).Return(todos, nil)
+		syncRepo.On("GetChangedTodos", mock.Anything, userID, int64(11), 4).Return(todos[1:], nil)
+		syncRepo.On("GetTombstones", mock.Anything, userID, int64(11), 4).Return(tombstones, nil)
+		syncRepo.On("GetChangedTodos", mock.Anything, userID, int64(14), 4).Return(todos[2:], nil)
+		syncRepo.On("GetTombstones", mock.Anything, userID, int64(14), 4).Return([]model.TodoTombstone{}, nil)
+
+		// A first sync has nothing to delete
+		first, err := syncService.GetChanges(ctx, userID, "", 1)
+
+		require.NoError(t, err)
+		require.Len(t, first.Todos, 1)
+		assert.Equal(t, todos[0].ID, first.Todos[0].ID)
+		assert.True(t, first.HasMore)
+		syncRepo.AssertNotCalled(t, "GetTombstones", mock.Anything, mock.Anything, int64(0), mock.Anything)
+
+		second, err := syncService.GetChanges(ctx, userID, first.NextToken, 3)
+
+		require.NoError(t, err)
+		require.Len(t, second.Deleted, 2)
+		assert.Equal(t, tombstones[0].TodoID, second.Deleted[0].TodoID)
+		assert.Equal(t, tombstones[1].TodoID, second.Deleted[1].TodoID)
+		require.Len(t, second.Todos, 1)
+		assert.Equal(t, todos[1].ID, second.Todos[0].ID)
+		assert.True(t, second.HasMore)
+
+		third, err := syncService.GetChanges(ctx, userID, second.NextToken, 3)
+
+		require.NoError(t, err)
+		require.Len(t, third.Todos, 1)
+		assert.Equal(t, todos[2].ID, third.Todos[0].ID)
+		assert.Empty(t, third.Deleted)
+		assert.False(t, third.HasMore)
+	})
+
+	t.Run("no changes keeps the token", func(t *testing.T) {
+		syncService, syncRepo := setupSyncService(userID, uuid.New(), new(MockTodoRepository))
+		syncRepo.On("GetChangedTodos", mock.Anything, userID, int64(0), service.DefaultSyncPageSize+1).Return([]model.SyncTodo{}, nil)
+
+		changes, err := syncService.GetChanges(ctx, userID, "", 0)
+		require.NoError(t, err)
+
+		again, err := syncService.GetChanges(ctx, userID, changes.NextToken, 0)
+
+		require.NoError(t, err)
+		assert.Empty(t, again.Todos)
+		assert.Empty(t, again.Deleted)
+		assert.Equal(t, changes.NextToken, again.NextToken)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		syncService, _ := setupSyncService(userID, uuid.New(), new(MockTodoRepository))
+
+		changes, err := syncService.GetChanges(ctx, userID, "not a token", 0)
+
+		assert.Equal(t, service.ErrInvalidSyncToken, err)
+		assert.Nil(t, changes)
+	})
+}
+
+func TestApplyMutations(t *testing.T) {
+	ctx := context.Background()
+	ownerID := uuid.New()
+	viewerID := uuid.New()
+	todoID := uuid.New()
+
+	update := func(baseVersion int64) model.SyncMutation {
+		return model.SyncMutation{
+			ID:          todoID.String(),
+			Op:          model.SyncOpUpsert,
+			BaseVersion: baseVersion,
+			Todo:        &model.UpdateTodoRequest{Title: "offline edit", IsCompleted: true},
+		}
+	}
+
+	tests := []struct {
+		name           string
+		userID         uuid.UUID
+		mutation       model.SyncMutation
+		current        *model.Todo
+		expectedStatus string
+		expectedError  *model.ErrorResponse
+		expectTodo     bool
+		expectWrite    string
+	}{
+		{
+			name:           "create todo with client generated ID",
+			userID:         ownerID,
+			mutation:       update(0),
+			current:        nil,
+			expectedStatus: model.SyncStatusApplied,
+			expectTodo:     true,
+			expectWrite:    "Create",
+		},
+		{
+			name:           "update based on the current version",
+			userID:         ownerID,
+			mutation:       update(3),
+			current:        &model.Todo{ID: todoID, UserID: ownerID, Title: "server", Version: 3},
+			expectedStatus: model.SyncStatusApplied,
+			expectTodo:     true,
+			expectWrite:    "Update",
+		},
+		{
+			name:           "update based on an outdated version",
+			userID:         ownerID,
+			mutation:       update(2),
+			current:        &model.Todo{ID: todoID, UserID: ownerID, Title: "server", Version: 3},
+			expectedStatus: model.SyncStatusConflict,
+			expectTodo:     true,
+		},
+		{
+			name:           "create with an ID already in use",
+			userID:         ownerID,
+			mutation:       update(0),
+			current:        &model.Todo{ID: todoID, UserID: ownerID, Title: "server", Version: 1},
+			expectedStatus: model.SyncStatusConflict,
+			expectTodo:     true,
+		},
+		{
+			name:           "update of a todo deleted on the server",
+			userID:         ownerID,
+			mutation:       update(3),
+			current:        nil,
+			expectedStatus: model.SyncStatusConflict,
+		},
+		{
+			name:           "delete based on the current version",
+			userID:         ownerID,
+			mutation:       model.SyncMutation{ID: todoID.String(), Op: model.SyncOpDelete, BaseVersion: 3},
+			current:        &model.Todo{ID: todoID, UserID: ownerID, Version: 3},
+			expectedStatus: model.SyncStatusApplied,
+			expectWrite:    "Delete",
+		},
+		{
+			name:           "delete of a todo already deleted",
+			userID:         ownerID,
+			mutation:       model.SyncMutation{ID: todoID.String(), Op: model.SyncOpDelete, BaseVersion: 3},
+			current:        nil,
+			expectedStatus: model.SyncStatusApplied,
+		},
+		{
+			name:           "viewer cannot update",
+			userID:         viewerID,
+			mutation:       update(3),
+			current:        &model.Todo{ID: todoID, UserID: ownerID, Version: 3},
+			expectedStatus: model.SyncStatusRejected,
+			expectedError:  model.NoPermissionToAccessTodoResponse,
+		},
+		{
+			name:           "todo of another user",
+			userID:         uuid.New(),
+			mutation:       update(0),
+			current:        &model.Todo{ID: todoID, UserID: ownerID, Version: 1},
+			expectedStatus: model.SyncStatusRejected,
+			expectedError:  model.NoPermissionToAccessTodoResponse,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var current *model.Todo
+			if tt.current != nil {
+				copied := *tt.current
+				current = &copied
+			}
+			todoRepo := newStoredTodoRepository(current)
+			syncService, _ := setupSyncService(ownerID, viewerID, todoRepo)
+
+			results, err := syncService.ApplyMutations(ctx, tt.userID, []model.SyncMutation{tt.mutation})
+
+			require.NoError(t, err)
+			require.Len(t, results, 1)
+			result := results[0]
+			assert.Equal(t, todoID.String(), result.ID)
+			assert.Equal(t, tt.expectedStatus, result.Status)
+			assert.Equal(t, tt.expectedError, result.Error)
+			assert.Equal(t, tt.expectTodo, result.Todo != nil)
+
+			for _, write := range []string{"Create", "Update", "Delete"} {
+				if write == tt.expectWrite {
+					todoRepo.AssertCalled(t, write, mock.Anything, mock.Anything)
+				} else {
+					todoRepo.AssertNotCalled(t, write, mock.Anything, mock.Anything)
+				}
+			}
+
+			switch tt.expectWrite {
+			case "Create":
+				assert.Equal(t, todoID, todoRepo.stored.ID)
+				assert.Equal(t, tt.userID, todoRepo.stored.UserID)
+				assert.True(t, todoRepo.stored.IsCompleted)
+				assert.Equal(t, int64(1), result.Todo.Version)
+			case "Update":
+				assert.Equal(t, "offline edit", result.Todo.Title)
+				assert.Equal(t, tt.current.Version+1, result.Todo.Version)
+			}
+			if tt.expectedStatus == model.SyncStatusConflict && tt.current != nil {
+				assert.Equal(t, "server", result.Todo.Title)
+				assert.Equal(t, tt.current.Version, result.Todo.Version)
+			}
+		})
+	}
+}
@@ -2,9 +2,13 @@ package service
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"sort"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
+	"github.com/yukimaterrace/todoms/config"
 	"github.com/yukimaterrace/todoms/model"
 	"github.com/yukimaterrace/todoms/repository"
 	"go.uber.org/zap"
@@ -16,6 +20,21 @@ var (
 
 	// ErrUnauthorized is returned when a user is not authorized to access a todo
 	ErrUnauthorized = errors.New("not authorized to access this todo")
+
+	// ErrAssigneeNoAccess is returned when assigning a todo to a user who cannot access it
+	ErrAssigneeNoAccess = errors.New("assignee does not have access to this todo")
+
+	// ErrInvalidTodoID is returned when a client-supplied todo ID is not a UUID
+	ErrInvalidTodoID = errors.New("invalid todo ID")
+
+	// ErrTodoIDConflict is returned when creating a todo with an ID already used by a different todo
+	ErrTodoIDConflict = errors.New("todo ID already in use")
+
+	// ErrTodoQuotaExceeded is returned when creating a todo would exceed the number of todos a user can own
+	ErrTodoQuotaExceeded = errors.New("todo quota exceeded")
+
+	// ErrDescriptionTooLong is returned when a todo description exceeds the maximum length
+	ErrDescriptionTooLong = errors.New("todo description too long")
 )
 
 // TodoService defines the interface for todo-related business logic
@@ -23,34 +42,69 @@ type TodoService interface {
 	// CreateTodo creates a new todo for the specified user
 	CreateTodo(ctx context.Context, userID uuid.UUID, req model.CreateTodoRequest) (*model.Todo, error)
 
-	// GetTodos retrieves all todos for the specified user
+	// GetTodos retrieves all todos owned by or shared with the specified user
 	GetTodos(ctx context.Context, userID uuid.UUID) ([]model.Todo, error)
 
-	// GetTodoByID retrieves a specific todo by ID, ensuring it belongs to the specified user
+	// GetAssignedTodos retrieves all todos assigned to the specified user
+	GetAssignedTodos(ctx context.Context, userID uuid.UUID) ([]model.Todo, error)
+
+	// GetTodoByID retrieves a specific todo by ID, ensuring the specified user can view it
 	GetTodoByID(ctx context.Context, userID uuid.UUID, todoID uuid.UUID) (*model.Todo, error)
 
-	// UpdateTodo updates a specific todo, ensuring it belongs to the specified user
+	// UpdateTodo updates a specific todo, ensuring the specified user can edit it
 	UpdateTodo(ctx context.Context, userID uuid.UUID, todoID uuid.UUID, req model.UpdateTodoRequest) (*model.Todo, error)
 
 	// DeleteTodo deletes a specific todo, ensuring it belongs to the specified user
 	DeleteTodo(ctx context.Context, userID uuid.UUID, todoID uuid.UUID) error
+
+	// DeleteOwnedTodos deletes every todo owned by the specified user
+	DeleteOwnedTodos(ctx context.Context, userID uuid.UUID) error
+
+	// AssignTodo assigns a todo to a user with access to it, or unassigns it when assigneeID is nil
+	AssignTodo(ctx context.Context, userID uuid.UUID, todoID uuid.UUID, assigneeID *uuid.UUID) (*model.Todo, error)
+
+	// GetAssignments retrieves the assignment history of a todo the specified user can view
+	GetAssignments(ctx context.Context, userID uuid.UUID, todoID uuid.UUID) ([]model.TodoAssignment, error)
+
+	// AuthorizeTodo retrieves a todo, ensuring the specified user has at least the required role on it
+	AuthorizeTodo(ctx context.Context, userID uuid.UUID, todoID uuid.UUID, required model.ShareRole) (*model.Todo, model.ShareRole, error)
+
+	// CheckQuota ensures a todo with the description can be saved for the specified user,
+	// and when creating, that the user can own another todo
+	CheckQuota(ctx context.Context, userID uuid.UUID, description *string, creating bool) error
 }
 
 // DefaultTodoService implements the TodoService interface
 type DefaultTodoService struct {
-	todoRepo repository.TodoRepository
-	logger   *zap.Logger
+	todoRepo     repository.TodoRepository
+	shareRepo    repository.TodoShareRepository
+	activityRepo repository.ActivityRepository
+	transactor   repository.Transactor
+	quotaConfig  *config.TodoQuotaConfig
+	logger       *zap.Logger
 }
 
 // NewTodoService creates a new DefaultTodoService instance
-func NewTodoService(todoRepo repository.TodoRepository, logger *zap.Logger) TodoService {
+func NewTodoService(
+	todoRepo repository.TodoRepository,
+	shareRepo repository.TodoShareRepository,
+	activityRepo repository.ActivityRepository,
+	transactor repository.Transactor,
+	quotaConfig *config.TodoQuotaConfig,
+	logger *zap.Logger,
+) TodoService {
 	return &DefaultTodoService{
-		todoRepo: todoRepo,
-		logger:   logger,
+		todoRepo:     todoRepo,
+		shareRepo:    shareRepo,
+		activityRepo: activityRepo,
+		transactor:   transactor,
+		quotaConfig:  quotaConfig,
+		logger:       logger,
 	}
 }
 
 // CreateTodo creates a new todo for the specified user
+// A todo created again with the same client-supplied ID and content is returned as it is
 func (s *DefaultTodoService) CreateTodo(ctx context.Context, userID uuid.UUID, req model.CreateTodoRequest) (*model.Todo, error) {
 	todo := &model.Todo{
 		UserID:      userID,
@@ -59,8 +113,55 @@ func (s *DefaultTodoService) CreateTodo(ctx context.Context, userID uuid.UUID, r
 		DueDate:     req.DueDate,
 		IsCompleted: false, // New todos are always not completed
 	}
+	if req.ID != nil {
+		id, err := uuid.Parse(*req.ID)
+		if err != nil {
+			return nil, ErrInvalidTodoID
+		}
+		todo.ID = id
+	}
 
-	err := s.todoRepo.Create(ctx, todo)
+	replayed := false
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if req.ID != nil {
+			existing, err := s.todoRepo.GetByIDForUpdate(ctx, todo.ID)
+			if err == nil {
+				if !isSameCreation(existing, todo) {
+					return ErrTodoIDConflict
+				}
+				todo, replayed = existing, true
+				return nil
+			}
+			if !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+		}
+		if err := s.CheckQuota(ctx, userID, todo.Description, true); err != nil {
+			return err
+		}
+		return insertTodo(ctx, s.todoRepo, s.activityRepo, todo)
+	})
+	if errors.Is(err, repository.ErrDuplicateTodoID) {
+		// Created by a concurrent request after the ID was looked up, which is replayed like a completed one
+		existing, getErr := s.todoRepo.GetByID(ctx, todo.ID)
+		switch {
+		case getErr == nil && isSameCreation(existing, todo):
+			todo, replayed, err = existing, true, nil
+		case getErr == nil || errors.Is(getErr, sql.ErrNoRows):
+			err = ErrTodoIDConflict
+		default:
+			err = getErr
+		}
+	}
+	if err == ErrTodoIDConflict {
+		s.logger.Warn("attempt to create todo with an ID already in use",
+			zap.String("user_id", userID.String()),
+			zap.String("todo_id", todo.ID.String()))
+		return nil, err
+	}
+	if err == ErrTodoQuotaExceeded || err == ErrDescriptionTooLong {
+		return nil, err
+	}
 	if err != nil {
 		s.logger.Error("failed to create todo",
 			zap.String("user_id", userID.String()),
@@ -70,11 +171,35 @@ func (s *DefaultTodoService) CreateTodo(ctx context.Context, userID uuid.UUID, r
 
 	s.logger.Info("todo created successfully",
 		zap.String("user_id", userID.String()),
-		zap.String("todo_id", todo.ID.String()))
+		zap.String("todo_id", todo.ID.String()),
+		zap.Bool("replayed", replayed))
 	return todo, nil
 }
 
-// GetTodos retrieves all todos for the specified user
+// isSameCreation reports whether an existing todo is the one a create request would make
+// Due dates are stored as dates, so only the date is compared
+func isSameCreation(existing *model.Todo, todo *model.Todo) bool {
+	if existing.UserID != todo.UserID || existing.Title != todo.Title {
+		return false
+	}
+	if (existing.Description == nil) != (todo.Description == nil) ||
+		(existing.Description != nil && *existing.Description != *todo.Description) {
+		return false
+	}
+	if (existing.DueDate == nil) != (todo.DueDate == nil) {
+		return false
+	}
+	if existing.DueDate != nil {
+		ey, em, ed := existing.DueDate.Date()
+		ty, tm, td := todo.DueDate.Date()
+		if ey != ty || em != tm || ed != td {
+			return false
+		}
+	}
+	return true
+}
+
+// GetTodos retrieves all todos owned by or shared with the specified user
 func (s *DefaultTodoService) GetTodos(ctx context.Context, userID uuid.UUID) ([]model.Todo, error) {
 	todos, err := s.todoRepo.GetByUserID(ctx, userID)
 	if err != nil {
@@ -84,30 +209,47 @@ func (s *DefaultTodoService) GetTodos(ctx context.Context, userID uuid.UUID) ([]
 		return nil, err
 	}
 
+	sharedTodos, err := s.todoRepo.GetSharedWithUserID(ctx, userID)
+	if err != nil {
+		s.logger.Error("failed to get shared todos",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	// Merge shared todos into the owned ones, keeping the newest first
+	todos = append(todos, sharedTodos...)
+	sort.SliceStable(todos, func(i, j int) bool {
+		return todos[i].CreatedAt.After(todos[j].CreatedAt)
+	})
+
 	s.logger.Info("retrieved todos successfully",
 		zap.String("user_id", userID.String()),
 		zap.Int("count", len(todos)))
 	return todos, nil
 }
 
-// GetTodoByID retrieves a specific todo by ID, ensuring it belongs to the specified user
-func (s *DefaultTodoService) GetTodoByID(ctx context.Context, userID uuid.UUID, todoID uuid.UUID) (*model.Todo, error) {
-	todo, err := s.todoRepo.GetByID(ctx, todoID)
+// GetAssignedTodos retrieves all todos assigned to the specified user
+func (s *DefaultTodoService) GetAssignedTodos(ctx context.Context, userID uuid.UUID) ([]model.Todo, error) {
+	todos, err := s.todoRepo.GetByAssigneeID(ctx, userID)
 	if err != nil {
-		s.logger.Error("failed to get todo",
+		s.logger.Error("failed to get assigned todos",
 			zap.String("user_id", userID.String()),
-			zap.String("todo_id", todoID.String()),
 			zap.Error(err))
-		return nil, ErrTodoNotFound
+		return nil, err
 	}
 
-	// Check if the todo belongs to the user
-	if todo.UserID != userID {
-		s.logger.Warn("unauthorized access attempt to todo",
-			zap.String("user_id", userID.String()),
-			zap.String("todo_id", todoID.String()),
-			zap.String("owner_id", todo.UserID.String()))
-		return nil, ErrUnauthorized
+	s.logger.Info("retrieved assigned todos successfully",
+		zap.String("user_id", userID.String()),
+		zap.Int("count", len(todos)))
+	return todos, nil
+}
+
+// GetTodoByID retrieves a specific todo by ID, ensuring the specified user can view it
+func (s *DefaultTodoService) GetTodoByID(ctx context.Context, userID uuid.UUID, todoID uuid.UUID) (*model.Todo, error) {
+	todo, _, err := s.AuthorizeTodo(ctx, userID, todoID, model.ShareRoleViewer)
+	if err != nil {
+		return nil, err
 	}
 
 	s.logger.Info("retrieved todo successfully",
@@ -116,26 +258,112 @@ func (s *DefaultTodoService) GetTodoByID(ctx context.Context, userID uuid.UUID,
 	return todo, nil
 }
 
-// UpdateTodo updates a specific todo, ensuring it belongs to the specified user
-func (s *DefaultTodoService) UpdateTodo(ctx context.Context, userID uuid.UUID, todoID uuid.UUID, req model.UpdateTodoRequest) (*model.Todo, error) {
-	// Check if the todo exists and belongs to the user
-	todo, err := s.GetTodoByID(ctx, userID, todoID)
+// AuthorizeTodo retrieves a todo, ensuring the specified user has at least the required role on it
+func (s *DefaultTodoService) AuthorizeTodo(ctx context.Context, userID uuid.UUID, todoID uuid.UUID, required model.ShareRole) (*model.Todo, model.ShareRole, error) {
+	todo, err := s.todoRepo.GetByID(ctx, todoID)
 	if err != nil {
-		return nil, err
+		s.logger.Error("failed to get todo",
+			zap.String("user_id", userID.String()),
+			zap.String("todo_id", todoID.String()),
+			zap.Error(err))
+		return nil, "", ErrTodoNotFound
+	}
+
+	role, err := s.authorize(ctx, userID, todo, required)
+	if err != nil {
+		return nil, "", err
 	}
 
-	// Update the todo
-	todo.Title = req.Title
-	todo.Description = req.Description
-	todo.DueDate = req.DueDate
-	todo.IsCompleted = req.IsCompleted
+	return todo, role, nil
+}
 
-	err = s.todoRepo.Update(ctx, todo)
+// authorize ensures the specified user has at least the required role on a todo, returning the role
+func (s *DefaultTodoService) authorize(ctx context.Context, userID uuid.UUID, todo *model.Todo, required model.ShareRole) (model.ShareRole, error) {
+	role, err := s.roleOf(ctx, todo, userID)
 	if err != nil {
-		s.logger.Error("failed to update todo",
+		return "", err
+	}
+
+	// Check if the user's role on the todo is sufficient
+	if !role.Includes(required) {
+		s.logger.Warn("unauthorized access attempt to todo",
 			zap.String("user_id", userID.String()),
-			zap.String("todo_id", todoID.String()),
+			zap.String("todo_id", todo.ID.String()),
+			zap.String("owner_id", todo.UserID.String()),
+			zap.String("role", string(role)),
+			zap.String("required_role", string(required)))
+		return "", ErrUnauthorized
+	}
+
+	return role, nil
+}
+
+// roleOf returns the role the user has on the todo, or an empty role if the user has no access
+func (s *DefaultTodoService) roleOf(ctx context.Context, todo *model.Todo, userID uuid.UUID) (model.ShareRole, error) {
+	if todo.UserID == userID {
+		return model.ShareRoleOwner, nil
+	}
+
+	share, err := s.shareRepo.Get(ctx, todo.ID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		s.logger.Error("failed to get todo share",
+			zap.String("user_id", userID.String()),
+			zap.String("todo_id", todo.ID.String()),
 			zap.Error(err))
+		return "", err
+	}
+
+	return share.Role, nil
+}
+
+// UpdateTodo updates a specific todo, ensuring the specified user can edit it
+// The todo is locked while it is checked and updated, so a concurrent update cannot change it in between
+// and a share revoked before the update commits is honoured
+func (s *DefaultTodoService) UpdateTodo(ctx context.Context, userID uuid.UUID, todoID uuid.UUID, req model.UpdateTodoRequest) (*model.Todo, error) {
+	var todo *model.Todo
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		// Check if the todo exists and the user is allowed to edit it
+		var err error
+		todo, err = s.todoRepo.GetByIDForUpdate(ctx, todoID)
+		if err != nil {
+			s.logger.Error("failed to get todo",
+				zap.String("user_id", userID.String()),
+				zap.String("todo_id", todoID.String()),
+				zap.Error(err))
+			return ErrTodoNotFound
+		}
+		if _, err := s.authorize(ctx, userID, todo, model.ShareRoleEditor); err != nil {
+			return err
+		}
+		if err := s.CheckQuota(ctx, userID, req.Description, false); err != nil {
+			return err
+		}
+
+		// Update the todo
+		before := todoSnapshot(todo)
+		todo.Title = req.Title
+		todo.Description = req.Description
+		todo.DueDate = req.DueDate
+		todo.IsCompleted = req.IsCompleted
+
+		if err := s.todoRepo.Update(ctx, todo); err != nil {
+			return err
+		}
+		return recordActivity(ctx, s.activityRepo, userID, model.ActivityEntityTodo, todoID, &todoID,
+			model.ActivityActionUpdated, before, todoSnapshot(todo))
+	})
+	if err != nil {
+		switch err {
+		case ErrTodoNotFound, ErrUnauthorized, ErrDescriptionTooLong:
+		default:
+			s.logger.Error("failed to update todo",
+				zap.String("user_id", userID.String()),
+				zap.String("todo_id", todoID.String()),
+				zap.Error(err))
+		}
 		return nil, err
 	}
 
@@ -146,24 +374,211 @@ func (s *DefaultTodoService) UpdateTodo(ctx context.Context, userID uuid.UUID, t
 }
 
 // DeleteTodo deletes a specific todo, ensuring it belongs to the specified user
+// The todo is locked while it is checked and deleted, so the recorded state is the one deleted
 func (s *DefaultTodoService) DeleteTodo(ctx context.Context, userID uuid.UUID, todoID uuid.UUID) error {
-	// Check if the todo exists and belongs to the user
-	_, err := s.GetTodoByID(ctx, userID, todoID)
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		// Check if the todo exists and belongs to the user
+		todo, err := s.todoRepo.GetByIDForUpdate(ctx, todoID)
+		if err != nil {
+			s.logger.Error("failed to get todo",
+				zap.String("user_id", userID.String()),
+				zap.String("todo_id", todoID.String()),
+				zap.Error(err))
+			return ErrTodoNotFound
+		}
+		if _, err := s.authorize(ctx, userID, todo, model.ShareRoleOwner); err != nil {
+			return err
+		}
+
+		return s.deleteTodo(ctx, userID, todo)
+	})
 	if err != nil {
+		switch err {
+		case ErrTodoNotFound, ErrUnauthorized:
+		default:
+			s.logger.Error("failed to delete todo",
+				zap.String("user_id", userID.String()),
+				zap.String("todo_id", todoID.String()),
+				zap.Error(err))
+		}
 		return err
 	}
 
-	err = s.todoRepo.Delete(ctx, todoID)
+	s.logger.Info("todo deleted successfully",
+		zap.String("user_id", userID.String()),
+		zap.String("todo_id", todoID.String()))
+	return nil
+}
+
+// DeleteOwnedTodos deletes every todo owned by the specified user
+// Each todo is deleted as if by DeleteTodo, so that the users it is shared with are notified
+func (s *DefaultTodoService) DeleteOwnedTodos(ctx context.Context, userID uuid.UUID) error {
+	var deleted int
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		todos, err := s.todoRepo.GetByUserID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		for i := range todos {
+			if err := s.deleteTodo(ctx, userID, &todos[i]); err != nil {
+				return err
+			}
+		}
+		deleted = len(todos)
+		return nil
+	})
 	if err != nil {
-		s.logger.Error("failed to delete todo",
+		s.logger.Error("failed to delete owned todos",
 			zap.String("user_id", userID.String()),
-			zap.String("todo_id", todoID.String()),
 			zap.Error(err))
 		return err
 	}
 
-	s.logger.Info("todo deleted successfully",
+	s.logger.Info("owned todos deleted successfully",
 		zap.String("user_id", userID.String()),
-		zap.String("todo_id", todoID.String()))
+		zap.Int("count", deleted))
 	return nil
 }
+
+// deleteTodo deletes a todo and records the activity
+func (s *DefaultTodoService) deleteTodo(ctx context.Context, userID uuid.UUID, todo *model.Todo) error {
+	if err := s.todoRepo.Delete(ctx, todo.ID); err != nil {
+		return err
+	}
+	return recordActivity(ctx, s.activityRepo, userID, model.ActivityEntityTodo, todo.ID, &todo.ID,
+		model.ActivityActionDeleted, todoSnapshot(todo), nil)
+}
+
+// AssignTodo assigns a todo to a user with access to it, or unassigns it when assigneeID is nil
+// The todo is locked while it is checked and assigned, so the recorded previous assignee is the one replaced
+func (s *DefaultTodoService) AssignTodo(ctx context.Context, userID uuid.UUID, todoID uuid.UUID, assigneeID *uuid.UUID) (*model.Todo, error) {
+	var todo *model.Todo
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		// Check if the todo exists and the user is allowed to edit it
+		var err error
+		todo, err = s.todoRepo.GetByIDForUpdate(ctx, todoID)
+		if err != nil {
+			s.logger.Error("failed to get todo",
+				zap.String("user_id", userID.String()),
+				zap.String("todo_id", todoID.String()),
+				zap.Error(err))
+			return ErrTodoNotFound
+		}
+		if _, err := s.authorize(ctx, userID, todo, model.ShareRoleEditor); err != nil {
+			return err
+		}
+
+		// Only users who can see the todo may be assigned to it
+		if assigneeID != nil {
+			role, err := s.roleOf(ctx, todo, *assigneeID)
+			if err != nil {
+				return err
+			}
+			if role == "" {
+				s.logger.Warn("attempt to assign todo to user without access",
+					zap.String("user_id", userID.String()),
+					zap.String("todo_id", todoID.String()),
+					zap.String("assignee_id", assigneeID.String()))
+				return ErrAssigneeNoAccess
+			}
+		}
+
+		previousAssigneeID := todo.AssigneeID
+		err = s.todoRepo.Assign(ctx, &model.TodoAssignment{
+			TodoID:     todoID,
+			AssigneeID: assigneeID,
+			AssignedBy: &userID,
+		})
+		if err != nil {
+			return err
+		}
+
+		// Reload to get the assignee summary
+		todo, err = s.todoRepo.GetByID(ctx, todoID)
+		if err != nil {
+			return err
+		}
+
+		return recordActivity(ctx, s.activityRepo, userID, model.ActivityEntityTodo, todoID, &todoID,
+			model.ActivityActionAssigned, activitySnapshot{"assigneeId": previousAssigneeID}, activitySnapshot{"assigneeId": assigneeID})
+	})
+	if err != nil {
+		switch err {
+		case ErrTodoNotFound, ErrUnauthorized, ErrAssigneeNoAccess:
+		default:
+			s.logger.Error("failed to assign todo",
+				zap.String("user_id", userID.String()),
+				zap.String("todo_id", todoID.String()),
+				zap.Error(err))
+		}
+		return nil, err
+	}
+
+	s.logger.Info("todo assigned successfully",
+		zap.String("user_id", userID.String()),
+		zap.String("todo_id", todoID.String()),
+		zap.Bool("unassigned", assigneeID == nil))
+	return todo, nil
+}
+
+// GetAssignments retrieves the assignment history of a todo the specified user can view
+func (s *DefaultTodoService) GetAssignments(ctx context.Context, userID uuid.UUID, todoID uuid.UUID) ([]model.TodoAssignment, error) {
+	_, _, err := s.AuthorizeTodo(ctx, userID, todoID, model.ShareRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	assignments, err := s.todoRepo.GetAssignments(ctx, todoID)
+	if err != nil {
+		s.logger.Error("failed to get todo assignments",
+			zap.String("user_id", userID.String()),
+			zap.String("todo_id", todoID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	return assignments, nil
+}
+
+// CheckQuota ensures a todo with the description can be saved for the specified user,
+// and when creating, that the user can own another todo
+// When creating it must be called in the transaction that inserts the todo, which then holds a lock on the user
+// until it ends, so that concurrent creations cannot exceed the quota
+func (s *DefaultTodoService) CheckQuota(ctx context.Context, userID uuid.UUID, description *string, creating bool) error {
+	if description != nil && utf8.RuneCountInString(*description) > s.quotaConfig.MaxDescriptionLength {
+		return ErrDescriptionTooLong
+	}
+	if !creating {
+		return nil
+	}
+
+	count, err := s.todoRepo.CountByUserIDForUpdate(ctx, userID)
+	if err != nil {
+		s.logger.Error("failed to count todos",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return err
+	}
+	if count >= s.quotaConfig.MaxTodosPerUser {
+		s.logger.Warn("todo quota exceeded",
+			zap.String("user_id", userID.String()),
+			zap.Int("count", count))
+		return ErrTodoQuotaExceeded
+	}
+	return nil
+}
+
+// insertTodo creates a todo and records it in the activity log
+// It must be called within a transaction
+func insertTodo(
+	ctx context.Context,
+	todoRepo repository.TodoRepository,
+	activityRepo repository.ActivityRepository,
+	todo *model.Todo,
+) error {
+	if err := todoRepo.Create(ctx, todo); err != nil {
+		return err
+	}
+	return recordActivity(ctx, activityRepo, todo.UserID, model.ActivityEntityTodo, todo.ID, &todo.ID,
+		model.ActivityActionCreated, nil, todoSnapshot(todo))
+}
@@ -3,14 +3,18 @@ package service_test
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/yukimaterrace/todoms/config"
 	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
 	"github.com/yukimaterrace/todoms/service"
 	"go.uber.org/zap"
 )
@@ -20,6 +24,25 @@ func TestCreateTodo(t *testing.T) {
 	logger := zap.NewNop()
 	ctx := context.Background()
 
+	// A description one character over the limit, counted in characters rather than bytes
+	longDescription := strings.Repeat("あ", config.DefaultMaxDescriptionLength+1)
+
+	// A todo already created with a client-supplied ID
+	ownerID := uuid.New()
+	clientID := uuid.New()
+	clientIDString := clientID.String()
+	description := "Existing Description"
+	dueDate := time.Date(2025, 4, 25, 18, 0, 0, 0, time.FixedZone("JST", 9*60*60))
+	storedDueDate := time.Date(2025, 4, 25, 0, 0, 0, 0, time.UTC)
+	existing := &model.Todo{
+		ID:          clientID,
+		UserID:      ownerID,
+		Title:       "Existing Todo",
+		Description: &description,
+		DueDate:     &storedDueDate,
+		Version:     1,
+	}
+
 	// Test cases
 	testCases := []struct {
 		name          string
@@ -84,6 +107,123 @@ func TestCreateTodo(t *testing.T) {
 				assert.NotNil(t, todo.DueDate)
 			},
 		},
+		{
+			name:   "Client Supplied ID",
+			userID: ownerID,
+			request: model.CreateTodoRequest{
+				ID:    &clientIDString,
+				Title: "Offline Todo",
+			},
+			setupMock: func(m *MockTodoRepository) {
+				m.On("GetByIDForUpdate", mock.Anything, clientID).Return(nil, sql.ErrNoRows)
+				m.On("Create", mock.Anything, mock.MatchedBy(func(todo *model.Todo) bool {
+					return todo.ID == clientID && todo.Title == "Offline Todo"
+				})).Return(nil)
+			},
+			expectedError: nil,
+			checkTodo: func(t *testing.T, todo *model.Todo) {
+				assert.Equal(t, clientID, todo.ID)
+			},
+		},
+		{
+			name:   "Identical Replay Of Client Supplied ID",
+			userID: ownerID,
+			request: model.CreateTodoRequest{
+				ID:          &clientIDString,
+				Title:       "Existing Todo",
+				Description: &description,
+				DueDate:     &dueDate,
+			},
+			setupMock: func(m *MockTodoRepository) {
+				m.On("GetByIDForUpdate", mock.Anything, clientID).Return(existing, nil)
+			},
+			expectedError: nil,
+			checkTodo: func(t *testing.T, todo *model.Todo) {
+				assert.Equal(t, existing, todo)
+			},
+		},
+		{
+			name:   "Client Supplied ID With Different Content",
+			userID: ownerID,
+			request: model.CreateTodoRequest{
+				ID:          &clientIDString,
+				Title:       "Changed Todo",
+				Description: &description,
+				DueDate:     &dueDate,
+			},
+			setupMock: func(m *MockTodoRepository) {
+				m.On("GetByIDForUpdate", mock.Anything, clientID).Return(existing, nil)
+			},
+			expectedError: service.ErrTodoIDConflict,
+		},
+		{
+			name:   "Client Supplied ID Of Another User",
+			userID: uuid.New(),
+			request: model.CreateTodoRequest{
+				ID:          &clientIDString,
+				Title:       "Existing Todo",
+				Description: &description,
+				DueDate:     &dueDate,
+			},
+			setupMock: func(m *MockTodoRepository) {
+				m.On("GetByIDForUpdate", mock.Anything, clientID).Return(existing, nil)
+			},
+			expectedError: service.ErrTodoIDConflict,
+		},
+		{
+			name:   "Identical Replay Created Concurrently",
+			userID: ownerID,
+			request: model.CreateTodoRequest{
+				ID:          &clientIDString,
+				Title:       "Existing Todo",
+				Description: &description,
+				DueDate:     &dueDate,
+			},
+			setupMock: func(m *MockTodoRepository) {
+				m.On("GetByIDForUpdate", mock.Anything, clientID).Return(nil, sql.ErrNoRows)
+				m.On("Create", mock.Anything, mock.Anything).Return(repository.ErrDuplicateTodoID)
+				m.On("GetByID", mock.Anything, clientID).Return(existing, nil)
+			},
+			expectedError: nil,
+			checkTodo: func(t *testing.T, todo *model.Todo) {
+				assert.Equal(t, existing, todo)
+			},
+		},
+		{
+			name:   "Different Content Created Concurrently",
+			userID: ownerID,
+			request: model.CreateTodoRequest{
+				ID:    &clientIDString,
+				Title: "Offline Todo",
+			},
+			setupMock: func(m *MockTodoRepository) {
+				m.On("GetByIDForUpdate", mock.Anything, clientID).Return(nil, sql.ErrNoRows)
+				m.On("Create", mock.Anything, mock.Anything).Return(repository.ErrDuplicateTodoID)
+				m.On("GetByID", mock.Anything, clientID).Return(existing, nil)
+			},
+			expectedError: service.ErrTodoIDConflict,
+		},
+		{
+			name:   "Todo Quota Exceeded",
+			userID: uuid.New(),
+			request: model.CreateTodoRequest{
+				Title: "One Too Many",
+			},
+			setupMock: func(m *MockTodoRepository) {
+				m.On("CountByUserIDForUpdate", mock.Anything, mock.Anything).Return(config.DefaultMaxTodosPerUser, nil)
+			},
+			expectedError: service.ErrTodoQuotaExceeded,
+		},
+		{
+			name:   "Description Too Long",
+			userID: uuid.New(),
+			request: model.CreateTodoRequest{
+				Title:       "Long Todo",
+				Description: &longDescription,
+			},
+			setupMock:     func(m *MockTodoRepository) {},
+			expectedError: service.ErrDescriptionTooLong,
+		},
 		{
 			name:   "Repository Error",
 			userID: uuid.New(),
@@ -105,9 +245,12 @@ func TestCreateTodo(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			// Setup
 			mockRepo := new(MockTodoRepository)
+			mockShareRepo := new(MockTodoShareRepository)
+			mockShareRepo.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(nil, sql.ErrNoRows).Maybe()
 			tc.setupMock(mockRepo)
+			mockRepo.On("CountByUserIDForUpdate", mock.Anything, tc.userID).Return(0, nil).Maybe()
 
-			todoService := service.NewTodoService(mockRepo, logger)
+			todoService := service.NewTodoService(mockRepo, mockShareRepo, newMockActivityRepository(), new(MockTransactor), config.DefaultTodoQuotaConfig(), logger)
 
 			// Execute
 			todo, err := todoService.CreateTodo(ctx, tc.userID, tc.request)
@@ -163,6 +306,34 @@ func TestGetTodos(t *testing.T) {
 					},
 				}
 				m.On("GetByUserID", mock.Anything, userID).Return(todos, nil)
+				m.On("GetSharedWithUserID", mock.Anything, userID).Return([]model.Todo{}, nil)
+			},
+			expectedError: nil,
+			expectedCount: 2,
+		},
+		{
+			name:   "Success with Shared Todos",
+			userID: uuid.New(),
+			setupMock: func(m *MockTodoRepository, userID uuid.UUID) {
+				now := time.Now()
+				ownTodos := []model.Todo{
+					{
+						ID:        uuid.New(),
+						UserID:    userID,
+						Title:     "Own Todo",
+						CreatedAt: now.Add(-time.Hour),
+					},
+				}
+				sharedTodos := []model.Todo{
+					{
+						ID:        uuid.New(),
+						UserID:    uuid.New(),
+						Title:     "Shared Todo",
+						CreatedAt: now,
+					},
+				}
+				m.On("GetByUserID", mock.Anything, userID).Return(ownTodos, nil)
+				m.On("GetSharedWithUserID", mock.Anything, userID).Return(sharedTodos, nil)
 			},
 			expectedError: nil,
 			expectedCount: 2,
@@ -172,6 +343,7 @@ func TestGetTodos(t *testing.T) {
 			userID: uuid.New(),
 			setupMock: func(m *MockTodoRepository, userID uuid.UUID) {
 				m.On("GetByUserID", mock.Anything, userID).Return([]model.Todo{}, nil)
+				m.On("GetSharedWithUserID", mock.Anything, userID).Return([]model.Todo{}, nil)
 			},
 			expectedError: nil,
 			expectedCount: 0,
@@ -192,9 +364,11 @@ func TestGetTodos(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			// Setup
 			mockRepo := new(MockTodoRepository)
+			mockShareRepo := new(MockTodoShareRepository)
+			mockShareRepo.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(nil, sql.ErrNoRows).Maybe()
 			tc.setupMock(mockRepo, tc.userID)
 
-			todoService := service.NewTodoService(mockRepo, logger)
+			todoService := service.NewTodoService(mockRepo, mockShareRepo, newMockActivityRepository(), new(MockTransactor), config.DefaultTodoQuotaConfig(), logger)
 
 			// Execute
 			todos, err := todoService.GetTodos(ctx, tc.userID)
@@ -279,9 +453,11 @@ func TestGetTodoByID(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			// Setup
 			mockRepo := new(MockTodoRepository)
+			mockShareRepo := new(MockTodoShareRepository)
+			mockShareRepo.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(nil, sql.ErrNoRows).Maybe()
 			tc.setupMock(mockRepo, tc.userID, tc.todoID)
 
-			todoService := service.NewTodoService(mockRepo, logger)
+			todoService := service.NewTodoService(mockRepo, mockShareRepo, newMockActivityRepository(), new(MockTransactor), config.DefaultTodoQuotaConfig(), logger)
 
 			// Execute
 			todo, err := todoService.GetTodoByID(ctx, tc.userID, tc.todoID)
@@ -345,7 +521,7 @@ func TestUpdateTodo(t *testing.T) {
 					UserID: userID,
 					Title:  "Original Title",
 				}
-				m.On("GetByID", mock.Anything, todoID).Return(todo, nil)
+				m.On("GetByIDForUpdate", mock.Anything, todoID).Return(todo, nil)
 
 				// Then update it
 				m.On("Update", mock.Anything, mock.MatchedBy(func(todo *model.Todo) bool {
@@ -367,7 +543,7 @@ func TestUpdateTodo(t *testing.T) {
 			todoID:  todoID,
 			request: updateRequest,
 			setupMock: func(m *MockTodoRepository, userID uuid.UUID, todoID uuid.UUID) {
-				m.On("GetByID", mock.Anything, todoID).Return(nil, errors.New("todo not found"))
+				m.On("GetByIDForUpdate", mock.Anything, todoID).Return(nil, errors.New("todo not found"))
 			},
 			expectedError: service.ErrTodoNotFound,
 			checkTodo:     nil,
@@ -383,7 +559,7 @@ func TestUpdateTodo(t *testing.T) {
 					UserID: anotherUserID, // Different from the requesting user
 					Title:  "Another User's Todo",
 				}
-				m.On("GetByID", mock.Anything, todoID).Return(todo, nil)
+				m.On("GetByIDForUpdate", mock.Anything, todoID).Return(todo, nil)
 			},
 			expectedError: service.ErrUnauthorized,
 			checkTodo:     nil,
@@ -400,7 +576,7 @@ func TestUpdateTodo(t *testing.T) {
 					UserID: userID,
 					Title:  "Original Title",
 				}
-				m.On("GetByID", mock.Anything, todoID).Return(todo, nil)
+				m.On("GetByIDForUpdate", mock.Anything, todoID).Return(todo, nil)
 
 				// Then fail on update
 				m.On("Update", mock.Anything, mock.Anything).Return(errors.New("database error"))
@@ -415,9 +591,11 @@ func TestUpdateTodo(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			// Setup
 			mockRepo := new(MockTodoRepository)
+			mockShareRepo := new(MockTodoShareRepository)
+			mockShareRepo.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(nil, sql.ErrNoRows).Maybe()
 			tc.setupMock(mockRepo, tc.userID, tc.todoID)
 
-			todoService := service.NewTodoService(mockRepo, logger)
+			todoService := service.NewTodoService(mockRepo, mockShareRepo, newMockActivityRepository(), new(MockTransactor), config.DefaultTodoQuotaConfig(), logger)
 
 			// Execute
 			todo, err := todoService.UpdateTodo(ctx, tc.userID, tc.todoID, tc.request)
@@ -439,8 +617,9 @@ func TestUpdateTodo(t *testing.T) {
 				}
 			}
 
-			// Verify mock expectations
+			// Verify mock expectations; the todo is only read through the row lock
 			mockRepo.AssertExpectations(t)
+			mockRepo.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
 		})
 	}
 }
@@ -474,7 +653,7 @@ func TestDeleteTodo(t *testing.T) {
 					UserID: userID,
 					Title:  "Todo to be deleted",
 				}
-				m.On("GetByID", mock.Anything, todoID).Return(todo, nil)
+				m.On("GetByIDForUpdate", mock.Anything, todoID).Return(todo, nil)
 
 				// Then delete it
 				m.On("Delete", mock.Anything, todoID).Return(nil)
@@ -486,7 +665,7 @@ func TestDeleteTodo(t *testing.T) {
 			userID: userID,
 			todoID: todoID,
 			setupMock: func(m *MockTodoRepository, userID uuid.UUID, todoID uuid.UUID) {
-				m.On("GetByID", mock.Anything, todoID).Return(nil, errors.New("todo not found"))
+				m.On("GetByIDForUpdate", mock.Anything, todoID).Return(nil, errors.New("todo not found"))
 			},
 			expectedError: service.ErrTodoNotFound,
 		},
@@ -500,7 +679,7 @@ func TestDeleteTodo(t *testing.T) {
 					UserID: anotherUserID, // Different from the requesting user
 					Title:  "Another User's Todo",
 				}
-				m.On("GetByID", mock.Anything, todoID).Return(todo, nil)
+				m.On("GetByIDForUpdate", mock.Anything, todoID).Return(todo, nil)
 			},
 			expectedError: service.ErrUnauthorized,
 		},
@@ -515,7 +694,7 @@ func TestDeleteTodo(t *testing.T) {
 					UserID: userID,
 					Title:  "Todo with delete error",
 				}
-				m.On("GetByID", mock.Anything, todoID).Return(todo, nil)
+				m.On("GetByIDForUpdate", mock.Anything, todoID).Return(todo, nil)
 
 				// Then fail on delete
 				m.On("Delete", mock.Anything, todoID).Return(errors.New("database error"))
@@ -529,9 +708,11 @@ func TestDeleteTodo(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			// Setup
 			mockRepo := new(MockTodoRepository)
+			mockShareRepo := new(MockTodoShareRepository)
+			mockShareRepo.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(nil, sql.ErrNoRows).Maybe()
 			tc.setupMock(mockRepo, tc.userID, tc.todoID)
 
-			todoService := service.NewTodoService(mockRepo, logger)
+			todoService := service.NewTodoService(mockRepo, mockShareRepo, newMockActivityRepository(), new(MockTransactor), config.DefaultTodoQuotaConfig(), logger)
 
 			// Execute
 			err := todoService.DeleteTodo(ctx, tc.userID, tc.todoID)
@@ -553,3 +734,227 @@ func TestDeleteTodo(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthorizeTodo(t *testing.T) {
+	// Create a test logger
+	logger := zap.NewNop()
+	ctx := context.Background()
+
+	// Setup common test data
+	ownerID := uuid.New()
+	userID := uuid.New()
+	todoID := uuid.New()
+
+	// Test cases
+	testCases := []struct {
+		name          string
+		userID        uuid.UUID
+		required      model.ShareRole
+		setupMock     func(*MockTodoShareRepository)
+		expectedRole  model.ShareRole
+		expectedError error
+	}{
+		{
+			name:          "Owner Can Delete",
+			userID:        ownerID,
+			required:      model.ShareRoleOwner,
+			setupMock:     func(m *MockTodoShareRepository) {},
+			expectedRole:  model.ShareRoleOwner,
+			expectedError: nil,
+		},
+		{
+			name:     "Viewer Can View",
+			userID:   userID,
+			required: model.ShareRoleViewer,
+			setupMock: func(m *MockTodoShareRepository) {
+				m.On("Get", mock.Anything, todoID, userID).Return(&model.TodoShare{Role: model.ShareRoleViewer}, nil)
+			},
+			expectedRole:  model.ShareRoleViewer,
+			expectedError: nil,
+		},
+		{
+			name:     "Viewer Cannot Edit",
+			userID:   userID,
+			required: model.ShareRoleEditor,
+			setupMock: func(m *MockTodoShareRepository) {
+				m.On("Get", mock.Anything, todoID, userID).Return(&model.TodoShare{Role: model.ShareRoleViewer}, nil)
+			},
+			expectedError: service.ErrUnauthorized,
+		},
+		{
+			name:     "Editor Can Edit",
+			userID:   userID,
+			required: model.ShareRoleEditor,
+			setupMock: func(m *MockTodoShareRepository) {
+				m.On("Get", mock.Anything, todoID, userID).Return(&model.TodoShare{Role: model.ShareRoleEditor}, nil)
+			},
+			expectedRole:  model.ShareRoleEditor,
+			expectedError: nil,
+		},
+		{
+			name:     "Editor Cannot Delete",
+			userID:   userID,
+			required: model.ShareRoleOwner,
+			setupMock: func(m *MockTodoShareRepository) {
+				m.On("Get", mock.Anything, todoID, userID).Return(&model.TodoShare{Role: model.ShareRoleEditor}, nil)
+			},
+			expectedError: service.ErrUnauthorized,
+		},
+		{
+			name:     "Not Shared",
+			userID:   userID,
+			required: model.ShareRoleViewer,
+			setupMock: func(m *MockTodoShareRepository) {
+				m.On("Get", mock.Anything, todoID, userID).Return(nil, sql.ErrNoRows)
+			},
+			expectedError: service.ErrUnauthorized,
+		},
+		{
+			name:     "Share Repository Error",
+			userID:   userID,
+			required: model.ShareRoleViewer,
+			setupMock: func(m *MockTodoShareRepository) {
+				m.On("Get", mock.Anything, todoID, userID).Return(nil, errors.New("database error"))
+			},
+			expectedError: errors.New("database error"),
+		},
+	}
+
+	// Run test cases
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Setup
+			mockRepo := new(MockTodoRepository)
+			mockRepo.On("GetByID", mock.Anything, todoID).Return(&model.Todo{
+				ID:     todoID,
+				UserID: ownerID,
+				Title:  "Shared Todo",
+			}, nil)
+			mockShareRepo := new(MockTodoShareRepository)
+			tc.setupMock(mockShareRepo)
+
+			todoService := service.NewTodoService(mockRepo, mockShareRepo, newMockActivityRepository(), new(MockTransactor), config.DefaultTodoQuotaConfig(), logger)
+
+			// Execute
+			todo, role, err := todoService.AuthorizeTodo(ctx, tc.userID, todoID, tc.required)
+
+			// Verify
+			if tc.expectedError != nil {
+				assert.Error(t, err)
+				assert.Equal(t, tc.expectedError.Error(), err.Error())
+				assert.Nil(t, todo)
+			} else {
+				assert.NoError(t, err)
+				assert.NotNil(t, todo)
+				assert.Equal(t, tc.expectedRole, role)
+			}
+
+			// Verify mock expectations
+			mockRepo.AssertExpectations(t)
+			mockShareRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAssignTodo(t *testing.T) {
+	// Create a test logger
+	logger := zap.NewNop()
+	ctx := context.Background()
+
+	// Setup common test data
+	ownerID := uuid.New()
+	editorID := uuid.New()
+	strangerID := uuid.New()
+	todoID := uuid.New()
+	editorEmail := "editor@example.com"
+
+	// Test cases
+	testCases := []struct {
+		name          string
+		userID        uuid.UUID
+		assigneeID    *uuid.UUID
+		setupMock     func(*MockTodoRepository, *MockTodoShareRepository)
+		expectedError error
+	}{
+		{
+			name:       "Assign To Shared User",
+			userID:     ownerID,
+			assigneeID: &editorID,
+			setupMock: func(m *MockTodoRepository, s *MockTodoShareRepository) {
+				m.On("GetByIDForUpdate", mock.Anything, todoID).Return(&model.Todo{ID: todoID, UserID: ownerID}, nil)
+				s.On("Get", mock.Anything, todoID, editorID).Return(&model.TodoShare{Role: model.ShareRoleEditor}, nil)
+				m.On("Assign", mock.Anything, mock.MatchedBy(func(a *model.TodoAssignment) bool {
+					return a.TodoID == todoID && *a.AssigneeID == editorID && *a.AssignedBy == ownerID
+				})).Return(nil)
+				m.On("GetByID", mock.Anything, todoID).Return(&model.Todo{
+					ID:            todoID,
+					UserID:        ownerID,
+					AssigneeID:    &editorID,
+					AssigneeEmail: &editorEmail,
+				}, nil).Once()
+			},
+			expectedError: nil,
+		},
+		{
+			name:       "Unassign",
+			userID:     ownerID,
+			assigneeID: nil,
+			setupMock: func(m *MockTodoRepository, s *MockTodoShareRepository) {
+				m.On("GetByIDForUpdate", mock.Anything, todoID).Return(&model.Todo{ID: todoID, UserID: ownerID, AssigneeID: &editorID}, nil)
+				m.On("Assign", mock.Anything, mock.MatchedBy(func(a *model.TodoAssignment) bool {
+					return a.AssigneeID == nil
+				})).Return(nil)
+				m.On("GetByID", mock.Anything, todoID).Return(&model.Todo{ID: todoID, UserID: ownerID}, nil)
+			},
+			expectedError: nil,
+		},
+		{
+			name:       "Assignee Without Access",
+			userID:     ownerID,
+			assigneeID: &strangerID,
+			setupMock: func(m *MockTodoRepository, s *MockTodoShareRepository) {
+				m.On("GetByIDForUpdate", mock.Anything, todoID).Return(&model.Todo{ID: todoID, UserID: ownerID}, nil)
+				s.On("Get", mock.Anything, todoID, strangerID).Return(nil, sql.ErrNoRows)
+			},
+			expectedError: service.ErrAssigneeNoAccess,
+		},
+		{
+			name:       "Viewer Cannot Assign",
+			userID:     editorID,
+			assigneeID: &editorID,
+			setupMock: func(m *MockTodoRepository, s *MockTodoShareRepository) {
+				m.On("GetByIDForUpdate", mock.Anything, todoID).Return(&model.Todo{ID: todoID, UserID: ownerID}, nil)
+				s.On("Get", mock.Anything, todoID, editorID).Return(&model.TodoShare{Role: model.ShareRoleViewer}, nil)
+			},
+			expectedError: service.ErrUnauthorized,
+		},
+	}
+
+	// Run test cases
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Setup
+			mockRepo := new(MockTodoRepository)
+			mockShareRepo := new(MockTodoShareRepository)
+			tc.setupMock(mockRepo, mockShareRepo)
+
+			todoService := service.NewTodoService(mockRepo, mockShareRepo, newMockActivityRepository(), new(MockTransactor), config.DefaultTodoQuotaConfig(), logger)
+
+			// Execute
+			todo, err := todoService.AssignTodo(ctx, tc.userID, todoID, tc.assigneeID)
+
+			// Verify
+			if tc.expectedError != nil {
+				assert.Equal(t, tc.expectedError, err)
+				assert.Nil(t, todo)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.assigneeID, todo.AssigneeID)
+			}
+
+			// Verify mock expectations
+			mockRepo.AssertExpectations(t)
+			mockShareRepo.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,300 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+	"go.uber.org/zap"
+)
+
+// TodoStreamService defines the interface for streaming todo events to real-time clients
+type TodoStreamService interface {
+	// Subscribe starts streaming the events of the todos a user can see
+	// If lastEventID is not zero, the events written after it are replayed first
+	Subscribe(ctx context.Context, userID uuid.UUID, lastEventID int64) (*TodoSubscription, error)
+}
+
+// TodoSubscription is the stream of events of a single client
+type TodoSubscription struct {
+	userID uuid.UUID
+	live   chan model.TodoStreamEvent
+	events chan model.TodoStreamEvent
+	done   chan struct{}
+	hub    *TodoStreamHub
+	once   sync.Once
+}
+
+// Events returns the replayed events followed by the live ones
+// The channel is closed when the client falls too far behind, and should reconnect to resume
+func (s *TodoSubscription) Events() <-chan model.TodoStreamEvent {
+	return s.events
+}
+
+// Close stops the subscription
+func (s *TodoSubscription) Close() {
+	s.once.Do(func() {
+		s.hub.unsubscribe(s)
+		close(s.done)
+	})
+}
+
+// TodoStreamHub fans todo events out to the subscriptions of this instance
+// It is fed with the sequences of the outbox events committed by any instance
+type TodoStreamHub struct {
+	outboxRepo   repository.OutboxRepository
+	todoService  TodoService
+	streamConfig *config.StreamConfig
+	logger       *zap.Logger
+
+	mu            sync.Mutex
+	subscriptions map[uuid.UUID]map[*TodoSubscription]struct{}
+	lastSequence  int64
+}
+
+// NewTodoStreamHub creates a new TodoStreamHub instance
+// Replayed events are checked with todoService against the todos the user can see now
+func NewTodoStreamHub(
+	outboxRepo repository.OutboxRepository,
+	todoService TodoService,
+	streamConfig *config.StreamConfig,
+	logger *zap.Logger,
+) *TodoStreamHub {
+	return &TodoStreamHub{
+		outboxRepo:    outboxRepo,
+		todoService:   todoService,
+		streamConfig:  streamConfig,
+		logger:        logger,
+		subscriptions: make(map[uuid.UUID]map[*TodoSubscription]struct{}),
+	}
+}
+
+// Run dispatches the events notified on the channel until it is closed or the context is cancelled
+// A zero sequence means notifications may have been missed, and the events since the last one are loaded
+func (h *TodoStreamHub) Run(ctx context.Context, sequences <-chan int64) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sequence, ok := <-sequences:
+			if !ok {
+				return
+			}
+			if sequence == 0 {
+				h.catchUp(ctx)
+				continue
+			}
+
+			event, err := h.outboxRepo.GetBySequence(ctx, sequence)
+			if err != nil {
+				h.logger.Error("failed to load outbox event for streaming",
+					zap.Int64("sequence", sequence),
+					zap.Error(err))
+				continue
+			}
+			h.dispatch(event)
+		}
+	}
+}
+
+// catchUp dispatches the events written since the last dispatched one
+func (h *TodoStreamHub) catchUp(ctx context.Context) {
+	h.mu.Lock()
+	lastSequence := h.lastSequence
+	h.mu.Unlock()
+	if lastSequence == 0 {
+		return
+	}
+
+	events, err := h.outboxRepo.GetAfter(ctx, lastSequence, h.streamConfig.ReplayLimit)
+	if err != nil {
+		h.logger.Error("failed to load missed outbox events for streaming",
+			zap.Int64("after_sequence", lastSequence),
+			zap.Error(err))
+		return
+	}
+	for i := range events {
+		h.dispatch(&events[i])
+	}
+}
+
+// dispatch sends an event to the subscriptions of its audience
+// Subscriptions whose buffer is full are dropped rather than holding up everyone else
+func (h *TodoStreamHub) dispatch(event *model.OutboxEvent) {
+	streamEvent := model.NewTodoStreamEvent(event)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if event.Sequence > h.lastSequence {
+		h.lastSequence = event.Sequence
+	}
+
+	for _, member := range event.Audience {
+		userID, err := uuid.Parse(member)
+		if err != nil {
+			continue
+		}
+		for subscription := range h.subscriptions[userID] {
+			select {
+			case subscription.live <- streamEvent:
+			default:
+				h.logger.Warn("dropping slow stream subscription",
+					zap.String("user_id", userID.String()))
+				h.remove(subscription)
+				close(subscription.live)
+			}
+		}
+	}
+}
+
+// Subscribe starts streaming the events of the todos a user can see
+func (h *TodoStreamHub) Subscribe(ctx context.Context, userID uuid.UUID, lastEventID int64) (*TodoSubscription, error) {
+	subscription := &TodoSubscription{
+		userID: userID,
+		live:   make(chan model.TodoStreamEvent, h.streamConfig.BufferSize),
+		events: make(chan model.TodoStreamEvent),
+		done:   make(chan struct{}),
+		hub:    h,
+	}
+
+	// Register before loading the replay, so no event falls in between
+	h.mu.Lock()
+	if h.subscriptions[userID] == nil {
+		h.subscriptions[userID] = make(map[*TodoSubscription]struct{})
+	}
+	h.subscriptions[userID][subscription] = struct{}{}
+	h.mu.Unlock()
+
+	var replay []model.TodoStreamEvent
+	if lastEventID > 0 {
+		events, err := h.outboxRepo.GetByAudienceAfter(ctx, userID, lastEventID, h.streamConfig.ReplayLimit+1)
+		if err != nil {
+			h.logger.Error("failed to load events to replay",
+				zap.String("user_id", userID.String()),
+				zap.Int64("last_event_id", lastEventID),
+				zap.Error(err))
+			subscription.Close()
+			return nil, err
+		}
+
+		// Read after the events, so an event pruned in between is still noticed
+		pruned, err := h.outboxRepo.GetPrunedSequence(ctx)
+		if err != nil {
+			h.logger.Error("failed to load pruned sequence",
+				zap.String("user_id", userID.String()),
+				zap.Int64("last_event_id", lastEventID),
+				zap.Error(err))
+			subscription.Close()
+			return nil, err
+		}
+
+		if lastEventID < pruned || len(events) > h.streamConfig.ReplayLimit {
+			replay = []model.TodoStreamEvent{{Type: model.StreamEventReset}}
+		} else {
+			replay, err = h.authorizeReplay(ctx, userID, events)
+			if err != nil {
+				h.logger.Error("failed to authorize events to replay",
+					zap.String("user_id", userID.String()),
+					zap.Int64("last_event_id", lastEventID),
+					zap.Error(err))
+				subscription.Close()
+				return nil, err
+			}
+		}
+	}
+
+	go subscription.forward(replay)
+
+	h.logger.Info("stream subscribed",
+		zap.String("user_id", userID.String()),
+		zap.Int64("last_event_id", lastEventID),
+		zap.Int("replayed", len(replay)))
+
+	return subscription, nil
+}
+
+// authorizeReplay returns the stream events to replay to a user, leaving out the events of todos
+// the user can no longer see, such as todos no longer shared with them
+// The audience of an event is the users who could see the todo when it was written, which is still right
+// for deletions, as a deleted todo cannot be shared again
+func (h *TodoStreamHub) authorizeReplay(ctx context.Context, userID uuid.UUID, events []model.OutboxEvent) ([]model.TodoStreamEvent, error) {
+	visible := make(map[uuid.UUID]bool)
+	var replay []model.TodoStreamEvent
+	for i := range events {
+		event := &events[i]
+		if event.EventType != model.DomainEventTodoDeleted {
+			canSee, checked := visible[event.AggregateID]
+			if !checked {
+				_, _, err := h.todoService.AuthorizeTodo(ctx, userID, event.AggregateID, model.ShareRoleViewer)
+				switch err {
+				case nil:
+					canSee = true
+				case ErrTodoNotFound, ErrUnauthorized:
+					canSee = false
+				default:
+					return nil, err
+				}
+				visible[event.AggregateID] = canSee
+			}
+			if !canSee {
+				continue
+			}
+		}
+		replay = append(replay, model.NewTodoStreamEvent(event))
+	}
+	return replay, nil
+}
+
+// forward sends the replayed events and then the live ones to the client, skipping live events
+// that were already replayed
+func (s *TodoSubscription) forward(replay []model.TodoStreamEvent) {
+	defer close(s.events)
+
+	var lastReplayed int64
+	for _, event := range replay {
+		select {
+		case s.events <- event:
+			lastReplayed = event.ID
+		case <-s.done:
+			return
+		}
+	}
+
+	for {
+		select {
+		case event, ok := <-s.live:
+			if !ok {
+				return
+			}
+			if event.ID <= lastReplayed {
+				continue
+			}
+			select {
+			case s.events <- event:
+			case <-s.done:
+				return
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// unsubscribe removes a subscription if the hub has not dropped it already
+func (h *TodoStreamHub) unsubscribe(subscription *TodoSubscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove(subscription)
+}
+
+// remove deletes a subscription from the hub, which must be locked
+func (h *TodoStreamHub) remove(subscription *TodoSubscription) {
+	subscriptions := h.subscriptions[subscription.userID]
+	delete(subscriptions, subscription)
+	if len(subscriptions) == 0 {
+		delete(h.subscriptions, subscription.userID)
+	}
+}
@@ -0,0 +1,295 @@
+package service_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+	"go.uber.org/zap"
+)
+
+func newTestStreamEvent(sequence int64, eventType string, audience ...uuid.UUID) model.OutboxEvent {
+	members := make(pq.StringArray, len(audience))
+	for i, userID := range audience {
+		members[i] = userID.String()
+	}
+	return model.OutboxEvent{
+		ID:            uuid.New(),
+		Sequence:      sequence,
+		AggregateType: model.AggregateTodo,
+		AggregateID:   uuid.New(),
+		EventType:     eventType,
+		Payload:       []byte(`{"title":"Buy milk"}`),
+		Audience:      members,
+	}
+}
+
+// newStreamTodoService creates the TodoService a TodoStreamHub authorizes replayed events with
+func newStreamTodoService(todoRepo *MockTodoRepository, shareRepo *MockTodoShareRepository) service.TodoService {
+	return service.NewTodoService(todoRepo, shareRepo, newMockActivityRepository(), new(MockTransactor),
+		config.DefaultTodoQuotaConfig(), zap.NewNop())
+}
+
+// receiveStreamEvent waits for the next event of a subscription
+func receiveStreamEvent(t *testing.T, subscription *service.TodoSubscription) model.TodoStreamEvent {
+	select {
+	case event, ok := <-subscription.Events():
+		require.True(t, ok, "subscription closed")
+		return event
+	case <-time.After(time.Second):
+		require.FailNow(t, "no event received")
+		return model.TodoStreamEvent{}
+	}
+}
+
+// assertNoStreamEvent checks that a subscription has nothing to deliver
+func assertNoStreamEvent(t *testing.T, subscription *service.TodoSubscription) {
+	select {
+	case event := <-subscription.Events():
+		assert.Failf(t, "unexpected event", "%+v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestTodoStreamHub(t *testing.T) {
+	ownerID := uuid.New()
+	viewerID := uuid.New()
+	outsiderID := uuid.New()
+	streamConfig := &config.StreamConfig{HeartbeatInterval: time.Second, ReplayLimit: 2, BufferSize: 2}
+
+	t.Run("live events reach the audience only", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		created := newTestStreamEvent(1, model.DomainEventTodoCreated, ownerID, viewerID)
+		completed := newTestStreamEvent(2, model.DomainEventTodoCompleted, ownerID)
+		mockOutboxRepo := new(MockOutboxRepository)
+		mockOutboxRepo.On("GetBySequence", mock.Anything, int64(1)).Return(&created, nil)
+		mockOutboxRepo.On("GetBySequence", mock.Anything, int64(2)).Return(&completed, nil)
+
+		hub := service.NewTodoStreamHub(mockOutboxRepo, newStreamTodoService(new(MockTodoRepository), new(MockTodoShareRepository)), streamConfig, zap.NewNop())
+		owner, err := hub.Subscribe(ctx, ownerID, 0)
+		require.NoError(t, err)
+		defer owner.Close()
+		viewer, err := hub.Subscribe(ctx, viewerID, 0)
+		require.NoError(t, err)
+		defer viewer.Close()
+		outsider, err := hub.Subscribe(ctx, outsiderID, 0)
+		require.NoError(t, err)
+		defer outsider.Close()
+
+		sequences := make(chan int64, 2)
+		sequences <- 1
+		sequences <- 2
+		go hub.Run(ctx, sequences)
+
+		event := receiveStreamEvent(t, owner)
+		assert.Equal(t, int64(1), event.ID)
+		assert.Equal(t, model.StreamEventTodoCreated, event.Type)
+		assert.JSONEq(t, `{"title":"Buy milk"}`, string(event.Data))
+		// Completions are streamed as updates
+		event = receiveStreamEvent(t, owner)
+		assert.Equal(t, int64(2), event.ID)
+		assert.Equal(t, model.StreamEventTodoUpdated, event.Type)
+
+		event = receiveStreamEvent(t, viewer)
+		assert.Equal(t, int64(1), event.ID)
+		assertNoStreamEvent(t, viewer)
+		assertNoStreamEvent(t, outsider)
+	})
+
+	t.Run("resume replays missed events without duplicating live ones", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		missed := newTestStreamEvent(5, model.DomainEventTodoUpdated, ownerID)
+		deleted := newTestStreamEvent(6, model.DomainEventTodoDeleted, ownerID)
+		mockOutboxRepo := new(MockOutboxRepository)
+		mockOutboxRepo.On("GetPrunedSequence", mock.Anything).Return(int64(0), nil)
+		mockOutboxRepo.On("GetByAudienceAfter", mock.Anything, ownerID, int64(4), 3).Return([]model.OutboxEvent{missed}, nil)
+		mockOutboxRepo.On("GetBySequence", mock.Anything, int64(5)).Return(&missed, nil)
+		mockOutboxRepo.On("GetBySequence", mock.Anything, int64(6)).Return(&deleted, nil)
+		mockTodoRepo := new(MockTodoRepository)
+		mockTodoRepo.On("GetByID", mock.Anything, missed.AggregateID).Return(&model.Todo{ID: missed.AggregateID, UserID: ownerID}, nil)
+
+		hub := service.NewTodoStreamHub(mockOutboxRepo, newStreamTodoService(mockTodoRepo, new(MockTodoShareRepository)), streamConfig, zap.NewNop())
+		owner, err := hub.Subscribe(ctx, ownerID, 4)
+		require.NoError(t, err)
+		defer owner.Close()
+
+		// The replayed event is also notified live, as it was committed around the subscription
+		sequences := make(chan int64, 2)
+		sequences <- 5
+		sequences <- 6
+		go hub.Run(ctx, sequences)
+
+		assert.Equal(t, int64(5), receiveStreamEvent(t, owner).ID)
+		event := receiveStreamEvent(t, owner)
+		assert.Equal(t, int64(6), event.ID)
+		assert.Equal(t, model.StreamEventTodoDeleted, event.Type)
+		assertNoStreamEvent(t, owner)
+	})
+
+	t.Run("resume leaves out todos no longer shared with the user", func(t *testing.T) {
+		ctx := context.Background()
+		revoked := newTestStreamEvent(10, model.DomainEventTodoUpdated, ownerID, viewerID)
+		shared := newTestStreamEvent(11, model.DomainEventTodoUpdated, ownerID, viewerID)
+		deleted := newTestStreamEvent(12, model.DomainEventTodoDeleted, ownerID, viewerID)
+		mockOutboxRepo := new(MockOutboxRepository)
+		mockOutboxRepo.On("GetPrunedSequence", mock.Anything).Return(int64(0), nil)
+		mockOutboxRepo.On("GetByAudienceAfter", mock.Anything, viewerID, int64(9), 4).
+			Return([]model.OutboxEvent{revoked, shared, deleted}, nil)
+		mockTodoRepo := new(MockTodoRepository)
+		mockTodoRepo.On("GetByID", mock.Anything, revoked.AggregateID).Return(&model.Todo{ID: revoked.AggregateID, UserID: ownerID}, nil)
+		mockTodoRepo.On("GetByID", mock.Anything, shared.AggregateID).Return(&model.Todo{ID: shared.AggregateID, UserID: ownerID}, nil)
+		mockShareRepo := new(MockTodoShareRepository)
+		mockShareRepo.On("Get", mock.Anything, revoked.AggregateID, viewerID).Return(nil, sql.ErrNoRows)
+		mockShareRepo.On("Get", mock.Anything, shared.AggregateID, viewerID).
+			Return(&model.TodoShare{TodoID: shared.AggregateID, UserID: viewerID, Role: model.ShareRoleViewer}, nil)
+
+		replayConfig := &config.StreamConfig{HeartbeatInterval: time.Second, ReplayLimit: 3, BufferSize: 2}
+		hub := service.NewTodoStreamHub(mockOutboxRepo, newStreamTodoService(mockTodoRepo, mockShareRepo), replayConfig, zap.NewNop())
+		viewer, err := hub.Subscribe(ctx, viewerID, 9)
+		require.NoError(t, err)
+		defer viewer.Close()
+
+		// The deletion was seen by the audience when it was written, and the todo cannot be shared again
+		assert.Equal(t, int64(11), receiveStreamEvent(t, viewer).ID)
+		assert.Equal(t, int64(12), receiveStreamEvent(t, viewer).ID)
+		assertNoStreamEvent(t, viewer)
+		mockShareRepo.AssertExpectations(t)
+	})
+
+	t.Run("resume fails when access cannot be checked", func(t *testing.T) {
+		ctx := context.Background()
+		event := newTestStreamEvent(10, model.DomainEventTodoUpdated, ownerID, viewerID)
+		mockOutboxRepo := new(MockOutboxRepository)
+		mockOutboxRepo.On("GetPrunedSequence", mock.Anything).Return(int64(0), nil)
+		mockOutboxRepo.On("GetByAudienceAfter", mock.Anything, viewerID, int64(9), 3).Return([]model.OutboxEvent{event}, nil)
+		mockTodoRepo := new(MockTodoRepository)
+		mockTodoRepo.On("GetByID", mock.Anything, event.AggregateID).Return(&model.Todo{ID: event.AggregateID, UserID: ownerID}, nil)
+		mockShareRepo := new(MockTodoShareRepository)
+		mockShareRepo.On("Get", mock.Anything, event.AggregateID, viewerID).Return(nil, errors.New("database error"))
+
+		hub := service.NewTodoStreamHub(mockOutboxRepo, newStreamTodoService(mockTodoRepo, mockShareRepo), streamConfig, zap.NewNop())
+		viewer, err := hub.Subscribe(ctx, viewerID, 9)
+
+		assert.Error(t, err)
+		assert.Nil(t, viewer)
+	})
+
+	t.Run("resume after too many missed events asks the client to reload", func(t *testing.T) {
+		ctx := context.Background()
+		mockOutboxRepo := new(MockOutboxRepository)
+		mockOutboxRepo.On("GetPrunedSequence", mock.Anything).Return(int64(0), nil)
+		mockOutboxRepo.On("GetByAudienceAfter", mock.Anything, ownerID, int64(1), 3).Return([]model.OutboxEvent{
+			newTestStreamEvent(2, model.DomainEventTodoUpdated, ownerID),
+			newTestStreamEvent(3, model.DomainEventTodoUpdated, ownerID),
+			newTestStreamEvent(4, model.DomainEventTodoUpdated, ownerID),
+		}, nil)
+
+		hub := service.NewTodoStreamHub(mockOutboxRepo, newStreamTodoService(new(MockTodoRepository), new(MockTodoShareRepository)), streamConfig, zap.NewNop())
+		owner, err := hub.Subscribe(ctx, ownerID, 1)
+		require.NoError(t, err)
+		defer owner.Close()
+
+		event := receiveStreamEvent(t, owner)
+		assert.Equal(t, model.StreamEventReset, event.Type)
+		assert.Zero(t, event.ID)
+		assertNoStreamEvent(t, owner)
+	})
+
+	t.Run("resume from a pruned event asks the client to reload", func(t *testing.T) {
+		ctx := context.Background()
+		mockOutboxRepo := new(MockOutboxRepository)
+		mockOutboxRepo.On("GetByAudienceAfter", mock.Anything, ownerID, int64(4), 3).Return([]model.OutboxEvent{
+			newTestStreamEvent(8, model.DomainEventTodoUpdated, ownerID),
+		}, nil)
+		mockOutboxRepo.On("GetPrunedSequence", mock.Anything).Return(int64(6), nil)
+
+		hub := service.NewTodoStreamHub(mockOutboxRepo, newStreamTodoService(new(MockTodoRepository), new(MockTodoShareRepository)), streamConfig, zap.NewNop())
+		owner, err := hub.Subscribe(ctx, ownerID, 4)
+		require.NoError(t, err)
+		defer owner.Close()
+
+		event := receiveStreamEvent(t, owner)
+		assert.Equal(t, model.StreamEventReset, event.Type)
+		assertNoStreamEvent(t, owner)
+	})
+
+	t.Run("resume fails when the pruned events cannot be checked", func(t *testing.T) {
+		ctx := context.Background()
+		mockOutboxRepo := new(MockOutboxRepository)
+		mockOutboxRepo.On("GetByAudienceAfter", mock.Anything, ownerID, int64(4), 3).Return([]model.OutboxEvent{}, nil)
+		mockOutboxRepo.On("GetPrunedSequence", mock.Anything).Return(int64(0), errors.New("database error"))
+
+		hub := service.NewTodoStreamHub(mockOutboxRepo, newStreamTodoService(new(MockTodoRepository), new(MockTodoShareRepository)), streamConfig, zap.NewNop())
+		owner, err := hub.Subscribe(ctx, ownerID, 4)
+		assert.Error(t, err)
+		assert.Nil(t, owner)
+	})
+
+	t.Run("slow subscriptions are dropped", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		mockOutboxRepo := new(MockOutboxRepository)
+		sequences := make(chan int64, 5)
+		for sequence := int64(1); sequence <= 5; sequence++ {
+			event := newTestStreamEvent(sequence, model.DomainEventTodoUpdated, ownerID)
+			mockOutboxRepo.On("GetBySequence", mock.Anything, sequence).Return(&event, nil)
+			sequences <- sequence
+		}
+
+		hub := service.NewTodoStreamHub(mockOutboxRepo, newStreamTodoService(new(MockTodoRepository), new(MockTodoShareRepository)), streamConfig, zap.NewNop())
+		owner, err := hub.Subscribe(ctx, ownerID, 0)
+		require.NoError(t, err)
+		defer owner.Close()
+
+		// Nothing is read until every event has been dispatched
+		done := make(chan struct{})
+		go func() {
+			hub.Run(ctx, sequences)
+			close(done)
+		}()
+		close(sequences)
+		<-done
+
+		// The buffered events are delivered, then the stream ends so the client resumes
+		var received int
+		for range owner.Events() {
+			received++
+		}
+		assert.Less(t, received, 5)
+	})
+
+	t.Run("reconnecting listener catches up on missed notifications", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		first := newTestStreamEvent(7, model.DomainEventTodoCreated, ownerID)
+		missed := newTestStreamEvent(8, model.DomainEventTodoUpdated, ownerID)
+		mockOutboxRepo := new(MockOutboxRepository)
+		mockOutboxRepo.On("GetBySequence", mock.Anything, int64(7)).Return(&first, nil)
+		mockOutboxRepo.On("GetAfter", mock.Anything, int64(7), 2).Return([]model.OutboxEvent{missed}, nil)
+
+		hub := service.NewTodoStreamHub(mockOutboxRepo, newStreamTodoService(new(MockTodoRepository), new(MockTodoShareRepository)), streamConfig, zap.NewNop())
+		owner, err := hub.Subscribe(ctx, ownerID, 0)
+		require.NoError(t, err)
+		defer owner.Close()
+
+		sequences := make(chan int64, 2)
+		sequences <- 7
+		sequences <- 0
+		go hub.Run(ctx, sequences)
+
+		assert.Equal(t, int64(7), receiveStreamEvent(t, owner).ID)
+		assert.Equal(t, int64(8), receiveStreamEvent(t, owner).ID)
+	})
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TOTP parameters, which are the defaults of authenticator apps (RFC 6238)
+const (
+	totpSecretSize = 20
+	totpPeriod     = 30
+	totpDigits     = 6
+
+	// totpSkew is the number of time steps before and after the current one whose codes are accepted,
+	// to allow for clock drift and the time taken to type the code
+	totpSkew = 1
+)
+
+// recoveryCodeSize is the number of random bytes in a recovery code
+const recoveryCodeSize = 10
+
+// errMalformedMFASecret is returned when an encrypted TOTP secret cannot be decrypted
+var errMalformedMFASecret = errors.New("malformed MFA secret")
+
+// totpEncoding is the base32 encoding secrets are shown with, without padding as authenticator apps expect
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// generateTOTPSecret generates a random TOTP secret
+func generateTOTPSecret() ([]byte, error) {
+	secret := make([]byte, totpSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// totpStep returns the TOTP time step of a time
+func totpStep(t time.Time) int64 {
+	return t.Unix() / totpPeriod
+}
+
+// totpCode returns the TOTP code of a secret for a time step (RFC 4226 with HMAC-SHA1)
+func totpCode(secret []byte, step int64) string {
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(step))
+
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// matchTOTPCode returns the time step within the skew of now whose code is the given one
+func matchTOTPCode(secret []byte, code string, now time.Time) (int64, bool) {
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	if _, err := strconv.Atoi(code); err != nil {
+		return 0, false
+	}
+
+	current := totpStep(now)
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if subtle.ConstantTimeCompare([]byte(totpCode(secret, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// otpauthURI returns the key URI authenticator apps enrol a secret with, usually shown as a QR code
+func otpauthURI(issuer, account string, secret []byte) string {
+	query := url.Values{}
+	query.Set("secret", totpEncoding.EncodeToString(secret))
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", strconv.Itoa(totpDigits))
+	query.Set("period", strconv.Itoa(totpPeriod))
+
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// encryptMFASecret encrypts a TOTP secret with AES-256-GCM, prefixing the nonce
+func encryptMFASecret(key string, secret []byte) ([]byte, error) {
+	aead, err := mfaSecretCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, secret, nil), nil
+}
+
+// decryptMFASecret decrypts a TOTP secret encrypted by encryptMFASecret
+func decryptMFASecret(key string, encrypted []byte) ([]byte, error) {
+	aead, err := mfaSecretCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(encrypted) < aead.NonceSize() {
+		return nil, errMalformedMFASecret
+	}
+	nonce, sealed := encrypted[:aead.NonceSize()], encrypted[aead.NonceSize():]
+	secret, err := aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, errMalformedMFASecret
+	}
+	return secret, nil
+}
+
+// mfaSecretCipher returns the AES-256-GCM cipher keyed by the SHA-256 hash of the key
+func mfaSecretCipher(key string) (cipher.AEAD, error) {
+	hash := sha256.Sum256([]byte("mfa-secret:" + key))
+	block, err := aes.NewCipher(hash[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// generateRecoveryCode generates a random recovery code, formatted as two groups of lowercase base32
+func generateRecoveryCode() (string, error) {
+	code := make([]byte, recoveryCodeSize)
+	if _, err := rand.Read(code); err != nil {
+		return "", err
+	}
+
+	encoded := strings.ToLower(totpEncoding.EncodeToString(code))
+	return encoded[:len(encoded)/2] + "-" + encoded[len(encoded)/2:], nil
+}
+
+// hashRecoveryCode returns the SHA-256 hash a recovery code is stored by
+// The code is normalised first, so that it can be typed without the dash and in any case
+func hashRecoveryCode(code string) []byte {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	hash := sha256.Sum256([]byte(normalized))
+	return hash[:]
+}
@@ -2,37 +2,108 @@ package service
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/yukimaterrace/todoms/config"
 	"github.com/yukimaterrace/todoms/model"
 	"github.com/yukimaterrace/todoms/repository"
 	"go.uber.org/zap"
-	"golang.org/x/crypto/bcrypt"
 )
 
 var (
 	// ErrEmailAlreadyExists is returned when trying to create a user with an email that already exists
 	ErrEmailAlreadyExists = errors.New("email already exists")
+
+	// ErrInvalidCurrentPassword is returned when the password given to confirm an account change is wrong
+	ErrInvalidCurrentPassword = errors.New("current password is incorrect")
+
+	// ErrPasswordResetRequired is returned when a user whose password an admin removed makes an account change
+	// before resetting it
+	ErrPasswordResetRequired = errors.New("password reset required")
+
+	// ErrReauthenticationRequired is returned when a user without a password makes an account change
+	// too long after logging in
+	ErrReauthenticationRequired = errors.New("reauthentication required")
+
+	// ErrIdentityAlreadyLinked is returned when linking an external account that is already linked to a user
+	ErrIdentityAlreadyLinked = errors.New("external account is already linked")
 )
 
+// maxDisplayNameLength is the longest display name a user can set, which names from providers are cut to
+const maxDisplayNameLength = 100
+
 // UserService defines the interface for user-related business logic
 type UserService interface {
 	// CreateUser creates a new user with the given email and password
+	// A password that breaks the password policy is refused with a *PasswordPolicyError
 	CreateUser(ctx context.Context, email, password string) (*model.User, error)
+
+	// CreateExternalUser creates a new user without a password, who logs in with the external identity
+	// The email address has to have been verified by the provider of the identity
+	CreateExternalUser(ctx context.Context, email, displayName string, identity *model.UserIdentity) (*model.User, error)
+
+	// GetUser retrieves the account of the specified user
+	GetUser(ctx context.Context, userID uuid.UUID) (*model.User, error)
+
+	// UpdateProfile sets the profile of the specified user
+	UpdateProfile(ctx context.Context, userID uuid.UUID, req model.UpdateProfileRequest) (*model.User, error)
+
+	// ChangePassword sets the password of the specified user after checking the current one,
+	// revoking every session of the user
+	// A new password that breaks the password policy is refused with a *PasswordPolicyError
+	ChangePassword(ctx context.Context, userID uuid.UUID, currentPassword, newPassword string) (*model.User, error)
+
+	// ChangeEmail sets the email address of the specified user after checking the password
+	// A user without a password has to have logged in recently instead, authTime being the time of that login
+	// The new address has to be verified again
+	ChangeEmail(ctx context.Context, userID uuid.UUID, email, password string, authTime time.Time) (*model.User, error)
+
+	// DeleteAccount deletes the specified user and the todos they own after checking the password
+	// A user without a password has to have logged in recently instead, authTime being the time of that login
+	DeleteAccount(ctx context.Context, userID uuid.UUID, password string, authTime time.Time) error
 }
 
 // DefaultUserService implements the UserService interface
 type DefaultUserService struct {
-	userRepo repository.UserRepository
-	logger   *zap.Logger
+	todoService    TodoService
+	passwordPolicy PasswordPolicy
+	passwordHasher PasswordHasher
+	userRepo       repository.UserRepository
+	userTokenRepo  repository.UserTokenRepository
+	identityRepo   repository.IdentityRepository
+	activityRepo   repository.ActivityRepository
+	transactor     repository.Transactor
+	authConfig     *config.AuthConfig
+	logger         *zap.Logger
 }
 
 // NewUserService creates a new DefaultUserService instance
-func NewUserService(userRepo repository.UserRepository, logger *zap.Logger) UserService {
+func NewUserService(
+	todoService TodoService,
+	passwordPolicy PasswordPolicy,
+	passwordHasher PasswordHasher,
+	userRepo repository.UserRepository,
+	userTokenRepo repository.UserTokenRepository,
+	identityRepo repository.IdentityRepository,
+	activityRepo repository.ActivityRepository,
+	transactor repository.Transactor,
+	authConfig *config.AuthConfig,
+	logger *zap.Logger,
+) UserService {
 	return &DefaultUserService{
-		userRepo: userRepo,
-		logger:   logger,
+		todoService:    todoService,
+		passwordPolicy: passwordPolicy,
+		passwordHasher: passwordHasher,
+		userRepo:       userRepo,
+		userTokenRepo:  userTokenRepo,
+		identityRepo:   identityRepo,
+		activityRepo:   activityRepo,
+		transactor:     transactor,
+		authConfig:     authConfig,
+		logger:         logger,
 	}
 }
 
@@ -46,8 +117,15 @@ func (s *DefaultUserService) CreateUser(ctx context.Context, email, password str
 		return nil, ErrEmailAlreadyExists
 	}
 
+	if err := s.passwordPolicy.Check(ctx, password, email); err != nil {
+		s.logger.Info("password refused by policy",
+			zap.String("email", email),
+			zap.Error(err))
+		return nil, err
+	}
+
 	// Generate password hash
-	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	passwordHash, err := s.passwordHasher.Hash(password)
 	if err != nil {
 		s.logger.Error("failed to generate password hash",
 			zap.String("email", email),
@@ -59,11 +137,22 @@ func (s *DefaultUserService) CreateUser(ctx context.Context, email, password str
 	user := &model.User{
 		ID:           uuid.New(),
 		Email:        email,
-		PasswordHash: string(passwordHash),
+		PasswordHash: passwordHash,
+		Role:         model.RoleUser,
+		UserProfile: model.UserProfile{
+			Timezone: model.DefaultTimezone,
+			Locale:   model.DefaultLocale,
+		},
 	}
 
 	// Save user to repository
-	err = s.userRepo.Create(ctx, user)
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.Create(ctx, user); err != nil {
+			return err
+		}
+		return recordActivity(ctx, s.activityRepo, user.ID, model.ActivityEntityUser, user.ID, nil,
+			model.ActivityActionCreated, nil, userSnapshot(user))
+	})
 	if err != nil {
 		s.logger.Error("failed to create user in repository",
 			zap.String("email", email),
@@ -77,3 +166,324 @@ func (s *DefaultUserService) CreateUser(ctx context.Context, email, password str
 		zap.String("user_id", user.ID.String()))
 	return user, nil
 }
+
+// CreateExternalUser creates a new user without a password, who logs in with the external identity
+// The email address counts as verified, as the provider of the identity vouched for it
+func (s *DefaultUserService) CreateExternalUser(ctx context.Context, email, displayName string, identity *model.UserIdentity) (*model.User, error) {
+	existingUser, err := s.userRepo.GetByEmail(ctx, email)
+	if err == nil && existingUser != nil {
+		s.logger.Warn("attempt to create external user with existing email",
+			zap.String("email", email),
+			zap.String("provider", identity.Provider))
+		return nil, ErrEmailAlreadyExists
+	}
+
+	if runes := []rune(displayName); len(runes) > maxDisplayNameLength {
+		displayName = string(runes[:maxDisplayNameLength])
+	}
+	user := &model.User{
+		ID:    uuid.New(),
+		Email: email,
+		Role:  model.RoleUser,
+		UserProfile: model.UserProfile{
+			DisplayName: displayName,
+			Timezone:    model.DefaultTimezone,
+			Locale:      model.DefaultLocale,
+		},
+	}
+	identity.UserID = user.ID
+
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.Create(ctx, user); err != nil {
+			return err
+		}
+		if err := s.userRepo.MarkEmailVerified(ctx, user.ID); err != nil {
+			return err
+		}
+		if err := s.identityRepo.CreateIdentity(ctx, identity); err != nil {
+			return err
+		}
+
+		user, err = s.userRepo.GetByID(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		return recordActivity(ctx, s.activityRepo, user.ID, model.ActivityEntityUser, user.ID, nil,
+			model.ActivityActionCreated, nil, userSnapshot(user))
+	})
+	if errors.Is(err, repository.ErrDuplicateIdentity) {
+		s.logger.Warn("attempt to create user with linked identity",
+			zap.String("provider", identity.Provider))
+		return nil, ErrIdentityAlreadyLinked
+	}
+	if err != nil {
+		s.logger.Error("failed to create external user in repository",
+			zap.String("email", email),
+			zap.String("provider", identity.Provider),
+			zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("external user created successfully",
+		zap.String("email", email),
+		zap.String("user_id", user.ID.String()),
+		zap.String("provider", identity.Provider))
+	return user, nil
+}
+
+// GetUser retrieves the account of the specified user
+func (s *DefaultUserService) GetUser(ctx context.Context, userID uuid.UUID) (*model.User, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		s.logger.Warn("user not found",
+			zap.String("user_id", userID.String()))
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		s.logger.Error("failed to get user",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// UpdateProfile sets the profile of the specified user
+func (s *DefaultUserService) UpdateProfile(ctx context.Context, userID uuid.UUID, req model.UpdateProfileRequest) (*model.User, error) {
+	user, err := s.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := *user
+	updated.UserProfile = model.UserProfile{
+		DisplayName: req.DisplayName,
+		Timezone:    req.Timezone,
+		Locale:      req.Locale,
+	}
+
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.UpdateProfile(ctx, userID, &updated.UserProfile); err != nil {
+			return err
+		}
+		return recordActivity(ctx, s.activityRepo, userID, model.ActivityEntityUser, userID, nil,
+			model.ActivityActionUpdated, userSnapshot(user), userSnapshot(&updated))
+	})
+	if err != nil {
+		s.logger.Error("failed to update profile",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("profile updated successfully",
+		zap.String("user_id", userID.String()))
+	return &updated, nil
+}
+
+// ChangePassword sets the password of the specified user after checking the current one
+// The session version is incremented, so the caller has to issue new tokens for the user
+func (s *DefaultUserService) ChangePassword(ctx context.Context, userID uuid.UUID, currentPassword, newPassword string) (*model.User, error) {
+	user, err := s.authorizePasswordChange(ctx, userID, currentPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.passwordPolicy.Check(ctx, newPassword, user.Email); err != nil {
+		s.logger.Info("password refused by policy",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	passwordHash, err := s.passwordHasher.Hash(newPassword)
+	if err != nil {
+		s.logger.Error("failed to generate password hash",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.UpdatePassword(ctx, userID, passwordHash); err != nil {
+			return err
+		}
+		// Reset links sent before would undo the change
+		if err := s.userTokenRepo.DeleteUnused(ctx, userID, model.UserTokenPasswordReset); err != nil {
+			return err
+		}
+		if err := recordActivity(ctx, s.activityRepo, userID, model.ActivityEntityUser, userID, nil,
+			model.ActivityActionPasswordChanged, nil, nil); err != nil {
+			return err
+		}
+
+		user, err = s.userRepo.GetByID(ctx, userID)
+		return err
+	})
+	if err != nil {
+		s.logger.Error("failed to change password",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("password changed successfully",
+		zap.String("user_id", userID.String()))
+	return user, nil
+}
+
+// ChangeEmail sets the email address of the specified user after checking the password or the time of the login
+// Verification links sent to the previous address are invalidated, so that they cannot verify the new one
+func (s *DefaultUserService) ChangeEmail(ctx context.Context, userID uuid.UUID, email, password string, authTime time.Time) (*model.User, error) {
+	user, err := s.authorizeAccountChange(ctx, userID, password, authTime)
+	if err != nil {
+		return nil, err
+	}
+	if user.Email == email {
+		return user, nil
+	}
+
+	var updated *model.User
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.UpdateEmail(ctx, userID, email); err != nil {
+			return err
+		}
+		if err := s.userTokenRepo.DeleteUnused(ctx, userID, model.UserTokenEmailVerification); err != nil {
+			return err
+		}
+
+		updated, err = s.userRepo.GetByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		return recordActivity(ctx, s.activityRepo, userID, model.ActivityEntityUser, userID, nil,
+			model.ActivityActionUpdated, userSnapshot(user), userSnapshot(updated))
+	})
+	if errors.Is(err, repository.ErrDuplicateEmail) {
+		s.logger.Warn("attempt to change email to existing email",
+			zap.String("user_id", userID.String()),
+			zap.String("email", email))
+		return nil, ErrEmailAlreadyExists
+	}
+	if err != nil {
+		s.logger.Error("failed to change email",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("email changed successfully",
+		zap.String("user_id", userID.String()),
+		zap.String("email", email))
+	return updated, nil
+}
+
+// DeleteAccount deletes the specified user after checking the password or the time of the login
+// The todos of the user are deleted first, so that the users they are shared with are notified;
+// comments, attachments, shares and webhooks of the user are deleted with the account
+func (s *DefaultUserService) DeleteAccount(ctx context.Context, userID uuid.UUID, password string, authTime time.Time) error {
+	if _, err := s.authorizeAccountChange(ctx, userID, password, authTime); err != nil {
+		return err
+	}
+
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.todoService.DeleteOwnedTodos(ctx, userID); err != nil {
+			return err
+		}
+		if err := s.userRepo.Delete(ctx, userID); err != nil {
+			return err
+		}
+		// The details of the account are not kept once it is deleted
+		return recordActivity(ctx, s.activityRepo, userID, model.ActivityEntityUser, userID, nil,
+			model.ActivityActionDeleted, nil, nil)
+	})
+	if err != nil {
+		s.logger.Error("failed to delete account",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return err
+	}
+
+	s.logger.Info("account deleted successfully",
+		zap.String("user_id", userID.String()))
+	return nil
+}
+
+// authorizeAccountChange retrieves the specified user, ensuring the password is theirs
+// Users without a password, who log in with an external provider or a passkey, cannot confirm the change
+// with one, so they have to have logged in within the reauthentication window instead
+func (s *DefaultUserService) authorizeAccountChange(ctx context.Context, userID uuid.UUID, password string, authTime time.Time) (*model.User, error) {
+	user, err := s.requireUsablePassword(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.HasPassword() {
+		if authTime.IsZero() || time.Since(authTime) > s.authConfig.ReauthenticationWindow {
+			s.logger.Info("account change without recent login",
+				zap.String("user_id", userID.String()),
+				zap.Time("auth_time", authTime))
+			return nil, ErrReauthenticationRequired
+		}
+		return user, nil
+	}
+
+	if err := s.verifyPassword(user, password); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// authorizePasswordChange retrieves the specified user, ensuring the current password is theirs
+// Users without a password set one with a password reset instead
+func (s *DefaultUserService) authorizePasswordChange(ctx context.Context, userID uuid.UUID, password string) (*model.User, error) {
+	user, err := s.requireUsablePassword(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.verifyPassword(user, password); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// requireUsablePassword retrieves the specified user, ensuring an admin has not removed their password
+// Such a user has to reset it first, as whoever the admin locked out may still hold a token of theirs
+func (s *DefaultUserService) requireUsablePassword(ctx context.Context, userID uuid.UUID) (*model.User, error) {
+	user, err := s.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.PasswordResetRequired() {
+		s.logger.Warn("account change before required password reset",
+			zap.String("user_id", userID.String()))
+		return nil, ErrPasswordResetRequired
+	}
+	return user, nil
+}
+
+// verifyPassword ensures the password given to confirm an account change is the user's
+func (s *DefaultUserService) verifyPassword(user *model.User, password string) error {
+	if match, err := s.passwordHasher.Verify(password, user.PasswordHash); err != nil || !match {
+		s.logger.Warn("account change with incorrect password",
+			zap.String("user_id", user.ID.String()),
+			zap.Error(err))
+		return ErrInvalidCurrentPassword
+	}
+	return nil
+}
+
+// userSnapshot returns the recorded state of a user account
+// Credentials are never recorded
+func userSnapshot(user *model.User) activitySnapshot {
+	return activitySnapshot{
+		"email":         user.Email,
+		"emailVerified": user.EmailVerified(),
+		"displayName":   user.DisplayName,
+		"timezone":      user.Timezone,
+		"locale":        user.Locale,
+	}
+}
@@ -2,22 +2,51 @@ package service_test
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/config"
 	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
 	"github.com/yukimaterrace/todoms/service"
 	"go.uber.org/zap"
-	"golang.org/x/crypto/bcrypt"
 )
 
-func TestCreateUser(t *testing.T) {
-	// Create a test logger
+// newUserService creates a UserService with the mocks
+func newUserService(
+	userRepo *MockUserRepository,
+	userTokenRepo *MockUserTokenRepository,
+	todoRepo *MockTodoRepository,
+) service.UserService {
 	logger := zap.NewNop()
+	todoService := service.NewTodoService(todoRepo, new(MockTodoShareRepository), newMockActivityRepository(),
+		new(MockTransactor), config.DefaultTodoQuotaConfig(), logger)
+	passwordPolicy := service.NewPasswordPolicy(nil, config.DefaultPasswordPolicyConfig(), logger)
+	return service.NewUserService(todoService, passwordPolicy, newTestPasswordHasher(), userRepo, userTokenRepo, new(MockIdentityRepository), newMockActivityRepository(), new(MockTransactor), config.DefaultAuthConfig(), logger)
+}
+
+// newUserWithPassword creates a user whose password is the given one
+func newUserWithPassword(t *testing.T, password string) *model.User {
+	passwordHash, err := newTestPasswordHasher().Hash(password)
+	require.NoError(t, err)
+	return &model.User{
+		ID:           uuid.New(),
+		Email:        "test@example.com",
+		PasswordHash: passwordHash,
+		UserProfile: model.UserProfile{
+			Timezone: model.DefaultTimezone,
+			Locale:   model.DefaultLocale,
+		},
+	}
+}
 
+func TestCreateUser(t *testing.T) {
 	// Test cases
 	testCases := []struct {
 		name          string
@@ -29,18 +58,28 @@ func TestCreateUser(t *testing.T) {
 		{
 			name:     "Success",
 			email:    "test@example.com",
-			password: "password123",
+			password: "amber-falcon-orbit-91",
 			setupMock: func(m *MockUserRepository) {
 				// Mock GetByEmail to return nil, indicating no user with this email exists
 				m.On("GetByEmail", mock.Anything, "test@example.com").Return(nil, errors.New("user not found"))
 				// Mock Create to return no error
 				m.On("Create", mock.Anything, mock.MatchedBy(func(user *model.User) bool {
 					return user.Email == "test@example.com" &&
-						bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte("password123")) == nil
+						user.Timezone == model.DefaultTimezone && user.Locale == model.DefaultLocale &&
+						passwordMatches(user.PasswordHash, "amber-falcon-orbit-91")
 				})).Return(nil)
 			},
 			expectedError: nil,
 		},
+		{
+			name:     "Password Refused By Policy",
+			email:    "weak@example.com",
+			password: "password123",
+			setupMock: func(m *MockUserRepository) {
+				m.On("GetByEmail", mock.Anything, "weak@example.com").Return(nil, errors.New("user not found"))
+			},
+			expectedError: &service.PasswordPolicyError{Violations: []service.PasswordViolation{{Reason: service.PasswordTooWeak}}},
+		},
 		{
 			name:     "Email Already Exists",
 			email:    "existing@example.com",
@@ -59,7 +98,7 @@ func TestCreateUser(t *testing.T) {
 		{
 			name:     "Repository Create Error",
 			email:    "error@example.com",
-			password: "password123",
+			password: "amber-falcon-orbit-91",
 			setupMock: func(m *MockUserRepository) {
 				// Mock GetByEmail to return nil, indicating no user with this email exists
 				m.On("GetByEmail", mock.Anything, "error@example.com").Return(nil, errors.New("user not found"))
@@ -79,7 +118,7 @@ func TestCreateUser(t *testing.T) {
 			mockRepo := new(MockUserRepository)
 			tc.setupMock(mockRepo)
 
-			userService := service.NewUserService(mockRepo, logger)
+			userService := newUserService(mockRepo, new(MockUserTokenRepository), new(MockTodoRepository))
 
 			// Execute
 			user, err := userService.CreateUser(context.Background(), tc.email, tc.password)
@@ -102,3 +141,326 @@ func TestCreateUser(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdateProfile(t *testing.T) {
+	user := newUserWithPassword(t, "password123")
+	req := model.UpdateProfileRequest{DisplayName: "Test User", Timezone: "Asia/Tokyo", Locale: "ja-JP"}
+
+	t.Run("Success", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+		userRepo.On("UpdateProfile", mock.Anything, user.ID, &model.UserProfile{
+			DisplayName: "Test User",
+			Timezone:    "Asia/Tokyo",
+			Locale:      "ja-JP",
+		}).Return(nil)
+
+		updated, err := newUserService(userRepo, new(MockUserTokenRepository), new(MockTodoRepository)).
+			UpdateProfile(context.Background(), user.ID, req)
+
+		require.NoError(t, err)
+		assert.Equal(t, "Test User", updated.DisplayName)
+		assert.Equal(t, "Asia/Tokyo", updated.Timezone)
+		assert.Equal(t, model.DefaultTimezone, user.Timezone)
+		userRepo.AssertExpectations(t)
+	})
+
+	t.Run("User not found", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		userRepo.On("GetByID", mock.Anything, user.ID).Return(nil, sql.ErrNoRows)
+
+		updated, err := newUserService(userRepo, new(MockUserTokenRepository), new(MockTodoRepository)).
+			UpdateProfile(context.Background(), user.ID, req)
+
+		assert.Equal(t, service.ErrUserNotFound, err)
+		assert.Nil(t, updated)
+	})
+}
+
+func TestChangePassword(t *testing.T) {
+	user := newUserWithPassword(t, "current-password")
+
+	testCases := []struct {
+		name            string
+		currentPassword string
+		newPassword     string
+		setupMocks      func(*MockUserRepository, *MockUserTokenRepository)
+		expectedError   error
+	}{
+		{
+			name:            "Success",
+			currentPassword: "current-password",
+			newPassword:     "amber-falcon-orbit-91",
+			setupMocks: func(userRepo *MockUserRepository, userTokenRepo *MockUserTokenRepository) {
+				userRepo.On("UpdatePassword", mock.Anything, user.ID, mock.MatchedBy(func(passwordHash string) bool {
+					return passwordMatches(passwordHash, "amber-falcon-orbit-91")
+				})).Return(nil)
+				userTokenRepo.On("DeleteUnused", mock.Anything, user.ID, model.UserTokenPasswordReset).Return(nil)
+			},
+		},
+		{
+			name:            "Incorrect current password",
+			currentPassword: "wrong-password",
+			newPassword:     "amber-falcon-orbit-91",
+			setupMocks:      func(*MockUserRepository, *MockUserTokenRepository) {},
+			expectedError:   service.ErrInvalidCurrentPassword,
+		},
+		{
+			name:            "New password contains the email",
+			currentPassword: "current-password",
+			newPassword:     user.Email + "-2024!",
+			setupMocks:      func(*MockUserRepository, *MockUserTokenRepository) {},
+			expectedError:   &service.PasswordPolicyError{Violations: []service.PasswordViolation{{Reason: service.PasswordContainsEmail}}},
+		},
+		{
+			name:            "Repository error",
+			currentPassword: "current-password",
+			newPassword:     "amber-falcon-orbit-91",
+			setupMocks: func(userRepo *MockUserRepository, userTokenRepo *MockUserTokenRepository) {
+				userRepo.On("UpdatePassword", mock.Anything, user.ID, mock.Anything).Return(errors.New("database error"))
+			},
+			expectedError: errors.New("database error"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			userRepo := new(MockUserRepository)
+			userTokenRepo := new(MockUserTokenRepository)
+			userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+			tc.setupMocks(userRepo, userTokenRepo)
+
+			updated, err := newUserService(userRepo, userTokenRepo, new(MockTodoRepository)).
+				ChangePassword(context.Background(), user.ID, tc.currentPassword, tc.newPassword)
+
+			if tc.expectedError != nil {
+				assert.EqualError(t, err, tc.expectedError.Error())
+				assert.Nil(t, updated)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, user.ID, updated.ID)
+			}
+			if tc.expectedError == service.ErrInvalidCurrentPassword {
+				userRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+			}
+			userRepo.AssertExpectations(t)
+			userTokenRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestChangeEmail(t *testing.T) {
+	user := newUserWithPassword(t, "password123")
+	externalUser := &model.User{ID: user.ID, Email: "test@example.com"}
+	resetUser := &model.User{ID: user.ID, Email: "test@example.com", PasswordHash: model.PasswordResetRequiredHash}
+
+	testCases := []struct {
+		name          string
+		user          *model.User
+		email         string
+		password      string
+		authTime      time.Time
+		setupMocks    func(*MockUserRepository, *MockUserTokenRepository)
+		expectedEmail string
+		expectedError error
+	}{
+		{
+			name:     "Success",
+			email:    "new@example.com",
+			password: "password123",
+			setupMocks: func(userRepo *MockUserRepository, userTokenRepo *MockUserTokenRepository) {
+				userRepo.On("UpdateEmail", mock.Anything, user.ID, "new@example.com").Return(nil)
+				userTokenRepo.On("DeleteUnused", mock.Anything, user.ID, model.UserTokenEmailVerification).Return(nil)
+				userRepo.On("GetByID", mock.Anything, user.ID).
+					Return(&model.User{ID: user.ID, Email: "new@example.com"}, nil).Once()
+			},
+			expectedEmail: "new@example.com",
+		},
+		{
+			name:          "Same email",
+			email:         "test@example.com",
+			password:      "password123",
+			setupMocks:    func(*MockUserRepository, *MockUserTokenRepository) {},
+			expectedEmail: "test@example.com",
+		},
+		{
+			name:     "Email already exists",
+			email:    "existing@example.com",
+			password: "password123",
+			setupMocks: func(userRepo *MockUserRepository, userTokenRepo *MockUserTokenRepository) {
+				userRepo.On("UpdateEmail", mock.Anything, user.ID, "existing@example.com").Return(repository.ErrDuplicateEmail)
+			},
+			expectedError: service.ErrEmailAlreadyExists,
+		},
+		{
+			name:          "Incorrect password",
+			email:         "new@example.com",
+			password:      "wrong-password",
+			setupMocks:    func(*MockUserRepository, *MockUserTokenRepository) {},
+			expectedError: service.ErrInvalidCurrentPassword,
+		},
+		{
+			name:          "Recent login does not replace the password",
+			email:         "new@example.com",
+			password:      "wrong-password",
+			authTime:      time.Now(),
+			setupMocks:    func(*MockUserRepository, *MockUserTokenRepository) {},
+			expectedError: service.ErrInvalidCurrentPassword,
+		},
+		{
+			name:     "User without a password who logged in recently",
+			user:     externalUser,
+			email:    "new@example.com",
+			authTime: time.Now().Add(-time.Minute),
+			setupMocks: func(userRepo *MockUserRepository, userTokenRepo *MockUserTokenRepository) {
+				userRepo.On("UpdateEmail", mock.Anything, user.ID, "new@example.com").Return(nil)
+				userTokenRepo.On("DeleteUnused", mock.Anything, user.ID, model.UserTokenEmailVerification).Return(nil)
+				userRepo.On("GetByID", mock.Anything, user.ID).
+					Return(&model.User{ID: user.ID, Email: "new@example.com"}, nil).Once()
+			},
+			expectedEmail: "new@example.com",
+		},
+		{
+			name:          "User without a password who logged in too long ago",
+			user:          externalUser,
+			email:         "new@example.com",
+			authTime:      time.Now().Add(-config.DefaultReauthenticationWindow - time.Minute),
+			setupMocks:    func(*MockUserRepository, *MockUserTokenRepository) {},
+			expectedError: service.ErrReauthenticationRequired,
+		},
+		{
+			name:          "User without a password and a token without a login",
+			user:          externalUser,
+			email:         "new@example.com",
+			setupMocks:    func(*MockUserRepository, *MockUserTokenRepository) {},
+			expectedError: service.ErrReauthenticationRequired,
+		},
+		{
+			// Whoever the admin locked out may hold a token of a recent login
+			name:          "User whose password an admin removed",
+			user:          resetUser,
+			email:         "attacker@example.com",
+			authTime:      time.Now(),
+			setupMocks:    func(*MockUserRepository, *MockUserTokenRepository) {},
+			expectedError: service.ErrPasswordResetRequired,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			current := user
+			if tc.user != nil {
+				current = tc.user
+			}
+			userRepo := new(MockUserRepository)
+			userTokenRepo := new(MockUserTokenRepository)
+			userRepo.On("GetByID", mock.Anything, user.ID).Return(current, nil).Once()
+			tc.setupMocks(userRepo, userTokenRepo)
+
+			updated, err := newUserService(userRepo, userTokenRepo, new(MockTodoRepository)).
+				ChangeEmail(context.Background(), user.ID, tc.email, tc.password, tc.authTime)
+
+			if tc.expectedError != nil {
+				assert.Equal(t, tc.expectedError, err)
+				assert.Nil(t, updated)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectedEmail, updated.Email)
+			}
+			userRepo.AssertExpectations(t)
+			userTokenRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDeleteAccount(t *testing.T) {
+	user := newUserWithPassword(t, "password123")
+	todos := []model.Todo{
+		{ID: uuid.New(), UserID: user.ID, Title: "First"},
+		{ID: uuid.New(), UserID: user.ID, Title: "Second"},
+	}
+
+	t.Run("Deletes the owned todos with the account", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		todoRepo := new(MockTodoRepository)
+		userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+		todoRepo.On("GetByUserID", mock.Anything, user.ID).Return(todos, nil)
+		todoRepo.On("Delete", mock.Anything, todos[0].ID).Return(nil)
+		todoRepo.On("Delete", mock.Anything, todos[1].ID).Return(nil)
+		userRepo.On("Delete", mock.Anything, user.ID).Return(nil)
+
+		err := newUserService(userRepo, new(MockUserTokenRepository), todoRepo).
+			DeleteAccount(context.Background(), user.ID, "password123", time.Time{})
+
+		require.NoError(t, err)
+		userRepo.AssertExpectations(t)
+		todoRepo.AssertExpectations(t)
+	})
+
+	t.Run("Incorrect password", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		todoRepo := new(MockTodoRepository)
+		userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+
+		err := newUserService(userRepo, new(MockUserTokenRepository), todoRepo).
+			DeleteAccount(context.Background(), user.ID, "wrong-password", time.Time{})
+
+		assert.Equal(t, service.ErrInvalidCurrentPassword, err)
+		todoRepo.AssertNotCalled(t, "GetByUserID", mock.Anything, mock.Anything)
+		userRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+
+	t.Run("User without a password who logged in recently", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		todoRepo := new(MockTodoRepository)
+		userRepo.On("GetByID", mock.Anything, user.ID).Return(&model.User{ID: user.ID, Email: "test@example.com"}, nil)
+		todoRepo.On("GetByUserID", mock.Anything, user.ID).Return([]model.Todo{}, nil)
+		userRepo.On("Delete", mock.Anything, user.ID).Return(nil)
+
+		err := newUserService(userRepo, new(MockUserTokenRepository), todoRepo).
+			DeleteAccount(context.Background(), user.ID, "", time.Now().Add(-time.Minute))
+
+		require.NoError(t, err)
+		userRepo.AssertExpectations(t)
+	})
+
+	t.Run("User without a password who logged in too long ago", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		todoRepo := new(MockTodoRepository)
+		userRepo.On("GetByID", mock.Anything, user.ID).Return(&model.User{ID: user.ID, Email: "test@example.com"}, nil)
+
+		err := newUserService(userRepo, new(MockUserTokenRepository), todoRepo).
+			DeleteAccount(context.Background(), user.ID, "", time.Now().Add(-time.Hour))
+
+		assert.Equal(t, service.ErrReauthenticationRequired, err)
+		userRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+
+	t.Run("User whose password an admin removed", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		todoRepo := new(MockTodoRepository)
+		resetUser := &model.User{ID: user.ID, Email: "test@example.com", PasswordHash: model.PasswordResetRequiredHash}
+		userRepo.On("GetByID", mock.Anything, user.ID).Return(resetUser, nil)
+
+		err := newUserService(userRepo, new(MockUserTokenRepository), todoRepo).
+			DeleteAccount(context.Background(), user.ID, "", time.Now())
+
+		assert.Equal(t, service.ErrPasswordResetRequired, err)
+		todoRepo.AssertNotCalled(t, "GetByUserID", mock.Anything, mock.Anything)
+		userRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Todo deletion error", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		todoRepo := new(MockTodoRepository)
+		userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+		todoRepo.On("GetByUserID", mock.Anything, user.ID).Return(todos, nil)
+		todoRepo.On("Delete", mock.Anything, todos[0].ID).Return(errors.New("database error"))
+
+		err := newUserService(userRepo, new(MockUserTokenRepository), todoRepo).
+			DeleteAccount(context.Background(), user.ID, "password123", time.Time{})
+
+		assert.EqualError(t, err, "database error")
+		userRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+}
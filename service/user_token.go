@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+)
+
+// userTokenSecretSize is the number of random bytes in a user token
+const userTokenSecretSize = 32
+
+// issueUserToken replaces the unused tokens of a user for the purpose with a new one and returns it
+func issueUserToken(
+	ctx context.Context,
+	userTokenRepo repository.UserTokenRepository,
+	transactor repository.Transactor,
+	signingKey string,
+	userID uuid.UUID,
+	purpose model.UserTokenPurpose,
+	ttl time.Duration,
+) (string, error) {
+	token, value, err := newUserToken(signingKey, userID, purpose)
+	if err != nil {
+		return "", err
+	}
+
+	err = transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := userTokenRepo.DeleteUnused(ctx, userID, purpose); err != nil {
+			return err
+		}
+		return userTokenRepo.Create(ctx, token, ttl)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return value, nil
+}
+
+// newUserToken generates a user token for the purpose, returning the row to store and the token to give the user
+// The token is a random secret followed by its signature, both base64url encoded, and only its hash is stored
+func newUserToken(signingKey string, userID uuid.UUID, purpose model.UserTokenPurpose) (*model.UserToken, string, error) {
+	secret := make([]byte, userTokenSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+
+	token := &model.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hashUserToken(secret),
+	}
+	value := base64.RawURLEncoding.EncodeToString(secret) + "." +
+		base64.RawURLEncoding.EncodeToString(userTokenMAC(signingKey, purpose, secret))
+	return token, value, nil
+}
+
+// parseUserToken returns the hash a user token is stored by when its signature is valid for the purpose
+// Forged tokens are rejected without looking them up, and the purpose is signed with the secret,
+// so a token issued for one purpose is not accepted for another
+func parseUserToken(signingKey string, purpose model.UserTokenPurpose, token string) ([]byte, bool) {
+	encodedSecret, encodedMAC, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, false
+	}
+
+	secret, err := base64.RawURLEncoding.DecodeString(encodedSecret)
+	if err != nil || len(secret) != userTokenSecretSize {
+		return nil, false
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(encodedMAC)
+	if err != nil || !hmac.Equal(mac, userTokenMAC(signingKey, purpose, secret)) {
+		return nil, false
+	}
+
+	return hashUserToken(secret), true
+}
+
+// hashUserToken returns the SHA-256 hash a user token secret is stored by
+func hashUserToken(secret []byte) []byte {
+	hash := sha256.Sum256(secret)
+	return hash[:]
+}
+
+// userTokenMAC returns the HMAC-SHA256 of a user token secret for the purpose
+func userTokenMAC(signingKey string, purpose model.UserTokenPurpose, secret []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(signingKey))
+	mac.Write([]byte("user-token:" + string(purpose) + ":"))
+	mac.Write(secret)
+	return mac.Sum(nil)
+}
+
+// userTokenLink adds a user token to the URL of the page it is used on as the token query parameter
+func userTokenLink(pageURL, token string) (string, error) {
+	link, err := url.Parse(pageURL)
+	if err != nil {
+		return "", err
+	}
+
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return link.String(), nil
+}
@@ -0,0 +1,239 @@
+package service
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/big"
+)
+
+// COSE algorithms accepted for passkeys (RFC 9053), in order of preference
+const (
+	coseAlgES256 int64 = -7
+	coseAlgEdDSA int64 = -8
+	coseAlgRS256 int64 = -257
+)
+
+// COSE key parameters (RFC 9052)
+const (
+	coseKeyType    int64 = 1
+	coseKeyAlg     int64 = 3
+	coseKeyCurve   int64 = -1
+	coseKeyX       int64 = -2
+	coseKeyY       int64 = -3
+	coseKeyRSAN    int64 = -1
+	coseKeyRSAE    int64 = -2
+	coseKtyOKP     int64 = 1
+	coseKtyEC2     int64 = 2
+	coseKtyRSA     int64 = 3
+	coseCrvP256    int64 = 1
+	coseCrvEd25519 int64 = 6
+)
+
+// Authenticator data flags
+const (
+	authDataUserPresent        byte = 0x01
+	authDataUserVerified       byte = 0x04
+	authDataAttestedCredential byte = 0x40
+)
+
+// Client data types of the ceremonies
+const (
+	clientDataTypeCreate = "webauthn.create"
+	clientDataTypeGet    = "webauthn.get"
+)
+
+// webauthnChallengeSize is the number of random bytes in a challenge
+const webauthnChallengeSize = 32
+
+// minRSAKeyBits is the smallest RSA key accepted for passkeys
+const minRSAKeyBits = 2048
+
+// errMalformedWebAuthnData is returned when data from an authenticator or the client cannot be parsed
+var errMalformedWebAuthnData = errors.New("malformed WebAuthn data")
+
+// collectedClientData is the client data the browser signs along with the authenticator data
+type collectedClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// parseClientData parses client data JSON and decodes its challenge
+func parseClientData(clientDataJSON []byte) (*collectedClientData, []byte, error) {
+	var clientData collectedClientData
+	if err := json.Unmarshal(clientDataJSON, &clientData); err != nil {
+		return nil, nil, errMalformedWebAuthnData
+	}
+
+	challenge, err := base64.RawURLEncoding.DecodeString(clientData.Challenge)
+	if err != nil {
+		return nil, nil, errMalformedWebAuthnData
+	}
+	return &clientData, challenge, nil
+}
+
+// authenticatorData is the data an authenticator returns with a registration or signs for a login
+type authenticatorData struct {
+	rpIDHash  []byte
+	flags     byte
+	signCount uint32
+
+	// Set for registrations only
+	aaguid       []byte
+	credentialID []byte
+	publicKey    []byte
+}
+
+// has reports whether all the flags are set
+func (a *authenticatorData) has(flags byte) bool {
+	return a.flags&flags == flags
+}
+
+// parseAuthenticatorData parses authenticator data, including the attested credential data when it is present
+// Extensions that follow are ignored
+func parseAuthenticatorData(data []byte) (*authenticatorData, error) {
+	if len(data) < 37 {
+		return nil, errMalformedWebAuthnData
+	}
+
+	authData := &authenticatorData{
+		rpIDHash:  data[:32],
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	if !authData.has(authDataAttestedCredential) {
+		return authData, nil
+	}
+
+	rest := data[37:]
+	if len(rest) < 18 {
+		return nil, errMalformedWebAuthnData
+	}
+	authData.aaguid = rest[:16]
+	idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if idLength == 0 || len(rest) < idLength {
+		return nil, errMalformedWebAuthnData
+	}
+	authData.credentialID = rest[:idLength]
+	rest = rest[idLength:]
+
+	_, keyLength, err := decodeCBOR(rest)
+	if err != nil {
+		return nil, errMalformedWebAuthnData
+	}
+	authData.publicKey = rest[:keyLength]
+	return authData, nil
+}
+
+// parseAttestationObject parses the attestation object of a registration and returns its authenticator data
+// The attestation statement is not verified, as registrations ask for no attestation
+func parseAttestationObject(attestationObject []byte) (*authenticatorData, error) {
+	decoded, _, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return nil, errMalformedWebAuthnData
+	}
+	object, ok := decoded.(map[any]any)
+	if !ok {
+		return nil, errMalformedWebAuthnData
+	}
+	if _, ok := object["fmt"].(string); !ok {
+		return nil, errMalformedWebAuthnData
+	}
+	rawAuthData, ok := object["authData"].([]byte)
+	if !ok {
+		return nil, errMalformedWebAuthnData
+	}
+
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if !authData.has(authDataAttestedCredential) {
+		return nil, errMalformedWebAuthnData
+	}
+	return authData, nil
+}
+
+// coseKey is a public key of a passkey with the algorithm it signs with
+type coseKey struct {
+	alg int64
+	key crypto.PublicKey
+}
+
+// parseCOSEKey parses a COSE key, accepting ES256, EdDSA and RS256 keys only
+func parseCOSEKey(data []byte) (*coseKey, error) {
+	decoded, _, err := decodeCBOR(data)
+	if err != nil {
+		return nil, errMalformedWebAuthnData
+	}
+	params, ok := decoded.(map[any]any)
+	if !ok {
+		return nil, errMalformedWebAuthnData
+	}
+	kty, _ := params[coseKeyType].(int64)
+	alg, _ := params[coseKeyAlg].(int64)
+
+	switch {
+	case kty == coseKtyEC2 && alg == coseAlgES256:
+		crv, _ := params[coseKeyCurve].(int64)
+		x, _ := params[coseKeyX].([]byte)
+		y, _ := params[coseKeyY].([]byte)
+		if crv != coseCrvP256 || len(x) != 32 || len(y) != 32 {
+			return nil, errMalformedWebAuthnData
+		}
+		// Reject points that are not on the curve
+		point := append(append([]byte{0x04}, x...), y...)
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return nil, errMalformedWebAuthnData
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		return &coseKey{alg: alg, key: key}, nil
+
+	case kty == coseKtyOKP && alg == coseAlgEdDSA:
+		crv, _ := params[coseKeyCurve].(int64)
+		x, _ := params[coseKeyX].([]byte)
+		if crv != coseCrvEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, errMalformedWebAuthnData
+		}
+		return &coseKey{alg: alg, key: ed25519.PublicKey(x)}, nil
+
+	case kty == coseKtyRSA && alg == coseAlgRS256:
+		n, _ := params[coseKeyRSAN].([]byte)
+		e, _ := params[coseKeyRSAE].([]byte)
+		if len(e) == 0 || len(e) > 4 {
+			return nil, errMalformedWebAuthnData
+		}
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if key.N.BitLen() < minRSAKeyBits || key.E < 3 || key.E%2 == 0 {
+			return nil, errMalformedWebAuthnData
+		}
+		return &coseKey{alg: alg, key: key}, nil
+	}
+	return nil, errMalformedWebAuthnData
+}
+
+// verify checks a signature of the key over the data
+func (k *coseKey) verify(data, signature []byte) bool {
+	switch key := k.key.(type) {
+	case *ecdsa.PublicKey:
+		hash := sha256.Sum256(data)
+		return ecdsa.VerifyASN1(key, hash[:], signature)
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, data, signature)
+	case *rsa.PublicKey:
+		hash := sha256.Sum256(data)
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature) == nil
+	}
+	return false
+}
@@ -0,0 +1,433 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrInvalidPasskeyChallenge is returned when a ceremony is completed with a challenge that was not issued
+	// for it, or was already used or has expired
+	ErrInvalidPasskeyChallenge = errors.New("invalid or expired passkey challenge")
+
+	// ErrInvalidPasskeyRegistration is returned when the response of an authenticator to a registration fails verification
+	ErrInvalidPasskeyRegistration = errors.New("invalid passkey registration")
+
+	// ErrPasskeyAlreadyRegistered is returned when registering a passkey that is already registered
+	ErrPasskeyAlreadyRegistered = errors.New("passkey is already registered")
+
+	// ErrPasskeyAuthenticationFailed is returned when the response of an authenticator to a login fails verification
+	// An unknown passkey fails the same way, so that it does not reveal which passkeys are registered
+	ErrPasskeyAuthenticationFailed = errors.New("passkey authentication failed")
+
+	// ErrPasskeyNotFound is returned when a user has no passkey with the given ID
+	ErrPasskeyNotFound = errors.New("passkey not found")
+)
+
+// DefaultPasskeyName is the name given to a passkey registered without one
+const DefaultPasskeyName = "Passkey"
+
+// webauthnAlgorithms are the COSE algorithms accepted for new passkeys, in order of preference
+var webauthnAlgorithms = []int64{coseAlgES256, coseAlgEdDSA, coseAlgRS256}
+
+// WebAuthnService defines the interface for passkey registration and login (WebAuthn Level 2)
+type WebAuthnService interface {
+	// BeginRegistration issues a challenge for the user to register a new passkey with
+	BeginRegistration(ctx context.Context, userID uuid.UUID) (*model.PublicKeyCredentialCreationOptions, error)
+
+	// FinishRegistration verifies the response of an authenticator to a registration and stores the passkey
+	FinishRegistration(ctx context.Context, userID uuid.UUID, name string, credential *model.RegistrationCredential) (*model.WebAuthnCredential, error)
+
+	// ListCredentials retrieves the passkeys of the user
+	ListCredentials(ctx context.Context, userID uuid.UUID) ([]model.WebAuthnCredential, error)
+
+	// DeleteCredential removes a passkey of the user
+	DeleteCredential(ctx context.Context, userID, id uuid.UUID) error
+
+	// BeginLogin issues a challenge to log in with any registered passkey
+	BeginLogin(ctx context.Context) (*model.PublicKeyCredentialRequestOptions, error)
+
+	// FinishLogin verifies the response of an authenticator to a login and returns the user the passkey belongs to
+	FinishLogin(ctx context.Context, credential *model.AssertionCredential) (*model.User, error)
+
+	// PurgeExpired deletes expired challenges and returns how many were deleted
+	PurgeExpired(ctx context.Context) (int64, error)
+}
+
+// DefaultWebAuthnService implements the WebAuthnService interface
+type DefaultWebAuthnService struct {
+	userRepo     repository.UserRepository
+	webAuthnRepo repository.WebAuthnRepository
+	config       *config.WebAuthnConfig
+	logger       *zap.Logger
+}
+
+// NewWebAuthnService creates a new DefaultWebAuthnService instance
+func NewWebAuthnService(
+	userRepo repository.UserRepository,
+	webAuthnRepo repository.WebAuthnRepository,
+	cfg *config.WebAuthnConfig,
+	logger *zap.Logger,
+) WebAuthnService {
+	return &DefaultWebAuthnService{
+		userRepo:     userRepo,
+		webAuthnRepo: webAuthnRepo,
+		config:       cfg,
+		logger:       logger,
+	}
+}
+
+// BeginRegistration issues a challenge for the user to register a new passkey with
+// The passkeys the user already has are excluded, so that an authenticator is not registered twice
+func (s *DefaultWebAuthnService) BeginRegistration(ctx context.Context, userID uuid.UUID) (*model.PublicKeyCredentialCreationOptions, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		s.logger.Error("failed to get user",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	credentials, err := s.webAuthnRepo.GetCredentialsByUserID(ctx, userID)
+	if err != nil {
+		s.logger.Error("failed to get passkeys",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	challenge, err := s.issueChallenge(ctx, &userID, model.WebAuthnRegistration)
+	if err != nil {
+		return nil, err
+	}
+
+	displayName := user.DisplayName
+	if displayName == "" {
+		displayName = user.Email
+	}
+	params := make([]model.PublicKeyCredentialParameters, len(webauthnAlgorithms))
+	for i, alg := range webauthnAlgorithms {
+		params[i] = model.PublicKeyCredentialParameters{Type: "public-key", Alg: alg}
+	}
+	exclude := make([]model.PublicKeyCredentialDescriptor, len(credentials))
+	for i, credential := range credentials {
+		exclude[i] = model.PublicKeyCredentialDescriptor{Type: "public-key", ID: credential.CredentialID}
+	}
+
+	return &model.PublicKeyCredentialCreationOptions{
+		Challenge: challenge,
+		RP:        model.RelyingParty{ID: s.config.RPID, Name: s.config.RPName},
+		User: model.PublicKeyCredentialUser{
+			ID:          user.ID[:],
+			Name:        user.Email,
+			DisplayName: displayName,
+		},
+		PubKeyCredParams:   params,
+		Timeout:            s.config.ChallengeTTL.Milliseconds(),
+		ExcludeCredentials: exclude,
+		AuthenticatorSelection: model.AuthenticatorSelection{
+			ResidentKey:        "required",
+			RequireResidentKey: true,
+			UserVerification:   "required",
+		},
+		Attestation: "none",
+	}, nil
+}
+
+// FinishRegistration verifies the response of an authenticator to a registration and stores the passkey
+// The attestation statement is not verified, as no attestation is asked for
+func (s *DefaultWebAuthnService) FinishRegistration(ctx context.Context, userID uuid.UUID, name string, credential *model.RegistrationCredential) (*model.WebAuthnCredential, error) {
+	clientData, err := s.consumeChallenge(ctx, credential.Response.ClientDataJSON, clientDataTypeCreate, model.WebAuthnRegistration, &userID)
+	if err != nil {
+		return nil, err
+	}
+
+	authData, err := parseAttestationObject(credential.Response.AttestationObject)
+	if err == nil {
+		err = s.verifyAuthenticatorData(authData)
+	}
+	if err == nil && !bytes.Equal(authData.credentialID, credential.RawID) {
+		err = errMalformedWebAuthnData
+	}
+	if err == nil {
+		_, err = parseCOSEKey(authData.publicKey)
+	}
+	if err != nil {
+		s.logger.Warn("invalid passkey registration",
+			zap.String("user_id", userID.String()),
+			zap.String("origin", clientData.Origin),
+			zap.Error(err))
+		return nil, ErrInvalidPasskeyRegistration
+	}
+
+	if name == "" {
+		name = DefaultPasskeyName
+	}
+	stored := &model.WebAuthnCredential{
+		UserID:       userID,
+		CredentialID: authData.credentialID,
+		PublicKey:    authData.publicKey,
+		SignCount:    int64(authData.signCount),
+		AAGUID:       authData.aaguid,
+		Name:         name,
+	}
+	err = s.webAuthnRepo.CreateCredential(ctx, stored)
+	if errors.Is(err, repository.ErrDuplicateCredential) {
+		s.logger.Warn("attempt to register a passkey twice",
+			zap.String("user_id", userID.String()))
+		return nil, ErrPasskeyAlreadyRegistered
+	}
+	if err != nil {
+		s.logger.Error("failed to store passkey",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("passkey registered",
+		zap.String("user_id", userID.String()),
+		zap.String("passkey_id", stored.ID.String()))
+	return stored, nil
+}
+
+// ListCredentials retrieves the passkeys of the user
+func (s *DefaultWebAuthnService) ListCredentials(ctx context.Context, userID uuid.UUID) ([]model.WebAuthnCredential, error) {
+	credentials, err := s.webAuthnRepo.GetCredentialsByUserID(ctx, userID)
+	if err != nil {
+		s.logger.Error("failed to get passkeys",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+	return credentials, nil
+}
+
+// DeleteCredential removes a passkey of the user
+func (s *DefaultWebAuthnService) DeleteCredential(ctx context.Context, userID, id uuid.UUID) error {
+	deleted, err := s.webAuthnRepo.DeleteCredential(ctx, userID, id)
+	if err != nil {
+		s.logger.Error("failed to delete passkey",
+			zap.String("user_id", userID.String()),
+			zap.String("passkey_id", id.String()),
+			zap.Error(err))
+		return err
+	}
+	if !deleted {
+		return ErrPasskeyNotFound
+	}
+
+	s.logger.Info("passkey deleted",
+		zap.String("user_id", userID.String()),
+		zap.String("passkey_id", id.String()))
+	return nil
+}
+
+// BeginLogin issues a challenge to log in with any registered passkey
+func (s *DefaultWebAuthnService) BeginLogin(ctx context.Context) (*model.PublicKeyCredentialRequestOptions, error) {
+	challenge, err := s.issueChallenge(ctx, nil, model.WebAuthnLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.PublicKeyCredentialRequestOptions{
+		Challenge:        challenge,
+		Timeout:          s.config.ChallengeTTL.Milliseconds(),
+		RPID:             s.config.RPID,
+		AllowCredentials: []model.PublicKeyCredentialDescriptor{},
+		UserVerification: "required",
+	}, nil
+}
+
+// FinishLogin verifies the response of an authenticator to a login and returns the user the passkey belongs to
+func (s *DefaultWebAuthnService) FinishLogin(ctx context.Context, credential *model.AssertionCredential) (*model.User, error) {
+	if _, err := s.consumeChallenge(ctx, credential.Response.ClientDataJSON, clientDataTypeGet, model.WebAuthnLogin, nil); err != nil {
+		return nil, err
+	}
+
+	stored, err := s.webAuthnRepo.GetCredentialByCredentialID(ctx, credential.RawID)
+	if errors.Is(err, sql.ErrNoRows) {
+		s.logger.Warn("login with an unknown passkey")
+		return nil, ErrPasskeyAuthenticationFailed
+	}
+	if err != nil {
+		s.logger.Error("failed to get passkey",
+			zap.Error(err))
+		return nil, err
+	}
+
+	signCount, err := s.verifyAssertion(stored, credential)
+	if err != nil {
+		s.logger.Warn("invalid passkey assertion",
+			zap.String("user_id", stored.UserID.String()),
+			zap.String("passkey_id", stored.ID.String()),
+			zap.Error(err))
+		return nil, ErrPasskeyAuthenticationFailed
+	}
+
+	if err := s.webAuthnRepo.UpdateCredentialUsage(ctx, stored.ID, signCount); err != nil {
+		s.logger.Error("failed to update passkey usage",
+			zap.String("passkey_id", stored.ID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, stored.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPasskeyAuthenticationFailed
+	}
+	if err != nil {
+		s.logger.Error("failed to get user",
+			zap.String("user_id", stored.UserID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("passkey verified",
+		zap.String("user_id", user.ID.String()),
+		zap.String("passkey_id", stored.ID.String()))
+	return user, nil
+}
+
+// PurgeExpired deletes expired challenges and returns how many were deleted
+func (s *DefaultWebAuthnService) PurgeExpired(ctx context.Context) (int64, error) {
+	deleted, err := s.webAuthnRepo.DeleteExpiredChallenges(ctx)
+	if err != nil {
+		s.logger.Error("failed to purge expired passkey challenges",
+			zap.Error(err))
+		return 0, err
+	}
+
+	if deleted > 0 {
+		s.logger.Info("purged expired passkey challenges",
+			zap.Int64("count", deleted))
+	}
+	return deleted, nil
+}
+
+// issueChallenge generates a random challenge for a ceremony and stores its hash
+func (s *DefaultWebAuthnService) issueChallenge(ctx context.Context, userID *uuid.UUID, ceremony model.WebAuthnCeremony) ([]byte, error) {
+	challenge := make([]byte, webauthnChallengeSize)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, err
+	}
+
+	hash := sha256.Sum256(challenge)
+	err := s.webAuthnRepo.CreateChallenge(ctx, &model.WebAuthnChallenge{
+		ChallengeHash: hash[:],
+		UserID:        userID,
+		Ceremony:      ceremony,
+	}, s.config.ChallengeTTL)
+	if err != nil {
+		s.logger.Error("failed to store passkey challenge",
+			zap.String("ceremony", string(ceremony)),
+			zap.Error(err))
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// consumeChallenge checks the client data of a ceremony and uses up the challenge it was signed for
+// A registration challenge can only be used by the user it was issued to
+func (s *DefaultWebAuthnService) consumeChallenge(
+	ctx context.Context,
+	clientDataJSON []byte,
+	clientDataType string,
+	ceremony model.WebAuthnCeremony,
+	userID *uuid.UUID,
+) (*collectedClientData, error) {
+	clientData, challenge, err := parseClientData(clientDataJSON)
+	if err != nil || clientData.Type != clientDataType {
+		s.logger.Warn("invalid passkey client data",
+			zap.String("ceremony", string(ceremony)))
+		return nil, ErrInvalidPasskeyChallenge
+	}
+	if !slices.Contains(s.config.Origins, clientData.Origin) || clientData.CrossOrigin {
+		s.logger.Warn("passkey ceremony from a disallowed origin",
+			zap.String("ceremony", string(ceremony)),
+			zap.String("origin", clientData.Origin))
+		return nil, ErrInvalidPasskeyChallenge
+	}
+
+	hash := sha256.Sum256(challenge)
+	issued, err := s.webAuthnRepo.ConsumeChallenge(ctx, hash[:], ceremony)
+	if errors.Is(err, sql.ErrNoRows) {
+		s.logger.Warn("passkey ceremony with an unknown or expired challenge",
+			zap.String("ceremony", string(ceremony)))
+		return nil, ErrInvalidPasskeyChallenge
+	}
+	if err != nil {
+		s.logger.Error("failed to consume passkey challenge",
+			zap.String("ceremony", string(ceremony)),
+			zap.Error(err))
+		return nil, err
+	}
+	if userID != nil && (issued.UserID == nil || *issued.UserID != *userID) {
+		s.logger.Warn("passkey registration with a challenge issued to another user",
+			zap.String("user_id", userID.String()))
+		return nil, ErrInvalidPasskeyChallenge
+	}
+
+	return clientData, nil
+}
+
+// verifyAuthenticatorData checks the authenticator data is for this relying party and the user was verified
+func (s *DefaultWebAuthnService) verifyAuthenticatorData(authData *authenticatorData) error {
+	rpIDHash := sha256.Sum256([]byte(s.config.RPID))
+	if subtle.ConstantTimeCompare(authData.rpIDHash, rpIDHash[:]) != 1 {
+		return errors.New("relying party ID mismatch")
+	}
+	if !authData.has(authDataUserPresent | authDataUserVerified) {
+		return errors.New("user not verified")
+	}
+	return nil
+}
+
+// verifyAssertion checks the response of an authenticator to a login against the stored passkey
+// and returns the signature counter to store
+func (s *DefaultWebAuthnService) verifyAssertion(stored *model.WebAuthnCredential, credential *model.AssertionCredential) (int64, error) {
+	// The user handle is the ID of the user the passkey was registered for
+	if len(credential.Response.UserHandle) > 0 && !bytes.Equal(credential.Response.UserHandle, stored.UserID[:]) {
+		return 0, errors.New("user handle mismatch")
+	}
+
+	authData, err := parseAuthenticatorData(credential.Response.AuthenticatorData)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.verifyAuthenticatorData(authData); err != nil {
+		return 0, err
+	}
+
+	key, err := parseCOSEKey(stored.PublicKey)
+	if err != nil {
+		return 0, err
+	}
+	clientDataHash := sha256.Sum256(credential.Response.ClientDataJSON)
+	signed := append(append([]byte{}, credential.Response.AuthenticatorData...), clientDataHash[:]...)
+	if !key.verify(signed, credential.Response.Signature) {
+		return 0, errors.New("invalid signature")
+	}
+
+	// A counter that did not increase means the authenticator may have been cloned
+	// Authenticators that do not count, such as synced passkeys, always report zero
+	signCount := int64(authData.signCount)
+	if (signCount != 0 || stored.SignCount != 0) && signCount <= stored.SignCount {
+		return 0, errors.New("signature counter did not increase")
+	}
+	return signCount, nil
+}
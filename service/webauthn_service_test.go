@@ -0,0 +1,709 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+	"github.com/yukimaterrace/todoms/service"
+	"go.uber.org/zap"
+)
+
+const (
+	testRPID   = "example.com"
+	testOrigin = "https://example.com"
+)
+
+// newWebAuthnService creates a WebAuthnService with the mocks
+func newWebAuthnService(userRepo *MockUserRepository, webAuthnRepo *MockWebAuthnRepository) service.WebAuthnService {
+	cfg := &config.WebAuthnConfig{
+		RPID:         testRPID,
+		RPName:       "todoms",
+		Origins:      []string{testOrigin},
+		ChallengeTTL: 5 * time.Minute,
+	}
+	return service.NewWebAuthnService(userRepo, webAuthnRepo, cfg, zap.NewNop())
+}
+
+// expectChallenge expects a challenge for the ceremony to be issued and lets it be consumed at most once
+// Consuming any other challenge fails as it would in the database
+func expectChallenge(webAuthnRepo *MockWebAuthnRepository, ceremony model.WebAuthnCeremony) {
+	issued := new(model.WebAuthnChallenge)
+	webAuthnRepo.On("CreateChallenge", mock.Anything, mock.MatchedBy(func(challenge *model.WebAuthnChallenge) bool {
+		return challenge.Ceremony == ceremony
+	}), 5*time.Minute).Run(func(args mock.Arguments) {
+		*issued = *args.Get(1).(*model.WebAuthnChallenge)
+	}).Return(nil).Once()
+	consumed := false
+	webAuthnRepo.On("ConsumeChallenge", mock.Anything, mock.MatchedBy(func(challengeHash []byte) bool {
+		return !consumed && bytes.Equal(challengeHash, issued.ChallengeHash)
+	}), ceremony).Run(func(mock.Arguments) {
+		consumed = true
+	}).Return(issued, nil).Maybe()
+	webAuthnRepo.On("ConsumeChallenge", mock.Anything, mock.Anything, mock.Anything).Return(nil, sql.ErrNoRows).Maybe()
+}
+
+// softAuthenticator is a software WebAuthn authenticator holding a single passkey
+type softAuthenticator struct {
+	rpID         string
+	origin       string
+	credentialID []byte
+	signer       crypto.Signer
+	userHandle   []byte
+	signCount    uint32
+
+	// flags are the authenticator data flags, user present and verified unless changed
+	flags byte
+}
+
+// newSoftAuthenticator creates a software authenticator with an ES256 or Ed25519 key
+func newSoftAuthenticator(t *testing.T, ed25519Key bool) *softAuthenticator {
+	credentialID := make([]byte, 16)
+	_, err := rand.Read(credentialID)
+	require.NoError(t, err)
+
+	var signer crypto.Signer
+	if ed25519Key {
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	} else {
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+	require.NoError(t, err)
+
+	return &softAuthenticator{
+		rpID:         testRPID,
+		origin:       testOrigin,
+		credentialID: credentialID,
+		signer:       signer,
+		flags:        0x01 | 0x04,
+	}
+}
+
+// create responds to navigator.credentials.create() with the options
+func (a *softAuthenticator) create(t *testing.T, options *model.PublicKeyCredentialCreationOptions) *model.RegistrationCredential {
+	a.userHandle = options.User.ID
+
+	attestedCredential := make([]byte, 18)
+	binary.BigEndian.PutUint16(attestedCredential[16:], uint16(len(a.credentialID)))
+	attestedCredential = append(attestedCredential, a.credentialID...)
+	attestedCredential = append(attestedCredential, a.coseKey(t)...)
+
+	authData := append(a.authenticatorData(0x40), attestedCredential...)
+	attestationObject := appendCBOR(nil, cborMap{
+		{"fmt", "none"},
+		{"attStmt", cborMap{}},
+		{"authData", authData},
+	})
+
+	return &model.RegistrationCredential{
+		ID:    base64.RawURLEncoding.EncodeToString(a.credentialID),
+		RawID: a.credentialID,
+		Type:  "public-key",
+		Response: model.AuthenticatorAttestationResponse{
+			ClientDataJSON:    a.clientData(t, "webauthn.create", options.Challenge),
+			AttestationObject: attestationObject,
+		},
+	}
+}
+
+// get responds to navigator.credentials.get() with the options, signing with the passkey
+func (a *softAuthenticator) get(t *testing.T, options *model.PublicKeyCredentialRequestOptions) *model.AssertionCredential {
+	a.signCount++
+	authData := a.authenticatorData(0)
+	clientDataJSON := a.clientData(t, "webauthn.get", options.Challenge)
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte{}, authData...), clientDataHash[:]...)
+	var signature []byte
+	var err error
+	if _, ok := a.signer.(ed25519.PrivateKey); ok {
+		signature, err = a.signer.Sign(rand.Reader, signed, crypto.Hash(0))
+	} else {
+		hash := sha256.Sum256(signed)
+		signature, err = a.signer.Sign(rand.Reader, hash[:], crypto.SHA256)
+	}
+	require.NoError(t, err)
+
+	return &model.AssertionCredential{
+		ID:    base64.RawURLEncoding.EncodeToString(a.credentialID),
+		RawID: a.credentialID,
+		Type:  "public-key",
+		Response: model.AuthenticatorAssertionResponse{
+			ClientDataJSON:    clientDataJSON,
+			AuthenticatorData: authData,
+			Signature:         signature,
+			UserHandle:        a.userHandle,
+		},
+	}
+}
+
+// authenticatorData returns the authenticator data up to the signature counter, with extra flags
+func (a *softAuthenticator) authenticatorData(flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+	data := append(rpIDHash[:], a.flags|flags)
+	return binary.BigEndian.AppendUint32(data, a.signCount)
+}
+
+// clientData returns the client data JSON a browser would collect
+func (a *softAuthenticator) clientData(t *testing.T, clientDataType string, challenge []byte) []byte {
+	clientData, err := json.Marshal(map[string]any{
+		"type":        clientDataType,
+		"challenge":   base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":      a.origin,
+		"crossOrigin": false,
+	})
+	require.NoError(t, err)
+	return clientData
+}
+
+// coseKey returns the public key of the passkey as a COSE key
+func (a *softAuthenticator) coseKey(t *testing.T) []byte {
+	switch key := a.signer.Public().(type) {
+	case *ecdsa.PublicKey:
+		return appendCBOR(nil, cborMap{
+			{1, 2}, {3, -7}, {-1, 1},
+			{-2, key.X.FillBytes(make([]byte, 32))},
+			{-3, key.Y.FillBytes(make([]byte, 32))},
+		})
+	case ed25519.PublicKey:
+		return appendCBOR(nil, cborMap{{1, 1}, {3, -8}, {-1, 6}, {-2, []byte(key)}})
+	}
+	t.Fatalf("unsupported key type %T", a.signer)
+	return nil
+}
+
+// cborMap is a CBOR map encoded by appendCBOR in the order of its entries
+type cborMap [][2]any
+
+// appendCBOR encodes the integers, byte and text strings and maps WebAuthn uses as CBOR
+func appendCBOR(b []byte, value any) []byte {
+	switch v := value.(type) {
+	case int:
+		if v < 0 {
+			return appendCBORHead(b, 1, uint64(-1-v))
+		}
+		return appendCBORHead(b, 0, uint64(v))
+	case []byte:
+		return append(appendCBORHead(b, 2, uint64(len(v))), v...)
+	case string:
+		return append(appendCBORHead(b, 3, uint64(len(v))), v...)
+	case cborMap:
+		b = appendCBORHead(b, 5, uint64(len(v)))
+		for _, entry := range v {
+			b = appendCBOR(appendCBOR(b, entry[0]), entry[1])
+		}
+		return b
+	}
+	panic("unsupported CBOR value")
+}
+
+// appendCBORHead encodes the major type and argument of a CBOR data item
+func appendCBORHead(b []byte, major byte, arg uint64) []byte {
+	switch {
+	case arg < 24:
+		return append(b, major<<5|byte(arg))
+	case arg <= 0xff:
+		return append(b, major<<5|24, byte(arg))
+	case arg <= 0xffff:
+		return binary.BigEndian.AppendUint16(append(b, major<<5|25), uint16(arg))
+	default:
+		return binary.BigEndian.AppendUint32(append(b, major<<5|26), uint32(arg))
+	}
+}
+
+// registerPasskey registers the passkey of the authenticator for a user and returns it as stored
+func registerPasskey(t *testing.T, user *model.User, authenticator *softAuthenticator) *model.WebAuthnCredential {
+	userRepo := new(MockUserRepository)
+	webAuthnRepo := new(MockWebAuthnRepository)
+	userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+	webAuthnRepo.On("GetCredentialsByUserID", mock.Anything, user.ID).Return([]model.WebAuthnCredential{}, nil)
+	expectChallenge(webAuthnRepo, model.WebAuthnRegistration)
+	webAuthnRepo.On("CreateCredential", mock.Anything, mock.Anything).Return(nil)
+
+	webAuthnService := newWebAuthnService(userRepo, webAuthnRepo)
+	options, err := webAuthnService.BeginRegistration(context.Background(), user.ID)
+	require.NoError(t, err)
+	stored, err := webAuthnService.FinishRegistration(context.Background(), user.ID, "", authenticator.create(t, options))
+	require.NoError(t, err)
+
+	stored.ID = uuid.New()
+	return stored
+}
+
+func TestBeginPasskeyRegistration(t *testing.T) {
+	user := newUserWithPassword(t, "password123")
+	registered := model.WebAuthnCredential{CredentialID: []byte("registered")}
+
+	userRepo := new(MockUserRepository)
+	webAuthnRepo := new(MockWebAuthnRepository)
+	userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+	webAuthnRepo.On("GetCredentialsByUserID", mock.Anything, user.ID).Return([]model.WebAuthnCredential{registered}, nil)
+	expectChallenge(webAuthnRepo, model.WebAuthnRegistration)
+
+	options, err := newWebAuthnService(userRepo, webAuthnRepo).BeginRegistration(context.Background(), user.ID)
+
+	require.NoError(t, err)
+	assert.Len(t, options.Challenge, 32)
+	assert.Equal(t, model.RelyingParty{ID: testRPID, Name: "todoms"}, options.RP)
+	assert.Equal(t, model.Base64URL(user.ID[:]), options.User.ID)
+	assert.Equal(t, user.Email, options.User.Name)
+	assert.Equal(t, user.Email, options.User.DisplayName)
+	assert.Equal(t, int64(-7), options.PubKeyCredParams[0].Alg)
+	assert.Equal(t, []model.PublicKeyCredentialDescriptor{{Type: "public-key", ID: registered.CredentialID}}, options.ExcludeCredentials)
+	assert.Equal(t, "required", options.AuthenticatorSelection.UserVerification)
+	assert.Equal(t, "none", options.Attestation)
+	assert.Equal(t, int64(300000), options.Timeout)
+
+	// Only the hash of the challenge is stored
+	hash := sha256.Sum256(options.Challenge)
+	webAuthnRepo.AssertCalled(t, "CreateChallenge", mock.Anything, mock.MatchedBy(func(challenge *model.WebAuthnChallenge) bool {
+		return bytes.Equal(challenge.ChallengeHash, hash[:]) && *challenge.UserID == user.ID
+	}), 5*time.Minute)
+	userRepo.AssertExpectations(t)
+}
+
+func TestFinishPasskeyRegistration(t *testing.T) {
+	user := newUserWithPassword(t, "password123")
+
+	testCases := []struct {
+		name string
+		// respond registers a passkey with the authenticator, changing its response as needed
+		respond       func(t *testing.T, options *model.PublicKeyCredentialCreationOptions) *model.RegistrationCredential
+		setupMock     func(*MockWebAuthnRepository)
+		expectedError error
+	}{
+		{
+			name: "Success with ES256",
+			respond: func(t *testing.T, options *model.PublicKeyCredentialCreationOptions) *model.RegistrationCredential {
+				return newSoftAuthenticator(t, false).create(t, options)
+			},
+			setupMock: func(webAuthnRepo *MockWebAuthnRepository) {
+				webAuthnRepo.On("CreateCredential", mock.Anything, mock.MatchedBy(func(credential *model.WebAuthnCredential) bool {
+					return credential.UserID == user.ID && credential.Name == "Laptop" && len(credential.AAGUID) == 16
+				})).Return(nil)
+			},
+		},
+		{
+			name: "Success with Ed25519",
+			respond: func(t *testing.T, options *model.PublicKeyCredentialCreationOptions) *model.RegistrationCredential {
+				return newSoftAuthenticator(t, true).create(t, options)
+			},
+			setupMock: func(webAuthnRepo *MockWebAuthnRepository) {
+				webAuthnRepo.On("CreateCredential", mock.Anything, mock.Anything).Return(nil)
+			},
+		},
+		{
+			name: "Disallowed origin",
+			respond: func(t *testing.T, options *model.PublicKeyCredentialCreationOptions) *model.RegistrationCredential {
+				authenticator := newSoftAuthenticator(t, false)
+				authenticator.origin = "https://evil.example"
+				return authenticator.create(t, options)
+			},
+			setupMock:     func(*MockWebAuthnRepository) {},
+			expectedError: service.ErrInvalidPasskeyChallenge,
+		},
+		{
+			name: "Challenge that was not issued",
+			respond: func(t *testing.T, options *model.PublicKeyCredentialCreationOptions) *model.RegistrationCredential {
+				forged := *options
+				forged.Challenge = []byte("forged-challenge")
+				return newSoftAuthenticator(t, false).create(t, &forged)
+			},
+			setupMock:     func(*MockWebAuthnRepository) {},
+			expectedError: service.ErrInvalidPasskeyChallenge,
+		},
+		{
+			name: "Other relying party",
+			respond: func(t *testing.T, options *model.PublicKeyCredentialCreationOptions) *model.RegistrationCredential {
+				authenticator := newSoftAuthenticator(t, false)
+				authenticator.rpID = "evil.example"
+				return authenticator.create(t, options)
+			},
+			setupMock:     func(*MockWebAuthnRepository) {},
+			expectedError: service.ErrInvalidPasskeyRegistration,
+		},
+		{
+			name: "User not verified",
+			respond: func(t *testing.T, options *model.PublicKeyCredentialCreationOptions) *model.RegistrationCredential {
+				authenticator := newSoftAuthenticator(t, false)
+				authenticator.flags = 0x01
+				return authenticator.create(t, options)
+			},
+			setupMock:     func(*MockWebAuthnRepository) {},
+			expectedError: service.ErrInvalidPasskeyRegistration,
+		},
+		{
+			name: "Credential ID does not match the authenticator data",
+			respond: func(t *testing.T, options *model.PublicKeyCredentialCreationOptions) *model.RegistrationCredential {
+				credential := newSoftAuthenticator(t, false).create(t, options)
+				credential.RawID = []byte("other-credential")
+				return credential
+			},
+			setupMock:     func(*MockWebAuthnRepository) {},
+			expectedError: service.ErrInvalidPasskeyRegistration,
+		},
+		{
+			name: "Malformed attestation object",
+			respond: func(t *testing.T, options *model.PublicKeyCredentialCreationOptions) *model.RegistrationCredential {
+				credential := newSoftAuthenticator(t, false).create(t, options)
+				credential.Response.AttestationObject = credential.Response.AttestationObject[:40]
+				return credential
+			},
+			setupMock:     func(*MockWebAuthnRepository) {},
+			expectedError: service.ErrInvalidPasskeyRegistration,
+		},
+		{
+			name: "Already registered",
+			respond: func(t *testing.T, options *model.PublicKeyCredentialCreationOptions) *model.RegistrationCredential {
+				return newSoftAuthenticator(t, false).create(t, options)
+			},
+			setupMock: func(webAuthnRepo *MockWebAuthnRepository) {
+				webAuthnRepo.On("CreateCredential", mock.Anything, mock.Anything).Return(repository.ErrDuplicateCredential)
+			},
+			expectedError: service.ErrPasskeyAlreadyRegistered,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			userRepo := new(MockUserRepository)
+			webAuthnRepo := new(MockWebAuthnRepository)
+			userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+			webAuthnRepo.On("GetCredentialsByUserID", mock.Anything, user.ID).Return([]model.WebAuthnCredential{}, nil)
+			expectChallenge(webAuthnRepo, model.WebAuthnRegistration)
+			tc.setupMock(webAuthnRepo)
+			webAuthnService := newWebAuthnService(userRepo, webAuthnRepo)
+
+			options, err := webAuthnService.BeginRegistration(context.Background(), user.ID)
+			require.NoError(t, err)
+			credential := tc.respond(t, options)
+
+			stored, err := webAuthnService.FinishRegistration(context.Background(), user.ID, "Laptop", credential)
+
+			if tc.expectedError != nil {
+				assert.Equal(t, tc.expectedError, err)
+				assert.Nil(t, stored)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, []byte(credential.RawID), stored.CredentialID)
+				assert.NotEmpty(t, stored.PublicKey)
+			}
+			webAuthnRepo.AssertExpectations(t)
+		})
+	}
+
+	t.Run("Challenge is used once", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		webAuthnRepo := new(MockWebAuthnRepository)
+		userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+		webAuthnRepo.On("GetCredentialsByUserID", mock.Anything, user.ID).Return([]model.WebAuthnCredential{}, nil)
+		expectChallenge(webAuthnRepo, model.WebAuthnRegistration)
+		webAuthnRepo.On("CreateCredential", mock.Anything, mock.Anything).Return(nil).Once()
+		webAuthnService := newWebAuthnService(userRepo, webAuthnRepo)
+
+		options, err := webAuthnService.BeginRegistration(context.Background(), user.ID)
+		require.NoError(t, err)
+		credential := newSoftAuthenticator(t, false).create(t, options)
+
+		_, err = webAuthnService.FinishRegistration(context.Background(), user.ID, "", credential)
+		require.NoError(t, err)
+		_, err = webAuthnService.FinishRegistration(context.Background(), user.ID, "", credential)
+		assert.Equal(t, service.ErrInvalidPasskeyChallenge, err)
+	})
+
+	t.Run("Challenge issued to another user", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		webAuthnRepo := new(MockWebAuthnRepository)
+		userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+		webAuthnRepo.On("GetCredentialsByUserID", mock.Anything, user.ID).Return([]model.WebAuthnCredential{}, nil)
+		expectChallenge(webAuthnRepo, model.WebAuthnRegistration)
+		webAuthnService := newWebAuthnService(userRepo, webAuthnRepo)
+
+		options, err := webAuthnService.BeginRegistration(context.Background(), user.ID)
+		require.NoError(t, err)
+
+		stored, err := webAuthnService.FinishRegistration(context.Background(), uuid.New(), "", newSoftAuthenticator(t, false).create(t, options))
+
+		assert.Equal(t, service.ErrInvalidPasskeyChallenge, err)
+		assert.Nil(t, stored)
+		webAuthnRepo.AssertNotCalled(t, "CreateCredential", mock.Anything, mock.Anything)
+	})
+}
+
+func TestFinishPasskeyLogin(t *testing.T) {
+	user := newUserWithPassword(t, "password123")
+
+	testCases := []struct {
+		name       string
+		ed25519Key bool
+		// respond logs in with the registered passkey of the authenticator, changing its response as needed
+		respond       func(t *testing.T, authenticator *softAuthenticator, options *model.PublicKeyCredentialRequestOptions) *model.AssertionCredential
+		setupMock     func(*MockWebAuthnRepository, *model.WebAuthnCredential)
+		expectedError error
+	}{
+		{
+			name: "Success with ES256",
+			respond: func(t *testing.T, authenticator *softAuthenticator, options *model.PublicKeyCredentialRequestOptions) *model.AssertionCredential {
+				return authenticator.get(t, options)
+			},
+			setupMock: func(webAuthnRepo *MockWebAuthnRepository, stored *model.WebAuthnCredential) {
+				webAuthnRepo.On("UpdateCredentialUsage", mock.Anything, stored.ID, int64(1)).Return(nil)
+			},
+		},
+		{
+			name:       "Success with Ed25519",
+			ed25519Key: true,
+			respond: func(t *testing.T, authenticator *softAuthenticator, options *model.PublicKeyCredentialRequestOptions) *model.AssertionCredential {
+				return authenticator.get(t, options)
+			},
+			setupMock: func(webAuthnRepo *MockWebAuthnRepository, stored *model.WebAuthnCredential) {
+				webAuthnRepo.On("UpdateCredentialUsage", mock.Anything, stored.ID, int64(1)).Return(nil)
+			},
+		},
+		{
+			name: "Authenticator without a signature counter",
+			respond: func(t *testing.T, authenticator *softAuthenticator, options *model.PublicKeyCredentialRequestOptions) *model.AssertionCredential {
+				authenticator.signCount = math32Max
+				return authenticator.get(t, options)
+			},
+			setupMock: func(webAuthnRepo *MockWebAuthnRepository, stored *model.WebAuthnCredential) {
+				webAuthnRepo.On("UpdateCredentialUsage", mock.Anything, stored.ID, int64(0)).Return(nil)
+			},
+		},
+		{
+			name: "Tampered signature",
+			respond: func(t *testing.T, authenticator *softAuthenticator, options *model.PublicKeyCredentialRequestOptions) *model.AssertionCredential {
+				credential := authenticator.get(t, options)
+				credential.Response.Signature[len(credential.Response.Signature)-1] ^= 0xff
+				return credential
+			},
+			setupMock:     func(*MockWebAuthnRepository, *model.WebAuthnCredential) {},
+			expectedError: service.ErrPasskeyAuthenticationFailed,
+		},
+		{
+			name: "Signed by another key",
+			respond: func(t *testing.T, authenticator *softAuthenticator, options *model.PublicKeyCredentialRequestOptions) *model.AssertionCredential {
+				authenticator.signer = newSoftAuthenticator(t, false).signer
+				return authenticator.get(t, options)
+			},
+			setupMock:     func(*MockWebAuthnRepository, *model.WebAuthnCredential) {},
+			expectedError: service.ErrPasskeyAuthenticationFailed,
+		},
+		{
+			name: "Signature counter did not increase",
+			respond: func(t *testing.T, authenticator *softAuthenticator, options *model.PublicKeyCredentialRequestOptions) *model.AssertionCredential {
+				return authenticator.get(t, options)
+			},
+			setupMock: func(_ *MockWebAuthnRepository, stored *model.WebAuthnCredential) {
+				stored.SignCount = 5
+			},
+			expectedError: service.ErrPasskeyAuthenticationFailed,
+		},
+		{
+			name: "User handle of another user",
+			respond: func(t *testing.T, authenticator *softAuthenticator, options *model.PublicKeyCredentialRequestOptions) *model.AssertionCredential {
+				otherID := uuid.New()
+				authenticator.userHandle = otherID[:]
+				return authenticator.get(t, options)
+			},
+			setupMock:     func(*MockWebAuthnRepository, *model.WebAuthnCredential) {},
+			expectedError: service.ErrPasskeyAuthenticationFailed,
+		},
+		{
+			name: "User not verified",
+			respond: func(t *testing.T, authenticator *softAuthenticator, options *model.PublicKeyCredentialRequestOptions) *model.AssertionCredential {
+				authenticator.flags = 0x01
+				return authenticator.get(t, options)
+			},
+			setupMock:     func(*MockWebAuthnRepository, *model.WebAuthnCredential) {},
+			expectedError: service.ErrPasskeyAuthenticationFailed,
+		},
+		{
+			name: "Registration client data",
+			respond: func(t *testing.T, authenticator *softAuthenticator, options *model.PublicKeyCredentialRequestOptions) *model.AssertionCredential {
+				credential := authenticator.get(t, options)
+				credential.Response.ClientDataJSON = authenticator.clientData(t, "webauthn.create", options.Challenge)
+				return credential
+			},
+			setupMock:     func(*MockWebAuthnRepository, *model.WebAuthnCredential) {},
+			expectedError: service.ErrInvalidPasskeyChallenge,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			authenticator := newSoftAuthenticator(t, tc.ed25519Key)
+			stored := registerPasskey(t, user, authenticator)
+
+			userRepo := new(MockUserRepository)
+			webAuthnRepo := new(MockWebAuthnRepository)
+			userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil).Maybe()
+			webAuthnRepo.On("GetCredentialByCredentialID", mock.Anything, stored.CredentialID).Return(stored, nil).Maybe()
+			expectChallenge(webAuthnRepo, model.WebAuthnLogin)
+			tc.setupMock(webAuthnRepo, stored)
+			webAuthnService := newWebAuthnService(userRepo, webAuthnRepo)
+
+			options, err := webAuthnService.BeginLogin(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, testRPID, options.RPID)
+			assert.Empty(t, options.AllowCredentials)
+
+			loggedIn, err := webAuthnService.FinishLogin(context.Background(), tc.respond(t, authenticator, options))
+
+			if tc.expectedError != nil {
+				assert.Equal(t, tc.expectedError, err)
+				assert.Nil(t, loggedIn)
+				webAuthnRepo.AssertNotCalled(t, "UpdateCredentialUsage", mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, user.ID, loggedIn.ID)
+			}
+			webAuthnRepo.AssertExpectations(t)
+		})
+	}
+
+	t.Run("Unknown passkey", func(t *testing.T) {
+		webAuthnRepo := new(MockWebAuthnRepository)
+		webAuthnRepo.On("GetCredentialByCredentialID", mock.Anything, mock.Anything).Return(nil, sql.ErrNoRows)
+		expectChallenge(webAuthnRepo, model.WebAuthnLogin)
+		webAuthnService := newWebAuthnService(new(MockUserRepository), webAuthnRepo)
+
+		options, err := webAuthnService.BeginLogin(context.Background())
+		require.NoError(t, err)
+
+		loggedIn, err := webAuthnService.FinishLogin(context.Background(), newSoftAuthenticator(t, false).get(t, options))
+
+		assert.Equal(t, service.ErrPasskeyAuthenticationFailed, err)
+		assert.Nil(t, loggedIn)
+	})
+
+	t.Run("Assertion cannot be replayed", func(t *testing.T) {
+		authenticator := newSoftAuthenticator(t, false)
+		stored := registerPasskey(t, user, authenticator)
+
+		userRepo := new(MockUserRepository)
+		webAuthnRepo := new(MockWebAuthnRepository)
+		userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+		webAuthnRepo.On("GetCredentialByCredentialID", mock.Anything, stored.CredentialID).Return(stored, nil)
+		webAuthnRepo.On("UpdateCredentialUsage", mock.Anything, stored.ID, int64(1)).Return(nil).Once()
+		expectChallenge(webAuthnRepo, model.WebAuthnLogin)
+		webAuthnService := newWebAuthnService(userRepo, webAuthnRepo)
+
+		options, err := webAuthnService.BeginLogin(context.Background())
+		require.NoError(t, err)
+		assertion := authenticator.get(t, options)
+
+		_, err = webAuthnService.FinishLogin(context.Background(), assertion)
+		require.NoError(t, err)
+		_, err = webAuthnService.FinishLogin(context.Background(), assertion)
+		assert.Equal(t, service.ErrInvalidPasskeyChallenge, err)
+	})
+}
+
+// math32Max makes the next signature counter of a soft authenticator wrap around to zero,
+// as reported by authenticators that do not count
+const math32Max = 1<<32 - 1
+
+func TestDeletePasskey(t *testing.T) {
+	userID := uuid.New()
+	passkeyID := uuid.New()
+
+	testCases := []struct {
+		name          string
+		deleted       bool
+		expectedError error
+	}{
+		{name: "Success", deleted: true},
+		{name: "Not found", deleted: false, expectedError: service.ErrPasskeyNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			webAuthnRepo := new(MockWebAuthnRepository)
+			webAuthnRepo.On("DeleteCredential", mock.Anything, userID, passkeyID).Return(tc.deleted, nil)
+
+			err := newWebAuthnService(new(MockUserRepository), webAuthnRepo).DeleteCredential(context.Background(), userID, passkeyID)
+
+			assert.Equal(t, tc.expectedError, err)
+			webAuthnRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthenticatePasskey(t *testing.T) {
+	user := newUserWithPassword(t, "password123")
+	authenticator := newSoftAuthenticator(t, false)
+	stored := registerPasskey(t, user, authenticator)
+
+	// login logs in with the passkey through an AuthenticationService using the config
+	login := func(t *testing.T, authConfig *config.AuthConfig) (*service.TokenPair, error) {
+		userRepo := new(MockUserRepository)
+		webAuthnRepo := new(MockWebAuthnRepository)
+		userRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+		webAuthnRepo.On("GetCredentialByCredentialID", mock.Anything, stored.CredentialID).Return(stored, nil)
+		webAuthnRepo.On("UpdateCredentialUsage", mock.Anything, stored.ID, mock.AnythingOfType("int64")).Return(nil)
+		expectChallenge(webAuthnRepo, model.WebAuthnLogin)
+		webAuthnService := newWebAuthnService(userRepo, webAuthnRepo)
+		authService := service.NewJWTAuthService(userRepo, newMockSessionRepository(), newTestPasswordHasher(), newMFAService(userRepo, newMockMFARepository()),
+			webAuthnService, nil, authConfig, zap.NewNop())
+
+		options, err := webAuthnService.BeginLogin(context.Background())
+		require.NoError(t, err)
+		return authService.AuthenticatePasskey(context.Background(), authenticator.get(t, options))
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		authConfig := config.NewAuthConfig("test-secret-key", 15*time.Minute, 24*time.Hour)
+
+		tokenPair, err := login(t, authConfig)
+
+		require.NoError(t, err)
+		claims, err := service.NewJWTAuthService(nil, nil, nil, nil, nil, nil, authConfig, zap.NewNop()).ValidateToken(tokenPair.AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, user.ID.String(), claims.UserID)
+	})
+
+	t.Run("Unverified email refused when unverified access is none", func(t *testing.T) {
+		authConfig := config.NewAuthConfig("test-secret-key", 15*time.Minute, 24*time.Hour)
+		authConfig.UnverifiedAccess = config.UnverifiedAccessNone
+
+		tokenPair, err := login(t, authConfig)
+
+		assert.Equal(t, service.ErrEmailNotVerified, err)
+		assert.Nil(t, tokenPair)
+	})
+
+	t.Run("Failed assertion", func(t *testing.T) {
+		authConfig := config.NewAuthConfig("test-secret-key", 15*time.Minute, 24*time.Hour)
+		webAuthnRepo := new(MockWebAuthnRepository)
+		webAuthnRepo.On("GetCredentialByCredentialID", mock.Anything, mock.Anything).Return(nil, sql.ErrNoRows)
+		expectChallenge(webAuthnRepo, model.WebAuthnLogin)
+		webAuthnService := newWebAuthnService(new(MockUserRepository), webAuthnRepo)
+		authService := service.NewJWTAuthService(new(MockUserRepository), nil, nil, nil, webAuthnService, nil, authConfig, zap.NewNop())
+
+		options, err := webAuthnService.BeginLogin(context.Background())
+		require.NoError(t, err)
+		tokenPair, err := authService.AuthenticatePasskey(context.Background(), newSoftAuthenticator(t, false).get(t, options))
+
+		assert.Equal(t, service.ErrPasskeyAuthenticationFailed, err)
+		assert.Nil(t, tokenPair)
+	})
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrWebhookURLNotAllowed is returned when a webhook URL does not point at a public address
+var ErrWebhookURLNotAllowed = errors.New("webhook URL does not resolve to a public address")
+
+// nonPublicPrefixes are the address ranges webhooks are not delivered to: loopback, private, link-local
+// (including cloud metadata services), shared, reserved, multicast and documentation ranges, in IPv4 and IPv6
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("224.0.0.0/3"),
+	netip.MustParsePrefix("::/128"),
+	netip.MustParsePrefix("::1/128"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("100::/64"),
+	netip.MustParsePrefix("2001:db8::/32"),
+	netip.MustParsePrefix("fc00::/7"),
+	netip.MustParsePrefix("fe80::/10"),
+	netip.MustParsePrefix("ff00::/8"),
+}
+
+// HostResolver looks up the addresses of a host name, as *net.Resolver does
+type HostResolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+// isPublicAddress reports whether an address can be reached over the public internet
+// IPv4 addresses mapped into IPv6 are checked as IPv4
+func isPublicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkWebhookURL returns ErrWebhookURLNotAllowed unless every address the host of the URL resolves to is public
+// The check is repeated when connecting, as the host may resolve differently by then
+func checkWebhookURL(ctx context.Context, resolver HostResolver, rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return ErrWebhookURLNotAllowed
+	}
+
+	host := parsed.Hostname()
+	if host == "" || strings.EqualFold(strings.TrimSuffix(host, "."), "localhost") {
+		return ErrWebhookURLNotAllowed
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !isPublicAddress(addr) {
+			return ErrWebhookURLNotAllowed
+		}
+		return nil
+	}
+
+	addrs, err := resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil || len(addrs) == 0 {
+		return ErrWebhookURLNotAllowed
+	}
+	for _, addr := range addrs {
+		if !isPublicAddress(addr) {
+			return ErrWebhookURLNotAllowed
+		}
+	}
+	return nil
+}
+
+// publicAddressControl is a net.Dialer Control function refusing connections to addresses that are not public
+// It runs after the host name is resolved, so a host resolving to another address when delivering is refused too
+func publicAddressControl(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil || !isPublicAddress(addrPort.Addr()) {
+		return ErrWebhookURLNotAllowed
+	}
+	return nil
+}
+
+// newWebhookHTTPClient creates a client for webhook deliveries with the timeout, which does not follow redirects
+// Unless allowPrivateNetworks is set, it only connects to public addresses and does not use a proxy
+func newWebhookHTTPClient(timeout time.Duration, allowPrivateNetworks bool) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !allowPrivateNetworks {
+		dialer := &net.Dialer{
+			Timeout: timeout,
+			Control: publicAddressControl,
+		}
+		transport.Proxy = nil
+		transport.DialContext = dialer.DialContext
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+)
+
+// webhookEventTypes maps the todo domain events to the webhook events they are delivered as
+// An assignment changes the todo, so it is delivered as an update
+var webhookEventTypes = map[string]string{
+	model.DomainEventTodoCreated:   model.WebhookEventTodoCreated,
+	model.DomainEventTodoUpdated:   model.WebhookEventTodoUpdated,
+	model.DomainEventTodoCompleted: model.WebhookEventTodoCompleted,
+	model.DomainEventTodoAssigned:  model.WebhookEventTodoUpdated,
+	model.DomainEventTodoDeleted:   model.WebhookEventTodoDeleted,
+}
+
+// WebhookDispatcher is an EventPublisher that queues the todo events relayed from the outbox
+// for the webhooks subscribed to them
+type WebhookDispatcher struct {
+	webhookRepo repository.WebhookRepository
+}
+
+// NewWebhookDispatcher creates a new WebhookDispatcher instance
+func NewWebhookDispatcher(webhookRepo repository.WebhookRepository) EventPublisher {
+	return &WebhookDispatcher{webhookRepo: webhookRepo}
+}
+
+// Publish queues deliveries of the event for the webhooks of its audience
+// The relay calls it in the transaction that marks the event as published, and the ID of the event
+// is used as the webhook event ID, so an event relayed again is not delivered twice
+func (d *WebhookDispatcher) Publish(ctx context.Context, event *model.OutboxEvent) error {
+	eventType, ok := webhookEventTypes[event.EventType]
+	if !ok || event.AggregateType != model.AggregateTodo {
+		return nil
+	}
+
+	var data model.TodoEventData
+	if err := json.Unmarshal(event.Payload, &data); err != nil {
+		return err
+	}
+	payload, err := json.Marshal(model.WebhookEvent{
+		ID:        event.ID.String(),
+		Type:      eventType,
+		CreatedAt: event.CreatedAt.UTC(),
+		Data:      data.TodoResponse,
+	})
+	if err != nil {
+		return err
+	}
+
+	return d.webhookRepo.EnqueueDeliveries(ctx, event.Audience, event.ID, eventType, payload)
+}
@@ -0,0 +1,120 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+)
+
+func TestWebhookDispatcher(t *testing.T) {
+	ownerID := uuid.New()
+	viewerID := uuid.New()
+	todoID := uuid.New()
+	payload, err := json.Marshal(model.TodoEventData{
+		TodoResponse: model.TodoResponse{ID: todoID.String(), Title: "Buy milk"},
+		UserID:       ownerID.String(),
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name          string
+		aggregateType string
+		eventType     string
+		repoErr       error
+		wantType      string
+		wantErr       bool
+	}{
+		{
+			name:          "Created",
+			aggregateType: model.AggregateTodo,
+			eventType:     model.DomainEventTodoCreated,
+			wantType:      model.WebhookEventTodoCreated,
+		},
+		{
+			name:          "Completed",
+			aggregateType: model.AggregateTodo,
+			eventType:     model.DomainEventTodoCompleted,
+			wantType:      model.WebhookEventTodoCompleted,
+		},
+		{
+			name:          "Assignment is delivered as an update",
+			aggregateType: model.AggregateTodo,
+			eventType:     model.DomainEventTodoAssigned,
+			wantType:      model.WebhookEventTodoUpdated,
+		},
+		{
+			name:          "Deleted",
+			aggregateType: model.AggregateTodo,
+			eventType:     model.DomainEventTodoDeleted,
+			wantType:      model.WebhookEventTodoDeleted,
+		},
+		{
+			name:          "Unknown event type is ignored",
+			aggregateType: model.AggregateTodo,
+			eventType:     "todo.archived",
+		},
+		{
+			name:          "Other aggregates are ignored",
+			aggregateType: "user",
+			eventType:     model.DomainEventTodoCreated,
+		},
+		{
+			name:          "Repository error fails the publish",
+			aggregateType: model.AggregateTodo,
+			eventType:     model.DomainEventTodoUpdated,
+			repoErr:       errors.New("database error"),
+			wantType:      model.WebhookEventTodoUpdated,
+			wantErr:       true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			event := newTestOutboxEvent(tc.eventType, 0)
+			event.AggregateType = tc.aggregateType
+			event.AggregateID = todoID
+			event.Payload = payload
+			event.Audience = pq.StringArray{ownerID.String(), viewerID.String()}
+
+			webhookRepo := new(MockWebhookRepository)
+			var body []byte
+			if tc.wantType != "" {
+				webhookRepo.On("EnqueueDeliveries", mock.Anything, []string(event.Audience), event.ID, tc.wantType, mock.Anything).
+					Return(tc.repoErr).Run(func(args mock.Arguments) {
+					body = args.Get(4).([]byte)
+				})
+			}
+
+			err := service.NewWebhookDispatcher(webhookRepo).Publish(context.Background(), &event)
+
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			webhookRepo.AssertExpectations(t)
+			if tc.wantType == "" {
+				webhookRepo.AssertNotCalled(t, "EnqueueDeliveries", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+
+			// The webhook event carries the outbox event ID, so a relayed event is queued once per webhook
+			var webhookEvent model.WebhookEvent
+			require.NoError(t, json.Unmarshal(body, &webhookEvent))
+			assert.Equal(t, event.ID.String(), webhookEvent.ID)
+			assert.Equal(t, tc.wantType, webhookEvent.Type)
+			assert.Equal(t, event.CreatedAt, webhookEvent.CreatedAt)
+			assert.Equal(t, todoID.String(), webhookEvent.Data.ID)
+			assert.Equal(t, "Buy milk", webhookEvent.Data.Title)
+		})
+	}
+}
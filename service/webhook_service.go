@@ -0,0 +1,276 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net"
+
+	"github.com/google/uuid"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+	"go.uber.org/zap"
+)
+
+// webhookDeliveryLogSize is the number of deliveries returned in a webhook's delivery log
+const webhookDeliveryLogSize = 100
+
+var (
+	// ErrWebhookNotFound is returned when a webhook with the specified ID is not found for the user
+	ErrWebhookNotFound = errors.New("webhook not found")
+
+	// ErrWebhookDeliveryNotFound is returned when a delivery with the specified ID is not found on the webhook
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+)
+
+// WebhookService defines the interface for managing webhook subscriptions
+type WebhookService interface {
+	// CreateWebhook subscribes the specified user to todo events with a newly generated secret
+	// The URL has to resolve to public addresses only
+	CreateWebhook(ctx context.Context, userID uuid.UUID, req model.CreateWebhookRequest) (*model.Webhook, error)
+
+	// GetWebhooks retrieves all webhooks of the specified user
+	GetWebhooks(ctx context.Context, userID uuid.UUID) ([]model.Webhook, error)
+
+	// GetWebhook retrieves a webhook, ensuring it belongs to the specified user
+	GetWebhook(ctx context.Context, userID uuid.UUID, webhookID uuid.UUID) (*model.Webhook, error)
+
+	// UpdateWebhook updates a webhook, ensuring it belongs to the specified user
+	UpdateWebhook(ctx context.Context, userID uuid.UUID, webhookID uuid.UUID, req model.UpdateWebhookRequest) (*model.Webhook, error)
+
+	// DeleteWebhook deletes a webhook, ensuring it belongs to the specified user
+	DeleteWebhook(ctx context.Context, userID uuid.UUID, webhookID uuid.UUID) error
+
+	// GetDeliveries retrieves the latest deliveries of a webhook belonging to the specified user
+	GetDeliveries(ctx context.Context, userID uuid.UUID, webhookID uuid.UUID) ([]model.WebhookDelivery, error)
+
+	// RedeliverDelivery queues a delivery of a webhook belonging to the specified user to be sent again
+	RedeliverDelivery(ctx context.Context, userID uuid.UUID, webhookID uuid.UUID, deliveryID uuid.UUID) (*model.WebhookDelivery, error)
+}
+
+// DefaultWebhookService implements the WebhookService interface
+type DefaultWebhookService struct {
+	webhookRepo   repository.WebhookRepository
+	resolver      HostResolver
+	webhookConfig *config.WebhookConfig
+	logger        *zap.Logger
+}
+
+// NewWebhookService creates a new DefaultWebhookService instance
+// If resolver is nil, the host names of webhook URLs are resolved with net.DefaultResolver
+func NewWebhookService(
+	webhookRepo repository.WebhookRepository,
+	resolver HostResolver,
+	webhookConfig *config.WebhookConfig,
+	logger *zap.Logger,
+) WebhookService {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	return &DefaultWebhookService{
+		webhookRepo:   webhookRepo,
+		resolver:      resolver,
+		webhookConfig: webhookConfig,
+		logger:        logger,
+	}
+}
+
+// checkURL returns ErrWebhookURLNotAllowed when a webhook URL points at an address that is not public,
+// unless the configuration allows private networks
+func (s *DefaultWebhookService) checkURL(ctx context.Context, userID uuid.UUID, rawURL string) error {
+	if s.webhookConfig.AllowPrivateNetworks {
+		return nil
+	}
+	if err := checkWebhookURL(ctx, s.resolver, rawURL); err != nil {
+		s.logger.Warn("webhook URL refused",
+			zap.String("user_id", userID.String()),
+			zap.String("url", rawURL))
+		return err
+	}
+	return nil
+}
+
+// CreateWebhook subscribes the specified user to todo events with a newly generated secret
+func (s *DefaultWebhookService) CreateWebhook(ctx context.Context, userID uuid.UUID, req model.CreateWebhookRequest) (*model.Webhook, error) {
+	if err := s.checkURL(ctx, userID, req.URL); err != nil {
+		return nil, err
+	}
+
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		s.logger.Error("failed to generate webhook secret",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	webhook := &model.Webhook{
+		UserID:     userID,
+		URL:        req.URL,
+		Secret:     secret,
+		EventTypes: req.EventTypes,
+		IsActive:   true,
+	}
+	err = s.webhookRepo.Create(ctx, webhook)
+	if err != nil {
+		s.logger.Error("failed to create webhook",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("webhook created successfully",
+		zap.String("user_id", userID.String()),
+		zap.String("webhook_id", webhook.ID.String()))
+
+	// Reload to get the timestamps
+	return s.GetWebhook(ctx, userID, webhook.ID)
+}
+
+// GetWebhooks retrieves all webhooks of the specified user
+func (s *DefaultWebhookService) GetWebhooks(ctx context.Context, userID uuid.UUID) ([]model.Webhook, error) {
+	webhooks, err := s.webhookRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		s.logger.Error("failed to get webhooks",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+// GetWebhook retrieves a webhook, ensuring it belongs to the specified user
+func (s *DefaultWebhookService) GetWebhook(ctx context.Context, userID uuid.UUID, webhookID uuid.UUID) (*model.Webhook, error) {
+	webhook, err := s.webhookRepo.GetByID(ctx, webhookID)
+	if err != nil {
+		s.logger.Error("failed to get webhook",
+			zap.String("user_id", userID.String()),
+			zap.String("webhook_id", webhookID.String()),
+			zap.Error(err))
+		return nil, ErrWebhookNotFound
+	}
+
+	// Webhooks of other users are reported as missing so their existence is not revealed
+	if webhook.UserID != userID {
+		s.logger.Warn("unauthorized access attempt to webhook",
+			zap.String("user_id", userID.String()),
+			zap.String("webhook_id", webhookID.String()))
+		return nil, ErrWebhookNotFound
+	}
+
+	return webhook, nil
+}
+
+// UpdateWebhook updates a webhook, ensuring it belongs to the specified user
+func (s *DefaultWebhookService) UpdateWebhook(ctx context.Context, userID uuid.UUID, webhookID uuid.UUID, req model.UpdateWebhookRequest) (*model.Webhook, error) {
+	webhook, err := s.GetWebhook(ctx, userID, webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkURL(ctx, userID, req.URL); err != nil {
+		return nil, err
+	}
+
+	webhook.URL = req.URL
+	webhook.EventTypes = req.EventTypes
+	webhook.IsActive = req.IsActive
+	err = s.webhookRepo.Update(ctx, webhook)
+	if err != nil {
+		s.logger.Error("failed to update webhook",
+			zap.String("user_id", userID.String()),
+			zap.String("webhook_id", webhookID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("webhook updated successfully",
+		zap.String("user_id", userID.String()),
+		zap.String("webhook_id", webhookID.String()))
+
+	// Reload to get the new updated_at
+	return s.GetWebhook(ctx, userID, webhookID)
+}
+
+// DeleteWebhook deletes a webhook, ensuring it belongs to the specified user
+func (s *DefaultWebhookService) DeleteWebhook(ctx context.Context, userID uuid.UUID, webhookID uuid.UUID) error {
+	_, err := s.GetWebhook(ctx, userID, webhookID)
+	if err != nil {
+		return err
+	}
+
+	err = s.webhookRepo.Delete(ctx, webhookID)
+	if err != nil {
+		s.logger.Error("failed to delete webhook",
+			zap.String("user_id", userID.String()),
+			zap.String("webhook_id", webhookID.String()),
+			zap.Error(err))
+		return err
+	}
+
+	s.logger.Info("webhook deleted successfully",
+		zap.String("user_id", userID.String()),
+		zap.String("webhook_id", webhookID.String()))
+	return nil
+}
+
+// GetDeliveries retrieves the latest deliveries of a webhook belonging to the specified user
+func (s *DefaultWebhookService) GetDeliveries(ctx context.Context, userID uuid.UUID, webhookID uuid.UUID) ([]model.WebhookDelivery, error) {
+	_, err := s.GetWebhook(ctx, userID, webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries, err := s.webhookRepo.GetDeliveriesByWebhookID(ctx, webhookID, webhookDeliveryLogSize)
+	if err != nil {
+		s.logger.Error("failed to get webhook deliveries",
+			zap.String("user_id", userID.String()),
+			zap.String("webhook_id", webhookID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// RedeliverDelivery queues a delivery of a webhook belonging to the specified user to be sent again
+func (s *DefaultWebhookService) RedeliverDelivery(ctx context.Context, userID uuid.UUID, webhookID uuid.UUID, deliveryID uuid.UUID) (*model.WebhookDelivery, error) {
+	_, err := s.GetWebhook(ctx, userID, webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery, err := s.webhookRepo.GetDeliveryByID(ctx, deliveryID)
+	if err != nil || delivery.WebhookID != webhookID {
+		return nil, ErrWebhookDeliveryNotFound
+	}
+
+	err = s.webhookRepo.ResetDelivery(ctx, deliveryID)
+	if err != nil {
+		s.logger.Error("failed to reset webhook delivery",
+			zap.String("user_id", userID.String()),
+			zap.String("delivery_id", deliveryID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("webhook delivery queued for redelivery",
+		zap.String("user_id", userID.String()),
+		zap.String("webhook_id", webhookID.String()),
+		zap.String("delivery_id", deliveryID.String()))
+
+	// Reload to get the new state
+	return s.webhookRepo.GetDeliveryByID(ctx, deliveryID)
+}
+
+// generateWebhookSecret generates a random secret used to sign deliveries
+func generateWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(secret), nil
+}
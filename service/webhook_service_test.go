@@ -0,0 +1,205 @@
+package service_test
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+	"go.uber.org/zap"
+)
+
+// staticResolver resolves host names to fixed addresses, failing for unknown hosts
+type staticResolver map[string][]netip.Addr
+
+func (r staticResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	addrs, ok := r[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return addrs, nil
+}
+
+// testResolver resolves example.com to a public address and the other test hosts to private ones
+var testResolver = staticResolver{
+	"example.com":          {netip.MustParseAddr("93.184.215.14")},
+	"internal.example.com": {netip.MustParseAddr("10.0.0.5")},
+	"mixed.example.com":    {netip.MustParseAddr("93.184.215.14"), netip.MustParseAddr("169.254.169.254")},
+}
+
+func TestCreateWebhook(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+
+	mockWebhookRepo := new(MockWebhookRepository)
+	mockWebhookRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.Webhook")).Return(nil).Run(func(args mock.Arguments) {
+		created := args.Get(1).(*model.Webhook)
+		created.ID = uuid.New()
+		mockWebhookRepo.On("GetByID", mock.Anything, created.ID).Return(created, nil)
+	})
+
+	webhookService := service.NewWebhookService(mockWebhookRepo, testResolver, config.DefaultWebhookConfig(), zap.NewNop())
+	webhook, err := webhookService.CreateWebhook(ctx, userID, model.CreateWebhookRequest{
+		URL:        "https://example.com/hook",
+		EventTypes: []string{model.WebhookEventTodoCreated},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, userID, webhook.UserID)
+	assert.True(t, webhook.IsActive)
+	assert.True(t, strings.HasPrefix(webhook.Secret, "whsec_"))
+	assert.Len(t, webhook.Secret, len("whsec_")+64)
+}
+
+func TestWebhookURLMustBePublic(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+
+	testCases := []struct {
+		url     string
+		allowed bool
+	}{
+		{url: "https://example.com/hook", allowed: true},
+		{url: "https://93.184.215.14/hook", allowed: true},
+		{url: "http://127.0.0.1:8080/hook"},
+		{url: "http://localhost/hook"},
+		{url: "http://10.1.2.3/hook"},
+		{url: "http://192.168.1.1/hook"},
+		{url: "http://169.254.169.254/latest/meta-data/"},
+		{url: "http://[::1]/hook"},
+		{url: "http://[fd00::1]/hook"},
+		{url: "http://[::ffff:127.0.0.1]/hook"},
+		{url: "http://0.0.0.0/hook"},
+		{url: "https://internal.example.com/hook"},
+		{url: "https://mixed.example.com/hook"},
+		{url: "https://unknown.example.com/hook"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.url, func(t *testing.T) {
+			mockWebhookRepo := new(MockWebhookRepository)
+			mockWebhookRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.Webhook")).Return(nil).Run(func(args mock.Arguments) {
+				created := args.Get(1).(*model.Webhook)
+				created.ID = uuid.New()
+				mockWebhookRepo.On("GetByID", mock.Anything, created.ID).Return(created, nil)
+			})
+			webhookService := service.NewWebhookService(mockWebhookRepo, testResolver, config.DefaultWebhookConfig(), zap.NewNop())
+
+			_, err := webhookService.CreateWebhook(ctx, userID, model.CreateWebhookRequest{
+				URL:        tc.url,
+				EventTypes: []string{model.WebhookEventTodoCreated},
+			})
+
+			if tc.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.Equal(t, service.ErrWebhookURLNotAllowed, err)
+				mockWebhookRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+			}
+		})
+	}
+
+	t.Run("Allowed on private networks when configured", func(t *testing.T) {
+		webhookConfig := config.DefaultWebhookConfig()
+		webhookConfig.AllowPrivateNetworks = true
+		mockWebhookRepo := new(MockWebhookRepository)
+		mockWebhookRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.Webhook")).Return(nil).Run(func(args mock.Arguments) {
+			created := args.Get(1).(*model.Webhook)
+			created.ID = uuid.New()
+			mockWebhookRepo.On("GetByID", mock.Anything, created.ID).Return(created, nil)
+		})
+		webhookService := service.NewWebhookService(mockWebhookRepo, testResolver, webhookConfig, zap.NewNop())
+
+		_, err := webhookService.CreateWebhook(ctx, userID, model.CreateWebhookRequest{
+			URL:        "http://127.0.0.1:8080/hook",
+			EventTypes: []string{model.WebhookEventTodoCreated},
+		})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("Update to a private address refused", func(t *testing.T) {
+		webhookID := uuid.New()
+		mockWebhookRepo := new(MockWebhookRepository)
+		mockWebhookRepo.On("GetByID", mock.Anything, webhookID).
+			Return(&model.Webhook{ID: webhookID, UserID: userID, URL: "https://example.com/hook"}, nil)
+		webhookService := service.NewWebhookService(mockWebhookRepo, testResolver, config.DefaultWebhookConfig(), zap.NewNop())
+
+		_, err := webhookService.UpdateWebhook(ctx, userID, webhookID, model.UpdateWebhookRequest{
+			URL:        "http://169.254.169.254/latest/meta-data/",
+			EventTypes: []string{model.WebhookEventTodoCreated},
+			IsActive:   true,
+		})
+
+		assert.Equal(t, service.ErrWebhookURLNotAllowed, err)
+		mockWebhookRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	})
+}
+
+func TestWebhookOwnership(t *testing.T) {
+	ctx := context.Background()
+	ownerID := uuid.New()
+	webhookID := uuid.New()
+	deliveryID := uuid.New()
+
+	setup := func() (service.WebhookService, *MockWebhookRepository) {
+		mockWebhookRepo := new(MockWebhookRepository)
+		mockWebhookRepo.On("GetByID", mock.Anything, webhookID).Return(&model.Webhook{ID: webhookID, UserID: ownerID}, nil)
+		mockWebhookRepo.On("GetByID", mock.Anything, mock.Anything).Return(nil, sql.ErrNoRows)
+		return service.NewWebhookService(mockWebhookRepo, testResolver, config.DefaultWebhookConfig(), zap.NewNop()), mockWebhookRepo
+	}
+
+	t.Run("other users cannot see the webhook", func(t *testing.T) {
+		webhookService, _ := setup()
+
+		_, err := webhookService.GetWebhook(ctx, uuid.New(), webhookID)
+
+		assert.Equal(t, service.ErrWebhookNotFound, err)
+	})
+
+	t.Run("other users cannot delete the webhook", func(t *testing.T) {
+		webhookService, webhookRepo := setup()
+
+		err := webhookService.DeleteWebhook(ctx, uuid.New(), webhookID)
+
+		assert.Equal(t, service.ErrWebhookNotFound, err)
+		webhookRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+
+	t.Run("owner redelivers a dead delivery", func(t *testing.T) {
+		webhookService, webhookRepo := setup()
+		webhookRepo.On("GetDeliveryByID", mock.Anything, deliveryID).Return(&model.WebhookDelivery{
+			ID:        deliveryID,
+			WebhookID: webhookID,
+			Status:    model.WebhookDeliveryDead,
+		}, nil)
+		webhookRepo.On("ResetDelivery", mock.Anything, deliveryID).Return(nil)
+
+		_, err := webhookService.RedeliverDelivery(ctx, ownerID, webhookID, deliveryID)
+
+		require.NoError(t, err)
+		webhookRepo.AssertCalled(t, "ResetDelivery", mock.Anything, deliveryID)
+	})
+
+	t.Run("delivery of another webhook is not found", func(t *testing.T) {
+		webhookService, webhookRepo := setup()
+		webhookRepo.On("GetDeliveryByID", mock.Anything, deliveryID).Return(&model.WebhookDelivery{
+			ID:        deliveryID,
+			WebhookID: uuid.New(),
+		}, nil)
+
+		_, err := webhookService.RedeliverDelivery(ctx, ownerID, webhookID, deliveryID)
+
+		assert.Equal(t, service.ErrWebhookDeliveryNotFound, err)
+		webhookRepo.AssertNotCalled(t, "ResetDelivery", mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/repository"
+	"go.uber.org/zap"
+)
+
+// Headers sent with every webhook delivery
+const (
+	WebhookSignatureHeader  = "X-Todoms-Signature"
+	WebhookEventHeader      = "X-Todoms-Event"
+	WebhookEventIDHeader    = "X-Todoms-Event-Id"
+	WebhookDeliveryIDHeader = "X-Todoms-Delivery"
+)
+
+// maxWebhookErrorLength is the maximum length of the error recorded for a failed attempt
+const maxWebhookErrorLength = 500
+
+// WebhookWorker delivers queued webhook events in the background
+type WebhookWorker struct {
+	webhookRepo   repository.WebhookRepository
+	client        *http.Client
+	webhookConfig *config.WebhookConfig
+	logger        *zap.Logger
+}
+
+// NewWebhookWorker creates a new WebhookWorker instance
+// If client is nil, a client with the configured timeout that does not follow redirects is used,
+// which refuses to connect to addresses that are not public unless the configuration allows private networks
+func NewWebhookWorker(webhookRepo repository.WebhookRepository, client *http.Client, webhookConfig *config.WebhookConfig, logger *zap.Logger) *WebhookWorker {
+	if client == nil {
+		client = newWebhookHTTPClient(webhookConfig.Timeout, webhookConfig.AllowPrivateNetworks)
+	}
+	return &WebhookWorker{
+		webhookRepo:   webhookRepo,
+		client:        client,
+		webhookConfig: webhookConfig,
+		logger:        logger,
+	}
+}
+
+// Run delivers due events every interval until the context is cancelled
+func (w *WebhookWorker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.DeliverDue(ctx)
+		}
+	}
+}
+
+// DeliverDue sends one batch of due deliveries concurrently and records the results
+// It returns the number of deliveries attempted
+func (w *WebhookWorker) DeliverDue(ctx context.Context) (int, error) {
+	// Lease the deliveries for longer than an attempt can take, so they are not picked up twice
+	lease := 2 * w.webhookConfig.Timeout
+	deliveries, err := w.webhookRepo.ClaimDueDeliveries(ctx, w.webhookConfig.BatchSize, lease)
+	if err != nil {
+		w.logger.Error("failed to claim webhook deliveries",
+			zap.Error(err))
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+	for i := range deliveries {
+		wg.Add(1)
+		go func(delivery *model.WebhookDelivery) {
+			defer wg.Done()
+			w.deliver(ctx, delivery)
+		}(&deliveries[i])
+	}
+	wg.Wait()
+
+	return len(deliveries), nil
+}
+
+// deliver makes one attempt to send a delivery and records the result
+func (w *WebhookWorker) deliver(ctx context.Context, delivery *model.WebhookDelivery) {
+	statusCode, err := w.send(ctx, delivery)
+
+	delivery.Attempts++
+	delivery.LastStatusCode = statusCode
+	delivery.LastError = nil
+	if err == nil {
+		delivery.Status = model.WebhookDeliverySucceeded
+	} else {
+		message := err.Error()
+		if len(message) > maxWebhookErrorLength {
+			message = message[:maxWebhookErrorLength]
+		}
+		delivery.LastError = &message
+
+		if delivery.Attempts >= w.webhookConfig.MaxAttempts {
+			delivery.Status = model.WebhookDeliveryDead
+			w.logger.Warn("webhook delivery dead-lettered",
+				zap.String("delivery_id", delivery.ID.String()),
+				zap.String("webhook_id", delivery.WebhookID.String()),
+				zap.Int("attempts", delivery.Attempts),
+				zap.Error(err))
+		} else {
+			delivery.Status = model.WebhookDeliveryPending
+			delivery.NextAttemptAt = time.Now().Add(w.backoff(delivery.Attempts))
+			w.logger.Info("webhook delivery failed, will retry",
+				zap.String("delivery_id", delivery.ID.String()),
+				zap.String("webhook_id", delivery.WebhookID.String()),
+				zap.Int("attempts", delivery.Attempts),
+				zap.Time("next_attempt_at", delivery.NextAttemptAt),
+				zap.Error(err))
+		}
+	}
+
+	if err := w.webhookRepo.UpdateDelivery(ctx, delivery); err != nil {
+		// The lease expires and the delivery is attempted again, which receivers must tolerate anyway
+		w.logger.Error("failed to record webhook delivery result",
+			zap.String("delivery_id", delivery.ID.String()),
+			zap.Error(err))
+	}
+}
+
+// send posts the signed payload to the webhook URL, succeeding only on a 2xx response
+func (w *WebhookWorker) send(ctx context.Context, delivery *model.WebhookDelivery) (*int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "todoms-webhook/1.0")
+	req.Header.Set(WebhookEventHeader, delivery.EventType)
+	req.Header.Set(WebhookEventIDHeader, delivery.EventID.String())
+	req.Header.Set(WebhookDeliveryIDHeader, delivery.ID.String())
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	// Drain a little of the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	statusCode := resp.StatusCode
+	if statusCode < 200 || statusCode > 299 {
+		return &statusCode, fmt.Errorf("unexpected status %d", statusCode)
+	}
+	return &statusCode, nil
+}
+
+// backoff returns the delay before the next attempt after the given number of failed attempts
+func (w *WebhookWorker) backoff(attempts int) time.Duration {
+	delay := w.webhookConfig.BaseBackoff
+	for i := 1; i < attempts && delay < w.webhookConfig.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > w.webhookConfig.MaxBackoff {
+		delay = w.webhookConfig.MaxBackoff
+	}
+	return delay
+}
+
+// SignWebhookPayload computes the signature header value of a delivery
+// The format is "t=<unix timestamp>,v1=<hex HMAC-SHA256 of "<timestamp>.<body>" keyed with the secret>"
+// Receivers should recompute it and reject deliveries with a stale timestamp to prevent replays
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	ts := strconv.FormatInt(timestamp, 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
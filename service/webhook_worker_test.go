@@ -0,0 +1,234 @@
+package service_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/yukimaterrace/todoms/config"
+	"github.com/yukimaterrace/todoms/model"
+	"github.com/yukimaterrace/todoms/service"
+	"go.uber.org/zap"
+)
+
+// webhookReceiver is an httptest receiver that records the requests it gets
+type webhookReceiver struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	status   int
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	r.mu.Unlock()
+	w.WriteHeader(r.status)
+}
+
+// setupWebhookWorker creates a worker whose repository hands out the given deliveries once
+// and records the results written back
+// The worker may deliver to private networks, as the receivers are httptest servers on the loopback address
+func setupWebhookWorker(deliveries []model.WebhookDelivery) (*service.WebhookWorker, *[]model.WebhookDelivery) {
+	return setupWebhookWorkerWithConfig(deliveries, &config.WebhookConfig{
+		MaxAttempts:          3,
+		BaseBackoff:          time.Minute,
+		MaxBackoff:           90 * time.Minute,
+		Timeout:              time.Second,
+		BatchSize:            10,
+		AllowPrivateNetworks: true,
+	})
+}
+
+// setupWebhookWorkerWithConfig is setupWebhookWorker with the given configuration
+func setupWebhookWorkerWithConfig(deliveries []model.WebhookDelivery, webhookConfig *config.WebhookConfig) (*service.WebhookWorker, *[]model.WebhookDelivery) {
+
+	var mu sync.Mutex
+	results := []model.WebhookDelivery{}
+	mockWebhookRepo := new(MockWebhookRepository)
+	mockWebhookRepo.On("ClaimDueDeliveries", mock.Anything, 10, 2*time.Second).Return(deliveries, nil).Once()
+	mockWebhookRepo.On("UpdateDelivery", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		mu.Lock()
+		defer mu.Unlock()
+		results = append(results, *args.Get(1).(*model.WebhookDelivery))
+	})
+
+	worker := service.NewWebhookWorker(mockWebhookRepo, nil, webhookConfig, zap.NewNop())
+	return worker, &results
+}
+
+func newTestDelivery(url string, attempts int) model.WebhookDelivery {
+	return model.WebhookDelivery{
+		ID:        uuid.New(),
+		WebhookID: uuid.New(),
+		EventID:   uuid.New(),
+		EventType: model.WebhookEventTodoCompleted,
+		Payload:   []byte(`{"type":"todo.completed"}`),
+		Status:    model.WebhookDeliveryPending,
+		Attempts:  attempts,
+		URL:       url,
+		Secret:    "whsec_test",
+	}
+}
+
+func TestWebhookWorkerDeliversSignedPayload(t *testing.T) {
+	receiver := &webhookReceiver{status: http.StatusNoContent}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	delivery := newTestDelivery(server.URL, 0)
+	worker, results := setupWebhookWorker([]model.WebhookDelivery{delivery})
+
+	count, err := worker.DeliverDue(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	require.Len(t, receiver.requests, 1)
+	req := receiver.requests[0]
+	assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+	assert.Equal(t, model.WebhookEventTodoCompleted, req.Header.Get(service.WebhookEventHeader))
+	assert.Equal(t, delivery.EventID.String(), req.Header.Get(service.WebhookEventIDHeader))
+	assert.Equal(t, delivery.ID.String(), req.Header.Get(service.WebhookDeliveryIDHeader))
+	assert.JSONEq(t, `{"type":"todo.completed"}`, string(receiver.bodies[0]))
+
+	// The receiver can verify the signature with the shared secret
+	signature := req.Header.Get(service.WebhookSignatureHeader)
+	timestampPart := strings.SplitN(signature, ",", 2)[0]
+	timestamp, err := strconv.ParseInt(strings.TrimPrefix(timestampPart, "t="), 10, 64)
+	require.NoError(t, err)
+	assert.Equal(t, service.SignWebhookPayload("whsec_test", timestamp, receiver.bodies[0]), signature)
+	assert.NotEqual(t, service.SignWebhookPayload("wrong", timestamp, receiver.bodies[0]), signature)
+
+	require.Len(t, *results, 1)
+	result := (*results)[0]
+	assert.Equal(t, model.WebhookDeliverySucceeded, result.Status)
+	assert.Equal(t, 1, result.Attempts)
+	assert.Equal(t, http.StatusNoContent, *result.LastStatusCode)
+	assert.Nil(t, result.LastError)
+}
+
+func TestWebhookWorkerRetriesWithBackoff(t *testing.T) {
+	receiver := &webhookReceiver{status: http.StatusInternalServerError}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	tests := []struct {
+		name          string
+		attempts      int
+		wantStatus    model.WebhookDeliveryStatus
+		wantBackoff   time.Duration
+		wantAttempted int
+	}{
+		{
+			name:          "first failure retries after the base backoff",
+			attempts:      0,
+			wantStatus:    model.WebhookDeliveryPending,
+			wantBackoff:   time.Minute,
+			wantAttempted: 1,
+		},
+		{
+			name:          "second failure doubles the backoff",
+			attempts:      1,
+			wantStatus:    model.WebhookDeliveryPending,
+			wantBackoff:   2 * time.Minute,
+			wantAttempted: 2,
+		},
+		{
+			name:          "last failure is dead-lettered",
+			attempts:      2,
+			wantStatus:    model.WebhookDeliveryDead,
+			wantAttempted: 3,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			worker, results := setupWebhookWorker([]model.WebhookDelivery{newTestDelivery(server.URL, tc.attempts)})
+
+			before := time.Now()
+			_, err := worker.DeliverDue(context.Background())
+
+			require.NoError(t, err)
+			require.Len(t, *results, 1)
+			result := (*results)[0]
+			assert.Equal(t, tc.wantStatus, result.Status)
+			assert.Equal(t, tc.wantAttempted, result.Attempts)
+			assert.Equal(t, http.StatusInternalServerError, *result.LastStatusCode)
+			assert.Equal(t, "unexpected status 500", *result.LastError)
+			if tc.wantStatus == model.WebhookDeliveryPending {
+				assert.WithinDuration(t, before.Add(tc.wantBackoff), result.NextAttemptAt, 5*time.Second)
+			}
+		})
+	}
+}
+
+func TestWebhookWorkerRecordsConnectionErrors(t *testing.T) {
+	// A closed server refuses the connection
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	worker, results := setupWebhookWorker([]model.WebhookDelivery{newTestDelivery(url, 0)})
+
+	_, err := worker.DeliverDue(context.Background())
+
+	require.NoError(t, err)
+	require.Len(t, *results, 1)
+	result := (*results)[0]
+	assert.Equal(t, model.WebhookDeliveryPending, result.Status)
+	assert.Nil(t, result.LastStatusCode)
+	require.NotNil(t, result.LastError)
+}
+
+func TestWebhookWorkerDoesNotFollowRedirects(t *testing.T) {
+	target := &webhookReceiver{status: http.StatusOK}
+	targetServer := httptest.NewServer(target)
+	defer targetServer.Close()
+	redirectServer := httptest.NewServer(http.RedirectHandler(targetServer.URL, http.StatusFound))
+	defer redirectServer.Close()
+
+	worker, results := setupWebhookWorker([]model.WebhookDelivery{newTestDelivery(redirectServer.URL, 0)})
+
+	_, err := worker.DeliverDue(context.Background())
+
+	require.NoError(t, err)
+	assert.Empty(t, target.requests)
+	require.Len(t, *results, 1)
+	assert.Equal(t, http.StatusFound, *(*results)[0].LastStatusCode)
+	assert.Equal(t, model.WebhookDeliveryPending, (*results)[0].Status)
+}
+
+func TestWebhookWorkerRefusesPrivateAddresses(t *testing.T) {
+	receiver := &webhookReceiver{status: http.StatusOK}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	worker, results := setupWebhookWorkerWithConfig([]model.WebhookDelivery{newTestDelivery(server.URL, 0)}, &config.WebhookConfig{
+		MaxAttempts: 3,
+		BaseBackoff: time.Minute,
+		MaxBackoff:  90 * time.Minute,
+		Timeout:     time.Second,
+		BatchSize:   10,
+	})
+
+	_, err := worker.DeliverDue(context.Background())
+
+	require.NoError(t, err)
+	assert.Empty(t, receiver.requests)
+	require.Len(t, *results, 1)
+	assert.Nil(t, (*results)[0].LastStatusCode)
+	require.NotNil(t, (*results)[0].LastError)
+	assert.Contains(t, *(*results)[0].LastError, service.ErrWebhookURLNotAllowed.Error())
+}